@@ -0,0 +1,323 @@
+// Package client contains a client for the HTTP API of the server.
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+)
+
+// ErrNotFound is returned by Kick when no session or connection has the given ID.
+var ErrNotFound = errors.New("not found")
+
+// StatusError is returned when the server replies with a status code that is not 200.
+type StatusError struct {
+	StatusCode int
+}
+
+// Error implements the error interface.
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("bad status code: %d", e.StatusCode)
+}
+
+// Client is a client for the HTTP API of the server.
+type Client struct {
+	// address of the API, i.e. "http://localhost:9997".
+	URL string
+
+	// credentials, in case the API or the tenant requires them.
+	User string
+	Pass string
+
+	// HTTP client used to perform requests.
+	// It defaults to http.DefaultClient.
+	HTTPClient *http.Client
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient != nil {
+		return c.HTTPClient
+	}
+	return http.DefaultClient
+}
+
+func (c *Client) newRequest(ctx context.Context, method string, path string, in interface{}) (*http.Request, error) {
+	var body io.Reader
+	if in != nil {
+		byts, err := json.Marshal(in)
+		if err != nil {
+			return nil, err
+		}
+		body = bytes.NewReader(byts)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, strings.TrimRight(c.URL, "/")+path, body)
+	if err != nil {
+		return nil, err
+	}
+
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	if c.User != "" {
+		req.SetBasicAuth(c.User, c.Pass)
+	}
+
+	return req, nil
+}
+
+// do performs a request, encoding in and decoding the response into out, when they are not nil.
+func (c *Client) do(ctx context.Context, method string, path string, in interface{}, out interface{}) error {
+	req, err := c.newRequest(ctx, method, path, in)
+	if err != nil {
+		return err
+	}
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: res.StatusCode}
+	}
+
+	if out == nil {
+		return nil
+	}
+
+	return json.NewDecoder(res.Body).Decode(out)
+}
+
+// ConfigGet returns the configuration.
+func (c *Client) ConfigGet(ctx context.Context) (map[string]interface{}, error) {
+	var out map[string]interface{}
+	err := c.do(ctx, http.MethodGet, "/v1/config/get", nil, &out)
+	return out, err
+}
+
+// ConfigSet changes the given parameters of the configuration.
+func (c *Client) ConfigSet(ctx context.Context, params map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, "/v1/config/set", params, nil)
+}
+
+// ConfigLogLevel changes the log levels without reloading the configuration.
+func (c *Client) ConfigLogLevel(ctx context.Context, req ConfigLogLevelReq) error {
+	return c.do(ctx, http.MethodPatch, "/v1/config/loglevel", req, nil)
+}
+
+// ConfigPathsAdd adds the configuration of a path.
+func (c *Client) ConfigPathsAdd(ctx context.Context, name string, params map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, "/v1/config/paths/add/"+name, params, nil)
+}
+
+// ConfigPathsEdit changes the given parameters of the configuration of a path.
+func (c *Client) ConfigPathsEdit(ctx context.Context, name string, params map[string]interface{}) error {
+	return c.do(ctx, http.MethodPost, "/v1/config/paths/edit/"+name, params, nil)
+}
+
+// ConfigPathsRemove removes the configuration of a path.
+func (c *Client) ConfigPathsRemove(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "/v1/config/paths/remove/"+name, nil, nil)
+}
+
+// PathsList returns the active paths.
+func (c *Client) PathsList(ctx context.Context) (*PathsList, error) {
+	var out PathsList
+	err := c.do(ctx, http.MethodGet, "/v1/paths/list", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PathsHistory returns the publisher sessions of a path.
+func (c *Client) PathsHistory(ctx context.Context, name string) (*PathHistory, error) {
+	var out PathHistory
+	err := c.do(ctx, http.MethodGet, "/v1/paths/"+name+"/history", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PathsReaderRejections returns the rejected reader attempts of a path.
+func (c *Client) PathsReaderRejections(ctx context.Context, name string) (*PathReaderRejections, error) {
+	var out PathReaderRejections
+	err := c.do(ctx, http.MethodGet, "/v1/paths/"+name+"/readerrejections", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// PathsDisable disables a path.
+func (c *Client) PathsDisable(ctx context.Context, name string, req PathsDisableReq) error {
+	return c.do(ctx, http.MethodPost, "/v1/paths/"+name+"/disable", req, nil)
+}
+
+// PathsEnable enables a path that has been disabled.
+func (c *Client) PathsEnable(ctx context.Context, name string) error {
+	return c.do(ctx, http.MethodPost, "/v1/paths/"+name+"/enable", nil, nil)
+}
+
+// PathsDisabled returns the paths that have been disabled.
+func (c *Client) PathsDisabled(ctx context.Context) (*PathsDisabled, error) {
+	var out PathsDisabled
+	err := c.do(ctx, http.MethodGet, "/v1/paths/disabled", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RTSPConnsList returns the connections of the RTSP server.
+func (c *Client) RTSPConnsList(ctx context.Context) (*RTSPConnsList, error) {
+	return c.rtspConnsList(ctx, "/v1/rtspconns/list")
+}
+
+// RTSPSConnsList returns the connections of the RTSPS server.
+func (c *Client) RTSPSConnsList(ctx context.Context) (*RTSPConnsList, error) {
+	return c.rtspConnsList(ctx, "/v1/rtspsconns/list")
+}
+
+func (c *Client) rtspConnsList(ctx context.Context, path string) (*RTSPConnsList, error) {
+	var out RTSPConnsList
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RTSPSessionsList returns the sessions of the RTSP server.
+func (c *Client) RTSPSessionsList(ctx context.Context) (*RTSPSessionsList, error) {
+	return c.rtspSessionsList(ctx, "/v1/rtspsessions/list")
+}
+
+// RTSPSSessionsList returns the sessions of the RTSPS server.
+func (c *Client) RTSPSSessionsList(ctx context.Context) (*RTSPSessionsList, error) {
+	return c.rtspSessionsList(ctx, "/v1/rtspssessions/list")
+}
+
+func (c *Client) rtspSessionsList(ctx context.Context, path string) (*RTSPSessionsList, error) {
+	var out RTSPSessionsList
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RTSPMulticastGroupsList returns the UDP-multicast groups of the RTSP server.
+func (c *Client) RTSPMulticastGroupsList(ctx context.Context) (*RTSPMulticastGroupsList, error) {
+	var out RTSPMulticastGroupsList
+	err := c.do(ctx, http.MethodGet, "/v1/rtspmulticastgroups/list", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RTSPSessionsKick kicks out a session of the RTSP server.
+func (c *Client) RTSPSessionsKick(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/rtspsessions/kick/"+id, nil, nil)
+}
+
+// RTSPSSessionsKick kicks out a session of the RTSPS server.
+func (c *Client) RTSPSSessionsKick(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/rtspssessions/kick/"+id, nil, nil)
+}
+
+// RTMPConnsList returns the connections of the RTMP server.
+func (c *Client) RTMPConnsList(ctx context.Context) (*RTMPConnsList, error) {
+	return c.rtmpConnsList(ctx, "/v1/rtmpconns/list")
+}
+
+// RTMPSConnsList returns the connections of the RTMPS server.
+func (c *Client) RTMPSConnsList(ctx context.Context) (*RTMPConnsList, error) {
+	return c.rtmpConnsList(ctx, "/v1/rtmpsconns/list")
+}
+
+func (c *Client) rtmpConnsList(ctx context.Context, path string) (*RTMPConnsList, error) {
+	var out RTMPConnsList
+	err := c.do(ctx, http.MethodGet, path, nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// RTMPConnsKick kicks out a connection of the RTMP server.
+func (c *Client) RTMPConnsKick(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/rtmpconns/kick/"+id, nil, nil)
+}
+
+// RTMPSConnsKick kicks out a connection of the RTMPS server.
+func (c *Client) RTMPSConnsKick(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/rtmpsconns/kick/"+id, nil, nil)
+}
+
+// WebRTCConnsList returns the connections of the WebRTC server.
+func (c *Client) WebRTCConnsList(ctx context.Context) (*WebRTCConnsList, error) {
+	var out WebRTCConnsList
+	err := c.do(ctx, http.MethodGet, "/v1/webrtcconns/list", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// WebRTCConnsKick kicks out a connection of the WebRTC server.
+func (c *Client) WebRTCConnsKick(ctx context.Context, id string) error {
+	return c.do(ctx, http.MethodPost, "/v1/webrtcconns/kick/"+id, nil, nil)
+}
+
+// HLSMuxersList returns the muxers of the HLS server.
+func (c *Client) HLSMuxersList(ctx context.Context) (*HLSMuxersList, error) {
+	var out HLSMuxersList
+	err := c.do(ctx, http.MethodGet, "/v1/hlsmuxers/list", nil, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// BulkKick kicks out the RTSP sessions, RTSPS sessions, RTMP connections,
+// RTMPS connections and WebRTC connections with the given IDs.
+func (c *Client) BulkKick(ctx context.Context, ids []string) (*BulkKickRes, error) {
+	in := struct {
+		IDs []string `json:"ids"`
+	}{
+		IDs: ids,
+	}
+
+	var out BulkKickRes
+	err := c.do(ctx, http.MethodPost, "/v1/bulkkick", in, &out)
+	if err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// Kick kicks out the session or connection with the given ID, regardless of its protocol.
+// It returns ErrNotFound if no session or connection has the ID.
+func (c *Client) Kick(ctx context.Context, id string) error {
+	res, err := c.BulkKick(ctx, []string{id})
+	if err != nil {
+		return err
+	}
+
+	if len(res.Kicked) == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
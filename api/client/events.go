@@ -0,0 +1,113 @@
+package client
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// EventType is the type of an event.
+type EventType string
+
+// event types.
+const (
+	EventPathReady        EventType = "pathReady"
+	EventPathNotReady     EventType = "pathNotReady"
+	EventClientConnect    EventType = "clientConnect"
+	EventClientDisconnect EventType = "clientDisconnect"
+	EventConfReload       EventType = "confReload"
+	EventSCTE35           EventType = "scte35"
+)
+
+// SCTE35Cue is a SCTE-35 cue received from the source of a path.
+type SCTE35Cue struct {
+	Command            string   `json:"command"`
+	Type               string   `json:"type"`
+	EventID            *uint32  `json:"eventId"`
+	Cancel             bool     `json:"cancel"`
+	Immediate          bool     `json:"immediate"`
+	Duration           *float64 `json:"duration"`
+	AutoReturn         bool     `json:"autoReturn"`
+	SegmentationTypeID *uint8   `json:"segmentationTypeId"`
+	Data               []byte   `json:"data"`
+}
+
+// Event is an event sent by the server.
+type Event struct {
+	Type EventType `json:"type"`
+	Time time.Time `json:"time"`
+	Path string    `json:"path,omitempty"`
+
+	// source or reader that connected to or disconnected from the path.
+	Client *SourceOrReader `json:"client,omitempty"`
+
+	// "publisher" or "reader".
+	Role string `json:"role,omitempty"`
+
+	// cue received from the source.
+	SCTE35 *SCTE35Cue `json:"scte35,omitempty"`
+}
+
+// WatchEvents calls cb for every event sent by the server.
+// If paths is not empty, only events of the given paths, and events without a path, are received.
+// It blocks until the context is canceled, or until the server closes the stream,
+// that happens when the API is closed or when the client doesn't keep up with events.
+func (c *Client) WatchEvents(ctx context.Context, paths []string, cb func(*Event)) error {
+	u := "/v1/events"
+	if len(paths) != 0 {
+		q := url.Values{}
+		for _, p := range paths {
+			q.Add("path", p)
+		}
+		u += "?" + q.Encode()
+	}
+
+	req, err := c.newRequest(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "text/event-stream")
+
+	res, err := c.httpClient().Do(req)
+	if err != nil {
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return &StatusError{StatusCode: res.StatusCode}
+	}
+
+	br := bufio.NewReader(res.Body)
+
+	for {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			return err
+		}
+
+		data, ok := strings.CutPrefix(strings.TrimRight(line, "\r\n"), "data: ")
+		if !ok {
+			continue
+		}
+
+		var ev Event
+		err = json.Unmarshal([]byte(data), &ev)
+		if err != nil {
+			return fmt.Errorf("unable to decode event: %v", err)
+		}
+
+		cb(&ev)
+	}
+}
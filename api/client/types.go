@@ -0,0 +1,203 @@
+package client
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// SourceOrReader is the source or a reader of a path.
+type SourceOrReader struct {
+	Type string `json:"type"`
+	ID   string `json:"id"`
+
+	// fields that depend on the type, i.e. the transport of RTSP sources.
+	Raw json.RawMessage `json:"-"`
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (s *SourceOrReader) UnmarshalJSON(b []byte) error {
+	type alias SourceOrReader
+	var tmp alias
+	if err := json.Unmarshal(b, &tmp); err != nil {
+		return err
+	}
+
+	*s = SourceOrReader(tmp)
+	s.Raw = append(json.RawMessage(nil), b...)
+	return nil
+}
+
+// PathAlert is an alert raised by the analyzers of a path.
+type PathAlert struct {
+	Type  string    `json:"type"`
+	Track string    `json:"track"`
+	Since time.Time `json:"since"`
+}
+
+// PathsListItem is an entry of PathsList.
+type PathsListItem struct {
+	ConfName      string                 `json:"confName"`
+	Conf          map[string]interface{} `json:"conf"`
+	Source        *SourceOrReader        `json:"source"`
+	SourceReady   bool                   `json:"sourceReady"`
+	SourceError   *string                `json:"sourceError"`
+	Tracks        []string               `json:"tracks"`
+	SSRCs         []*uint32              `json:"ssrcs"`
+	BytesReceived uint64                 `json:"bytesReceived"`
+	ReaderCount   int                    `json:"readerCount"`
+	Readers       []SourceOrReader       `json:"readers"`
+	Alerts        []PathAlert            `json:"alerts"`
+	Health        *int                   `json:"health"`
+}
+
+// PathsList is the response of PathsList.
+type PathsList struct {
+	Items map[string]PathsListItem `json:"items"`
+}
+
+// PathHistoryItem is a publisher session of a path.
+type PathHistoryItem struct {
+	Start    time.Time       `json:"start"`
+	Stop     *time.Time      `json:"stop"`
+	Duration float64         `json:"duration"`
+	Source   *SourceOrReader `json:"source"`
+	Reason   *string         `json:"reason"`
+}
+
+// PathHistory is the response of PathsHistory.
+type PathHistory struct {
+	Items []PathHistoryItem `json:"items"`
+}
+
+// PathReaderRejectionsLast is the last rejected reader attempt of a path.
+type PathReaderRejectionsLast struct {
+	Time     time.Time `json:"time"`
+	Protocol string    `json:"protocol"`
+	Reason   string    `json:"reason"`
+	Message  string    `json:"message"`
+}
+
+// PathReaderRejections is the response of PathsReaderRejections.
+type PathReaderRejections struct {
+	// duration of the period in which rejections are counted, in seconds.
+	Window    float64                      `json:"window"`
+	Total     uint64                       `json:"total"`
+	Reasons   map[string]uint64            `json:"reasons"`
+	Protocols map[string]map[string]uint64 `json:"protocols"`
+	Last      *PathReaderRejectionsLast    `json:"last"`
+}
+
+// PathDisabledItem is an entry of PathsDisabled.
+type PathDisabledItem struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+// PathsDisabled is the response of PathsDisabled.
+type PathsDisabled struct {
+	Items map[string]PathDisabledItem `json:"items"`
+}
+
+// PathsDisableReq contains the parameters of PathsDisable.
+type PathsDisableReq struct {
+	// message returned to clients.
+	Message string `json:"message,omitempty"`
+
+	// whether to close the path and its publisher and readers.
+	Drop bool `json:"drop,omitempty"`
+}
+
+// ConfigLogLevelReq contains the parameters of ConfigLogLevel.
+// Nil fields are left unchanged, an empty LogLevels removes all module levels.
+type ConfigLogLevelReq struct {
+	LogLevel  *string           `json:"logLevel"`
+	LogLevels map[string]string `json:"logLevels"`
+}
+
+// RTSPConnsListItem is an entry of RTSPConnsList.
+type RTSPConnsListItem struct {
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// RTSPConnsList is the response of RTSPConnsList and RTSPSConnsList.
+type RTSPConnsList struct {
+	Items map[string]RTSPConnsListItem `json:"items"`
+}
+
+// RTSPSessionsListItem is an entry of RTSPSessionsList.
+type RTSPSessionsListItem struct {
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+	Timeout       string    `json:"timeout"`
+	LastKeepalive time.Time `json:"lastKeepalive"`
+}
+
+// RTSPSessionsList is the response of RTSPSessionsList and RTSPSSessionsList.
+type RTSPSessionsList struct {
+	Items map[string]RTSPSessionsListItem `json:"items"`
+}
+
+// RTSPMulticastGroupsListItem is an entry of RTSPMulticastGroupsList.
+type RTSPMulticastGroupsListItem struct {
+	Readers int `json:"readers"`
+}
+
+// RTSPMulticastGroupsList is the response of RTSPMulticastGroupsList.
+type RTSPMulticastGroupsList struct {
+	Items map[string]RTSPMulticastGroupsListItem `json:"items"`
+}
+
+// RTMPConnsListItem is an entry of RTMPConnsList.
+type RTMPConnsListItem struct {
+	Created       time.Time `json:"created"`
+	RemoteAddr    string    `json:"remoteAddr"`
+	State         string    `json:"state"`
+	BytesReceived uint64    `json:"bytesReceived"`
+	BytesSent     uint64    `json:"bytesSent"`
+}
+
+// RTMPConnsList is the response of RTMPConnsList and RTMPSConnsList.
+type RTMPConnsList struct {
+	Items map[string]RTMPConnsListItem `json:"items"`
+}
+
+// WebRTCConnsListItem is an entry of WebRTCConnsList.
+type WebRTCConnsListItem struct {
+	Created                   time.Time `json:"created"`
+	RemoteAddr                string    `json:"remoteAddr"`
+	PeerConnectionEstablished bool      `json:"peerConnectionEstablished"`
+	LocalCandidate            string    `json:"localCandidate"`
+	RemoteCandidate           string    `json:"remoteCandidate"`
+	BytesReceived             uint64    `json:"bytesReceived"`
+	BytesSent                 uint64    `json:"bytesSent"`
+}
+
+// WebRTCConnsList is the response of WebRTCConnsList.
+type WebRTCConnsList struct {
+	Items map[string]WebRTCConnsListItem `json:"items"`
+}
+
+// HLSMuxersListItem is an entry of HLSMuxersList.
+type HLSMuxersListItem struct {
+	Created     time.Time `json:"created"`
+	LastRequest time.Time `json:"lastRequest"`
+	BytesSent   uint64    `json:"bytesSent"`
+	TimeToClose *float64  `json:"timeToClose"`
+}
+
+// HLSMuxersList is the response of HLSMuxersList.
+type HLSMuxersList struct {
+	Items map[string]HLSMuxersListItem `json:"items"`
+}
+
+// BulkKickRes is the response of BulkKick.
+type BulkKickRes struct {
+	Kicked   []string `json:"kicked"`
+	NotFound []string `json:"notFound"`
+}
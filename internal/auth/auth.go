@@ -0,0 +1,106 @@
+// Package auth contains the authorization logic shared by all servers.
+package auth
+
+import (
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// Action is an action performed on a path.
+type Action string
+
+// actions.
+const (
+	ActionPublish Action = "publish"
+	ActionRead    Action = "read"
+)
+
+// Protocol is the protocol used to access a path.
+type Protocol string
+
+// protocols.
+const (
+	ProtocolRTSP   Protocol = "rtsp"
+	ProtocolRTMP   Protocol = "rtmp"
+	ProtocolHLS    Protocol = "hls"
+	ProtocolWebRTC Protocol = "webrtc"
+	ProtocolHTTP   Protocol = "http"
+)
+
+// ErrInvalidCredentials is returned when the client is allowed to perform
+// the action only with credentials that it did not provide.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// Request is a request to access a path.
+type Request struct {
+	IP       net.IP
+	Action   Action
+	Protocol Protocol
+
+	// CheckCredentials returns whether the client provided the given credentials.
+	CheckCredentials func(user conf.Credential, pass conf.Credential) bool
+}
+
+func permissionMatches(perm conf.PathPermission, req Request) bool {
+	actionFound := false
+	for _, action := range perm.Actions {
+		if Action(action) == req.Action {
+			actionFound = true
+			break
+		}
+	}
+	if !actionFound {
+		return false
+	}
+
+	if len(perm.Protocols) != 0 {
+		protoFound := false
+		for _, proto := range perm.Protocols {
+			if Protocol(proto) == req.Protocol {
+				protoFound = true
+				break
+			}
+		}
+		if !protoFound {
+			return false
+		}
+	}
+
+	if len(perm.IPs) != 0 && !IPEqualOrInRange(req.IP, perm.IPs) {
+		return false
+	}
+
+	return true
+}
+
+// Check checks a request against the permissions of a path.
+// It returns ErrInvalidCredentials when the request can be accepted
+// only by providing valid credentials.
+func Check(perms conf.PathPermissions, req Request) error {
+	credentialsRequired := false
+
+	for _, perm := range perms {
+		if !permissionMatches(perm, req) {
+			continue
+		}
+
+		if perm.User == "" {
+			return nil
+		}
+
+		if req.CheckCredentials != nil && req.CheckCredentials(perm.User, perm.Pass) {
+			return nil
+		}
+
+		credentialsRequired = true
+	}
+
+	if credentialsRequired {
+		return ErrInvalidCredentials
+	}
+
+	return fmt.Errorf("IP '%s' not allowed to %s with %s", req.IP, req.Action, req.Protocol)
+}
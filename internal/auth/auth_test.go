@@ -0,0 +1,148 @@
+package auth
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestCheck(t *testing.T) {
+	_, localNet, _ := net.ParseCIDR("127.0.0.0/8")
+
+	perms := conf.PathPermissions{
+		{
+			User:    "admin",
+			Pass:    "sha256:jGl25bVBBBW96Qi9Te4V37Fnqchz/Eu4qB9vKrRIqRg=", // "admin"
+			Actions: []string{"publish", "read"},
+		},
+		{
+			IPs:       conf.IPsOrCIDRs{localNet},
+			Actions:   []string{"read"},
+			Protocols: []string{"hls", "webrtc"},
+		},
+	}
+
+	credentials := func(user string, pass string) func(conf.Credential, conf.Credential) bool {
+		return func(permUser conf.Credential, permPass conf.Credential) bool {
+			return CredentialMatches(permUser, user) && CredentialMatches(permPass, pass)
+		}
+	}
+
+	for _, ca := range []struct {
+		name string
+		req  Request
+		err  string
+	}{
+		{
+			"publish with credentials",
+			Request{
+				IP:               net.ParseIP("10.0.0.1"),
+				Action:           ActionPublish,
+				Protocol:         ProtocolRTMP,
+				CheckCredentials: credentials("admin", "admin"),
+			},
+			"",
+		},
+		{
+			"publish with wrong credentials",
+			Request{
+				IP:               net.ParseIP("127.0.0.1"),
+				Action:           ActionPublish,
+				Protocol:         ProtocolRTSP,
+				CheckCredentials: credentials("admin", "wrong"),
+			},
+			"invalid credentials",
+		},
+		{
+			"read with ip",
+			Request{
+				IP:               net.ParseIP("127.0.0.1"),
+				Action:           ActionRead,
+				Protocol:         ProtocolHLS,
+				CheckCredentials: credentials("", ""),
+			},
+			"",
+		},
+		{
+			"read with ip and wrong protocol",
+			Request{
+				IP:               net.ParseIP("127.0.0.1"),
+				Action:           ActionRead,
+				Protocol:         ProtocolRTSP,
+				CheckCredentials: credentials("", ""),
+			},
+			"invalid credentials",
+		},
+		{
+			"no matching permission",
+			Request{
+				IP:       net.ParseIP("10.0.0.1"),
+				Action:   ActionRead,
+				Protocol: ProtocolHLS,
+			},
+			"invalid credentials",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			err := Check(perms, ca.req)
+			if ca.err == "" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, ca.err)
+			}
+		})
+	}
+
+	err := Check(perms[1:], Request{
+		IP:       net.ParseIP("10.0.0.1"),
+		Action:   ActionRead,
+		Protocol: ProtocolHLS,
+	})
+	require.EqualError(t, err, "IP '10.0.0.1' not allowed to read with hls")
+}
+
+func TestCredentialMatches(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		cred conf.Credential
+	}{
+		{
+			"plain",
+			"testpass",
+		},
+		{
+			"sha256",
+			"sha256:E9JJ8stBJ7QM+nV4ZoUCeHk/gU3tPFh/5YieiJp6n2w=",
+		},
+		{
+			"bcrypt",
+			"$2a$04$rSVUoWESFFBjpyK.oe1FCuRHt/ZI4y.rbZL9tC6sJZUs6ZHWNjS66",
+		},
+		{
+			"argon2id",
+			"$argon2id$v=19$m=1024,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$DzC/unKUaUpev+Cj7GK6j0sJ6W+tSNv6JBeEvExJJqc",
+		},
+		{
+			"argon2i",
+			"$argon2i$v=19$m=1024,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$FXN5+YBpgzHMStEa4/CVbVDrqIn6O65pW1btobsDSRs",
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, true, CredentialMatches(ca.cred, "testpass"))
+			require.Equal(t, false, CredentialMatches(ca.cred, "wrongpass"))
+		})
+	}
+}
+
+func TestCredentialMatchesInvalidArgon2(t *testing.T) {
+	for _, hash := range []string{
+		"$argon2id$v=19$m=1024,t=0,p=1$c29tZXNhbHRzb21lc2FsdA$DzC/unKUaUpev+Cj7GK6j0sJ6W+tSNv6JBeEvExJJqc",
+		"$argon2id$v=19$m=1024,t=1,p=0$c29tZXNhbHRzb21lc2FsdA$DzC/unKUaUpev+Cj7GK6j0sJ6W+tSNv6JBeEvExJJqc",
+		"$argon2id$v=19$m=0,t=1,p=1$c29tZXNhbHRzb21lc2FsdA$DzC/unKUaUpev+Cj7GK6j0sJ6W+tSNv6JBeEvExJJqc",
+	} {
+		require.Equal(t, false, CredentialMatches(conf.Credential(hash), "testpass"))
+	}
+}
@@ -0,0 +1,86 @@
+package auth
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func sha256Base64(in string) string {
+	h := sha256.Sum256([]byte(in))
+	return base64.StdEncoding.EncodeToString(h[:])
+}
+
+// argon2Matches checks a value against an Argon2 hash in PHC string format,
+// i.e. $argon2id$v=19$m=65536,t=3,p=4$salt$key
+func argon2Matches(hash string, value string) bool {
+	parts := strings.Split(hash, "$")
+	if len(parts) != 6 {
+		return false
+	}
+
+	var version int
+	_, err := fmt.Sscanf(parts[2], "v=%d", &version)
+	if err != nil || version != argon2.Version {
+		return false
+	}
+
+	var memory, time uint32
+	var threads uint8
+	_, err = fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads)
+	if err != nil {
+		return false
+	}
+
+	// hashes are validated when the configuration is loaded,
+	// check parameters again since the argon2 package panics when they are zero.
+	if memory < 1 || memory > conf.Argon2MaxMemory ||
+		time < 1 || time > conf.Argon2MaxTime ||
+		threads < 1 {
+		return false
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false
+	}
+
+	key, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil || len(key) == 0 {
+		return false
+	}
+
+	var computed []byte
+	if parts[1] == "argon2id" {
+		computed = argon2.IDKey([]byte(value), salt, time, memory, threads, uint32(len(key)))
+	} else {
+		computed = argon2.Key([]byte(value), salt, time, memory, threads, uint32(len(key)))
+	}
+
+	return subtle.ConstantTimeCompare(computed, key) == 1
+}
+
+// CredentialMatches checks whether a value provided in plain text by a client
+// matches a credential, that may be hashed.
+func CredentialMatches(cred conf.Credential, value string) bool {
+	switch {
+	case cred.IsSHA256():
+		return sha256Base64(value) == strings.TrimPrefix(string(cred), "sha256:")
+
+	case cred.IsBcrypt():
+		return bcrypt.CompareHashAndPassword([]byte(cred), []byte(value)) == nil
+
+	case cred.IsArgon2():
+		return argon2Matches(string(cred), value)
+
+	default:
+		return string(cred) == value
+	}
+}
@@ -1,11 +1,12 @@
-package core
+package auth
 
 import (
 	"fmt"
 	"net"
 )
 
-func ipEqualOrInRange(ip net.IP, ips []fmt.Stringer) bool {
+// IPEqualOrInRange checks whether an IP is equal to or contained in one of the given IPs or CIDRs.
+func IPEqualOrInRange(ip net.IP, ips []fmt.Stringer) bool {
 	for _, item := range ips {
 		switch titem := item.(type) {
 		case net.IP:
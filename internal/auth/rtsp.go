@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"encoding/hex"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/base"
+	"github.com/bluenviron/gortsplib/v3/pkg/headers"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// DefaultRTSPRealm is the realm that is used when no realm is provided.
+const DefaultRTSPRealm = "IPCAM"
+
+func md5Hex(in string) string {
+	h := md5.Sum([]byte(in))
+	return hex.EncodeToString(h[:])
+}
+
+// RTSPValidator validates the credentials of RTSP requests.
+// Unlike the gortsplib validator, it can check a request against multiple
+// credentials, since the digest nonce does not depend on them.
+type RTSPValidator struct {
+	methods []headers.AuthMethod
+	realm   string
+	nonce   string
+}
+
+// NewRTSPValidator allocates a RTSPValidator.
+// If methods is nil, the Basic and Digest methods are used.
+// If realm is empty, DefaultRTSPRealm is used.
+func NewRTSPValidator(methods []headers.AuthMethod, realm string) *RTSPValidator {
+	if methods == nil {
+		methods = []headers.AuthMethod{headers.AuthBasic, headers.AuthDigest}
+	}
+
+	if realm == "" {
+		realm = DefaultRTSPRealm
+	}
+
+	nonceByts := make([]byte, 16)
+	rand.Read(nonceByts)
+
+	return &RTSPValidator{
+		methods: methods,
+		realm:   realm,
+		nonce:   hex.EncodeToString(nonceByts),
+	}
+}
+
+// Header returns the WWW-Authenticate header needed by a client to authenticate.
+func (v *RTSPValidator) Header() base.HeaderValue {
+	realm := v.realm
+
+	var ret base.HeaderValue
+	for _, m := range v.methods {
+		switch m {
+		case headers.AuthBasic:
+			ret = append(ret, headers.Authenticate{
+				Method: headers.AuthBasic,
+				Realm:  &realm,
+			}.Marshal()...)
+
+		case headers.AuthDigest:
+			ret = append(ret, headers.Authenticate{
+				Method: headers.AuthDigest,
+				Realm:  &realm,
+				Nonce:  &v.nonce,
+			}.Marshal()...)
+		}
+	}
+	return ret
+}
+
+// Validate checks whether a request contains the given credentials.
+func (v *RTSPValidator) Validate(
+	req *base.Request,
+	baseURL *url.URL,
+	user conf.Credential,
+	pass conf.Credential,
+) bool {
+	var auth headers.Authorization
+	err := auth.Unmarshal(req.Header["Authorization"])
+	if err != nil {
+		return false
+	}
+
+	if auth.Method == headers.AuthBasic {
+		return CredentialMatches(user, auth.BasicUser) &&
+			CredentialMatches(pass, auth.BasicPass)
+	}
+
+	// hashed credentials can't be used with the Digest method
+	if user.IsHashed() || pass.IsHashed() {
+		return false
+	}
+
+	dv := auth.DigestValues
+	if dv.Realm == nil || dv.Nonce == nil || dv.Username == nil ||
+		dv.URI == nil || dv.Response == nil {
+		return false
+	}
+
+	if *dv.Nonce != v.nonce || *dv.Realm != v.realm || *dv.Username != string(user) {
+		return false
+	}
+
+	ur := req.URL
+	if *dv.URI != ur.String() {
+		// in SETUP requests, VLC strips the control attribute.
+		// try again with the base URL.
+		if baseURL == nil || *dv.URI != baseURL.String() {
+			return false
+		}
+		ur = baseURL
+	}
+
+	response := md5Hex(md5Hex(string(user)+":"+v.realm+":"+string(pass)) +
+		":" + v.nonce + ":" + md5Hex(string(req.Method)+":"+ur.String()))
+
+	return *dv.Response == response
+}
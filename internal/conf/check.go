@@ -0,0 +1,187 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// CheckError is an error found while checking a configuration.
+type CheckError struct {
+	// line of the configuration file the error refers to, or zero.
+	Line int
+	Err  error
+}
+
+// Error implements error.
+func (e CheckError) Error() string {
+	if e.Line != 0 {
+		return fmt.Sprintf("line %d: %s", e.Line, e.Err)
+	}
+	return e.Err.Error()
+}
+
+// yamlKeyLine returns the line of the YAML key identified by a sequence of keys.
+func yamlKeyLine(root *yamlv3.Node, keys []string) int {
+	if root == nil {
+		return 0
+	}
+
+	cur := root
+	if cur.Kind == yamlv3.DocumentNode && len(cur.Content) != 0 {
+		cur = cur.Content[0]
+	}
+
+	line := 0
+
+	for _, key := range keys {
+		if cur.Kind != yamlv3.MappingNode {
+			return line
+		}
+
+		found := false
+		for i := 0; i+1 < len(cur.Content); i += 2 {
+			if cur.Content[i].Value == key {
+				line = cur.Content[i].Line
+				cur = cur.Content[i+1]
+				found = true
+				break
+			}
+		}
+		if !found {
+			return line
+		}
+	}
+
+	return line
+}
+
+// Check loads and validates a configuration file, like Load does,
+// but instead of stopping at the first error, it returns all errors found.
+func Check(fpath string) []CheckError {
+	byts, _, err := readConfFile(fpath)
+	if err != nil {
+		return []CheckError{{Err: err}}
+	}
+
+	conf := &Conf{}
+	var errs []CheckError
+	var root *yamlv3.Node
+
+	if byts != nil {
+		temp, err := parseYAML(byts)
+		if err != nil {
+			return []CheckError{{Err: err}}
+		}
+
+		var node yamlv3.Node
+		if yamlv3.Unmarshal(byts, &node) == nil {
+			root = &node
+		}
+
+		for _, ferr := range checkNonExistentFields(temp, Conf{}) {
+			errs = append(errs, CheckError{Line: yamlKeyLine(root, ferr.keys), Err: ferr.err})
+		}
+
+		// load every parameter separately, in order to find all errors
+		if ma, ok := temp.(map[string]interface{}); ok {
+			keys := make([]string, 0, len(ma))
+			for k := range ma {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+
+			for _, k := range keys {
+				if k == "paths" {
+					continue
+				}
+
+				enc, _ := json.Marshal(map[string]interface{}{k: ma[k]})
+				err := json.Unmarshal(enc, conf)
+				if err != nil {
+					errs = append(errs, CheckError{
+						Line: yamlKeyLine(root, []string{k}),
+						Err:  fmt.Errorf("parameter %s: %s", k, err),
+					})
+				}
+			}
+
+			if paths, ok := ma["paths"].(map[string]interface{}); ok {
+				conf.Paths = make(map[string]*PathConf)
+
+				names := make([]string, 0, len(paths))
+				for name := range paths {
+					names = append(names, name)
+				}
+				sort.Strings(names)
+
+				for _, name := range names {
+					enc, _ := json.Marshal(paths[name])
+					pconf := &PathConf{}
+					err := json.Unmarshal(enc, pconf)
+					if err != nil {
+						errs = append(errs, CheckError{
+							Line: yamlKeyLine(root, []string{"paths", name}),
+							Err:  fmt.Errorf("path '%s': %s", name, err),
+						})
+						continue
+					}
+
+					conf.Paths[name] = pconf
+				}
+			}
+		}
+	}
+
+	err = loadFromEnvironment("RTSP", conf) // legacy prefix
+	if err != nil {
+		errs = append(errs, CheckError{Err: err})
+	}
+
+	err = loadFromEnvironment("MTX", conf)
+	if err != nil {
+		errs = append(errs, CheckError{Err: err})
+	}
+
+	// check global parameters first, then every path separately
+	paths := conf.Paths
+	conf.Paths = nil
+
+	err = conf.CheckAndFillMissing()
+	if err != nil {
+		errs = append(errs, CheckError{Err: err})
+	}
+
+	conf.Paths = paths
+
+	names := make([]string, 0, len(paths))
+	for name := range paths {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pconf := paths[name]
+		if pconf == nil {
+			pconf = &PathConf{}
+		}
+
+		// "all" is an alias for "~^.*$"
+		checkedName := name
+		if name == "all" {
+			checkedName = "~^.*$"
+		}
+
+		err := pconf.checkAndFillMissing(conf, checkedName)
+		if err != nil {
+			errs = append(errs, CheckError{
+				Line: yamlKeyLine(root, []string{"paths", name}),
+				Err:  fmt.Errorf("path '%s': %s", name, err),
+			})
+		}
+	}
+
+	return errs
+}
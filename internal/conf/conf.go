@@ -2,9 +2,12 @@
 package conf
 
 import (
+	"crypto/tls"
 	"encoding/base64"
 	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
 	"reflect"
 	"sort"
@@ -39,47 +42,56 @@ func decrypt(key string, byts []byte) ([]byte, error) {
 	return decrypted, nil
 }
 
-func loadFromFile(fpath string, conf *Conf) (bool, error) {
+func resolveConfPath(fpath string) string {
 	if fpath == "mediamtx.yml" {
 		// give priority to the legacy configuration file, in order not to break
 		// existing setups
 		if _, err := os.Stat("rtsp-simple-server.yml"); err == nil {
-			fpath = "rtsp-simple-server.yml"
+			return "rtsp-simple-server.yml"
 		}
 	}
+	return fpath
+}
+
+func readConfFile(fpath string) ([]byte, bool, error) {
+	fpath = resolveConfPath(fpath)
 
 	// mediamtx.yml is optional
 	// other configuration files are not
 	if fpath == "mediamtx.yml" || fpath == "rtsp-simple-server.yml" {
 		if _, err := os.Stat(fpath); err != nil {
-			return false, nil
+			return nil, false, nil
 		}
 	}
 
 	byts, err := os.ReadFile(fpath)
 	if err != nil {
-		return true, err
+		return nil, true, err
 	}
 
 	if key, ok := os.LookupEnv("RTSP_CONFKEY"); ok { // legacy format
 		byts, err = decrypt(key, byts)
 		if err != nil {
-			return true, err
+			return nil, true, err
 		}
 	}
 
 	if key, ok := os.LookupEnv("MTX_CONFKEY"); ok {
 		byts, err = decrypt(key, byts)
 		if err != nil {
-			return true, err
+			return nil, true, err
 		}
 	}
 
-	// load YAML config into a generic map
+	return byts, true, nil
+}
+
+// parseYAML loads YAML into a generic map with string keys.
+func parseYAML(byts []byte) (interface{}, error) {
 	var temp interface{}
-	err = yaml.Unmarshal(byts, &temp)
+	err := yaml.Unmarshal(byts, &temp)
 	if err != nil {
-		return true, err
+		return nil, err
 	}
 
 	// convert interface{} keys into string keys to avoid JSON errors
@@ -94,6 +106,7 @@ func loadFromFile(fpath string, conf *Conf) (bool, error) {
 					return nil, fmt.Errorf("integer keys are not supported (%v)", k)
 				}
 
+				var err error
 				m2[ks], err = convert(v)
 				if err != nil {
 					return nil, err
@@ -104,6 +117,7 @@ func loadFromFile(fpath string, conf *Conf) (bool, error) {
 		case []interface{}:
 			a2 := make([]interface{}, len(x))
 			for i, v := range x {
+				var err error
 				a2[i], err = convert(v)
 				if err != nil {
 					return nil, err
@@ -114,59 +128,114 @@ func loadFromFile(fpath string, conf *Conf) (bool, error) {
 
 		return i, nil
 	}
-	temp, err = convert(temp)
-	if err != nil {
-		return false, err
+	return convert(temp)
+}
+
+// fieldError is an error related to a specific configuration field.
+type fieldError struct {
+	keys []string
+	err  error
+}
+
+// checkNonExistentFields returns an error for every parameter that doesn't exist.
+func checkNonExistentFields(what interface{}, ref interface{}) []fieldError {
+	if what == nil {
+		return nil
 	}
 
-	// check for non-existent parameters
-	var checkNonExistentFields func(what interface{}, ref interface{}) error
-	checkNonExistentFields = func(what interface{}, ref interface{}) error {
-		if what == nil {
-			return nil
-		}
+	ma, ok := what.(map[string]interface{})
+	if !ok {
+		return []fieldError{{err: fmt.Errorf("not a map")}}
+	}
 
-		ma, ok := what.(map[string]interface{})
-		if !ok {
-			return fmt.Errorf("not a map")
-		}
+	var errs []fieldError
+
+	keys := make([]string, 0, len(ma))
+	for k := range ma {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		v := ma[k]
 
-		for k, v := range ma {
-			fi := func() reflect.Type {
-				rr := reflect.TypeOf(ref)
-				for i := 0; i < rr.NumField(); i++ {
-					f := rr.Field(i)
-					if f.Tag.Get("json") == k {
-						return f.Type
-					}
+		fi := func() reflect.Type {
+			rr := reflect.TypeOf(ref)
+			for i := 0; i < rr.NumField(); i++ {
+				f := rr.Field(i)
+				if f.Tag.Get("json") == k {
+					return f.Type
 				}
-				return nil
-			}()
-			if fi == nil {
-				return fmt.Errorf("non-existent parameter: '%s'", k)
 			}
+			return nil
+		}()
+		if fi == nil {
+			errs = append(errs, fieldError{
+				keys: []string{k},
+				err:  fmt.Errorf("non-existent parameter: '%s'", k),
+			})
+			continue
+		}
 
-			if fi == reflect.TypeOf(map[string]*PathConf{}) && v != nil {
-				ma2, ok := v.(map[string]interface{})
-				if !ok {
-					return fmt.Errorf("parameter %s is not a map", k)
-				}
+		if (fi == reflect.TypeOf(map[string]*PathConf{}) || fi == reflect.TypeOf(map[string]*TenantConf{})) &&
+			v != nil {
+			ma2, ok := v.(map[string]interface{})
+			if !ok {
+				errs = append(errs, fieldError{
+					keys: []string{k},
+					err:  fmt.Errorf("parameter %s is not a map", k),
+				})
+				continue
+			}
 
-				for k2, v2 := range ma2 {
-					err := checkNonExistentFields(v2, reflect.Zero(fi.Elem().Elem()).Interface())
-					if err != nil {
-						return fmt.Errorf("parameter %s, key %s: %s", k, k2, err)
-					}
+			keys2 := make([]string, 0, len(ma2))
+			for k2 := range ma2 {
+				keys2 = append(keys2, k2)
+			}
+			sort.Strings(keys2)
+
+			for _, k2 := range keys2 {
+				for _, ferr := range checkNonExistentFields(ma2[k2], reflect.Zero(fi.Elem().Elem()).Interface()) {
+					errs = append(errs, fieldError{
+						keys: append([]string{k, k2}, ferr.keys...),
+						err:  fmt.Errorf("parameter %s, key %s: %s", k, k2, ferr.err),
+					})
 				}
 			}
+			continue
 		}
-		return nil
+
+		// nested structs, like socket options
+		if ma2, ok := v.(map[string]interface{}); ok && fi.Kind() == reflect.Struct {
+			for _, ferr := range checkNonExistentFields(ma2, reflect.Zero(fi).Interface()) {
+				errs = append(errs, fieldError{
+					keys: append([]string{k}, ferr.keys...),
+					err:  fmt.Errorf("parameter %s: %s", k, ferr.err),
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+func loadFromFile(fpath string, conf *Conf) (bool, error) {
+	byts, found, err := readConfFile(fpath)
+	if !found || err != nil {
+		return found, err
 	}
-	err = checkNonExistentFields(temp, Conf{})
+
+	temp, err := parseYAML(byts)
 	if err != nil {
 		return true, err
 	}
 
+	// check for non-existent parameters
+	errs := checkNonExistentFields(temp, Conf{})
+	if errs != nil {
+		return true, errs[0].err
+	}
+
 	// convert the generic map into JSON
 	byts, err = json.Marshal(temp)
 	if err != nil {
@@ -185,61 +254,116 @@ func loadFromFile(fpath string, conf *Conf) (bool, error) {
 // Conf is a configuration.
 type Conf struct {
 	// general
-	LogLevel                  LogLevel        `json:"logLevel"`
-	LogDestinations           LogDestinations `json:"logDestinations"`
-	LogFile                   string          `json:"logFile"`
-	ReadTimeout               StringDuration  `json:"readTimeout"`
-	WriteTimeout              StringDuration  `json:"writeTimeout"`
-	ReadBufferCount           int             `json:"readBufferCount"`
-	UDPMaxPayloadSize         int             `json:"udpMaxPayloadSize"`
-	ExternalAuthenticationURL string          `json:"externalAuthenticationURL"`
-	API                       bool            `json:"api"`
-	APIAddress                string          `json:"apiAddress"`
-	Metrics                   bool            `json:"metrics"`
-	MetricsAddress            string          `json:"metricsAddress"`
-	PPROF                     bool            `json:"pprof"`
-	PPROFAddress              string          `json:"pprofAddress"`
-	RunOnConnect              string          `json:"runOnConnect"`
-	RunOnConnectRestart       bool            `json:"runOnConnectRestart"`
+	LogLevel                  LogLevel         `json:"logLevel"`
+	LogLevels                 LogLevels        `json:"logLevels"`
+	LogDestinations           LogDestinations  `json:"logDestinations"`
+	LogFile                   string           `json:"logFile"`
+	ReadTimeout               StringDuration   `json:"readTimeout"`
+	WriteTimeout              StringDuration   `json:"writeTimeout"`
+	ReadBufferCount           int              `json:"readBufferCount"`
+	UDPMaxPayloadSize         int              `json:"udpMaxPayloadSize"`
+	SourceStartConcurrency    int              `json:"sourceStartConcurrency"`
+	ExternalAuthenticationURL string           `json:"externalAuthenticationURL"`
+	AuthBanAttempts           int              `json:"authBanAttempts"`
+	AuthBanDuration           StringDuration   `json:"authBanDuration"`
+	AuthPauseAfterError       StringDuration   `json:"authPauseAfterError"`
+	AllowedReadIPs            IPsOrCIDRs       `json:"allowedReadIPs"`
+	AllowedPublishIPs         IPsOrCIDRs       `json:"allowedPublishIPs"`
+	GeoIPDatabase             string           `json:"geoIPDatabase"`
+	GeoIPAllowedCountries     []string         `json:"geoIPAllowedCountries"`
+	GeoIPDeniedCountries      []string         `json:"geoIPDeniedCountries"`
+	API                       bool             `json:"api"`
+	APIAddress                string           `json:"apiAddress"`
+	APIUser                   Credential       `json:"apiUser"`
+	APIPass                   Credential       `json:"apiPass"`
+	APICaptureDirectory       string           `json:"apiCaptureDirectory"`
+	APICaptureMaxSize         StringSize       `json:"apiCaptureMaxSize"`
+	APICaptureMaxFiles        int              `json:"apiCaptureMaxFiles"`
+	GRPCAPI                   bool             `json:"grpcAPI"`
+	GRPCAPIAddress            string           `json:"grpcAPIAddress"`
+	Metrics                   bool             `json:"metrics"`
+	MetricsAddress            string           `json:"metricsAddress"`
+	MetricsPathsExclude       PathNameMatchers `json:"metricsPathsExclude"`
+	MetricsPathsAggregate     PathNameMatchers `json:"metricsPathsAggregate"`
+	StatsFile                 string           `json:"statsFile"`
+	PersistAPIPaths           bool             `json:"persistAPIPaths"`
+	PersistAPIPathsFile       string           `json:"persistAPIPathsFile"`
+	PathGroupSuffixes         []string         `json:"pathGroupSuffixes"`
+	PPROF                     bool             `json:"pprof"`
+	PPROFAddress              string           `json:"pprofAddress"`
+	UnixSocketPermissions     FileMode         `json:"unixSocketPermissions"`
+	RunOnConnect              string           `json:"runOnConnect"`
+	RunOnConnectRestart       bool             `json:"runOnConnectRestart"`
 
 	// RTSP
-	RTSPDisable       bool        `json:"rtspDisable"`
-	Protocols         Protocols   `json:"protocols"`
-	Encryption        Encryption  `json:"encryption"`
-	RTSPAddress       string      `json:"rtspAddress"`
-	RTSPSAddress      string      `json:"rtspsAddress"`
-	RTPAddress        string      `json:"rtpAddress"`
-	RTCPAddress       string      `json:"rtcpAddress"`
-	MulticastIPRange  string      `json:"multicastIPRange"`
-	MulticastRTPPort  int         `json:"multicastRTPPort"`
-	MulticastRTCPPort int         `json:"multicastRTCPPort"`
-	ServerKey         string      `json:"serverKey"`
-	ServerCert        string      `json:"serverCert"`
-	AuthMethods       AuthMethods `json:"authMethods"`
+	RTSPDisable           bool           `json:"rtspDisable"`
+	Protocols             Protocols      `json:"protocols"`
+	Encryption            Encryption     `json:"encryption"`
+	RTSPAddress           string         `json:"rtspAddress"`
+	RTSPSAddress          string         `json:"rtspsAddress"`
+	RTPAddress            string         `json:"rtpAddress"`
+	RTCPAddress           string         `json:"rtcpAddress"`
+	MulticastIPRange      string         `json:"multicastIPRange"`
+	MulticastRTPPort      int            `json:"multicastRTPPort"`
+	MulticastRTCPPort     int            `json:"multicastRTCPPort"`
+	RTSPTunnelAddress     string         `json:"rtspTunnelAddress"`
+	ServerKey             string         `json:"serverKey"`
+	ServerCert            string         `json:"serverCert"`
+	AuthMethods           AuthMethods    `json:"authMethods"`
+	RTSPAuthRealm         string         `json:"rtspAuthRealm"`
+	RTSPAuthStatusText    string         `json:"rtspAuthStatusText"`
+	MDNS                  bool           `json:"mdns"`
+	MDNSHostName          string         `json:"mdnsHostName"`
+	RTSPShutdownRedirect  string         `json:"rtspShutdownRedirect"`
+	RTSPSessionTimeout    StringDuration `json:"rtspSessionTimeout"`
+	RTSPSessionTimeoutTCP bool           `json:"rtspSessionTimeoutTCP"`
+	RTSPIPFilter          IPFilter       `json:"rtspIPFilter"`
+	RTSPSocketOptions     SocketOptions  `json:"rtspSocketOptions"`
+	RTPSocketOptions      SocketOptions  `json:"rtpSocketOptions"`
 
 	// RTMP
-	RTMPDisable    bool       `json:"rtmpDisable"`
-	RTMPAddress    string     `json:"rtmpAddress"`
-	RTMPEncryption Encryption `json:"rtmpEncryption"`
-	RTMPSAddress   string     `json:"rtmpsAddress"`
-	RTMPServerKey  string     `json:"rtmpServerKey"`
-	RTMPServerCert string     `json:"rtmpServerCert"`
+	RTMPDisable       bool          `json:"rtmpDisable"`
+	RTMPAddress       string        `json:"rtmpAddress"`
+	RTMPEncryption    Encryption    `json:"rtmpEncryption"`
+	RTMPSAddress      string        `json:"rtmpsAddress"`
+	RTMPServerKey     string        `json:"rtmpServerKey"`
+	RTMPServerCert    string        `json:"rtmpServerCert"`
+	RTMPIPFilter      IPFilter      `json:"rtmpIPFilter"`
+	RTMPSocketOptions SocketOptions `json:"rtmpSocketOptions"`
 
 	// HLS
-	HLSDisable         bool           `json:"hlsDisable"`
-	HLSAddress         string         `json:"hlsAddress"`
-	HLSEncryption      bool           `json:"hlsEncryption"`
-	HLSServerKey       string         `json:"hlsServerKey"`
-	HLSServerCert      string         `json:"hlsServerCert"`
-	HLSAlwaysRemux     bool           `json:"hlsAlwaysRemux"`
-	HLSVariant         HLSVariant     `json:"hlsVariant"`
-	HLSSegmentCount    int            `json:"hlsSegmentCount"`
-	HLSSegmentDuration StringDuration `json:"hlsSegmentDuration"`
-	HLSPartDuration    StringDuration `json:"hlsPartDuration"`
-	HLSSegmentMaxSize  StringSize     `json:"hlsSegmentMaxSize"`
-	HLSAllowOrigin     string         `json:"hlsAllowOrigin"`
-	HLSTrustedProxies  IPsOrCIDRs     `json:"hlsTrustedProxies"`
-	HLSDirectory       string         `json:"hlsDirectory"`
+	HLSDisable              bool           `json:"hlsDisable"`
+	HLSAddress              string         `json:"hlsAddress"`
+	HLSEncryption           bool           `json:"hlsEncryption"`
+	HLSServerKey            string         `json:"hlsServerKey"`
+	HLSServerCert           string         `json:"hlsServerCert"`
+	HLSTokenSecret          string         `json:"hlsTokenSecret"`
+	HLSAuthRealm            string         `json:"hlsAuthRealm"`
+	HLSAuthErrorBody        string         `json:"hlsAuthErrorBody"`
+	HLSAlwaysRemux          bool           `json:"hlsAlwaysRemux"`
+	HLSVariant              HLSVariant     `json:"hlsVariant"`
+	HLSSegmentCount         int            `json:"hlsSegmentCount"`
+	HLSSegmentDuration      StringDuration `json:"hlsSegmentDuration"`
+	HLSPartDuration         StringDuration `json:"hlsPartDuration"`
+	HLSSegmentMaxSize       StringSize     `json:"hlsSegmentMaxSize"`
+	HLSPlaylistLength       StringDuration `json:"hlsPlaylistLength"`
+	HLSSegmentPush          bool           `json:"hlsSegmentPush"`
+	HLSPreloadHints         bool           `json:"hlsPreloadHints"`
+	HLSAllowOrigins         []string       `json:"hlsAllowOrigins"`
+	HLSAllowHeaders         []string       `json:"hlsAllowHeaders"`
+	HLSAllowCredentials     bool           `json:"hlsAllowCredentials"`
+	HLSPlaylistCacheControl string         `json:"hlsPlaylistCacheControl"`
+	HLSSegmentCacheControl  string         `json:"hlsSegmentCacheControl"`
+	HLSTrustedProxies       IPsOrCIDRs     `json:"hlsTrustedProxies"`
+	HLSBaseURL              string         `json:"hlsBaseURL"`
+	HLSDirectory            string         `json:"hlsDirectory"`
+	HLSIndexFile            string         `json:"hlsIndexFile"`
+	HLSPosterURL            string         `json:"hlsPosterURL"`
+	HLSStaticDirectory      string         `json:"hlsStaticDirectory"`
+	HLSMuxerCloseAfter      StringDuration `json:"hlsMuxerCloseAfter"`
+	HLSMuxerCheckPeriod     StringDuration `json:"hlsMuxerCheckPeriod"`
+	HLSIPFilter             IPFilter       `json:"hlsIPFilter"`
+	HLSSocketOptions        SocketOptions  `json:"hlsSocketOptions"`
 
 	// WebRTC
 	WebRTCDisable           bool       `json:"webrtcDisable"`
@@ -254,6 +378,36 @@ type Conf struct {
 	WebRTCICEUDPMuxAddress  string     `json:"webrtcICEUDPMuxAddress"`
 	WebRTCICETCPMuxAddress  string     `json:"webrtcICETCPMuxAddress"`
 
+	// HTTP ingest
+	HTTPIngest               bool       `json:"httpIngest"`
+	HTTPIngestAddress        string     `json:"httpIngestAddress"`
+	HTTPIngestTrustedProxies IPsOrCIDRs `json:"httpIngestTrustedProxies"`
+
+	// ACME
+	ACMEDomains      []string `json:"acmeDomains"`
+	ACMEEmail        string   `json:"acmeEmail"`
+	ACMECacheDir     string   `json:"acmeCacheDir"`
+	ACMEDirectoryURL string   `json:"acmeDirectoryURL"`
+	ACMEHTTPAddress  string   `json:"acmeHTTPAddress"`
+
+	// TLS
+	TLSMinVersion    TLSVersion      `json:"tlsMinVersion"`
+	TLSCipherSuites  TLSCipherSuites `json:"tlsCipherSuites"`
+	TLSLogHandshakes bool            `json:"tlsLogHandshakes"`
+
+	// cluster
+	ClusterOriginAPIURL    string         `json:"clusterOriginAPIURL"`
+	ClusterOriginRTSPURL   string         `json:"clusterOriginRTSPURL"`
+	ClusterPathPrefix      string         `json:"clusterPathPrefix"`
+	ClusterPollPeriod      StringDuration `json:"clusterPollPeriod"`
+	ClusterAuthPassThrough bool           `json:"clusterAuthPassThrough"`
+
+	// tenants
+	Tenants map[string]*TenantConf `json:"tenants"`
+
+	// publish path rules
+	PublishPathRules PublishPathRules `json:"publishPathRules"`
+
 	// paths
 	Paths map[string]*PathConf `json:"paths"`
 }
@@ -277,6 +431,13 @@ func Load(fpath string) (*Conf, bool, error) {
 		return nil, false, err
 	}
 
+	if conf.PersistAPIPaths && conf.PersistAPIPathsFile != "" {
+		err = loadPathsOverrides(conf.PersistAPIPathsFile, conf)
+		if err != nil {
+			return nil, false, err
+		}
+	}
+
 	err = conf.CheckAndFillMissing()
 	if err != nil {
 		return nil, false, err
@@ -331,21 +492,82 @@ func (conf *Conf) CheckAndFillMissing() error {
 	if conf.UDPMaxPayloadSize > 1472 {
 		return fmt.Errorf("'udpMaxPayloadSize' must be less than 1472")
 	}
+	if conf.SourceStartConcurrency == 0 {
+		conf.SourceStartConcurrency = 32
+	}
+	if conf.SourceStartConcurrency < 0 {
+		return fmt.Errorf("'sourceStartConcurrency' can't be negative")
+	}
 	if conf.ExternalAuthenticationURL != "" {
 		if !strings.HasPrefix(conf.ExternalAuthenticationURL, "http://") &&
 			!strings.HasPrefix(conf.ExternalAuthenticationURL, "https://") {
 			return fmt.Errorf("'externalAuthenticationURL' must be a HTTP URL")
 		}
 	}
+	if conf.AuthBanAttempts < 0 {
+		return fmt.Errorf("'authBanAttempts' can't be negative")
+	}
+	if conf.AuthBanDuration == 0 {
+		conf.AuthBanDuration = 10 * StringDuration(time.Minute)
+	}
+	if conf.AuthPauseAfterError < 0 {
+		return fmt.Errorf("'authPauseAfterError' can't be negative")
+	}
+	if conf.AuthPauseAfterError == 0 {
+		conf.AuthPauseAfterError = 2 * StringDuration(time.Second)
+	}
+	if (len(conf.GeoIPAllowedCountries) != 0 || len(conf.GeoIPDeniedCountries) != 0) &&
+		conf.GeoIPDatabase == "" {
+		return fmt.Errorf("'geoIPAllowedCountries' and 'geoIPDeniedCountries' require 'geoIPDatabase'")
+	}
 	if conf.APIAddress == "" {
 		conf.APIAddress = "127.0.0.1:9997"
 	}
+	if (conf.APIUser != "" && conf.APIPass == "") ||
+		(conf.APIUser == "" && conf.APIPass != "") {
+		return fmt.Errorf("API username and password must be both filled")
+	}
+	if conf.APICaptureMaxSize == 0 {
+		conf.APICaptureMaxSize = 100 * 1024 * 1024
+	}
+	if conf.APICaptureMaxFiles == 0 {
+		conf.APICaptureMaxFiles = 10
+	}
+	if conf.APICaptureMaxFiles < 0 {
+		return fmt.Errorf("'apiCaptureMaxFiles' must be greater than zero")
+	}
+	if conf.GRPCAPIAddress == "" {
+		conf.GRPCAPIAddress = "127.0.0.1:9996"
+	}
 	if conf.MetricsAddress == "" {
 		conf.MetricsAddress = "127.0.0.1:9998"
 	}
 	if conf.PPROFAddress == "" {
 		conf.PPROFAddress = "127.0.0.1:9999"
 	}
+	for _, addr := range []string{conf.APIAddress, conf.GRPCAPIAddress, conf.MetricsAddress, conf.PPROFAddress} {
+		if strings.HasPrefix(addr, "unix://") && len(addr) == len("unix://") {
+			return fmt.Errorf("'%s' is not a valid Unix socket address", addr)
+		}
+	}
+	if conf.UnixSocketPermissions == 0 {
+		conf.UnixSocketPermissions = 0o660
+	}
+	if conf.PathGroupSuffixes == nil {
+		conf.PathGroupSuffixes = []string{"_1080p", "_720p", "_480p"}
+	}
+	for i, suffix := range conf.PathGroupSuffixes {
+		err := IsValidPathName("path" + suffix)
+		if suffix == "" || err != nil || strings.Contains(suffix, "/") {
+			return fmt.Errorf("invalid path group suffix '%s'", suffix)
+		}
+
+		for _, other := range conf.PathGroupSuffixes[:i] {
+			if strings.HasSuffix(suffix, other) || strings.HasSuffix(other, suffix) {
+				return fmt.Errorf("path group suffixes '%s' and '%s' overlap", other, suffix)
+			}
+		}
+	}
 
 	// RTSP
 	if len(conf.Protocols) == 0 {
@@ -394,6 +616,43 @@ func (conf *Conf) CheckAndFillMissing() error {
 	if len(conf.AuthMethods) == 0 {
 		conf.AuthMethods = AuthMethods{headers.AuthBasic, headers.AuthDigest}
 	}
+	if conf.RTSPAuthRealm == "" {
+		conf.RTSPAuthRealm = "IPCAM"
+	}
+	if strings.ContainsAny(conf.RTSPAuthRealm, "\"\r\n") {
+		return fmt.Errorf("'rtspAuthRealm' can't contain quotes or newlines")
+	}
+	if strings.ContainsAny(conf.RTSPAuthStatusText, "\r\n") {
+		return fmt.Errorf("'rtspAuthStatusText' can't contain newlines")
+	}
+	if conf.RTSPSessionTimeout == 0 {
+		conf.RTSPSessionTimeout = 60 * StringDuration(time.Second)
+	}
+	// the RTSP library closes UDP readers after 60 seconds without keepalives,
+	// therefore longer timeouts can't be honored.
+	if conf.RTSPSessionTimeout < StringDuration(time.Second) ||
+		conf.RTSPSessionTimeout > 60*StringDuration(time.Second) {
+		return fmt.Errorf("'rtspSessionTimeout' must be between 1s and 60s")
+	}
+	err := conf.RTSPSocketOptions.check("rtspSocketOptions", false)
+	if err != nil {
+		return err
+	}
+	err = conf.RTPSocketOptions.check("rtpSocketOptions", true)
+	if err != nil {
+		return err
+	}
+	if conf.RTSPShutdownRedirect != "" {
+		if !strings.HasPrefix(conf.RTSPShutdownRedirect, "rtsp://") &&
+			!strings.HasPrefix(conf.RTSPShutdownRedirect, "rtsps://") {
+			return fmt.Errorf("'%s' is not a valid RTSP URL", conf.RTSPShutdownRedirect)
+		}
+
+		_, err := url.Parse(conf.RTSPShutdownRedirect)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid RTSP URL", conf.RTSPShutdownRedirect)
+		}
+	}
 
 	// RTMP
 	if conf.RTMPAddress == "" {
@@ -402,11 +661,24 @@ func (conf *Conf) CheckAndFillMissing() error {
 	if conf.RTMPSAddress == "" {
 		conf.RTMPSAddress = ":1936"
 	}
+	err = conf.RTMPSocketOptions.check("rtmpSocketOptions", false)
+	if err != nil {
+		return err
+	}
 
 	// HLS
 	if conf.HLSAddress == "" {
 		conf.HLSAddress = ":8888"
 	}
+	if conf.HLSAuthRealm == "" {
+		conf.HLSAuthRealm = "mediamtx"
+	}
+	if strings.ContainsAny(conf.HLSAuthRealm, "\"\r\n") {
+		return fmt.Errorf("'hlsAuthRealm' can't contain quotes or newlines")
+	}
+	if conf.HLSAuthErrorBody != "" && !json.Valid([]byte(conf.HLSAuthErrorBody)) {
+		return fmt.Errorf("'hlsAuthErrorBody' must be valid JSON")
+	}
 	if conf.HLSServerKey == "" {
 		conf.HLSServerKey = "server.key"
 	}
@@ -428,8 +700,41 @@ func (conf *Conf) CheckAndFillMissing() error {
 	if conf.HLSSegmentMaxSize == 0 {
 		conf.HLSSegmentMaxSize = 50 * 1024 * 1024
 	}
-	if conf.HLSAllowOrigin == "" {
-		conf.HLSAllowOrigin = "*"
+	if conf.HLSAllowOrigins == nil {
+		conf.HLSAllowOrigins = []string{"*"}
+	}
+	if conf.HLSAllowHeaders == nil {
+		conf.HLSAllowHeaders = []string{}
+	}
+	if conf.HLSPlaylistCacheControl == "" {
+		conf.HLSPlaylistCacheControl = "no-store"
+	}
+	if conf.HLSSegmentCacheControl == "" {
+		conf.HLSSegmentCacheControl = "max-age=30, immutable"
+	}
+	if conf.HLSBaseURL != "" {
+		if !strings.HasPrefix(conf.HLSBaseURL, "http://") &&
+			!strings.HasPrefix(conf.HLSBaseURL, "https://") &&
+			!strings.HasPrefix(conf.HLSBaseURL, "/") {
+			return fmt.Errorf("'hlsBaseURL' must be a HTTP URL or an absolute path")
+		}
+		_, err := url.Parse(conf.HLSBaseURL)
+		if err != nil {
+			return fmt.Errorf("'hlsBaseURL' is not a valid URL: %w", err)
+		}
+	}
+	if conf.HLSMuxerCloseAfter == 0 {
+		conf.HLSMuxerCloseAfter = 60 * StringDuration(time.Second)
+	}
+	if conf.HLSMuxerCheckPeriod == 0 {
+		conf.HLSMuxerCheckPeriod = 1 * StringDuration(time.Second)
+	}
+	if conf.HLSMuxerCheckPeriod > conf.HLSMuxerCloseAfter {
+		return fmt.Errorf("'hlsMuxerCheckPeriod' must be lower than 'hlsMuxerCloseAfter'")
+	}
+	err = conf.HLSSocketOptions.check("hlsSocketOptions", false)
+	if err != nil {
+		return err
 	}
 
 	// WebRTC
@@ -449,6 +754,57 @@ func (conf *Conf) CheckAndFillMissing() error {
 		conf.WebRTCICEServers = []string{"stun:stun.l.google.com:19302"}
 	}
 
+	// HTTP ingest
+	if conf.HTTPIngestAddress == "" {
+		conf.HTTPIngestAddress = ":8890"
+	}
+
+	// ACME
+	if conf.ACMECacheDir == "" {
+		conf.ACMECacheDir = "acme"
+	}
+	if conf.ACMEDirectoryURL != "" {
+		u, err := url.Parse(conf.ACMEDirectoryURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'acmeDirectoryURL' must be a HTTP URL")
+		}
+	}
+
+	// TLS
+	if conf.TLSMinVersion == 0 {
+		conf.TLSMinVersion = tls.VersionTLS12
+	}
+	if conf.TLSMinVersion == tls.VersionTLS13 && len(conf.TLSCipherSuites) != 0 {
+		return fmt.Errorf("'tlsCipherSuites' can't be used when 'tlsMinVersion' is 1.3")
+	}
+
+	// cluster
+	if conf.ClusterOriginAPIURL != "" {
+		u, err := url.Parse(conf.ClusterOriginAPIURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'clusterOriginAPIURL' must be a HTTP URL")
+		}
+
+		if conf.ClusterOriginRTSPURL == "" {
+			conf.ClusterOriginRTSPURL = "rtsp://" + net.JoinHostPort(u.Hostname(), "8554")
+		}
+	}
+	if conf.ClusterOriginRTSPURL != "" {
+		u, err := url.Parse(conf.ClusterOriginRTSPURL)
+		if err != nil || (u.Scheme != "rtsp" && u.Scheme != "rtsps") {
+			return fmt.Errorf("'clusterOriginRTSPURL' must be a RTSP URL")
+		}
+	}
+	if conf.ClusterPathPrefix != "" {
+		err := IsValidPathName(conf.ClusterPathPrefix + "path")
+		if err != nil {
+			return fmt.Errorf("invalid 'clusterPathPrefix': %s", err)
+		}
+	}
+	if conf.ClusterPollPeriod == 0 {
+		conf.ClusterPollPeriod = 10 * StringDuration(time.Second)
+	}
+
 	// do not add automatically "all", since user may want to
 	// initialize all paths through API or hot reloading.
 	if conf.Paths == nil {
@@ -482,5 +838,31 @@ func (conf *Conf) CheckAndFillMissing() error {
 		}
 	}
 
+	for i := range conf.PublishPathRules {
+		err := conf.PublishPathRules[i].checkAndFillMissing()
+		if err != nil {
+			return fmt.Errorf("invalid publish path rule %d: %s", i+1, err)
+		}
+	}
+
+	// tenants are checked after paths, since they need to know which paths are regular expressions.
+	sortedNames = make([]string, 0, len(conf.Tenants))
+	for name := range conf.Tenants {
+		sortedNames = append(sortedNames, name)
+	}
+	sort.Strings(sortedNames)
+
+	for _, name := range sortedNames {
+		tconf := conf.Tenants[name]
+		if tconf == nil {
+			return fmt.Errorf("tenant '%s' is empty", name)
+		}
+
+		err := tconf.checkAndFillMissing(conf, name)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
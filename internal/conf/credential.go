@@ -4,11 +4,23 @@ import (
 	"encoding/json"
 	"fmt"
 	"regexp"
+	"strconv"
 	"strings"
 )
 
 var reCredential = regexp.MustCompile(`^[a-zA-Z0-9!\$\(\)\*\+\.;<=>\[\]\^_\-\{\}@#&]+$`)
 
+var reBcrypt = regexp.MustCompile(`^\$2[aby]\$[0-9]{2}\$[./A-Za-z0-9]{53}$`)
+
+var reArgon2 = regexp.MustCompile(`^\$argon2(id|i)\$v=[0-9]+\$m=([0-9]+),t=([0-9]+),p=([0-9]+)\$[A-Za-z0-9+/]+\$[A-Za-z0-9+/]+$`)
+
+// Argon2MaxMemory is the maximum memory (in KiB) that can be used by an Argon2 hash.
+// Every authentication computes the hash, therefore the value is bounded.
+const Argon2MaxMemory = 1024 * 1024
+
+// Argon2MaxTime is the maximum number of iterations of an Argon2 hash.
+const Argon2MaxTime = 16
+
 const credentialSupportedChars = "A-Z,0-9,!,$,(,),*,+,.,;,<,=,>,[,],^,_,-,\",\",@,#,&"
 
 // Credential is a parameter that is used as username or password.
@@ -26,9 +38,20 @@ func (d *Credential) UnmarshalJSON(b []byte) error {
 		return err
 	}
 
-	if in != "" &&
+	switch {
+	case strings.HasPrefix(in, "$2"):
+		if !reBcrypt.MatchString(in) {
+			return fmt.Errorf("invalid bcrypt hash")
+		}
+
+	case strings.HasPrefix(in, "$argon2"):
+		if err := checkArgon2(in); err != nil {
+			return err
+		}
+
+	case in != "" &&
 		!strings.HasPrefix(in, "sha256:") &&
-		!reCredential.MatchString(in) {
+		!reCredential.MatchString(in):
 		return fmt.Errorf("credential contains unsupported characters. Supported are: %s", credentialSupportedChars)
 	}
 
@@ -36,6 +59,50 @@ func (d *Credential) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+func checkArgon2(in string) error {
+	m := reArgon2.FindStringSubmatch(in)
+	if m == nil {
+		return fmt.Errorf("invalid argon2 hash")
+	}
+
+	memory, err := strconv.ParseUint(m[2], 10, 32)
+	if err != nil || memory < 1 || memory > Argon2MaxMemory {
+		return fmt.Errorf("invalid argon2 hash: memory must be between 1 and %d", Argon2MaxMemory)
+	}
+
+	time, err := strconv.ParseUint(m[3], 10, 32)
+	if err != nil || time < 1 || time > Argon2MaxTime {
+		return fmt.Errorf("invalid argon2 hash: iterations must be between 1 and %d", Argon2MaxTime)
+	}
+
+	threads, err := strconv.ParseUint(m[4], 10, 8)
+	if err != nil || threads < 1 {
+		return fmt.Errorf("invalid argon2 hash: parallelism must be between 1 and 255")
+	}
+
+	return nil
+}
+
+// IsSHA256 returns whether the credential is a SHA256 hash.
+func (d Credential) IsSHA256() bool {
+	return strings.HasPrefix(string(d), "sha256:")
+}
+
+// IsBcrypt returns whether the credential is a bcrypt hash.
+func (d Credential) IsBcrypt() bool {
+	return strings.HasPrefix(string(d), "$2")
+}
+
+// IsArgon2 returns whether the credential is an Argon2 hash.
+func (d Credential) IsArgon2() bool {
+	return strings.HasPrefix(string(d), "$argon2")
+}
+
+// IsHashed returns whether the credential is hashed.
+func (d Credential) IsHashed() bool {
+	return d.IsSHA256() || d.IsBcrypt() || d.IsArgon2()
+}
+
 // unmarshalEnv implements envUnmarshaler.
 func (d *Credential) unmarshalEnv(s string) error {
 	return d.UnmarshalJSON([]byte(`"` + s + `"`))
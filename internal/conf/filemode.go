@@ -0,0 +1,51 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+)
+
+// FileMode is a set of file permissions that is unmarshaled from an octal string.
+type FileMode os.FileMode
+
+// MarshalJSON implements json.Marshaler.
+func (m FileMode) MarshalJSON() ([]byte, error) {
+	return json.Marshal(fmt.Sprintf("%04o", uint32(m)))
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (m *FileMode) UnmarshalJSON(b []byte) error {
+	// YAML parses unquoted values like 0660 into octal integers
+	var num uint32
+	if err := json.Unmarshal(b, &num); err == nil {
+		if num > 0o777 {
+			return fmt.Errorf("invalid file permissions: %o", num)
+		}
+		*m = FileMode(num)
+		return nil
+	}
+
+	var in string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	v, err := strconv.ParseUint(in, 8, 32)
+	if err != nil {
+		return fmt.Errorf("invalid file permissions: '%s'", in)
+	}
+
+	if v > 0o777 {
+		return fmt.Errorf("invalid file permissions: '%s'", in)
+	}
+
+	*m = FileMode(v)
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (m *FileMode) unmarshalEnv(s string) error {
+	return m.UnmarshalJSON([]byte(`"` + s + `"`))
+}
@@ -0,0 +1,10 @@
+package conf
+
+// IPFilter contains the IPs that are allowed or denied to connect to a listener.
+type IPFilter struct {
+	// IPs or networks allowed to connect. Leave empty to allow any IP.
+	Allow IPsOrCIDRs `json:"allow"`
+
+	// IPs or networks not allowed to connect. It has priority over Allow.
+	Deny IPsOrCIDRs `json:"deny"`
+}
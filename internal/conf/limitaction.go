@@ -0,0 +1,66 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// LimitAction is the limitAction parameter.
+type LimitAction int
+
+// limit actions.
+const (
+	LimitActionWarn LimitAction = iota
+	LimitActionNotReady
+	LimitActionDisconnect
+)
+
+// MarshalJSON implements json.Marshaler.
+func (d LimitAction) MarshalJSON() ([]byte, error) {
+	var out string
+
+	switch d {
+	case LimitActionWarn:
+		out = "warn"
+
+	case LimitActionNotReady:
+		out = "notReady"
+
+	case LimitActionDisconnect:
+		out = "disconnect"
+
+	default:
+		return nil, fmt.Errorf("invalid limit action: %v", d)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *LimitAction) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "warn":
+		*d = LimitActionWarn
+
+	case "notReady":
+		*d = LimitActionNotReady
+
+	case "disconnect":
+		*d = LimitActionDisconnect
+
+	default:
+		return fmt.Errorf("invalid limit action: '%s'", in)
+	}
+
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *LimitAction) unmarshalEnv(s string) error {
+	return d.UnmarshalJSON([]byte(`"` + s + `"`))
+}
@@ -0,0 +1,57 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// LogModules are the modules whose log level can be set separately.
+var LogModules = []string{"rtsp", "rtmp", "hls", "webrtc", "path"}
+
+// LogLevels is the logLevels parameter.
+type LogLevels map[string]LogLevel
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *LogLevels) UnmarshalJSON(b []byte) error {
+	var in map[string]LogLevel
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	for module := range in {
+		found := false
+		for _, m := range LogModules {
+			if module == m {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return fmt.Errorf("invalid log module: '%s'", module)
+		}
+	}
+
+	*d = in
+
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *LogLevels) unmarshalEnv(s string) error {
+	in := make(map[string]string)
+
+	if s != "" {
+		for _, entry := range strings.Split(s, ",") {
+			module, level, ok := strings.Cut(entry, ":")
+			if !ok {
+				return fmt.Errorf("invalid entry: '%s'", entry)
+			}
+			in[module] = level
+		}
+	}
+
+	byts, _ := json.Marshal(in)
+	return d.UnmarshalJSON(byts)
+}
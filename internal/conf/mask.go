@@ -0,0 +1,282 @@
+package conf
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// SecretMask is the value that replaces secrets in masked configurations.
+const SecretMask = "******"
+
+func maskCredential(c Credential) Credential {
+	if c == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+func maskString(s string) string {
+	if s == "" {
+		return ""
+	}
+	return SecretMask
+}
+
+// maskURL masks the password contained in a URL.
+func maskURL(s string) string {
+	u, err := url.Parse(s)
+	if err != nil || u.User == nil {
+		return s
+	}
+
+	if _, ok := u.User.Password(); !ok {
+		return s
+	}
+
+	// Redacted() replaces the password with "xxxxx".
+	return strings.Replace(u.Redacted(), ":xxxxx@", ":"+SecretMask+"@", 1)
+}
+
+// maskICEServer masks the password of an ICE server in format type:user:pass:host:port.
+func maskICEServer(s string) string {
+	parts := strings.Split(s, ":")
+	if len(parts) != 5 {
+		return s
+	}
+
+	parts[2] = SecretMask
+	return strings.Join(parts, ":")
+}
+
+// WithSecretsMasked returns a copy of the configuration in which
+// passwords, secrets and credentials inside URLs are replaced with SecretMask.
+func (conf Conf) WithSecretsMasked() *Conf {
+	dest := conf.Clone()
+
+	dest.ExternalAuthenticationURL = maskURL(dest.ExternalAuthenticationURL)
+	dest.APIPass = maskCredential(dest.APIPass)
+	dest.HLSTokenSecret = maskString(dest.HLSTokenSecret)
+	dest.ClusterOriginAPIURL = maskURL(dest.ClusterOriginAPIURL)
+	dest.ClusterOriginRTSPURL = maskURL(dest.ClusterOriginRTSPURL)
+
+	for i, s := range dest.WebRTCICEServers {
+		dest.WebRTCICEServers[i] = maskICEServer(s)
+	}
+
+	for _, tconf := range dest.Tenants {
+		if tconf != nil {
+			tconf.APIPass = maskCredential(tconf.APIPass)
+		}
+	}
+
+	for _, pconf := range dest.Paths {
+		if pconf != nil {
+			pconf.maskSecrets()
+		}
+	}
+
+	return dest
+}
+
+func (pconf *PathConf) maskSecrets() {
+	pconf.Source = maskURL(pconf.Source)
+	pconf.SourceONVIFAddress = maskURL(pconf.SourceONVIFAddress)
+	pconf.SourceONVIFEventsAddress = maskURL(pconf.SourceONVIFEventsAddress)
+	pconf.SourceRedirect = maskURL(pconf.SourceRedirect)
+	pconf.Fallback = maskURL(pconf.Fallback)
+	pconf.RunOnAlertWebhook = maskURL(pconf.RunOnAlertWebhook)
+	pconf.RunOnMotionWebhook = maskURL(pconf.RunOnMotionWebhook)
+	pconf.RunOnSCTE35Webhook = maskURL(pconf.RunOnSCTE35Webhook)
+	pconf.PublishPass = maskCredential(pconf.PublishPass)
+	pconf.StreamKey = maskCredential(pconf.StreamKey)
+	pconf.ReadPass = maskCredential(pconf.ReadPass)
+
+	for i := range pconf.Permissions {
+		pconf.Permissions[i].Pass = maskCredential(pconf.Permissions[i].Pass)
+	}
+}
+
+// errMaskedSecret is returned when a masked secret can't be associated with a current value.
+func errMaskedSecret(name string) error {
+	return fmt.Errorf("'%s' contains a masked secret that doesn't correspond to the current value", name)
+}
+
+func keepMaskedCredential(name string, v *Credential, cur Credential) error {
+	if *v != SecretMask {
+		return nil
+	}
+	if cur == "" {
+		return errMaskedSecret(name)
+	}
+	*v = cur
+	return nil
+}
+
+func keepMaskedString(name string, v *string, cur string) error {
+	if *v != SecretMask {
+		return nil
+	}
+	if cur == "" {
+		return errMaskedSecret(name)
+	}
+	*v = cur
+	return nil
+}
+
+// keepMaskedURL restores the password of a URL when it is masked.
+// The rest of the URL must be unchanged, otherwise the password
+// would be sent to a different destination.
+func keepMaskedURL(name string, v *string, cur string) error {
+	u, err := url.Parse(*v)
+	if err != nil || u.User == nil {
+		return nil
+	}
+
+	if pass, ok := u.User.Password(); !ok || pass != SecretMask {
+		return nil
+	}
+
+	if cur == "" || maskURL(cur) != *v {
+		return errMaskedSecret(name)
+	}
+	*v = cur
+	return nil
+}
+
+// KeepMaskedSecrets replaces secrets that are equal to SecretMask,
+// that are present when a configuration obtained with WithSecretsMasked() is sent back,
+// with the corresponding secrets of the current configuration.
+func (conf *Conf) KeepMaskedSecrets(cur *Conf) error {
+	err := keepMaskedURL("externalAuthenticationURL", &conf.ExternalAuthenticationURL, cur.ExternalAuthenticationURL)
+	if err != nil {
+		return err
+	}
+
+	err = keepMaskedCredential("apiPass", &conf.APIPass, cur.APIPass)
+	if err != nil {
+		return err
+	}
+
+	err = keepMaskedString("hlsTokenSecret", &conf.HLSTokenSecret, cur.HLSTokenSecret)
+	if err != nil {
+		return err
+	}
+
+	err = keepMaskedURL("clusterOriginAPIURL", &conf.ClusterOriginAPIURL, cur.ClusterOriginAPIURL)
+	if err != nil {
+		return err
+	}
+
+	err = keepMaskedURL("clusterOriginRTSPURL", &conf.ClusterOriginRTSPURL, cur.ClusterOriginRTSPURL)
+	if err != nil {
+		return err
+	}
+
+outer:
+	for i, s := range conf.WebRTCICEServers {
+		if parts := strings.Split(s, ":"); len(parts) == 5 && parts[2] == SecretMask {
+			for _, curS := range cur.WebRTCICEServers {
+				if maskICEServer(curS) == s {
+					conf.WebRTCICEServers[i] = curS
+					continue outer
+				}
+			}
+			return errMaskedSecret("webrtcICEServers")
+		}
+	}
+
+	for name, tconf := range conf.Tenants {
+		if tconf == nil {
+			continue
+		}
+
+		var curPass Credential
+		if curTconf, ok := cur.Tenants[name]; ok && curTconf != nil {
+			curPass = curTconf.APIPass
+		}
+
+		err = keepMaskedCredential("apiPass", &tconf.APIPass, curPass)
+		if err != nil {
+			return fmt.Errorf("tenant '%s': %s", name, err)
+		}
+	}
+
+	for name, pconf := range conf.Paths {
+		if pconf == nil {
+			continue
+		}
+
+		curPconf, ok := cur.Paths[name]
+		if !ok || curPconf == nil {
+			curPconf = &PathConf{}
+		}
+
+		err = pconf.keepMaskedSecrets(curPconf)
+		if err != nil {
+			return fmt.Errorf("path '%s': %s", name, err)
+		}
+	}
+
+	return nil
+}
+
+func (pconf *PathConf) keepMaskedSecrets(cur *PathConf) error {
+	for _, e := range []struct {
+		name string
+		v    *string
+		cur  string
+	}{
+		{"source", &pconf.Source, cur.Source},
+		{"sourceONVIFAddress", &pconf.SourceONVIFAddress, cur.SourceONVIFAddress},
+		{"sourceONVIFEventsAddress", &pconf.SourceONVIFEventsAddress, cur.SourceONVIFEventsAddress},
+		{"sourceRedirect", &pconf.SourceRedirect, cur.SourceRedirect},
+		{"fallback", &pconf.Fallback, cur.Fallback},
+		{"runOnAlertWebhook", &pconf.RunOnAlertWebhook, cur.RunOnAlertWebhook},
+		{"runOnMotionWebhook", &pconf.RunOnMotionWebhook, cur.RunOnMotionWebhook},
+		{"runOnSCTE35Webhook", &pconf.RunOnSCTE35Webhook, cur.RunOnSCTE35Webhook},
+	} {
+		err := keepMaskedURL(e.name, e.v, e.cur)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, e := range []struct {
+		name string
+		v    *Credential
+		cur  Credential
+	}{
+		{"publishPass", &pconf.PublishPass, cur.PublishPass},
+		{"streamKey", &pconf.StreamKey, cur.StreamKey},
+		{"readPass", &pconf.ReadPass, cur.ReadPass},
+	} {
+		err := keepMaskedCredential(e.name, e.v, e.cur)
+		if err != nil {
+			return err
+		}
+	}
+
+	for i := range pconf.Permissions {
+		var curPass Credential
+		if i < len(cur.Permissions) && cur.Permissions[i].User == pconf.Permissions[i].User {
+			curPass = cur.Permissions[i].Pass
+		}
+
+		err := keepMaskedCredential("permissions", &pconf.Permissions[i].Pass, curPass)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// PathDefaults returns the configuration of a path without any parameter,
+// filled with the default values of the current configuration.
+func (conf *Conf) PathDefaults() *PathConf {
+	// a path without parameters always passes the check.
+	pconf := &PathConf{}
+	pconf.checkAndFillMissing(conf, "~^.*$")
+	return pconf
+}
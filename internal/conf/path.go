@@ -7,14 +7,19 @@ import (
 	gourl "net/url"
 	"reflect"
 	"regexp"
+	"runtime"
+	"strconv"
 	"strings"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 )
 
 var rePathName = regexp.MustCompile(`^[0-9a-zA-Z_\-/\.~]+$`)
 
+var reSourceTemplate = regexp.MustCompile(`\{(?:query:([0-9a-zA-Z_\-\.~]+)|group:([0-9]+)|protocol)\}`)
+
 // IsValidPathName checks if a path name is valid.
 func IsValidPathName(name string) error {
 	if name == "" {
@@ -40,17 +45,37 @@ func IsValidPathName(name string) error {
 type PathConf struct {
 	Regexp *regexp.Regexp `json:"-"`
 
+	// aliases
+	Aliases []string `json:"aliases"`
+
 	// source
 	Source                     string         `json:"source"`
 	SourceProtocol             SourceProtocol `json:"sourceProtocol"`
 	SourceAnyPortEnable        bool           `json:"sourceAnyPortEnable"`
+	SourceParameters           []string       `json:"sourceParameters"`
+	SourceBackchannel          bool           `json:"sourceBackchannel"`
 	SourceFingerprint          string         `json:"sourceFingerprint"`
+	SourceONVIFAddress         string         `json:"sourceONVIFAddress"`
+	SourceONVIFProfileToken    string         `json:"sourceONVIFProfileToken"`
+	SourceONVIFEventsAddress   string         `json:"sourceONVIFEventsAddress"`
 	SourceOnDemand             bool           `json:"sourceOnDemand"`
 	SourceOnDemandStartTimeout StringDuration `json:"sourceOnDemandStartTimeout"`
 	SourceOnDemandCloseAfter   StringDuration `json:"sourceOnDemandCloseAfter"`
+	SourceRetryMin             StringDuration `json:"sourceRetryMin"`
+	SourceRetryMax             StringDuration `json:"sourceRetryMax"`
+	SourceRetryJitter          float64        `json:"sourceRetryJitter"`
+	SourceRetryMaxCount        int            `json:"sourceRetryMaxCount"`
 	SourceRedirect             string         `json:"sourceRedirect"`
+	SourceLoop                 bool           `json:"sourceLoop"`
+	SourceS3Endpoint           string         `json:"sourceS3Endpoint"`
+	SourceS3Region             string         `json:"sourceS3Region"`
 	DisablePublisherOverride   bool           `json:"disablePublisherOverride"`
+	PreserveSSRC               bool           `json:"preserveSSRC"`
 	Fallback                   string         `json:"fallback"`
+	KeepLastFrame              StringDuration `json:"keepLastFrame"`
+	KeepLastFrameLabel         string         `json:"keepLastFrameLabel"`
+	ReaderGracePeriod          StringDuration `json:"readerGracePeriod"`
+	NormalizeTimestamps        bool           `json:"normalizeTimestamps"`
 	RPICameraCamID             int            `json:"rpiCameraCamID"`
 	RPICameraWidth             int            `json:"rpiCameraWidth"`
 	RPICameraHeight            int            `json:"rpiCameraHeight"`
@@ -83,13 +108,42 @@ type PathConf struct {
 	RPICameraTextOverlayEnable bool           `json:"rpiCameraTextOverlayEnable"`
 	RPICameraTextOverlay       string         `json:"rpiCameraTextOverlay"`
 
+	// testsrc
+	TestsrcWidth   int `json:"testsrcWidth"`
+	TestsrcHeight  int `json:"testsrcHeight"`
+	TestsrcFPS     int `json:"testsrcFPS"`
+	TestsrcBitrate int `json:"testsrcBitrate"`
+
+	// multicast output
+	MulticastOutputAddress string `json:"multicastOutputAddress"`
+	MulticastOutputTTL     int    `json:"multicastOutputTTL"`
+	MulticastOutputSource  string `json:"multicastOutputSource"`
+	MulticastOutputSDPFile string `json:"multicastOutputSDPFile"`
+
+	// SEI timestamps
+	SEITimestamp      bool   `json:"seiTimestamp"`
+	SEITimestampLabel string `json:"seiTimestampLabel"`
+
+	// pacing
+	ReaderMaxBitrate int `json:"readerMaxBitrate"`
+
+	// analysis
+	StaticVideoTimeout StringDuration `json:"staticVideoTimeout"`
+	SilentAudioTimeout StringDuration `json:"silentAudioTimeout"`
+	MaxBitrate         int            `json:"maxBitrate"`
+	MaxGOPDuration     StringDuration `json:"maxGOPDuration"`
+	MinHealthScore     int            `json:"minHealthScore"`
+	LimitAction        LimitAction    `json:"limitAction"`
+
 	// authentication
-	PublishUser Credential `json:"publishUser"`
-	PublishPass Credential `json:"publishPass"`
-	PublishIPs  IPsOrCIDRs `json:"publishIPs"`
-	ReadUser    Credential `json:"readUser"`
-	ReadPass    Credential `json:"readPass"`
-	ReadIPs     IPsOrCIDRs `json:"readIPs"`
+	PublishUser Credential      `json:"publishUser"`
+	PublishPass Credential      `json:"publishPass"`
+	PublishIPs  IPsOrCIDRs      `json:"publishIPs"`
+	StreamKey   Credential      `json:"streamKey"`
+	ReadUser    Credential      `json:"readUser"`
+	ReadPass    Credential      `json:"readPass"`
+	ReadIPs     IPsOrCIDRs      `json:"readIPs"`
+	Permissions PathPermissions `json:"permissions"`
 
 	// external commands
 	RunOnInit               string         `json:"runOnInit"`
@@ -102,6 +156,18 @@ type PathConf struct {
 	RunOnReadyRestart       bool           `json:"runOnReadyRestart"`
 	RunOnRead               string         `json:"runOnRead"`
 	RunOnReadRestart        bool           `json:"runOnReadRestart"`
+	RunOnAlert              string         `json:"runOnAlert"`
+	RunOnAlertRestart       bool           `json:"runOnAlertRestart"`
+	RunOnAlertWebhook       string         `json:"runOnAlertWebhook"`
+	RunOnMotion             string         `json:"runOnMotion"`
+	RunOnMotionRestart      bool           `json:"runOnMotionRestart"`
+	RunOnMotionWebhook      string         `json:"runOnMotionWebhook"`
+	RunOnSCTE35Webhook      string         `json:"runOnSCTE35Webhook"`
+	RunOnUser               string         `json:"runOnUser"`
+	RunOnDir                string         `json:"runOnDir"`
+	RunOnCPULimit           StringDuration `json:"runOnCPULimit"`
+	RunOnMemoryLimit        StringSize     `json:"runOnMemoryLimit"`
+	RunOnKillTimeout        StringDuration `json:"runOnKillTimeout"`
 }
 
 func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
@@ -125,27 +191,86 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		pconf.Source = "publisher"
 	}
 
+	if len(pconf.Aliases) > 0 {
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have aliases. use another path")
+		}
+
+		for _, alias := range pconf.Aliases {
+			err := IsValidPathName(alias)
+			if err != nil {
+				return fmt.Errorf("invalid alias '%s': %s", alias, err)
+			}
+
+			if alias == name {
+				return fmt.Errorf("alias '%s' is equal to the path name", alias)
+			}
+
+			for otherName, otherPath := range conf.Paths {
+				if otherPath == pconf || otherPath == nil {
+					continue
+				}
+
+				for _, otherAlias := range otherPath.Aliases {
+					if otherAlias == alias {
+						return fmt.Errorf("alias '%s' is used in two paths, '%s' and '%s'",
+							alias, name, otherName)
+					}
+				}
+
+				// the alias has its own configuration, that is used for authentication only.
+				if otherName == alias && (otherPath.Source != "" && otherPath.Source != "publisher" ||
+					len(otherPath.Aliases) > 0) {
+					return fmt.Errorf("path '%s' is an alias of '%s' and can contain authentication settings only",
+						alias, name)
+				}
+			}
+		}
+	}
+
+	if len(pconf.SourceParameters) != 0 &&
+		!strings.HasPrefix(pconf.Source, "rtsp://") &&
+		!strings.HasPrefix(pconf.Source, "rtsps://") {
+		return fmt.Errorf("'sourceParameters' can be used with RTSP sources only")
+	}
+
+	if pconf.SourceBackchannel {
+		if !strings.HasPrefix(pconf.Source, "rtsp://") {
+			return fmt.Errorf("'sourceBackchannel' can be used only when source is a RTSP URL")
+		}
+
+		if pconf.SourceProtocol.Transport != nil && *pconf.SourceProtocol.Transport != gortsplib.TransportTCP {
+			return fmt.Errorf("'sourceBackchannel' can be used only with the TCP source protocol")
+		}
+	}
+
 	switch {
 	case pconf.Source == "publisher":
 
 	case strings.HasPrefix(pconf.Source, "rtsp://") ||
 		strings.HasPrefix(pconf.Source, "rtsps://"):
-		if pconf.Regexp != nil {
+		if pconf.Regexp != nil && !pconf.HasSourceTemplate() {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a RTSP source. use another path")
 		}
 
-		_, err := url.Parse(pconf.Source)
+		_, err := url.Parse(pconf.SourceWithTemplates("", nil, ""))
 		if err != nil {
 			return fmt.Errorf("'%s' is not a valid RTSP URL", pconf.Source)
 		}
 
+		for _, param := range pconf.SourceParameters {
+			if param == "" || strings.ContainsAny(param, ":\r\n") {
+				return fmt.Errorf("invalid source parameter '%s'", param)
+			}
+		}
+
 	case strings.HasPrefix(pconf.Source, "rtmp://") ||
 		strings.HasPrefix(pconf.Source, "rtmps://"):
-		if pconf.Regexp != nil {
+		if pconf.Regexp != nil && !pconf.HasSourceTemplate() {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a RTMP source. use another path")
 		}
 
-		u, err := gourl.Parse(pconf.Source)
+		u, err := gourl.Parse(pconf.SourceWithTemplates("", nil, ""))
 		if err != nil {
 			return fmt.Errorf("'%s' is not a valid RTMP URL", pconf.Source)
 		}
@@ -161,11 +286,11 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 
 	case strings.HasPrefix(pconf.Source, "http://") ||
 		strings.HasPrefix(pconf.Source, "https://"):
-		if pconf.Regexp != nil {
+		if pconf.Regexp != nil && !pconf.HasSourceTemplate() {
 			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a HLS source. use another path")
 		}
 
-		u, err := gourl.Parse(pconf.Source)
+		u, err := gourl.Parse(pconf.SourceWithTemplates("", nil, ""))
 		if err != nil {
 			return fmt.Errorf("'%s' is not a valid HLS URL", pconf.Source)
 		}
@@ -197,6 +322,68 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 			return fmt.Errorf("'%s' is not a valid IP", host)
 		}
 
+	case strings.HasPrefix(pconf.Source, "pipe://"):
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a pipe source. use another path")
+		}
+
+		if pconf.Source == "pipe://" {
+			return fmt.Errorf("'%s' is not a valid pipe URL", pconf.Source)
+		}
+
+		for otherName, otherPath := range conf.Paths {
+			if otherPath != pconf && otherPath != nil && otherPath.Source == pconf.Source {
+				return fmt.Errorf("pipe '%s' is used as source in two paths, '%s' and '%s'",
+					pconf.Source, name, otherName)
+			}
+		}
+
+	case pconf.Source == "stdin":
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have 'stdin' as source. use another path")
+		}
+
+		for otherName, otherPath := range conf.Paths {
+			if otherPath != pconf && otherPath != nil && otherPath.Source == "stdin" {
+				return fmt.Errorf("'stdin' is used as source in two paths, '%s' and '%s'", name, otherName)
+			}
+		}
+
+	case strings.HasPrefix(pconf.Source, "exec://"):
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have an exec source. use another path")
+		}
+
+		if strings.TrimSpace(pconf.Source[len("exec://"):]) == "" {
+			return fmt.Errorf("'%s' is not a valid exec URL", pconf.Source)
+		}
+
+	case strings.HasPrefix(pconf.Source, "file://"):
+		if pconf.Regexp != nil && !pconf.HasSourceTemplate() {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a file source. use another path")
+		}
+
+		if !strings.HasPrefix(pconf.Source, "file:///") {
+			return fmt.Errorf("'%s' is not a valid file URL, the file path must be absolute", pconf.Source)
+		}
+
+	case strings.HasPrefix(pconf.Source, "s3://"):
+		if pconf.Regexp != nil && !pconf.HasSourceTemplate() {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a S3 source. use another path")
+		}
+
+		u, err := gourl.Parse(pconf.SourceWithTemplates("", nil, ""))
+		if err != nil || u.Host == "" || strings.TrimPrefix(u.Path, "/") == "" {
+			return fmt.Errorf("'%s' is not a valid S3 URL", pconf.Source)
+		}
+
+		if pconf.SourceS3Endpoint != "" {
+			u, err := gourl.Parse(pconf.SourceS3Endpoint)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+				return fmt.Errorf("'%s' is not a valid S3 endpoint", pconf.SourceS3Endpoint)
+			}
+		}
+
 	case pconf.Source == "redirect":
 		if pconf.SourceRedirect == "" {
 			return fmt.Errorf("source redirect must be filled")
@@ -255,14 +442,78 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 			pconf.RPICameraTextOverlay = "%Y-%m-%d %H:%M:%S - MediaMTX"
 		}
 
+	case pconf.Source == "testsrc":
+		if pconf.TestsrcWidth == 0 {
+			pconf.TestsrcWidth = 1280
+		}
+		if pconf.TestsrcHeight == 0 {
+			pconf.TestsrcHeight = 720
+		}
+		if pconf.TestsrcFPS == 0 {
+			pconf.TestsrcFPS = 30
+		}
+		if pconf.TestsrcBitrate == 0 {
+			pconf.TestsrcBitrate = 2000000
+		}
+
+		if pconf.TestsrcWidth < 192 || pconf.TestsrcWidth > 4096 || (pconf.TestsrcWidth%2) != 0 {
+			return fmt.Errorf("'testsrcWidth' must be an even number between 192 and 4096")
+		}
+		if pconf.TestsrcHeight < 32 || pconf.TestsrcHeight > 2304 || (pconf.TestsrcHeight%2) != 0 {
+			return fmt.Errorf("'testsrcHeight' must be an even number between 32 and 2304")
+		}
+		if pconf.TestsrcFPS < 1 || pconf.TestsrcFPS > 120 {
+			return fmt.Errorf("'testsrcFPS' must be between 1 and 120")
+		}
+		if pconf.TestsrcBitrate < 0 {
+			return fmt.Errorf("'testsrcBitrate' must be positive")
+		}
+
 	default:
 		return fmt.Errorf("invalid source: '%s'", pconf.Source)
 	}
 
+	if pconf.SourceONVIFAddress != "" {
+		if !strings.HasPrefix(pconf.Source, "rtsp://") &&
+			!strings.HasPrefix(pconf.Source, "rtsps://") {
+			return fmt.Errorf("'sourceONVIFAddress' can be used only when source is a RTSP URL")
+		}
+
+		u, err := gourl.Parse(pconf.SourceONVIFAddress)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid ONVIF address", pconf.SourceONVIFAddress)
+		}
+
+		if pconf.SourceONVIFProfileToken == "" {
+			return fmt.Errorf("'sourceONVIFProfileToken' is required when 'sourceONVIFAddress' is set")
+		}
+	}
+
+	if pconf.SourceONVIFEventsAddress != "" {
+		if !strings.HasPrefix(pconf.Source, "rtsp://") &&
+			!strings.HasPrefix(pconf.Source, "rtsps://") {
+			return fmt.Errorf("'sourceONVIFEventsAddress' can be used only when source is a RTSP URL")
+		}
+
+		u, err := gourl.Parse(pconf.SourceONVIFEventsAddress)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid ONVIF address", pconf.SourceONVIFEventsAddress)
+		}
+	}
+
+	if pconf.SourceLoop {
+		if !strings.HasPrefix(pconf.Source, "file://") &&
+			!strings.HasPrefix(pconf.Source, "s3://") {
+			return fmt.Errorf("'sourceLoop' can be used only when source is a file or S3 URL")
+		}
+	}
+
 	if pconf.SourceOnDemand {
 		if pconf.Source == "publisher" {
 			return fmt.Errorf("'sourceOnDemand' is useless when source is 'publisher'")
 		}
+	} else if pconf.HasSourceTemplate() {
+		return fmt.Errorf("templates can be used in the source only when 'sourceOnDemand' is true")
 	}
 
 	if pconf.SourceOnDemandStartTimeout == 0 {
@@ -273,6 +524,26 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		pconf.SourceOnDemandCloseAfter = 10 * StringDuration(time.Second)
 	}
 
+	if pconf.SourceRetryMin == 0 {
+		pconf.SourceRetryMin = 5 * StringDuration(time.Second)
+	}
+
+	if pconf.SourceRetryMax == 0 {
+		pconf.SourceRetryMax = pconf.SourceRetryMin
+	}
+
+	if pconf.SourceRetryMax < pconf.SourceRetryMin {
+		return fmt.Errorf("'sourceRetryMax' must be greater than or equal to 'sourceRetryMin'")
+	}
+
+	if pconf.SourceRetryJitter < 0 || pconf.SourceRetryJitter > 1 {
+		return fmt.Errorf("'sourceRetryJitter' must be between 0 and 1")
+	}
+
+	if pconf.SourceRetryMaxCount < 0 {
+		return fmt.Errorf("'sourceRetryMaxCount' must be greater than or equal to 0")
+	}
+
 	if pconf.Fallback != "" {
 		if strings.HasPrefix(pconf.Fallback, "/") {
 			err := IsValidPathName(pconf.Fallback[1:])
@@ -287,6 +558,91 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		}
 	}
 
+	if pconf.KeepLastFrame != 0 {
+		if pconf.KeepLastFrame < 0 {
+			return fmt.Errorf("'keepLastFrame' can't be negative")
+		}
+
+		if pconf.Source != "publisher" {
+			return fmt.Errorf("'keepLastFrame' can be used only when source is 'publisher'")
+		}
+	}
+
+	if pconf.ReaderGracePeriod < 0 {
+		return fmt.Errorf("'readerGracePeriod' can't be negative")
+	}
+
+	if pconf.MulticastOutputAddress != "" {
+		if pconf.Regexp != nil {
+			return fmt.Errorf("a path with a regular expression (or path 'all') cannot have a multicast output. use another path")
+		}
+
+		host, port, err := net.SplitHostPort(pconf.MulticastOutputAddress)
+		if err != nil {
+			return fmt.Errorf("'%s' is not a valid multicast address", pconf.MulticastOutputAddress)
+		}
+
+		ip := net.ParseIP(host)
+		if ip == nil || ip.To4() == nil || !ip.IsMulticast() {
+			return fmt.Errorf("'%s' is not a IPv4 multicast IP", host)
+		}
+
+		tmp, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || tmp == 0 || (tmp%2) != 0 {
+			return fmt.Errorf("multicast output port must be even")
+		}
+
+		for otherName, otherPath := range conf.Paths {
+			if otherPath != pconf && otherPath != nil &&
+				otherPath.MulticastOutputAddress == pconf.MulticastOutputAddress {
+				return fmt.Errorf("multicast output address '%s' is used in two paths, '%s' and '%s'",
+					pconf.MulticastOutputAddress, name, otherName)
+			}
+		}
+
+		if pconf.MulticastOutputTTL == 0 {
+			pconf.MulticastOutputTTL = 16
+		}
+		if pconf.MulticastOutputTTL > 255 {
+			return fmt.Errorf("'multicastOutputTTL' must be lower than 256")
+		}
+
+		if pconf.MulticastOutputSource != "" {
+			ip := net.ParseIP(pconf.MulticastOutputSource)
+			if ip == nil || ip.To4() == nil {
+				return fmt.Errorf("'%s' is not a valid IPv4", pconf.MulticastOutputSource)
+			}
+		}
+	}
+
+	if pconf.SEITimestampLabel != "" && !pconf.SEITimestamp {
+		return fmt.Errorf("'seiTimestampLabel' is useless when 'seiTimestamp' is disabled")
+	}
+
+	if pconf.ReaderMaxBitrate < 0 {
+		return fmt.Errorf("'readerMaxBitrate' can't be negative")
+	}
+
+	if pconf.StaticVideoTimeout < 0 {
+		return fmt.Errorf("'staticVideoTimeout' can't be negative")
+	}
+
+	if pconf.SilentAudioTimeout < 0 {
+		return fmt.Errorf("'silentAudioTimeout' can't be negative")
+	}
+
+	if pconf.MaxBitrate < 0 {
+		return fmt.Errorf("'maxBitrate' can't be negative")
+	}
+
+	if pconf.MaxGOPDuration < 0 {
+		return fmt.Errorf("'maxGOPDuration' can't be negative")
+	}
+
+	if pconf.MinHealthScore < 0 || pconf.MinHealthScore > 100 {
+		return fmt.Errorf("'minHealthScore' must be between 0 and 100")
+	}
+
 	if (pconf.PublishUser != "" && pconf.PublishPass == "") ||
 		(pconf.PublishUser == "" && pconf.PublishPass != "") {
 		return fmt.Errorf("read username and password must be both filled")
@@ -306,6 +662,11 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 			"the stream is not provided by a publisher, but by a fixed source")
 	}
 
+	if pconf.StreamKey != "" && pconf.Source != "publisher" {
+		return fmt.Errorf("'streamKey' is useless when source is not 'publisher', since " +
+			"the stream is not provided by a publisher, but by a fixed source")
+	}
+
 	if len(pconf.PublishIPs) > 0 && conf.ExternalAuthenticationURL != "" {
 		return fmt.Errorf("'publishIPs' can't be used with 'externalAuthenticationURL'")
 	}
@@ -323,6 +684,25 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		return fmt.Errorf("'readIPs' can't be used with 'externalAuthenticationURL'")
 	}
 
+	if len(pconf.Permissions) != 0 {
+		if pconf.PublishUser != "" || len(pconf.PublishIPs) > 0 ||
+			pconf.ReadUser != "" || len(pconf.ReadIPs) > 0 {
+			return fmt.Errorf("'permissions' can't be used together with " +
+				"'publishUser', 'publishIPs', 'readUser' or 'readIPs'")
+		}
+
+		if conf.ExternalAuthenticationURL != "" {
+			return fmt.Errorf("'permissions' can't be used with 'externalAuthenticationURL'")
+		}
+
+		for i, perm := range pconf.Permissions {
+			err := perm.check()
+			if err != nil {
+				return fmt.Errorf("invalid permission %d: %s", i+1, err)
+			}
+		}
+	}
+
 	if pconf.RunOnInit != "" && pconf.Regexp != nil {
 		return fmt.Errorf("a path with a regular expression does not support option 'runOnInit'; use another path")
 	}
@@ -331,6 +711,31 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		return fmt.Errorf("'runOnDemand' can be used only when source is 'publisher'")
 	}
 
+	if (pconf.RunOnMotion != "" || pconf.RunOnMotionWebhook != "") && pconf.SourceONVIFEventsAddress == "" {
+		return fmt.Errorf("'runOnMotion' and 'runOnMotionWebhook' require 'sourceONVIFEventsAddress'")
+	}
+
+	if pconf.RunOnMotionWebhook != "" {
+		u, err := gourl.Parse(pconf.RunOnMotionWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid webhook URL", pconf.RunOnMotionWebhook)
+		}
+	}
+
+	if pconf.RunOnAlertWebhook != "" {
+		u, err := gourl.Parse(pconf.RunOnAlertWebhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid webhook URL", pconf.RunOnAlertWebhook)
+		}
+	}
+
+	if pconf.RunOnSCTE35Webhook != "" {
+		u, err := gourl.Parse(pconf.RunOnSCTE35Webhook)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("'%s' is not a valid webhook URL", pconf.RunOnSCTE35Webhook)
+		}
+	}
+
 	if pconf.RunOnDemandStartTimeout == 0 {
 		pconf.RunOnDemandStartTimeout = 10 * StringDuration(time.Second)
 	}
@@ -339,6 +744,14 @@ func (pconf *PathConf) checkAndFillMissing(conf *Conf, name string) error {
 		pconf.RunOnDemandCloseAfter = 10 * StringDuration(time.Second)
 	}
 
+	if pconf.RunOnUser != "" && runtime.GOOS == "windows" {
+		return fmt.Errorf("'runOnUser' is not supported on Windows")
+	}
+
+	if (pconf.RunOnCPULimit != 0 || pconf.RunOnMemoryLimit != 0) && runtime.GOOS != "linux" {
+		return fmt.Errorf("'runOnCPULimit' and 'runOnMemoryLimit' are supported on Linux only")
+	}
+
 	return nil
 }
 
@@ -372,7 +785,13 @@ func (pconf PathConf) HasStaticSource() bool {
 		strings.HasPrefix(pconf.Source, "http://") ||
 		strings.HasPrefix(pconf.Source, "https://") ||
 		strings.HasPrefix(pconf.Source, "udp://") ||
-		pconf.Source == "rpiCamera"
+		strings.HasPrefix(pconf.Source, "pipe://") ||
+		pconf.Source == "stdin" ||
+		strings.HasPrefix(pconf.Source, "exec://") ||
+		strings.HasPrefix(pconf.Source, "file://") ||
+		strings.HasPrefix(pconf.Source, "s3://") ||
+		pconf.Source == "rpiCamera" ||
+		pconf.Source == "testsrc"
 }
 
 // HasOnDemandStaticSource checks whether the path has a on demand static source.
@@ -380,6 +799,41 @@ func (pconf PathConf) HasOnDemandStaticSource() bool {
 	return pconf.HasStaticSource() && pconf.SourceOnDemand
 }
 
+// HasSourceTemplate checks whether the source contains templates
+// (i.e. {query:key}, {group:1} or {protocol}), that are filled with the reader that starts the source.
+func (pconf PathConf) HasSourceTemplate() bool {
+	return reSourceTemplate.MatchString(pconf.Source)
+}
+
+// SourceWithTemplates returns the source, with templates replaced by
+// values of the given query, by capture groups of the path regular expression
+// and by the protocol of the reader.
+// Values are escaped in order to avoid changing the URL structure.
+func (pconf PathConf) SourceWithTemplates(rawQuery string, matches []string, protocol string) string {
+	query, _ := gourl.ParseQuery(rawQuery)
+
+	return reSourceTemplate.ReplaceAllStringFunc(pconf.Source, func(m string) string {
+		sm := reSourceTemplate.FindStringSubmatch(m)
+
+		var v string
+		switch {
+		case sm[1] != "":
+			v = query.Get(sm[1])
+
+		case sm[2] != "":
+			i, _ := strconv.ParseUint(sm[2], 10, 31)
+			if i >= 1 && int(i) < len(matches) {
+				v = matches[i]
+			}
+
+		default:
+			v = protocol
+		}
+
+		return strings.ReplaceAll(gourl.QueryEscape(v), "+", "%20")
+	})
+}
+
 // HasOnDemandPublisher checks whether the path has a on-demand publisher.
 func (pconf PathConf) HasOnDemandPublisher() bool {
 	return pconf.RunOnDemand != ""
@@ -0,0 +1,43 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// PathNameMatchers is a parameter that contains a list of path names
+// or regular expressions, that start with a tilde.
+type PathNameMatchers []string
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *PathNameMatchers) UnmarshalJSON(b []byte) error {
+	var in []string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	for _, entry := range in {
+		if entry == "" {
+			return fmt.Errorf("empty path name")
+		}
+
+		if entry[0] == '~' {
+			_, err := regexp.Compile(entry[1:])
+			if err != nil {
+				return fmt.Errorf("invalid regular expression: %s", entry[1:])
+			}
+		}
+	}
+
+	*d = in
+
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *PathNameMatchers) unmarshalEnv(s string) error {
+	byts, _ := json.Marshal(strings.Split(s, ","))
+	return d.UnmarshalJSON(byts)
+}
@@ -0,0 +1,61 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+var permissionActions = []string{"publish", "read"}
+
+var permissionProtocols = []string{"rtsp", "rtmp", "hls", "webrtc", "http"}
+
+func stringInSlice(v string, list []string) bool {
+	for _, item := range list {
+		if item == v {
+			return true
+		}
+	}
+	return false
+}
+
+// PathPermission is an entry of the permissions of a path.
+type PathPermission struct {
+	User      Credential `json:"user"`
+	Pass      Credential `json:"pass"`
+	IPs       IPsOrCIDRs `json:"ips"`
+	Actions   []string   `json:"actions"`
+	Protocols []string   `json:"protocols"`
+}
+
+func (p *PathPermission) check() error {
+	if (p.User != "" && p.Pass == "") ||
+		(p.User == "" && p.Pass != "") {
+		return fmt.Errorf("user and pass must be both filled")
+	}
+
+	if len(p.Actions) == 0 {
+		return fmt.Errorf("at least one action must be provided")
+	}
+
+	for _, action := range p.Actions {
+		if !stringInSlice(action, permissionActions) {
+			return fmt.Errorf("invalid action '%s'", action)
+		}
+	}
+
+	for _, proto := range p.Protocols {
+		if !stringInSlice(proto, permissionProtocols) {
+			return fmt.Errorf("invalid protocol '%s'", proto)
+		}
+	}
+
+	return nil
+}
+
+// PathPermissions is the permissions parameter.
+type PathPermissions []PathPermission
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *PathPermissions) unmarshalEnv(s string) error {
+	return json.Unmarshal([]byte(s), (*[]PathPermission)(d))
+}
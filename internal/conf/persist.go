@@ -0,0 +1,482 @@
+package conf
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	yamlv3 "gopkg.in/yaml.v3"
+)
+
+// pathsOverrides is the content of the file that contains paths persisted by the API,
+// when they are not persisted into the main configuration file.
+type pathsOverrides struct {
+	Paths        map[string]map[string]interface{} `yaml:"paths"`
+	RemovedPaths []string                          `yaml:"removedPaths,omitempty"`
+}
+
+// ChangedPaths returns the paths that have been added, edited or removed in newConf,
+// with their new configuration, or nil when they have been removed.
+func ChangedPaths(oldConf *Conf, newConf *Conf) map[string]*PathConf {
+	ret := make(map[string]*PathConf)
+
+	for name, pconf := range newConf.Paths {
+		if oldPconf, ok := oldConf.Paths[name]; !ok || !reflect.DeepEqual(oldPconf, pconf) {
+			ret[name] = pconf
+		}
+	}
+
+	for name := range oldConf.Paths {
+		if _, ok := newConf.Paths[name]; !ok {
+			ret[name] = nil
+		}
+	}
+
+	return ret
+}
+
+// pathConfDiff returns the parameters of a path configuration that differ from the default ones.
+func pathConfDiff(conf *Conf, name string, pconf *PathConf) (map[string]interface{}, error) {
+	toMap := func(v interface{}) (map[string]interface{}, error) {
+		byts, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		dec := json.NewDecoder(bytes.NewReader(byts))
+		dec.UseNumber()
+
+		var ret map[string]interface{}
+		err = dec.Decode(&ret)
+		return ret, err
+	}
+
+	cur, err := toMap(pconf)
+	if err != nil {
+		return nil, err
+	}
+
+	def := &PathConf{}
+	def.checkAndFillMissing(conf, name) //nolint:errcheck
+
+	defMap, err := toMap(def)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]interface{})
+	for key, val := range cur {
+		if !reflect.DeepEqual(val, defMap[key]) {
+			ret[key] = val
+		}
+	}
+
+	return ret, nil
+}
+
+// pathConfYAML returns a path configuration in YAML format, indented by the given amount of spaces.
+// key is the name of the entry, that may be an alias of the path name.
+func pathConfYAML(conf *Conf, key string, name string, pconf *PathConf, indent int) (string, error) {
+	diff, err := pathConfDiff(conf, name, pconf)
+	if err != nil {
+		return "", err
+	}
+
+	var buf bytes.Buffer
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+
+	err = enc.Encode(map[string]interface{}{key: diff})
+	if err != nil {
+		return "", err
+	}
+
+	prefix := strings.Repeat(" ", indent)
+	lines := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = prefix + line
+	}
+
+	return strings.Join(lines, "\n") + "\n", nil
+}
+
+// yamlNodeLastLine returns the last line occupied by a YAML node and its children.
+func yamlNodeLastLine(n *yamlv3.Node) int {
+	ret := n.Line
+	if n.Kind == yamlv3.ScalarNode && (n.Style&(yamlv3.LiteralStyle|yamlv3.FoldedStyle)) != 0 {
+		ret += strings.Count(strings.TrimSuffix(n.Value, "\n"), "\n") + 1
+	}
+
+	for _, c := range n.Content {
+		if l := yamlNodeLastLine(c); l > ret {
+			ret = l
+		}
+	}
+
+	return ret
+}
+
+type persistEdit struct {
+	// lines to replace, starting from 1. When last is lower than first, text is inserted before first.
+	first int
+	last  int
+	text  string
+}
+
+func applyPersistEdits(byts []byte, edits []persistEdit) []byte {
+	lines := strings.SplitAfter(string(byts), "\n")
+	if len(lines) != 0 && lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	if len(lines) != 0 && !strings.HasSuffix(lines[len(lines)-1], "\n") {
+		lines[len(lines)-1] += "\n"
+	}
+
+	// apply edits from the bottom, in order not to change lines of the following ones
+	sort.SliceStable(edits, func(i, j int) bool {
+		return edits[i].first > edits[j].first
+	})
+
+	for _, e := range edits {
+		tail := append([]string{}, lines[e.last:]...)
+		lines = append(lines[:e.first-1], e.text)
+		lines = append(lines, tail...)
+	}
+
+	return []byte(strings.Join(lines, ""))
+}
+
+// persistPathsIntoConfFile edits paths of a configuration file in place,
+// leaving untouched every other part of the file, comments included.
+func persistPathsIntoConfFile(byts []byte, conf *Conf, changed map[string]*PathConf) ([]byte, error) {
+	var doc yamlv3.Node
+	err := yamlv3.Unmarshal(byts, &doc)
+	if err != nil {
+		return nil, err
+	}
+
+	var root *yamlv3.Node
+	if doc.Kind == yamlv3.DocumentNode && len(doc.Content) != 0 {
+		root = doc.Content[0]
+	}
+
+	if root != nil && root.Kind != yamlv3.MappingNode {
+		return nil, fmt.Errorf("the configuration file doesn't contain a map")
+	}
+
+	names := make([]string, 0, len(changed))
+	for name := range changed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	renderAll := func(indent int) (string, error) {
+		var text string
+		for _, name := range names {
+			if changed[name] == nil {
+				continue
+			}
+			t, err := pathConfYAML(conf, name, name, changed[name], indent)
+			if err != nil {
+				return "", err
+			}
+			text += t
+		}
+		return text, nil
+	}
+
+	// find the paths section
+	var pathsKey *yamlv3.Node
+	var paths *yamlv3.Node
+	if root != nil {
+		for i := 0; i+1 < len(root.Content); i += 2 {
+			if root.Content[i].Value == "paths" {
+				pathsKey = root.Content[i]
+				paths = root.Content[i+1]
+				break
+			}
+		}
+	}
+
+	// the section doesn't exist: append it
+	if pathsKey == nil {
+		text, err := renderAll(2)
+		if err != nil {
+			return nil, err
+		}
+
+		if text == "" {
+			return byts, nil
+		}
+
+		if len(byts) != 0 && !bytes.HasSuffix(byts, []byte("\n")) {
+			byts = append(byts, '\n')
+		}
+		return append(byts, []byte("\npaths:\n"+text)...), nil
+	}
+
+	// the section is empty or in flow style: rewrite it entirely
+	if paths.Kind != yamlv3.MappingNode || paths.Style&yamlv3.FlowStyle != 0 || len(paths.Content) == 0 {
+		all := make(map[string]*PathConf)
+		for name, pconf := range conf.Paths {
+			all[name] = pconf
+		}
+		names = names[:0]
+		for name := range all {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		changed = all
+
+		text, err := renderAll(pathsKey.Column + 1)
+		if err != nil {
+			return nil, err
+		}
+
+		return applyPersistEdits(byts, []persistEdit{{
+			first: pathsKey.Line,
+			last:  yamlNodeLastLine(paths),
+			text:  strings.Repeat(" ", pathsKey.Column-1) + "paths:\n" + text,
+		}}), nil
+	}
+
+	indent := paths.Content[0].Column - 1
+	var edits []persistEdit
+	var added string
+
+	for _, name := range names {
+		pconf := changed[name]
+
+		var key *yamlv3.Node
+		var val *yamlv3.Node
+		for i := 0; i+1 < len(paths.Content); i += 2 {
+			k := paths.Content[i].Value
+			if k == name || (k == "all" && name == "~^.*$") {
+				key = paths.Content[i]
+				val = paths.Content[i+1]
+				break
+			}
+		}
+
+		var text string
+		if pconf != nil {
+			entryName := name
+			if key != nil {
+				entryName = key.Value
+			}
+
+			var err error
+			text, err = pathConfYAML(conf, entryName, name, pconf, indent)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if key == nil {
+			added += text
+			continue
+		}
+
+		edits = append(edits, persistEdit{
+			first: key.Line,
+			last:  yamlNodeLastLine(val),
+			text:  text,
+		})
+	}
+
+	if added != "" {
+		end := yamlNodeLastLine(paths)
+		edits = append(edits, persistEdit{
+			first: end + 1,
+			last:  end,
+			text:  added,
+		})
+	}
+
+	return applyPersistEdits(byts, edits), nil
+}
+
+// persistPathsIntoOverridesFile edits paths of a file that is dedicated to paths persisted by the API.
+func persistPathsIntoOverridesFile(byts []byte, conf *Conf, changed map[string]*PathConf) ([]byte, error) {
+	var ov pathsOverrides
+	if byts != nil {
+		err := yamlv3.Unmarshal(byts, &ov)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if ov.Paths == nil {
+		ov.Paths = make(map[string]map[string]interface{})
+	}
+
+	removed := make(map[string]struct{})
+	for _, name := range ov.RemovedPaths {
+		removed[name] = struct{}{}
+	}
+
+	for name, pconf := range changed {
+		if pconf == nil {
+			delete(ov.Paths, name)
+			removed[name] = struct{}{}
+			continue
+		}
+
+		diff, err := pathConfDiff(conf, name, pconf)
+		if err != nil {
+			return nil, err
+		}
+
+		ov.Paths[name] = diff
+		delete(removed, name)
+	}
+
+	ov.RemovedPaths = nil
+	for name := range removed {
+		ov.RemovedPaths = append(ov.RemovedPaths, name)
+	}
+	sort.Strings(ov.RemovedPaths)
+
+	var buf bytes.Buffer
+	buf.WriteString("# Paths added, edited or removed with the API. This file is generated automatically.\n")
+
+	enc := yamlv3.NewEncoder(&buf)
+	enc.SetIndent(2)
+	err := enc.Encode(ov)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// writeFileAtomic writes a file by writing a temporary file and renaming it,
+// in order not to leave a corrupted file in case of crash.
+func writeFileAtomic(fpath string, byts []byte) error {
+	mode := os.FileMode(0o644)
+	if fi, err := os.Stat(fpath); err == nil {
+		mode = fi.Mode().Perm()
+	}
+
+	tmpf, err := os.CreateTemp(filepath.Dir(fpath), "."+filepath.Base(fpath)+".tmp")
+	if err != nil {
+		return err
+	}
+
+	_, err = tmpf.Write(byts)
+	if err == nil {
+		err = tmpf.Chmod(mode)
+	}
+	if err == nil {
+		err = tmpf.Sync()
+	}
+	tmpf.Close()
+
+	if err != nil {
+		os.Remove(tmpf.Name())
+		return err
+	}
+
+	err = os.Rename(tmpf.Name(), fpath)
+	if err != nil {
+		os.Remove(tmpf.Name())
+		return err
+	}
+
+	return nil
+}
+
+// PersistPaths writes path configurations changed at runtime into a file.
+// changed contains the configuration of added and edited paths, and nil for removed paths.
+// If overrides is false, the file is the main configuration file, and everything but the changed
+// paths is left untouched. Otherwise, the file is dedicated to persisted paths.
+// It returns the content of the written file.
+func PersistPaths(fpath string, overrides bool, conf *Conf, changed map[string]*PathConf) ([]byte, error) {
+	if !overrides {
+		if _, ok := os.LookupEnv("MTX_CONFKEY"); ok {
+			return nil, fmt.Errorf("encrypted configuration files can't be edited")
+		}
+		if _, ok := os.LookupEnv("RTSP_CONFKEY"); ok {
+			return nil, fmt.Errorf("encrypted configuration files can't be edited")
+		}
+
+		fpath = resolveConfPath(fpath)
+	}
+
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			return nil, err
+		}
+		byts = nil
+	}
+
+	if overrides {
+		byts, err = persistPathsIntoOverridesFile(byts, conf, changed)
+	} else {
+		byts, err = persistPathsIntoConfFile(byts, conf, changed)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	err = writeFileAtomic(fpath, byts)
+	if err != nil {
+		return nil, err
+	}
+
+	return byts, nil
+}
+
+// loadPathsOverrides applies paths persisted by the API into a dedicated file.
+func loadPathsOverrides(fpath string, conf *Conf) error {
+	byts, err := os.ReadFile(fpath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	var ov pathsOverrides
+	err = yamlv3.Unmarshal(byts, &ov)
+	if err != nil {
+		return fmt.Errorf("unable to load %s: %s", fpath, err)
+	}
+
+	for _, name := range ov.RemovedPaths {
+		delete(conf.Paths, name)
+		if name == "~^.*$" {
+			delete(conf.Paths, "all")
+		}
+	}
+
+	for name, params := range ov.Paths {
+		enc, err := json.Marshal(params)
+		if err != nil {
+			return err
+		}
+
+		pconf := &PathConf{}
+		err = json.Unmarshal(enc, pconf)
+		if err != nil {
+			return fmt.Errorf("unable to load %s: path '%s': %s", fpath, name, err)
+		}
+
+		if conf.Paths == nil {
+			conf.Paths = make(map[string]*PathConf)
+		}
+
+		if name == "~^.*$" {
+			delete(conf.Paths, "all")
+		}
+		conf.Paths[name] = pconf
+	}
+
+	return nil
+}
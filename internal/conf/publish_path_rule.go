@@ -0,0 +1,56 @@
+package conf
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+var publishPathRuleIdentities = []string{"user", "token"}
+
+// PublishPathRule is an entry of the publishPathRules parameter.
+// It confines the publishers whose identity matches Match
+// to the paths that start with Prefix.
+type PublishPathRule struct {
+	Identity string `json:"identity"`
+	Match    string `json:"match"`
+	Prefix   string `json:"prefix"`
+}
+
+func (r *PublishPathRule) checkAndFillMissing() error {
+	if r.Identity == "" {
+		r.Identity = "user"
+	}
+
+	if !stringInSlice(r.Identity, publishPathRuleIdentities) {
+		return fmt.Errorf("invalid identity '%s'", r.Identity)
+	}
+
+	if r.Match == "" {
+		r.Match = "^(.+)$"
+	}
+
+	_, err := regexp.Compile(r.Match)
+	if err != nil {
+		return fmt.Errorf("invalid 'match': %s", err)
+	}
+
+	if r.Prefix == "" {
+		return fmt.Errorf("'prefix' is required")
+	}
+
+	if strings.HasPrefix(r.Prefix, "/") {
+		return fmt.Errorf("'prefix' can't begin with a slash")
+	}
+
+	return nil
+}
+
+// PublishPathRules is the publishPathRules parameter.
+type PublishPathRules []PublishPathRule
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *PublishPathRules) unmarshalEnv(s string) error {
+	return json.Unmarshal([]byte(s), (*[]PublishPathRule)(d))
+}
@@ -0,0 +1,33 @@
+package conf
+
+import (
+	"fmt"
+)
+
+// SocketOptions are the options of the sockets of a listener.
+type SocketOptions struct {
+	// size of the receive buffer (SO_RCVBUF). Zero means the default of the system.
+	ReadBufferSize StringSize `json:"readBufferSize"`
+
+	// size of the send buffer (SO_SNDBUF). Zero means the default of the system.
+	WriteBufferSize StringSize `json:"writeBufferSize"`
+
+	// enable the Nagle algorithm, that is, disable TCP_NODELAY.
+	Nagle bool `json:"nagle"`
+}
+
+func (o SocketOptions) check(name string, isUDP bool) error {
+	const maxBufferSize = 1 << 30
+
+	if o.ReadBufferSize > maxBufferSize {
+		return fmt.Errorf("'%s.readBufferSize' must be lower than 1GB", name)
+	}
+	if o.WriteBufferSize > maxBufferSize {
+		return fmt.Errorf("'%s.writeBufferSize' must be lower than 1GB", name)
+	}
+	if isUDP && o.Nagle {
+		return fmt.Errorf("'%s.nagle' can't be used with UDP sockets", name)
+	}
+
+	return nil
+}
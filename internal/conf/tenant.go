@@ -0,0 +1,115 @@
+package conf
+
+import (
+	"fmt"
+	"strings"
+)
+
+// TenantConf is a tenant configuration.
+type TenantConf struct {
+	// namespace
+	Prefix string `json:"prefix"`
+
+	// sources
+	AllowedSourceHosts []string `json:"allowedSourceHosts"`
+
+	// quotas
+	MaxPaths      int `json:"maxPaths"`
+	MaxPublishers int `json:"maxPublishers"`
+	MaxReaders    int `json:"maxReaders"`
+	MaxBandwidth  int `json:"maxBandwidth"`
+
+	// API
+	APIUser Credential `json:"apiUser"`
+	APIPass Credential `json:"apiPass"`
+}
+
+func (tconf *TenantConf) checkAndFillMissing(conf *Conf, name string) error {
+	if tconf.Prefix == "" {
+		return fmt.Errorf("tenant '%s': 'prefix' is required", name)
+	}
+
+	// the prefix must end with a slash, otherwise a tenant with prefix "team1"
+	// would own the paths of a tenant with prefix "team10/".
+	if !strings.HasSuffix(tconf.Prefix, "/") {
+		return fmt.Errorf("tenant '%s': 'prefix' must end with '/'", name)
+	}
+
+	// the prefix must be usable as the beginning of a path name.
+	err := IsValidPathName(tconf.Prefix + "path")
+	if err != nil {
+		return fmt.Errorf("tenant '%s': invalid 'prefix': %s", name, err)
+	}
+
+	for otherName, other := range conf.Tenants {
+		if other == tconf || other == nil {
+			continue
+		}
+
+		if strings.HasPrefix(tconf.Prefix, other.Prefix) || strings.HasPrefix(other.Prefix, tconf.Prefix) {
+			return fmt.Errorf("tenants '%s' and '%s' have overlapping prefixes", name, otherName)
+		}
+	}
+
+	for _, host := range tconf.AllowedSourceHosts {
+		if host == "" || strings.ContainsAny(host, "/@ ") {
+			return fmt.Errorf("tenant '%s': invalid host in 'allowedSourceHosts': '%s'", name, host)
+		}
+	}
+
+	if tconf.MaxPaths < 0 {
+		return fmt.Errorf("tenant '%s': 'maxPaths' can't be negative", name)
+	}
+
+	if tconf.MaxPublishers < 0 {
+		return fmt.Errorf("tenant '%s': 'maxPublishers' can't be negative", name)
+	}
+
+	if tconf.MaxReaders < 0 {
+		return fmt.Errorf("tenant '%s': 'maxReaders' can't be negative", name)
+	}
+
+	if tconf.MaxBandwidth < 0 {
+		return fmt.Errorf("tenant '%s': 'maxBandwidth' can't be negative", name)
+	}
+
+	if (tconf.APIUser != "" && tconf.APIPass == "") ||
+		(tconf.APIUser == "" && tconf.APIPass != "") {
+		return fmt.Errorf("tenant '%s': API username and password must be both filled", name)
+	}
+
+	if tconf.APIUser != "" && conf.APIUser == "" {
+		return fmt.Errorf("tenant '%s': API credentials of tenants require 'apiUser' and 'apiPass'", name)
+	}
+
+	if tconf.MaxPaths != 0 {
+		count := 0
+		for pathName, pconf := range conf.Paths {
+			if (pconf == nil || pconf.Regexp == nil) && strings.HasPrefix(pathName, tconf.Prefix) {
+				count++
+			}
+		}
+
+		if count > tconf.MaxPaths {
+			return fmt.Errorf("tenant '%s' has %d configured paths, but 'maxPaths' is %d",
+				name, count, tconf.MaxPaths)
+		}
+	}
+
+	return nil
+}
+
+// Owns checks whether a path belongs to the namespace of the tenant.
+func (tconf TenantConf) Owns(pathName string) bool {
+	return strings.HasPrefix(pathName, tconf.Prefix)
+}
+
+// FindTenant returns the tenant that owns the given path, if any.
+func FindTenant(tenants map[string]*TenantConf, pathName string) (string, *TenantConf) {
+	for name, tconf := range tenants {
+		if tconf.Owns(pathName) {
+			return name, tconf
+		}
+	}
+	return "", nil
+}
@@ -0,0 +1,58 @@
+package conf
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// TLSCipherSuites is the tlsCipherSuites parameter.
+type TLSCipherSuites []uint16
+
+// MarshalJSON implements json.Marshaler.
+func (d TLSCipherSuites) MarshalJSON() ([]byte, error) {
+	out := make([]string, len(d))
+
+	for i, v := range d {
+		out[i] = tls.CipherSuiteName(v)
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *TLSCipherSuites) UnmarshalJSON(b []byte) error {
+	var in []string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	*d = nil
+
+outer:
+	for _, v := range in {
+		// insecure cipher suites are not allowed.
+		for _, s := range tls.CipherSuites() {
+			if s.Name == v {
+				// TLS 1.3 cipher suites are not configurable.
+				if len(s.SupportedVersions) == 1 && s.SupportedVersions[0] == tls.VersionTLS13 {
+					return fmt.Errorf("cipher suite '%s' belongs to TLS 1.3 and can't be configured", v)
+				}
+
+				*d = append(*d, s.ID)
+				continue outer
+			}
+		}
+
+		return fmt.Errorf("invalid cipher suite: '%s'", v)
+	}
+
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *TLSCipherSuites) unmarshalEnv(s string) error {
+	byts, _ := json.Marshal(strings.Split(s, ","))
+	return d.UnmarshalJSON(byts)
+}
@@ -0,0 +1,71 @@
+package conf
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"fmt"
+)
+
+// TLSVersion is the tlsMinVersion parameter.
+type TLSVersion uint16
+
+// String implements fmt.Stringer.
+func (d TLSVersion) String() string {
+	switch d {
+	case tls.VersionTLS10:
+		return "1.0"
+
+	case tls.VersionTLS11:
+		return "1.1"
+
+	case tls.VersionTLS12:
+		return "1.2"
+
+	case tls.VersionTLS13:
+		return "1.3"
+	}
+
+	return "unknown"
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d TLSVersion) MarshalJSON() ([]byte, error) {
+	out := d.String()
+	if out == "unknown" {
+		return nil, fmt.Errorf("invalid TLS version: %v", uint16(d))
+	}
+
+	return json.Marshal(out)
+}
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *TLSVersion) UnmarshalJSON(b []byte) error {
+	var in string
+	if err := json.Unmarshal(b, &in); err != nil {
+		return err
+	}
+
+	switch in {
+	case "1.0":
+		*d = tls.VersionTLS10
+
+	case "1.1":
+		*d = tls.VersionTLS11
+
+	case "1.2":
+		*d = tls.VersionTLS12
+
+	case "1.3":
+		*d = tls.VersionTLS13
+
+	default:
+		return fmt.Errorf("invalid TLS version: '%s'", in)
+	}
+
+	return nil
+}
+
+// unmarshalEnv implements envUnmarshaler.
+func (d *TLSVersion) unmarshalEnv(s string) error {
+	return d.UnmarshalJSON([]byte(`"` + s + `"`))
+}
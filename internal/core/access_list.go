@@ -0,0 +1,116 @@
+package core
+
+import (
+	"fmt"
+	"net"
+
+	"github.com/oschwald/maxminddb-golang"
+
+	"github.com/aler9/mediamtx/internal/auth"
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+type accessListGeoIPRecord struct {
+	Country struct {
+		ISOCode string `maxminddb:"iso_code"`
+	} `maxminddb:"country"`
+}
+
+// accessList restricts the IPs that are allowed to connect to the server,
+// regardless of the path they want to read or publish.
+type accessList struct {
+	allowedReadIPs        conf.IPsOrCIDRs
+	allowedPublishIPs     conf.IPsOrCIDRs
+	geoIPAllowedCountries map[string]struct{}
+	geoIPDeniedCountries  map[string]struct{}
+
+	geoIP *maxminddb.Reader
+}
+
+func newAccessList(
+	allowedReadIPs conf.IPsOrCIDRs,
+	allowedPublishIPs conf.IPsOrCIDRs,
+	geoIPDatabase string,
+	geoIPAllowedCountries []string,
+	geoIPDeniedCountries []string,
+) (*accessList, error) {
+	l := &accessList{
+		allowedReadIPs:        allowedReadIPs,
+		allowedPublishIPs:     allowedPublishIPs,
+		geoIPAllowedCountries: make(map[string]struct{}),
+		geoIPDeniedCountries:  make(map[string]struct{}),
+	}
+
+	if geoIPDatabase != "" {
+		var err error
+		l.geoIP, err = maxminddb.Open(geoIPDatabase)
+		if err != nil {
+			return nil, fmt.Errorf("unable to open the GeoIP database: %s", err)
+		}
+	}
+
+	for _, c := range geoIPAllowedCountries {
+		l.geoIPAllowedCountries[c] = struct{}{}
+	}
+
+	for _, c := range geoIPDeniedCountries {
+		l.geoIPDeniedCountries[c] = struct{}{}
+	}
+
+	return l, nil
+}
+
+func (l *accessList) close() {
+	if l.geoIP != nil {
+		l.geoIP.Close()
+	}
+}
+
+func (l *accessList) country(ip net.IP) string {
+	var record accessListGeoIPRecord
+	err := l.geoIP.Lookup(ip, &record)
+	if err != nil {
+		return ""
+	}
+	return record.Country.ISOCode
+}
+
+// checkConn is called when a connection is accepted, when the action of the client
+// is still unknown.
+func (l *accessList) checkConn(ip net.IP) error {
+	if l.allowedReadIPs != nil && l.allowedPublishIPs != nil &&
+		!auth.IPEqualOrInRange(ip, l.allowedReadIPs) &&
+		!auth.IPEqualOrInRange(ip, l.allowedPublishIPs) {
+		return fmt.Errorf("IP '%s' not allowed", ip)
+	}
+
+	if l.geoIP != nil {
+		country := l.country(ip)
+
+		if len(l.geoIPAllowedCountries) != 0 {
+			if _, ok := l.geoIPAllowedCountries[country]; !ok {
+				return fmt.Errorf("IP '%s' not allowed (country '%s')", ip, country)
+			}
+		}
+
+		if _, ok := l.geoIPDeniedCountries[country]; ok {
+			return fmt.Errorf("IP '%s' not allowed (country '%s')", ip, country)
+		}
+	}
+
+	return nil
+}
+
+// checkAction is called when the client is authenticated.
+func (l *accessList) checkAction(ip net.IP, isPublishing bool) error {
+	ips := l.allowedReadIPs
+	if isPublishing {
+		ips = l.allowedPublishIPs
+	}
+
+	if ips != nil && !auth.IPEqualOrInRange(ip, ips) {
+		return fmt.Errorf("IP '%s' not allowed", ip)
+	}
+
+	return l.checkConn(ip)
+}
@@ -0,0 +1,43 @@
+package core
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAccessList(t *testing.T) {
+	p, ok := newInstance("allowedReadIPs: [10.0.0.0/8]\n" +
+		"allowedPublishIPs: [127.0.0.1]\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/mystream", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	u, err := url.Parse("rtsp://127.0.0.1:8554/mystream")
+	require.NoError(t, err)
+
+	reader := gortsplib.Client{}
+	err = reader.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer reader.Close()
+
+	_, _, _, err = reader.Describe(u)
+	require.EqualError(t, err, "bad status code: 401 (Unauthorized)")
+
+	hc := &http.Client{Transport: &http.Transport{}}
+
+	res, err := hc.Get("http://localhost:8888/mystream/")
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusForbidden, res.StatusCode)
+}
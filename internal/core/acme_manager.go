@@ -0,0 +1,97 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type acmeManagerParent interface {
+	logger.Writer
+}
+
+// acmeManager obtains and renews TLS certificates automatically
+// with the ACME protocol (i.e. from Let's Encrypt).
+type acmeManager struct {
+	parent acmeManagerParent
+
+	manager    *autocert.Manager
+	ln         net.Listener
+	httpServer *http.Server
+}
+
+func newACMEManager(
+	domains []string,
+	email string,
+	cacheDir string,
+	directoryURL string,
+	httpAddress string,
+	readTimeout conf.StringDuration,
+	parent acmeManagerParent,
+) (*acmeManager, error) {
+	m := &acmeManager{
+		parent: parent,
+		manager: &autocert.Manager{
+			Prompt:     autocert.AcceptTOS,
+			HostPolicy: autocert.HostWhitelist(domains...),
+			Cache:      autocert.DirCache(cacheDir),
+			Email:      email,
+		},
+	}
+
+	if directoryURL != "" {
+		m.manager.Client = &acme.Client{
+			DirectoryURL: directoryURL,
+		}
+	}
+
+	// HTTP-01 challenges. Without this listener, only TLS-ALPN-01 challenges
+	// can be performed, and they require a TLS listener on port 443.
+	if httpAddress != "" {
+		var err error
+		m.ln, err = listenMultiple("tcp", httpAddress)
+		if err != nil {
+			return nil, err
+		}
+
+		m.httpServer = &http.Server{
+			Handler:           m.manager.HTTPHandler(nil),
+			ReadHeaderTimeout: time.Duration(readTimeout),
+			ErrorLog:          log.New(&nilWriter{}, "", 0),
+		}
+
+		m.Log(logger.Info, "challenge listener opened on "+httpAddress)
+
+		go m.httpServer.Serve(m.ln)
+	}
+
+	return m, nil
+}
+
+func (m *acmeManager) close() {
+	if m.httpServer != nil {
+		m.Log(logger.Info, "challenge listener is closing")
+		m.httpServer.Shutdown(context.Background())
+		m.ln.Close() // in case Shutdown() is called before Serve()
+	}
+}
+
+// Log is the main logging function.
+func (m *acmeManager) Log(level logger.Level, format string, args ...interface{}) {
+	m.parent.Log(level, "[ACME] "+format, args...)
+}
+
+// tlsConfig returns a TLS configuration that obtains certificates
+// during handshakes, and renews them before they expire.
+func (m *acmeManager) tlsConfig() *tls.Config {
+	return m.manager.TLSConfig()
+}
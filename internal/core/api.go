@@ -3,19 +3,30 @@ package core
 import (
 	"context"
 	"encoding/json"
+	"fmt"
+	"io"
 	"log"
 	"net"
 	"net/http"
 	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
 	"sync"
 	"time"
 
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/gin-gonic/gin"
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/onvif"
+	"github.com/aler9/mediamtx/internal/websocket"
 )
 
+// apiCaptureMaxDuration is the maximum duration of a capture.
+const apiCaptureMaxDuration = 10 * time.Minute
+
 func interfaceIsEmpty(i interface{}) bool {
 	return reflect.ValueOf(i).Kind() != reflect.Ptr || reflect.ValueOf(i).IsNil()
 }
@@ -58,9 +69,9 @@ func generateStructWithOptionalFields(model interface{}) interface{} {
 	return reflect.New(reflect.StructOf(fields)).Interface()
 }
 
-func loadConfData(ctx *gin.Context) (interface{}, error) {
+func loadConfData(r io.Reader) (interface{}, error) {
 	in := generateStructWithOptionalFields(conf.Conf{})
-	err := json.NewDecoder(ctx.Request.Body).Decode(in)
+	err := json.NewDecoder(r).Decode(in)
 	if err != nil {
 		return nil, err
 	}
@@ -68,9 +79,9 @@ func loadConfData(ctx *gin.Context) (interface{}, error) {
 	return in, err
 }
 
-func loadConfPathData(ctx *gin.Context) (interface{}, error) {
+func loadConfPathData(r io.Reader) (interface{}, error) {
 	in := generateStructWithOptionalFields(conf.PathConf{})
-	err := json.NewDecoder(ctx.Request.Body).Decode(in)
+	err := json.NewDecoder(r).Decode(in)
 	if err != nil {
 		return nil, err
 	}
@@ -78,23 +89,149 @@ func loadConfPathData(ctx *gin.Context) (interface{}, error) {
 	return in, err
 }
 
+func loadConfPathsData(r io.Reader) (map[string]interface{}, error) {
+	var raw map[string]json.RawMessage
+	err := json.NewDecoder(r).Decode(&raw)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make(map[string]interface{}, len(raw))
+
+	for name, byts := range raw {
+		in := generateStructWithOptionalFields(conf.PathConf{})
+		err := json.Unmarshal(byts, in)
+		if err != nil {
+			return nil, err
+		}
+		ret[name] = in
+	}
+
+	return ret, nil
+}
+
+// confWithData returns a copy of the configuration in which fields of the given data are replaced.
+func confWithData(c *conf.Conf, in interface{}) (*conf.Conf, error) {
+	newConf := c.Clone()
+
+	fillStruct(newConf, in)
+
+	// masked secrets sent back by clients keep their current value.
+	err := newConf.KeepMaskedSecrets(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
+// confWithPathAdded returns a copy of the configuration with a new path.
+func confWithPathAdded(c *conf.Conf, name string, in interface{}) (*conf.Conf, error) {
+	newConf := c.Clone()
+
+	if _, ok := newConf.Paths[name]; ok {
+		return nil, fmt.Errorf("path '%s' already exists", name)
+	}
+
+	newConfPath := &conf.PathConf{}
+	fillStruct(newConfPath, in)
+
+	newConf.Paths[name] = newConfPath
+
+	// masked secrets sent back by clients keep their current value.
+	err := newConf.KeepMaskedSecrets(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
+// confWithPathEdited returns a copy of the configuration in which fields of a path are replaced.
+func confWithPathEdited(c *conf.Conf, name string, in interface{}) (*conf.Conf, error) {
+	newConf := c.Clone()
+
+	newConfPath, ok := newConf.Paths[name]
+	if !ok {
+		return nil, fmt.Errorf("path '%s' doesn't exist", name)
+	}
+
+	fillStruct(newConfPath, in)
+
+	// masked secrets sent back by clients keep their current value.
+	err := newConf.KeepMaskedSecrets(c)
+	if err != nil {
+		return nil, err
+	}
+
+	err = newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
+// confWithPathRemoved returns a copy of the configuration without a path.
+func confWithPathRemoved(c *conf.Conf, name string) (*conf.Conf, error) {
+	newConf := c.Clone()
+
+	if _, ok := newConf.Paths[name]; !ok {
+		return nil, fmt.Errorf("path '%s' doesn't exist", name)
+	}
+
+	delete(newConf.Paths, name)
+
+	err := newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
 type apiPathManager interface {
 	apiPathsList() pathAPIPathsListRes
+	apiPathsCapture(req pathAPIPathsCaptureReq) pathAPIPathsCaptureRes
+	apiPathsPTZ(name string) pathAPIPathsPTZRes
+	apiPathsHistory(name string) *pathAPIHistoryData
+	apiPathsReaderRejections(name string) *pathAPIReaderRejectionsData
+	apiPathsDisable(name string, disable bool, message string, drop bool) error
+	apiPathsDisabled() *pathAPIDisabledData
+	apiPathsTee(name string, target string, duration time.Duration) pathAPIPathsTeeRes
+	apiPathsTeeRemove(name string, target string) error
+	apiSourceStartProgress() sourceStartProgressAPI
 }
 
 type apiHLSServer interface {
 	apiMuxersList() hlsServerAPIMuxersListRes
+	apiTokenRevoke(token string) bool
+	apiSessionsRevoke(user string, token string) int
 }
 
 type apiRTSPServer interface {
 	apiConnsList() rtspServerAPIConnsListRes
 	apiSessionsList() rtspServerAPISessionsListRes
 	apiSessionsKick(string) rtspServerAPISessionsKickRes
+	apiSessionsRevoke(user string, token string) int
+	apiSessionsRedirect(string, *url.URL) rtspServerAPISessionsRedirectRes
+	apiMulticastGroupsList() rtspServerAPIMulticastGroupsListRes
 }
 
 type apiRTMPServer interface {
 	apiConnsList() rtmpServerAPIConnsListRes
 	apiConnsKick(id string) rtmpServerAPIConnsKickRes
+	apiConnsRevoke(user string, token string) int
 }
 
 type apiParent interface {
@@ -105,19 +242,35 @@ type apiParent interface {
 type apiWebRTCServer interface {
 	apiConnsList() webRTCServerAPIConnsListRes
 	apiConnsKick(id string) webRTCServerAPIConnsKickRes
+	apiConnsRevoke(user string, token string) int
 }
 
-type api struct {
-	conf         *conf.Conf
-	pathManager  apiPathManager
-	rtspServer   apiRTSPServer
-	rtspsServer  apiRTSPServer
-	rtmpServer   apiRTMPServer
-	rtmpsServer  apiRTMPServer
-	hlsServer    apiHLSServer
-	webRTCServer apiWebRTCServer
-	parent       apiParent
+type apiHTTPIngestServer interface {
+	apiConnsRevoke(user string, token string) int
+}
+
+type apiAuthBanList interface {
+	apiList() authBanListAPIListRes
+	apiUnban(ip string) authBanListAPIUnbanRes
+}
 
+type api struct {
+	conf             *conf.Conf
+	pathManager      apiPathManager
+	rtspServer       apiRTSPServer
+	rtspsServer      apiRTSPServer
+	rtmpServer       apiRTMPServer
+	rtmpsServer      apiRTMPServer
+	hlsServer        apiHLSServer
+	webRTCServer     apiWebRTCServer
+	httpIngestServer apiHTTPIngestServer
+	authBanList      apiAuthBanList
+	events           *apiEvents
+	metrics          *metrics
+	parent           apiParent
+
+	ctx        context.Context
+	ctxCancel  func()
 	ln         net.Listener
 	httpServer *http.Server
 	mutex      sync.Mutex
@@ -125,7 +278,10 @@ type api struct {
 
 func newAPI(
 	address string,
+	socketPermissions conf.FileMode,
 	readTimeout conf.StringDuration,
+	user conf.Credential,
+	pass conf.Credential,
 	conf *conf.Conf,
 	pathManager apiPathManager,
 	rtspServer apiRTSPServer,
@@ -134,70 +290,108 @@ func newAPI(
 	rtmpsServer apiRTMPServer,
 	hlsServer apiHLSServer,
 	webRTCServer apiWebRTCServer,
+	httpIngestServer apiHTTPIngestServer,
+	authBanList apiAuthBanList,
+	events *apiEvents,
+	metrics *metrics,
 	parent apiParent,
 ) (*api, error) {
-	ln, err := net.Listen(restrictNetwork("tcp", address))
+	ln, err := httpListen(address, socketPermissions)
 	if err != nil {
 		return nil, err
 	}
 
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
 	a := &api{
-		conf:         conf,
-		pathManager:  pathManager,
-		rtspServer:   rtspServer,
-		rtspsServer:  rtspsServer,
-		rtmpServer:   rtmpServer,
-		rtmpsServer:  rtmpsServer,
-		hlsServer:    hlsServer,
-		webRTCServer: webRTCServer,
-		parent:       parent,
-		ln:           ln,
+		conf:             conf,
+		pathManager:      pathManager,
+		rtspServer:       rtspServer,
+		rtspsServer:      rtspsServer,
+		rtmpServer:       rtmpServer,
+		rtmpsServer:      rtmpsServer,
+		hlsServer:        hlsServer,
+		webRTCServer:     webRTCServer,
+		httpIngestServer: httpIngestServer,
+		authBanList:      authBanList,
+		events:           events,
+		metrics:          metrics,
+		parent:           parent,
+		ctx:              ctx,
+		ctxCancel:        ctxCancel,
+		ln:               ln,
 	}
 
 	router := gin.New()
 	router.SetTrustedProxies(nil)
 
+	if a.metrics != nil {
+		router.Use(a.metrics.requests.middleware("api"))
+	}
+
 	mwLog := httpLoggerMiddleware(a)
 	router.NoRoute(mwLog, httpServerHeaderMiddleware)
-	group := router.Group("/", mwLog, httpServerHeaderMiddleware)
+	group := router.Group("/", mwLog, httpServerHeaderMiddleware, apiAuthMiddleware(user, pass, conf.Tenants))
 
-	group.GET("/v1/config/get", a.onConfigGet)
-	group.POST("/v1/config/set", a.onConfigSet)
+	// endpoints that can be used by tenants, limited to the paths of their namespace.
 	group.POST("/v1/config/paths/add/*name", a.onConfigPathsAdd)
 	group.POST("/v1/config/paths/edit/*name", a.onConfigPathsEdit)
 	group.POST("/v1/config/paths/remove/*name", a.onConfigPathsDelete)
+	group.POST("/v1/config/paths/bulkedit", a.onConfigPathsBulkEdit)
+	group.GET("/v1/paths/*name", a.onPathsGet)
+	group.GET("/v1/pathgroups/list", a.onPathGroupsList)
+	group.GET("/v1/events", a.onEvents)
+	group.POST("/v1/paths/*name", a.onPathsAction)
+
+	adminGroup := group.Group("/", apiAdminOnlyMiddleware)
+
+	adminGroup.GET("/v1/config/get", a.onConfigGet)
+	adminGroup.GET("/v1/config/pathdefaults", a.onConfigPathDefaults)
+	adminGroup.GET("/v1/sourcestart/get", a.onSourceStartGet)
+	adminGroup.POST("/v1/config/set", a.onConfigSet)
+	adminGroup.PATCH("/v1/config/loglevel", a.onConfigLogLevel)
 
 	if !interfaceIsEmpty(a.hlsServer) {
-		group.GET("/v1/hlsmuxers/list", a.onHLSMuxersList)
+		adminGroup.GET("/v1/hlsmuxers/list", a.onHLSMuxersList)
 	}
 
-	group.GET("/v1/paths/list", a.onPathsList)
+	adminGroup.POST("/v1/bulkkick", a.onBulkKick)
+	adminGroup.DELETE("/v1/auth/revoke", a.onAuthRevoke)
+	adminGroup.POST("/v1/auth/revoke", a.onAuthRevoke)
 
 	if !interfaceIsEmpty(a.rtspServer) {
-		group.GET("/v1/rtspconns/list", a.onRTSPConnsList)
-		group.GET("/v1/rtspsessions/list", a.onRTSPSessionsList)
-		group.POST("/v1/rtspsessions/kick/:id", a.onRTSPSessionsKick)
+		adminGroup.GET("/v1/rtspconns/list", a.onRTSPConnsList)
+		adminGroup.GET("/v1/rtspsessions/list", a.onRTSPSessionsList)
+		adminGroup.POST("/v1/rtspsessions/kick/:id", a.onRTSPSessionsKick)
+		adminGroup.POST("/v1/rtspsessions/redirect/:id", a.onRTSPSessionsRedirect)
+		adminGroup.GET("/v1/rtspmulticastgroups/list", a.onRTSPMulticastGroupsList)
 	}
 
 	if !interfaceIsEmpty(a.rtspsServer) {
-		group.GET("/v1/rtspsconns/list", a.onRTSPSConnsList)
-		group.GET("/v1/rtspssessions/list", a.onRTSPSSessionsList)
-		group.POST("/v1/rtspssessions/kick/:id", a.onRTSPSSessionsKick)
+		adminGroup.GET("/v1/rtspsconns/list", a.onRTSPSConnsList)
+		adminGroup.GET("/v1/rtspssessions/list", a.onRTSPSSessionsList)
+		adminGroup.POST("/v1/rtspssessions/kick/:id", a.onRTSPSSessionsKick)
+		adminGroup.POST("/v1/rtspssessions/redirect/:id", a.onRTSPSSessionsRedirect)
 	}
 
 	if !interfaceIsEmpty(a.rtmpServer) {
-		group.GET("/v1/rtmpconns/list", a.onRTMPConnsList)
-		group.POST("/v1/rtmpconns/kick/:id", a.onRTMPConnsKick)
+		adminGroup.GET("/v1/rtmpconns/list", a.onRTMPConnsList)
+		adminGroup.POST("/v1/rtmpconns/kick/:id", a.onRTMPConnsKick)
 	}
 
 	if !interfaceIsEmpty(a.rtmpsServer) {
-		group.GET("/v1/rtmpsconns/list", a.onRTMPSConnsList)
-		group.POST("/v1/rtmpsconns/kick/:id", a.onRTMPSConnsKick)
+		adminGroup.GET("/v1/rtmpsconns/list", a.onRTMPSConnsList)
+		adminGroup.POST("/v1/rtmpsconns/kick/:id", a.onRTMPSConnsKick)
 	}
 
 	if !interfaceIsEmpty(a.webRTCServer) {
-		group.GET("/v1/webrtcconns/list", a.onWebRTCConnsList)
-		group.POST("/v1/webrtcconns/kick/:id", a.onWebRTCConnsKick)
+		adminGroup.GET("/v1/webrtcconns/list", a.onWebRTCConnsList)
+		adminGroup.POST("/v1/webrtcconns/kick/:id", a.onWebRTCConnsKick)
+	}
+
+	if !interfaceIsEmpty(a.authBanList) {
+		adminGroup.GET("/v1/authbans/list", a.onAuthBansList)
+		adminGroup.POST("/v1/authbans/unban/:ip", a.onAuthBansUnban)
 	}
 
 	a.httpServer = &http.Server{
@@ -215,6 +409,7 @@ func newAPI(
 
 func (a *api) close() {
 	a.Log(logger.Info, "listener is closing")
+	a.ctxCancel() // terminate event streams, that would block Shutdown()
 	a.httpServer.Shutdown(context.Background())
 	a.ln.Close() // in case Shutdown() is called before Serve()
 }
@@ -228,11 +423,55 @@ func (a *api) onConfigGet(ctx *gin.Context) {
 	c := a.conf
 	a.mutex.Unlock()
 
-	ctx.JSON(http.StatusOK, c)
+	ctx.JSON(http.StatusOK, c.WithSecretsMasked())
+}
+
+func (a *api) onConfigPathDefaults(ctx *gin.Context) {
+	a.mutex.Lock()
+	c := a.conf
+	a.mutex.Unlock()
+
+	ctx.JSON(http.StatusOK, c.PathDefaults())
+}
+
+func (a *api) onSourceStartGet(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, a.pathManager.apiSourceStartProgress())
 }
 
 func (a *api) onConfigSet(ctx *gin.Context) {
-	in, err := loadConfData(ctx)
+	in, err := loadConfData(ctx.Request.Body)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	newConf, err := confWithData(a.conf, in)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	a.conf = newConf
+
+	// since reloading the configuration can cause the shutdown of the API,
+	// call it in a goroutine
+	go a.parent.apiConfigSet(newConf)
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onConfigLogLevel(ctx *gin.Context) {
+	var in struct {
+		LogLevel  *conf.LogLevel  `json:"logLevel"`
+		LogLevels *conf.LogLevels `json:"logLevels"`
+	}
+
+	dec := json.NewDecoder(ctx.Request.Body)
+	dec.DisallowUnknownFields()
+	err := dec.Decode(&in)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
@@ -243,7 +482,12 @@ func (a *api) onConfigSet(ctx *gin.Context) {
 
 	newConf := a.conf.Clone()
 
-	fillStruct(newConf, in)
+	if in.LogLevel != nil {
+		newConf.LogLevel = *in.LogLevel
+	}
+	if in.LogLevels != nil {
+		newConf.LogLevels = *in.LogLevels
+	}
 
 	err = newConf.CheckAndFillMissing()
 	if err != nil {
@@ -253,8 +497,7 @@ func (a *api) onConfigSet(ctx *gin.Context) {
 
 	a.conf = newConf
 
-	// since reloading the configuration can cause the shutdown of the API,
-	// call it in a goroutine
+	// log levels are applied without recreating any component.
 	go a.parent.apiConfigSet(newConf)
 
 	ctx.Status(http.StatusOK)
@@ -268,28 +511,28 @@ func (a *api) onConfigPathsAdd(ctx *gin.Context) {
 	}
 	name = name[1:]
 
-	in, err := loadConfPathData(ctx)
-	if err != nil {
-		ctx.AbortWithStatus(http.StatusBadRequest)
+	if !apiCanAccessPath(ctx, name) {
+		ctx.AbortWithStatus(http.StatusForbidden)
 		return
 	}
 
-	a.mutex.Lock()
-	defer a.mutex.Unlock()
-
-	newConf := a.conf.Clone()
-
-	if _, ok := newConf.Paths[name]; ok {
+	in, err := loadConfPathData(ctx.Request.Body)
+	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
-	newConfPath := &conf.PathConf{}
-	fillStruct(newConfPath, in)
+	err = apiTenantCheckPathData(apiTenant(ctx), in)
+	if err != nil {
+		a.Log(logger.Warn, "%v", err)
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
 
-	newConf.Paths[name] = newConfPath
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
 
-	err = newConf.CheckAndFillMissing()
+	newConf, err := confWithPathAdded(a.conf, name, in)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
@@ -312,26 +555,59 @@ func (a *api) onConfigPathsEdit(ctx *gin.Context) {
 	}
 	name = name[1:]
 
-	in, err := loadConfPathData(ctx)
+	if !apiCanAccessPath(ctx, name) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	in, err := loadConfPathData(ctx.Request.Body)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
+	err = apiTenantCheckPathData(apiTenant(ctx), in)
+	if err != nil {
+		a.Log(logger.Warn, "%v", err)
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
-	newConf := a.conf.Clone()
+	newConf, err := confWithPathEdited(a.conf, name, in)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
 
-	newConfPath, ok := newConf.Paths[name]
-	if !ok {
+	a.conf = newConf
+
+	// since reloading the configuration can cause the shutdown of the API,
+	// call it in a goroutine
+	go a.parent.apiConfigSet(newConf)
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onConfigPathsDelete(ctx *gin.Context) {
+	name := ctx.Param("name")
+	if len(name) < 2 || name[0] != '/' {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
+	name = name[1:]
 
-	fillStruct(newConfPath, in)
+	if !apiCanAccessPath(ctx, name) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
 
-	err = newConf.CheckAndFillMissing()
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	newConf, err := confWithPathRemoved(a.conf, name)
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
@@ -346,27 +622,48 @@ func (a *api) onConfigPathsEdit(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
-func (a *api) onConfigPathsDelete(ctx *gin.Context) {
-	name := ctx.Param("name")
-	if len(name) < 2 || name[0] != '/' {
+func (a *api) onConfigPathsBulkEdit(ctx *gin.Context) {
+	in, err := loadConfPathsData(ctx.Request.Body)
+	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
-	name = name[1:]
 
 	a.mutex.Lock()
 	defer a.mutex.Unlock()
 
 	newConf := a.conf.Clone()
 
-	if _, ok := newConf.Paths[name]; !ok {
+	// edits are applied all together or not at all.
+	for name, pathIn := range in {
+		if !apiCanAccessPath(ctx, name) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		err = apiTenantCheckPathData(apiTenant(ctx), pathIn)
+		if err != nil {
+			a.Log(logger.Warn, "%v", err)
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		newConfPath, ok := newConf.Paths[name]
+		if !ok {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		fillStruct(newConfPath, pathIn)
+	}
+
+	err = newConf.KeepMaskedSecrets(a.conf)
+	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
 	}
 
-	delete(newConf.Paths, name)
-
-	err := newConf.CheckAndFillMissing()
+	err = newConf.CheckAndFillMissing()
 	if err != nil {
 		ctx.AbortWithStatus(http.StatusBadRequest)
 		return
@@ -381,86 +678,743 @@ func (a *api) onConfigPathsDelete(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
-func (a *api) onPathsList(ctx *gin.Context) {
-	res := a.pathManager.apiPathsList()
-	if res.err != nil {
-		ctx.AbortWithStatus(http.StatusInternalServerError)
-		return
-	}
-
-	ctx.JSON(http.StatusOK, res.data)
-}
+func (a *api) onPathsGet(ctx *gin.Context) {
+	param := ctx.Param("name")
 
-func (a *api) onRTSPConnsList(ctx *gin.Context) {
-	res := a.rtspServer.apiConnsList()
-	if res.err != nil {
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+	// "/v1/paths/list" can't be registered separately since it overlaps with this route.
+	if param == "/list" {
+		a.onPathsList(ctx)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, res.data)
-}
-
-func (a *api) onRTSPSessionsList(ctx *gin.Context) {
-	res := a.rtspServer.apiSessionsList()
-	if res.err != nil {
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+	if param == "/disabled" {
+		a.onPathsDisabled(ctx)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, res.data)
-}
+	var name string
+	var get func(name string) interface{}
 
-func (a *api) onRTSPSessionsKick(ctx *gin.Context) {
-	id := ctx.Param("id")
+	if v, ok := strings.CutSuffix(param, "/history"); ok {
+		name = v
+		get = func(name string) interface{} { return a.pathManager.apiPathsHistory(name) }
+	} else if v, ok := strings.CutSuffix(param, "/readerrejections"); ok {
+		name = v
+		get = func(name string) interface{} { return a.pathManager.apiPathsReaderRejections(name) }
+	}
 
-	res := a.rtspServer.apiSessionsKick(id)
-	if res.err != nil {
+	if get == nil || len(name) < 2 || name[0] != '/' {
+		ctx.AbortWithStatus(http.StatusNotFound)
 		return
 	}
+	name = name[1:]
 
-	ctx.Status(http.StatusOK)
-}
-
-func (a *api) onRTSPSConnsList(ctx *gin.Context) {
-	res := a.rtspsServer.apiConnsList()
-	if res.err != nil {
-		ctx.AbortWithStatus(http.StatusInternalServerError)
+	if !apiCanAccessPath(ctx, name) {
+		ctx.AbortWithStatus(http.StatusForbidden)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, res.data)
+	ctx.JSON(http.StatusOK, get(name))
 }
 
-func (a *api) onRTSPSSessionsList(ctx *gin.Context) {
-	res := a.rtspsServer.apiSessionsList()
+func (a *api) onPathsList(ctx *gin.Context) {
+	res := a.pathManager.apiPathsList()
 	if res.err != nil {
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
+	for name := range res.data.Items {
+		if !apiCanAccessPath(ctx, name) {
+			delete(res.data.Items, name)
+		}
+	}
+
 	ctx.JSON(http.StatusOK, res.data)
 }
 
-func (a *api) onRTSPSSessionsKick(ctx *gin.Context) {
-	id := ctx.Param("id")
+func (a *api) onPathsDisabled(ctx *gin.Context) {
+	data := a.pathManager.apiPathsDisabled()
 
-	res := a.rtspsServer.apiSessionsKick(id)
-	if res.err != nil {
-		return
+	for name := range data.Items {
+		if !apiCanAccessPath(ctx, name) {
+			delete(data.Items, name)
+		}
 	}
 
-	ctx.Status(http.StatusOK)
+	ctx.JSON(http.StatusOK, data)
 }
 
-func (a *api) onRTMPConnsList(ctx *gin.Context) {
-	res := a.rtmpServer.apiConnsList()
+func (a *api) onPathGroupsList(ctx *gin.Context) {
+	a.mutex.Lock()
+	suffixes := a.conf.PathGroupSuffixes
+	a.mutex.Unlock()
+
+	res := a.pathManager.apiPathsList()
 	if res.err != nil {
 		ctx.AbortWithStatus(http.StatusInternalServerError)
 		return
 	}
 
-	ctx.JSON(http.StatusOK, res.data)
+	for name := range res.data.Items {
+		if !apiCanAccessPath(ctx, name) {
+			delete(res.data.Items, name)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, pathGroupsFromPaths(suffixes, res.data))
+}
+
+func (a *api) onEvents(ctx *gin.Context) {
+	tconf := apiTenant(ctx)
+
+	var paths map[string]struct{}
+	if names := ctx.QueryArray("path"); len(names) != 0 {
+		paths = make(map[string]struct{}, len(names))
+		for _, name := range names {
+			paths[name] = struct{}{}
+		}
+	}
+
+	if strings.EqualFold(ctx.GetHeader("Upgrade"), "websocket") {
+		a.onEventsWebSocket(ctx, tconf, paths)
+	} else {
+		a.onEventsSSE(ctx, tconf, paths)
+	}
+}
+
+// onEventsWebSocket sends events as WebSocket text messages.
+func (a *api) onEventsWebSocket(ctx *gin.Context, tconf *conf.TenantConf, paths map[string]struct{}) {
+	wc, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		return
+	}
+	defer wc.Close()
+
+	ch := a.events.subscribe()
+	defer a.events.unsubscribe(ch)
+
+	// read messages in order to process pongs and detect disconnections.
+	readErr := make(chan struct{})
+	go func() {
+		defer close(readErr)
+		for {
+			_, err := wc.ReadMessage()
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !apiEventVisible(ev, tconf, paths) {
+				continue
+			}
+
+			err := wc.WriteJSON(ev)
+			if err != nil {
+				return
+			}
+
+		case <-readErr:
+			return
+
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// onEventsSSE sends events with the Server-Sent Events protocol.
+func (a *api) onEventsSSE(ctx *gin.Context, tconf *conf.TenantConf, paths map[string]struct{}) {
+	ch := a.events.subscribe()
+	defer a.events.unsubscribe(ch)
+
+	ctx.Writer.Header().Set("Content-Type", "text/event-stream")
+	ctx.Writer.Header().Set("Cache-Control", "no-cache")
+	ctx.Writer.WriteHeader(http.StatusOK)
+	ctx.Writer.Flush()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return
+			}
+
+			if !apiEventVisible(ev, tconf, paths) {
+				continue
+			}
+
+			byts, _ := json.Marshal(ev)
+
+			_, err := fmt.Fprintf(ctx.Writer, "data: %s\n\n", byts)
+			if err != nil {
+				return
+			}
+			ctx.Writer.Flush()
+
+		case <-ctx.Request.Context().Done():
+			return
+
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+func (a *api) onPathsQuery(ctx *gin.Context) {
+	var in struct {
+		Names  []string `json:"names"`
+		Regexp string   `json:"regexp"`
+	}
+	err := json.NewDecoder(ctx.Request.Body).Decode(&in)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	var re *regexp.Regexp
+	if in.Regexp != "" {
+		re, err = regexp.Compile(in.Regexp)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := a.pathManager.apiPathsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	names := make(map[string]struct{}, len(in.Names))
+	for _, name := range in.Names {
+		names[name] = struct{}{}
+	}
+
+	out := &pathAPIPathsListData{
+		Items: make(map[string]pathAPIPathsListItem),
+	}
+
+	for name, item := range res.data.Items {
+		if !apiCanAccessPath(ctx, name) {
+			continue
+		}
+
+		_, ok := names[name]
+		if ok || (re != nil && re.MatchString(name)) {
+			out.Items[name] = item
+		}
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (a *api) onPathsAction(ctx *gin.Context) {
+	param := ctx.Param("name")
+
+	// "/v1/paths/query" can't be registered separately since it overlaps with this route.
+	if param == "/query" {
+		a.onPathsQuery(ctx)
+		return
+	}
+
+	for _, action := range []string{
+		"/debug/capture", "/ptz/move", "/ptz/zoom", "/ptz/preset", "/tee", "/tee/remove",
+		"/disable", "/enable",
+	} {
+		name, ok := strings.CutSuffix(param, action)
+		if !ok {
+			continue
+		}
+
+		if len(name) < 2 || name[0] != '/' {
+			break
+		}
+		name = name[1:]
+
+		if !apiCanAccessPath(ctx, name) {
+			ctx.AbortWithStatus(http.StatusForbidden)
+			return
+		}
+
+		switch action {
+		case "/debug/capture":
+			a.onPathsCapture(ctx, name)
+
+		case "/tee", "/tee/remove":
+			a.onPathsTee(ctx, name, action == "/tee/remove")
+
+		case "/disable", "/enable":
+			a.onPathsDisable(ctx, name, action == "/disable")
+
+		default:
+			a.onPathsPTZ(ctx, name, action[len("/ptz/"):])
+		}
+		return
+	}
+
+	ctx.AbortWithStatus(http.StatusNotFound)
+}
+
+func (a *api) onPathsCapture(ctx *gin.Context, name string) {
+	// captures write files on the host, therefore they are reserved to administrators.
+	if apiTenant(ctx) != nil {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	a.mutex.Lock()
+	c := a.conf
+	a.mutex.Unlock()
+
+	if c.APICaptureDirectory == "" {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	duration := 30 * time.Second
+	if tmp := ctx.Query("duration"); tmp != "" {
+		var err error
+		duration, err = time.ParseDuration(tmp)
+		if err != nil || duration <= 0 || duration > apiCaptureMaxDuration {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := a.pathManager.apiPathsCapture(pathAPIPathsCaptureReq{
+		name:      name,
+		duration:  duration,
+		directory: c.APICaptureDirectory,
+		maxSize:   uint64(c.APICaptureMaxSize),
+		maxFiles:  c.APICaptureMaxFiles,
+	})
+	if res.err != nil {
+		a.Log(logger.Info, "unable to start a capture of path '%s': %v", name, res.err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		File string `json:"file"`
+	}{res.file})
+}
+
+func (a *api) onPathsDisable(ctx *gin.Context, name string, disable bool) {
+	var in struct {
+		Message string `json:"message"`
+		Drop    bool   `json:"drop"`
+	}
+
+	if disable {
+		// the body is optional.
+		err := json.NewDecoder(ctx.Request.Body).Decode(&in)
+		if err != nil && err != io.EOF {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	err := a.pathManager.apiPathsDisable(name, disable, in.Message, in.Drop)
+	if err != nil {
+		a.Log(logger.Info, "unable to change the state of path '%s': %v", name, err)
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onPathsTee(ctx *gin.Context, name string, remove bool) {
+	target := ctx.Query("target")
+	if target == "" {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	if !apiCanAccessPath(ctx, target) {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	if remove {
+		err := a.pathManager.apiPathsTeeRemove(name, target)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		ctx.Status(http.StatusOK)
+		return
+	}
+
+	var duration time.Duration
+	if tmp := ctx.Query("duration"); tmp != "" {
+		var err error
+		duration, err = time.ParseDuration(tmp)
+		if err != nil || duration <= 0 {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	res := a.pathManager.apiPathsTee(name, target, duration)
+	if res.err != nil {
+		a.Log(logger.Info, "unable to copy path '%s' to path '%s': %v", name, target, res.err)
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, struct {
+		ID string `json:"id"`
+	}{res.id})
+}
+
+func (a *api) onPathsPTZ(ctx *gin.Context, name string, action string) {
+	queryFloat := func(key string) (float64, bool) {
+		tmp := ctx.Query(key)
+		if tmp == "" {
+			return 0, true
+		}
+
+		v, err := strconv.ParseFloat(tmp, 64)
+		if err != nil || v < -1 || v > 1 {
+			return 0, false
+		}
+
+		return v, true
+	}
+
+	var timeout time.Duration
+	if tmp := ctx.Query("duration"); tmp != "" {
+		var err error
+		timeout, err = time.ParseDuration(tmp)
+		if err != nil || timeout <= 0 {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	var do func(c *onvif.Client) error
+
+	switch action {
+	case "move":
+		pan, ok1 := queryFloat("pan")
+		tilt, ok2 := queryFloat("tilt")
+		if !ok1 || !ok2 {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		do = func(c *onvif.Client) error {
+			if pan == 0 && tilt == 0 {
+				return c.Stop(true, false)
+			}
+			return c.Move(pan, tilt, timeout)
+		}
+
+	case "zoom":
+		speed, ok := queryFloat("speed")
+		if !ok {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		do = func(c *onvif.Client) error {
+			if speed == 0 {
+				return c.Stop(false, true)
+			}
+			return c.Zoom(speed, timeout)
+		}
+
+	default: // preset
+		token := ctx.Query("token")
+		if token == "" {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+
+		do = func(c *onvif.Client) error {
+			return c.GotoPreset(token)
+		}
+	}
+
+	res := a.pathManager.apiPathsPTZ(name)
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	err := do(res.client)
+	if err != nil {
+		a.Log(logger.Warn, "PTZ command to path '%s' failed: %s", name, err)
+		ctx.AbortWithStatus(http.StatusBadGateway)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// apiKick kicks out the RTSP session, RTSPS session, RTMP connection, RTMPS connection
+// or WebRTC connection with the given ID.
+func apiKick(
+	id string,
+	rtspServer apiRTSPServer,
+	rtspsServer apiRTSPServer,
+	rtmpServer apiRTMPServer,
+	rtmpsServer apiRTMPServer,
+	webRTCServer apiWebRTCServer,
+) bool {
+	if !interfaceIsEmpty(rtspServer) && rtspServer.apiSessionsKick(id).err == nil {
+		return true
+	}
+	if !interfaceIsEmpty(rtspsServer) && rtspsServer.apiSessionsKick(id).err == nil {
+		return true
+	}
+	if !interfaceIsEmpty(rtmpServer) && rtmpServer.apiConnsKick(id).err == nil {
+		return true
+	}
+	if !interfaceIsEmpty(rtmpsServer) && rtmpsServer.apiConnsKick(id).err == nil {
+		return true
+	}
+	if !interfaceIsEmpty(webRTCServer) && webRTCServer.apiConnsKick(id).err == nil {
+		return true
+	}
+	return false
+}
+
+// onBulkKick kicks out RTSP sessions, RTSPS sessions, RTMP connections, RTMPS connections
+// and WebRTC connections with the given IDs.
+func (a *api) onBulkKick(ctx *gin.Context) {
+	var in struct {
+		IDs []string `json:"ids"`
+	}
+	err := json.NewDecoder(ctx.Request.Body).Decode(&in)
+	if err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	out := struct {
+		Kicked   []string `json:"kicked"`
+		NotFound []string `json:"notFound"`
+	}{
+		Kicked:   []string{},
+		NotFound: []string{},
+	}
+
+	for _, id := range in.IDs {
+		if apiKick(id, a.rtspServer, a.rtspsServer, a.rtmpServer, a.rtmpsServer, a.webRTCServer) {
+			out.Kicked = append(out.Kicked, id)
+		} else {
+			out.NotFound = append(out.NotFound, id)
+		}
+	}
+
+	ctx.JSON(http.StatusOK, out)
+}
+
+// onAuthRevoke closes RTSP and RTMP sessions, WebRTC sessions, HTTP ingest sessions
+// and HLS MP4 streams that have been authenticated with the given user or token,
+// and rejects further HLS requests that use the token. Parameters are read from the query (DELETE)
+// or from a JSON body (POST), in order to allow external systems to push revocations
+// through webhooks.
+func (a *api) onAuthRevoke(ctx *gin.Context) {
+	var in struct {
+		User  string `json:"user"`
+		Token string `json:"token"`
+	}
+
+	if ctx.Request.Method == http.MethodPost {
+		err := json.NewDecoder(ctx.Request.Body).Decode(&in)
+		if err != nil {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	} else {
+		in.User = ctx.Query("user")
+		in.Token = ctx.Query("token")
+	}
+
+	if in.User == "" && in.Token == "" {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	out := struct {
+		Closed          int  `json:"closed"`
+		HLSTokenRevoked bool `json:"hlsTokenRevoked"`
+	}{}
+
+	for _, s := range []apiRTSPServer{a.rtspServer, a.rtspsServer} {
+		if !interfaceIsEmpty(s) {
+			out.Closed += s.apiSessionsRevoke(in.User, in.Token)
+		}
+	}
+
+	for _, s := range []apiRTMPServer{a.rtmpServer, a.rtmpsServer} {
+		if !interfaceIsEmpty(s) {
+			out.Closed += s.apiConnsRevoke(in.User, in.Token)
+		}
+	}
+
+	if !interfaceIsEmpty(a.webRTCServer) {
+		out.Closed += a.webRTCServer.apiConnsRevoke(in.User, in.Token)
+	}
+
+	if !interfaceIsEmpty(a.httpIngestServer) {
+		out.Closed += a.httpIngestServer.apiConnsRevoke(in.User, in.Token)
+	}
+
+	if !interfaceIsEmpty(a.hlsServer) {
+		out.Closed += a.hlsServer.apiSessionsRevoke(in.User, in.Token)
+
+		if in.Token != "" {
+			out.HLSTokenRevoked = a.hlsServer.apiTokenRevoke(in.Token)
+		}
+	}
+
+	a.Log(logger.Info, "authorization revoked, %d sessions closed", out.Closed)
+
+	ctx.JSON(http.StatusOK, out)
+}
+
+func (a *api) onRTSPConnsList(ctx *gin.Context) {
+	res := a.rtspServer.apiConnsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onRTSPSessionsList(ctx *gin.Context) {
+	res := a.rtspServer.apiSessionsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onRTSPMulticastGroupsList(ctx *gin.Context) {
+	res := a.rtspServer.apiMulticastGroupsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onRTSPSessionsKick(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	res := a.rtspServer.apiSessionsKick(id)
+	if res.err != nil {
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+// apiRedirectTarget returns the server a RTSP session has to be redirected to.
+func apiRedirectTarget(ctx *gin.Context) (*url.URL, bool) {
+	target := ctx.Query("target")
+	if !strings.HasPrefix(target, "rtsp://") && !strings.HasPrefix(target, "rtsps://") {
+		return nil, false
+	}
+
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, false
+	}
+
+	return u, true
+}
+
+func (a *api) onRTSPSessionsRedirect(ctx *gin.Context) {
+	target, ok := apiRedirectTarget(ctx)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	res := a.rtspServer.apiSessionsRedirect(ctx.Param("id"), target)
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onRTSPSConnsList(ctx *gin.Context) {
+	res := a.rtspsServer.apiConnsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onRTSPSSessionsList(ctx *gin.Context) {
+	res := a.rtspsServer.apiSessionsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onRTSPSSessionsKick(ctx *gin.Context) {
+	id := ctx.Param("id")
+
+	res := a.rtspsServer.apiSessionsKick(id)
+	if res.err != nil {
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onRTSPSSessionsRedirect(ctx *gin.Context) {
+	target, ok := apiRedirectTarget(ctx)
+	if !ok {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	res := a.rtspsServer.apiSessionsRedirect(ctx.Param("id"), target)
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (a *api) onRTMPConnsList(ctx *gin.Context) {
+	res := a.rtmpServer.apiConnsList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
 }
 
 func (a *api) onRTMPConnsKick(ctx *gin.Context) {
@@ -526,6 +1480,28 @@ func (a *api) onWebRTCConnsKick(ctx *gin.Context) {
 	ctx.Status(http.StatusOK)
 }
 
+func (a *api) onAuthBansList(ctx *gin.Context) {
+	res := a.authBanList.apiList()
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusInternalServerError)
+		return
+	}
+
+	ctx.JSON(http.StatusOK, res.data)
+}
+
+func (a *api) onAuthBansUnban(ctx *gin.Context) {
+	ip := ctx.Param("ip")
+
+	res := a.authBanList.apiUnban(ip)
+	if res.err != nil {
+		ctx.AbortWithStatus(http.StatusNotFound)
+		return
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
 // confReload is called by core.
 func (a *api) confReload(conf *conf.Conf) {
 	a.mutex.Lock()
@@ -0,0 +1,106 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// number of events that can be queued for each subscriber.
+const apiEventsQueueSize = 64
+
+type apiEventType string
+
+const (
+	apiEventPathReady        apiEventType = "pathReady"
+	apiEventPathNotReady     apiEventType = "pathNotReady"
+	apiEventClientConnect    apiEventType = "clientConnect"
+	apiEventClientDisconnect apiEventType = "clientDisconnect"
+	apiEventConfReload       apiEventType = "confReload"
+	apiEventSCTE35           apiEventType = "scte35"
+)
+
+type apiEvent struct {
+	Type apiEventType `json:"type"`
+	Time time.Time    `json:"time"`
+	Path string       `json:"path,omitempty"`
+
+	// source or reader that connected to or disconnected from the path.
+	Client interface{} `json:"client,omitempty"`
+
+	// "publisher" or "reader".
+	Role string `json:"role,omitempty"`
+
+	// cue received from the source.
+	SCTE35 *scte35Cue `json:"scte35,omitempty"`
+
+	// counter of the bytes received by the path, used by statsStore.
+	bytesReceived *uint64
+}
+
+// apiEventVisible checks whether an event can be sent to a subscriber.
+// Events without a path are sent to all subscribers.
+func apiEventVisible(ev apiEvent, tconf *conf.TenantConf, paths map[string]struct{}) bool {
+	if ev.Path == "" {
+		return true
+	}
+
+	if tconf != nil && !tconf.Owns(ev.Path) {
+		return false
+	}
+
+	if paths != nil {
+		if _, ok := paths[ev.Path]; !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// apiEvents dispatches events to the subscribers of the APIs.
+// Subscribers that don't keep up with events are removed and their channel is closed.
+type apiEvents struct {
+	mutex       sync.Mutex
+	subscribers map[chan apiEvent]struct{}
+}
+
+func newAPIEvents() *apiEvents {
+	return &apiEvents{
+		subscribers: make(map[chan apiEvent]struct{}),
+	}
+}
+
+func (e *apiEvents) subscribe() chan apiEvent {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	ch := make(chan apiEvent, apiEventsQueueSize)
+	e.subscribers[ch] = struct{}{}
+	return ch
+}
+
+func (e *apiEvents) unsubscribe(ch chan apiEvent) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	if _, ok := e.subscribers[ch]; ok {
+		delete(e.subscribers, ch)
+		close(ch)
+	}
+}
+
+func (e *apiEvents) publish(ev apiEvent) {
+	e.mutex.Lock()
+	defer e.mutex.Unlock()
+
+	for ch := range e.subscribers {
+		select {
+		case ch <- ev:
+		default:
+			delete(e.subscribers, ch)
+			close(ch)
+		}
+	}
+}
@@ -0,0 +1,302 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/auth"
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+const apiTenantKey = "tenant"
+
+// apiAuthMiddleware returns a middleware that accepts the API credentials,
+// that give access to all endpoints, and the API credentials of tenants,
+// that give access to the paths of their namespace only.
+// If API credentials are empty, all requests are accepted.
+func apiAuthMiddleware(
+	user conf.Credential,
+	pass conf.Credential,
+	tenants map[string]*conf.TenantConf,
+) func(*gin.Context) {
+	return func(ctx *gin.Context) {
+		if user == "" {
+			ctx.Next()
+			return
+		}
+
+		reqUser, reqPass, ok := ctx.Request.BasicAuth()
+		if ok {
+			if tconf, ok := apiAuthenticate(user, pass, tenants, reqUser, reqPass); ok {
+				if tconf != nil {
+					ctx.Set(apiTenantKey, tconf)
+				}
+				ctx.Next()
+				return
+			}
+		}
+
+		ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
+		ctx.AbortWithStatus(http.StatusUnauthorized)
+	}
+}
+
+// apiAuthenticate checks credentials against the API credentials and the API credentials of tenants.
+// It returns the tenant that owns the credentials, or nil if they are the API credentials.
+func apiAuthenticate(
+	user conf.Credential,
+	pass conf.Credential,
+	tenants map[string]*conf.TenantConf,
+	reqUser string,
+	reqPass string,
+) (*conf.TenantConf, bool) {
+	if auth.CredentialMatches(user, reqUser) && auth.CredentialMatches(pass, reqPass) {
+		return nil, true
+	}
+
+	for _, tconf := range tenants {
+		if tconf.APIUser != "" &&
+			auth.CredentialMatches(tconf.APIUser, reqUser) &&
+			auth.CredentialMatches(tconf.APIPass, reqPass) {
+			return tconf, true
+		}
+	}
+
+	return nil, false
+}
+
+// apiAdminOnlyMiddleware rejects requests performed with the API credentials of a tenant.
+func apiAdminOnlyMiddleware(ctx *gin.Context) {
+	if apiTenant(ctx) != nil {
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+
+	ctx.Next()
+}
+
+// apiTenant returns the tenant that performed the request, or nil.
+func apiTenant(ctx *gin.Context) *conf.TenantConf {
+	tconf, ok := ctx.Get(apiTenantKey)
+	if !ok {
+		return nil
+	}
+	return tconf.(*conf.TenantConf)
+}
+
+// apiCanAccessPath checks whether the author of the request can access the given path.
+func apiCanAccessPath(ctx *gin.Context, name string) bool {
+	tconf := apiTenant(ctx)
+	return tconf == nil || tconf.Owns(name)
+}
+
+// parameters that can be set by tenants.
+// Parameters that run commands, send requests to arbitrary hosts,
+// or access files and devices of the host are reserved to administrators.
+var apiTenantAllowedParams = map[string]struct{}{
+	"aliases":                    {},
+	"source":                     {},
+	"sourceProtocol":             {},
+	"sourceAnyPortEnable":        {},
+	"sourceParameters":           {},
+	"sourceFingerprint":          {},
+	"sourceOnDemand":             {},
+	"sourceOnDemandStartTimeout": {},
+	"sourceOnDemandCloseAfter":   {},
+	"sourceRetryMin":             {},
+	"sourceRetryMax":             {},
+	"sourceRetryJitter":          {},
+	"sourceRetryMaxCount":        {},
+	"sourceRedirect":             {},
+	"disablePublisherOverride":   {},
+	"preserveSSRC":               {},
+	"keepLastFrame":              {},
+	"keepLastFrameLabel":         {},
+	"readerGracePeriod":          {},
+	"normalizeTimestamps":        {},
+	"seiTimestamp":               {},
+	"seiTimestampLabel":          {},
+	"readerMaxBitrate":           {},
+	"staticVideoTimeout":         {},
+	"silentAudioTimeout":         {},
+	"maxBitrate":                 {},
+	"maxGOPDuration":             {},
+	"minHealthScore":             {},
+	"limitAction":                {},
+	"publishUser":                {},
+	"publishPass":                {},
+	"publishIPs":                 {},
+	"streamKey":                  {},
+	"readUser":                   {},
+	"readPass":                   {},
+	"readIPs":                    {},
+	"permissions":                {},
+}
+
+// sources that can be set by tenants.
+// Sources that read local files, devices or run commands are reserved to administrators.
+var apiTenantAllowedSources = []string{
+	"publisher",
+	"redirect",
+	"rtsp://",
+	"rtsps://",
+	"rtmp://",
+	"rtmps://",
+	"http://",
+	"https://",
+}
+
+const apiTenantSourceLookupTimeout = 2 * time.Second
+
+// apiTenantIsInternalIP checks whether an IP belongs to the host or to its private networks.
+func apiTenantIsInternalIP(ip net.IP) bool {
+	return ip.IsLoopback() ||
+		ip.IsPrivate() ||
+		ip.IsLinkLocalUnicast() ||
+		ip.IsLinkLocalMulticast() ||
+		ip.IsMulticast() ||
+		ip.IsUnspecified()
+}
+
+// apiTenantHostIsAllowed checks whether a host is in the list of allowed hosts of a tenant.
+// Entries that start with "*." match all subdomains.
+func apiTenantHostIsAllowed(tconf *conf.TenantConf, host string) bool {
+	host = strings.ToLower(host)
+
+	for _, entry := range tconf.AllowedSourceHosts {
+		entry = strings.ToLower(entry)
+
+		if strings.HasPrefix(entry, "*.") {
+			if strings.HasSuffix(host, entry[1:]) {
+				return true
+			}
+		} else if host == entry {
+			return true
+		}
+	}
+
+	return false
+}
+
+// apiTenantCheckSourceURL checks that a URL provided by a tenant doesn't point
+// to the host or to its private networks, in order to prevent tenants from
+// using the server to reach internal services.
+// When the tenant has a list of allowed hosts, only those hosts are accepted.
+func apiTenantCheckSourceURL(tconf *conf.TenantConf, raw string) error {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return fmt.Errorf("invalid URL '%s'", raw)
+	}
+
+	host := u.Hostname()
+	if host == "" {
+		return fmt.Errorf("URL '%s' has no host", raw)
+	}
+
+	if len(tconf.AllowedSourceHosts) != 0 {
+		if !apiTenantHostIsAllowed(tconf, host) {
+			return fmt.Errorf("host '%s' can't be used by tenants", host)
+		}
+		return nil
+	}
+
+	if strings.EqualFold(host, "localhost") || strings.HasSuffix(strings.ToLower(host), ".localhost") {
+		return fmt.Errorf("host '%s' can't be used by tenants", host)
+	}
+
+	if ip := net.ParseIP(host); ip != nil {
+		if apiTenantIsInternalIP(ip) {
+			return fmt.Errorf("host '%s' can't be used by tenants", host)
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), apiTenantSourceLookupTimeout)
+	defer cancel()
+
+	// hosts that can't be resolved are accepted, since they can't be reached either.
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil
+	}
+
+	for _, addr := range addrs {
+		if apiTenantIsInternalIP(addr.IP) {
+			return fmt.Errorf("host '%s' can't be used by tenants", host)
+		}
+	}
+
+	return nil
+}
+
+// apiTenantCheckPathData checks that path parameters provided by a tenant
+// are in the list of parameters allowed to tenants, and that sources and aliases
+// don't point outside of the tenant namespace.
+func apiTenantCheckPathData(tconf *conf.TenantConf, in interface{}) error {
+	if tconf == nil {
+		return nil
+	}
+
+	rv := reflect.ValueOf(in).Elem()
+	rt := rv.Type()
+
+	for i := 0; i < rt.NumField(); i++ {
+		if rv.Field(i).IsNil() {
+			continue
+		}
+
+		jsonName := rt.Field(i).Tag.Get("json")
+
+		if _, ok := apiTenantAllowedParams[jsonName]; !ok {
+			return fmt.Errorf("parameter '%s' can't be set by tenants", jsonName)
+		}
+
+		switch jsonName {
+		case "source":
+			source := rv.Field(i).Elem().String()
+			allowed := false
+			for _, prefix := range apiTenantAllowedSources {
+				if strings.HasPrefix(source, prefix) {
+					allowed = true
+					break
+				}
+			}
+			if !allowed {
+				return fmt.Errorf("source '%s' can't be set by tenants", source)
+			}
+
+			if source != "publisher" && source != "redirect" {
+				err := apiTenantCheckSourceURL(tconf, source)
+				if err != nil {
+					return err
+				}
+			}
+
+		case "sourceRedirect":
+			redirect := rv.Field(i).Elem().String()
+			if redirect != "" {
+				err := apiTenantCheckSourceURL(tconf, redirect)
+				if err != nil {
+					return err
+				}
+			}
+
+		case "aliases":
+			for _, alias := range rv.Field(i).Elem().Interface().([]string) {
+				if !tconf.Owns(alias) {
+					return fmt.Errorf("alias '%s' is outside of the tenant namespace", alias)
+				}
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,230 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	// period of the removal of expired failures and bans.
+	authBanListSweepPeriod = 1 * time.Minute
+)
+
+type authBanListAPIListItem struct {
+	BannedUntil time.Time `json:"bannedUntil"`
+}
+
+type authBanListAPIListData struct {
+	Items map[string]authBanListAPIListItem `json:"items"`
+}
+
+type authBanListAPIListRes struct {
+	data *authBanListAPIListData
+	err  error
+}
+
+type authBanListAPIUnbanRes struct {
+	err error
+}
+
+type authBanListEntry struct {
+	failures    []time.Time
+	bannedUntil time.Time
+}
+
+type authBanListParent interface {
+	logger.Writer
+}
+
+// authBanList keeps track of authentication failures of all protocols
+// and bans IPs that fail authentication too many times.
+type authBanList struct {
+	attempts int
+	duration time.Duration
+	parent   authBanListParent
+
+	ctx       context.Context
+	ctxCancel func()
+	wg        sync.WaitGroup
+
+	mutex   sync.Mutex
+	entries map[string]*authBanListEntry
+}
+
+func newAuthBanList(
+	attempts int,
+	duration conf.StringDuration,
+	parent authBanListParent,
+) *authBanList {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	l := &authBanList{
+		attempts:  attempts,
+		duration:  time.Duration(duration),
+		parent:    parent,
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		entries:   make(map[string]*authBanListEntry),
+	}
+
+	l.wg.Add(1)
+	go l.run()
+
+	return l
+}
+
+func (l *authBanList) close() {
+	l.ctxCancel()
+	l.wg.Wait()
+}
+
+func (l *authBanList) run() {
+	defer l.wg.Done()
+
+	t := time.NewTicker(authBanListSweepPeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			l.mutex.Lock()
+			l.removeExpired(time.Now())
+			l.mutex.Unlock()
+
+		case <-l.ctx.Done():
+			return
+		}
+	}
+}
+
+// Log is the main logging function.
+func (l *authBanList) Log(level logger.Level, format string, args ...interface{}) {
+	l.parent.Log(level, "[auth ban list] "+format, args...)
+}
+
+// removeExpiredEntry removes failures and bans of an entry that are older than the ban duration,
+// and removes the entry when it is empty.
+func (l *authBanList) removeExpiredEntry(key string, e *authBanListEntry, now time.Time) {
+	n := 0
+	for _, t := range e.failures {
+		if now.Sub(t) < l.duration {
+			e.failures[n] = t
+			n++
+		}
+	}
+	e.failures = e.failures[:n]
+
+	if len(e.failures) == 0 && !now.Before(e.bannedUntil) {
+		delete(l.entries, key)
+	}
+}
+
+// removeExpired removes failures and bans that are older than the ban duration.
+func (l *authBanList) removeExpired(now time.Time) {
+	for key, e := range l.entries {
+		l.removeExpiredEntry(key, e, now)
+	}
+}
+
+// check returns an error if the IP is banned.
+// It is called on every request, therefore it inspects the entry of the IP only;
+// other entries are removed periodically.
+func (l *authBanList) check(ip net.IP) error {
+	key := ip.String()
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e, ok := l.entries[key]
+	if !ok {
+		return nil
+	}
+
+	now := time.Now()
+	if now.Before(e.bannedUntil) {
+		return fmt.Errorf("IP '%s' is banned because of too many authentication failures", ip)
+	}
+
+	l.removeExpiredEntry(key, e, now)
+
+	return nil
+}
+
+// onFailure is called when an authentication fails.
+func (l *authBanList) onFailure(ip net.IP) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	key := ip.String()
+
+	e, ok := l.entries[key]
+	if ok {
+		l.removeExpiredEntry(key, e, now)
+		e, ok = l.entries[key]
+	}
+
+	if !ok {
+		e = &authBanListEntry{}
+		l.entries[key] = e
+	}
+
+	if now.Before(e.bannedUntil) {
+		return
+	}
+
+	e.failures = append(e.failures, now)
+
+	if len(e.failures) >= l.attempts {
+		e.failures = nil
+		e.bannedUntil = now.Add(l.duration)
+		l.Log(logger.Warn, "IP '%s' banned for %v after %d authentication failures",
+			key, l.duration, l.attempts)
+	}
+}
+
+// apiList is called by api.
+func (l *authBanList) apiList() authBanListAPIListRes {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	now := time.Now()
+	l.removeExpired(now)
+
+	data := &authBanListAPIListData{
+		Items: make(map[string]authBanListAPIListItem),
+	}
+
+	for ip, e := range l.entries {
+		if now.Before(e.bannedUntil) {
+			data.Items[ip] = authBanListAPIListItem{
+				BannedUntil: e.bannedUntil,
+			}
+		}
+	}
+
+	return authBanListAPIListRes{data: data}
+}
+
+// apiUnban is called by api.
+func (l *authBanList) apiUnban(ip string) authBanListAPIUnbanRes {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	e, ok := l.entries[ip]
+	if !ok || !time.Now().Before(e.bannedUntil) {
+		return authBanListAPIUnbanRes{err: fmt.Errorf("not found")}
+	}
+
+	delete(l.entries, ip)
+
+	l.Log(logger.Info, "IP '%s' unbanned", ip)
+
+	return authBanListAPIUnbanRes{}
+}
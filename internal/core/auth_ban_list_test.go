@@ -0,0 +1,41 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestAuthBanListExpiry(t *testing.T) {
+	l := newAuthBanList(2, conf.StringDuration(100*time.Millisecond), &nilLogger{})
+	defer l.close()
+
+	ip1 := net.ParseIP("192.168.2.1")
+	ip2 := net.ParseIP("192.168.2.2")
+
+	l.onFailure(ip1)
+	l.onFailure(ip1)
+	l.onFailure(ip2)
+
+	require.Error(t, l.check(ip1))
+	require.NoError(t, l.check(ip2))
+
+	time.Sleep(150 * time.Millisecond)
+
+	// the entry of a checked IP is removed when it expires.
+	require.NoError(t, l.check(ip1))
+
+	l.mutex.Lock()
+	_, ok := l.entries[ip1.String()]
+	require.Equal(t, false, ok)
+	require.Equal(t, 1, len(l.entries))
+
+	// entries of other IPs are removed by the periodic sweep.
+	l.removeExpired(time.Now())
+	require.Equal(t, 0, len(l.entries))
+	l.mutex.Unlock()
+}
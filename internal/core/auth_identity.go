@@ -0,0 +1,81 @@
+package core
+
+import (
+	"net/url"
+	"sync"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// authIdentity is the identity provided by an authenticated client.
+// It is used to find the sessions that must be closed when a user or a token is revoked.
+type authIdentity struct {
+	user  string
+	token string
+}
+
+// newAuthIdentity allocates an authIdentity. The token is read from the
+// same query parameter that is used by HLS tokens.
+func newAuthIdentity(user string, rawQuery string) authIdentity {
+	query, _ := url.ParseQuery(rawQuery)
+
+	return authIdentity{
+		user:  user,
+		token: hlsTokenNormalize(query.Get(hlsTokenParam)),
+	}
+}
+
+// matches checks whether the identity is affected by the revocation of a user or a token.
+func (i authIdentity) matches(user string, token string) bool {
+	return (user != "" && i.user == user) || (token != "" && i.token == hlsTokenNormalize(token))
+}
+
+type authSession interface {
+	logger.Writer
+	close()
+}
+
+// authSessions contains the sessions of HTTP-based servers, that are served by
+// request handlers and therefore are not tracked by the servers themselves.
+// It allows to close sessions when their user or token is revoked.
+type authSessions struct {
+	mutex    sync.Mutex
+	sessions map[authSession]authIdentity
+}
+
+func newAuthSessions() *authSessions {
+	return &authSessions{
+		sessions: make(map[authSession]authIdentity),
+	}
+}
+
+func (as *authSessions) add(s authSession, identity authIdentity) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	as.sessions[s] = identity
+}
+
+func (as *authSessions) remove(s authSession) {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+	delete(as.sessions, s)
+}
+
+// revoke closes sessions that have been authenticated with the given user or token.
+func (as *authSessions) revoke(user string, token string) int {
+	as.mutex.Lock()
+	defer as.mutex.Unlock()
+
+	n := 0
+
+	for s, identity := range as.sessions {
+		if identity.matches(user, token) {
+			s.Log(logger.Info, "authorization has been revoked")
+			s.close()
+			delete(as.sessions, s)
+			n++
+		}
+	}
+
+	return n
+}
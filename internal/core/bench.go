@@ -0,0 +1,576 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gohlslib"
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	rtspurl "github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/rtmp"
+	"github.com/aler9/mediamtx/internal/rtmp/message"
+	"github.com/aler9/mediamtx/internal/testsrc"
+)
+
+const (
+	benchTimeout = 10 * time.Second
+	benchFPS     = 25
+)
+
+// benchClient contains the statistics of a reader or publisher of the bench command.
+type benchClient struct {
+	start     time.Time
+	firstData int64  // unix nanoseconds, accessed atomically
+	bytes     uint64 // accessed atomically
+	err       error
+}
+
+func (c *benchClient) onData(n int) {
+	atomic.CompareAndSwapInt64(&c.firstData, 0, time.Now().UnixNano())
+	atomic.AddUint64(&c.bytes, uint64(n))
+}
+
+// startupLatency returns the time elapsed between the start of the client and
+// the first data it received or sent. It returns false if no data was received or sent.
+func (c *benchClient) startupLatency() (time.Duration, bool) {
+	v := atomic.LoadInt64(&c.firstData)
+	if v == 0 {
+		return 0, false
+	}
+	return time.Unix(0, v).Sub(c.start), true
+}
+
+// benchURL fills the {n} template of a URL.
+func benchURL(ur string, n int) string {
+	return strings.ReplaceAll(ur, "{n}", strconv.FormatInt(int64(n), 10))
+}
+
+// benchRTMPDial connects to a RTMP or RTMPS server, adding the default port if missing.
+func benchRTMPDial(ctx context.Context, u *url.URL) (net.Conn, error) {
+	_, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		u.Host = net.JoinHostPort(u.Host, "1935")
+	}
+
+	ctx2, cancel := context.WithTimeout(ctx, benchTimeout)
+	defer cancel()
+
+	if u.Scheme == "rtmp" {
+		return (&net.Dialer{}).DialContext(ctx2, "tcp", u.Host)
+	}
+
+	return (&tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}).DialContext(ctx2, "tcp", u.Host)
+}
+
+func benchReadRTSP(ctx context.Context, ur string, bc *benchClient) error {
+	u, err := rtspurl.Parse(ur)
+	if err != nil {
+		return err
+	}
+
+	c := gortsplib.Client{
+		ReadTimeout:  benchTimeout,
+		WriteTimeout: benchTimeout,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+
+	err = c.Start(u.Scheme, u.Host)
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	medias, baseURL, _, err := c.Describe(u)
+	if err != nil {
+		return err
+	}
+
+	err = c.SetupAll(medias, baseURL)
+	if err != nil {
+		return err
+	}
+
+	c.OnPacketRTPAny(func(medi *media.Media, forma formats.Format, pkt *rtp.Packet) {
+		bc.onData(len(pkt.Payload))
+	})
+
+	_, err = c.Play(nil)
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-waitErr(c.Wait):
+		return err
+
+	case <-ctx.Done():
+		return nil
+	}
+}
+
+func benchReadRTMP(ctx context.Context, ur string, bc *benchClient) error {
+	u, err := url.Parse(ur)
+	if err != nil {
+		return err
+	}
+
+	nconn, err := benchRTMPDial(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer nconn.Close()
+
+	go func() {
+		<-ctx.Done()
+		nconn.Close()
+	}()
+
+	conn := rtmp.NewConn(nconn)
+
+	nconn.SetDeadline(time.Now().Add(benchTimeout))
+	err = conn.InitializeClient(u, false)
+	if err != nil {
+		return err
+	}
+
+	_, _, err = conn.ReadTracks()
+	if err != nil {
+		return err
+	}
+
+	for {
+		nconn.SetReadDeadline(time.Now().Add(benchTimeout))
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return err
+		}
+
+		switch tmsg := msg.(type) {
+		case *message.Video:
+			bc.onData(len(tmsg.Payload))
+
+		case *message.ExtendedCodedFrames:
+			bc.onData(len(tmsg.Payload))
+
+		case *message.ExtendedFramesX:
+			bc.onData(len(tmsg.Payload))
+
+		case *message.Audio:
+			bc.onData(len(tmsg.Payload))
+		}
+	}
+}
+
+func benchReadHLS(ctx context.Context, ur string, bc *benchClient) error {
+	c := &gohlslib.Client{
+		URI: ur,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+		Log: func(level gohlslib.LogLevel, format string, args ...interface{}) {
+		},
+	}
+
+	c.OnTracks(func(tracks []*gohlslib.Track) error {
+		for _, track := range tracks {
+			c.OnData(track, func(pts time.Duration, unit interface{}) {
+				switch tunit := unit.(type) {
+				case [][]byte:
+					for _, nalu := range tunit {
+						bc.onData(len(nalu))
+					}
+
+				case []byte:
+					bc.onData(len(tunit))
+				}
+			})
+		}
+		return nil
+	})
+
+	err := c.Start()
+	if err != nil {
+		return err
+	}
+
+	select {
+	case err := <-c.Wait():
+		return err
+
+	case <-ctx.Done():
+		c.Close()
+		<-c.Wait()
+		return nil
+	}
+}
+
+// benchPublish generates a H264 test pattern and passes its frames to the callback.
+func benchPublish(
+	ctx context.Context,
+	enc *testsrc.Encoder,
+	bc *benchClient,
+	cb func(pts time.Duration, au [][]byte) error,
+) error {
+	start := time.Now()
+	count := 0
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			pts := time.Duration(count) * time.Second / benchFPS
+			au := enc.Encode(time.Now())
+
+			err := cb(pts, au)
+			if err != nil {
+				return err
+			}
+
+			for _, nalu := range au {
+				bc.onData(len(nalu))
+			}
+
+			count++
+			timer.Reset(time.Until(start.Add(time.Duration(count) * time.Second / benchFPS)))
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func benchPublishRTSP(ctx context.Context, ur string, enc *testsrc.Encoder, bc *benchClient) error {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+		SPS:               enc.SPS(),
+		PPS:               enc.PPS(),
+	}
+	medi := &media.Media{
+		Type:    media.TypeVideo,
+		Formats: []formats.Format{forma},
+	}
+
+	c := gortsplib.Client{
+		ReadTimeout:  benchTimeout,
+		WriteTimeout: benchTimeout,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+
+	err := c.StartRecording(ur, media.Medias{medi})
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+
+	rtpEnc := forma.CreateEncoder()
+
+	return benchPublish(ctx, enc, bc, func(pts time.Duration, au [][]byte) error {
+		pkts, err := rtpEnc.Encode(au, pts)
+		if err != nil {
+			return err
+		}
+
+		for _, pkt := range pkts {
+			err := c.WritePacketRTP(medi, pkt)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+func benchPublishRTMP(ctx context.Context, ur string, enc *testsrc.Encoder, bc *benchClient) error {
+	u, err := url.Parse(ur)
+	if err != nil {
+		return err
+	}
+
+	nconn, err := benchRTMPDial(ctx, u)
+	if err != nil {
+		return err
+	}
+	defer nconn.Close()
+
+	conn := rtmp.NewConn(nconn)
+
+	nconn.SetDeadline(time.Now().Add(benchTimeout))
+	err = conn.InitializeClient(u, true)
+	if err != nil {
+		return err
+	}
+
+	err = conn.WriteTracks(&formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+		SPS:               enc.SPS(),
+		PPS:               enc.PPS(),
+	}, nil)
+	if err != nil {
+		return err
+	}
+
+	return benchPublish(ctx, enc, bc, func(pts time.Duration, au [][]byte) error {
+		avcc, err := h264.AVCCMarshal(au)
+		if err != nil {
+			return err
+		}
+
+		nconn.SetWriteDeadline(time.Now().Add(benchTimeout))
+		return conn.WriteMessage(&message.Video{
+			ChunkStreamID:   message.VideoChunkStreamID,
+			MessageStreamID: 0x1000000,
+			Codec:           message.CodecH264,
+			IsKeyFrame:      h264.IDRPresent(au),
+			Type:            message.VideoTypeAU,
+			Payload:         avcc,
+			DTS:             pts,
+		})
+	})
+}
+
+func benchReader(ur string) (func(context.Context, string, *benchClient) error, error) {
+	u, err := url.Parse(ur)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "rtsp", "rtsps":
+		return benchReadRTSP, nil
+
+	case "rtmp", "rtmps":
+		return benchReadRTMP, nil
+
+	case "http", "https":
+		return benchReadHLS, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported scheme: '%s'", u.Scheme)
+	}
+}
+
+func benchPublisher(ur string) (func(context.Context, string, *testsrc.Encoder, *benchClient) error, error) {
+	u, err := url.Parse(ur)
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "rtsp", "rtsps":
+		return benchPublishRTSP, nil
+
+	case "rtmp", "rtmps":
+		return benchPublishRTMP, nil
+
+	default:
+		return nil, fmt.Errorf("unsupported scheme of publishers: '%s'", u.Scheme)
+	}
+}
+
+// benchReport prints statistics of a group of clients.
+func benchReport(buf *strings.Builder, name string, clients []*benchClient, duration time.Duration) {
+	var latencies []time.Duration
+	var bytes uint64
+	errors := make(map[string]int)
+
+	for _, bc := range clients {
+		if v, ok := bc.startupLatency(); ok {
+			latencies = append(latencies, v)
+		}
+		bytes += atomic.LoadUint64(&bc.bytes)
+		if bc.err != nil {
+			errors[bc.err.Error()]++
+		}
+	}
+
+	fmt.Fprintf(buf, "%s: %d/%d connected (%.1f%%)\n", name, len(latencies), len(clients),
+		float64(len(latencies))*100/float64(len(clients)))
+
+	if len(latencies) != 0 {
+		sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+		var sum time.Duration
+		for _, v := range latencies {
+			sum += v
+		}
+
+		fmt.Fprintf(buf, "  startup latency: min %v, avg %v, p95 %v, max %v\n",
+			latencies[0].Round(time.Millisecond),
+			(sum / time.Duration(len(latencies))).Round(time.Millisecond),
+			latencies[(len(latencies)*95-1)/100].Round(time.Millisecond),
+			latencies[len(latencies)-1].Round(time.Millisecond))
+
+		total := float64(bytes) * 8 / 1000 / duration.Seconds()
+		fmt.Fprintf(buf, "  throughput: %.2f kbit/s total, %.2f kbit/s per client (measured over %v)\n",
+			total, total/float64(len(latencies)), duration)
+	}
+
+	if len(errors) != 0 {
+		msgs := make([]string, 0, len(errors))
+		for msg := range errors {
+			msgs = append(msgs, msg)
+		}
+		sort.Strings(msgs)
+
+		fmt.Fprintf(buf, "  errors:\n")
+		for _, msg := range msgs {
+			fmt.Fprintf(buf, "    %d x %s\n", errors[msg], msg)
+		}
+	}
+}
+
+// bench spawns publishers and readers against a server, keeps them running for the given duration
+// and returns a human-readable report with connection success rate, startup latency and throughput.
+// In URLs, {n} is replaced with the index of the publisher.
+func bench(
+	ur string,
+	readers int,
+	publishers int,
+	publishURL string,
+	publishBitrate int,
+	duration time.Duration,
+) (string, error) {
+	if readers < 0 || publishers < 0 {
+		return "", fmt.Errorf("number of readers and publishers can't be negative")
+	}
+
+	if publishers > 1 && (!strings.Contains(ur, "{n}") ||
+		(publishURL != "" && !strings.Contains(publishURL, "{n}"))) {
+		return "", fmt.Errorf("URLs must contain {n} when there are multiple publishers")
+	}
+
+	readFunc, err := benchReader(ur)
+	if err != nil {
+		return "", err
+	}
+
+	if publishURL == "" {
+		publishURL = ur
+	}
+
+	var publishFunc func(context.Context, string, *testsrc.Encoder, *benchClient) error
+	if publishers > 0 {
+		publishFunc, err = benchPublisher(publishURL)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	var wg sync.WaitGroup
+
+	pubClients := make([]*benchClient, publishers)
+
+	for i := range pubClients {
+		enc, err := testsrc.NewEncoder(testsrc.Params{
+			Width:   640,
+			Height:  480,
+			FPS:     benchFPS,
+			Bitrate: publishBitrate,
+		})
+		if err != nil {
+			return "", err
+		}
+
+		bc := &benchClient{start: time.Now()}
+		pubClients[i] = bc
+
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bc.err = publishFunc(ctx, benchURL(publishURL, i), enc, bc)
+		}(i)
+	}
+
+	// wait for publishers to send the first frame
+	if publishers > 0 {
+		t := time.NewTicker(100 * time.Millisecond)
+		deadline := time.Now().Add(benchTimeout)
+
+		for {
+			<-t.C
+
+			ready := 0
+			for _, bc := range pubClients {
+				if _, ok := bc.startupLatency(); ok {
+					ready++
+				}
+			}
+
+			if ready == publishers || time.Now().After(deadline) {
+				break
+			}
+		}
+
+		t.Stop()
+	}
+
+	readClients := make([]*benchClient, readers)
+
+	for i := range readClients {
+		n := 0
+		if publishers > 0 {
+			n = i % publishers
+		}
+
+		bc := &benchClient{start: time.Now()}
+		readClients[i] = bc
+
+		wg.Add(1)
+		go func(n int) {
+			defer wg.Done()
+			bc.err = readFunc(ctx, benchURL(ur, n), bc)
+		}(n)
+	}
+
+	time.Sleep(duration)
+	ctxCancel()
+	wg.Wait()
+
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "target: %s\n", ur)
+
+	if publishers > 0 {
+		benchReport(&buf, "publishers", pubClients, duration)
+	}
+
+	if readers > 0 {
+		benchReport(&buf, "readers", readClients, duration)
+	}
+
+	return buf.String(), nil
+}
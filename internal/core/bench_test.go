@@ -0,0 +1,40 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestBench(t *testing.T) {
+	p, ok := newInstance("hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	for _, ca := range []string{"rtsp", "rtmp"} {
+		t.Run(ca, func(t *testing.T) {
+			var ur string
+			if ca == "rtsp" {
+				ur = "rtsp://localhost:8554/bench{n}"
+			} else {
+				ur = "rtmp://localhost/bench{n}"
+			}
+
+			report, err := bench(ur, 4, 2, "", 100000, 1*time.Second)
+			require.NoError(t, err)
+			require.Contains(t, report, "publishers: 2/2 connected (100.0%)")
+			require.Contains(t, report, "readers: 4/4 connected (100.0%)")
+			require.NotContains(t, report, "errors:")
+		})
+	}
+
+	_, err := bench("rtsp://localhost:8554/bench", 1, 2, "", 100000, 1*time.Second)
+	require.EqualError(t, err, "URLs must contain {n} when there are multiple publishers")
+
+	_, err = bench("ftp://localhost/bench", 1, 0, "", 100000, 1*time.Second)
+	require.EqualError(t, err, "unsupported scheme: 'ftp'")
+}
@@ -0,0 +1,180 @@
+package core
+
+import (
+	"crypto/tls"
+	"sync"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/confwatcher"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type certLoaderParent interface {
+	logger.Writer
+}
+
+// certLoader loads a TLS certificate from disk and reloads it when
+// the certificate or the key change, without restarting listeners.
+type certLoader struct {
+	certPath string
+	keyPath  string
+	parent   certLoaderParent
+
+	certWatcher *confwatcher.ConfWatcher
+	keyWatcher  *confwatcher.ConfWatcher
+	mutex       sync.RWMutex
+	cert        *tls.Certificate
+
+	// in
+	terminate chan struct{}
+
+	// out
+	done chan struct{}
+}
+
+func newCertLoader(
+	certPath string,
+	keyPath string,
+	parent certLoaderParent,
+) (*certLoader, error) {
+	cert, err := tls.LoadX509KeyPair(certPath, keyPath)
+	if err != nil {
+		return nil, err
+	}
+
+	l := &certLoader{
+		certPath:  certPath,
+		keyPath:   keyPath,
+		parent:    parent,
+		cert:      &cert,
+		terminate: make(chan struct{}),
+		done:      make(chan struct{}),
+	}
+
+	l.certWatcher, err = confwatcher.New(certPath)
+	if err != nil {
+		return nil, err
+	}
+
+	l.keyWatcher, err = confwatcher.New(keyPath)
+	if err != nil {
+		l.certWatcher.Close()
+		return nil, err
+	}
+
+	go l.run()
+
+	return l, nil
+}
+
+func (l *certLoader) close() {
+	close(l.terminate)
+	<-l.done
+	l.certWatcher.Close()
+	l.keyWatcher.Close()
+}
+
+// Log is the main logging function.
+func (l *certLoader) Log(level logger.Level, format string, args ...interface{}) {
+	l.parent.Log(level, "[cert loader] "+format, args...)
+}
+
+func (l *certLoader) run() {
+	defer close(l.done)
+
+	for {
+		select {
+		case _, ok := <-l.certWatcher.Watch():
+			if !ok {
+				l.Log(logger.Warn, "unable to watch '%s'", l.certPath)
+				return
+			}
+			l.reload()
+
+		case _, ok := <-l.keyWatcher.Watch():
+			if !ok {
+				l.Log(logger.Warn, "unable to watch '%s'", l.keyPath)
+				return
+			}
+			l.reload()
+
+		case <-l.terminate:
+			return
+		}
+	}
+}
+
+func (l *certLoader) reload() {
+	// certificate and key may be updated at different times;
+	// in case they don't match, the next change will trigger another reload.
+	cert, err := tls.LoadX509KeyPair(l.certPath, l.keyPath)
+	if err != nil {
+		l.Log(logger.Warn, "unable to reload certificate '%s': %v", l.certPath, err)
+		return
+	}
+
+	l.mutex.Lock()
+	l.cert = &cert
+	l.mutex.Unlock()
+
+	l.Log(logger.Info, "certificate '%s' reloaded", l.certPath)
+}
+
+// getCertificate implements tls.Config.GetCertificate.
+func (l *certLoader) getCertificate(_ *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mutex.RLock()
+	defer l.mutex.RUnlock()
+	return l.cert, nil
+}
+
+// loadTLSConfig returns the TLS configuration of a listener.
+// When ACME is enabled, certificates are obtained automatically,
+// otherwise they are loaded from disk and reloaded when they change.
+// The minimum TLS version and cipher suites are applied to every listener.
+// The returned certLoader must be closed with the listener.
+func loadTLSConfig(
+	serverCert string,
+	serverKey string,
+	acmeManager *acmeManager,
+	tlsMinVersion conf.TLSVersion,
+	tlsCipherSuites conf.TLSCipherSuites,
+	tlsLogHandshakes bool,
+	parent certLoaderParent,
+) (*tls.Config, *certLoader, error) {
+	var tlsConfig *tls.Config
+	var l *certLoader
+
+	if acmeManager != nil {
+		tlsConfig = acmeManager.tlsConfig()
+	} else {
+		var err error
+		l, err = newCertLoader(serverCert, serverKey, parent)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tlsConfig = &tls.Config{GetCertificate: l.getCertificate}
+	}
+
+	tlsConfig.MinVersion = uint16(tlsMinVersion)
+	tlsConfig.CipherSuites = tlsCipherSuites
+
+	if tlsLogHandshakes {
+		// the remote address is known before the handshake, while
+		// the negotiated parameters are known after it.
+		tlsConfig.GetConfigForClient = func(hello *tls.ClientHelloInfo) (*tls.Config, error) {
+			connConfig := tlsConfig.Clone()
+			connConfig.GetConfigForClient = nil
+			connConfig.VerifyConnection = func(cs tls.ConnectionState) error {
+				parent.Log(logger.Info, "[conn %v] TLS handshake completed, version %s, cipher suite %s",
+					hello.Conn.RemoteAddr(),
+					conf.TLSVersion(cs.Version),
+					tls.CipherSuiteName(cs.CipherSuite))
+				return nil
+			}
+			return connConfig, nil
+		}
+	}
+
+	return tlsConfig, l, nil
+}
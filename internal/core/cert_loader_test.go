@@ -0,0 +1,156 @@
+package core
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type nilLogger struct{}
+
+func (nilLogger) Log(logger.Level, string, ...interface{}) {}
+
+func generateCertificate() ([]byte, []byte, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now(),
+		NotAfter:     time.Now().Add(24 * time.Hour),
+	}
+
+	certDER, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		nil
+}
+
+func TestCertLoaderReload(t *testing.T) {
+	serverCertFpath, err := writeTempFile(serverCert)
+	require.NoError(t, err)
+	defer os.Remove(serverCertFpath)
+
+	serverKeyFpath, err := writeTempFile(serverKey)
+	require.NoError(t, err)
+	defer os.Remove(serverKeyFpath)
+
+	l, err := newCertLoader(serverCertFpath, serverKeyFpath, &nilLogger{})
+	require.NoError(t, err)
+	defer l.close()
+
+	cert1, err := l.getCertificate(nil)
+	require.NoError(t, err)
+
+	newCert, newKey, err := generateCertificate()
+	require.NoError(t, err)
+
+	// write the key first, in order to test that a mismatching pair is ignored
+	err = os.WriteFile(serverKeyFpath, newKey, 0o644)
+	require.NoError(t, err)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	cert2, err := l.getCertificate(nil)
+	require.NoError(t, err)
+	require.Equal(t, cert1, cert2)
+
+	err = os.WriteFile(serverCertFpath, newCert, 0o644)
+	require.NoError(t, err)
+
+	time.Sleep(1500 * time.Millisecond)
+
+	cert3, err := l.getCertificate(nil)
+	require.NoError(t, err)
+	require.NotEqual(t, cert1, cert3)
+
+	block, _ := pem.Decode(newCert)
+	require.True(t, bytes.Equal(block.Bytes, cert3.Certificate[0]))
+}
+
+type testLogger struct {
+	ch chan string
+}
+
+func (l *testLogger) Log(_ logger.Level, format string, args ...interface{}) {
+	select {
+	case l.ch <- fmt.Sprintf(format, args...):
+	default:
+	}
+}
+
+func TestLoadTLSConfigPolicy(t *testing.T) {
+	serverCertFpath, err := writeTempFile(serverCert)
+	require.NoError(t, err)
+	defer os.Remove(serverCertFpath)
+
+	serverKeyFpath, err := writeTempFile(serverKey)
+	require.NoError(t, err)
+	defer os.Remove(serverKeyFpath)
+
+	lg := &testLogger{ch: make(chan string, 1)}
+
+	tlsConfig, l, err := loadTLSConfig(serverCertFpath, serverKeyFpath, nil,
+		tls.VersionTLS12, conf.TLSCipherSuites{tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256}, true, lg)
+	require.NoError(t, err)
+	defer l.close()
+
+	ln, err := tls.Listen("tcp", "localhost:9555", tlsConfig)
+	require.NoError(t, err)
+	defer ln.Close()
+
+	go func() {
+		for {
+			nconn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			nconn.(*tls.Conn).Handshake()
+			nconn.Close()
+		}
+	}()
+
+	_, err = tls.Dial("tcp", "localhost:9555", &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS11,
+	})
+	require.Error(t, err)
+
+	conn, err := tls.Dial("tcp", "localhost:9555", &tls.Config{
+		InsecureSkipVerify: true,
+		MaxVersion:         tls.VersionTLS12,
+	})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	require.Equal(t, uint16(tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256), conn.ConnectionState().CipherSuite)
+	require.Regexp(t, `^\[conn .+?\] TLS handshake completed, version 1.2, `+
+		`cipher suite TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256$`, <-lg.ch)
+}
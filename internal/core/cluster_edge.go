@@ -0,0 +1,209 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type clusterEdgePathManager interface {
+	clusterPathsSet(pathConfs map[string]*conf.PathConf)
+}
+
+type clusterEdgeParent interface {
+	logger.Writer
+}
+
+// clusterEdge periodically queries the API of an origin server
+// and creates a path with an on-demand RTSP source for every path of the origin,
+// in order to scale readers horizontally.
+type clusterEdge struct {
+	originAPIURL    string
+	originRTSPURL   string
+	pathPrefix      string
+	pollPeriod      time.Duration
+	authPassThrough bool
+	pathManager     clusterEdgePathManager
+	parent          clusterEdgeParent
+
+	ctx        context.Context
+	ctxCancel  func()
+	httpClient *http.Client
+	pathConfs  map[string]*conf.PathConf
+
+	// out
+	done chan struct{}
+}
+
+func newClusterEdge(
+	parentCtx context.Context,
+	originAPIURL string,
+	originRTSPURL string,
+	pathPrefix string,
+	pollPeriod conf.StringDuration,
+	authPassThrough bool,
+	readTimeout conf.StringDuration,
+	pathManager clusterEdgePathManager,
+	parent clusterEdgeParent,
+) *clusterEdge {
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	e := &clusterEdge{
+		originAPIURL:    strings.TrimSuffix(originAPIURL, "/"),
+		originRTSPURL:   strings.TrimSuffix(originRTSPURL, "/"),
+		pathPrefix:      pathPrefix,
+		pollPeriod:      time.Duration(pollPeriod),
+		authPassThrough: authPassThrough,
+		pathManager:     pathManager,
+		parent:          parent,
+		ctx:             ctx,
+		ctxCancel:       ctxCancel,
+		httpClient: &http.Client{
+			Timeout: time.Duration(readTimeout),
+		},
+		done: make(chan struct{}),
+	}
+
+	e.Log(logger.Info, "pulling paths from %s", e.originAPIURL)
+
+	go e.run()
+
+	return e
+}
+
+func (e *clusterEdge) close() {
+	e.Log(logger.Info, "closing")
+	e.ctxCancel()
+	<-e.done
+
+	e.pathManager.clusterPathsSet(nil)
+}
+
+// Log is the main logging function.
+func (e *clusterEdge) Log(level logger.Level, format string, args ...interface{}) {
+	e.parent.Log(level, "[cluster] "+format, args...)
+}
+
+func (e *clusterEdge) run() {
+	defer close(e.done)
+
+	t := time.NewTicker(e.pollPeriod)
+	defer t.Stop()
+
+	e.poll()
+
+	for {
+		select {
+		case <-t.C:
+			e.poll()
+
+		case <-e.ctx.Done():
+			return
+		}
+	}
+}
+
+func (e *clusterEdge) poll() {
+	originPaths, err := e.fetchOriginPaths()
+	if err != nil {
+		e.Log(logger.Warn, "unable to fetch paths of origin: %v", err)
+		return
+	}
+
+	pathConfs := make(map[string]*conf.PathConf)
+
+	for name, item := range originPaths {
+		pathConf, err := e.pathConf(name, item)
+		if err != nil {
+			e.Log(logger.Warn, "skipping path '%s': %v", name, err)
+			continue
+		}
+
+		pathConfs[e.pathPrefix+name] = pathConf
+	}
+
+	if reflect.DeepEqual(pathConfs, e.pathConfs) {
+		return
+	}
+
+	e.Log(logger.Info, "origin has %d %s", len(pathConfs), func() string {
+		if len(pathConfs) == 1 {
+			return "path"
+		}
+		return "paths"
+	}())
+
+	e.pathConfs = pathConfs
+	e.pathManager.clusterPathsSet(pathConfs)
+}
+
+func (e *clusterEdge) fetchOriginPaths() (map[string]pathAPIPathsListItem, error) {
+	req, err := http.NewRequestWithContext(e.ctx, http.MethodGet, e.originAPIURL+"/v1/paths/list", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	res, err := e.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	var data pathAPIPathsListData
+	err = json.NewDecoder(res.Body).Decode(&data)
+	if err != nil {
+		return nil, err
+	}
+
+	return data.Items, nil
+}
+
+func (e *clusterEdge) pathConf(name string, item pathAPIPathsListItem) (*conf.PathConf, error) {
+	source, err := url.Parse(e.originRTSPURL + "/" + name)
+	if err != nil {
+		return nil, err
+	}
+
+	pathConf := &conf.PathConf{
+		SourceOnDemand: true,
+	}
+
+	if e.authPassThrough && item.Conf != nil {
+		pathConf.ReadUser = item.Conf.ReadUser
+		pathConf.ReadPass = item.Conf.ReadPass
+		pathConf.ReadIPs = item.Conf.ReadIPs
+
+		// credentials can be used to read from the origin only when they are not hashed.
+		if item.Conf.ReadUser != "" &&
+			!item.Conf.ReadUser.IsHashed() && !item.Conf.ReadPass.IsHashed() {
+			source.User = url.UserPassword(string(item.Conf.ReadUser), string(item.Conf.ReadPass))
+		}
+	}
+
+	pathConf.Source = source.String()
+
+	// fill default values and validate
+	temp := &conf.Conf{
+		Paths: map[string]*conf.PathConf{
+			e.pathPrefix + name: pathConf,
+		},
+	}
+	err = temp.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return pathConf, nil
+}
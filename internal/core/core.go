@@ -2,14 +2,18 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"os"
 	"os/signal"
 	"reflect"
+	"syscall"
+	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/gin-gonic/gin"
 
 	"github.com/aler9/mediamtx/internal/conf"
@@ -22,37 +26,82 @@ import (
 
 var version = "v0.0.0"
 
+type coreAddPathReq struct {
+	name     string
+	pathConf *conf.PathConf
+	res      chan error
+}
+
+type coreRemovePathReq struct {
+	name string
+	res  chan error
+}
+
 // Core is an instance of mediamtx.
 type Core struct {
-	ctx             context.Context
-	ctxCancel       func()
-	confPath        string
-	conf            *conf.Conf
-	confFound       bool
-	logger          *logger.Logger
-	externalCmdPool *externalcmd.Pool
-	metrics         *metrics
-	pprof           *pprof
-	pathManager     *pathManager
-	rtspServer      *rtspServer
-	rtspsServer     *rtspServer
-	rtmpServer      *rtmpServer
-	rtmpsServer     *rtmpServer
-	hlsServer       *hlsServer
-	webRTCServer    *webRTCServer
-	api             *api
-	confWatcher     *confwatcher.ConfWatcher
+	ctx              context.Context
+	ctxCancel        func()
+	confPath         string
+	conf             *conf.Conf
+	confFound        bool
+	handleSignals    bool
+	events           *apiEvents
+	logger           *logger.Logger
+	externalCmdPool  *externalcmd.Pool
+	metrics          *metrics
+	pprof            *pprof
+	authBanList      *authBanList
+	hlsRevokedTokens *hlsRevokedTokens
+	accessList       *accessList
+	acmeManager      *acmeManager
+	pathManager      *pathManager
+	clusterEdge      *clusterEdge
+	rtspServer       *rtspServer
+	rtspsServer      *rtspServer
+	mdnsAdvertiser   *mdnsAdvertiser
+	rtmpServer       *rtmpServer
+	rtmpsServer      *rtmpServer
+	hlsServer        *hlsServer
+	webRTCServer     *webRTCServer
+	httpIngestServer *httpIngestServer
+	statsStore       *statsStore
+	api              *api
+	grpcAPI          *grpcAPI
+	confWatcher      *confwatcher.ConfWatcher
+
+	// content of the configuration file written by persistPaths
+	persistedConf []byte
 
 	// in
-	chAPIConfigSet chan *conf.Conf
+	chAPIConfigSet     chan *conf.Conf
+	chGRPCAPIConfigSet chan *conf.Conf
+	chAddPath          chan coreAddPathReq
+	chRemovePath       chan coreRemovePathReq
+	chGetPathManager   chan chan *pathManager
 
 	// out
 	done chan struct{}
 }
 
 var cli struct {
-	Version  bool   `help:"print version"`
-	Confpath string `arg:"" default:"mediamtx.yml"`
+	Version     bool `help:"print version"`
+	CheckConfig bool `help:"check the configuration file, print all errors and exit"`
+	CheckPorts  bool `help:"when checking the configuration file, also check that listener ports are free"`
+	Run         struct {
+		Confpath string `arg:"" default:"mediamtx.yml"`
+	} `cmd:"" default:"withargs" help:"run the server (default command)"`
+	Probe struct {
+		URL      string        `arg:"" help:"URL of a RTSP, RTMP or HLS source"`
+		Duration time.Duration `default:"5s" help:"how long to read the source in order to measure its bitrate"`
+	} `cmd:"" help:"connect to a source, print its tracks and bitrate, then exit"`
+	Bench struct {
+		URL            string        `arg:"" help:"URL of a RTSP, RTMP or HLS stream that is read. {n} is replaced with the index of the publisher"`
+		Readers        int           `default:"10" help:"number of readers"`
+		Publishers     int           `default:"0" help:"number of synthetic publishers that generate a H264 test pattern"`
+		PublishURL     string        `help:"RTSP or RTMP URL used by publishers. The default is the URL of readers"`
+		PublishBitrate int           `default:"1000000" help:"bitrate of each publisher, in bit/s"`
+		Duration       time.Duration `default:"10s" help:"duration of the test"`
+	} `cmd:"" help:"spawn readers and publishers against a server, print success rate, startup latency and throughput, then exit"`
 }
 
 // New allocates a core.
@@ -73,7 +122,7 @@ func New(args []string) (*Core, bool) {
 		panic(err)
 	}
 
-	_, err = parser.Parse(args)
+	kctx, err := parser.Parse(args)
 	parser.FatalIfErrorf(err)
 
 	if cli.Version {
@@ -81,23 +130,69 @@ func New(args []string) (*Core, bool) {
 		os.Exit(0)
 	}
 
-	ctx, ctxCancel := context.WithCancel(context.Background())
+	if cli.CheckConfig {
+		errs := conf.Check(cli.Run.Confpath)
+		if errs != nil {
+			for _, err := range errs {
+				fmt.Printf("ERR: %s\n", err)
+			}
+			os.Exit(1)
+		}
 
-	p := &Core{
-		ctx:            ctx,
-		ctxCancel:      ctxCancel,
-		confPath:       cli.Confpath,
-		chAPIConfigSet: make(chan *conf.Conf),
-		done:           make(chan struct{}),
+		cnf, _, err := conf.Load(cli.Run.Confpath)
+		if err != nil {
+			fmt.Printf("ERR: %s\n", err)
+			os.Exit(1)
+		}
+
+		if errs := preflight(cnf, cli.CheckPorts); errs != nil {
+			for _, err := range errs {
+				fmt.Printf("ERR: %s\n", err)
+			}
+			os.Exit(1)
+		}
+
+		fmt.Println("configuration is valid")
+		os.Exit(0)
 	}
 
-	p.conf, p.confFound, err = conf.Load(p.confPath)
+	if kctx.Command() == "probe <url>" {
+		report, err := probe(cli.Probe.URL, cli.Probe.Duration)
+		if err != nil {
+			fmt.Printf("ERR: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(report)
+		os.Exit(0)
+	}
+
+	if kctx.Command() == "bench <url>" {
+		report, err := bench(cli.Bench.URL, cli.Bench.Readers, cli.Bench.Publishers,
+			cli.Bench.PublishURL, cli.Bench.PublishBitrate, cli.Bench.Duration)
+		if err != nil {
+			fmt.Printf("ERR: %s\n", err)
+			os.Exit(1)
+		}
+
+		fmt.Print(report)
+		os.Exit(0)
+	}
+
+	cnf, confFound, err := conf.Load(cli.Run.Confpath)
 	if err != nil {
-		fmt.Printf("ERR: %s\n", err)
+		// print all errors of the configuration instead of the first one
+		if errs := conf.Check(cli.Run.Confpath); errs != nil {
+			for _, err := range errs {
+				fmt.Printf("ERR: %s\n", err)
+			}
+		} else {
+			fmt.Printf("ERR: %s\n", err)
+		}
 		return nil, false
 	}
 
-	err = p.createResources(true)
+	p, err := newCore(cli.Run.Confpath, cnf, confFound, true)
 	if err != nil {
 		if p.logger != nil {
 			p.Log(logger.Error, "%s", err)
@@ -113,6 +208,52 @@ func New(args []string) (*Core, bool) {
 	return p, true
 }
 
+// NewFromConf allocates a core that uses the given configuration,
+// without reading any configuration file or command line argument.
+// It allows to embed the server into other Go applications.
+// Missing fields of the configuration are filled with default values.
+func NewFromConf(cnf *conf.Conf) (*Core, error) {
+	err := cnf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	cnf = cnf.Clone()
+
+	p, err := newCore("", cnf, false, false)
+	if err != nil {
+		p.closeResources(nil, false)
+		return nil, err
+	}
+
+	go p.run()
+
+	return p, nil
+}
+
+func newCore(confPath string, cnf *conf.Conf, confFound bool, handleSignals bool) (*Core, error) {
+	ctx, ctxCancel := context.WithCancel(context.Background())
+
+	p := &Core{
+		ctx:                ctx,
+		ctxCancel:          ctxCancel,
+		confPath:           confPath,
+		conf:               cnf,
+		confFound:          confFound,
+		handleSignals:      handleSignals,
+		events:             newAPIEvents(),
+		chAPIConfigSet:     make(chan *conf.Conf),
+		chGRPCAPIConfigSet: make(chan *conf.Conf),
+		chAddPath:          make(chan coreAddPathReq),
+		chRemovePath:       make(chan coreRemovePathReq),
+		chGetPathManager:   make(chan chan *pathManager),
+		done:               make(chan struct{}),
+	}
+
+	err := p.createResources(true)
+	return p, err
+}
+
 // Close closes Core and waits for all goroutines to return.
 func (p *Core) Close() {
 	p.ctxCancel()
@@ -129,6 +270,25 @@ func (p *Core) Log(level logger.Level, format string, args ...interface{}) {
 	p.logger.Log(level, format, args...)
 }
 
+// coreLogModule is the log writer of a module whose log level can be set separately.
+type coreLogModule struct {
+	module string
+	p      *Core
+}
+
+// Log implements logger.Writer.
+func (l *coreLogModule) Log(level logger.Level, format string, args ...interface{}) {
+	l.p.logger.LogModule(l.module, level, format, args...)
+}
+
+func coreLogModuleLevels(levels conf.LogLevels) map[string]logger.Level {
+	ret := make(map[string]logger.Level, len(levels))
+	for module, level := range levels {
+		ret[module] = logger.Level(level)
+	}
+	return ret
+}
+
 func (p *Core) run() {
 	defer close(p.done)
 
@@ -140,12 +300,22 @@ func (p *Core) run() {
 	}()
 
 	interrupt := make(chan os.Signal, 1)
-	signal.Notify(interrupt, os.Interrupt)
+	if p.handleSignals {
+		signal.Notify(interrupt, os.Interrupt, syscall.SIGTERM)
+	}
 
 outer:
 	for {
 		select {
 		case <-confChanged:
+			// the file has been changed by persistPaths
+			if p.persistedConf != nil {
+				byts, err := os.ReadFile(p.confPath)
+				if err == nil && bytes.Equal(byts, p.persistedConf) {
+					break
+				}
+			}
+
 			p.Log(logger.Info, "reloading configuration (file changed)")
 
 			newConf, _, err := conf.Load(p.confPath)
@@ -163,14 +333,77 @@ outer:
 		case newConf := <-p.chAPIConfigSet:
 			p.Log(logger.Info, "reloading configuration (API request)")
 
+			oldConf := p.conf
 			err := p.reloadConf(newConf, true)
 			if err != nil {
 				p.Log(logger.Error, "%s", err)
 				break outer
 			}
 
+			p.persistPaths(oldConf)
+
+			if p.grpcAPI != nil {
+				p.grpcAPI.confReload(newConf)
+			}
+
+		case newConf := <-p.chGRPCAPIConfigSet:
+			p.Log(logger.Info, "reloading configuration (gRPC API request)")
+
+			oldConf := p.conf
+			err := p.reloadConf(newConf, true)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
+			p.persistPaths(oldConf)
+
+			if p.api != nil {
+				p.api.confReload(newConf)
+			}
+
+		case req := <-p.chAddPath:
+			newConf, err := p.confWithPathAdded(req.name, req.pathConf)
+			req.res <- err
+			if err != nil {
+				break
+			}
+
+			p.Log(logger.Info, "reloading configuration (path added)")
+
+			oldConf := p.conf
+			err = p.reloadConf(newConf, false)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
+			p.persistPaths(oldConf)
+
+		case req := <-p.chRemovePath:
+			newConf, err := p.confWithPathRemoved(req.name)
+			req.res <- err
+			if err != nil {
+				break
+			}
+
+			p.Log(logger.Info, "reloading configuration (path removed)")
+
+			oldConf := p.conf
+			err = p.reloadConf(newConf, false)
+			if err != nil {
+				p.Log(logger.Error, "%s", err)
+				break outer
+			}
+
+			p.persistPaths(oldConf)
+
+		case res := <-p.chGetPathManager:
+			res <- p.pathManager
+
 		case <-interrupt:
 			p.Log(logger.Info, "shutting down gracefully")
+			p.redirectRTSPReaders()
 			break outer
 
 		case <-p.ctx.Done():
@@ -183,6 +416,40 @@ outer:
 	p.closeResources(nil, false)
 }
 
+// redirectRTSPReaders redirects RTSP readers to another server before shutting down,
+// in order to allow them to keep reading.
+func (p *Core) redirectRTSPReaders() {
+	if p.conf.RTSPShutdownRedirect == "" {
+		return
+	}
+
+	target, err := url.Parse(p.conf.RTSPShutdownRedirect)
+	if err != nil {
+		return
+	}
+
+	n := 0
+	for _, s := range []*rtspServer{p.rtspServer, p.rtspsServer} {
+		if s != nil {
+			n += s.redirectReaders(target)
+		}
+	}
+
+	if n == 0 {
+		return
+	}
+
+	p.Log(logger.Info, "%d RTSP %s redirected to %s", n, func() string {
+		if n == 1 {
+			return "reader"
+		}
+		return "readers"
+	}(), p.conf.RTSPShutdownRedirect)
+
+	// wait for readers to receive the REDIRECT request
+	time.Sleep(rtspSessionRedirectGracePeriod)
+}
+
 func (p *Core) createResources(initial bool) error {
 	var err error
 
@@ -197,9 +464,12 @@ func (p *Core) createResources(initial bool) error {
 		}
 	}
 
+	// log levels can be changed without recreating the logger.
+	p.logger.SetLevel(logger.Level(p.conf.LogLevel), coreLogModuleLevels(p.conf.LogLevels))
+
 	if initial {
 		p.Log(logger.Info, "MediaMTX / rtsp-simple-server %s", version)
-		if !p.confFound {
+		if !p.confFound && p.confPath != "" {
 			p.Log(logger.Warn, "configuration file not found, using an empty configuration")
 		}
 
@@ -210,6 +480,14 @@ func (p *Core) createResources(initial bool) error {
 
 		gin.SetMode(gin.ReleaseMode)
 
+		errs := preflight(p.conf, true)
+		if errs != nil {
+			for _, err := range errs {
+				p.Log(logger.Error, "%s", err)
+			}
+			return fmt.Errorf("preflight checks failed with %d problem(s), the server cannot start", len(errs))
+		}
+
 		p.externalCmdPool = externalcmd.NewPool()
 	}
 
@@ -217,7 +495,10 @@ func (p *Core) createResources(initial bool) error {
 		if p.metrics == nil {
 			p.metrics, err = newMetrics(
 				p.conf.MetricsAddress,
+				p.conf.UnixSocketPermissions,
 				p.conf.ReadTimeout,
+				p.conf.MetricsPathsExclude,
+				p.conf.MetricsPathsAggregate,
 				p,
 			)
 			if err != nil {
@@ -230,6 +511,53 @@ func (p *Core) createResources(initial bool) error {
 		if p.pprof == nil {
 			p.pprof, err = newPPROF(
 				p.conf.PPROFAddress,
+				p.conf.UnixSocketPermissions,
+				p.conf.ReadTimeout,
+				p.conf.APIUser,
+				p.conf.APIPass,
+				p,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.conf.AuthBanAttempts != 0 {
+		if p.authBanList == nil {
+			p.authBanList = newAuthBanList(
+				p.conf.AuthBanAttempts,
+				p.conf.AuthBanDuration,
+				p,
+			)
+		}
+	}
+
+	if p.conf.AllowedReadIPs != nil ||
+		p.conf.AllowedPublishIPs != nil ||
+		p.conf.GeoIPDatabase != "" {
+		if p.accessList == nil {
+			p.accessList, err = newAccessList(
+				p.conf.AllowedReadIPs,
+				p.conf.AllowedPublishIPs,
+				p.conf.GeoIPDatabase,
+				p.conf.GeoIPAllowedCountries,
+				p.conf.GeoIPDeniedCountries,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(p.conf.ACMEDomains) != 0 {
+		if p.acmeManager == nil {
+			p.acmeManager, err = newACMEManager(
+				p.conf.ACMEDomains,
+				p.conf.ACMEEmail,
+				p.conf.ACMECacheDir,
+				p.conf.ACMEDirectoryURL,
+				p.conf.ACMEHTTPAddress,
 				p.conf.ReadTimeout,
 				p,
 			)
@@ -248,12 +576,32 @@ func (p *Core) createResources(initial bool) error {
 			p.conf.ReadBufferCount,
 			p.conf.UDPMaxPayloadSize,
 			p.conf.Paths,
+			p.conf.Tenants,
+			p.conf.PublishPathRules,
+			p.conf.SourceStartConcurrency,
 			p.externalCmdPool,
 			p.metrics,
-			p,
+			p.events,
+			&coreLogModule{module: "path", p: p},
 		)
 	}
 
+	if p.conf.ClusterOriginAPIURL != "" {
+		if p.clusterEdge == nil {
+			p.clusterEdge = newClusterEdge(
+				p.ctx,
+				p.conf.ClusterOriginAPIURL,
+				p.conf.ClusterOriginRTSPURL,
+				p.conf.ClusterPathPrefix,
+				p.conf.ClusterPollPeriod,
+				p.conf.ClusterAuthPassThrough,
+				p.conf.ReadTimeout,
+				p.pathManager,
+				p,
+			)
+		}
+	}
+
 	if !p.conf.RTSPDisable &&
 		(p.conf.Encryption == conf.EncryptionNo ||
 			p.conf.Encryption == conf.EncryptionOptional) {
@@ -263,11 +611,21 @@ func (p *Core) createResources(initial bool) error {
 			p.rtspServer, err = newRTSPServer(
 				p.ctx,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
 				p.conf.RTSPAddress,
 				p.conf.AuthMethods,
+				p.conf.AuthPauseAfterError,
+				p.conf.RTSPAuthRealm,
+				p.conf.RTSPAuthStatusText,
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTSPSessionTimeout,
+				p.conf.RTSPSessionTimeoutTCP,
+				p.conf.RTSPIPFilter,
+				p.conf.RTSPSocketOptions,
+				p.conf.RTPSocketOptions,
 				useUDP,
 				useMulticast,
 				p.conf.RTPAddress,
@@ -278,6 +636,11 @@ func (p *Core) createResources(initial bool) error {
 				false,
 				"",
 				"",
+				nil,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
+				p.conf.RTSPTunnelAddress,
 				p.conf.RTSPAddress,
 				p.conf.Protocols,
 				p.conf.RunOnConnect,
@@ -285,7 +648,7 @@ func (p *Core) createResources(initial bool) error {
 				p.externalCmdPool,
 				p.metrics,
 				p.pathManager,
-				p,
+				&coreLogModule{module: "rtsp", p: p},
 			)
 			if err != nil {
 				return err
@@ -300,11 +663,21 @@ func (p *Core) createResources(initial bool) error {
 			p.rtspsServer, err = newRTSPServer(
 				p.ctx,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
 				p.conf.RTSPSAddress,
 				p.conf.AuthMethods,
+				p.conf.AuthPauseAfterError,
+				p.conf.RTSPAuthRealm,
+				p.conf.RTSPAuthStatusText,
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTSPSessionTimeout,
+				p.conf.RTSPSessionTimeoutTCP,
+				p.conf.RTSPIPFilter,
+				p.conf.RTSPSocketOptions,
+				conf.SocketOptions{},
 				false,
 				false,
 				"",
@@ -315,6 +688,11 @@ func (p *Core) createResources(initial bool) error {
 				true,
 				p.conf.ServerCert,
 				p.conf.ServerKey,
+				p.acmeManager,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
+				"",
 				p.conf.RTSPAddress,
 				p.conf.Protocols,
 				p.conf.RunOnConnect,
@@ -322,6 +700,22 @@ func (p *Core) createResources(initial bool) error {
 				p.externalCmdPool,
 				p.metrics,
 				p.pathManager,
+				&coreLogModule{module: "rtsp", p: p},
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.conf.MDNS && p.rtspServer != nil {
+		if p.mdnsAdvertiser == nil {
+			p.mdnsAdvertiser, err = newMDNSAdvertiser(
+				p.ctx,
+				p.conf.MDNSHostName,
+				p.conf.RTSPAddress,
+				p.events,
+				p.pathManager,
 				p,
 			)
 			if err != nil {
@@ -337,20 +731,29 @@ func (p *Core) createResources(initial bool) error {
 			p.rtmpServer, err = newRTMPServer(
 				p.ctx,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
+				p.conf.AuthPauseAfterError,
 				p.conf.RTMPAddress,
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTMPIPFilter,
+				p.conf.RTMPSocketOptions,
 				false,
 				"",
 				"",
+				nil,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
 				p.conf.RTSPAddress,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
 				p.externalCmdPool,
 				p.metrics,
 				p.pathManager,
-				p,
+				&coreLogModule{module: "rtmp", p: p},
 			)
 			if err != nil {
 				return err
@@ -365,20 +768,29 @@ func (p *Core) createResources(initial bool) error {
 			p.rtmpsServer, err = newRTMPServer(
 				p.ctx,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
+				p.conf.AuthPauseAfterError,
 				p.conf.RTMPSAddress,
 				p.conf.ReadTimeout,
 				p.conf.WriteTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.RTMPIPFilter,
+				p.conf.RTMPSocketOptions,
 				true,
 				p.conf.RTMPServerCert,
 				p.conf.RTMPServerKey,
+				p.acmeManager,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
 				p.conf.RTSPAddress,
 				p.conf.RunOnConnect,
 				p.conf.RunOnConnectRestart,
 				p.externalCmdPool,
 				p.metrics,
 				p.pathManager,
-				p,
+				&coreLogModule{module: "rtmp", p: p},
 			)
 			if err != nil {
 				return err
@@ -387,28 +799,59 @@ func (p *Core) createResources(initial bool) error {
 	}
 
 	if !p.conf.HLSDisable {
+		// revoked tokens survive the HLS server, that is recreated when the configuration changes.
+		if p.hlsRevokedTokens == nil {
+			p.hlsRevokedTokens = newHLSRevokedTokens()
+		}
+
 		if p.hlsServer == nil {
 			p.hlsServer, err = newHLSServer(
 				p.ctx,
 				p.conf.HLSAddress,
+				p.conf.HLSIPFilter,
+				p.conf.HLSSocketOptions,
 				p.conf.HLSEncryption,
 				p.conf.HLSServerKey,
 				p.conf.HLSServerCert,
+				p.acmeManager,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
+				p.conf.HLSTokenSecret,
+				p.hlsRevokedTokens,
+				p.conf.HLSAuthRealm,
+				p.conf.HLSAuthErrorBody,
 				p.conf.HLSAlwaysRemux,
 				p.conf.HLSVariant,
 				p.conf.HLSSegmentCount,
 				p.conf.HLSSegmentDuration,
 				p.conf.HLSPartDuration,
 				p.conf.HLSSegmentMaxSize,
-				p.conf.HLSAllowOrigin,
+				p.conf.HLSPlaylistLength,
+				p.conf.HLSSegmentPush,
+				p.conf.HLSPreloadHints,
+				p.conf.HLSAllowOrigins,
+				p.conf.HLSAllowHeaders,
+				p.conf.HLSAllowCredentials,
+				p.conf.HLSPlaylistCacheControl,
+				p.conf.HLSSegmentCacheControl,
 				p.conf.HLSTrustedProxies,
+				p.conf.HLSBaseURL,
 				p.conf.HLSDirectory,
+				p.conf.HLSIndexFile,
+				p.conf.HLSPosterURL,
+				p.conf.HLSStaticDirectory,
 				p.conf.ReadTimeout,
 				p.conf.ReadBufferCount,
+				p.conf.HLSMuxerCloseAfter,
+				p.conf.HLSMuxerCheckPeriod,
+				p.conf.PathGroupSuffixes,
 				p.pathManager,
 				p.metrics,
-				p,
+				&coreLogModule{module: "hls", p: p},
 			)
 			if err != nil {
 				return err
@@ -421,10 +864,15 @@ func (p *Core) createResources(initial bool) error {
 			p.webRTCServer, err = newWebRTCServer(
 				p.ctx,
 				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
 				p.conf.WebRTCAddress,
 				p.conf.WebRTCEncryption,
 				p.conf.WebRTCServerKey,
 				p.conf.WebRTCServerCert,
+				p.conf.TLSMinVersion,
+				p.conf.TLSCipherSuites,
+				p.conf.TLSLogHandshakes,
 				p.conf.WebRTCAllowOrigin,
 				p.conf.WebRTCTrustedProxies,
 				p.conf.WebRTCICEServers,
@@ -432,7 +880,7 @@ func (p *Core) createResources(initial bool) error {
 				p.conf.ReadBufferCount,
 				p.pathManager,
 				p.metrics,
-				p,
+				&coreLogModule{module: "webrtc", p: p},
 				p.conf.WebRTCICEHostNAT1To1IPs,
 				p.conf.WebRTCICEUDPMuxAddress,
 				p.conf.WebRTCICETCPMuxAddress,
@@ -443,11 +891,50 @@ func (p *Core) createResources(initial bool) error {
 		}
 	}
 
+	if p.conf.HTTPIngest {
+		if p.httpIngestServer == nil {
+			p.httpIngestServer, err = newHTTPIngestServer(
+				p.ctx,
+				p.conf.HTTPIngestAddress,
+				p.conf.HTTPIngestTrustedProxies,
+				p.conf.ExternalAuthenticationURL,
+				p.authBanList,
+				p.accessList,
+				p.conf.AuthPauseAfterError,
+				p.conf.ReadTimeout,
+				p.pathManager,
+				p,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.conf.StatsFile != "" {
+		if p.statsStore == nil {
+			p.statsStore, err = newStatsStore(
+				p.ctx,
+				p.conf.StatsFile,
+				p.pathManager,
+				p.events,
+				p.metrics,
+				p,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	if p.conf.API {
 		if p.api == nil {
 			p.api, err = newAPI(
 				p.conf.APIAddress,
+				p.conf.UnixSocketPermissions,
 				p.conf.ReadTimeout,
+				p.conf.APIUser,
+				p.conf.APIPass,
 				p.conf,
 				p.pathManager,
 				p.rtspServer,
@@ -456,6 +943,33 @@ func (p *Core) createResources(initial bool) error {
 				p.rtmpsServer,
 				p.hlsServer,
 				p.webRTCServer,
+				p.httpIngestServer,
+				p.authBanList,
+				p.events,
+				p.metrics,
+				p,
+			)
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	if p.conf.GRPCAPI {
+		if p.grpcAPI == nil {
+			p.grpcAPI, err = newGRPCAPI(
+				p.conf.GRPCAPIAddress,
+				p.conf.UnixSocketPermissions,
+				p.conf.APIUser,
+				p.conf.APIPass,
+				p.conf,
+				p.pathManager,
+				p.rtspServer,
+				p.rtspsServer,
+				p.rtmpServer,
+				p.rtmpsServer,
+				p.webRTCServer,
+				p.events,
 				p,
 			)
 			if err != nil {
@@ -482,11 +996,36 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 	closeMetrics := newConf == nil ||
 		newConf.Metrics != p.conf.Metrics ||
 		newConf.MetricsAddress != p.conf.MetricsAddress ||
+		!reflect.DeepEqual(newConf.MetricsPathsExclude, p.conf.MetricsPathsExclude) ||
+		!reflect.DeepEqual(newConf.MetricsPathsAggregate, p.conf.MetricsPathsAggregate) ||
+		newConf.UnixSocketPermissions != p.conf.UnixSocketPermissions ||
 		newConf.ReadTimeout != p.conf.ReadTimeout
 
 	closePPROF := newConf == nil ||
 		newConf.PPROF != p.conf.PPROF ||
 		newConf.PPROFAddress != p.conf.PPROFAddress ||
+		newConf.APIUser != p.conf.APIUser ||
+		newConf.APIPass != p.conf.APIPass ||
+		newConf.UnixSocketPermissions != p.conf.UnixSocketPermissions ||
+		newConf.ReadTimeout != p.conf.ReadTimeout
+
+	closeAuthBanList := newConf == nil ||
+		newConf.AuthBanAttempts != p.conf.AuthBanAttempts ||
+		newConf.AuthBanDuration != p.conf.AuthBanDuration
+
+	closeAccessList := newConf == nil ||
+		!reflect.DeepEqual(newConf.AllowedReadIPs, p.conf.AllowedReadIPs) ||
+		!reflect.DeepEqual(newConf.AllowedPublishIPs, p.conf.AllowedPublishIPs) ||
+		newConf.GeoIPDatabase != p.conf.GeoIPDatabase ||
+		!reflect.DeepEqual(newConf.GeoIPAllowedCountries, p.conf.GeoIPAllowedCountries) ||
+		!reflect.DeepEqual(newConf.GeoIPDeniedCountries, p.conf.GeoIPDeniedCountries)
+
+	closeACMEManager := newConf == nil ||
+		!reflect.DeepEqual(newConf.ACMEDomains, p.conf.ACMEDomains) ||
+		newConf.ACMEEmail != p.conf.ACMEEmail ||
+		newConf.ACMECacheDir != p.conf.ACMECacheDir ||
+		newConf.ACMEDirectoryURL != p.conf.ACMEDirectoryURL ||
+		newConf.ACMEHTTPAddress != p.conf.ACMEHTTPAddress ||
 		newConf.ReadTimeout != p.conf.ReadTimeout
 
 	closePathManager := newConf == nil ||
@@ -495,26 +1034,49 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
 		newConf.UDPMaxPayloadSize != p.conf.UDPMaxPayloadSize ||
+		!reflect.DeepEqual(newConf.Tenants, p.conf.Tenants) ||
+		!reflect.DeepEqual(newConf.PublishPathRules, p.conf.PublishPathRules) ||
+		newConf.SourceStartConcurrency != p.conf.SourceStartConcurrency ||
 		closeMetrics
 	if !closePathManager && !reflect.DeepEqual(newConf.Paths, p.conf.Paths) {
 		p.pathManager.confReload(newConf.Paths)
 	}
 
+	closeClusterEdge := newConf == nil ||
+		newConf.ClusterOriginAPIURL != p.conf.ClusterOriginAPIURL ||
+		newConf.ClusterOriginRTSPURL != p.conf.ClusterOriginRTSPURL ||
+		newConf.ClusterPathPrefix != p.conf.ClusterPathPrefix ||
+		newConf.ClusterPollPeriod != p.conf.ClusterPollPeriod ||
+		newConf.ClusterAuthPassThrough != p.conf.ClusterAuthPassThrough ||
+		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		closePathManager
+
 	closeRTSPServer := newConf == nil ||
 		newConf.RTSPDisable != p.conf.RTSPDisable ||
 		newConf.Encryption != p.conf.Encryption ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		closeAuthBanList ||
+		closeAccessList ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.AuthMethods, p.conf.AuthMethods) ||
+		newConf.AuthPauseAfterError != p.conf.AuthPauseAfterError ||
+		newConf.RTSPAuthRealm != p.conf.RTSPAuthRealm ||
+		newConf.RTSPAuthStatusText != p.conf.RTSPAuthStatusText ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
+		newConf.RTSPSessionTimeout != p.conf.RTSPSessionTimeout ||
+		newConf.RTSPSessionTimeoutTCP != p.conf.RTSPSessionTimeoutTCP ||
+		!reflect.DeepEqual(newConf.RTSPIPFilter, p.conf.RTSPIPFilter) ||
+		newConf.RTSPSocketOptions != p.conf.RTSPSocketOptions ||
+		newConf.RTPSocketOptions != p.conf.RTPSocketOptions ||
 		!reflect.DeepEqual(newConf.Protocols, p.conf.Protocols) ||
 		newConf.RTPAddress != p.conf.RTPAddress ||
 		newConf.RTCPAddress != p.conf.RTCPAddress ||
 		newConf.MulticastIPRange != p.conf.MulticastIPRange ||
 		newConf.MulticastRTPPort != p.conf.MulticastRTPPort ||
 		newConf.MulticastRTCPPort != p.conf.MulticastRTCPPort ||
+		newConf.RTSPTunnelAddress != p.conf.RTSPTunnelAddress ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
 		!reflect.DeepEqual(newConf.Protocols, p.conf.Protocols) ||
 		newConf.RunOnConnect != p.conf.RunOnConnect ||
@@ -522,15 +1084,31 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeMetrics ||
 		closePathManager
 
+	closeTLSPolicy := newConf == nil ||
+		newConf.TLSMinVersion != p.conf.TLSMinVersion ||
+		!reflect.DeepEqual(newConf.TLSCipherSuites, p.conf.TLSCipherSuites) ||
+		newConf.TLSLogHandshakes != p.conf.TLSLogHandshakes
+
 	closeRTSPSServer := newConf == nil ||
+		closeACMEManager ||
+		closeTLSPolicy ||
 		newConf.RTSPDisable != p.conf.RTSPDisable ||
 		newConf.Encryption != p.conf.Encryption ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		closeAuthBanList ||
+		closeAccessList ||
 		newConf.RTSPSAddress != p.conf.RTSPSAddress ||
 		!reflect.DeepEqual(newConf.AuthMethods, p.conf.AuthMethods) ||
+		newConf.AuthPauseAfterError != p.conf.AuthPauseAfterError ||
+		newConf.RTSPAuthRealm != p.conf.RTSPAuthRealm ||
+		newConf.RTSPAuthStatusText != p.conf.RTSPAuthStatusText ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
+		newConf.RTSPSessionTimeout != p.conf.RTSPSessionTimeout ||
+		newConf.RTSPSessionTimeoutTCP != p.conf.RTSPSessionTimeoutTCP ||
+		!reflect.DeepEqual(newConf.RTSPIPFilter, p.conf.RTSPIPFilter) ||
+		newConf.RTSPSocketOptions != p.conf.RTSPSocketOptions ||
 		newConf.ServerCert != p.conf.ServerCert ||
 		newConf.ServerKey != p.conf.ServerKey ||
 		newConf.RTSPAddress != p.conf.RTSPAddress ||
@@ -540,11 +1118,22 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closeMetrics ||
 		closePathManager
 
+	closeMDNSAdvertiser := newConf == nil ||
+		newConf.MDNS != p.conf.MDNS ||
+		newConf.MDNSHostName != p.conf.MDNSHostName ||
+		closeRTSPServer ||
+		closePathManager
+
 	closeRTMPServer := newConf == nil ||
 		newConf.RTMPDisable != p.conf.RTMPDisable ||
 		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
 		newConf.RTMPAddress != p.conf.RTMPAddress ||
+		!reflect.DeepEqual(newConf.RTMPIPFilter, p.conf.RTMPIPFilter) ||
+		newConf.RTMPSocketOptions != p.conf.RTMPSocketOptions ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		newConf.AuthPauseAfterError != p.conf.AuthPauseAfterError ||
+		closeAuthBanList ||
+		closeAccessList ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
@@ -555,10 +1144,17 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closePathManager
 
 	closeRTMPSServer := newConf == nil ||
+		closeACMEManager ||
+		closeTLSPolicy ||
 		newConf.RTMPDisable != p.conf.RTMPDisable ||
 		newConf.RTMPEncryption != p.conf.RTMPEncryption ||
 		newConf.RTMPSAddress != p.conf.RTMPSAddress ||
+		!reflect.DeepEqual(newConf.RTMPIPFilter, p.conf.RTMPIPFilter) ||
+		newConf.RTMPSocketOptions != p.conf.RTMPSocketOptions ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		newConf.AuthPauseAfterError != p.conf.AuthPauseAfterError ||
+		closeAuthBanList ||
+		closeAccessList ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.WriteTimeout != p.conf.WriteTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
@@ -571,29 +1167,55 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		closePathManager
 
 	closeHLSServer := newConf == nil ||
+		closeACMEManager ||
+		closeTLSPolicy ||
 		newConf.HLSDisable != p.conf.HLSDisable ||
 		newConf.HLSAddress != p.conf.HLSAddress ||
+		!reflect.DeepEqual(newConf.HLSIPFilter, p.conf.HLSIPFilter) ||
+		newConf.HLSSocketOptions != p.conf.HLSSocketOptions ||
 		newConf.HLSEncryption != p.conf.HLSEncryption ||
 		newConf.HLSServerKey != p.conf.HLSServerKey ||
 		newConf.HLSServerCert != p.conf.HLSServerCert ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		closeAuthBanList ||
+		closeAccessList ||
+		newConf.HLSTokenSecret != p.conf.HLSTokenSecret ||
+		newConf.HLSAuthRealm != p.conf.HLSAuthRealm ||
+		newConf.HLSAuthErrorBody != p.conf.HLSAuthErrorBody ||
 		newConf.HLSAlwaysRemux != p.conf.HLSAlwaysRemux ||
 		newConf.HLSVariant != p.conf.HLSVariant ||
 		newConf.HLSSegmentCount != p.conf.HLSSegmentCount ||
 		newConf.HLSSegmentDuration != p.conf.HLSSegmentDuration ||
 		newConf.HLSPartDuration != p.conf.HLSPartDuration ||
 		newConf.HLSSegmentMaxSize != p.conf.HLSSegmentMaxSize ||
-		newConf.HLSAllowOrigin != p.conf.HLSAllowOrigin ||
+		newConf.HLSPlaylistLength != p.conf.HLSPlaylistLength ||
+		newConf.HLSSegmentPush != p.conf.HLSSegmentPush ||
+		newConf.HLSPreloadHints != p.conf.HLSPreloadHints ||
+		!reflect.DeepEqual(newConf.HLSAllowOrigins, p.conf.HLSAllowOrigins) ||
+		!reflect.DeepEqual(newConf.HLSAllowHeaders, p.conf.HLSAllowHeaders) ||
+		newConf.HLSAllowCredentials != p.conf.HLSAllowCredentials ||
+		newConf.HLSPlaylistCacheControl != p.conf.HLSPlaylistCacheControl ||
+		newConf.HLSSegmentCacheControl != p.conf.HLSSegmentCacheControl ||
 		!reflect.DeepEqual(newConf.HLSTrustedProxies, p.conf.HLSTrustedProxies) ||
+		newConf.HLSBaseURL != p.conf.HLSBaseURL ||
 		newConf.HLSDirectory != p.conf.HLSDirectory ||
+		newConf.HLSIndexFile != p.conf.HLSIndexFile ||
+		newConf.HLSPosterURL != p.conf.HLSPosterURL ||
+		newConf.HLSStaticDirectory != p.conf.HLSStaticDirectory ||
+		newConf.HLSMuxerCloseAfter != p.conf.HLSMuxerCloseAfter ||
+		newConf.HLSMuxerCheckPeriod != p.conf.HLSMuxerCheckPeriod ||
+		!reflect.DeepEqual(newConf.PathGroupSuffixes, p.conf.PathGroupSuffixes) ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		newConf.ReadBufferCount != p.conf.ReadBufferCount ||
 		closePathManager ||
 		closeMetrics
 
 	closeWebRTCServer := newConf == nil ||
+		closeTLSPolicy ||
 		newConf.WebRTCDisable != p.conf.WebRTCDisable ||
 		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		closeAuthBanList ||
+		closeAccessList ||
 		newConf.WebRTCAddress != p.conf.WebRTCAddress ||
 		newConf.WebRTCEncryption != p.conf.WebRTCEncryption ||
 		newConf.WebRTCServerKey != p.conf.WebRTCServerKey ||
@@ -609,15 +1231,51 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		newConf.WebRTCICEUDPMuxAddress != p.conf.WebRTCICEUDPMuxAddress ||
 		newConf.WebRTCICETCPMuxAddress != p.conf.WebRTCICETCPMuxAddress
 
+	closeHTTPIngestServer := newConf == nil ||
+		newConf.HTTPIngest != p.conf.HTTPIngest ||
+		newConf.HTTPIngestAddress != p.conf.HTTPIngestAddress ||
+		!reflect.DeepEqual(newConf.HTTPIngestTrustedProxies, p.conf.HTTPIngestTrustedProxies) ||
+		newConf.ExternalAuthenticationURL != p.conf.ExternalAuthenticationURL ||
+		newConf.AuthPauseAfterError != p.conf.AuthPauseAfterError ||
+		closeAuthBanList ||
+		closeAccessList ||
+		newConf.ReadTimeout != p.conf.ReadTimeout ||
+		closePathManager
+
+	closeStatsStore := newConf == nil ||
+		newConf.StatsFile != p.conf.StatsFile ||
+		closeMetrics ||
+		closePathManager
+
 	closeAPI := newConf == nil ||
 		newConf.API != p.conf.API ||
 		newConf.APIAddress != p.conf.APIAddress ||
+		newConf.APIUser != p.conf.APIUser ||
+		newConf.APIPass != p.conf.APIPass ||
+		newConf.UnixSocketPermissions != p.conf.UnixSocketPermissions ||
 		newConf.ReadTimeout != p.conf.ReadTimeout ||
 		closePathManager ||
 		closeRTSPServer ||
 		closeRTSPSServer ||
 		closeRTMPServer ||
 		closeHLSServer ||
+		closeWebRTCServer ||
+		closeHTTPIngestServer ||
+		closeAuthBanList ||
+		closeMetrics
+
+	closeGRPCAPI := newConf == nil ||
+		newConf.GRPCAPI != p.conf.GRPCAPI ||
+		newConf.GRPCAPIAddress != p.conf.GRPCAPIAddress ||
+		newConf.APIUser != p.conf.APIUser ||
+		newConf.APIPass != p.conf.APIPass ||
+		!reflect.DeepEqual(newConf.Tenants, p.conf.Tenants) ||
+		newConf.UnixSocketPermissions != p.conf.UnixSocketPermissions ||
+		closePathManager ||
+		closeRTSPServer ||
+		closeRTSPSServer ||
+		closeRTMPServer ||
+		closeRTMPSServer ||
 		closeWebRTCServer
 
 	if newConf == nil && p.confWatcher != nil {
@@ -634,6 +1292,25 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		}
 	}
 
+	if p.grpcAPI != nil {
+		if closeGRPCAPI {
+			p.grpcAPI.close()
+			p.grpcAPI = nil
+		} else if !calledByAPI { // avoid a loop
+			p.grpcAPI.confReload(newConf)
+		}
+	}
+
+	if closeStatsStore && p.statsStore != nil {
+		p.statsStore.close()
+		p.statsStore = nil
+	}
+
+	if closeMDNSAdvertiser && p.mdnsAdvertiser != nil {
+		p.mdnsAdvertiser.close()
+		p.mdnsAdvertiser = nil
+	}
+
 	if closeRTSPSServer && p.rtspsServer != nil {
 		p.rtspsServer.close()
 		p.rtspsServer = nil
@@ -644,6 +1321,16 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.rtspServer = nil
 	}
 
+	if closeHTTPIngestServer && p.httpIngestServer != nil {
+		p.httpIngestServer.close()
+		p.httpIngestServer = nil
+	}
+
+	if closeClusterEdge && p.clusterEdge != nil {
+		p.clusterEdge.close()
+		p.clusterEdge = nil
+	}
+
 	if closePathManager && p.pathManager != nil {
 		p.pathManager.close()
 		p.pathManager = nil
@@ -669,6 +1356,21 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 		p.rtmpServer = nil
 	}
 
+	if closeAccessList && p.accessList != nil {
+		p.accessList.close()
+		p.accessList = nil
+	}
+
+	if closeACMEManager && p.acmeManager != nil {
+		p.acmeManager.close()
+		p.acmeManager = nil
+	}
+
+	if closeAuthBanList && p.authBanList != nil {
+		p.authBanList.close()
+		p.authBanList = nil
+	}
+
 	if closePPROF && p.pprof != nil {
 		p.pprof.close()
 		p.pprof = nil
@@ -697,7 +1399,134 @@ func (p *Core) closeResources(newConf *conf.Conf, calledByAPI bool) {
 func (p *Core) reloadConf(newConf *conf.Conf, calledByAPI bool) error {
 	p.closeResources(newConf, calledByAPI)
 	p.conf = newConf
-	return p.createResources(false)
+
+	err := p.createResources(false)
+	if err != nil {
+		return err
+	}
+
+	p.events.publish(apiEvent{Type: apiEventConfReload, Time: time.Now()})
+	return nil
+}
+
+// persistPaths writes paths changed by the API into the configuration file
+// or into the file dedicated to persisted paths.
+func (p *Core) persistPaths(oldConf *conf.Conf) {
+	if !p.conf.PersistAPIPaths {
+		return
+	}
+
+	changed := conf.ChangedPaths(oldConf, p.conf)
+	if len(changed) == 0 {
+		return
+	}
+
+	fpath := p.conf.PersistAPIPathsFile
+	overrides := (fpath != "")
+
+	if !overrides {
+		if p.confPath == "" {
+			p.Log(logger.Warn, "paths can't be persisted since there's no configuration file")
+			return
+		}
+		fpath = p.confPath
+	}
+
+	byts, err := conf.PersistPaths(fpath, overrides, p.conf, changed)
+	if err != nil {
+		p.Log(logger.Warn, "unable to persist paths: %s", err)
+		return
+	}
+
+	if !overrides {
+		p.persistedConf = byts
+	}
+
+	p.Log(logger.Info, "%d path(s) persisted into %s", len(changed), fpath)
+}
+
+func (p *Core) confWithPathAdded(name string, pathConf *conf.PathConf) (*conf.Conf, error) {
+	newConf := p.conf.Clone()
+
+	if _, ok := newConf.Paths[name]; ok {
+		return nil, fmt.Errorf("path '%s' already exists", name)
+	}
+
+	newConf.Paths[name] = pathConf.Clone()
+
+	err := newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
+func (p *Core) confWithPathRemoved(name string) (*conf.Conf, error) {
+	newConf := p.conf.Clone()
+
+	if _, ok := newConf.Paths[name]; !ok {
+		return nil, fmt.Errorf("path '%s' not found", name)
+	}
+
+	delete(newConf.Paths, name)
+
+	err := newConf.CheckAndFillMissing()
+	if err != nil {
+		return nil, err
+	}
+
+	return newConf, nil
+}
+
+// AddPath adds a path configuration to a running Core.
+func (p *Core) AddPath(name string, pathConf *conf.PathConf) error {
+	req := coreAddPathReq{
+		name:     name,
+		pathConf: pathConf,
+		res:      make(chan error),
+	}
+
+	select {
+	case p.chAddPath <- req:
+		return <-req.res
+	case <-p.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// RemovePath removes a path configuration from a running Core.
+func (p *Core) RemovePath(name string) error {
+	req := coreRemovePathReq{
+		name: name,
+		res:  make(chan error),
+	}
+
+	select {
+	case p.chRemovePath <- req:
+		return <-req.res
+	case <-p.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+func (p *Core) getPathManager() (*pathManager, error) {
+	res := make(chan *pathManager)
+
+	select {
+	case p.chGetPathManager <- res:
+		return <-res, nil
+	case <-p.ctx.Done():
+		return nil, fmt.Errorf("terminated")
+	}
+}
+
+// grpcAPIConfigSet is called by grpcAPI.
+func (p *Core) grpcAPIConfigSet(conf *conf.Conf) {
+	select {
+	case p.chGRPCAPIConfigSet <- conf:
+	case <-p.ctx.Done():
+	}
 }
 
 // apiConfigSet is called by api.
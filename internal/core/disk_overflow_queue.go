@@ -0,0 +1,215 @@
+package core
+
+import (
+	"encoding/binary"
+	"os"
+	"sync"
+)
+
+type diskOverflowQueueStats struct {
+	// number of items in memory.
+	MemoryItems int `json:"memoryItems"`
+	// size of items that are waiting on disk.
+	DiskBytes uint64 `json:"diskBytes"`
+	// maximum size reached by items on disk.
+	MaxDiskBytes uint64 `json:"maxDiskBytes"`
+	// number of times the queue has overflowed to disk.
+	Overflows uint64 `json:"overflows"`
+	// number of items that were discarded since the disk queue was full.
+	Dropped uint64 `json:"dropped"`
+}
+
+// diskOverflowQueue is a FIFO queue that stores items in memory and,
+// when memory is full, moves them into a temporary file, in order not to lose them
+// when the consumer is slower than the producer for a short period.
+// When the file is full too, new items are discarded.
+type diskOverflowQueue struct {
+	memSize   int
+	dir       string
+	maxSize   uint64
+	marshal   func(interface{}) ([]byte, error)
+	unmarshal func([]byte) (interface{}, error)
+
+	mutex    sync.Mutex
+	cond     *sync.Cond
+	closed   bool
+	mem      []interface{}
+	file     *os.File
+	writeOff int64
+	readOff  int64
+	stats    diskOverflowQueueStats
+}
+
+func newDiskOverflowQueue(
+	memSize int,
+	dir string,
+	maxSize uint64,
+	marshal func(interface{}) ([]byte, error),
+	unmarshal func([]byte) (interface{}, error),
+) *diskOverflowQueue {
+	q := &diskOverflowQueue{
+		memSize:   memSize,
+		dir:       dir,
+		maxSize:   maxSize,
+		marshal:   marshal,
+		unmarshal: unmarshal,
+	}
+	q.cond = sync.NewCond(&q.mutex)
+	return q
+}
+
+// close closes the queue and removes the temporary file.
+func (q *diskOverflowQueue) close() {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	q.closed = true
+	q.mem = nil
+	q.removeFile()
+	q.cond.Broadcast()
+}
+
+func (q *diskOverflowQueue) removeFile() {
+	if q.file != nil {
+		q.file.Close()
+		os.Remove(q.file.Name())
+		q.file = nil
+		q.writeOff = 0
+		q.readOff = 0
+		q.stats.DiskBytes = 0
+	}
+}
+
+// push appends an item to the queue.
+func (q *diskOverflowQueue) push(item interface{}) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	if q.closed {
+		return
+	}
+
+	// items are written to disk until the file is emptied, in order to preserve their order.
+	if q.file == nil && len(q.mem) < q.memSize {
+		q.mem = append(q.mem, item)
+		q.cond.Signal()
+		return
+	}
+
+	if !q.writeToDisk(item) {
+		q.stats.Dropped++
+		return
+	}
+
+	q.cond.Signal()
+}
+
+func (q *diskOverflowQueue) writeToDisk(item interface{}) bool {
+	byts, err := q.marshal(item)
+	if err != nil {
+		return false
+	}
+
+	size := uint64(4 + len(byts))
+
+	if q.stats.DiskBytes+size > q.maxSize {
+		return false
+	}
+
+	if q.file == nil {
+		f, err := os.CreateTemp(q.dir, "overflow_*.bin")
+		if err != nil {
+			return false
+		}
+		q.file = f
+		q.stats.Overflows++
+	}
+
+	buf := make([]byte, size)
+	binary.BigEndian.PutUint32(buf, uint32(len(byts)))
+	copy(buf[4:], byts)
+
+	_, err = q.file.WriteAt(buf, q.writeOff)
+	if err != nil {
+		return false
+	}
+
+	q.writeOff += int64(size)
+	q.stats.DiskBytes += size
+	if q.stats.DiskBytes > q.stats.MaxDiskBytes {
+		q.stats.MaxDiskBytes = q.stats.DiskBytes
+	}
+
+	return true
+}
+
+// pull removes the oldest item from the queue.
+// It blocks until an item is available or the queue is closed.
+func (q *diskOverflowQueue) pull() (interface{}, bool) {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	for {
+		if q.closed {
+			return nil, false
+		}
+
+		if len(q.mem) != 0 {
+			item := q.mem[0]
+			q.mem[0] = nil
+			q.mem = q.mem[1:]
+			return item, true
+		}
+
+		if q.file != nil {
+			item, ok := q.readFromDisk()
+			if ok {
+				return item, true
+			}
+			continue
+		}
+
+		q.cond.Wait()
+	}
+}
+
+func (q *diskOverflowQueue) readFromDisk() (interface{}, bool) {
+	var header [4]byte
+	_, err := q.file.ReadAt(header[:], q.readOff)
+	if err != nil {
+		q.removeFile()
+		return nil, false
+	}
+
+	buf := make([]byte, binary.BigEndian.Uint32(header[:]))
+	_, err = q.file.ReadAt(buf, q.readOff+4)
+	if err != nil {
+		q.removeFile()
+		return nil, false
+	}
+
+	size := uint64(4 + len(buf))
+	q.readOff += int64(size)
+	q.stats.DiskBytes -= size
+
+	// the file is empty, switch back to memory.
+	if q.readOff == q.writeOff {
+		q.removeFile()
+	}
+
+	item, err := q.unmarshal(buf)
+	if err != nil {
+		return nil, false
+	}
+
+	return item, true
+}
+
+func (q *diskOverflowQueue) safeStats() diskOverflowQueueStats {
+	q.mutex.Lock()
+	defer q.mutex.Unlock()
+
+	stats := q.stats
+	stats.MemoryItems = len(q.mem)
+	return stats
+}
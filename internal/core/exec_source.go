@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asticode/go-astits"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/externalcmd"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	execSourceMinBackoff = 1 * time.Second
+	execSourceMaxBackoff = 60 * time.Second
+)
+
+type execSourceParent interface {
+	logger.Writer
+	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
+	sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq)
+}
+
+// execSource runs a command and reads a MPEG-TS stream from its standard output.
+type execSource struct {
+	readTimeout     conf.StringDuration
+	externalCmdPool *externalcmd.Pool
+	parent          execSourceParent
+
+	// additional pause before restarting a command that
+	// exited without producing a stream. It is doubled after every failure.
+	backoff time.Duration
+}
+
+func newExecSource(
+	readTimeout conf.StringDuration,
+	externalCmdPool *externalcmd.Pool,
+	parent execSourceParent,
+) *execSource {
+	return &execSource{
+		readTimeout:     readTimeout,
+		externalCmdPool: externalCmdPool,
+		parent:          parent,
+	}
+}
+
+func (s *execSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[exec source] "+format, args...)
+}
+
+// run implements sourceStaticImpl.
+func (s *execSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
+	if s.backoff != 0 {
+		s.Log(logger.Info, "waiting %v before restarting the command", s.backoff)
+
+		select {
+		case <-time.After(s.backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("terminated")
+		}
+	}
+
+	ready := false
+	defer func() {
+		switch {
+		case ready:
+			s.backoff = 0
+		case s.backoff == 0:
+			s.backoff = execSourceMinBackoff
+		default:
+			s.backoff *= 2
+			if s.backoff > execSourceMaxBackoff {
+				s.backoff = execSourceMaxBackoff
+			}
+		}
+	}()
+
+	pr, pw, err := os.Pipe()
+	if err != nil {
+		return err
+	}
+	defer pr.Close()
+	defer pw.Close()
+
+	cmdExited := make(chan int, 1)
+
+	s.Log(logger.Info, "command started")
+	cmd := externalcmd.NewCmdWithStdout(
+		s.externalCmdPool,
+		cnf.Source[len("exec://"):],
+		false,
+		externalcmd.Environment{},
+		pw,
+		func(co int) {
+			cmdExited <- co
+		})
+	defer cmd.Close()
+
+	dem := astits.NewDemuxer(
+		context.Background(),
+		pr,
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+	readerErr := make(chan error)
+
+	go func() {
+		readerErr <- func() error {
+			pr.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
+			tracks, err := mpegtsFindTracks(dem)
+			if err != nil {
+				return err
+			}
+
+			medias, writers := mpegtsMedias(tracks, s)
+
+			res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+				medias:             medias,
+				generateRTPPackets: true,
+			})
+			if res.err != nil {
+				return res.err
+			}
+
+			defer func() {
+				s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+			}()
+
+			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
+			ready = true
+
+			return mpegtsReadData(dem, res.stream, writers, func() {
+				pr.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
+			})
+		}()
+	}()
+
+	select {
+	case err := <-readerErr:
+		return err
+
+	case co := <-cmdExited:
+		pr.Close()
+		<-readerErr
+		return fmt.Errorf("command exited with code %d", co)
+
+	case <-ctx.Done():
+		pr.Close()
+		<-readerErr
+		return fmt.Errorf("terminated")
+	}
+}
+
+// apiSourceDescribe implements sourceStaticImpl.
+func (*execSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"execSource"}
+}
@@ -16,6 +16,7 @@ const (
 	externalAuthProtoRTMP   externalAuthProto = "rtmp"
 	externalAuthProtoHLS    externalAuthProto = "hls"
 	externalAuthProtoWebRTC externalAuthProto = "webrtc"
+	externalAuthProtoHTTP   externalAuthProto = "http"
 )
 
 func externalAuth(
@@ -0,0 +1,377 @@
+package core
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/asticode/go-astits"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+var errFileSourceEnded = errors.New("end of file reached")
+
+type fileSourceParent interface {
+	logger.Writer
+	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
+	sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq)
+}
+
+// fileSource reads a MPEG-TS or MP4 file, from the disk or from an object storage
+// compatible with AWS S3, and publishes it in real time, once or in a loop.
+type fileSource struct {
+	parent fileSourceParent
+}
+
+func newFileSource(
+	parent fileSourceParent,
+) *fileSource {
+	return &fileSource{
+		parent: parent,
+	}
+}
+
+func (s *fileSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[file source] "+format, args...)
+}
+
+type readSeekCloser interface {
+	io.ReadSeeker
+	io.Closer
+}
+
+func openFileSource(ctx context.Context, cnf *conf.PathConf) (readSeekCloser, error) {
+	if strings.HasPrefix(cnf.Source, "s3://") {
+		return newS3Reader(ctx, cnf.Source, cnf.SourceS3Endpoint, cnf.SourceS3Region)
+	}
+
+	// values of query templates are escaped.
+	fpath, err := url.PathUnescape(cnf.Source[len("file://"):])
+	if err != nil {
+		return nil, fmt.Errorf("'%s' is not a valid file URL", cnf.Source)
+	}
+
+	if fileSourceHasParentDir(fpath) {
+		return nil, fmt.Errorf("'%s' is not a valid file URL", cnf.Source)
+	}
+
+	return os.Open(fpath)
+}
+
+// fileSourceHasParentDir checks whether a path refers to a parent directory,
+// in order to prevent query templates from reading files outside of the intended directory.
+func fileSourceHasParentDir(fpath string) bool {
+	for _, elem := range strings.Split(fpath, "/") {
+		if elem == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// fileSourceIsMP4 checks whether a file is a MP4 file, by reading the type of its first box.
+func fileSourceIsMP4(r io.ReadSeeker) (bool, error) {
+	buf := make([]byte, 8)
+	_, err := io.ReadFull(r, buf)
+	if err != nil {
+		return false, err
+	}
+
+	_, err = r.Seek(0, io.SeekStart)
+	if err != nil {
+		return false, err
+	}
+
+	switch string(buf[4:]) {
+	case "ftyp", "moov", "mdat", "free", "skip", "wide":
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// fileSourcePacer waits until the time of a frame is reached,
+// in order to publish a file in real time.
+type fileSourcePacer struct {
+	ctx   context.Context
+	start time.Time
+}
+
+func newFileSourcePacer(ctx context.Context) *fileSourcePacer {
+	return &fileSourcePacer{
+		ctx:   ctx,
+		start: time.Now(),
+	}
+}
+
+func (p *fileSourcePacer) wait(t time.Duration) error {
+	d := time.Until(p.start.Add(t))
+	if d <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-timer.C:
+		return nil
+	case <-p.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// run implements sourceStaticImpl.
+func (s *fileSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
+	s.Log(logger.Debug, "opening %s", cnf.Source)
+
+	r, err := openFileSource(ctx, cnf)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	readerErr := make(chan error)
+
+	go func() {
+		readerErr <- func() error {
+			isMP4, err := fileSourceIsMP4(r)
+			if err != nil {
+				return err
+			}
+
+			if isMP4 {
+				return s.runMP4(ctx, r, cnf.SourceLoop)
+			}
+			return s.runMPEGTS(ctx, r, cnf.SourceLoop)
+		}()
+	}()
+
+	select {
+	case err := <-readerErr:
+		if err != errFileSourceEnded {
+			return err
+		}
+
+		// do not restart the source after the end of the file.
+		s.Log(logger.Info, "%v", err)
+
+		for {
+			select {
+			case <-reloadConf:
+
+			case <-ctx.Done():
+				return fmt.Errorf("terminated")
+			}
+		}
+
+	case <-ctx.Done():
+		r.Close()
+		<-readerErr
+		return fmt.Errorf("terminated")
+	}
+}
+
+func (s *fileSource) runMPEGTS(ctx context.Context, r io.ReadSeeker, loop bool) error {
+	dem := astits.NewDemuxer(
+		context.Background(),
+		r,
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+	tracks, err := mpegtsFindTracks(dem)
+	if err != nil {
+		return err
+	}
+
+	medias, writers := mpegtsMedias(tracks, s)
+
+	res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+		medias:             medias,
+		generateRTPPackets: true,
+	})
+	if res.err != nil {
+		return res.err
+	}
+
+	defer func() {
+		s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+	}()
+
+	s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
+
+	pacer := newFileSourcePacer(ctx)
+
+	// timestamps of every iteration of the loop follow the ones of the previous iteration.
+	var offset time.Duration
+	var end time.Duration
+	lastPTS := make(map[uint16]time.Duration)
+	lastDuration := make(map[uint16]time.Duration)
+
+	pacedWriters := make(map[uint16]*mpegtsTrackWriter, len(writers))
+
+	for pid, writer := range writers {
+		pid := pid
+		writer := writer
+
+		if writer.scheduled {
+			// scheduled data is written as soon as it's received, since its PTS points to the future.
+			pacedWriters[pid] = &mpegtsTrackWriter{
+				ptsOptional: writer.ptsOptional,
+				scheduled:   true,
+				write: func(stream *stream, pts time.Duration, data []byte) {
+					writer.write(stream, pts+offset, data)
+				},
+			}
+			continue
+		}
+
+		pacedWriters[pid] = &mpegtsTrackWriter{
+			ptsOptional: writer.ptsOptional,
+			write: func(stream *stream, pts time.Duration, data []byte) {
+				pts += offset
+
+				err := pacer.wait(pts)
+				if err != nil {
+					return
+				}
+
+				// the duration of a frame is not available in MPEG-TS,
+				// therefore the one of the previous frame is used.
+				if prev, ok := lastPTS[pid]; ok && pts > prev {
+					lastDuration[pid] = pts - prev
+				}
+				lastPTS[pid] = pts
+
+				if e := pts + lastDuration[pid]; e > end {
+					end = e
+				}
+
+				writer.write(stream, pts, data)
+			},
+		}
+	}
+
+	for {
+		err := mpegtsReadData(dem, res.stream, pacedWriters, func() {})
+		if !errors.Is(err, astits.ErrNoMorePackets) {
+			return err
+		}
+
+		if !loop {
+			return errFileSourceEnded
+		}
+
+		_, err = r.Seek(0, io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		dem = astits.NewDemuxer(
+			context.Background(),
+			r,
+			astits.DemuxerOptPacketSize(188),
+			astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+		_, err = mpegtsFindTracks(dem)
+		if err != nil {
+			return err
+		}
+
+		offset = end
+		lastPTS = make(map[uint16]time.Duration)
+	}
+}
+
+func (s *fileSource) runMP4(ctx context.Context, r io.ReadSeeker, loop bool) error {
+	tracks, err := mp4ReadTracks(r)
+	if err != nil {
+		return err
+	}
+
+	medias, writers := mp4Medias(tracks, s)
+
+	res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+		medias:             medias,
+		generateRTPPackets: true,
+	})
+	if res.err != nil {
+		return res.err
+	}
+
+	defer func() {
+		s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+	}()
+
+	s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
+
+	// timestamps are shifted in order to start from zero.
+	start := tracks[0].samples[0].dts
+	var end time.Duration
+	for _, track := range tracks {
+		first := track.samples[0]
+		last := track.samples[len(track.samples)-1]
+
+		if first.dts < start {
+			start = first.dts
+		}
+		if e := last.dts + last.duration; e > end {
+			end = e
+		}
+	}
+
+	pacer := newFileSourcePacer(ctx)
+	pos := make([]int, len(tracks))
+	var offset time.Duration
+
+	for {
+		i := mp4NextSample(tracks, pos)
+		if i < 0 {
+			if !loop {
+				return errFileSourceEnded
+			}
+
+			// timestamps of every iteration of the loop follow the ones of the previous iteration.
+			offset += end - start
+			for j := range pos {
+				pos[j] = 0
+			}
+			continue
+		}
+
+		sample := tracks[i].samples[pos[i]]
+		pos[i]++
+
+		err := pacer.wait(offset + sample.dts - start)
+		if err != nil {
+			return err
+		}
+
+		_, err = r.Seek(int64(sample.offset), io.SeekStart)
+		if err != nil {
+			return err
+		}
+
+		data := make([]byte, sample.size)
+		_, err = io.ReadFull(r, data)
+		if err != nil {
+			return err
+		}
+
+		writers[i](res.stream, offset+sample.pts-start, data)
+	}
+}
+
+// apiSourceDescribe implements sourceStaticImpl.
+func (*fileSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"fileSource"}
+}
@@ -0,0 +1,376 @@
+package core
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"time"
+
+	gomp4 "github.com/abema/go-mp4"
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gohlslib/pkg/fmp4"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// mp4Sample is a sample of a MP4 track.
+type mp4Sample struct {
+	offset   uint64
+	size     uint32
+	dts      time.Duration
+	pts      time.Duration
+	duration time.Duration
+}
+
+// mp4Track is a track of a MP4 file.
+type mp4Track struct {
+	codec   codecs.Codec
+	samples []*mp4Sample
+}
+
+func mp4Duration(v int64, timeScale uint32) time.Duration {
+	return time.Duration(v) * time.Second / time.Duration(timeScale)
+}
+
+// mp4TrackTables contains the sample tables of a MP4 track.
+type mp4TrackTables struct {
+	raw       []byte
+	timeScale uint32
+	stts      *gomp4.Stts
+	ctts      *gomp4.Ctts
+	stsc      *gomp4.Stsc
+	stsz      *gomp4.Stsz
+	stco      []uint64
+	elst      *gomp4.Elst
+}
+
+// samples computes position and timing of samples from the sample tables.
+func (t *mp4TrackTables) samples() ([]*mp4Sample, error) {
+	if t.timeScale == 0 || t.stts == nil || t.stsc == nil || t.stsz == nil || t.stco == nil {
+		return nil, fmt.Errorf("sample tables are missing")
+	}
+
+	count := int(t.stsz.SampleCount)
+	samples := make([]*mp4Sample, count)
+
+	// sizes
+	for i := range samples {
+		samples[i] = &mp4Sample{}
+		if t.stsz.SampleSize != 0 {
+			samples[i].size = t.stsz.SampleSize
+		} else {
+			if i >= len(t.stsz.EntrySize) {
+				return nil, fmt.Errorf("invalid stsz")
+			}
+			samples[i].size = t.stsz.EntrySize[i]
+		}
+	}
+
+	// offsets
+	i := 0
+	for e, entry := range t.stsc.Entries {
+		lastChunk := uint32(len(t.stco))
+		if e+1 < len(t.stsc.Entries) {
+			lastChunk = t.stsc.Entries[e+1].FirstChunk - 1
+		}
+
+		for chunk := entry.FirstChunk; chunk <= lastChunk && i < count; chunk++ {
+			if chunk == 0 || int(chunk) > len(t.stco) {
+				return nil, fmt.Errorf("invalid stsc")
+			}
+
+			offset := t.stco[chunk-1]
+			for j := uint32(0); j < entry.SamplesPerChunk && i < count; j++ {
+				samples[i].offset = offset
+				offset += uint64(samples[i].size)
+				i++
+			}
+		}
+	}
+	if i != count {
+		return nil, fmt.Errorf("invalid stsc")
+	}
+
+	// the first edit removes the initial part of the media, that usually
+	// corresponds to the delay introduced by B-frames.
+	var shift int64
+	if t.elst != nil {
+		for e := range t.elst.Entries {
+			if mt := t.elst.GetMediaTime(e); mt >= 0 {
+				shift = mt
+				break
+			}
+		}
+	}
+
+	// timestamps
+	i = 0
+	var dts int64
+	for _, entry := range t.stts.Entries {
+		for j := uint32(0); j < entry.SampleCount && i < count; j++ {
+			samples[i].dts = mp4Duration(dts-shift, t.timeScale)
+			samples[i].pts = samples[i].dts
+			samples[i].duration = mp4Duration(int64(entry.SampleDelta), t.timeScale)
+			dts += int64(entry.SampleDelta)
+			i++
+		}
+	}
+	if i != count {
+		return nil, fmt.Errorf("invalid stts")
+	}
+
+	if t.ctts != nil {
+		i = 0
+		for e, entry := range t.ctts.Entries {
+			for j := uint32(0); j < entry.SampleCount && i < count; j++ {
+				samples[i].pts += mp4Duration(t.ctts.GetSampleOffset(e), t.timeScale)
+				i++
+			}
+		}
+	}
+
+	return samples, nil
+}
+
+// mp4ReadTracks reads the tracks of a MP4 file.
+// Fragmented files are not supported, since their samples are not listed in the moov box.
+func mp4ReadTracks(r io.ReadSeeker) ([]*mp4Track, error) {
+	var moov []byte
+
+	_, err := gomp4.ReadBoxStructure(r, func(h *gomp4.ReadHandle) (interface{}, error) {
+		if h.BoxInfo.Type == gomp4.BoxTypeMoov() {
+			var buf bytes.Buffer
+			_, err := h.ReadData(&buf)
+			if err != nil {
+				return nil, err
+			}
+			moov = buf.Bytes()
+		}
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if moov == nil {
+		return nil, fmt.Errorf("moov box not found")
+	}
+
+	var tables []*mp4TrackTables
+	var cur *mp4TrackTables
+
+	_, err = gomp4.ReadBoxStructure(bytes.NewReader(moov), func(h *gomp4.ReadHandle) (interface{}, error) {
+		switch h.BoxInfo.Type.String() {
+		case "mvex":
+			return nil, fmt.Errorf("fragmented MP4 files are not supported")
+
+		case "trak":
+			cur = &mp4TrackTables{
+				raw: moov[h.BoxInfo.Offset : h.BoxInfo.Offset+h.BoxInfo.Size],
+			}
+			tables = append(tables, cur)
+			return h.Expand()
+
+		case "mdia", "minf", "stbl", "edts":
+			return h.Expand()
+
+		case "mdhd", "stts", "ctts", "stsc", "stsz", "stco", "co64", "elst":
+			if cur == nil {
+				return nil, nil
+			}
+
+			box, _, err := h.ReadPayload()
+			if err != nil {
+				return nil, err
+			}
+
+			switch tbox := box.(type) {
+			case *gomp4.Mdhd:
+				cur.timeScale = tbox.Timescale
+			case *gomp4.Stts:
+				cur.stts = tbox
+			case *gomp4.Ctts:
+				cur.ctts = tbox
+			case *gomp4.Stsc:
+				cur.stsc = tbox
+			case *gomp4.Stsz:
+				cur.stsz = tbox
+			case *gomp4.Stco:
+				cur.stco = make([]uint64, len(tbox.ChunkOffset))
+				for i, v := range tbox.ChunkOffset {
+					cur.stco[i] = uint64(v)
+				}
+			case *gomp4.Co64:
+				cur.stco = tbox.ChunkOffset
+			case *gomp4.Elst:
+				cur.elst = tbox
+			}
+		}
+
+		return nil, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var tracks []*mp4Track
+
+	for _, t := range tables {
+		// codecs are decoded in the same way of fMP4 initialization files.
+		// Tracks with unsupported codecs are skipped.
+		var init fmp4.Init
+		err := init.Unmarshal(t.raw)
+		if err != nil || len(init.Tracks) != 1 || init.Tracks[0].Codec == nil {
+			continue
+		}
+
+		samples, err := t.samples()
+		if err != nil {
+			return nil, err
+		}
+
+		if len(samples) == 0 {
+			continue
+		}
+
+		tracks = append(tracks, &mp4Track{
+			codec:   init.Tracks[0].Codec,
+			samples: samples,
+		})
+	}
+
+	if tracks == nil {
+		return nil, fmt.Errorf("no supported tracks found")
+	}
+
+	return tracks, nil
+}
+
+type mp4WriteFunc func(stream *stream, pts time.Duration, data []byte)
+
+// mp4Medias converts MP4 tracks into medias.
+// It also returns, for each track, a function that writes its samples into a stream.
+func mp4Medias(tracks []*mp4Track, l logger.Writer) (media.Medias, []mp4WriteFunc) {
+	var medias media.Medias
+	writers := make([]mp4WriteFunc, len(tracks))
+
+	for i, track := range tracks {
+		var medi *media.Media
+
+		switch tcodec := track.codec.(type) {
+		case *codecs.H264:
+			medi = &media.Media{
+				Type: media.TypeVideo,
+				Formats: []formats.Format{&formats.H264{
+					PayloadTyp:        96,
+					PacketizationMode: 1,
+					SPS:               tcodec.SPS,
+					PPS:               tcodec.PPS,
+				}},
+			}
+
+			writers[i] = func(stream *stream, pts time.Duration, data []byte) {
+				au, err := h264.AVCCUnmarshal(data)
+				if err != nil {
+					l.Log(logger.Warn, "%v", err)
+					return
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				})
+			}
+
+		case *codecs.H265:
+			medi = &media.Media{
+				Type: media.TypeVideo,
+				Formats: []formats.Format{&formats.H265{
+					PayloadTyp: 96,
+					VPS:        tcodec.VPS,
+					SPS:        tcodec.SPS,
+					PPS:        tcodec.PPS,
+				}},
+			}
+
+			writers[i] = func(stream *stream, pts time.Duration, data []byte) {
+				au, err := h264.AVCCUnmarshal(data)
+				if err != nil {
+					l.Log(logger.Warn, "%v", err)
+					return
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH265{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				})
+			}
+
+		case *codecs.MPEG4Audio:
+			medi = &media.Media{
+				Type: media.TypeAudio,
+				Formats: []formats.Format{&formats.MPEG4Audio{
+					PayloadTyp:       96,
+					SizeLength:       13,
+					IndexLength:      3,
+					IndexDeltaLength: 3,
+					Config:           &tcodec.Config,
+				}},
+			}
+
+			writers[i] = func(stream *stream, pts time.Duration, data []byte) {
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG4Audio{
+					PTS: pts,
+					AUs: [][]byte{data},
+					NTP: time.Now(),
+				})
+			}
+
+		case *codecs.Opus:
+			medi = &media.Media{
+				Type: media.TypeAudio,
+				Formats: []formats.Format{&formats.Opus{
+					PayloadTyp: 96,
+					IsStereo:   (tcodec.Channels == 2),
+				}},
+			}
+
+			writers[i] = func(stream *stream, pts time.Duration, data []byte) {
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitOpus{
+					PTS:   pts,
+					Frame: data,
+					NTP:   time.Now(),
+				})
+			}
+		}
+
+		medias = append(medias, medi)
+	}
+
+	return medias, writers
+}
+
+// mp4NextSample returns the index of the track whose next sample has the lowest DTS,
+// in order to read samples in the same order in which they must be decoded.
+// It returns -1 when all samples have been read.
+func mp4NextSample(tracks []*mp4Track, pos []int) int {
+	next := -1
+
+	for i, track := range tracks {
+		if pos[i] >= len(track.samples) {
+			continue
+		}
+
+		if next < 0 || track.samples[pos[i]].dts < tracks[next].samples[pos[next]].dts {
+			next = i
+		}
+	}
+
+	return next
+}
@@ -0,0 +1,310 @@
+package core
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// size of the ranges that are requested to the object storage.
+	s3ReaderChunkSize = 1024 * 1024
+
+	s3DefaultRegion = "us-east-1"
+
+	// hash of an empty payload.
+	s3EmptyPayloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+)
+
+// s3Credentials are credentials of an object storage compatible with AWS S3.
+type s3Credentials struct {
+	accessKeyID     string
+	secretAccessKey string
+	sessionToken    string
+}
+
+func s3HMAC(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func s3SHA256(data string) string {
+	h := sha256.Sum256([]byte(data))
+	return hex.EncodeToString(h[:])
+}
+
+// s3EncodePath encodes a path as required by the AWS Signature Version 4.
+func s3EncodePath(p string) string {
+	var b strings.Builder
+
+	for _, c := range []byte(p) {
+		if (c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || c == '/' {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+
+	return b.String()
+}
+
+// s3SignRequest signs a request without body with the AWS Signature Version 4.
+func s3SignRequest(req *http.Request, creds *s3Credentials, region string, now time.Time) {
+	amzDate := now.UTC().Format("20060102T150405Z")
+	date := amzDate[:8]
+
+	req.Header.Set("x-amz-date", amzDate)
+	req.Header.Set("x-amz-content-sha256", s3EmptyPayloadHash)
+	if creds.sessionToken != "" {
+		req.Header.Set("x-amz-security-token", creds.sessionToken)
+	}
+
+	headers := map[string]string{
+		"host": req.URL.Host,
+	}
+	for key, vals := range req.Header {
+		lkey := strings.ToLower(key)
+		if lkey == "range" || strings.HasPrefix(lkey, "x-amz-") {
+			headers[lkey] = strings.TrimSpace(strings.Join(vals, ","))
+		}
+	}
+
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := req.Method + "\n" +
+		s3EncodePath(req.URL.Path) + "\n" +
+		req.URL.Query().Encode() + "\n" +
+		canonicalHeaders.String() + "\n" +
+		signedHeaders + "\n" +
+		s3EmptyPayloadHash
+
+	scope := date + "/" + region + "/s3/aws4_request"
+
+	stringToSign := "AWS4-HMAC-SHA256\n" +
+		amzDate + "\n" +
+		scope + "\n" +
+		s3SHA256(canonicalRequest)
+
+	key := s3HMAC([]byte("AWS4"+creds.secretAccessKey), date)
+	key = s3HMAC(key, region)
+	key = s3HMAC(key, "s3")
+	key = s3HMAC(key, "aws4_request")
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+creds.accessKeyID+"/"+scope+
+		", SignedHeaders="+signedHeaders+
+		", Signature="+hex.EncodeToString(s3HMAC(key, stringToSign)))
+}
+
+// s3ObjectURL returns the HTTP URL and the credentials of an object
+// identified by a URL in the s3://[accessKeyID:secretAccessKey@]bucket/key format.
+// When the endpoint is empty, the object is searched in AWS S3,
+// otherwise it is searched in the endpoint with a path-style URL.
+func s3ObjectURL(source string, endpoint string, region string) (string, *s3Credentials, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return "", nil, err
+	}
+
+	bucket := u.Host
+	key := strings.TrimPrefix(u.Path, "/")
+	if bucket == "" || key == "" || fileSourceHasParentDir(key) {
+		return "", nil, fmt.Errorf("'%s' is not a valid S3 URL", source)
+	}
+
+	var creds *s3Credentials
+
+	if u.User != nil {
+		pass, _ := u.User.Password()
+		creds = &s3Credentials{
+			accessKeyID:     u.User.Username(),
+			secretAccessKey: pass,
+		}
+	} else if id := os.Getenv("AWS_ACCESS_KEY_ID"); id != "" {
+		creds = &s3Credentials{
+			accessKeyID:     id,
+			secretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			sessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+		}
+	}
+
+	if endpoint == "" {
+		return "https://" + bucket + ".s3." + region + ".amazonaws.com/" + s3EncodePath(key), creds, nil
+	}
+
+	return strings.TrimSuffix(endpoint, "/") + "/" + bucket + "/" + s3EncodePath(key), creds, nil
+}
+
+// s3Reader reads an object from an object storage compatible with AWS S3.
+// It implements io.ReadSeeker, by requesting ranges of the object.
+type s3Reader struct {
+	ctx        context.Context
+	httpClient *http.Client
+	objectURL  string
+	creds      *s3Credentials
+	region     string
+
+	size     int64
+	pos      int64
+	buf      []byte
+	bufStart int64
+}
+
+func newS3Reader(
+	ctx context.Context,
+	source string,
+	endpoint string,
+	region string,
+) (*s3Reader, error) {
+	if region == "" {
+		region = s3DefaultRegion
+	}
+
+	objectURL, creds, err := s3ObjectURL(source, endpoint, region)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &s3Reader{
+		ctx:        ctx,
+		httpClient: &http.Client{},
+		objectURL:  objectURL,
+		creds:      creds,
+		region:     region,
+	}
+
+	res, err := r.do(http.MethodHead, "")
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	if res.ContentLength < 0 {
+		return nil, fmt.Errorf("object size is unknown")
+	}
+	r.size = res.ContentLength
+
+	return r, nil
+}
+
+func (r *s3Reader) do(method string, byteRange string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(r.ctx, method, r.objectURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if byteRange != "" {
+		req.Header.Set("Range", byteRange)
+	}
+
+	if r.creds != nil {
+		s3SignRequest(req, r.creds, r.region, time.Now())
+	}
+
+	return r.httpClient.Do(req)
+}
+
+func (r *s3Reader) fill() error {
+	end := r.pos + s3ReaderChunkSize
+	if end > r.size {
+		end = r.size
+	}
+
+	res, err := r.do(http.MethodGet, "bytes="+strconv.FormatInt(r.pos, 10)+"-"+strconv.FormatInt(end-1, 10))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	buf, err := io.ReadAll(res.Body)
+	if err != nil {
+		return err
+	}
+
+	if len(buf) == 0 {
+		return io.ErrUnexpectedEOF
+	}
+
+	r.buf = buf
+	r.bufStart = r.pos
+	return nil
+}
+
+// Read implements io.Reader.
+func (r *s3Reader) Read(p []byte) (int, error) {
+	// reads must be interrupted even when data is buffered.
+	err := r.ctx.Err()
+	if err != nil {
+		return 0, err
+	}
+
+	if r.pos >= r.size {
+		return 0, io.EOF
+	}
+
+	if r.pos < r.bufStart || r.pos >= r.bufStart+int64(len(r.buf)) {
+		err = r.fill()
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, r.buf[r.pos-r.bufStart:])
+	r.pos += int64(n)
+	return n, nil
+}
+
+// Seek implements io.Seeker.
+func (r *s3Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.pos
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, fmt.Errorf("invalid whence")
+	}
+
+	if offset < 0 {
+		return 0, fmt.Errorf("negative position")
+	}
+
+	r.pos = offset
+	return offset, nil
+}
+
+// Close implements io.Closer.
+func (r *s3Reader) Close() error {
+	r.httpClient.CloseIdleConnections()
+	return nil
+}
@@ -0,0 +1,506 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"sort"
+	"sync"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/encoding/protojson"
+	"google.golang.org/protobuf/types/known/structpb"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/grpcapi"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type grpcAPITenantKey struct{}
+
+// grpcAPITenant returns the tenant that performed the request, or nil.
+func grpcAPITenant(ctx context.Context) *conf.TenantConf {
+	tconf, _ := ctx.Value(grpcAPITenantKey{}).(*conf.TenantConf)
+	return tconf
+}
+
+// grpcAPICanAccessPath checks whether the author of the request can access the given path.
+func grpcAPICanAccessPath(ctx context.Context, name string) bool {
+	tconf := grpcAPITenant(ctx)
+	return tconf == nil || tconf.Owns(name)
+}
+
+// grpcAPIDecodeStruct converts a protobuf Struct into JSON.
+func grpcAPIDecodeStruct(in *structpb.Struct) ([]byte, error) {
+	if in == nil {
+		return []byte("{}"), nil
+	}
+	return protojson.Marshal(in)
+}
+
+// grpcAPIEncodeStruct converts a value into a protobuf Struct, through its JSON representation.
+func grpcAPIEncodeStruct(in interface{}) (*structpb.Struct, error) {
+	byts, err := json.Marshal(in)
+	if err != nil {
+		return nil, err
+	}
+
+	var out structpb.Struct
+	err = protojson.Unmarshal(byts, &out)
+	if err != nil {
+		return nil, err
+	}
+
+	return &out, nil
+}
+
+func grpcAPISourceOrReader(in interface{}) *grpcapi.PathSourceOrReader {
+	if in == nil {
+		return nil
+	}
+
+	var tmp struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}
+	byts, _ := json.Marshal(in)
+	json.Unmarshal(byts, &tmp) //nolint:errcheck
+
+	return &grpcapi.PathSourceOrReader{
+		Type: tmp.Type,
+		Id:   tmp.ID,
+	}
+}
+
+var grpcAPIEventTypes = map[apiEventType]grpcapi.Event_Type{
+	apiEventPathReady:        grpcapi.Event_TYPE_PATH_READY,
+	apiEventPathNotReady:     grpcapi.Event_TYPE_PATH_NOT_READY,
+	apiEventClientConnect:    grpcapi.Event_TYPE_CLIENT_CONNECT,
+	apiEventClientDisconnect: grpcapi.Event_TYPE_CLIENT_DISCONNECT,
+	apiEventConfReload:       grpcapi.Event_TYPE_CONF_RELOAD,
+}
+
+// grpcAPIServerStream is a grpc.ServerStream with a custom context.
+type grpcAPIServerStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *grpcAPIServerStream) Context() context.Context {
+	return s.ctx
+}
+
+type grpcAPIParent interface {
+	logger.Writer
+	grpcAPIConfigSet(conf *conf.Conf)
+}
+
+type grpcAPI struct {
+	grpcapi.UnimplementedAPIServer
+
+	user         conf.Credential
+	pass         conf.Credential
+	tenants      map[string]*conf.TenantConf
+	conf         *conf.Conf
+	pathManager  apiPathManager
+	rtspServer   apiRTSPServer
+	rtspsServer  apiRTSPServer
+	rtmpServer   apiRTMPServer
+	rtmpsServer  apiRTMPServer
+	webRTCServer apiWebRTCServer
+	events       *apiEvents
+	parent       grpcAPIParent
+
+	ln     net.Listener
+	server *grpc.Server
+	mutex  sync.Mutex
+}
+
+func newGRPCAPI(
+	address string,
+	socketPermissions conf.FileMode,
+	user conf.Credential,
+	pass conf.Credential,
+	conf *conf.Conf,
+	pathManager apiPathManager,
+	rtspServer apiRTSPServer,
+	rtspsServer apiRTSPServer,
+	rtmpServer apiRTMPServer,
+	rtmpsServer apiRTMPServer,
+	webRTCServer apiWebRTCServer,
+	events *apiEvents,
+	parent grpcAPIParent,
+) (*grpcAPI, error) {
+	ln, err := httpListen(address, socketPermissions)
+	if err != nil {
+		return nil, err
+	}
+
+	a := &grpcAPI{
+		user:         user,
+		pass:         pass,
+		tenants:      conf.Tenants,
+		conf:         conf,
+		pathManager:  pathManager,
+		rtspServer:   rtspServer,
+		rtspsServer:  rtspsServer,
+		rtmpServer:   rtmpServer,
+		rtmpsServer:  rtmpsServer,
+		webRTCServer: webRTCServer,
+		events:       events,
+		parent:       parent,
+		ln:           ln,
+	}
+
+	a.server = grpc.NewServer(
+		grpc.UnaryInterceptor(a.unaryInterceptor),
+		grpc.StreamInterceptor(a.streamInterceptor))
+	grpcapi.RegisterAPIServer(a.server, a)
+
+	go a.server.Serve(ln)
+
+	a.Log(logger.Info, "listener opened on "+address)
+
+	return a, nil
+}
+
+func (a *grpcAPI) close() {
+	a.Log(logger.Info, "listener is closing")
+	a.server.Stop()
+	a.ln.Close() // in case Stop() is called before Serve()
+}
+
+func (a *grpcAPI) Log(level logger.Level, format string, args ...interface{}) {
+	a.parent.Log(level, "[gRPC API] "+format, args...)
+}
+
+// authenticate checks the credentials contained in the "authorization" metadata key,
+// in the same format of the HTTP Basic authentication.
+func (a *grpcAPI) authenticate(ctx context.Context) (context.Context, error) {
+	if a.user == "" {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+
+	for _, v := range md.Get("authorization") {
+		req := http.Request{Header: http.Header{"Authorization": []string{v}}}
+
+		reqUser, reqPass, ok := req.BasicAuth()
+		if !ok {
+			continue
+		}
+
+		if tconf, ok := apiAuthenticate(a.user, a.pass, a.tenants, reqUser, reqPass); ok {
+			if tconf != nil {
+				ctx = context.WithValue(ctx, grpcAPITenantKey{}, tconf)
+			}
+			return ctx, nil
+		}
+	}
+
+	return nil, status.Error(codes.Unauthenticated, "authentication failed")
+}
+
+func (a *grpcAPI) unaryInterceptor(
+	ctx context.Context,
+	req interface{},
+	info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler,
+) (interface{}, error) {
+	ctx, err := a.authenticate(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return handler(ctx, req)
+}
+
+func (a *grpcAPI) streamInterceptor(
+	srv interface{},
+	ss grpc.ServerStream,
+	info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler,
+) error {
+	ctx, err := a.authenticate(ss.Context())
+	if err != nil {
+		return err
+	}
+
+	return handler(srv, &grpcAPIServerStream{ServerStream: ss, ctx: ctx})
+}
+
+// setConf applies a new configuration. It must be called with the mutex locked.
+func (a *grpcAPI) setConf(newConf *conf.Conf) {
+	a.conf = newConf
+
+	// since reloading the configuration can cause the shutdown of the API,
+	// call it in a goroutine
+	go a.parent.grpcAPIConfigSet(newConf)
+}
+
+// GetConfig implements grpcapi.APIServer.
+func (a *grpcAPI) GetConfig(ctx context.Context, req *grpcapi.GetConfigRequest) (*grpcapi.GetConfigResponse, error) {
+	if grpcAPITenant(ctx) != nil {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	a.mutex.Lock()
+	c := a.conf
+	a.mutex.Unlock()
+
+	out, err := grpcAPIEncodeStruct(c.WithSecretsMasked())
+	if err != nil {
+		return nil, status.Error(codes.Internal, err.Error())
+	}
+
+	return &grpcapi.GetConfigResponse{Conf: out}, nil
+}
+
+// SetConfig implements grpcapi.APIServer.
+func (a *grpcAPI) SetConfig(ctx context.Context, req *grpcapi.SetConfigRequest) (*grpcapi.SetConfigResponse, error) {
+	if grpcAPITenant(ctx) != nil {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	byts, err := grpcAPIDecodeStruct(req.Conf)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	in, err := loadConfData(bytes.NewReader(byts))
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	newConf, err := confWithData(a.conf, in)
+	if err != nil {
+		return nil, status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	a.setConf(newConf)
+
+	return &grpcapi.SetConfigResponse{}, nil
+}
+
+func (a *grpcAPI) editPathConfig(
+	ctx context.Context,
+	name string,
+	data *structpb.Struct,
+	edit func(c *conf.Conf, name string, in interface{}) (*conf.Conf, error),
+) error {
+	if name == "" {
+		return status.Error(codes.InvalidArgument, "invalid path name")
+	}
+
+	if !grpcAPICanAccessPath(ctx, name) {
+		return status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	byts, err := grpcAPIDecodeStruct(data)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	in, err := loadConfPathData(bytes.NewReader(byts))
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	err = apiTenantCheckPathData(grpcAPITenant(ctx), in)
+	if err != nil {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	newConf, err := edit(a.conf, name, in)
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+
+	a.setConf(newConf)
+
+	return nil
+}
+
+// AddPathConfig implements grpcapi.APIServer.
+func (a *grpcAPI) AddPathConfig(
+	ctx context.Context,
+	req *grpcapi.AddPathConfigRequest,
+) (*grpcapi.AddPathConfigResponse, error) {
+	err := a.editPathConfig(ctx, req.Name, req.Conf, confWithPathAdded)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcapi.AddPathConfigResponse{}, nil
+}
+
+// EditPathConfig implements grpcapi.APIServer.
+func (a *grpcAPI) EditPathConfig(
+	ctx context.Context,
+	req *grpcapi.EditPathConfigRequest,
+) (*grpcapi.EditPathConfigResponse, error) {
+	err := a.editPathConfig(ctx, req.Name, req.Conf, confWithPathEdited)
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcapi.EditPathConfigResponse{}, nil
+}
+
+// RemovePathConfig implements grpcapi.APIServer.
+func (a *grpcAPI) RemovePathConfig(
+	ctx context.Context,
+	req *grpcapi.RemovePathConfigRequest,
+) (*grpcapi.RemovePathConfigResponse, error) {
+	err := a.editPathConfig(ctx, req.Name, nil, func(c *conf.Conf, name string, _ interface{}) (*conf.Conf, error) {
+		return confWithPathRemoved(c, name)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &grpcapi.RemovePathConfigResponse{}, nil
+}
+
+// ListPaths implements grpcapi.APIServer.
+func (a *grpcAPI) ListPaths(ctx context.Context, req *grpcapi.ListPathsRequest) (*grpcapi.ListPathsResponse, error) {
+	res := a.pathManager.apiPathsList()
+	if res.err != nil {
+		return nil, status.Error(codes.Internal, res.err.Error())
+	}
+
+	names := make([]string, 0, len(res.data.Items))
+	for name := range res.data.Items {
+		if grpcAPICanAccessPath(ctx, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	out := &grpcapi.ListPathsResponse{
+		Paths: make([]*grpcapi.Path, len(names)),
+	}
+
+	for i, name := range names {
+		item := res.data.Items[name]
+
+		pa := &grpcapi.Path{
+			Name:          name,
+			ConfName:      item.ConfName,
+			Source:        grpcAPISourceOrReader(item.Source),
+			SourceReady:   item.SourceReady,
+			SourceError:   item.SourceError,
+			Tracks:        item.Tracks,
+			BytesReceived: item.BytesReceived,
+		}
+
+		for _, r := range item.Readers {
+			pa.Readers = append(pa.Readers, grpcAPISourceOrReader(r))
+		}
+
+		for _, alert := range item.Alerts {
+			pa.Alerts = append(pa.Alerts, &grpcapi.PathAlert{
+				Type:  string(alert.Type),
+				Track: alert.Track,
+				Since: timestamppb.New(alert.Since),
+			})
+		}
+
+		if item.Health != nil {
+			v := int32(*item.Health)
+			pa.Health = &v
+		}
+
+		out.Paths[i] = pa
+	}
+
+	return out, nil
+}
+
+// Kick implements grpcapi.APIServer.
+func (a *grpcAPI) Kick(ctx context.Context, req *grpcapi.KickRequest) (*grpcapi.KickResponse, error) {
+	if grpcAPITenant(ctx) != nil {
+		return nil, status.Error(codes.PermissionDenied, "forbidden")
+	}
+
+	out := &grpcapi.KickResponse{}
+
+	for _, id := range req.Ids {
+		if apiKick(id, a.rtspServer, a.rtspsServer, a.rtmpServer, a.rtmpsServer, a.webRTCServer) {
+			out.Kicked = append(out.Kicked, id)
+		} else {
+			out.NotFound = append(out.NotFound, id)
+		}
+	}
+
+	return out, nil
+}
+
+// WatchEvents implements grpcapi.APIServer.
+func (a *grpcAPI) WatchEvents(req *grpcapi.WatchEventsRequest, stream grpcapi.API_WatchEventsServer) error {
+	ctx := stream.Context()
+
+	var paths map[string]struct{}
+	if len(req.Paths) != 0 {
+		paths = make(map[string]struct{}, len(req.Paths))
+		for _, name := range req.Paths {
+			paths[name] = struct{}{}
+		}
+	}
+
+	ch := a.events.subscribe()
+	defer a.events.unsubscribe(ch)
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			if !ok {
+				return status.Error(codes.ResourceExhausted, "events are not being read fast enough")
+			}
+
+			if !apiEventVisible(ev, grpcAPITenant(ctx), paths) {
+				continue
+			}
+
+			// events that are not part of the gRPC API are skipped.
+			evType, ok := grpcAPIEventTypes[ev.Type]
+			if !ok {
+				continue
+			}
+
+			err := stream.Send(&grpcapi.Event{
+				Type:   evType,
+				Time:   timestamppb.New(ev.Time),
+				Path:   ev.Path,
+				Client: grpcAPISourceOrReader(ev.Client),
+				Role:   ev.Role,
+			})
+			if err != nil {
+				return err
+			}
+
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// confReload is called by core.
+func (a *grpcAPI) confReload(conf *conf.Conf) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+	a.conf = conf
+}
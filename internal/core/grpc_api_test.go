@@ -0,0 +1,190 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/structpb"
+
+	"github.com/aler9/mediamtx/internal/grpcapi"
+)
+
+func grpcAPIClient(t *testing.T) (grpcapi.APIClient, func()) {
+	conn, err := grpc.Dial("localhost:9996", grpc.WithTransportCredentials(insecure.NewCredentials()))
+	require.NoError(t, err)
+	return grpcapi.NewAPIClient(conn), func() { conn.Close() }
+}
+
+func grpcAPIContext(user string, pass string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(),
+		"authorization", "Basic "+base64.StdEncoding.EncodeToString([]byte(user+":"+pass)))
+}
+
+func TestGRPCAPIAuth(t *testing.T) {
+	p, ok := newInstance("grpcAPI: yes\n" +
+		"apiUser: myuser\n" +
+		"apiPass: mypass\n" +
+		"tenants:\n" +
+		"  team1:\n" +
+		"    prefix: team1/\n" +
+		"    apiUser: team1\n" +
+		"    apiPass: team1pass\n" +
+		"paths:\n" +
+		"  team1/cam:\n" +
+		"  team2/cam:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	c, closeClient := grpcAPIClient(t)
+	defer closeClient()
+
+	_, err := c.GetConfig(context.Background(), &grpcapi.GetConfigRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	_, err = c.GetConfig(grpcAPIContext("myuser", "wrong"), &grpcapi.GetConfigRequest{})
+	require.Equal(t, codes.Unauthenticated, status.Code(err))
+
+	res, err := c.GetConfig(grpcAPIContext("myuser", "mypass"), &grpcapi.GetConfigRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "myuser", res.Conf.Fields["apiUser"].GetStringValue())
+	require.Equal(t, "******", res.Conf.Fields["apiPass"].GetStringValue())
+
+	_, err = c.GetConfig(grpcAPIContext("team1", "team1pass"), &grpcapi.GetConfigRequest{})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+
+	list, err := c.ListPaths(grpcAPIContext("team1", "team1pass"), &grpcapi.ListPathsRequest{})
+	require.NoError(t, err)
+	require.Equal(t, 1, len(list.Paths))
+	require.Equal(t, "team1/cam", list.Paths[0].Name)
+
+	_, err = c.AddPathConfig(grpcAPIContext("team1", "team1pass"), &grpcapi.AddPathConfigRequest{
+		Name: "team2/other",
+	})
+	require.Equal(t, codes.PermissionDenied, status.Code(err))
+}
+
+func TestGRPCAPIConfigPaths(t *testing.T) {
+	p, ok := newInstance("grpcAPI: yes\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	c, closeClient := grpcAPIClient(t)
+	defer closeClient()
+
+	pathConf, err := structpb.NewStruct(map[string]interface{}{
+		"source":         "rtsp://127.0.0.1:9999/mypath",
+		"sourceOnDemand": true,
+	})
+	require.NoError(t, err)
+
+	_, err = c.AddPathConfig(context.Background(), &grpcapi.AddPathConfigRequest{
+		Name: "my/path",
+		Conf: pathConf,
+	})
+	require.NoError(t, err)
+
+	_, err = c.AddPathConfig(context.Background(), &grpcapi.AddPathConfigRequest{
+		Name: "my/path",
+		Conf: pathConf,
+	})
+	require.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	pathConf, err = structpb.NewStruct(map[string]interface{}{
+		"source": "rtsp://127.0.0.1:9998/mypath",
+	})
+	require.NoError(t, err)
+
+	_, err = c.EditPathConfig(context.Background(), &grpcapi.EditPathConfigRequest{
+		Name: "my/path",
+		Conf: pathConf,
+	})
+	require.NoError(t, err)
+
+	res, err := c.GetConfig(context.Background(), &grpcapi.GetConfigRequest{})
+	require.NoError(t, err)
+	require.Equal(t, "rtsp://127.0.0.1:9998/mypath",
+		res.Conf.Fields["paths"].GetStructValue().Fields["my/path"].GetStructValue().Fields["source"].GetStringValue())
+
+	_, err = c.RemovePathConfig(context.Background(), &grpcapi.RemovePathConfigRequest{
+		Name: "my/path",
+	})
+	require.NoError(t, err)
+
+	res, err = c.GetConfig(context.Background(), &grpcapi.GetConfigRequest{})
+	require.NoError(t, err)
+	_, ok = res.Conf.Fields["paths"].GetStructValue().Fields["my/path"]
+	require.Equal(t, false, ok)
+}
+
+func TestGRPCAPIWatchEvents(t *testing.T) {
+	p, ok := newInstance("grpcAPI: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	c, closeClient := grpcAPIClient(t)
+	defer closeClient()
+
+	ctx, ctxCancel := context.WithCancel(context.Background())
+	defer ctxCancel()
+
+	stream, err := c.WatchEvents(ctx, &grpcapi.WatchEventsRequest{
+		Paths: []string{"mypath"},
+	})
+	require.NoError(t, err)
+
+	// wait for the subscription
+	time.Sleep(500 * time.Millisecond)
+
+	func() {
+		source := gortsplib.Client{}
+		err := source.StartRecording("rtsp://localhost:8554/otherpath", media.Medias{testMediaH264})
+		require.NoError(t, err)
+		defer source.Close()
+	}()
+
+	source := gortsplib.Client{}
+	err = source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{testMediaH264})
+	require.NoError(t, err)
+
+	recvPathEvent := func() *grpcapi.Event {
+		for {
+			ev, err := stream.Recv()
+			require.NoError(t, err)
+			require.Equal(t, "mypath", ev.Path)
+
+			if ev.Type == grpcapi.Event_TYPE_CLIENT_CONNECT || ev.Type == grpcapi.Event_TYPE_CLIENT_DISCONNECT {
+				require.Equal(t, "publisher", ev.Role)
+				require.Equal(t, "rtspSession", ev.Client.Type)
+				continue
+			}
+
+			return ev
+		}
+	}
+
+	ev := recvPathEvent()
+	require.Equal(t, grpcapi.Event_TYPE_PATH_READY, ev.Type)
+
+	source.Close()
+
+	ev = recvPathEvent()
+	require.Equal(t, grpcapi.Event_TYPE_PATH_NOT_READY, ev.Type)
+
+	list, err := c.ListPaths(context.Background(), &grpcapi.ListPathsRequest{})
+	require.NoError(t, err)
+	for _, pa := range list.Paths {
+		require.Equal(t, false, pa.SourceReady)
+	}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"bytes"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var hlsPlaylistURIRe = regexp.MustCompile(`URI="([^"]*)"`)
+
+func hlsURIIsAbsolute(u string) bool {
+	return strings.HasPrefix(u, "http://") ||
+		strings.HasPrefix(u, "https://") ||
+		strings.HasPrefix(u, "/")
+}
+
+// hlsPlaylistRewriter is a http.ResponseWriter that buffers a playlist,
+// prefixes every relative URI with a base URL and suffixes it with a query.
+type hlsPlaylistRewriter struct {
+	http.ResponseWriter
+	baseURL string
+	query   string
+
+	statusCode int
+	buf        bytes.Buffer
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *hlsPlaylistRewriter) WriteHeader(statusCode int) {
+	w.statusCode = statusCode
+}
+
+// Write implements http.ResponseWriter.
+func (w *hlsPlaylistRewriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+func (w *hlsPlaylistRewriter) rewrite(byts []byte) []byte {
+	lines := strings.Split(string(byts), "\n")
+
+	for i, line := range lines {
+		switch {
+		case line == "":
+
+		case strings.HasPrefix(line, "#"):
+			lines[i] = hlsPlaylistURIRe.ReplaceAllStringFunc(line, func(attr string) string {
+				u := attr[len(`URI="`) : len(attr)-1]
+				if hlsURIIsAbsolute(u) {
+					return attr
+				}
+				return `URI="` + w.rewriteURI(u) + `"`
+			})
+
+		case !hlsURIIsAbsolute(line):
+			lines[i] = w.rewriteURI(line)
+		}
+	}
+
+	return []byte(strings.Join(lines, "\n"))
+}
+
+func (w *hlsPlaylistRewriter) rewriteURI(u string) string {
+	u = w.baseURL + u
+	if w.query != "" {
+		u += "?" + w.query
+	}
+	return u
+}
+
+// flush writes the rewritten playlist to the underlying writer.
+func (w *hlsPlaylistRewriter) flush() {
+	if w.statusCode == 0 {
+		return
+	}
+
+	byts := w.buf.Bytes()
+	if w.statusCode == http.StatusOK {
+		byts = w.rewrite(byts)
+	}
+
+	w.ResponseWriter.WriteHeader(w.statusCode)
+	w.ResponseWriter.Write(byts)
+}
@@ -0,0 +1,272 @@
+package core
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maximum number of cues that are waiting to be inserted into playlists.
+const hlsCuesMax = 32
+
+type hlsCue struct {
+	out      bool
+	eventID  *uint32
+	time     time.Time
+	duration time.Duration
+
+	// URI of the segment that the cue is inserted before.
+	// It's filled when the first segment that starts after the cue is available.
+	segment string
+}
+
+func (c *hlsCue) tag() string {
+	if !c.out {
+		return "#EXT-X-CUE-IN"
+	}
+
+	if c.duration == 0 {
+		return "#EXT-X-CUE-OUT"
+	}
+
+	return "#EXT-X-CUE-OUT:DURATION=" + strconv.FormatFloat(c.duration.Seconds(), 'f', -1, 64)
+}
+
+type hlsCuesSegment struct {
+	firstLine int
+	uri       string
+	start     time.Time
+	hasStart  bool
+	duration  time.Duration
+}
+
+// hlsCuesParseSegments returns the complete segments of a media playlist.
+func hlsCuesParseSegments(lines []string) []*hlsCuesSegment {
+	var segs []*hlsCuesSegment
+	cur := &hlsCuesSegment{firstLine: -1}
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"):
+			t, err := time.Parse(time.RFC3339Nano, line[len("#EXT-X-PROGRAM-DATE-TIME:"):])
+			if err == nil {
+				cur.start = t
+				cur.hasStart = true
+			}
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := line[len("#EXTINF:"):]
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				v = v[:i]
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err == nil {
+				cur.duration = time.Duration(f * float64(time.Second))
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-PART:"):
+
+		case strings.HasPrefix(line, "#"):
+			continue
+
+		default:
+			if cur.firstLine < 0 {
+				cur.firstLine = i
+			}
+			cur.uri = line
+			segs = append(segs, cur)
+			cur = &hlsCuesSegment{firstLine: -1}
+			continue
+		}
+
+		if cur.firstLine < 0 {
+			cur.firstLine = i
+		}
+	}
+
+	// fill the start time of segments that don't have a date
+	// by using the ones of other segments.
+	anchor := -1
+	for i, seg := range segs {
+		if seg.hasStart {
+			anchor = i
+			break
+		}
+	}
+	if anchor < 0 {
+		return nil
+	}
+
+	for i := anchor - 1; i >= 0; i-- {
+		segs[i].start = segs[i+1].start.Add(-segs[i].duration)
+	}
+	for i := anchor + 1; i < len(segs); i++ {
+		if !segs[i].hasStart {
+			segs[i].start = segs[i-1].start.Add(segs[i-1].duration)
+		}
+	}
+
+	return segs
+}
+
+// hlsCues stores ad breaks signaled by SCTE-35 cues
+// and inserts them into playlists with EXT-X-CUE-OUT and EXT-X-CUE-IN tags.
+// Since segments are not split at splice points, tags are inserted
+// before the first segment that starts after the splice point.
+type hlsCues struct {
+	// filled by the writer, in order to convert timestamps into dates.
+	refFilled bool
+	refPTS    time.Duration
+	refNTP    time.Time
+
+	mutex sync.Mutex
+	cues  []*hlsCue
+}
+
+// setReference is called by the writer when a video frame is written into the muxer.
+func (c *hlsCues) setReference(pts time.Duration, ntp time.Time) {
+	c.refFilled = true
+	c.refPTS = pts
+	c.refNTP = ntp
+}
+
+// add is called by the writer when a cue is received.
+// When hasPTS is true, pts is the splice time, otherwise the splice is immediate.
+func (c *hlsCues) add(cue *scte35Cue, pts time.Duration, hasPTS bool, ntp time.Time) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if cue.Cancel {
+		if cue.EventID == nil {
+			return
+		}
+
+		n := 0
+		for _, ec := range c.cues {
+			if ec.segment == "" && ec.eventID != nil && *ec.eventID == *cue.EventID {
+				continue
+			}
+			c.cues[n] = ec
+			n++
+		}
+		c.cues = c.cues[:n]
+		return
+	}
+
+	if cue.Type == "" {
+		return
+	}
+
+	t := ntp
+	if hasPTS && c.refFilled {
+		t = c.refNTP.Add(pts - c.refPTS)
+	}
+
+	hc := &hlsCue{
+		out:      (cue.Type == "out"),
+		eventID:  cue.EventID,
+		time:     t,
+		duration: scte35DurationValue(cue.Duration),
+	}
+	c.cues = append(c.cues, hc)
+
+	// breaks with auto return end without an additional cue.
+	if hc.out && cue.AutoReturn && hc.duration != 0 {
+		c.cues = append(c.cues, &hlsCue{
+			out:     false,
+			eventID: cue.EventID,
+			time:    t.Add(hc.duration),
+		})
+	}
+
+	if len(c.cues) > hlsCuesMax {
+		c.cues = c.cues[len(c.cues)-hlsCuesMax:]
+	}
+}
+
+// insert inserts cues into a media playlist.
+func (c *hlsCues) insert(byts []byte) []byte {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if len(c.cues) == 0 {
+		return byts
+	}
+
+	lines := strings.Split(string(byts), "\n")
+
+	segs := hlsCuesParseSegments(lines)
+	if segs == nil {
+		return byts
+	}
+
+	// delta updates don't contain older segments.
+	skipped := strings.Contains(string(byts), "#EXT-X-SKIP:")
+
+	segIndexes := make(map[string]int, len(segs))
+	for i, seg := range segs {
+		segIndexes[seg.uri] = i
+	}
+
+	tags := make(map[int][]string)
+	n := 0
+
+	for _, cue := range c.cues {
+		if cue.segment == "" {
+			for i, seg := range segs {
+				if !seg.start.Before(cue.time) {
+					// the cue belongs to a segment that is not in the playlist anymore.
+					if i == 0 && seg.start.Sub(cue.time) >= seg.duration {
+						break
+					}
+
+					cue.segment = seg.uri
+					break
+				}
+			}
+
+			if cue.segment == "" {
+				// the splice point has not been reached yet.
+				if skipped || !cue.time.Before(segs[0].start) {
+					c.cues[n] = cue
+					n++
+				}
+				continue
+			}
+		}
+
+		i, ok := segIndexes[cue.segment]
+		if !ok {
+			// the segment is not in the playlist anymore.
+			if skipped {
+				c.cues[n] = cue
+				n++
+			}
+			continue
+		}
+
+		tags[segs[i].firstLine] = append(tags[segs[i].firstLine], cue.tag())
+		c.cues[n] = cue
+		n++
+	}
+
+	c.cues = c.cues[:n]
+
+	if len(tags) == 0 {
+		return byts
+	}
+
+	out := make([]string, 0, len(lines)+len(tags))
+	for i, line := range lines {
+		out = append(out, tags[i]...)
+		out = append(out, line)
+	}
+
+	return []byte(strings.Join(out, "\n"))
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLSCuesInsert(t *testing.T) {
+	eventID := uint32(1)
+	duration := 4.0
+
+	ref := time.Date(2010, 1, 1, 1, 1, 0, 0, time.UTC)
+
+	c := &hlsCues{}
+	c.setReference(10*time.Second, ref)
+
+	// splice point in the middle of the second segment, break with auto return.
+	c.add(&scte35Cue{
+		Type:       "out",
+		EventID:    &eventID,
+		Duration:   &duration,
+		AutoReturn: true,
+	}, 13*time.Second, true, ref)
+
+	// cancelled cue.
+	c.add(&scte35Cue{
+		Type:    "out",
+		EventID: func() *uint32 { v := uint32(2); return &v }(),
+	}, 11*time.Second, true, ref)
+	c.add(&scte35Cue{
+		Cancel:  true,
+		EventID: func() *uint32 { v := uint32(2); return &v }(),
+	}, 0, false, ref)
+
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:0\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg0.mp4\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg1.mp4\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:04Z\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg2.mp4\n"
+
+	require.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-TARGETDURATION:2\n"+
+		"#EXT-X-MEDIA-SEQUENCE:0\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg0.mp4\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg1.mp4\n"+
+		"#EXT-X-CUE-OUT:DURATION=4\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:04Z\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg2.mp4\n",
+		string(c.insert([]byte(playlist))))
+
+	playlist = "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:2\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg2.mp4\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg3.mp4\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:08Z\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg4.mp4\n"
+
+	require.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:3\n"+
+		"#EXT-X-TARGETDURATION:2\n"+
+		"#EXT-X-MEDIA-SEQUENCE:2\n"+
+		"#EXT-X-CUE-OUT:DURATION=4\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg2.mp4\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg3.mp4\n"+
+		"#EXT-X-CUE-IN\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:08Z\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg4.mp4\n",
+		string(c.insert([]byte(playlist))))
+
+	// cues are removed when their segment leaves the playlist.
+	playlist = "#EXTM3U\n" +
+		"#EXT-X-VERSION:3\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-MEDIA-SEQUENCE:5\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:10Z\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg5.mp4\n"
+
+	require.Equal(t, playlist, string(c.insert([]byte(playlist))))
+	require.Empty(t, c.cues)
+}
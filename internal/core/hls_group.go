@@ -0,0 +1,222 @@
+package core
+
+import (
+	"bytes"
+	"net/http"
+	gopath "path"
+	"sync"
+
+	"github.com/bluenviron/gohlslib/pkg/playlist"
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// hlsGroupResponseRecorder is a gin.ResponseWriter that stores the response
+// of a rendition of a path group.
+type hlsGroupResponseRecorder struct {
+	gin.ResponseWriter
+	header     http.Header
+	statusCode int
+	buf        bytes.Buffer
+}
+
+// Header implements http.ResponseWriter.
+func (w *hlsGroupResponseRecorder) Header() http.Header {
+	return w.header
+}
+
+// WriteHeader implements http.ResponseWriter.
+func (w *hlsGroupResponseRecorder) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+// WriteHeaderNow implements gin.ResponseWriter.
+func (w *hlsGroupResponseRecorder) WriteHeaderNow() {
+	w.WriteHeader(http.StatusOK)
+}
+
+// Write implements http.ResponseWriter.
+func (w *hlsGroupResponseRecorder) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.buf.Write(p)
+}
+
+// WriteString implements gin.ResponseWriter.
+func (w *hlsGroupResponseRecorder) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Status implements gin.ResponseWriter.
+func (w *hlsGroupResponseRecorder) Status() int {
+	return w.statusCode
+}
+
+// Size implements gin.ResponseWriter.
+func (w *hlsGroupResponseRecorder) Size() int {
+	return w.buf.Len()
+}
+
+// Written implements gin.ResponseWriter.
+func (w *hlsGroupResponseRecorder) Written() bool {
+	return w.statusCode != 0
+}
+
+// groupRenditions returns the ready renditions of a path group,
+// or nil if the name doesn't correspond to a group or corresponds to a ready path.
+func (s *hlsServer) groupRenditions(name string) []string {
+	if len(s.pathGroupSuffixes) == 0 {
+		return nil
+	}
+
+	res := s.pathManager.apiPathsList()
+	if res.err != nil {
+		return nil
+	}
+
+	// paths take precedence over groups.
+	if item, ok := res.data.Items[name]; ok && item.SourceReady {
+		return nil
+	}
+
+	names := make(map[string]struct{})
+	for pathName, item := range res.data.Items {
+		if item.SourceReady {
+			names[pathName] = struct{}{}
+		}
+	}
+
+	return pathGroupRenditions(s.pathGroupSuffixes, name, names)
+}
+
+// handleGroupRequest serves the player page and the multivariant playlist of a path group.
+// The playlist contains the variants of every ready rendition.
+// It returns false when the request doesn't refer to a path group.
+func (s *hlsServer) handleGroupRequest(ctx *gin.Context, group string, fname string) bool {
+	if fname != "" && fname != "index.m3u8" {
+		return false
+	}
+
+	renditions := s.groupRenditions(group)
+	if renditions == nil {
+		return false
+	}
+
+	if fname == "" {
+		ctx.Header("Content-Type", `text/html`)
+		ctx.Writer.WriteHeader(http.StatusOK)
+		err := s.index.render(ctx.Writer, group)
+		if err != nil {
+			s.Log(logger.Warn, "unable to render the player page: %v", err)
+		}
+		return true
+	}
+
+	recorders := make([]*hlsGroupResponseRecorder, len(renditions))
+	var wg sync.WaitGroup
+
+	// the playlist of every rendition is requested to its muxer,
+	// in order to apply authentication and to obtain bandwidth and codecs.
+	for i, rendition := range renditions {
+		rec := &hlsGroupResponseRecorder{
+			ResponseWriter: ctx.Writer,
+			header:         make(http.Header),
+		}
+		recorders[i] = rec
+
+		subCtx := ctx.Copy()
+		subCtx.Writer = rec
+		subCtx.Request = ctx.Request.Clone(ctx.Request.Context())
+
+		hreq := &hlsMuxerRequest{
+			path:     rendition,
+			file:     "index.m3u8",
+			query:    ctx.Request.URL.RawQuery,
+			clientIP: ctx.ClientIP(),
+			res:      make(chan hlsMuxerRequestRes),
+		}
+
+		select {
+		case s.request <- hreq:
+		case <-s.ctx.Done():
+			return true
+		}
+
+		muxer := (<-hreq.res).muxer
+		if muxer == nil {
+			continue
+		}
+
+		wg.Add(1)
+		go func(rendition string) {
+			defer wg.Done()
+			subCtx.Request.URL.Path = "index.m3u8"
+			muxer.handleRequest(subCtx, s.playlistBaseURL(ctx, rendition), nil, "")
+		}(rendition)
+	}
+
+	wg.Wait()
+
+	out := &playlist.Multivariant{
+		Version:             3,
+		IndependentSegments: true,
+	}
+
+	for i, rec := range recorders {
+		switch rec.statusCode {
+		case http.StatusOK:
+
+		case http.StatusUnauthorized:
+			ctx.Header("WWW-Authenticate", rec.header.Get("WWW-Authenticate"))
+			if ct := rec.header.Get("Content-Type"); ct != "" {
+				ctx.Header("Content-Type", ct)
+			}
+			ctx.Writer.WriteHeader(http.StatusUnauthorized)
+			ctx.Writer.Write(rec.buf.Bytes())
+			return true
+
+		default:
+			continue
+		}
+
+		var pl playlist.Multivariant
+		err := pl.Unmarshal(rec.buf.Bytes())
+		if err != nil {
+			s.Log(logger.Warn, "unable to decode the playlist of '%s': %v", renditions[i], err)
+			continue
+		}
+
+		if pl.Version > out.Version {
+			out.Version = pl.Version
+		}
+
+		for _, variant := range pl.Variants {
+			// URIs are relative to the group.
+			if !hlsURIIsAbsolute(variant.URI) {
+				variant.URI = "../" + gopath.Base(renditions[i]) + "/" + variant.URI
+			}
+			out.Variants = append(out.Variants, variant)
+		}
+	}
+
+	if len(out.Variants) == 0 {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return true
+	}
+
+	byts, err := out.Marshal()
+	if err != nil {
+		ctx.Writer.WriteHeader(http.StatusInternalServerError)
+		return true
+	}
+
+	if cc := s.cacheControl("index.m3u8"); cc != "" {
+		ctx.Header("Cache-Control", cc)
+	}
+	ctx.Header("Content-Type", `application/vnd.apple.mpegurl`)
+	ctx.Writer.WriteHeader(http.StatusOK)
+	ctx.Writer.Write(byts)
+	return true
+}
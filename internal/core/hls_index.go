@@ -0,0 +1,60 @@
+package core
+
+import (
+	_ "embed"
+	"html/template"
+	"io"
+	"os"
+	"strings"
+)
+
+// URL prefix of the static assets of the HLS player page.
+// It contains a character that is not allowed in path names.
+const hlsAssetsPrefix = "@assets/"
+
+//go:embed hls_index.html
+var hlsIndexDefault string
+
+type hlsIndexData struct {
+	PathName  string
+	PosterURL string
+	AssetsURL string
+}
+
+// hlsIndex is the HLS player page.
+type hlsIndex struct {
+	tmpl      *template.Template
+	posterURL string
+	assetsURL string
+}
+
+func newHLSIndex(indexFile string, posterURL string, assetsURL string) (*hlsIndex, error) {
+	src := hlsIndexDefault
+
+	if indexFile != "" {
+		byts, err := os.ReadFile(indexFile)
+		if err != nil {
+			return nil, err
+		}
+		src = string(byts)
+	}
+
+	tmpl, err := template.New("index").Parse(src)
+	if err != nil {
+		return nil, err
+	}
+
+	return &hlsIndex{
+		tmpl:      tmpl,
+		posterURL: posterURL,
+		assetsURL: assetsURL,
+	}, nil
+}
+
+func (i *hlsIndex) render(w io.Writer, pathName string) error {
+	return i.tmpl.Execute(w, hlsIndexData{
+		PathName:  pathName,
+		PosterURL: strings.ReplaceAll(i.posterURL, "$RTSP_PATH", pathName),
+		AssetsURL: i.assetsURL,
+	})
+}
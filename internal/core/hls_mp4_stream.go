@@ -0,0 +1,691 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/aler9/writerseeker"
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gohlslib/pkg/fmp4"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	// hlsMP4StreamFile is the file name of the progressive MP4 stream of a path.
+	hlsMP4StreamFile = "stream.mp4"
+
+	// minimum duration of fragments of streams without video.
+	hlsMP4StreamAudioFragmentDuration = 200 * time.Millisecond
+)
+
+// durationGoToMP4 converts a duration into the time scale of a MP4 track,
+// rounding it to the nearest value.
+func durationGoToMP4(v time.Duration, timeScale uint32) uint64 {
+	timeScale64 := uint64(timeScale)
+	secs := v / time.Second
+	dec := v % time.Second
+	return uint64(secs)*timeScale64 + (uint64(dec)*timeScale64+uint64(time.Second)/2)/uint64(time.Second)
+}
+
+type hlsMP4StreamSample struct {
+	*fmp4.PartSample
+	dts time.Duration
+}
+
+// hlsMP4StreamTrack is a track of a progressive MP4 stream.
+type hlsMP4StreamTrack struct {
+	init *fmp4.InitTrack
+
+	next     *hlsMP4StreamSample
+	samples  []*fmp4.PartSample
+	baseTime uint64
+	duration time.Duration
+}
+
+// push adds a sample to the track.
+// Samples are delayed by one, since their duration is the difference
+// between their DTS and the DTS of the next sample.
+func (t *hlsMP4StreamTrack) push(sample *hlsMP4StreamSample) {
+	prev := t.next
+	t.next = sample
+	if prev == nil {
+		return
+	}
+
+	if sample.dts < prev.dts {
+		sample.dts = prev.dts
+	}
+
+	// durations are computed from absolute timestamps, in order not to accumulate rounding errors.
+	prev.Duration = uint32(durationGoToMP4(sample.dts, t.init.TimeScale) -
+		durationGoToMP4(prev.dts, t.init.TimeScale))
+
+	if len(t.samples) == 0 {
+		t.baseTime = durationGoToMP4(prev.dts, t.init.TimeScale)
+		t.duration = 0
+	}
+
+	t.samples = append(t.samples, prev.PartSample)
+	t.duration += sample.dts - prev.dts
+}
+
+// hlsMP4StreamMuxer converts a stream into a fragmented MP4 file,
+// that is written progressively: an initialization segment followed by fragments.
+// Video fragments contain a single access unit, in order to minimize latency.
+type hlsMP4StreamMuxer struct {
+	w          io.Writer
+	videoTrack *hlsMP4StreamTrack
+	audioTrack *hlsMP4StreamTrack
+
+	started      bool
+	startDTS     time.Duration
+	dtsExtractor interface {
+		Extract([][]byte, time.Duration) (time.Duration, error)
+	}
+}
+
+func (m *hlsMP4StreamMuxer) writeInit() error {
+	var init fmp4.Init
+
+	if m.videoTrack != nil {
+		init.Tracks = append(init.Tracks, m.videoTrack.init)
+	}
+	if m.audioTrack != nil {
+		init.Tracks = append(init.Tracks, m.audioTrack.init)
+	}
+
+	var ws writerseeker.WriterSeeker
+	err := init.Marshal(&ws)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.w.Write(ws.Bytes())
+	return err
+}
+
+func (m *hlsMP4StreamMuxer) writeFragment() error {
+	var part fmp4.Part
+
+	for _, track := range []*hlsMP4StreamTrack{m.videoTrack, m.audioTrack} {
+		if track == nil || len(track.samples) == 0 {
+			continue
+		}
+
+		part.Tracks = append(part.Tracks, &fmp4.PartTrack{
+			ID:       track.init.ID,
+			BaseTime: track.baseTime,
+			Samples:  track.samples,
+			IsVideo:  track == m.videoTrack,
+		})
+		track.samples = nil
+	}
+
+	if part.Tracks == nil {
+		return nil
+	}
+
+	var ws writerseeker.WriterSeeker
+	err := part.Marshal(&ws)
+	if err != nil {
+		return err
+	}
+
+	_, err = m.w.Write(ws.Bytes())
+	return err
+}
+
+// updateVideoParams fills the parameters of the video track with the ones
+// contained in an access unit. It returns true if they have changed.
+func (m *hlsMP4StreamMuxer) updateVideoParams(au [][]byte) bool {
+	changed := false
+
+	switch tcodec := m.videoTrack.init.Codec.(type) {
+	case *codecs.H264:
+		for _, nalu := range au {
+			var dest *[]byte
+
+			switch h264.NALUType(nalu[0] & 0x1F) {
+			case h264.NALUTypeSPS:
+				dest = &tcodec.SPS
+			case h264.NALUTypePPS:
+				dest = &tcodec.PPS
+			default:
+				continue
+			}
+
+			if !bytes.Equal(*dest, nalu) {
+				changed = changed || *dest != nil
+				*dest = nalu
+			}
+		}
+
+	case *codecs.H265:
+		for _, nalu := range au {
+			var dest *[]byte
+
+			switch h265.NALUType((nalu[0] >> 1) & 0b111111) {
+			case h265.NALUType_VPS_NUT:
+				dest = &tcodec.VPS
+			case h265.NALUType_SPS_NUT:
+				dest = &tcodec.SPS
+			case h265.NALUType_PPS_NUT:
+				dest = &tcodec.PPS
+			default:
+				continue
+			}
+
+			if !bytes.Equal(*dest, nalu) {
+				changed = changed || *dest != nil
+				*dest = nalu
+			}
+		}
+	}
+
+	return changed
+}
+
+func (m *hlsMP4StreamMuxer) videoParamsMissing() bool {
+	switch tcodec := m.videoTrack.init.Codec.(type) {
+	case *codecs.H264:
+		return tcodec.SPS == nil || tcodec.PPS == nil
+
+	case *codecs.H265:
+		return tcodec.VPS == nil || tcodec.SPS == nil || tcodec.PPS == nil
+	}
+	return false
+}
+
+func (m *hlsMP4StreamMuxer) writeVideo(pts time.Duration, au [][]byte, randomAccess bool) error {
+	if !m.started {
+		// skip access units until a random access point and parameters are available.
+		if !randomAccess {
+			return nil
+		}
+
+		m.updateVideoParams(au)
+		if m.videoParamsMissing() {
+			return nil
+		}
+
+		if _, ok := m.videoTrack.init.Codec.(*codecs.H265); ok {
+			m.dtsExtractor = h265.NewDTSExtractor()
+		} else {
+			m.dtsExtractor = h264.NewDTSExtractor()
+		}
+	} else if m.updateVideoParams(au) {
+		// the initialization segment can't be sent again.
+		return fmt.Errorf("parameters of the video track have changed")
+	}
+
+	dts, err := m.dtsExtractor.Extract(au, pts)
+	if err != nil {
+		return fmt.Errorf("unable to extract DTS: %v", err)
+	}
+
+	if !m.started {
+		err := m.writeInit()
+		if err != nil {
+			return err
+		}
+
+		m.started = true
+		m.startDTS = dts
+	}
+
+	dts -= m.startDTS
+	pts -= m.startDTS
+
+	avcc, err := h264.AVCCMarshal(au)
+	if err != nil {
+		return err
+	}
+
+	m.videoTrack.push(&hlsMP4StreamSample{
+		PartSample: &fmp4.PartSample{
+			PTSOffset:       int32(durationGoToMP4(pts-dts, m.videoTrack.init.TimeScale)),
+			IsNonSyncSample: !randomAccess,
+			Payload:         avcc,
+		},
+		dts: dts,
+	})
+
+	return m.writeFragment()
+}
+
+func (m *hlsMP4StreamMuxer) writeAudio(pts time.Duration, au []byte) error {
+	if m.videoTrack != nil {
+		// wait for the video track
+		if !m.started {
+			return nil
+		}
+	} else if !m.started {
+		err := m.writeInit()
+		if err != nil {
+			return err
+		}
+
+		m.started = true
+		m.startDTS = pts
+	}
+
+	dts := pts - m.startDTS
+	if dts < 0 {
+		return nil
+	}
+
+	m.audioTrack.push(&hlsMP4StreamSample{
+		PartSample: &fmp4.PartSample{
+			Payload: au,
+		},
+		dts: dts,
+	})
+
+	// audio samples are sent together with video samples, when there's a video track.
+	if m.videoTrack == nil && m.audioTrack.duration >= hlsMP4StreamAudioFragmentDuration {
+		return m.writeFragment()
+	}
+
+	return nil
+}
+
+type hlsMP4StreamPathManager interface {
+	readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes
+	readerRejected(pathName string, protocol string, reason pathReaderRejectionReason, message string)
+}
+
+type hlsMP4StreamParent interface {
+	logger.Writer
+}
+
+// hlsMP4Stream is a reader that sends a path to a HTTP client
+// in the form of a progressive, fragmented MP4 file,
+// that can be played directly by <video> tags and by generic clients,
+// without the playlists of HLS.
+type hlsMP4Stream struct {
+	externalAuthenticationURL string
+	authBanList               *authBanList
+	tokenSecret               string
+	revokedTokens             *hlsRevokedTokens
+	authRealm                 string
+	authErrorBody             string
+	readBufferCount           int
+	pathName                  string
+	ginCtx                    *gin.Context
+	pathManager               hlsMP4StreamPathManager
+	parent                    hlsMP4StreamParent
+
+	ctx       context.Context
+	ctxCancel func()
+}
+
+func newHLSMP4Stream(
+	parentCtx context.Context,
+	externalAuthenticationURL string,
+	authBanList *authBanList,
+	tokenSecret string,
+	revokedTokens *hlsRevokedTokens,
+	authRealm string,
+	authErrorBody string,
+	readBufferCount int,
+	pathName string,
+	ginCtx *gin.Context,
+	pathManager hlsMP4StreamPathManager,
+	parent hlsMP4StreamParent,
+) *hlsMP4Stream {
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	return &hlsMP4Stream{
+		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		tokenSecret:               tokenSecret,
+		revokedTokens:             revokedTokens,
+		authRealm:                 authRealm,
+		authErrorBody:             authErrorBody,
+		readBufferCount:           readBufferCount,
+		pathName:                  pathName,
+		ginCtx:                    ginCtx,
+		pathManager:               pathManager,
+		parent:                    parent,
+		ctx:                       ctx,
+		ctxCancel:                 ctxCancel,
+	}
+}
+
+// Log is the main logging function.
+func (s *hlsMP4Stream) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[mp4 stream %v] "+format, append([]interface{}{s.ginCtx.Request.RemoteAddr}, args...)...)
+}
+
+// close implements reader.
+func (s *hlsMP4Stream) close() {
+	s.ctxCancel()
+}
+
+// apiReaderDescribe implements reader.
+func (s *hlsMP4Stream) apiReaderDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"hlsMP4Stream"}
+}
+
+// run serves the stream until the client disconnects or the path is closed.
+func (s *hlsMP4Stream) run() {
+	defer s.ctxCancel()
+
+	res := s.pathManager.readerAdd(pathReaderAddReq{
+		author:   s,
+		pathName: s.pathName,
+		query:    s.ginCtx.Request.URL.RawQuery,
+		protocol: "hls",
+		authenticate: func(
+			pathIPs []fmt.Stringer,
+			pathUser conf.Credential,
+			pathPass conf.Credential,
+			pathPermissions conf.PathPermissions,
+		) error {
+			return hlsAuthenticate(
+				s.ginCtx,
+				s.pathName,
+				pathIPs,
+				pathUser,
+				pathPass,
+				pathPermissions,
+				s.externalAuthenticationURL,
+				s.authBanList,
+				s.tokenSecret,
+				s.revokedTokens)
+		},
+	})
+	if res.err != nil {
+		switch terr := res.err.(type) {
+		case pathErrAuthCritical:
+			s.Log(logger.Info, "authentication error: %s", terr.message)
+
+			if s.authBanList != nil {
+				s.authBanList.onFailure(net.ParseIP(s.ginCtx.ClientIP()))
+			}
+
+			hlsWriteUnauthorized(s.ginCtx, s.ginCtx.Writer, s.authRealm, s.authErrorBody)
+
+		case pathErrAuthNotCritical:
+			hlsWriteUnauthorized(s.ginCtx, s.ginCtx.Writer, s.authRealm, s.authErrorBody)
+
+		default:
+			// the path doesn't exist, or its source didn't become ready in time.
+			s.ginCtx.Writer.WriteHeader(http.StatusNotFound)
+		}
+		return
+	}
+
+	defer res.path.readerRemove(pathReaderRemoveReq{author: s})
+
+	ringBuffer, _ := ringbuffer.New(uint64(s.readBufferCount))
+
+	m := &hlsMP4StreamMuxer{
+		w: &hlsMP4StreamWriter{w: s.ginCtx.Writer},
+	}
+
+	var medias media.Medias
+
+	videoMedia := s.setupVideo(res.stream, ringBuffer, m)
+	if videoMedia != nil {
+		medias = append(medias, videoMedia)
+	}
+
+	audioMedia := s.setupAudio(res.stream, ringBuffer, m)
+	if audioMedia != nil {
+		medias = append(medias, audioMedia)
+	}
+
+	defer res.stream.readerRemove(s)
+
+	if medias == nil {
+		err := newReaderErrCodecsUnsupported("hls", hlsSupportedCodecs, res.stream.medias())
+		s.Log(logger.Info, "closed (%v)", err)
+		s.pathManager.readerRejected(s.pathName, "hls", pathReaderRejectionCodecUnsupported, err.Error())
+		err.writeHTTP(s.ginCtx.Writer)
+		return
+	}
+
+	s.Log(logger.Info, "is reading from path '%s', %s",
+		res.path.name, sourceMediaInfo(medias))
+
+	s.ginCtx.Header("Content-Type", "video/mp4")
+	s.ginCtx.Header("Cache-Control", "no-cache")
+	s.ginCtx.Writer.WriteHeader(http.StatusOK)
+	s.ginCtx.Writer.Flush()
+
+	go func() {
+		select {
+		case <-s.ctx.Done():
+		case <-s.ginCtx.Request.Context().Done():
+		}
+		ringBuffer.Close()
+	}()
+
+	for {
+		item, ok := ringBuffer.Pull()
+		if !ok {
+			s.Log(logger.Info, "closed")
+			return
+		}
+
+		err := item.(func() error)()
+		if err != nil {
+			s.Log(logger.Info, "closed (%v)", err)
+			return
+		}
+	}
+}
+
+func (s *hlsMP4Stream) setupVideo(
+	stream *stream,
+	ringBuffer *ringbuffer.RingBuffer,
+	m *hlsMP4StreamMuxer,
+) *media.Media {
+	var videoFormatH265 *formats.H265
+	videoMedia := stream.medias().FindFormat(&videoFormatH265)
+
+	if videoFormatH265 != nil {
+		vps, sps, pps := videoFormatH265.SafeParams()
+
+		m.videoTrack = &hlsMP4StreamTrack{
+			init: &fmp4.InitTrack{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &codecs.H265{
+					VPS: vps,
+					SPS: sps,
+					PPS: pps,
+				},
+			},
+		}
+
+		stream.readerAdd(s, videoMedia, videoFormatH265, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitH265)
+
+				if tunit.AU == nil {
+					return nil
+				}
+
+				randomAccess := false
+				for _, nalu := range tunit.AU {
+					switch h265.NALUType((nalu[0] >> 1) & 0b111111) {
+					case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+						randomAccess = true
+					}
+				}
+
+				err := m.writeVideo(tunit.PTS, tunit.AU, randomAccess)
+				if err != nil {
+					return err
+				}
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return videoMedia
+	}
+
+	var videoFormatH264 *formats.H264
+	videoMedia = stream.medias().FindFormat(&videoFormatH264)
+
+	if videoFormatH264 != nil {
+		sps, pps := videoFormatH264.SafeParams()
+
+		m.videoTrack = &hlsMP4StreamTrack{
+			init: &fmp4.InitTrack{
+				ID:        1,
+				TimeScale: 90000,
+				Codec: &codecs.H264{
+					SPS: sps,
+					PPS: pps,
+				},
+			},
+		}
+
+		stream.readerAdd(s, videoMedia, videoFormatH264, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitH264)
+
+				if tunit.AU == nil {
+					return nil
+				}
+
+				err := m.writeVideo(tunit.PTS, tunit.AU, h264.IDRPresent(tunit.AU))
+				if err != nil {
+					return err
+				}
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return videoMedia
+	}
+
+	return nil
+}
+
+func (s *hlsMP4Stream) setupAudio(
+	stream *stream,
+	ringBuffer *ringbuffer.RingBuffer,
+	m *hlsMP4StreamMuxer,
+) *media.Media {
+	var audioFormatMPEG4Audio *formats.MPEG4Audio
+	audioMedia := stream.medias().FindFormat(&audioFormatMPEG4Audio)
+
+	if audioFormatMPEG4Audio != nil {
+		m.audioTrack = &hlsMP4StreamTrack{
+			init: &fmp4.InitTrack{
+				ID:        2,
+				TimeScale: uint32(audioFormatMPEG4Audio.ClockRate()),
+				Codec: &codecs.MPEG4Audio{
+					Config: *audioFormatMPEG4Audio.Config,
+				},
+			},
+		}
+
+		stream.readerAdd(s, audioMedia, audioFormatMPEG4Audio, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitMPEG4Audio)
+
+				if tunit.AUs == nil {
+					return nil
+				}
+
+				for i, au := range tunit.AUs {
+					err := m.writeAudio(
+						tunit.PTS+time.Duration(i)*mpeg4audio.SamplesPerAccessUnit*
+							time.Second/time.Duration(audioFormatMPEG4Audio.ClockRate()),
+						au)
+					if err != nil {
+						return err
+					}
+				}
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return audioMedia
+	}
+
+	var audioFormatOpus *formats.Opus
+	audioMedia = stream.medias().FindFormat(&audioFormatOpus)
+
+	if audioFormatOpus != nil {
+		m.audioTrack = &hlsMP4StreamTrack{
+			init: &fmp4.InitTrack{
+				ID:        2,
+				TimeScale: 48000,
+				Codec: &codecs.Opus{
+					Channels: func() int {
+						if audioFormatOpus.IsStereo {
+							return 2
+						}
+						return 1
+					}(),
+				},
+			},
+		}
+
+		stream.readerAdd(s, audioMedia, audioFormatOpus, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitOpus)
+
+				err := m.writeAudio(tunit.PTS, tunit.Frame)
+				if err != nil {
+					return err
+				}
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return audioMedia
+	}
+
+	return nil
+}
+
+// hlsMP4StreamWriter sends every write to the client immediately.
+type hlsMP4StreamWriter struct {
+	w gin.ResponseWriter
+}
+
+func (w *hlsMP4StreamWriter) Write(p []byte) (int, error) {
+	n, err := w.w.Write(p)
+	if err != nil {
+		return n, err
+	}
+
+	w.w.Flush()
+	return n, nil
+}
@@ -3,14 +3,17 @@ package core
 import (
 	"bytes"
 	"context"
-	_ "embed"
 	"errors"
 	"fmt"
+	"hash/fnv"
 	"io"
 	"net"
 	"net/http"
+	"net/url"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"sync"
 	"sync/atomic"
 	"time"
@@ -22,6 +25,7 @@ import (
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
 	"github.com/gin-gonic/gin"
 
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
@@ -29,34 +33,187 @@ import (
 )
 
 const (
-	closeCheckPeriod      = 1 * time.Second
-	closeAfterInactivity  = 60 * time.Second
 	hlsMuxerRecreatePause = 10 * time.Second
 )
 
-//go:embed hls_index.html
-var hlsIndex []byte
-
+// responseWriterWithCounter is a http.ResponseWriter that counts sent bytes.
+// Writing of the status code is delayed until the body is written, in order to allow
+// ReadFrom to fill the Content-Length header, that is needed to send files with sendfile().
 type responseWriterWithCounter struct {
 	http.ResponseWriter
 	bytesSent *uint64
+
+	statusCode    int
+	headerWritten bool
+}
+
+func (w *responseWriterWithCounter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *responseWriterWithCounter) writeHeaderNow() {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if w.statusCode != 0 {
+			w.ResponseWriter.WriteHeader(w.statusCode)
+		}
+	}
 }
 
 func (w *responseWriterWithCounter) Write(p []byte) (int, error) {
+	w.writeHeaderNow()
 	n, err := w.ResponseWriter.Write(p)
 	atomic.AddUint64(w.bytesSent, uint64(n))
 	return n, err
 }
 
+// ReadFrom implements io.ReaderFrom.
+func (w *responseWriterWithCounter) ReadFrom(r io.Reader) (int64, error) {
+	if f, ok := r.(*os.File); ok && !w.headerWritten &&
+		(w.statusCode == 0 || w.statusCode == http.StatusOK) &&
+		w.Header().Get("Content-Length") == "" {
+		if size, err := hlsFileRemainingSize(f); err == nil {
+			w.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+	}
+
+	w.writeHeaderNow()
+	n, err := httpCopy(w.ResponseWriter, r)
+	atomic.AddUint64(w.bytesSent, uint64(n))
+	return n, err
+}
+
+func hlsFileRemainingSize(f *os.File) (int64, error) {
+	fi, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+
+	pos, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return 0, err
+	}
+
+	return fi.Size() - pos, nil
+}
+
+// responseWriterWithCacheControl is a http.ResponseWriter that sets
+// the Cache-Control header of successful responses.
+type responseWriterWithCacheControl struct {
+	http.ResponseWriter
+	cacheControl string
+
+	headerWritten bool
+}
+
+func (w *responseWriterWithCacheControl) WriteHeader(statusCode int) {
+	if !w.headerWritten {
+		w.headerWritten = true
+		if statusCode == http.StatusOK ||
+			statusCode == http.StatusPartialContent ||
+			statusCode == http.StatusNotModified {
+			w.Header().Set("Cache-Control", w.cacheControl)
+		}
+	}
+	w.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (w *responseWriterWithCacheControl) Write(p []byte) (int, error) {
+	if !w.headerWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+// ReadFrom implements io.ReaderFrom.
+func (w *responseWriterWithCacheControl) ReadFrom(r io.Reader) (int64, error) {
+	if !w.headerWritten {
+		w.WriteHeader(http.StatusOK)
+	}
+	return httpCopy(w.ResponseWriter, r)
+}
+
+// hlsFileWriter is a http.ResponseWriter that receives segments, parts and init files from the muxer
+// and sends them with http.ServeContent, in order to support Range requests and ETags.
+// Files stored on disk are sent directly, while files stored in RAM are buffered.
+type hlsFileWriter struct {
+	http.ResponseWriter
+	req *http.Request
+
+	statusCode int
+	buf        bytes.Buffer
+	served     bool
+}
+
+func (w *hlsFileWriter) WriteHeader(statusCode int) {
+	if w.statusCode == 0 {
+		w.statusCode = statusCode
+	}
+}
+
+func (w *hlsFileWriter) Write(p []byte) (int, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+	return w.buf.Write(p)
+}
+
+// ReadFrom implements io.ReaderFrom.
+func (w *hlsFileWriter) ReadFrom(r io.Reader) (int64, error) {
+	if w.statusCode == 0 {
+		w.statusCode = http.StatusOK
+	}
+
+	if f, ok := r.(*os.File); ok && w.statusCode == http.StatusOK && w.buf.Len() == 0 && !w.served {
+		fi, err := f.Stat()
+		if err == nil {
+			w.served = true
+			w.Header().Set("ETag", fmt.Sprintf(`"%x-%x"`, fi.ModTime().UnixNano(), fi.Size()))
+			http.ServeContent(w.ResponseWriter, w.req, "", fi.ModTime(), f)
+			return fi.Size(), nil
+		}
+	}
+
+	return w.buf.ReadFrom(r)
+}
+
+// flush sends the buffered file to the underlying writer.
+func (w *hlsFileWriter) flush() {
+	if w.served || w.statusCode == 0 {
+		return
+	}
+
+	if w.statusCode != http.StatusOK {
+		w.ResponseWriter.WriteHeader(w.statusCode)
+		w.ResponseWriter.Write(w.buf.Bytes())
+		return
+	}
+
+	h := fnv.New64a()
+	h.Write(w.buf.Bytes())
+	w.Header().Set("ETag", fmt.Sprintf(`"%x"`, h.Sum64()))
+
+	http.ServeContent(w.ResponseWriter, w.req, "", time.Time{}, bytes.NewReader(w.buf.Bytes()))
+}
+
+type hlsMuxerRequestRes struct {
+	muxer *hlsMuxer
+	err   error
+}
+
 type hlsMuxerRequest struct {
 	path     string
 	file     string
+	query    string
 	clientIP string
-	res      chan *hlsMuxer
+	res      chan hlsMuxerRequestRes
 }
 
 type hlsMuxerPathManager interface {
 	readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes
+	readerRejected(pathName string, protocol string, reason pathReaderRejectionReason, message string)
 }
 
 type hlsMuxerParent interface {
@@ -66,15 +223,26 @@ type hlsMuxerParent interface {
 
 type hlsMuxer struct {
 	remoteAddr                string
+	query                     string
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	tokenSecret               string
+	revokedTokens             *hlsRevokedTokens
+	authRealm                 string
+	authErrorBody             string
 	alwaysRemux               bool
 	variant                   conf.HLSVariant
 	segmentCount              int
 	segmentDuration           conf.StringDuration
 	partDuration              conf.StringDuration
 	segmentMaxSize            conf.StringSize
+	playlistLength            conf.StringDuration
 	directory                 string
 	readBufferCount           int
+	closeAfter                conf.StringDuration
+	checkPeriod               conf.StringDuration
+	index                     *hlsIndex
+	sequence                  *hlsMuxerSequence
 	wg                        *sync.WaitGroup
 	pathName                  string
 	pathManager               hlsMuxerPathManager
@@ -84,11 +252,14 @@ type hlsMuxer struct {
 	ctxCancel       func()
 	created         time.Time
 	path            *path
+	aliasConf       *conf.PathConf
 	ringBuffer      *ringbuffer.RingBuffer
 	lastRequestTime *int64
 	muxer           *gohlslib.Muxer
+	cues            *hlsCues
 	requests        []*hlsMuxerRequest
 	bytesSent       *uint64
+	pushedSegments  *hlsPushedSegments
 
 	// in
 	chRequest          chan *hlsMuxerRequest
@@ -98,15 +269,26 @@ type hlsMuxer struct {
 func newHLSMuxer(
 	parentCtx context.Context,
 	remoteAddr string,
+	query string,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	tokenSecret string,
+	revokedTokens *hlsRevokedTokens,
+	authRealm string,
+	authErrorBody string,
 	alwaysRemux bool,
 	variant conf.HLSVariant,
 	segmentCount int,
 	segmentDuration conf.StringDuration,
 	partDuration conf.StringDuration,
 	segmentMaxSize conf.StringSize,
+	playlistLength conf.StringDuration,
 	directory string,
 	readBufferCount int,
+	closeAfter conf.StringDuration,
+	checkPeriod conf.StringDuration,
+	index *hlsIndex,
+	sequence *hlsMuxerSequence,
 	wg *sync.WaitGroup,
 	pathName string,
 	pathManager hlsMuxerPathManager,
@@ -116,15 +298,26 @@ func newHLSMuxer(
 
 	m := &hlsMuxer{
 		remoteAddr:                remoteAddr,
+		query:                     query,
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		tokenSecret:               tokenSecret,
+		revokedTokens:             revokedTokens,
+		authRealm:                 authRealm,
+		authErrorBody:             authErrorBody,
 		alwaysRemux:               alwaysRemux,
 		variant:                   variant,
 		segmentCount:              segmentCount,
 		segmentDuration:           segmentDuration,
 		partDuration:              partDuration,
 		segmentMaxSize:            segmentMaxSize,
+		playlistLength:            playlistLength,
 		directory:                 directory,
 		readBufferCount:           readBufferCount,
+		closeAfter:                closeAfter,
+		checkPeriod:               checkPeriod,
+		index:                     index,
+		sequence:                  sequence,
 		wg:                        wg,
 		pathName:                  pathName,
 		pathManager:               pathManager,
@@ -137,6 +330,7 @@ func newHLSMuxer(
 			return &v
 		}(),
 		bytesSent:          new(uint64),
+		pushedSegments:     newHLSPushedSegments(),
 		chRequest:          make(chan *hlsMuxerRequest),
 		chAPIHLSMuxersList: make(chan hlsServerAPIMuxersListSubReq),
 	}
@@ -203,10 +397,10 @@ func (m *hlsMuxer) run() {
 			case req := <-m.chRequest:
 				switch {
 				case isRecreating:
-					req.res <- nil
+					req.res <- hlsMuxerRequestRes{}
 
 				case isReady:
-					req.res <- m
+					req.res <- hlsMuxerRequestRes{muxer: m}
 
 				default:
 					m.requests = append(m.requests, req)
@@ -217,13 +411,14 @@ func (m *hlsMuxer) run() {
 					Created:     m.created,
 					LastRequest: time.Unix(0, atomic.LoadInt64(m.lastRequestTime)),
 					BytesSent:   atomic.LoadUint64(m.bytesSent),
+					TimeToClose: m.timeToClose(),
 				}
 				close(req.res)
 
 			case <-innerReady:
 				isReady = true
 				for _, req := range m.requests {
-					req.res <- m
+					req.res <- hlsMuxerRequestRes{muxer: m}
 				}
 				m.requests = nil
 
@@ -232,7 +427,7 @@ func (m *hlsMuxer) run() {
 
 				if m.alwaysRemux {
 					m.Log(logger.Info, "ERR: %v", err)
-					m.clearQueuedRequests()
+					m.clearQueuedRequests(err)
 					isReady = false
 					isRecreating = true
 					recreateTimer = time.NewTimer(hlsMuxerRecreatePause)
@@ -249,16 +444,16 @@ func (m *hlsMuxer) run() {
 
 	m.ctxCancel()
 
-	m.clearQueuedRequests()
+	m.clearQueuedRequests(err)
 
 	m.parent.muxerClose(m)
 
 	m.Log(logger.Info, "destroyed (%v)", err)
 }
 
-func (m *hlsMuxer) clearQueuedRequests() {
+func (m *hlsMuxer) clearQueuedRequests(err error) {
 	for _, req := range m.requests {
-		req.res <- nil
+		req.res <- hlsMuxerRequestRes{err: err}
 	}
 	m.requests = nil
 }
@@ -267,18 +462,22 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 	res := m.pathManager.readerAdd(pathReaderAddReq{
 		author:   m,
 		pathName: m.pathName,
+		query:    m.query,
+		protocol: "hls",
 	})
 	if res.err != nil {
 		return res.err
 	}
 
 	m.path = res.path
+	m.aliasConf = res.aliasConf
 
 	defer func() {
 		m.path.readerRemove(pathReaderRemoveReq{author: m})
 	}()
 
 	m.ringBuffer, _ = ringbuffer.New(uint64(m.readBufferCount))
+	m.cues = &hlsCues{}
 
 	var medias media.Medias
 
@@ -292,11 +491,14 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 		medias = append(medias, audioMedia)
 	}
 
+	m.createSCTE35Track(res.stream)
+
 	defer res.stream.readerRemove(m)
 
 	if medias == nil {
-		return fmt.Errorf(
-			"the stream doesn't contain any supported codec, which are currently H264, H265, MPEG4-Audio, Opus")
+		err := newReaderErrCodecsUnsupported("hls", hlsSupportedCodecs, res.stream.medias())
+		m.pathManager.readerRejected(m.pathName, "hls", pathReaderRejectionCodecUnsupported, err.Error())
+		return err
 	}
 
 	var muxerDirectory string
@@ -323,6 +525,8 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 	}
 	defer m.muxer.Close()
 
+	m.sequence.restart()
+
 	innerReady <- struct{}{}
 
 	m.Log(logger.Info, "is converting into HLS, %s",
@@ -333,7 +537,7 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 		writerDone <- m.runWriter()
 	}()
 
-	closeCheckTicker := time.NewTicker(closeCheckPeriod)
+	closeCheckTicker := time.NewTicker(time.Duration(m.checkPeriod))
 	defer closeCheckTicker.Stop()
 
 	for {
@@ -341,7 +545,7 @@ func (m *hlsMuxer) runInner(innerCtx context.Context, innerReady chan struct{})
 		case <-closeCheckTicker.C:
 			if m.remoteAddr != "" {
 				t := time.Unix(0, atomic.LoadInt64(m.lastRequestTime))
-				if time.Since(t) >= closeAfterInactivity {
+				if time.Since(t) >= time.Duration(m.closeAfter) {
 					m.ringBuffer.Close()
 					<-writerDone
 					return fmt.Errorf("not used anymore")
@@ -386,6 +590,10 @@ func (m *hlsMuxer) createVideoTrack(stream *stream) (*media.Media, *gohlslib.Tra
 					return fmt.Errorf("muxer error: %v", err)
 				}
 
+				m.cues.setReference(tunit.PTS, tunit.NTP)
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -427,6 +635,10 @@ func (m *hlsMuxer) createVideoTrack(stream *stream) (*media.Media, *gohlslib.Tra
 					return fmt.Errorf("muxer error: %v", err)
 				}
 
+				m.cues.setReference(tunit.PTS, tunit.NTP)
+
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -477,6 +689,8 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 					}
 				}
 
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -513,6 +727,8 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 					return fmt.Errorf("muxer error: %v", err)
 				}
 
+				stream.latency.observe(streamLatencyHLS, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -529,9 +745,37 @@ func (m *hlsMuxer) createAudioTrack(stream *stream) (*media.Media, *gohlslib.Tra
 		}
 	}
 
+	var audioFormatG711 *formats.G711
+	var audioFormatG722 *formats.G722
+	if stream.medias().FindFormat(&audioFormatG711) != nil ||
+		stream.medias().FindFormat(&audioFormatG722) != nil {
+		m.Log(logger.Warn, "skipping G711/G722 audio track, since it is not supported by HLS; "+
+			"it must be transcoded into AAC or Opus, for instance with runOnReady and FFmpeg")
+	}
+
 	return nil, nil
 }
 
+// createSCTE35Track reads SCTE-35 cues, that are inserted into playlists.
+func (m *hlsMuxer) createSCTE35Track(stream *stream) {
+	medi, forma := scte35FindFormat(stream.medias())
+	if forma == nil {
+		return
+	}
+
+	stream.readerAdd(m, medi, forma, func(unit formatprocessor.Unit) {
+		m.ringBuffer.Push(func() error {
+			cue, hasPTS, err := scte35UnitCue(unit)
+			if err != nil {
+				return nil
+			}
+
+			m.cues.add(cue, unit.(*formatprocessor.UnitGeneric).PTS, hasPTS, unit.GetNTP())
+			return nil
+		})
+	})
+}
+
 func (m *hlsMuxer) runWriter() error {
 	for {
 		item, ok := m.ringBuffer.Pull()
@@ -546,51 +790,189 @@ func (m *hlsMuxer) runWriter() error {
 	}
 }
 
-func (m *hlsMuxer) handleRequest(ctx *gin.Context) {
+func (m *hlsMuxer) handleRequest(
+	ctx *gin.Context,
+	playlistBaseURL string,
+	hinter *hlsSegmentHinter,
+	cacheControl string,
+) {
 	atomic.StoreInt64(m.lastRequestTime, time.Now().UnixNano())
 
-	w := &responseWriterWithCounter{
+	cw := &responseWriterWithCounter{
 		ResponseWriter: ctx.Writer,
 		bytesSent:      m.bytesSent,
 	}
+	defer cw.writeHeaderNow()
+
+	var w http.ResponseWriter = cw
+
+	if cacheControl != "" {
+		w = &responseWriterWithCacheControl{
+			ResponseWriter: w,
+			cacheControl:   cacheControl,
+		}
+	}
 
 	err := m.authenticate(ctx)
 	if err != nil {
 		if terr, ok := err.(pathErrAuthCritical); ok {
 			m.Log(logger.Info, "authentication error: %s", terr.message)
+			m.pathManager.readerRejected(m.pathName, "hls", pathReaderRejectionAuth, terr.message)
+
+			if m.authBanList != nil {
+				m.authBanList.onFailure(net.ParseIP(ctx.ClientIP()))
+			}
 		}
 
-		ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
-		w.WriteHeader(http.StatusUnauthorized)
+		hlsWriteUnauthorized(ctx, w, m.authRealm, m.authErrorBody)
 		return
 	}
 
 	if ctx.Request.URL.Path == "" {
 		ctx.Header("Content-Type", `text/html`)
 		w.WriteHeader(http.StatusOK)
-		io.Copy(w, bytes.NewReader(hlsIndex))
+		err := m.index.render(w, m.pathName)
+		if err != nil {
+			m.Log(logger.Warn, "unable to render the player page: %v", err)
+		}
+		return
+	}
+
+	// propagate the token to the URIs inside playlists, since players don't do it.
+	var query string
+	if m.tokenSecret != "" {
+		if token := ctx.Query(hlsTokenParam); token != "" {
+			query = hlsTokenParam + "=" + url.QueryEscape(token)
+		}
+	}
+
+	if !m.sequence.translateRequest(ctx.Request.URL) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if strings.HasSuffix(ctx.Request.URL.Path, ".m3u8") {
+		if m.playlistLength != 0 {
+			hlsPlaylistWindowRequest(ctx.Request.URL)
+		}
+
+		rw := &hlsPlaylistRewriter{
+			ResponseWriter: w,
+			baseURL:        playlistBaseURL,
+			query:          query,
+		}
+		m.muxer.Handle(rw, ctx.Request)
+		if rw.statusCode == http.StatusOK {
+			byts := m.sequence.renumber(rw.buf.Bytes())
+			if m.playlistLength != 0 {
+				byts = hlsPlaylistWindow(byts, time.Duration(m.playlistLength))
+			}
+			byts = m.cues.insert(byts)
+			rw.buf.Reset()
+			rw.buf.Write(byts)
+			if hinter != nil {
+				hinter.hint(ctx, rw.buf.Bytes(), query, m.pushedSegments)
+			}
+		}
+		rw.flush()
 		return
 	}
 
-	m.muxer.Handle(w, ctx.Request)
+	fw := &hlsFileWriter{
+		ResponseWriter: w,
+		req:            ctx.Request,
+	}
+	m.muxer.Handle(fw, ctx.Request)
+	fw.flush()
 }
 
 func (m *hlsMuxer) authenticate(ctx *gin.Context) error {
-	pathConf := m.path.safeConf()
-	pathIPs := pathConf.ReadIPs
-	pathUser := pathConf.ReadUser
-	pathPass := pathConf.ReadPass
+	// readers that use an alias are authenticated with the settings of the alias.
+	pathConf := m.aliasConf
+	if pathConf == nil {
+		pathConf = m.path.safeConf()
+	}
 
-	if m.externalAuthenticationURL != "" {
+	return hlsAuthenticate(
+		ctx,
+		m.pathName,
+		pathConf.ReadIPs,
+		pathConf.ReadUser,
+		pathConf.ReadPass,
+		pathConf.Permissions,
+		m.externalAuthenticationURL,
+		m.authBanList,
+		m.tokenSecret,
+		m.revokedTokens)
+}
+
+// hlsWriteUnauthorized writes the response of a request that failed authentication.
+func hlsWriteUnauthorized(ctx *gin.Context, w http.ResponseWriter, authRealm string, authErrorBody string) {
+	ctx.Header("WWW-Authenticate", `Basic realm="`+authRealm+`"`)
+
+	if authErrorBody != "" {
+		ctx.Header("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		w.Write([]byte(authErrorBody))
+		return
+	}
+
+	w.WriteHeader(http.StatusUnauthorized)
+}
+
+// hlsAuthenticate authenticates a HTTP request of a reader of the HLS server.
+func hlsAuthenticate(
+	ctx *gin.Context,
+	pathName string,
+	pathIPs []fmt.Stringer,
+	pathUser conf.Credential,
+	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
+	externalAuthenticationURL string,
+	authBanList *authBanList,
+	tokenSecret string,
+	revokedTokens *hlsRevokedTokens,
+) error {
+	if authBanList != nil {
+		err := authBanList.check(net.ParseIP(ctx.ClientIP()))
+		if err != nil {
+			return pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
+	// a valid token replaces any other authentication method.
+	if tokenSecret != "" {
+		if token := ctx.Query(hlsTokenParam); token != "" {
+			now := time.Now()
+
+			err := hlsTokenCheck(tokenSecret, pathName, token, now)
+			if err != nil {
+				return pathErrAuthCritical{
+					message: err.Error(),
+				}
+			}
+
+			if revokedTokens.has(token, now) {
+				return pathErrAuthCritical{
+					message: "token has been revoked",
+				}
+			}
+			return nil
+		}
+	}
+
+	if externalAuthenticationURL != "" {
 		ip := net.ParseIP(ctx.ClientIP())
 		user, pass, ok := ctx.Request.BasicAuth()
 
 		err := externalAuth(
-			m.externalAuthenticationURL,
+			externalAuthenticationURL,
 			ip.String(),
 			user,
 			pass,
-			m.pathName,
+			pathName,
 			externalAuthProtoHLS,
 			nil,
 			false,
@@ -609,7 +991,7 @@ func (m *hlsMuxer) authenticate(ctx *gin.Context) error {
 	if pathIPs != nil {
 		ip := net.ParseIP(ctx.ClientIP())
 
-		if !ipEqualOrInRange(ip, pathIPs) {
+		if !auth.IPEqualOrInRange(ip, pathIPs) {
 			return pathErrAuthCritical{
 				message: fmt.Sprintf("IP '%s' not allowed", ip),
 			}
@@ -622,13 +1004,36 @@ func (m *hlsMuxer) authenticate(ctx *gin.Context) error {
 			return pathErrAuthNotCritical{}
 		}
 
-		if user != string(pathUser) || pass != string(pathPass) {
+		if !auth.CredentialMatches(pathUser, user) || !auth.CredentialMatches(pathPass, pass) {
 			return pathErrAuthCritical{
 				message: "invalid credentials",
 			}
 		}
 	}
 
+	if len(pathPermissions) != 0 {
+		user, pass, ok := ctx.Request.BasicAuth()
+
+		err := auth.Check(pathPermissions, auth.Request{
+			IP:       net.ParseIP(ctx.ClientIP()),
+			Action:   auth.ActionRead,
+			Protocol: auth.ProtocolHLS,
+			CheckCredentials: func(pathUser conf.Credential, pathPass conf.Credential) bool {
+				return auth.CredentialMatches(pathUser, user) &&
+					auth.CredentialMatches(pathPass, pass)
+			},
+		})
+		if err != nil {
+			if err == auth.ErrInvalidCredentials && !ok {
+				return pathErrAuthNotCritical{}
+			}
+
+			return pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -637,8 +1042,23 @@ func (m *hlsMuxer) processRequest(req *hlsMuxerRequest) {
 	select {
 	case m.chRequest <- req:
 	case <-m.ctx.Done():
-		req.res <- nil
+		req.res <- hlsMuxerRequestRes{}
+	}
+}
+
+// timeToClose returns the seconds after which the muxer is closed because of inactivity,
+// or nil if the muxer is not closed because of inactivity.
+func (m *hlsMuxer) timeToClose() *float64 {
+	if m.remoteAddr == "" {
+		return nil
+	}
+
+	t := time.Unix(0, atomic.LoadInt64(m.lastRequestTime))
+	v := (time.Duration(m.closeAfter) - time.Since(t)).Seconds()
+	if v < 0 {
+		v = 0
 	}
+	return &v
 }
 
 // apiMuxersList is called by api.
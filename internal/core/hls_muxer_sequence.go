@@ -0,0 +1,207 @@
+package core
+
+import (
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+var hlsMuxerSequenceFileRe = regexp.MustCompile(`^(seg|part)([0-9]+)(\.[a-z0-9]+)$`)
+
+// hlsMuxerSequenceRename adds an offset to the ID contained in the name of a segment or part.
+// When remove is true, the offset is subtracted instead; false is returned if the result is negative.
+func hlsMuxerSequenceRename(name string, msnOffset uint64, partOffset uint64, remove bool) (string, uint64, bool) {
+	m := hlsMuxerSequenceFileRe.FindStringSubmatch(name)
+	if m == nil {
+		return name, 0, true
+	}
+
+	id, err := strconv.ParseUint(m[2], 10, 64)
+	if err != nil {
+		return name, 0, true
+	}
+
+	offset := msnOffset
+	if m[1] == "part" {
+		offset = partOffset
+	}
+
+	if remove {
+		if id < offset {
+			return "", 0, false
+		}
+		id -= offset
+	} else {
+		id += offset
+	}
+
+	return m[1] + strconv.FormatUint(id, 10) + m[3], id, true
+}
+
+// hlsMuxerSequenceRenumber adds offsets to the media sequence number and to the names
+// of segments and parts of a media playlist, and sets its discontinuity sequence number.
+// It returns the rewritten playlist, the next media sequence number, the next part ID
+// and whether the playlist is a media playlist; multivariant playlists are returned untouched.
+func hlsMuxerSequenceRenumber(
+	byts []byte,
+	msnOffset uint64,
+	partOffset uint64,
+	discontinuity uint64,
+) ([]byte, uint64, uint64, bool) {
+	lines := strings.Split(string(byts), "\n")
+	out := make([]string, 0, len(lines)+1)
+
+	isMedia := false
+	var msn uint64
+	var nextPart uint64
+	trailingParts := false
+
+	for _, line := range lines {
+		switch {
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			v, err := strconv.ParseUint(line[len("#EXT-X-MEDIA-SEQUENCE:"):], 10, 64)
+			if err != nil {
+				return byts, 0, 0, false
+			}
+
+			isMedia = true
+			msn = v + msnOffset
+			out = append(out, "#EXT-X-MEDIA-SEQUENCE:"+strconv.FormatUint(v+msnOffset, 10))
+
+			if discontinuity != 0 {
+				out = append(out, "#EXT-X-DISCONTINUITY-SEQUENCE:"+strconv.FormatUint(discontinuity, 10))
+			}
+			continue
+
+		case strings.HasPrefix(line, "#EXT-X-SKIP:"):
+			for _, attr := range strings.Split(line[len("#EXT-X-SKIP:"):], ",") {
+				if v := strings.TrimPrefix(attr, "SKIPPED-SEGMENTS="); v != attr {
+					n, _ := strconv.ParseUint(v, 10, 64)
+					msn += n
+				}
+			}
+
+		case strings.HasPrefix(line, "#"):
+			line = hlsPlaylistURIRe.ReplaceAllStringFunc(line, func(attr string) string {
+				name, id, _ := hlsMuxerSequenceRename(attr[len(`URI="`):len(attr)-1], msnOffset, partOffset, false)
+				if strings.HasPrefix(name, "part") && id+1 > nextPart {
+					nextPart = id + 1
+				}
+				return `URI="` + name + `"`
+			})
+
+			if strings.HasPrefix(line, "#EXT-X-PART:") {
+				trailingParts = true
+			}
+
+		case line != "":
+			line, _, _ = hlsMuxerSequenceRename(line, msnOffset, partOffset, false)
+			msn++
+			trailingParts = false
+		}
+
+		out = append(out, line)
+	}
+
+	if !isMedia {
+		return byts, 0, 0, false
+	}
+
+	// parts of the segment that is being generated have been served too.
+	if trailingParts {
+		msn++
+	}
+
+	return []byte(strings.Join(out, "\n")), msn, nextPart, true
+}
+
+// hlsMuxerSequence keeps media sequence numbers and names of segments and parts
+// of a path increasing when its muxer is recreated, and increases the discontinuity
+// sequence number, in order not to break players that cached a previous playlist.
+type hlsMuxerSequence struct {
+	mutex sync.Mutex
+
+	msnOffset     uint64
+	partOffset    uint64
+	discontinuity uint64
+
+	// media sequence number and part ID that follow the ones that have been served.
+	served   bool
+	nextMSN  uint64
+	nextPart uint64
+}
+
+// restart is called when a muxer is created.
+func (s *hlsMuxerSequence) restart() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if !s.served {
+		return
+	}
+
+	s.served = false
+	s.msnOffset = s.nextMSN
+	s.partOffset = s.nextPart
+	s.discontinuity++
+}
+
+// renumber rewrites a playlist generated by the current muxer.
+func (s *hlsMuxerSequence) renumber(byts []byte) []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	byts, nextMSN, nextPart, ok := hlsMuxerSequenceRenumber(byts, s.msnOffset, s.partOffset, s.discontinuity)
+	if !ok {
+		return byts
+	}
+
+	s.served = true
+
+	if nextMSN > s.nextMSN {
+		s.nextMSN = nextMSN
+	}
+	if nextPart > s.nextPart {
+		s.nextPart = nextPart
+	}
+
+	return byts
+}
+
+// translateRequest converts media sequence numbers and file names of a request
+// into the ones of the current muxer. It returns false if the requested file
+// belongs to a previous muxer.
+func (s *hlsMuxerSequence) translateRequest(u *url.URL) bool {
+	s.mutex.Lock()
+	msnOffset := s.msnOffset
+	partOffset := s.partOffset
+	s.mutex.Unlock()
+
+	if msnOffset == 0 && partOffset == 0 {
+		return true
+	}
+
+	q := u.Query()
+	if v := q.Get("_HLS_msn"); v != "" {
+		if msn, err := strconv.ParseUint(v, 10, 64); err == nil {
+			if msn < msnOffset {
+				msn = 0
+			} else {
+				msn -= msnOffset
+			}
+			q.Set("_HLS_msn", strconv.FormatUint(msn, 10))
+			u.RawQuery = q.Encode()
+		}
+	}
+
+	i := strings.LastIndex(u.Path, "/")
+	name, _, ok := hlsMuxerSequenceRename(u.Path[i+1:], msnOffset, partOffset, true)
+	if !ok {
+		return false
+	}
+	u.Path = u.Path[:i+1] + name
+
+	return true
+}
@@ -0,0 +1,128 @@
+package core
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// hlsPlaylistWindowRequest removes the delta update request from the query of a playlist request.
+// Delta updates skip segments that the player received before, that may have been
+// removed from the window in the meanwhile, therefore full playlists are always served.
+func hlsPlaylistWindowRequest(u *url.URL) {
+	q := u.Query()
+	if q.Has("_HLS_skip") {
+		q.Del("_HLS_skip")
+		u.RawQuery = q.Encode()
+	}
+}
+
+// hlsPlaylistWindow limits a media playlist to segments that started within
+// the given duration from the live edge, and advances its media sequence number
+// by the number of removed segments.
+// The result is a live playlist without EXT-X-PLAYLIST-TYPE, since event playlists
+// can't have segments removed (RFC 8216, section 4.3.3.5).
+// Multivariant playlists are returned untouched.
+func hlsPlaylistWindow(byts []byte, length time.Duration) []byte {
+	lines := strings.Split(string(byts), "\n")
+
+	isMedia := false
+	var msn uint64
+	msnLine := -1
+	var segs []*hlsCuesSegment
+	var segEnds []int
+	cur := &hlsCuesSegment{firstLine: -1}
+
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+
+		switch {
+		case line == "":
+			continue
+
+		case strings.HasPrefix(line, "#EXT-X-MEDIA-SEQUENCE:"):
+			v, err := strconv.ParseUint(line[len("#EXT-X-MEDIA-SEQUENCE:"):], 10, 64)
+			if err != nil {
+				return byts
+			}
+			isMedia = true
+			msn = v
+			msnLine = i
+			continue
+
+		case strings.HasPrefix(line, "#EXT-X-SERVER-CONTROL:"):
+			var attrs []string
+			for _, attr := range strings.Split(line[len("#EXT-X-SERVER-CONTROL:"):], ",") {
+				if !strings.HasPrefix(attr, "CAN-SKIP-UNTIL=") {
+					attrs = append(attrs, attr)
+				}
+			}
+			lines[i] = "#EXT-X-SERVER-CONTROL:" + strings.Join(attrs, ",")
+			continue
+
+		case strings.HasPrefix(line, "#EXTINF:"):
+			v := line[len("#EXTINF:"):]
+			if i := strings.IndexByte(v, ','); i >= 0 {
+				v = v[:i]
+			}
+			f, err := strconv.ParseFloat(v, 64)
+			if err == nil {
+				cur.duration = time.Duration(f * float64(time.Second))
+			}
+
+		case strings.HasPrefix(line, "#EXT-X-PROGRAM-DATE-TIME:"),
+			strings.HasPrefix(line, "#EXT-X-PART:"):
+
+		case strings.HasPrefix(line, "#"):
+			continue
+
+		default:
+			if cur.firstLine < 0 {
+				cur.firstLine = i
+			}
+			segs = append(segs, cur)
+			segEnds = append(segEnds, i)
+			cur = &hlsCuesSegment{firstLine: -1}
+			continue
+		}
+
+		if cur.firstLine < 0 {
+			cur.firstLine = i
+		}
+	}
+
+	if !isMedia {
+		return byts
+	}
+
+	// find the oldest segment that starts within the window.
+	first := len(segs)
+	var age time.Duration
+	for first > 0 && age+segs[first-1].duration <= length {
+		first--
+		age += segs[first].duration
+	}
+
+	// the most recent segment is always kept.
+	if first == len(segs) && first > 0 {
+		first--
+	}
+
+	out := make([]string, 0, len(lines)+1)
+
+	for i, line := range lines {
+		if first > 0 && i >= segs[0].firstLine && i <= segEnds[first-1] {
+			continue
+		}
+
+		if i == msnLine {
+			out = append(out, "#EXT-X-MEDIA-SEQUENCE:"+strconv.FormatUint(msn+uint64(first), 10))
+			continue
+		}
+
+		out = append(out, line)
+	}
+
+	return []byte(strings.Join(out, "\n"))
+}
@@ -0,0 +1,72 @@
+package core
+
+import (
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestHLSPlaylistWindow(t *testing.T) {
+	playlist := "#EXTM3U\n" +
+		"#EXT-X-VERSION:9\n" +
+		"#EXT-X-TARGETDURATION:2\n" +
+		"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=5.00000,CAN-SKIP-UNTIL=12.00000\n" +
+		"#EXT-X-MEDIA-SEQUENCE:10\n" +
+		"#EXT-X-MAP:URI=\"init.mp4\"\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg10.mp4\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg11.mp4\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:04Z\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg12.mp4\n" +
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:06Z\n" +
+		"#EXTINF:2.00000,\n" +
+		"seg13.mp4\n" +
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part20.mp4\"\n"
+
+	require.Equal(t, "#EXTM3U\n"+
+		"#EXT-X-VERSION:9\n"+
+		"#EXT-X-TARGETDURATION:2\n"+
+		"#EXT-X-SERVER-CONTROL:CAN-BLOCK-RELOAD=YES,PART-HOLD-BACK=5.00000\n"+
+		"#EXT-X-MEDIA-SEQUENCE:12\n"+
+		"#EXT-X-MAP:URI=\"init.mp4\"\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:04Z\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg12.mp4\n"+
+		"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:06Z\n"+
+		"#EXTINF:2.00000,\n"+
+		"seg13.mp4\n"+
+		"#EXT-X-PRELOAD-HINT:TYPE=PART,URI=\"part20.mp4\"\n",
+		string(hlsPlaylistWindow([]byte(playlist), 5*time.Second)))
+
+	// segments are removed from the beginning, therefore the playlist can't be an event playlist.
+	require.NotContains(t, string(hlsPlaylistWindow([]byte(playlist), 5*time.Second)), "#EXT-X-PLAYLIST-TYPE")
+
+	// the window contains all segments.
+	require.Contains(t, string(hlsPlaylistWindow([]byte(playlist), time.Minute)),
+		"#EXT-X-MEDIA-SEQUENCE:10\n#EXT-X-MAP:URI=\"init.mp4\"\n"+
+			"#EXTINF:2.00000,\nseg10.mp4\n")
+
+	// the most recent segment is kept even if it is longer than the window.
+	require.Contains(t, string(hlsPlaylistWindow([]byte(playlist), time.Second)),
+		"#EXT-X-MEDIA-SEQUENCE:13\n#EXT-X-MAP:URI=\"init.mp4\"\n"+
+			"#EXT-X-PROGRAM-DATE-TIME:2010-01-01T01:01:06Z\n")
+
+	// multivariant playlists are not modified.
+	multivariant := "#EXTM3U\n" +
+		"#EXT-X-VERSION:9\n" +
+		"#EXT-X-STREAM-INF:BANDWIDTH=200000,CODECS=\"avc1.42c028\"\n" +
+		"stream.m3u8\n"
+	require.Equal(t, multivariant, string(hlsPlaylistWindow([]byte(multivariant), 5*time.Second)))
+}
+
+func TestHLSPlaylistWindowRequest(t *testing.T) {
+	u, err := url.Parse("http://localhost/stream/stream.m3u8?_HLS_msn=5&_HLS_skip=YES")
+	require.NoError(t, err)
+
+	hlsPlaylistWindowRequest(u)
+	require.Equal(t, "_HLS_msn=5", u.RawQuery)
+}
@@ -0,0 +1,135 @@
+package core
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// time after which the segments pushed to a connection are forgotten.
+const hlsPushedSegmentsMaxAge = 1 * time.Minute
+
+// headers of the playlist request that are copied into pushed requests,
+// in order to allow them to pass authentication.
+var hlsSegmentHinterPushHeaders = []string{"Authorization", "Cookie"}
+
+// hlsPlaylistLastSegment returns the URI of the most recent segment of a media playlist,
+// or an empty string if there's none.
+func hlsPlaylistLastSegment(byts []byte) string {
+	lines := strings.Split(string(byts), "\n")
+
+	for i := len(lines) - 1; i >= 0; i-- {
+		line := strings.TrimSpace(lines[i])
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		// multivariant playlist
+		if strings.HasSuffix(line, ".m3u8") {
+			return ""
+		}
+
+		return line
+	}
+
+	return ""
+}
+
+type hlsPushedSegmentsEntry struct {
+	uri  string
+	time time.Time
+}
+
+// hlsPushedSegments keeps track of the last segment that a muxer pushed
+// to every connection, in order to avoid pushing the same segment
+// every time a client reloads the playlist.
+type hlsPushedSegments struct {
+	mutex   sync.Mutex
+	entries map[string]hlsPushedSegmentsEntry
+}
+
+func newHLSPushedSegments() *hlsPushedSegments {
+	return &hlsPushedSegments{
+		entries: make(map[string]hlsPushedSegmentsEntry),
+	}
+}
+
+// markPushed returns false if the segment has already been pushed to the connection,
+// otherwise it records it and returns true.
+func (p *hlsPushedSegments) markPushed(conn string, uri string, now time.Time) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	for key, e := range p.entries {
+		if now.Sub(e.time) >= hlsPushedSegmentsMaxAge {
+			delete(p.entries, key)
+		}
+	}
+
+	if e, ok := p.entries[conn]; ok && e.uri == uri {
+		return false
+	}
+
+	p.entries[conn] = hlsPushedSegmentsEntry{
+		uri:  uri,
+		time: now,
+	}
+	return true
+}
+
+// hlsSegmentHinter announces the most recent segment of a media playlist
+// to the client that requested the playlist, in order to save a round trip.
+type hlsSegmentHinter struct {
+	push         bool
+	preloadHints bool
+
+	// URL path of the playlist directory, as received by the server.
+	dir string
+
+	// base URL of the playlist, as seen by clients.
+	baseURL string
+}
+
+// hint is called before writing a playlist.
+// query is appended to the URI of the segment.
+// Segments are pushed to a connection only once, since clients reload
+// the playlist many times before a new segment is available.
+func (h *hlsSegmentHinter) hint(ctx *gin.Context, playlist []byte, query string, pushed *hlsPushedSegments) {
+	uri := hlsPlaylistLastSegment(playlist)
+	if uri == "" || hlsURIIsAbsolute(uri) {
+		return
+	}
+
+	if query != "" {
+		uri += "?" + query
+	}
+
+	if h.preloadHints {
+		ctx.Writer.Header().Add("Link", "<"+h.baseURL+uri+">; rel=preload; as=fetch")
+	}
+
+	if h.push {
+		// Pusher is available with HTTP/2 only.
+		pusher := ctx.Writer.Pusher()
+		if pusher == nil {
+			return
+		}
+
+		if !pushed.markPushed(ctx.Request.RemoteAddr, uri, time.Now()) {
+			return
+		}
+
+		header := make(http.Header)
+		for _, key := range hlsSegmentHinterPushHeaders {
+			if v, ok := ctx.Request.Header[key]; ok {
+				header[key] = v
+			}
+		}
+
+		pusher.Push(h.dir+"/"+uri, &http.PushOptions{Header: header})
+	}
+}
@@ -5,8 +5,10 @@ import (
 	"crypto/tls"
 	"fmt"
 	"log"
+	"math"
 	"net"
 	"net/http"
+	"net/url"
 	gopath "path"
 	"strings"
 	"sync"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/gin-gonic/gin"
 
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
 )
@@ -28,6 +31,7 @@ type hlsServerAPIMuxersListItem struct {
 	Created     time.Time `json:"created"`
 	LastRequest time.Time `json:"lastRequest"`
 	BytesSent   uint64    `json:"bytesSent"`
+	TimeToClose *float64  `json:"timeToClose"`
 }
 
 type hlsServerAPIMuxersListData struct {
@@ -55,15 +59,34 @@ type hlsServerParent interface {
 
 type hlsServer struct {
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
+	tokenSecret               string
+	authRealm                 string
+	authErrorBody             string
 	alwaysRemux               bool
 	variant                   conf.HLSVariant
 	segmentCount              int
 	segmentDuration           conf.StringDuration
 	partDuration              conf.StringDuration
 	segmentMaxSize            conf.StringSize
-	allowOrigin               string
+	playlistLength            conf.StringDuration
+	segmentPush               bool
+	preloadHints              bool
+	allowOrigins              []string
+	allowHeaders              []string
+	allowCredentials          bool
+	playlistCacheControl      string
+	segmentCacheControl       string
+	trustedProxies            conf.IPsOrCIDRs
+	baseURL                   *url.URL
 	directory                 string
+	staticDirectory           string
 	readBufferCount           int
+	muxerCloseAfter           conf.StringDuration
+	muxerCheckPeriod          conf.StringDuration
+	pathGroupSuffixes         []string
+	index                     *hlsIndex
 	pathManager               *pathManager
 	metrics                   *metrics
 	parent                    hlsServerParent
@@ -72,12 +95,23 @@ type hlsServer struct {
 	ctxCancel  func()
 	wg         sync.WaitGroup
 	ln         net.Listener
+	certLoader *certLoader
 	httpServer *http.Server
 	muxers     map[string]*hlsMuxer
 
+	// tokens revoked through the API.
+	revokedTokens *hlsRevokedTokens
+
+	// MP4 streams, that are long-lived and must be closed when their user or token is revoked.
+	sessions *authSessions
+
+	// sequences of paths, that survive the muxers.
+	sequences map[string]*hlsMuxerSequence
+
 	// in
 	chPathSourceReady    chan *path
 	chPathSourceNotReady chan *path
+	chPathSourceReplaced chan *path
 	request              chan *hlsMuxerRequest
 	chMuxerClose         chan *hlsMuxer
 	chAPIMuxerList       chan hlsServerAPIMuxersListReq
@@ -86,65 +120,151 @@ type hlsServer struct {
 func newHLSServer(
 	parentCtx context.Context,
 	address string,
+	ipFilter conf.IPFilter,
+	socketOptions conf.SocketOptions,
 	encryption bool,
 	serverKey string,
 	serverCert string,
+	acmeManager *acmeManager,
+	tlsMinVersion conf.TLSVersion,
+	tlsCipherSuites conf.TLSCipherSuites,
+	tlsLogHandshakes bool,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
+	tokenSecret string,
+	revokedTokens *hlsRevokedTokens,
+	authRealm string,
+	authErrorBody string,
 	alwaysRemux bool,
 	variant conf.HLSVariant,
 	segmentCount int,
 	segmentDuration conf.StringDuration,
 	partDuration conf.StringDuration,
 	segmentMaxSize conf.StringSize,
-	allowOrigin string,
+	playlistLength conf.StringDuration,
+	segmentPush bool,
+	preloadHints bool,
+	allowOrigins []string,
+	allowHeaders []string,
+	allowCredentials bool,
+	playlistCacheControl string,
+	segmentCacheControl string,
 	trustedProxies conf.IPsOrCIDRs,
+	baseURL string,
 	directory string,
+	indexFile string,
+	posterURL string,
+	staticDirectory string,
 	readTimeout conf.StringDuration,
 	readBufferCount int,
+	muxerCloseAfter conf.StringDuration,
+	muxerCheckPeriod conf.StringDuration,
+	pathGroupSuffixes []string,
 	pathManager *pathManager,
 	metrics *metrics,
 	parent hlsServerParent,
 ) (*hlsServer, error) {
-	ln, err := net.Listen(restrictNetwork("tcp", address))
+	ln, err := listenMultiple("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
+	ln = newIPFilterListener(ln, ipFilter, parent)
+	ln = newSocketOptionsListener(ln, socketOptions)
+
 	var tlsConfig *tls.Config
+	var certLoader *certLoader
 	if encryption {
-		crt, err := tls.LoadX509KeyPair(serverCert, serverKey)
+		tlsConfig, certLoader, err = loadTLSConfig(serverCert, serverKey, acmeManager,
+			tlsMinVersion, tlsCipherSuites, tlsLogHandshakes, parent)
 		if err != nil {
 			ln.Close()
 			return nil, err
 		}
+	}
 
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{crt},
+	// keep enough segments to cover the DVR window
+	if playlistLength != 0 {
+		n := int(math.Ceil(float64(playlistLength) / float64(segmentDuration)))
+		if n > segmentCount {
+			segmentCount = n
 		}
 	}
 
+	var parsedBaseURL *url.URL
+	if baseURL != "" {
+		parsedBaseURL, err = url.Parse(baseURL)
+		if err != nil {
+			ln.Close()
+			if certLoader != nil {
+				certLoader.close()
+			}
+			return nil, err
+		}
+		parsedBaseURL.Path = strings.TrimSuffix(parsedBaseURL.Path, "/")
+	}
+
+	assetsURL := "/" + hlsAssetsPrefix
+	if parsedBaseURL != nil {
+		assetsURL = parsedBaseURL.Path + assetsURL
+	}
+
+	index, err := newHLSIndex(indexFile, posterURL, assetsURL)
+	if err != nil {
+		ln.Close()
+		if certLoader != nil {
+			certLoader.close()
+		}
+		return nil, err
+	}
+
 	ctx, ctxCancel := context.WithCancel(parentCtx)
 
 	s := &hlsServer{
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
+		tokenSecret:               tokenSecret,
+		authRealm:                 authRealm,
+		authErrorBody:             authErrorBody,
 		alwaysRemux:               alwaysRemux,
 		variant:                   variant,
 		segmentCount:              segmentCount,
 		segmentDuration:           segmentDuration,
 		partDuration:              partDuration,
 		segmentMaxSize:            segmentMaxSize,
-		allowOrigin:               allowOrigin,
+		playlistLength:            playlistLength,
+		segmentPush:               segmentPush,
+		preloadHints:              preloadHints,
+		allowOrigins:              allowOrigins,
+		allowHeaders:              allowHeaders,
+		allowCredentials:          allowCredentials,
+		playlistCacheControl:      playlistCacheControl,
+		segmentCacheControl:       segmentCacheControl,
+		trustedProxies:            trustedProxies,
+		baseURL:                   parsedBaseURL,
 		directory:                 directory,
+		staticDirectory:           staticDirectory,
 		readBufferCount:           readBufferCount,
+		muxerCloseAfter:           muxerCloseAfter,
+		muxerCheckPeriod:          muxerCheckPeriod,
+		pathGroupSuffixes:         pathGroupSuffixes,
+		index:                     index,
 		pathManager:               pathManager,
 		parent:                    parent,
 		metrics:                   metrics,
 		ctx:                       ctx,
 		ctxCancel:                 ctxCancel,
 		ln:                        ln,
+		certLoader:                certLoader,
 		muxers:                    make(map[string]*hlsMuxer),
+		sequences:                 make(map[string]*hlsMuxerSequence),
+		revokedTokens:             revokedTokens,
+		sessions:                  newAuthSessions(),
 		chPathSourceReady:         make(chan *path),
 		chPathSourceNotReady:      make(chan *path),
+		chPathSourceReplaced:      make(chan *path),
 		request:                   make(chan *hlsMuxerRequest),
 		chMuxerClose:              make(chan *hlsMuxer),
 		chAPIMuxerList:            make(chan hlsServerAPIMuxersListReq),
@@ -153,6 +273,10 @@ func newHLSServer(
 	router := gin.New()
 	httpSetTrustedProxies(router, trustedProxies)
 
+	if s.metrics != nil {
+		router.Use(s.metrics.requests.middleware("hls"))
+	}
+
 	router.NoRoute(httpLoggerMiddleware(s), httpServerHeaderMiddleware, s.onRequest)
 
 	s.httpServer = &http.Server{
@@ -185,6 +309,10 @@ func (s *hlsServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *hlsServer) run() {
@@ -201,7 +329,10 @@ outer:
 		select {
 		case pa := <-s.chPathSourceReady:
 			if s.alwaysRemux {
-				s.createMuxer(pa.name, "")
+				// a muxer may have been requested by a reader that started an on-demand path.
+				if _, ok := s.muxers[pa.name]; !ok {
+					s.createMuxer(pa.name, "", "")
+				}
 			}
 
 		case pa := <-s.chPathSourceNotReady:
@@ -213,19 +344,26 @@ outer:
 				}
 			}
 
-		case req := <-s.request:
-			r, ok := s.muxers[req.path]
-			switch {
-			case ok:
-				r.processRequest(req)
+		case pa := <-s.chPathSourceReplaced:
+			// recreate the muxer, in order to start a new discontinuity sequence
+			// that contains segments of the new source only.
+			if c, ok := s.muxers[pa.name]; ok {
+				c.close()
+				delete(s.muxers, pa.name)
 
-			case s.alwaysRemux:
-				req.res <- nil
+				if s.alwaysRemux {
+					s.createMuxer(pa.name, "", "")
+				}
+			}
 
-			default:
-				r := s.createMuxer(req.path, req.clientIP)
-				r.processRequest(req)
+		case req := <-s.request:
+			r, ok := s.muxers[req.path]
+			if !ok {
+				// when alwaysRemux is enabled, muxers are created by requests too,
+				// in order to allow readers to start on-demand paths.
+				r = s.createMuxer(req.path, req.clientIP, req.query)
 			}
+			r.processRequest(req)
 
 		case c := <-s.chMuxerClose:
 			if c2, ok := s.muxers[c.PathName()]; !ok || c2 != c {
@@ -262,15 +400,18 @@ outer:
 }
 
 func (s *hlsServer) onRequest(ctx *gin.Context) {
-	ctx.Writer.Header().Set("Access-Control-Allow-Origin", s.allowOrigin)
-	ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	s.setCORSHeaders(ctx)
 
 	switch ctx.Request.Method {
 	case http.MethodGet:
 
 	case http.MethodOptions:
 		ctx.Writer.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-		ctx.Writer.Header().Set("Access-Control-Allow-Headers", ctx.Request.Header.Get("Access-Control-Request-Headers"))
+		if len(s.allowHeaders) != 0 {
+			ctx.Writer.Header().Set("Access-Control-Allow-Headers", strings.Join(s.allowHeaders, ", "))
+		} else {
+			ctx.Writer.Header().Set("Access-Control-Allow-Headers", ctx.Request.Header.Get("Access-Control-Request-Headers"))
+		}
 		ctx.Writer.WriteHeader(http.StatusOK)
 		return
 
@@ -278,14 +419,43 @@ func (s *hlsServer) onRequest(ctx *gin.Context) {
 		return
 	}
 
+	if s.accessList != nil {
+		err := s.accessList.checkAction(net.ParseIP(ctx.ClientIP()), false)
+		if err != nil {
+			s.Log(logger.Info, "[conn %v] %v", ctx.Request.RemoteAddr, err)
+			ctx.Writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	origPath := ctx.Request.URL.Path
+	reqPath := origPath
+
+	// remove the path prefix of the base URL, if the proxy didn't.
+	if prefix := s.pathPrefix(); prefix != "" {
+		if reqPath == prefix {
+			reqPath = "/"
+		} else if strings.HasPrefix(reqPath, prefix+"/") {
+			reqPath = reqPath[len(prefix):]
+		}
+	}
+
 	// remove leading prefix
-	pa := ctx.Request.URL.Path[1:]
+	pa := reqPath[1:]
 
 	switch pa {
 	case "", "favicon.ico":
 		return
 	}
 
+	if strings.HasPrefix(pa, hlsAssetsPrefix) {
+		if s.staticDirectory != "" {
+			ctx.Request.URL.Path = "/" + strings.TrimPrefix(pa, hlsAssetsPrefix)
+			http.FileServer(http.Dir(s.staticDirectory)).ServeHTTP(ctx.Writer, ctx.Request)
+		}
+		return
+	}
+
 	dir, fname := func() (string, string) {
 		if strings.HasSuffix(pa, ".m3u8") ||
 			strings.HasSuffix(pa, ".ts") ||
@@ -297,7 +467,7 @@ func (s *hlsServer) onRequest(ctx *gin.Context) {
 	}()
 
 	if fname == "" && !strings.HasSuffix(dir, "/") {
-		ctx.Writer.Header().Set("Location", "/"+dir+"/")
+		ctx.Writer.Header().Set("Location", s.pathPrefix()+"/"+dir+"/")
 		ctx.Writer.WriteHeader(http.StatusMovedPermanently)
 		return
 	}
@@ -308,38 +478,211 @@ func (s *hlsServer) onRequest(ctx *gin.Context) {
 
 	dir = strings.TrimSuffix(dir, "/")
 
+	if fname == hlsMP4StreamFile {
+		st := newHLSMP4Stream(
+			s.ctx,
+			s.externalAuthenticationURL,
+			s.authBanList,
+			s.tokenSecret,
+			s.revokedTokens,
+			s.authRealm,
+			s.authErrorBody,
+			s.readBufferCount,
+			dir,
+			ctx,
+			s.pathManager,
+			s,
+		)
+
+		user, _, _ := ctx.Request.BasicAuth()
+		s.sessions.add(st, newAuthIdentity(user, ctx.Request.URL.RawQuery))
+		defer s.sessions.remove(st)
+
+		st.run()
+		return
+	}
+
+	if s.handleGroupRequest(ctx, dir, fname) {
+		return
+	}
+
 	hreq := &hlsMuxerRequest{
 		path:     dir,
 		file:     fname,
+		query:    ctx.Request.URL.RawQuery,
 		clientIP: ctx.ClientIP(),
-		res:      make(chan *hlsMuxer),
+		res:      make(chan hlsMuxerRequestRes),
 	}
 
 	select {
 	case s.request <- hreq:
-		muxer := <-hreq.res
-		if muxer != nil {
-			ctx.Request.URL.Path = fname
-			muxer.handleRequest(ctx)
+		res := <-hreq.res
+		muxer := res.muxer
+		if muxer == nil {
+			if terr, ok := res.err.(readerErrCodecsUnsupported); ok {
+				terr.writeHTTP(ctx.Writer)
+				return
+			}
+
+			// the path doesn't exist, or its source didn't become ready in time.
+			ctx.Writer.WriteHeader(http.StatusNotFound)
+			return
 		}
 
+		playlistBaseURL := s.playlistBaseURL(ctx, dir)
+
+		var hinter *hlsSegmentHinter
+		if s.segmentPush || s.preloadHints {
+			hinter = &hlsSegmentHinter{
+				push:         s.segmentPush,
+				preloadHints: s.preloadHints,
+				dir:          gopath.Dir(origPath),
+				baseURL:      playlistBaseURL,
+			}
+		}
+
+		ctx.Request.URL.Path = fname
+		muxer.handleRequest(ctx, playlistBaseURL, hinter, s.cacheControl(fname))
+
 	case <-s.ctx.Done():
 	}
 }
 
-func (s *hlsServer) createMuxer(pathName string, remoteAddr string) *hlsMuxer {
+// setCORSHeaders sets the CORS headers of a response.
+// Since browsers don't accept credentials when the allowed origin is a wildcard,
+// the origin of the request is sent back when credentials are allowed.
+func (s *hlsServer) setCORSHeaders(ctx *gin.Context) {
+	origin := ctx.Request.Header.Get("Origin")
+
+	var allowOrigin string
+
+	for _, o := range s.allowOrigins {
+		if o == "*" {
+			if s.allowCredentials && origin != "" {
+				allowOrigin = origin
+			} else {
+				allowOrigin = "*"
+			}
+			break
+		}
+
+		if o == origin {
+			allowOrigin = origin
+			break
+		}
+	}
+
+	if allowOrigin != "*" {
+		ctx.Writer.Header().Add("Vary", "Origin")
+	}
+
+	if allowOrigin == "" {
+		return
+	}
+
+	ctx.Writer.Header().Set("Access-Control-Allow-Origin", allowOrigin)
+
+	if s.allowCredentials {
+		ctx.Writer.Header().Set("Access-Control-Allow-Credentials", "true")
+	}
+}
+
+// cacheControl returns the value of the Cache-Control header of a file.
+func (s *hlsServer) cacheControl(fname string) string {
+	switch {
+	case strings.HasSuffix(fname, ".m3u8"):
+		return s.playlistCacheControl
+
+	case strings.HasSuffix(fname, ".ts"), strings.HasSuffix(fname, ".mp4"):
+		return s.segmentCacheControl
+	}
+
+	return ""
+}
+
+func (s *hlsServer) pathPrefix() string {
+	if s.baseURL == nil {
+		return ""
+	}
+	return s.baseURL.Path
+}
+
+func (s *hlsServer) isTrustedProxy(ctx *gin.Context) bool {
+	ip := net.ParseIP(ctx.RemoteIP())
+	return ip != nil && auth.IPEqualOrInRange(ip, s.trustedProxies)
+}
+
+// playlistBaseURL returns the URL that is prepended to URIs inside playlists.
+// When the base URL doesn't contain a host, the scheme and host are taken
+// from the request or from the X-Forwarded-* headers of trusted proxies.
+func (s *hlsServer) playlistBaseURL(ctx *gin.Context, dir string) string {
+	if s.baseURL == nil {
+		return ""
+	}
+
+	u := *s.baseURL
+
+	if u.Host == "" {
+		if ctx.Request.TLS != nil {
+			u.Scheme = "https"
+		} else {
+			u.Scheme = "http"
+		}
+		u.Host = ctx.Request.Host
+
+		if s.isTrustedProxy(ctx) {
+			if proto := httpFirstHeaderValue(ctx, "X-Forwarded-Proto"); proto == "http" || proto == "https" {
+				u.Scheme = proto
+			}
+			if host := httpFirstHeaderValue(ctx, "X-Forwarded-Host"); host != "" {
+				u.Host = host
+			}
+		}
+	}
+
+	u.Path += "/" + dir + "/"
+
+	return u.String()
+}
+
+func httpFirstHeaderValue(ctx *gin.Context, key string) string {
+	v := ctx.Request.Header.Get(key)
+	if i := strings.IndexByte(v, ','); i >= 0 {
+		v = v[:i]
+	}
+	return strings.TrimSpace(v)
+}
+
+func (s *hlsServer) createMuxer(pathName string, remoteAddr string, query string) *hlsMuxer {
+	sequence, ok := s.sequences[pathName]
+	if !ok {
+		sequence = &hlsMuxerSequence{}
+		s.sequences[pathName] = sequence
+	}
+
 	r := newHLSMuxer(
 		s.ctx,
 		remoteAddr,
+		query,
 		s.externalAuthenticationURL,
-		s.alwaysRemux,
+		s.authBanList,
+		s.tokenSecret,
+		s.revokedTokens,
+		s.authRealm,
+		s.authErrorBody,
+		s.alwaysRemux && remoteAddr == "",
 		s.variant,
 		s.segmentCount,
 		s.segmentDuration,
 		s.partDuration,
 		s.segmentMaxSize,
+		s.playlistLength,
 		s.directory,
 		s.readBufferCount,
+		s.muxerCloseAfter,
+		s.muxerCheckPeriod,
+		s.index,
+		sequence,
 		&s.wg,
 		pathName,
 		s.pathManager,
@@ -372,6 +715,28 @@ func (s *hlsServer) pathSourceNotReady(pa *path) {
 	}
 }
 
+// pathSourceReplaced is called by pathManager.
+func (s *hlsServer) pathSourceReplaced(pa *path) {
+	select {
+	case s.chPathSourceReplaced <- pa:
+	case <-s.ctx.Done():
+	}
+}
+
+// apiTokenRevoke is called by api.
+func (s *hlsServer) apiTokenRevoke(token string) bool {
+	if s.tokenSecret == "" {
+		return false
+	}
+
+	return s.revokedTokens.add(token, time.Now())
+}
+
+// apiSessionsRevoke is called by api.
+func (s *hlsServer) apiSessionsRevoke(user string, token string) int {
+	return s.sessions.revoke(user, token)
+}
+
 // apiMuxersList is called by api.
 func (s *hlsServer) apiMuxersList() hlsServerAPIMuxersListRes {
 	req := hlsServerAPIMuxersListReq{
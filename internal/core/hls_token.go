@@ -0,0 +1,140 @@
+package core
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// name of the query parameter that contains the token.
+const hlsTokenParam = "token"
+
+func hlsTokenSignature(secret string, pathName string, expires string) []byte {
+	h := hmac.New(sha256.New, []byte(secret))
+	h.Write([]byte(pathName + ":" + expires))
+	return h.Sum(nil)
+}
+
+// hlsTokenSign generates a token that allows to read a path until the given time.
+// The token is in the format EXPIRES-SIGNATURE, where EXPIRES is a Unix timestamp
+// and SIGNATURE is the hex-encoded HMAC-SHA256 of "PATH:EXPIRES".
+func hlsTokenSign(secret string, pathName string, expires time.Time) string {
+	e := strconv.FormatInt(expires.Unix(), 10)
+	return e + "-" + hex.EncodeToString(hlsTokenSignature(secret, pathName, e))
+}
+
+// hlsTokenCheck checks a token generated by hlsTokenSign.
+func hlsTokenCheck(secret string, pathName string, token string, now time.Time) error {
+	parts := strings.SplitN(token, "-", 2)
+	if len(parts) != 2 {
+		return fmt.Errorf("invalid token")
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return fmt.Errorf("invalid token")
+	}
+
+	sig, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return fmt.Errorf("invalid token")
+	}
+
+	if !hmac.Equal(sig, hlsTokenSignature(secret, pathName, parts[0])) {
+		return fmt.Errorf("invalid token")
+	}
+
+	if now.Unix() >= expires {
+		return fmt.Errorf("token is expired")
+	}
+
+	return nil
+}
+
+// hlsTokenExpiration returns the expiration time of a token generated by hlsTokenSign.
+func hlsTokenExpiration(token string) (time.Time, bool) {
+	parts := strings.SplitN(token, "-", 2)
+	if len(parts) != 2 {
+		return time.Time{}, false
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return time.Unix(expires, 0), true
+}
+
+// hlsTokenNormalize returns the canonical form of a token generated by hlsTokenSign,
+// in order to compare tokens that are decoded in the same way but are written differently,
+// for instance with an uppercase signature. Tokens that can't be decoded are returned as is.
+func hlsTokenNormalize(token string) string {
+	parts := strings.SplitN(token, "-", 2)
+	if len(parts) != 2 {
+		return token
+	}
+
+	expires, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return token
+	}
+
+	sig, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return token
+	}
+
+	return strconv.FormatInt(expires, 10) + "-" + hex.EncodeToString(sig)
+}
+
+// hlsRevokedTokens contains tokens that have been revoked before their expiration.
+type hlsRevokedTokens struct {
+	mutex  sync.Mutex
+	tokens map[string]time.Time
+}
+
+func newHLSRevokedTokens() *hlsRevokedTokens {
+	return &hlsRevokedTokens{
+		tokens: make(map[string]time.Time),
+	}
+}
+
+func (t *hlsRevokedTokens) removeExpired(now time.Time) {
+	for token, expires := range t.tokens {
+		if !now.Before(expires) {
+			delete(t.tokens, token)
+		}
+	}
+}
+
+// add revokes a token until its expiration.
+// It returns false if the token is not a valid token or it is already expired.
+func (t *hlsRevokedTokens) add(token string, now time.Time) bool {
+	expires, ok := hlsTokenExpiration(token)
+	if !ok || !now.Before(expires) {
+		return false
+	}
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.removeExpired(now)
+	t.tokens[hlsTokenNormalize(token)] = expires
+	return true
+}
+
+// has checks whether a token has been revoked.
+func (t *hlsRevokedTokens) has(token string, now time.Time) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.removeExpired(now)
+	_, ok := t.tokens[hlsTokenNormalize(token)]
+	return ok
+}
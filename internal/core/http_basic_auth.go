@@ -0,0 +1,30 @@
+package core
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/auth"
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// httpBasicAuthMiddleware returns a middleware that requires the given credentials.
+// If credentials are empty, all requests are accepted.
+func httpBasicAuthMiddleware(user conf.Credential, pass conf.Credential) func(*gin.Context) {
+	return func(ctx *gin.Context) {
+		if user == "" {
+			ctx.Next()
+			return
+		}
+
+		reqUser, reqPass, ok := ctx.Request.BasicAuth()
+		if !ok || !auth.CredentialMatches(user, reqUser) || !auth.CredentialMatches(pass, reqPass) {
+			ctx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
+			ctx.AbortWithStatus(http.StatusUnauthorized)
+			return
+		}
+
+		ctx.Next()
+	}
+}
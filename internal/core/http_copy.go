@@ -0,0 +1,48 @@
+package core
+
+import (
+	"io"
+	"net/http"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+)
+
+// size of the buffers used to copy response bodies.
+const httpCopyBufferSize = 32 * 1024
+
+var httpCopyBufferPool = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, httpCopyBufferSize)
+		return &buf
+	},
+}
+
+// httpWriterOnly hides the io.ReaderFrom implementation of a writer,
+// in order to avoid infinite recursion in io.CopyBuffer.
+type httpWriterOnly struct {
+	io.Writer
+}
+
+// httpCopy copies a response body from r to w.
+// When w is backed by net/http and r is a file, the file is sent with sendfile(),
+// without copying it into user space. Otherwise, a pooled buffer is used.
+func httpCopy(w http.ResponseWriter, r io.Reader) (int64, error) {
+	if rf, ok := w.(io.ReaderFrom); ok {
+		return rf.ReadFrom(r)
+	}
+
+	// the writer of gin doesn't implement io.ReaderFrom.
+	// Write the header through it, then bypass it.
+	if gw, ok := w.(gin.ResponseWriter); ok {
+		if uw, ok := gw.(interface{ Unwrap() http.ResponseWriter }); ok {
+			gw.WriteHeaderNow()
+			return httpCopy(uw.Unwrap(), r)
+		}
+	}
+
+	buf := httpCopyBufferPool.Get().(*[]byte)
+	defer httpCopyBufferPool.Put(buf)
+
+	return io.CopyBuffer(httpWriterOnly{w}, r, *buf)
+}
@@ -0,0 +1,419 @@
+package core
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+
+	"github.com/aler9/mediamtx/internal/auth"
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/rtmp/message"
+	"github.com/aler9/mediamtx/internal/rtmp/tracks"
+)
+
+// httpIngestBodyReader reads a request body and extends the read deadline
+// of the underlying connection before every read.
+type httpIngestBodyReader struct {
+	ctx         context.Context
+	body        io.Reader
+	rc          *http.ResponseController
+	readTimeout conf.StringDuration
+}
+
+func (r *httpIngestBodyReader) Read(p []byte) (int, error) {
+	if r.ctx.Err() != nil {
+		return 0, fmt.Errorf("terminated")
+	}
+	r.rc.SetReadDeadline(time.Now().Add(time.Duration(r.readTimeout)))
+	return r.body.Read(p)
+}
+
+type httpIngestConnPathManager interface {
+	publisherAdd(req pathPublisherAddReq) pathPublisherAnnounceRes
+}
+
+type httpIngestConnParent interface {
+	logger.Writer
+}
+
+type httpIngestConn struct {
+	externalAuthenticationURL string
+	authBanList               *authBanList
+	authPauseAfterError       conf.StringDuration
+	readTimeout               conf.StringDuration
+	pathName                  string
+	ginCtx                    *gin.Context
+	pathManager               httpIngestConnPathManager
+	parent                    httpIngestConnParent
+
+	ctx       context.Context
+	ctxCancel func()
+	uuid      uuid.UUID
+}
+
+func newHTTPIngestConn(
+	parentCtx context.Context,
+	externalAuthenticationURL string,
+	authBanList *authBanList,
+	authPauseAfterError conf.StringDuration,
+	readTimeout conf.StringDuration,
+	pathName string,
+	ginCtx *gin.Context,
+	pathManager httpIngestConnPathManager,
+	parent httpIngestConnParent,
+) *httpIngestConn {
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	return &httpIngestConn{
+		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		authPauseAfterError:       authPauseAfterError,
+		readTimeout:               readTimeout,
+		pathName:                  pathName,
+		ginCtx:                    ginCtx,
+		pathManager:               pathManager,
+		parent:                    parent,
+		ctx:                       ctx,
+		ctxCancel:                 ctxCancel,
+		uuid:                      uuid.New(),
+	}
+}
+
+// close implements publisher.
+func (c *httpIngestConn) close() {
+	c.ctxCancel()
+}
+
+// Log is the main logging function.
+func (c *httpIngestConn) Log(level logger.Level, format string, args ...interface{}) {
+	c.parent.Log(level, "[conn %v] "+format, append([]interface{}{c.ginCtx.Request.RemoteAddr}, args...)...)
+}
+
+func (c *httpIngestConn) ip() net.IP {
+	return net.ParseIP(c.ginCtx.ClientIP())
+}
+
+// run reads the request body until the client stops sending it.
+func (c *httpIngestConn) run() {
+	defer c.ctxCancel()
+
+	c.Log(logger.Info, "opened")
+
+	err := c.runInner()
+
+	if terr, ok := err.(pathErrAuthCritical); ok {
+		c.Log(logger.Info, "authentication error: %s", terr.message)
+
+		if c.authBanList != nil {
+			c.authBanList.onFailure(c.ip())
+		}
+
+		// wait some seconds to stop brute force attacks
+		select {
+		case <-time.After(time.Duration(c.authPauseAfterError)):
+		case <-c.ctx.Done():
+		}
+	}
+
+	switch err.(type) {
+	case pathErrAuthNotCritical, pathErrAuthCritical:
+		c.ginCtx.Header("WWW-Authenticate", `Basic realm="mediamtx"`)
+		c.ginCtx.Writer.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, io.ErrUnexpectedEOF) &&
+		!errors.Is(err, astits.ErrNoMorePackets) {
+		c.Log(logger.Info, "closed (%v)", err)
+		c.ginCtx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c.Log(logger.Info, "closed")
+	c.ginCtx.Writer.WriteHeader(http.StatusOK)
+}
+
+func (c *httpIngestConn) runInner() error {
+	res := c.pathManager.publisherAdd(pathPublisherAddReq{
+		author:   c,
+		pathName: c.pathName,
+		authenticate: func(
+			pathIPs []fmt.Stringer,
+			pathUser conf.Credential,
+			pathPass conf.Credential,
+			pathPermissions conf.PathPermissions,
+		) (authIdentity, error) {
+			return c.authenticate(pathIPs, pathUser, pathPass, pathPermissions)
+		},
+	})
+	if res.err != nil {
+		return res.err
+	}
+
+	path := res.path
+
+	defer func() {
+		path.publisherRemove(pathPublisherRemoveReq{author: c})
+	}()
+
+	br := bufio.NewReader(&httpIngestBodyReader{
+		ctx:         c.ctx,
+		body:        c.ginCtx.Request.Body,
+		rc:          http.NewResponseController(c.ginCtx.Writer),
+		readTimeout: c.readTimeout,
+	})
+
+	readerErr := make(chan error)
+
+	go func() {
+		readerErr <- func() error {
+			// detect the container format from the first bytes of the body
+			sig, err := br.Peek(3)
+			if err != nil {
+				return err
+			}
+
+			switch {
+			case sig[0] == 0x47:
+				return c.runMPEGTS(path, br)
+
+			case string(sig) == "FLV":
+				return c.runFLV(path, br)
+
+			default:
+				return fmt.Errorf("unsupported body format, only MPEG-TS and FLV are supported")
+			}
+		}()
+	}()
+
+	select {
+	case err := <-readerErr:
+		return err
+
+	case <-c.ctx.Done():
+		// unblock the reader
+		http.NewResponseController(c.ginCtx.Writer).SetReadDeadline(time.Now())
+		<-readerErr
+		return fmt.Errorf("terminated")
+	}
+}
+
+func (c *httpIngestConn) runMPEGTS(path *path, r io.Reader) error {
+	dem := astits.NewDemuxer(
+		context.Background(),
+		r,
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+	tsTracks, err := mpegtsFindTracks(dem)
+	if err != nil {
+		return err
+	}
+
+	medias, writers := mpegtsMedias(tsTracks, c)
+
+	stream, err := c.publisherStart(path, medias)
+	if err != nil {
+		return err
+	}
+
+	return mpegtsReadData(dem, stream, writers, func() {})
+}
+
+func (c *httpIngestConn) runFLV(path *path, r io.Reader) error {
+	fr := message.NewFLVReader(r)
+
+	videoFormat, audioFormat, err := tracks.Read(fr)
+	if err != nil {
+		return err
+	}
+
+	var medias media.Medias
+	var videoMedia *media.Media
+	var audioMedia *media.Media
+
+	if videoFormat != nil {
+		videoMedia = &media.Media{
+			Type:    media.TypeVideo,
+			Formats: []formats.Format{videoFormat},
+		}
+		medias = append(medias, videoMedia)
+	}
+
+	if audioFormat != nil {
+		audioMedia = &media.Media{
+			Type:    media.TypeAudio,
+			Formats: []formats.Format{audioFormat},
+		}
+		medias = append(medias, audioMedia)
+	}
+
+	stream, err := c.publisherStart(path, medias)
+	if err != nil {
+		return err
+	}
+
+	videoWriteFunc := getRTMPWriteFunc(videoMedia, videoFormat, stream)
+	audioWriteFunc := getRTMPWriteFunc(audioMedia, audioFormat, stream)
+
+	for {
+		msg, err := fr.Read()
+		if err != nil {
+			return err
+		}
+
+		switch msg.(type) {
+		case *message.Video, *message.ExtendedFramesX, *message.ExtendedCodedFrames:
+			if videoFormat == nil {
+				return fmt.Errorf("received a video packet, but track is not set up")
+			}
+
+			err := videoWriteFunc(msg)
+			if err != nil {
+				c.Log(logger.Warn, "%v", err)
+			}
+
+		case *message.Audio:
+			if audioFormat == nil {
+				return fmt.Errorf("received an audio packet, but track is not set up")
+			}
+
+			err := audioWriteFunc(msg)
+			if err != nil {
+				c.Log(logger.Warn, "%v", err)
+			}
+		}
+	}
+}
+
+func (c *httpIngestConn) publisherStart(path *path, medias media.Medias) (*stream, error) {
+	res := path.publisherStart(pathPublisherStartReq{
+		author:             c,
+		medias:             medias,
+		generateRTPPackets: true,
+	})
+	if res.err != nil {
+		return nil, res.err
+	}
+
+	c.Log(logger.Info, "is publishing to path '%s', %s",
+		path.name,
+		sourceMediaInfo(medias))
+
+	return res.stream, nil
+}
+
+func (c *httpIngestConn) authenticate(
+	pathIPs []fmt.Stringer,
+	pathUser conf.Credential,
+	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
+) (authIdentity, error) {
+	var identity authIdentity
+
+	if c.authBanList != nil {
+		err := c.authBanList.check(c.ip())
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
+	user, pass, ok := c.ginCtx.Request.BasicAuth()
+
+	if c.externalAuthenticationURL != "" {
+		err := externalAuth(
+			c.externalAuthenticationURL,
+			c.ip().String(),
+			user,
+			pass,
+			c.pathName,
+			externalAuthProtoHTTP,
+			&c.uuid,
+			true,
+			c.ginCtx.Request.URL.RawQuery)
+		if err != nil {
+			if !ok {
+				return authIdentity{}, pathErrAuthNotCritical{}
+			}
+
+			return authIdentity{}, pathErrAuthCritical{
+				message: fmt.Sprintf("external authentication failed: %s", err),
+			}
+		}
+
+		// credentials and the query have been verified by the external service.
+		identity = newAuthIdentity(user, c.ginCtx.Request.URL.RawQuery)
+	}
+
+	if pathIPs != nil {
+		ip := c.ip()
+		if !auth.IPEqualOrInRange(ip, pathIPs) {
+			return authIdentity{}, pathErrAuthCritical{
+				message: fmt.Sprintf("IP '%s' not allowed", ip),
+			}
+		}
+	}
+
+	if pathUser != "" {
+		if !ok {
+			return authIdentity{}, pathErrAuthNotCritical{}
+		}
+
+		if !auth.CredentialMatches(pathUser, user) || !auth.CredentialMatches(pathPass, pass) {
+			return authIdentity{}, pathErrAuthCritical{
+				message: "invalid credentials",
+			}
+		}
+
+		identity.user = user
+	}
+
+	if len(pathPermissions) != 0 {
+		err := auth.Check(pathPermissions, auth.Request{
+			IP:       c.ip(),
+			Action:   auth.ActionPublish,
+			Protocol: auth.ProtocolHTTP,
+			CheckCredentials: func(pathUser conf.Credential, pathPass conf.Credential) bool {
+				if auth.CredentialMatches(pathUser, user) &&
+					auth.CredentialMatches(pathPass, pass) {
+					identity.user = user
+					return true
+				}
+				return false
+			},
+		})
+		if err != nil {
+			if err == auth.ErrInvalidCredentials && !ok {
+				return authIdentity{}, pathErrAuthNotCritical{}
+			}
+
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+// apiSourceDescribe implements source.
+func (c *httpIngestConn) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}{"httpIngestConn", c.uuid.String()}
+}
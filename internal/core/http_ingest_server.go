@@ -0,0 +1,162 @@
+package core
+
+import (
+	"context"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	httpIngestPathPrefix = "/publish/"
+)
+
+type httpIngestServerParent interface {
+	logger.Writer
+}
+
+// httpIngestServer allows to publish MPEG-TS or FLV streams with HTTP POST requests,
+// in environments where only HTTP traffic is allowed.
+type httpIngestServer struct {
+	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
+	authPauseAfterError       conf.StringDuration
+	readTimeout               conf.StringDuration
+	pathManager               *pathManager
+	parent                    httpIngestServerParent
+
+	ctx         context.Context
+	ctxCancel   func()
+	ln          net.Listener
+	httpServer  *http.Server
+	requestPool *httpRequestPool
+	sessions    *authSessions
+}
+
+func newHTTPIngestServer(
+	parentCtx context.Context,
+	address string,
+	trustedProxies conf.IPsOrCIDRs,
+	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
+	authPauseAfterError conf.StringDuration,
+	readTimeout conf.StringDuration,
+	pathManager *pathManager,
+	parent httpIngestServerParent,
+) (*httpIngestServer, error) {
+	ln, err := listenMultiple("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	s := &httpIngestServer{
+		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
+		authPauseAfterError:       authPauseAfterError,
+		readTimeout:               readTimeout,
+		pathManager:               pathManager,
+		parent:                    parent,
+		ctx:                       ctx,
+		ctxCancel:                 ctxCancel,
+		ln:                        ln,
+		requestPool:               newHTTPRequestPool(),
+		sessions:                  newAuthSessions(),
+	}
+
+	// the logger middleware is not used since it reads the whole request body.
+	router := gin.New()
+	httpSetTrustedProxies(router, trustedProxies)
+
+	router.NoRoute(s.requestPool.mw, httpServerHeaderMiddleware, s.onRequest)
+
+	s.httpServer = &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(readTimeout),
+		ErrorLog:          log.New(&nilWriter{}, "", 0),
+	}
+
+	s.Log(logger.Info, "listener opened on "+address)
+
+	go s.httpServer.Serve(s.ln)
+
+	return s, nil
+}
+
+// Log is the main logging function.
+func (s *httpIngestServer) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[HTTP ingest] "+format, args...)
+}
+
+func (s *httpIngestServer) close() {
+	s.Log(logger.Info, "listener is closing")
+	s.ctxCancel()
+	s.httpServer.Shutdown(context.Background())
+	s.ln.Close() // in case Shutdown() is called before Serve()
+	s.requestPool.close()
+}
+
+func (s *httpIngestServer) onRequest(ctx *gin.Context) {
+	s.Log(logger.Debug, "[conn %v] %s %s", ctx.Request.RemoteAddr, ctx.Request.Method, ctx.Request.URL.Path)
+
+	if !strings.HasPrefix(ctx.Request.URL.Path, httpIngestPathPrefix) {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if ctx.Request.Method != http.MethodPost {
+		ctx.Writer.Header().Set("Allow", http.MethodPost)
+		ctx.Writer.WriteHeader(http.StatusMethodNotAllowed)
+		return
+	}
+
+	if s.accessList != nil {
+		err := s.accessList.checkAction(net.ParseIP(ctx.ClientIP()), true)
+		if err != nil {
+			s.Log(logger.Info, "[conn %v] %v", ctx.Request.RemoteAddr, err)
+			ctx.Writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
+	pathName := strings.TrimSuffix(ctx.Request.URL.Path[len(httpIngestPathPrefix):], "/")
+
+	err := conf.IsValidPathName(pathName)
+	if err != nil {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	c := newHTTPIngestConn(
+		s.ctx,
+		s.externalAuthenticationURL,
+		s.authBanList,
+		s.authPauseAfterError,
+		s.readTimeout,
+		pathName,
+		ctx,
+		s.pathManager,
+		s)
+
+	user, _, _ := ctx.Request.BasicAuth()
+	s.sessions.add(c, newAuthIdentity(user, ctx.Request.URL.RawQuery))
+	defer s.sessions.remove(c)
+
+	c.run()
+}
+
+// apiConnsRevoke is called by api.
+func (s *httpIngestServer) apiConnsRevoke(user string, token string) int {
+	return s.sessions.revoke(user, token)
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/rtmp/h264conf"
+	"github.com/aler9/mediamtx/internal/rtmp/message"
+)
+
+func writeFLVTag(w io.Writer, msg message.Message) error {
+	raw, err := msg.Marshal()
+	if err != nil {
+		return err
+	}
+
+	ts := uint32(raw.Timestamp / time.Millisecond)
+
+	header := []byte{
+		raw.Type,
+		byte(len(raw.Body) >> 16), byte(len(raw.Body) >> 8), byte(len(raw.Body)),
+		byte(ts >> 16), byte(ts >> 8), byte(ts), byte(ts >> 24),
+		0, 0, 0,
+	}
+
+	_, err = w.Write(append(header, raw.Body...))
+	if err != nil {
+		return err
+	}
+
+	var size [4]byte
+	binary.BigEndian.PutUint32(size[:], uint32(len(header)+len(raw.Body)))
+	_, err = w.Write(size[:])
+	return err
+}
+
+func TestHTTPIngestServerPublish(t *testing.T) {
+	for _, ca := range []string{"mpegts", "flv"} {
+		t.Run(ca, func(t *testing.T) {
+			p, ok := newInstance("httpIngest: yes\n" +
+				"paths:\n" +
+				"  all:\n" +
+				"    publishUser: myuser\n" +
+				"    publishPass: mypass\n")
+			require.Equal(t, true, ok)
+			defer p.Close()
+
+			pr, pw := io.Pipe()
+
+			req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8890/publish/mystream", pr)
+			require.NoError(t, err)
+			req.SetBasicAuth("myuser", "mypass")
+
+			hc := &http.Client{Transport: &http.Transport{}}
+
+			resDone := make(chan *http.Response)
+			go func() {
+				res, err := hc.Do(req)
+				require.NoError(t, err)
+				resDone <- res
+			}()
+
+			if ca == "mpegts" {
+				mux := astits.NewMuxer(context.Background(), pw)
+
+				mux.AddElementaryStream(astits.PMTElementaryStream{
+					ElementaryPID: 256,
+					StreamType:    astits.StreamTypeH264Video,
+				})
+
+				mux.SetPCRPID(256)
+
+				_, err = mux.WriteTables()
+				require.NoError(t, err)
+
+				_, err = mux.WriteData(&astits.MuxerData{
+					PID: 256,
+					PES: &astits.PESData{
+						Header: &astits.PESHeader{
+							OptionalHeader: &astits.PESOptionalHeader{
+								MarkerBits:      2,
+								PTSDTSIndicator: astits.PTSDTSIndicatorOnlyPTS,
+								PTS:             &astits.ClockReference{Base: int64(1 * 90000)},
+							},
+							StreamID: 224, // video
+						},
+						Data: []byte{
+							0, 0, 0, 1, 5, // IDR
+						},
+					},
+				})
+				require.NoError(t, err)
+			} else {
+				_, err = pw.Write([]byte{
+					'F', 'L', 'V', 0x01, 0x01, 0x00, 0x00, 0x00, 0x09,
+					0x00, 0x00, 0x00, 0x00,
+				})
+				require.NoError(t, err)
+
+				conf := h264conf.Conf{
+					SPS: testFormatH264.SPS,
+					PPS: testFormatH264.PPS,
+				}
+				buf, err := conf.Marshal()
+				require.NoError(t, err)
+
+				err = writeFLVTag(pw, &message.Video{
+					Codec:      message.CodecH264,
+					IsKeyFrame: true,
+					Type:       message.VideoTypeConfig,
+					Payload:    buf,
+				})
+				require.NoError(t, err)
+
+				for i := 0; i < 2; i++ {
+					err = writeFLVTag(pw, &message.Video{
+						DTS:        time.Duration(i) * time.Second,
+						Codec:      message.CodecH264,
+						IsKeyFrame: true,
+						Type:       message.VideoTypeAU,
+						Payload:    []byte{0x00, 0x00, 0x00, 0x01, 0x05},
+					})
+					require.NoError(t, err)
+				}
+			}
+
+			time.Sleep(500 * time.Millisecond)
+
+			u, err := url.Parse("rtsp://127.0.0.1:8554/mystream")
+			require.NoError(t, err)
+
+			c := gortsplib.Client{}
+			err = c.Start(u.Scheme, u.Host)
+			require.NoError(t, err)
+			defer c.Close()
+
+			medias, _, _, err := c.Describe(u)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(medias))
+			require.IsType(t, &formats.H264{}, medias[0].Formats[0])
+
+			pw.Close()
+
+			res := <-resDone
+			defer res.Body.Close()
+			require.Equal(t, http.StatusOK, res.StatusCode)
+		})
+	}
+}
+
+func TestHTTPIngestServerAuthFail(t *testing.T) {
+	p, ok := newInstance("httpIngest: yes\n" +
+		"paths:\n" +
+		"  all:\n" +
+		"    publishUser: myuser\n" +
+		"    publishPass: mypass\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	hc := &http.Client{Transport: &http.Transport{}}
+
+	res, err := hc.Post("http://127.0.0.1:8890/publish/mystream", "video/mp2t", nil)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+	require.Equal(t, `Basic realm="mediamtx"`, res.Header.Get("WWW-Authenticate"))
+}
+
+func TestHTTPIngestServerForwardedFor(t *testing.T) {
+	p, ok := newInstance("httpIngest: yes\n" +
+		"paths:\n" +
+		"  all:\n" +
+		"    publishIPs: [10.0.0.1/32]\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	hc := &http.Client{Transport: &http.Transport{}}
+
+	// X-Forwarded-For is ignored when the request doesn't come from a trusted proxy.
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8890/publish/mystream", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Forwarded-For", "10.0.0.1")
+
+	res, err := hc.Do(req)
+	require.NoError(t, err)
+	defer res.Body.Close()
+	require.Equal(t, http.StatusUnauthorized, res.StatusCode)
+}
+
+func TestHTTPIngestServerRevoke(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"httpIngest: yes\n" +
+		"paths:\n" +
+		"  all:\n" +
+		"    publishUser: myuser\n" +
+		"    publishPass: mypass\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	pr, pw := io.Pipe()
+	defer pw.Close()
+
+	req, err := http.NewRequest(http.MethodPost, "http://127.0.0.1:8890/publish/mystream", pr)
+	require.NoError(t, err)
+	req.SetBasicAuth("myuser", "mypass")
+
+	hc := &http.Client{Transport: &http.Transport{}}
+
+	resDone := make(chan struct{})
+	go func() {
+		defer close(resDone)
+		res, err := hc.Do(req)
+		if err == nil {
+			res.Body.Close()
+		}
+	}()
+
+	time.Sleep(500 * time.Millisecond)
+
+	var out struct {
+		Closed int `json:"closed"`
+	}
+	err = httpRequest(http.MethodDelete, "http://localhost:9997/v1/auth/revoke?user=myuser", nil, &out)
+	require.NoError(t, err)
+	require.Equal(t, 1, out.Closed)
+
+	<-resDone
+}
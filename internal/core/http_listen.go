@@ -0,0 +1,41 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// httpListen opens a listener for a HTTP server.
+// address can be in the format host:port or unix:///path/to/socket.
+func httpListen(address string, socketPermissions conf.FileMode) (net.Listener, error) {
+	if !strings.HasPrefix(address, "unix://") {
+		return listenMultiple("tcp", address)
+	}
+
+	fpath := address[len("unix://"):]
+
+	// remove socket left by a previous instance that didn't shut down cleanly
+	if fi, err := os.Lstat(fpath); err == nil {
+		if fi.Mode()&os.ModeSocket == 0 {
+			return nil, fmt.Errorf("'%s' already exists and is not a socket", fpath)
+		}
+		os.Remove(fpath)
+	}
+
+	ln, err := net.Listen("unix", fpath)
+	if err != nil {
+		return nil, err
+	}
+
+	err = os.Chmod(fpath, os.FileMode(socketPermissions))
+	if err != nil {
+		ln.Close()
+		return nil, err
+	}
+
+	return ln, nil
+}
@@ -0,0 +1,140 @@
+package core
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// inProcessPublisherStart adds a publisher to a path and starts publishing.
+func inProcessPublisherStart(
+	p *Core,
+	author publisher,
+	pathName string,
+	medias media.Medias,
+	generateRTPPackets bool,
+) (*path, *stream, error) {
+	pm, err := p.getPathManager()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res := pm.publisherAdd(pathPublisherAddReq{
+		author:   author,
+		pathName: pathName,
+	})
+	if res.err != nil {
+		return nil, nil, res.err
+	}
+
+	sres := res.path.publisherStart(pathPublisherStartReq{
+		author:             author,
+		medias:             medias,
+		generateRTPPackets: generateRTPPackets,
+	})
+	if sres.err != nil {
+		res.path.publisherRemove(pathPublisherRemoveReq{author: author})
+		return nil, nil, sres.err
+	}
+
+	author.Log(logger.Info, "is publishing to path '%s', %s",
+		res.path.name,
+		sourceMediaInfo(medias))
+
+	return res.path, sres.stream, nil
+}
+
+// InProcessPublisher is a publisher that lives inside the same process of the server.
+// It allows Go applications that embed Core to publish RTP packets
+// without a network hop.
+type InProcessPublisher struct {
+	uuid      uuid.UUID
+	ctx       context.Context
+	ctxCancel func()
+	parent    logger.Writer
+
+	path       *path
+	writeFuncs map[*media.Media]map[uint8]rtspWriteFunc
+}
+
+// NewPublisher creates an in-process publisher, that publishes the given medias to a path.
+// Authentication is skipped.
+func (p *Core) NewPublisher(pathName string, medias media.Medias) (*InProcessPublisher, error) {
+	ctx, ctxCancel := context.WithCancel(p.ctx)
+
+	pub := &InProcessPublisher{
+		uuid:       uuid.New(),
+		ctx:        ctx,
+		ctxCancel:  ctxCancel,
+		parent:     p,
+		writeFuncs: make(map[*media.Media]map[uint8]rtspWriteFunc),
+	}
+
+	path, stream, err := inProcessPublisherStart(p, pub, pathName, medias, false)
+	if err != nil {
+		ctxCancel()
+		return nil, err
+	}
+
+	pub.path = path
+
+	for _, medi := range medias {
+		pub.writeFuncs[medi] = make(map[uint8]rtspWriteFunc)
+		for _, forma := range medi.Formats {
+			pub.writeFuncs[medi][forma.PayloadType()] = getRTSPWriteFunc(medi, forma, stream)
+		}
+	}
+
+	return pub, nil
+}
+
+// Close closes the publisher and removes it from the path.
+func (pub *InProcessPublisher) Close() {
+	pub.close()
+	pub.path.publisherRemove(pathPublisherRemoveReq{author: pub})
+}
+
+// Done returns a channel that is closed when the publisher is closed,
+// either by Close() or by the server (i.e. when the path is removed).
+func (pub *InProcessPublisher) Done() <-chan struct{} {
+	return pub.ctx.Done()
+}
+
+// WritePacketRTP writes a RTP packet of a media to the path.
+func (pub *InProcessPublisher) WritePacketRTP(medi *media.Media, pkt *rtp.Packet) error {
+	if pub.ctx.Err() != nil {
+		return fmt.Errorf("terminated")
+	}
+
+	writeFunc, ok := pub.writeFuncs[medi][pkt.PayloadType]
+
+	if !ok {
+		return fmt.Errorf("media or payload type (%d) not found", pkt.PayloadType)
+	}
+
+	writeFunc(pkt)
+	return nil
+}
+
+// close implements publisher.
+func (pub *InProcessPublisher) close() {
+	pub.ctxCancel()
+}
+
+// Log is the main logging function.
+func (pub *InProcessPublisher) Log(level logger.Level, format string, args ...interface{}) {
+	pub.parent.Log(level, "[in-process publisher %v] "+format, append([]interface{}{pub.uuid}, args...)...)
+}
+
+// apiSourceDescribe implements source.
+func (pub *InProcessPublisher) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}{"inProcessPublisher", pub.uuid.String()}
+}
@@ -0,0 +1,150 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/google/uuid"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// InProcessRawPublisher is a publisher that lives inside the same process of the server
+// and publishes raw H264 access units and MPEG-4 Audio access units.
+// RTP packets are generated by the server.
+type InProcessRawPublisher struct {
+	uuid      uuid.UUID
+	ctx       context.Context
+	ctxCancel func()
+	parent    logger.Writer
+
+	videoMedia  *media.Media
+	videoFormat *formats.H264
+	audioMedia  *media.Media
+	audioFormat *formats.MPEG4Audio
+	path        *path
+	stream      *stream
+}
+
+// NewRawPublisher creates an in-process publisher that publishes raw frames to a path.
+// At least one between videoFormat and audioFormat must be provided.
+// Authentication is skipped.
+func (p *Core) NewRawPublisher(
+	pathName string,
+	videoFormat *formats.H264,
+	audioFormat *formats.MPEG4Audio,
+) (*InProcessRawPublisher, error) {
+	if videoFormat == nil && audioFormat == nil {
+		return nil, fmt.Errorf("at least one between video and audio format must be provided")
+	}
+
+	ctx, ctxCancel := context.WithCancel(p.ctx)
+
+	pub := &InProcessRawPublisher{
+		uuid:        uuid.New(),
+		ctx:         ctx,
+		ctxCancel:   ctxCancel,
+		parent:      p,
+		videoFormat: videoFormat,
+		audioFormat: audioFormat,
+	}
+
+	var medias media.Medias
+
+	if videoFormat != nil {
+		pub.videoMedia = &media.Media{
+			Type:    media.TypeVideo,
+			Formats: []formats.Format{videoFormat},
+		}
+		medias = append(medias, pub.videoMedia)
+	}
+
+	if audioFormat != nil {
+		pub.audioMedia = &media.Media{
+			Type:    media.TypeAudio,
+			Formats: []formats.Format{audioFormat},
+		}
+		medias = append(medias, pub.audioMedia)
+	}
+
+	path, stream, err := inProcessPublisherStart(p, pub, pathName, medias, true)
+	if err != nil {
+		ctxCancel()
+		return nil, err
+	}
+
+	pub.path = path
+	pub.stream = stream
+
+	return pub, nil
+}
+
+// Close closes the publisher and removes it from the path.
+func (pub *InProcessRawPublisher) Close() {
+	pub.close()
+	pub.path.publisherRemove(pathPublisherRemoveReq{author: pub})
+}
+
+// Done returns a channel that is closed when the publisher is closed,
+// either by Close() or by the server (i.e. when the path is removed).
+func (pub *InProcessRawPublisher) Done() <-chan struct{} {
+	return pub.ctx.Done()
+}
+
+// WriteH264 writes a H264 access unit, in the form of a list of NAL units.
+func (pub *InProcessRawPublisher) WriteH264(pts time.Duration, au [][]byte) error {
+	if pub.ctx.Err() != nil {
+		return fmt.Errorf("terminated")
+	}
+
+	if pub.videoFormat == nil {
+		return fmt.Errorf("video format not set up")
+	}
+
+	pub.stream.writeUnit(pub.videoMedia, pub.videoFormat, &formatprocessor.UnitH264{
+		PTS: pts,
+		AU:  au,
+		NTP: time.Now(),
+	})
+	return nil
+}
+
+// WriteMPEG4Audio writes MPEG-4 Audio access units.
+func (pub *InProcessRawPublisher) WriteMPEG4Audio(pts time.Duration, aus [][]byte) error {
+	if pub.ctx.Err() != nil {
+		return fmt.Errorf("terminated")
+	}
+
+	if pub.audioFormat == nil {
+		return fmt.Errorf("audio format not set up")
+	}
+
+	pub.stream.writeUnit(pub.audioMedia, pub.audioFormat, &formatprocessor.UnitMPEG4Audio{
+		PTS: pts,
+		AUs: aus,
+		NTP: time.Now(),
+	})
+	return nil
+}
+
+// close implements publisher.
+func (pub *InProcessRawPublisher) close() {
+	pub.ctxCancel()
+}
+
+// Log is the main logging function.
+func (pub *InProcessRawPublisher) Log(level logger.Level, format string, args ...interface{}) {
+	pub.parent.Log(level, "[in-process raw publisher %v] "+format, append([]interface{}{pub.uuid}, args...)...)
+}
+
+// apiSourceDescribe implements source.
+func (pub *InProcessRawPublisher) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+		ID   string `json:"id"`
+	}{"inProcessRawPublisher", pub.uuid.String()}
+}
@@ -0,0 +1,276 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/google/uuid"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// InProcessReaderPacket is a RTP packet received by an InProcessReader.
+type InProcessReaderPacket struct {
+	Media  *media.Media
+	Format formats.Format
+	Packet *rtp.Packet
+	NTP    time.Time
+}
+
+// InProcessReader is a reader that lives inside the same process of the server.
+// It allows Go applications that embed Core to read RTP packets
+// without a network hop.
+type InProcessReader struct {
+	uuid      uuid.UUID
+	ctx       context.Context
+	ctxCancel func()
+	parent    logger.Writer
+
+	path     *path
+	stream   *stream
+	overflow *diskOverflowQueue
+
+	// out
+	packets chan *InProcessReaderPacket
+}
+
+// NewReader creates an in-process reader, that reads all medias of a path.
+// Authentication is skipped.
+// Packets are dropped when the channel returned by Packets() is full;
+// its size is equal to readBufferCount.
+func (p *Core) NewReader(pathName string) (*InProcessReader, error) {
+	return p.newReader(pathName, "", 0)
+}
+
+// NewReaderWithOverflow creates an in-process reader that doesn't lose packets
+// when the consumer is temporarily slower than the stream (i.e. during short I/O stalls
+// of a recorder or of a relay).
+// When the channel returned by Packets() is full, packets are queued in memory, up to
+// readBufferCount packets, then in a temporary file inside overflowDir, up to overflowMaxSize bytes.
+// Packets are dropped only when the temporary file is full.
+func (p *Core) NewReaderWithOverflow(pathName string, overflowDir string, overflowMaxSize uint64) (*InProcessReader, error) {
+	if overflowMaxSize == 0 {
+		return nil, fmt.Errorf("overflow size must be greater than zero")
+	}
+
+	return p.newReader(pathName, overflowDir, overflowMaxSize)
+}
+
+func (p *Core) newReader(pathName string, overflowDir string, overflowMaxSize uint64) (*InProcessReader, error) {
+	pm, err := p.getPathManager()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, ctxCancel := context.WithCancel(p.ctx)
+
+	r := &InProcessReader{
+		uuid:      uuid.New(),
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		parent:    p,
+	}
+
+	res := pm.readerAdd(pathReaderAddReq{
+		author:   r,
+		pathName: pathName,
+	})
+	if res.err != nil {
+		ctxCancel()
+		return nil, res.err
+	}
+
+	r.path = res.path
+	r.stream = res.stream
+	r.packets = make(chan *InProcessReaderPacket, res.path.readBufferCount)
+
+	if overflowMaxSize != 0 {
+		r.overflow = newDiskOverflowQueue(
+			res.path.readBufferCount,
+			overflowDir,
+			overflowMaxSize,
+			r.marshalPacket,
+			r.unmarshalPacket)
+
+		go r.runOverflow()
+	}
+
+	for _, medi := range res.stream.medias() {
+		for _, forma := range medi.Formats {
+			cmedi := medi
+			cforma := forma
+
+			res.stream.readerAdd(r, medi, forma, func(unit formatprocessor.Unit) {
+				ntp := unit.GetNTP()
+
+				for _, pkt := range unit.GetRTPPackets() {
+					rpkt := &InProcessReaderPacket{
+						Media:  cmedi,
+						Format: cforma,
+						Packet: pkt,
+						NTP:    ntp,
+					}
+
+					if r.overflow != nil {
+						r.overflow.push(rpkt)
+						continue
+					}
+
+					select {
+					case r.packets <- rpkt:
+					default:
+					}
+				}
+			})
+		}
+	}
+
+	r.Log(logger.Info, "is reading from path '%s', %s",
+		res.path.name, sourceMediaInfo(res.stream.medias()))
+
+	return r, nil
+}
+
+// Close closes the reader and removes it from the path.
+func (r *InProcessReader) Close() {
+	r.close()
+	r.stream.readerRemove(r)
+	r.path.readerRemove(pathReaderRemoveReq{author: r})
+}
+
+// Done returns a channel that is closed when the reader is closed,
+// either by Close() or by the server (i.e. when the publisher stops).
+func (r *InProcessReader) Done() <-chan struct{} {
+	return r.ctx.Done()
+}
+
+// Medias returns the medias of the stream.
+func (r *InProcessReader) Medias() media.Medias {
+	return r.stream.medias()
+}
+
+// Packets returns a channel that contains the RTP packets of the stream.
+func (r *InProcessReader) Packets() <-chan *InProcessReaderPacket {
+	return r.packets
+}
+
+// close implements reader.
+func (r *InProcessReader) close() {
+	r.ctxCancel()
+}
+
+// runOverflow moves packets from the overflow queue to the channel returned by Packets().
+func (r *InProcessReader) runOverflow() {
+	go func() {
+		<-r.ctx.Done()
+		r.overflow.close()
+	}()
+
+	for {
+		item, ok := r.overflow.pull()
+		if !ok {
+			return
+		}
+
+		select {
+		case r.packets <- item.(*InProcessReaderPacket):
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// marshalPacket encodes a packet in order to store it on disk.
+// The format is identified by its position among the formats of the stream.
+func (r *InProcessReader) marshalPacket(item interface{}) ([]byte, error) {
+	pkt := item.(*InProcessReaderPacket)
+
+	index := -1
+	i := 0
+	for _, medi := range r.stream.medias() {
+		for _, forma := range medi.Formats {
+			if forma == pkt.Format {
+				index = i
+			}
+			i++
+		}
+	}
+	if index < 0 {
+		return nil, fmt.Errorf("format not found")
+	}
+
+	byts, err := pkt.Packet.Marshal()
+	if err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 10+len(byts))
+	binary.BigEndian.PutUint16(buf, uint16(index))
+	binary.BigEndian.PutUint64(buf[2:], uint64(pkt.NTP.UnixNano()))
+	copy(buf[10:], byts)
+
+	return buf, nil
+}
+
+func (r *InProcessReader) unmarshalPacket(buf []byte) (interface{}, error) {
+	if len(buf) < 10 {
+		return nil, fmt.Errorf("invalid packet")
+	}
+
+	index := int(binary.BigEndian.Uint16(buf))
+
+	i := 0
+	for _, medi := range r.stream.medias() {
+		for _, forma := range medi.Formats {
+			if i == index {
+				var pkt rtp.Packet
+				err := pkt.Unmarshal(buf[10:])
+				if err != nil {
+					return nil, err
+				}
+
+				return &InProcessReaderPacket{
+					Media:  medi,
+					Format: forma,
+					Packet: &pkt,
+					NTP:    time.Unix(0, int64(binary.BigEndian.Uint64(buf[2:]))),
+				}, nil
+			}
+			i++
+		}
+	}
+
+	return nil, fmt.Errorf("format not found")
+}
+
+// Log is the main logging function.
+func (r *InProcessReader) Log(level logger.Level, format string, args ...interface{}) {
+	r.parent.Log(level, "[in-process reader %v] "+format, append([]interface{}{r.uuid}, args...)...)
+}
+
+type inProcessReaderAPIDescribe struct {
+	Type     string                  `json:"type"`
+	ID       string                  `json:"id"`
+	Overflow *diskOverflowQueueStats `json:"overflow"`
+}
+
+// apiReaderDescribe implements reader.
+func (r *InProcessReader) apiReaderDescribe() interface{} {
+	ret := inProcessReaderAPIDescribe{
+		Type: "inProcessReader",
+		ID:   r.uuid.String(),
+	}
+
+	if r.overflow != nil {
+		stats := r.overflow.safeStats()
+		ret.Overflow = &stats
+	}
+
+	return ret
+}
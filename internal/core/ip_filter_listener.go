@@ -0,0 +1,66 @@
+package core
+
+import (
+	"net"
+
+	"github.com/aler9/mediamtx/internal/auth"
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+func ipFilterAllows(filter conf.IPFilter, ip net.IP) bool {
+	if auth.IPEqualOrInRange(ip, filter.Deny) {
+		return false
+	}
+
+	if len(filter.Allow) != 0 && !auth.IPEqualOrInRange(ip, filter.Allow) {
+		return false
+	}
+
+	return true
+}
+
+// ipFilterListener is a net.Listener that closes connections of disallowed IPs
+// as soon as they are accepted, before any protocol handshake or TLS negotiation.
+type ipFilterListener struct {
+	net.Listener
+	filter conf.IPFilter
+	parent logger.Writer
+}
+
+func newIPFilterListener(ln net.Listener, filter conf.IPFilter, parent logger.Writer) net.Listener {
+	if len(filter.Allow) == 0 && len(filter.Deny) == 0 {
+		return ln
+	}
+
+	return &ipFilterListener{
+		Listener: ln,
+		filter:   filter,
+		parent:   parent,
+	}
+}
+
+// Accept implements net.Listener.
+func (l *ipFilterListener) Accept() (net.Conn, error) {
+	for {
+		nconn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		addr, ok := nconn.RemoteAddr().(*net.TCPAddr)
+		if !ok || ipFilterAllows(l.filter, addr.IP) {
+			return nconn, nil
+		}
+
+		// reset the connection instead of closing it gracefully,
+		// in order to free resources immediately.
+		if tconn, ok := nconn.(*net.TCPConn); ok {
+			tconn.SetLinger(0) //nolint:errcheck
+		}
+		nconn.Close()
+
+		l.parent.Log(logger.Debug, "connection from %v to %v rejected: IP not allowed",
+			addr.IP, l.Listener.Addr())
+	}
+}
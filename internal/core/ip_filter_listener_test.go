@@ -0,0 +1,64 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestIPFilterListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:9992")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	require.Equal(t, ln, newIPFilterListener(ln, conf.IPFilter{}, nilLogger{}))
+
+	l := &testLogger{ch: make(chan string, 1)}
+
+	ln2 := newIPFilterListener(ln, conf.IPFilter{
+		Allow: conf.IPsOrCIDRs{mustParseCIDR("127.0.0.0/8")},
+		Deny:  conf.IPsOrCIDRs{net.ParseIP("127.0.0.1")},
+	}, l)
+
+	accepted := make(chan net.Conn)
+	go func() {
+		nconn, err := ln2.Accept()
+		if err == nil {
+			accepted <- nconn
+		}
+	}()
+
+	// denied IP. The connection can be reset during the dial too.
+	conn1, err := net.Dial("tcp", "127.0.0.1:9992")
+	if err == nil {
+		defer conn1.Close()
+		_, err = conn1.Read(make([]byte, 1))
+		require.Error(t, err)
+	}
+
+	require.Equal(t, "connection from 127.0.0.1 to 127.0.0.1:9992 rejected: IP not allowed", <-l.ch)
+
+	// allowed IP
+	conn2, err := (&net.Dialer{
+		LocalAddr: &net.TCPAddr{IP: net.ParseIP("127.0.0.2")},
+	}).Dial("tcp", "127.0.0.1:9992")
+	require.NoError(t, err)
+	defer conn2.Close()
+
+	sconn := <-accepted
+	defer sconn.Close()
+
+	require.Equal(t, "127.0.0.2", sconn.RemoteAddr().(*net.TCPAddr).IP.String())
+}
+
+func mustParseCIDR(v string) fmt.Stringer {
+	_, ipnet, err := net.ParseCIDR(v)
+	if err != nil {
+		panic(err)
+	}
+	return ipnet
+}
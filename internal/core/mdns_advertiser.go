@@ -0,0 +1,182 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/zeroconf"
+)
+
+const mdnsAdvertiserServiceType = "_rtsp._tcp"
+
+type mdnsAdvertiserParent interface {
+	logger.Writer
+}
+
+// mdnsAdvertiser advertises ready paths in the local network with mDNS / DNS-SD.
+type mdnsAdvertiser struct {
+	port        uint16
+	events      *apiEvents
+	pathManager apiPathManager
+	parent      mdnsAdvertiserParent
+
+	ctx       context.Context
+	ctxCancel func()
+	server    *zeroconf.Server
+
+	// advertised services, indexed by path name
+	services map[string]zeroconf.Service
+
+	// out
+	done chan struct{}
+}
+
+func newMDNSAdvertiser(
+	parentCtx context.Context,
+	hostName string,
+	rtspAddress string,
+	events *apiEvents,
+	pathManager apiPathManager,
+	parent mdnsAdvertiserParent,
+) (*mdnsAdvertiser, error) {
+	port, err := strconv.ParseUint(listenAddressPort(rtspAddress), 10, 16)
+	if err != nil {
+		return nil, fmt.Errorf("unable to find the port of rtspAddress")
+	}
+
+	if hostName == "" {
+		hostName, err = os.Hostname()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	server, err := zeroconf.New(hostName)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	a := &mdnsAdvertiser{
+		port:        uint16(port),
+		events:      events,
+		pathManager: pathManager,
+		parent:      parent,
+		ctx:         ctx,
+		ctxCancel:   ctxCancel,
+		server:      server,
+		services:    make(map[string]zeroconf.Service),
+		done:        make(chan struct{}),
+	}
+
+	a.Log(logger.Info, "advertising paths as %s.local", zeroconf.HostName(hostName))
+
+	go a.run()
+
+	return a, nil
+}
+
+func (a *mdnsAdvertiser) close() {
+	a.Log(logger.Info, "closing")
+	a.ctxCancel()
+	<-a.done
+}
+
+// Log is the main logging function.
+func (a *mdnsAdvertiser) Log(level logger.Level, format string, args ...interface{}) {
+	a.parent.Log(level, "[mDNS] "+format, args...)
+}
+
+func (a *mdnsAdvertiser) run() {
+	defer close(a.done)
+	defer a.server.Close()
+
+	ch := a.events.subscribe()
+	defer func() {
+		a.events.unsubscribe(ch)
+	}()
+
+	a.sync()
+
+	for {
+		select {
+		case ev, ok := <-ch:
+			// the subscriber has been removed since it didn't keep up with events
+			if !ok {
+				ch = a.events.subscribe()
+				a.sync()
+				continue
+			}
+
+			switch ev.Type {
+			case apiEventPathReady, apiEventPathNotReady, apiEventConfReload:
+				a.sync()
+			}
+
+		case <-a.ctx.Done():
+			return
+		}
+	}
+}
+
+// sync advertises ready paths and stops advertising the other ones.
+func (a *mdnsAdvertiser) sync() {
+	res := a.pathManager.apiPathsList()
+	if res.err != nil {
+		return
+	}
+
+	ready := make(map[string]zeroconf.Service)
+
+	for name, item := range res.data.Items {
+		if !item.SourceReady {
+			continue
+		}
+
+		ready[name] = zeroconf.Service{
+			Instance: name,
+			Type:     mdnsAdvertiserServiceType,
+			Port:     a.port,
+			Text:     mdnsAdvertiserText(name, item.Tracks),
+		}
+	}
+
+	for name, svc := range a.services {
+		if _, ok := ready[name]; !ok {
+			a.server.Remove(svc.Instance, svc.Type)
+			delete(a.services, name)
+		}
+	}
+
+	names := make([]string, 0, len(ready))
+	for name := range ready {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		svc := ready[name]
+
+		if cur, ok := a.services[name]; ok && strings.Join(cur.Text, "\n") == strings.Join(svc.Text, "\n") {
+			continue
+		}
+
+		a.server.Add(svc)
+		a.services[name] = svc
+	}
+}
+
+// mdnsAdvertiserText returns the TXT record of a path.
+// The "path" key is the one used by players to build the URL.
+func mdnsAdvertiserText(name string, tracks []string) []string {
+	return []string{
+		"path=/" + name,
+		"tracks=" + strings.Join(tracks, ","),
+	}
+}
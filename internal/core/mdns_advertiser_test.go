@@ -0,0 +1,72 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestMDNSAdvertiser(t *testing.T) {
+	p, ok := newInstance("mdns: yes\n" +
+		"mdnsHostName: testhost\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/my/stream", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	conn, err := net.ListenUDP("udp4", &net.UDPAddr{})
+	require.NoError(t, err)
+	defer conn.Close()
+
+	query := dnsmessage.Message{
+		Header: dnsmessage.Header{ID: 1234},
+		Questions: []dnsmessage.Question{{
+			Name:  dnsmessage.MustNewName("_rtsp._tcp.local."),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+		}},
+	}
+	byts, err := query.Pack()
+	require.NoError(t, err)
+
+	var res dnsmessage.Message
+
+	for i := 0; ; i++ {
+		require.Less(t, i, 10)
+
+		_, err = conn.WriteTo(byts, &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353})
+		require.NoError(t, err)
+
+		conn.SetReadDeadline(time.Now().Add(500 * time.Millisecond))
+		buf := make([]byte, 1500)
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			continue
+		}
+
+		err = res.Unpack(buf[:n])
+		require.NoError(t, err)
+		break
+	}
+
+	require.Equal(t, uint16(1234), res.Header.ID)
+	require.Equal(t, 1, len(res.Answers))
+	require.Equal(t, "my/stream._rtsp._tcp.local.", res.Answers[0].Body.(*dnsmessage.PTRResource).PTR.String())
+
+	srv := res.Additionals[0].Body.(*dnsmessage.SRVResource)
+	require.Equal(t, "testhost.local.", srv.Target.String())
+	require.Equal(t, uint16(8554), srv.Port)
+
+	txt := res.Additionals[1].Body.(*dnsmessage.TXTResource)
+	require.Equal(t, []string{"path=/my/stream", "tracks=H264"}, txt.TXT)
+}
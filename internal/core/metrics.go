@@ -6,6 +6,7 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"regexp"
 	"strconv"
 	"sync"
 	"time"
@@ -24,8 +25,40 @@ type metricsParent interface {
 	logger.Writer
 }
 
+type metricsPathMatcher struct {
+	entry  string
+	regexp *regexp.Regexp
+}
+
+func newMetricsPathMatchers(entries conf.PathNameMatchers) []*metricsPathMatcher {
+	ret := make([]*metricsPathMatcher, len(entries))
+	for i, entry := range entries {
+		ret[i] = &metricsPathMatcher{entry: entry}
+		if entry[0] == '~' {
+			ret[i].regexp = regexp.MustCompile(entry[1:])
+		}
+	}
+	return ret
+}
+
+func (pm *metricsPathMatcher) match(name string) bool {
+	if pm.regexp != nil {
+		return pm.regexp.MatchString(name)
+	}
+	return name == pm.entry
+}
+
+// metricsPathAggregate contains the sum of the metrics of paths that are aggregated into a single label.
+type metricsPathAggregate struct {
+	paths         int64
+	bytesReceived int64
+	readers       int64
+}
+
 type metrics struct {
-	parent metricsParent
+	pathsExclude   []*metricsPathMatcher
+	pathsAggregate []*metricsPathMatcher
+	parent         metricsParent
 
 	ln           net.Listener
 	httpServer   *http.Server
@@ -36,21 +69,29 @@ type metrics struct {
 	rtmpServer   apiRTMPServer
 	hlsServer    apiHLSServer
 	webRTCServer apiWebRTCServer
+	statsStore   *statsStore
+	requests     *metricsRequests
 }
 
 func newMetrics(
 	address string,
+	socketPermissions conf.FileMode,
 	readTimeout conf.StringDuration,
+	pathsExclude conf.PathNameMatchers,
+	pathsAggregate conf.PathNameMatchers,
 	parent metricsParent,
 ) (*metrics, error) {
-	ln, err := net.Listen(restrictNetwork(restrictNetwork("tcp", address)))
+	ln, err := httpListen(address, socketPermissions)
 	if err != nil {
 		return nil, err
 	}
 
 	m := &metrics{
-		parent: parent,
-		ln:     ln,
+		pathsExclude:   newMetricsPathMatchers(pathsExclude),
+		pathsAggregate: newMetricsPathMatchers(pathsAggregate),
+		parent:         parent,
+		ln:             ln,
+		requests:       newMetricsRequests(),
 	}
 
 	router := gin.New()
@@ -83,12 +124,40 @@ func (m *metrics) Log(level logger.Level, format string, args ...interface{}) {
 	m.parent.Log(level, "[metrics] "+format, args...)
 }
 
+// pathLabel returns the label of a path.
+// Paths can be excluded from metrics or aggregated into a single label
+// in order to limit the cardinality of metrics.
+func (m *metrics) pathLabel(name string) (string, bool, bool) {
+	for _, pm := range m.pathsExclude {
+		if pm.match(name) {
+			return "", false, false
+		}
+	}
+
+	for _, pm := range m.pathsAggregate {
+		if pm.match(name) {
+			return pm.entry, true, true
+		}
+	}
+
+	return name, false, true
+}
+
 func (m *metrics) onMetrics(ctx *gin.Context) {
 	out := ""
 
 	res := m.pathManager.apiPathsList()
 	if res.err == nil && len(res.data.Items) != 0 {
-		for name, i := range res.data.Items {
+		aggregates := make(map[string]map[string]*metricsPathAggregate)
+		n := 0
+
+		for pathName, i := range res.data.Items {
+			name, aggregated, ok := m.pathLabel(pathName)
+			if !ok {
+				continue
+			}
+			n++
+
 			var state string
 			if i.SourceReady {
 				state = "ready"
@@ -96,9 +165,77 @@ func (m *metrics) onMetrics(ctx *gin.Context) {
 				state = "notReady"
 			}
 
+			if aggregated {
+				if _, ok := aggregates[name]; !ok {
+					aggregates[name] = make(map[string]*metricsPathAggregate)
+				}
+				agg, ok := aggregates[name][state]
+				if !ok {
+					agg = &metricsPathAggregate{}
+					aggregates[name][state] = agg
+				}
+
+				agg.paths++
+				agg.bytesReceived += int64(i.BytesReceived)
+				agg.readers += int64(i.ReaderCount)
+				continue
+			}
+
 			tags := "{name=\"" + name + "\",state=\"" + state + "\"}"
 			out += metric("paths", tags, 1)
 			out += metric("paths_bytes_received", tags, int64(i.BytesReceived))
+			out += metric("paths_readers", tags, int64(i.ReaderCount))
+			if i.Health != nil {
+				out += metric("paths_health", tags, int64(*i.Health))
+			}
+
+			if i.sourceStats != nil {
+				if i.sourceStats.RTT != nil {
+					out += metric("paths_source_rtt_ms", tags, int64(*i.sourceStats.RTT*1000))
+				}
+				out += metric("paths_source_packets_lost", tags, int64(i.sourceStats.PacketsLost))
+				out += metric("paths_source_reconnects", tags, int64(i.sourceStats.Reconnects))
+				if i.sourceStats.Jitter != nil {
+					out += metric("paths_source_jitter_ms", tags, int64(*i.sourceStats.Jitter*1000))
+					out += metric("paths_source_fraction_lost_percent", tags, int64(*i.sourceStats.FractionLost*100))
+				}
+			}
+
+			for _, r := range i.Readers {
+				if d, ok := r.(inProcessReaderAPIDescribe); ok && d.Overflow != nil {
+					rtags := "{name=\"" + name + "\",state=\"" + state + "\",id=\"" + d.ID + "\"}"
+					out += metric("paths_reader_overflow_disk_bytes", rtags, int64(d.Overflow.DiskBytes))
+					out += metric("paths_reader_overflow_max_disk_bytes", rtags, int64(d.Overflow.MaxDiskBytes))
+					out += metric("paths_reader_overflows", rtags, int64(d.Overflow.Overflows))
+					out += metric("paths_reader_overflow_dropped", rtags, int64(d.Overflow.Dropped))
+				}
+			}
+
+			for _, protocol := range []string{
+				streamLatencyRTSP,
+				streamLatencyRTMP,
+				streamLatencyHLS,
+				streamLatencyWebRTC,
+			} {
+				if l, ok := i.latency[protocol]; ok {
+					ltags := "{name=\"" + name + "\",state=\"" + state + "\",protocol=\"" + protocol + "\"}"
+					out += metric("paths_latency_p50_ms", ltags, l.P50.Milliseconds())
+					out += metric("paths_latency_p99_ms", ltags, l.P99.Milliseconds())
+				}
+			}
+		}
+
+		for name, states := range aggregates {
+			for state, agg := range states {
+				tags := "{name=\"" + name + "\",state=\"" + state + "\"}"
+				out += metric("paths", tags, agg.paths)
+				out += metric("paths_bytes_received", tags, agg.bytesReceived)
+				out += metric("paths_readers", tags, agg.readers)
+			}
+		}
+
+		if n == 0 {
+			out += metric("paths", "", 0)
 		}
 	} else {
 		out += metric("paths", "", 0)
@@ -107,10 +244,27 @@ func (m *metrics) onMetrics(ctx *gin.Context) {
 	if !interfaceIsEmpty(m.hlsServer) {
 		res := m.hlsServer.apiMuxersList()
 		if res.err == nil && len(res.data.Items) != 0 {
-			for name, i := range res.data.Items {
+			muxers := make(map[string]int64)
+			bytesSent := make(map[string]int64)
+
+			for pathName, i := range res.data.Items {
+				name, _, ok := m.pathLabel(pathName)
+				if !ok {
+					continue
+				}
+				muxers[name]++
+				bytesSent[name] += int64(i.BytesSent)
+			}
+
+			if len(muxers) == 0 {
+				out += metric("hls_muxers", "", 0)
+				out += metric("hls_muxers_bytes_sent", "", 0)
+			}
+
+			for name, count := range muxers {
 				tags := "{name=\"" + name + "\"}"
-				out += metric("hls_muxers", tags, 1)
-				out += metric("hls_muxers_bytes_sent", tags, int64(i.BytesSent))
+				out += metric("hls_muxers", tags, count)
+				out += metric("hls_muxers_bytes_sent", tags, bytesSent[name])
 			}
 		} else {
 			out += metric("hls_muxers", "", 0)
@@ -218,6 +372,31 @@ func (m *metrics) onMetrics(ctx *gin.Context) {
 		}
 	}
 
+	if m.statsStore != nil {
+		data := m.statsStore.apiStats()
+		out += metric("stats_sessions_total", "", int64(data.TotalSessions))
+		out += metric("stats_bytes_received_total", "", int64(data.TotalBytesReceived))
+		bytesReceived := make(map[string]int64)
+		publishSeconds := make(map[string]int64)
+
+		for pathName, pa := range data.Paths {
+			name, _, ok := m.pathLabel(pathName)
+			if !ok {
+				continue
+			}
+			bytesReceived[name] += int64(pa.BytesReceived)
+			publishSeconds[name] += int64(pa.PublishSeconds)
+		}
+
+		for name, v := range bytesReceived {
+			tags := "{name=\"" + name + "\"}"
+			out += metric("paths_bytes_received_total", tags, v)
+			out += metric("paths_publish_seconds_total", tags, publishSeconds[name])
+		}
+	}
+
+	out += m.requests.render()
+
 	ctx.Writer.WriteHeader(http.StatusOK)
 	io.WriteString(ctx.Writer, out)
 }
@@ -263,3 +442,10 @@ func (m *metrics) webRTCServerSet(s apiWebRTCServer) {
 	defer m.mutex.Unlock()
 	m.webRTCServer = s
 }
+
+// statsStoreSet is called by statsStore.
+func (m *metrics) statsStoreSet(s *statsStore) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+	m.statsStore = s
+}
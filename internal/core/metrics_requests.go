@@ -0,0 +1,124 @@
+package core
+
+import (
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// upper bounds of the buckets of request latency histograms, in seconds.
+var metricsRequestsBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+type metricsRequestsKey struct {
+	server string
+	method string
+	status string
+}
+
+func (k metricsRequestsKey) tags() string {
+	return "server=\"" + k.server + "\",method=\"" + k.method + "\",status=\"" + k.status + "\""
+}
+
+type metricsRequestsHistogram struct {
+	buckets []uint64
+	sum     float64
+	count   uint64
+	errors  uint64
+}
+
+// metricsRequests collects latency and errors of requests handled by servers.
+type metricsRequests struct {
+	mutex      sync.Mutex
+	histograms map[metricsRequestsKey]*metricsRequestsHistogram
+}
+
+func newMetricsRequests() *metricsRequests {
+	return &metricsRequests{
+		histograms: make(map[metricsRequestsKey]*metricsRequestsHistogram),
+	}
+}
+
+// observe adds a request to the histogram of the given server, method and status.
+// statuses greater or equal than 400 are counted as errors.
+func (r *metricsRequests) observe(server string, method string, status int, d time.Duration) {
+	key := metricsRequestsKey{
+		server: server,
+		method: method,
+		status: strconv.FormatInt(int64(status), 10),
+	}
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	h, ok := r.histograms[key]
+	if !ok {
+		h = &metricsRequestsHistogram{
+			buckets: make([]uint64, len(metricsRequestsBuckets)),
+		}
+		r.histograms[key] = h
+	}
+
+	secs := d.Seconds()
+	for i, le := range metricsRequestsBuckets {
+		if secs <= le {
+			h.buckets[i]++
+		}
+	}
+	h.sum += secs
+	h.count++
+
+	if status >= 400 {
+		h.errors++
+	}
+}
+
+// render returns histograms and error counters in the Prometheus text format.
+func (r *metricsRequests) render() string {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	keys := make([]metricsRequestsKey, 0, len(r.histograms))
+	for key := range r.histograms {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].server != keys[j].server {
+			return keys[i].server < keys[j].server
+		}
+		if keys[i].method != keys[j].method {
+			return keys[i].method < keys[j].method
+		}
+		return keys[i].status < keys[j].status
+	})
+
+	out := ""
+
+	for _, key := range keys {
+		h := r.histograms[key]
+		tags := key.tags()
+
+		for i, le := range metricsRequestsBuckets {
+			out += metric("requests_duration_seconds_bucket",
+				"{"+tags+",le=\""+strconv.FormatFloat(le, 'f', -1, 64)+"\"}", int64(h.buckets[i]))
+		}
+		out += metric("requests_duration_seconds_bucket", "{"+tags+",le=\"+Inf\"}", int64(h.count))
+		out += "requests_duration_seconds_sum{" + tags + "} " + strconv.FormatFloat(h.sum, 'f', -1, 64) + "\n"
+		out += metric("requests_duration_seconds_count", "{"+tags+"}", int64(h.count))
+		out += metric("requests_errors_total", "{"+tags+"}", int64(h.errors))
+	}
+
+	return out
+}
+
+// middleware returns a middleware that measures the latency of HTTP requests.
+func (r *metricsRequests) middleware(server string) func(*gin.Context) {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+		r.observe(server, ctx.Request.Method, ctx.Writer.Status(), time.Since(start))
+	}
+}
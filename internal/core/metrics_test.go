@@ -6,6 +6,7 @@ import (
 	"net/url"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
@@ -101,16 +102,22 @@ webrtc_conns_bytes_sent 0
 	err = conn.WriteTracks(videoTrack, nil)
 	require.NoError(t, err)
 
+	// wait for the RTMP publisher to be registered by the path.
+	time.Sleep(500 * time.Millisecond)
+
 	bo, err = httpPullFile("http://localhost:9998/metrics")
 	require.NoError(t, err)
 
 	require.Regexp(t,
 		`^paths\{name=".*?",state="ready"\} 1`+"\n"+
 			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
+			`paths_readers\{name=".*?",state="ready"\} [0-9]+`+"\n"+
 			`paths\{name=".*?",state="ready"\} 1`+"\n"+
 			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
+			`paths_readers\{name=".*?",state="ready"\} [0-9]+`+"\n"+
 			`paths\{name=".*?",state="ready"\} 1`+"\n"+
 			`paths_bytes_received\{name=".*?",state="ready"\} 0`+"\n"+
+			`paths_readers\{name=".*?",state="ready"\} [0-9]+`+"\n"+
 			`hls_muxers\{name=".*?"\} 1`+"\n"+
 			`hls_muxers_bytes_sent\{name=".*?"\} [0-9]+`+"\n"+
 			`hls_muxers\{name=".*?"\} 1`+"\n"+
@@ -135,6 +142,75 @@ webrtc_conns_bytes_sent 0
 			`webrtc_conns 0`+"\n"+
 			`webrtc_conns_bytes_received 0`+"\n"+
 			`webrtc_conns_bytes_sent 0`+"\n"+
+			`(requests_[a-z_]+\{server="rtsps?",method="[A-Z]+",status="200"(,le="[0-9\.\+Inf]+")?\} [0-9\.]+`+"\n"+`)+`+
 			"$",
 		string(bo))
 }
+
+func TestMetricsPathsCardinality(t *testing.T) {
+	p, ok := newInstance("metrics: yes\n" +
+		"metricsPathsExclude: [hidden]\n" +
+		"metricsPathsAggregate: [\"~^viewer_\"]\n" +
+		"rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	for _, pathName := range []string{"viewer_1", "viewer_2", "hidden", "cam"} {
+		source := gortsplib.Client{}
+		err := source.StartRecording("rtsp://localhost:8554/"+pathName, media.Medias{testMediaH264})
+		require.NoError(t, err)
+		defer source.Close()
+	}
+
+	bo, err := httpPullFile("http://localhost:9998/metrics")
+	require.NoError(t, err)
+
+	require.Contains(t, string(bo), `paths{name="~^viewer_",state="ready"} 2`+"\n")
+	require.Contains(t, string(bo), `paths_readers{name="~^viewer_",state="ready"} 0`+"\n")
+	require.Contains(t, string(bo), `paths{name="cam",state="ready"} 1`+"\n")
+	require.NotContains(t, string(bo), `viewer_1`)
+	require.NotContains(t, string(bo), `hidden`)
+}
+
+func TestMetricsRequests(t *testing.T) {
+	r := newMetricsRequests()
+	r.observe("api", "GET", 200, 20*time.Millisecond)
+	r.observe("api", "GET", 200, 3*time.Second)
+	r.observe("hls", "GET", 404, 1*time.Millisecond)
+
+	require.Equal(t, `requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.005"} 0
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.01"} 0
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.025"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.05"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.1"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.25"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="0.5"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="1"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="2.5"} 1
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="5"} 2
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="10"} 2
+requests_duration_seconds_bucket{server="api",method="GET",status="200",le="+Inf"} 2
+requests_duration_seconds_sum{server="api",method="GET",status="200"} 3.02
+requests_duration_seconds_count{server="api",method="GET",status="200"} 2
+requests_errors_total{server="api",method="GET",status="200"} 0
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.005"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.01"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.025"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.05"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.1"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.25"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="0.5"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="1"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="2.5"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="5"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="10"} 1
+requests_duration_seconds_bucket{server="hls",method="GET",status="404",le="+Inf"} 1
+requests_duration_seconds_sum{server="hls",method="GET",status="404"} 0.001
+requests_duration_seconds_count{server="hls",method="GET",status="404"} 1
+requests_errors_total{server="hls",method="GET",status="404"} 1
+`, r.render())
+}
@@ -0,0 +1,543 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg2audio"
+	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+var opusDurations = [32]int{
+	480, 960, 1920, 2880, /* Silk NB */
+	480, 960, 1920, 2880, /* Silk MB */
+	480, 960, 1920, 2880, /* Silk WB */
+	480, 960, /* Hybrid SWB */
+	480, 960, /* Hybrid FB */
+	120, 240, 480, 960, /* CELT NB */
+	120, 240, 480, 960, /* CELT NB */
+	120, 240, 480, 960, /* CELT NB */
+	120, 240, 480, 960, /* CELT NB */
+}
+
+const (
+	mpegtsOpusIdentifier = uint32('O')<<24 | uint32('p')<<16 | uint32('u')<<8 | uint32('s')
+	mpegtsKLVIdentifier  = uint32('K')<<24 | uint32('L')<<16 | uint32('V')<<8 | uint32('A')
+
+	mpegtsStreamTypeSCTE35 astits.StreamType = 0x86
+)
+
+// mpegtsCodecMPEG2Video is a MPEG-1 or MPEG-2 Video codec.
+type mpegtsCodecMPEG2Video struct{}
+
+// mpegtsCodecMPEG2Audio is a MPEG-1 or MPEG-2 Audio codec (including MP3).
+type mpegtsCodecMPEG2Audio struct{}
+
+// mpegtsCodecKLV is a KLV metadata codec (SMPTE 336M, STANAG 4609).
+type mpegtsCodecKLV struct{}
+
+// mpegtsCodecDVBSubtitle is a DVB subtitle codec (ETSI EN 300 743).
+type mpegtsCodecDVBSubtitle struct{}
+
+// mpegtsCodecSCTE35 is a SCTE-35 splice information codec.
+type mpegtsCodecSCTE35 struct{}
+
+// mpegtsTrack is a MPEG-TS track.
+// Codec is either a mpegts.Codec or a private data codec that is passed through as it is.
+type mpegtsTrack struct {
+	ES    *astits.PMTElementaryStream
+	Codec interface{}
+}
+
+func mpegtsFindMPEG4AudioConfig(dem *astits.Demuxer, pid uint16) (*mpeg4audio.Config, error) {
+	for {
+		data, err := dem.NextData()
+		if err != nil {
+			return nil, err
+		}
+
+		if data.PES == nil || data.PID != pid {
+			continue
+		}
+
+		var adtsPkts mpeg4audio.ADTSPackets
+		err = adtsPkts.Unmarshal(data.PES.Data)
+		if err != nil {
+			return nil, fmt.Errorf("unable to decode ADTS: %s", err)
+		}
+
+		pkt := adtsPkts[0]
+		return &mpeg4audio.Config{
+			Type:         pkt.Type,
+			SampleRate:   pkt.SampleRate,
+			ChannelCount: pkt.ChannelCount,
+		}, nil
+	}
+}
+
+func mpegtsHasRegistration(descriptors []*astits.Descriptor, identifier uint32) bool {
+	for _, sd := range descriptors {
+		if sd.Registration != nil && sd.Registration.FormatIdentifier == identifier {
+			return true
+		}
+	}
+	return false
+}
+
+func mpegtsFindOpusChannels(descriptors []*astits.Descriptor) int {
+	for _, sd := range descriptors {
+		if sd.Extension != nil && sd.Extension.Tag == 0x80 &&
+			sd.Extension.Unknown != nil && len(*sd.Extension.Unknown) >= 1 {
+			return int((*sd.Extension.Unknown)[0])
+		}
+	}
+	return 0
+}
+
+func mpegtsHasSubtitling(descriptors []*astits.Descriptor) bool {
+	for _, sd := range descriptors {
+		if sd.Tag == astits.DescriptorTagSubtitling {
+			return true
+		}
+	}
+	return false
+}
+
+// mpegtsFindPrivateDataCodec finds the codec of a track with private data.
+func mpegtsFindPrivateDataCodec(descriptors []*astits.Descriptor) interface{} {
+	switch {
+	case mpegtsHasRegistration(descriptors, mpegtsOpusIdentifier):
+		channels := mpegtsFindOpusChannels(descriptors)
+		if channels <= 0 {
+			return nil
+		}
+		return &mpegts.CodecOpus{
+			Channels: channels,
+		}
+
+	case mpegtsHasRegistration(descriptors, mpegtsKLVIdentifier):
+		return &mpegtsCodecKLV{}
+
+	case mpegtsHasSubtitling(descriptors):
+		return &mpegtsCodecDVBSubtitle{}
+	}
+
+	return nil
+}
+
+// mpegtsFindTracks finds the tracks in a MPEG-TS stream.
+// Unlike mpegts.FindTracks(), it also returns private data tracks.
+func mpegtsFindTracks(dem *astits.Demuxer) ([]*mpegtsTrack, error) {
+	var tracks []*mpegtsTrack
+
+	for {
+		data, err := dem.NextData()
+		if err != nil {
+			return nil, err
+		}
+
+		if data.PMT == nil {
+			continue
+		}
+
+		for _, es := range data.PMT.ElementaryStreams {
+			var codec interface{}
+
+			switch es.StreamType {
+			case astits.StreamTypeMPEG1Video, astits.StreamTypeMPEG2Video:
+				codec = &mpegtsCodecMPEG2Video{}
+
+			case astits.StreamTypeMPEG1Audio, astits.StreamTypeMPEG2Audio:
+				codec = &mpegtsCodecMPEG2Audio{}
+
+			case astits.StreamTypeH264Video:
+				codec = &mpegts.CodecH264{}
+
+			case astits.StreamTypeH265Video:
+				codec = &mpegts.CodecH265{}
+
+			case astits.StreamTypeAACAudio:
+				conf, err := mpegtsFindMPEG4AudioConfig(dem, es.ElementaryPID)
+				if err != nil {
+					return nil, err
+				}
+
+				codec = &mpegts.CodecMPEG4Audio{
+					Config: *conf,
+				}
+
+			case astits.StreamTypeMetadata:
+				if mpegtsHasRegistration(es.ElementaryStreamDescriptors, mpegtsKLVIdentifier) {
+					codec = &mpegtsCodecKLV{}
+				}
+
+			case astits.StreamTypePrivateData:
+				codec = mpegtsFindPrivateDataCodec(es.ElementaryStreamDescriptors)
+
+			case mpegtsStreamTypeSCTE35:
+				codec = &mpegtsCodecSCTE35{}
+			}
+
+			if codec != nil {
+				tracks = append(tracks, &mpegtsTrack{
+					ES:    es,
+					Codec: codec,
+				})
+			}
+		}
+		break
+	}
+
+	if tracks == nil {
+		return nil, fmt.Errorf("no tracks found")
+	}
+
+	return tracks, nil
+}
+
+func opusGetPacketDuration(pkt []byte) time.Duration {
+	if len(pkt) == 0 {
+		return 0
+	}
+
+	frameDuration := opusDurations[pkt[0]>>3]
+
+	frameCount := 0
+	switch pkt[0] & 3 {
+	case 0:
+		frameCount = 1
+	case 1:
+		frameCount = 2
+	case 2:
+		frameCount = 2
+	case 3:
+		if len(pkt) < 2 {
+			return 0
+		}
+		frameCount = int(pkt[1] & 63)
+	}
+
+	return (time.Duration(frameDuration) * time.Duration(frameCount) * time.Millisecond) / 48
+}
+
+type mpegtsWriteFunc func(stream *stream, pts time.Duration, data []byte)
+
+// mpegtsTrackWriter writes data of an elementary stream into a stream.
+type mpegtsTrackWriter struct {
+	write mpegtsWriteFunc
+
+	// PES packets can lack the PTS (i.e. asynchronous KLV metadata).
+	// In this case, the PTS of the previous packet is used.
+	ptsOptional bool
+
+	// the PTS can point to the future (i.e. SCTE-35 splice times),
+	// therefore it is not used as reference for packets without PTS.
+	scheduled bool
+}
+
+// mpegtsDataWriteFunc returns a function that writes private data into a stream.
+func mpegtsDataWriteFunc(medi *media.Media) mpegtsWriteFunc {
+	return func(stream *stream, pts time.Duration, data []byte) {
+		stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitGeneric{
+			PTS:     pts,
+			Payload: data,
+			NTP:     time.Now(),
+		})
+	}
+}
+
+// mpegtsMedias converts MPEG-TS tracks into medias.
+// It also returns, for each elementary stream, a writer that writes its data into a stream.
+func mpegtsMedias(tracks []*mpegtsTrack, l logger.Writer) (media.Medias, map[uint16]*mpegtsTrackWriter) {
+	var medias media.Medias
+	writers := make(map[uint16]*mpegtsTrackWriter, len(tracks))
+
+	for _, track := range tracks {
+		var medi *media.Media
+		var writeFunc mpegtsWriteFunc
+		ptsOptional := false
+		scheduled := false
+
+		switch tcodec := track.Codec.(type) {
+		case *mpegts.CodecH264:
+			medi = &media.Media{
+				Type: media.TypeVideo,
+				Formats: []formats.Format{&formats.H264{
+					PayloadTyp:        96,
+					PacketizationMode: 1,
+				}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				au, err := h264.AnnexBUnmarshal(data)
+				if err != nil {
+					l.Log(logger.Warn, "%v", err)
+					return
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				})
+			}
+
+		case *mpegts.CodecH265:
+			medi = &media.Media{
+				Type: media.TypeVideo,
+				Formats: []formats.Format{&formats.H265{
+					PayloadTyp: 96,
+				}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				au, err := h264.AnnexBUnmarshal(data)
+				if err != nil {
+					l.Log(logger.Warn, "%v", err)
+					return
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH265{
+					PTS: pts,
+					AU:  au,
+					NTP: time.Now(),
+				})
+			}
+
+		case *mpegts.CodecMPEG4Audio:
+			medi = &media.Media{
+				Type: media.TypeAudio,
+				Formats: []formats.Format{&formats.MPEG4Audio{
+					PayloadTyp:       96,
+					SizeLength:       13,
+					IndexLength:      3,
+					IndexDeltaLength: 3,
+					Config:           &tcodec.Config,
+				}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				var pkts mpeg4audio.ADTSPackets
+				err := pkts.Unmarshal(data)
+				if err != nil {
+					l.Log(logger.Warn, "%v", err)
+					return
+				}
+
+				aus := make([][]byte, len(pkts))
+				for i, pkt := range pkts {
+					aus[i] = pkt.AU
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG4Audio{
+					PTS: pts,
+					AUs: aus,
+					NTP: time.Now(),
+				})
+			}
+
+		case *mpegts.CodecOpus:
+			medi = &media.Media{
+				Type: media.TypeAudio,
+				Formats: []formats.Format{&formats.Opus{
+					PayloadTyp: 96,
+					IsStereo:   (tcodec.Channels == 2),
+				}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				pos := 0
+
+				for {
+					var au mpegts.OpusAccessUnit
+					n, err := au.Unmarshal(data[pos:])
+					if err != nil {
+						l.Log(logger.Warn, "%v", err)
+						return
+					}
+					pos += n
+
+					stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitOpus{
+						PTS:   pts,
+						Frame: au.Frame,
+						NTP:   time.Now(),
+					})
+
+					if len(data[pos:]) == 0 {
+						break
+					}
+
+					pts += opusGetPacketDuration(au.Frame)
+				}
+			}
+
+		case *mpegtsCodecMPEG2Video:
+			medi = &media.Media{
+				Type:    media.TypeVideo,
+				Formats: []formats.Format{&formats.MPEG2Video{}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG2Video{
+					PTS:   pts,
+					Frame: data,
+					NTP:   time.Now(),
+				})
+			}
+
+		case *mpegtsCodecMPEG2Audio:
+			medi = &media.Media{
+				Type:    media.TypeAudio,
+				Formats: []formats.Format{&formats.MPEG2Audio{}},
+			}
+
+			writeFunc = func(stream *stream, pts time.Duration, data []byte) {
+				var frames [][]byte
+
+				for len(data) != 0 {
+					var h mpeg2audio.FrameHeader
+					err := h.Unmarshal(data)
+					if err != nil {
+						l.Log(logger.Warn, "%v", err)
+						return
+					}
+
+					frameLen := h.FrameLen()
+					if frameLen > len(data) {
+						l.Log(logger.Warn, "MPEG-2 audio frame is truncated")
+						return
+					}
+
+					frames = append(frames, data[:frameLen])
+					data = data[frameLen:]
+				}
+
+				stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG2Audio{
+					PTS:    pts,
+					Frames: frames,
+					NTP:    time.Now(),
+				})
+			}
+
+		case *mpegtsCodecKLV:
+			medi = &media.Media{
+				Type: media.TypeApplication,
+				Formats: []formats.Format{&formats.Generic{
+					PayloadTyp: 96,
+					RTPMa:      "smpte336m/90000",
+					ClockRat:   90000,
+				}},
+			}
+			writeFunc = mpegtsDataWriteFunc(medi)
+			ptsOptional = true
+
+		case *mpegtsCodecDVBSubtitle:
+			medi = &media.Media{
+				Type: media.TypeApplication,
+				Formats: []formats.Format{&formats.Generic{
+					PayloadTyp: 96,
+					RTPMa:      "x-dvb-subtitle/90000",
+					ClockRat:   90000,
+				}},
+			}
+			writeFunc = mpegtsDataWriteFunc(medi)
+
+		case *mpegtsCodecSCTE35:
+			medi = &media.Media{
+				Type: media.TypeApplication,
+				Formats: []formats.Format{&formats.Generic{
+					PayloadTyp: 96,
+					RTPMa:      scte35RTPMap,
+					ClockRat:   90000,
+				}},
+			}
+			writeFunc = mpegtsDataWriteFunc(medi)
+			ptsOptional = true
+			scheduled = true
+		}
+
+		medias = append(medias, medi)
+		writers[track.ES.ElementaryPID] = &mpegtsTrackWriter{
+			write:       writeFunc,
+			ptsOptional: ptsOptional,
+			scheduled:   scheduled,
+		}
+	}
+
+	return medias, writers
+}
+
+// mpegtsReadData reads data from a MPEG-TS demuxer and writes it into a stream.
+// onRead is called before reading each chunk of data.
+func mpegtsReadData(
+	dem *astits.Demuxer,
+	stream *stream,
+	writers map[uint16]*mpegtsTrackWriter,
+	onRead func(),
+) error {
+	var timedec *mpegts.TimeDecoder
+	var lastPTS time.Duration
+
+	for {
+		onRead()
+		data, err := dem.NextData()
+		if err != nil {
+			return err
+		}
+
+		if data.PES == nil {
+			continue
+		}
+
+		writer, ok := writers[data.PID]
+
+		if data.PES.Header.OptionalHeader == nil ||
+			data.PES.Header.OptionalHeader.PTSDTSIndicator == astits.PTSDTSIndicatorNoPTSOrDTS ||
+			data.PES.Header.OptionalHeader.PTSDTSIndicator == astits.PTSDTSIndicatorIsForbidden {
+			if !ok || !writer.ptsOptional {
+				return fmt.Errorf("PTS is missing")
+			}
+
+			// wait for the first packet with a PTS
+			if timedec == nil {
+				continue
+			}
+
+			writer.write(stream, lastPTS, data.PES.Data)
+			continue
+		}
+
+		if ok && writer.scheduled {
+			// wait for the first packet with a PTS
+			if timedec == nil {
+				continue
+			}
+
+			writer.write(stream, timedec.Decode(data.PES.Header.OptionalHeader.PTS.Base), data.PES.Data)
+			continue
+		}
+
+		var pts time.Duration
+		if timedec == nil {
+			timedec = mpegts.NewTimeDecoder(data.PES.Header.OptionalHeader.PTS.Base)
+			pts = 0
+		} else {
+			pts = timedec.Decode(data.PES.Header.OptionalHeader.PTS.Base)
+		}
+		lastPTS = pts
+
+		if !ok {
+			continue
+		}
+
+		writer.write(stream, pts, data.PES.Data)
+	}
+}
@@ -0,0 +1,93 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPEGTSFindTracks(t *testing.T) {
+	var buf bytes.Buffer
+	mux := astits.NewMuxer(context.Background(), &buf)
+
+	err := mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 256,
+		StreamType:    astits.StreamTypeH264Video,
+	})
+	require.NoError(t, err)
+
+	err = mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 257,
+		StreamType:    astits.StreamTypeMetadata,
+		ElementaryStreamDescriptors: []*astits.Descriptor{{
+			Length: 4,
+			Tag:    astits.DescriptorTagRegistration,
+			Registration: &astits.DescriptorRegistration{
+				FormatIdentifier: mpegtsKLVIdentifier,
+			},
+		}},
+	})
+	require.NoError(t, err)
+
+	err = mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 258,
+		StreamType:    astits.StreamTypePrivateData,
+		ElementaryStreamDescriptors: []*astits.Descriptor{{
+			Length: 8,
+			Tag:    astits.DescriptorTagSubtitling,
+			Subtitling: &astits.DescriptorSubtitling{
+				Items: []*astits.DescriptorSubtitlingItem{{
+					Language: []byte("eng"),
+					Type:     0x10,
+				}},
+			},
+		}},
+	})
+	require.NoError(t, err)
+
+	err = mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 259,
+		StreamType:    astits.StreamTypeMPEG2Video,
+	})
+	require.NoError(t, err)
+
+	err = mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 260,
+		StreamType:    astits.StreamTypeMPEG1Audio,
+	})
+	require.NoError(t, err)
+
+	mux.SetPCRPID(256)
+
+	_, err = mux.WriteTables()
+	require.NoError(t, err)
+
+	dem := astits.NewDemuxer(context.Background(), &buf, astits.DemuxerOptPacketSize(188))
+
+	tracks, err := mpegtsFindTracks(dem)
+	require.NoError(t, err)
+	require.Equal(t, 5, len(tracks))
+	require.IsType(t, &mpegts.CodecH264{}, tracks[0].Codec)
+	require.IsType(t, &mpegtsCodecKLV{}, tracks[1].Codec)
+	require.IsType(t, &mpegtsCodecDVBSubtitle{}, tracks[2].Codec)
+	require.IsType(t, &mpegtsCodecMPEG2Video{}, tracks[3].Codec)
+	require.IsType(t, &mpegtsCodecMPEG2Audio{}, tracks[4].Codec)
+
+	medias, writers := mpegtsMedias(tracks, nil)
+	require.Equal(t, 5, len(medias))
+	require.Equal(t, media.TypeApplication, medias[1].Type)
+	require.Equal(t, "smpte336m/90000", medias[1].Formats[0].(*formats.Generic).RTPMap())
+	require.Equal(t, 90000, medias[1].Formats[0].ClockRate())
+	require.Equal(t, true, writers[257].ptsOptional)
+	require.Equal(t, media.TypeApplication, medias[2].Type)
+	require.Equal(t, "x-dvb-subtitle/90000", medias[2].Formats[0].(*formats.Generic).RTPMap())
+	require.Equal(t, false, writers[258].ptsOptional)
+	require.IsType(t, &formats.MPEG2Video{}, medias[3].Formats[0])
+	require.IsType(t, &formats.MPEG2Audio{}, medias[4].Formats[0])
+}
@@ -0,0 +1,207 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// delays between attempts to accept connections after an error,
+	// like the ones caused by the exhaustion of file descriptors.
+	retryListenerMinDelay = 5 * time.Millisecond
+	retryListenerMaxDelay = 1 * time.Second
+)
+
+// splitListenAddress splits a listen address that contains
+// multiple comma-separated binds, i.e. "[::]:8554,0.0.0.0:8554".
+func splitListenAddress(address string) []string {
+	var ret []string
+
+	for _, addr := range strings.Split(address, ",") {
+		addr = strings.TrimSpace(addr)
+		if addr != "" {
+			ret = append(ret, addr)
+		}
+	}
+
+	return ret
+}
+
+// listenAddressPort returns the port of the first bind of a listen address.
+func listenAddressPort(address string) string {
+	addrs := splitListenAddress(address)
+	if len(addrs) == 0 {
+		return ""
+	}
+
+	_, port, _ := net.SplitHostPort(addrs[0])
+	return port
+}
+
+// retryListener is a net.Listener that, when accepting a connection fails
+// because of a temporary error, like the exhaustion of file descriptors,
+// waits and tries again, instead of returning the error.
+// Only the errors caused by the closure of the listener are returned.
+type retryListener struct {
+	net.Listener
+
+	closeOnce sync.Once
+	done      chan struct{}
+}
+
+func newRetryListener(ln net.Listener) *retryListener {
+	return &retryListener{
+		Listener: ln,
+		done:     make(chan struct{}),
+	}
+}
+
+// Accept implements net.Listener.
+func (l *retryListener) Accept() (net.Conn, error) {
+	var delay time.Duration
+
+	for {
+		conn, err := l.Listener.Accept()
+		if err == nil {
+			return conn, nil
+		}
+
+		if errors.Is(err, net.ErrClosed) {
+			return nil, err
+		}
+
+		if delay == 0 {
+			delay = retryListenerMinDelay
+		} else {
+			delay *= 2
+			if delay > retryListenerMaxDelay {
+				delay = retryListenerMaxDelay
+			}
+		}
+
+		t := time.NewTimer(delay)
+		select {
+		case <-t.C:
+		case <-l.done:
+			t.Stop()
+			return nil, net.ErrClosed
+		}
+	}
+}
+
+// Close implements net.Listener.
+func (l *retryListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
+}
+
+type multiListenerAcceptRes struct {
+	conn net.Conn
+	err  error
+}
+
+// multiListener is a net.Listener that returns connections accepted by multiple listeners.
+// It is needed on platforms that do not provide dual-stack sockets.
+type multiListener struct {
+	lns []net.Listener
+
+	closeOnce sync.Once
+	chAccept  chan multiListenerAcceptRes
+	done      chan struct{}
+}
+
+// listenMultiple creates a listener for every bind of a listen address.
+// Temporary errors are handled by the returned listener, therefore
+// an error returned by Accept() means that the listener is closed.
+func listenMultiple(network string, address string) (net.Listener, error) {
+	addrs := splitListenAddress(address)
+
+	if len(addrs) <= 1 {
+		ln, err := net.Listen(restrictNetwork(network, address))
+		if err != nil {
+			return nil, err
+		}
+		return newRetryListener(ln), nil
+	}
+
+	l := &multiListener{
+		chAccept: make(chan multiListenerAcceptRes),
+		done:     make(chan struct{}),
+	}
+
+	for _, addr := range addrs {
+		ln, err := net.Listen(restrictNetwork(network, addr))
+		if err != nil {
+			for _, ln := range l.lns {
+				ln.Close()
+			}
+			return nil, err
+		}
+
+		l.lns = append(l.lns, newRetryListener(ln))
+	}
+
+	for _, ln := range l.lns {
+		go l.runAccept(ln)
+	}
+
+	return l, nil
+}
+
+func (l *multiListener) runAccept(ln net.Listener) {
+	for {
+		conn, err := ln.Accept()
+
+		select {
+		case l.chAccept <- multiListenerAcceptRes{conn, err}:
+		case <-l.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+
+		// listeners handle temporary errors by themselves,
+		// therefore any error means that the listener is closed.
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener.
+func (l *multiListener) Accept() (net.Conn, error) {
+	select {
+	case res := <-l.chAccept:
+		return res.conn, res.err
+
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener.
+func (l *multiListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+
+	var err error
+	for _, ln := range l.lns {
+		err2 := ln.Close()
+		if err == nil {
+			err = err2
+		}
+	}
+	return err
+}
+
+// Addr implements net.Listener.
+func (l *multiListener) Addr() net.Addr {
+	return l.lns[0].Addr()
+}
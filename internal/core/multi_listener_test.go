@@ -0,0 +1,91 @@
+package core
+
+import (
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestListenMultiple(t *testing.T) {
+	ln, err := listenMultiple("tcp", "127.0.0.1:9995, 127.0.0.2:9995")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	for _, addr := range []string{"127.0.0.1:9995", "127.0.0.2:9995"} {
+		conn, err := net.Dial("tcp", addr)
+		require.NoError(t, err)
+		defer conn.Close()
+
+		sconn, err := ln.Accept()
+		require.NoError(t, err)
+		defer sconn.Close()
+
+		require.Equal(t, addr, sconn.LocalAddr().String())
+	}
+
+	ln.Close()
+
+	_, err = ln.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+type testFaultyListener struct {
+	net.Listener
+	errs []error
+}
+
+func (l *testFaultyListener) Accept() (net.Conn, error) {
+	if len(l.errs) != 0 {
+		err := l.errs[0]
+		l.errs = l.errs[1:]
+		return nil, err
+	}
+	return l.Listener.Accept()
+}
+
+func TestListenMultipleTemporaryError(t *testing.T) {
+	ln1, err := net.Listen("tcp", "127.0.0.1:9995")
+	require.NoError(t, err)
+
+	ln2, err := net.Listen("tcp", "127.0.0.2:9995")
+	require.NoError(t, err)
+
+	l := &multiListener{
+		lns: []net.Listener{
+			newRetryListener(&testFaultyListener{Listener: ln1, errs: []error{
+				errors.New("too many open files"),
+				errors.New("too many open files"),
+			}}),
+			newRetryListener(ln2),
+		},
+		chAccept: make(chan multiListenerAcceptRes),
+		done:     make(chan struct{}),
+	}
+	for _, ln := range l.lns {
+		go l.runAccept(ln)
+	}
+	defer l.Close()
+
+	// temporary errors are not returned, and the listener
+	// that encountered them is still accepting connections.
+	conn, err := net.Dial("tcp", "127.0.0.1:9995")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sconn, err := l.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+	require.Equal(t, "127.0.0.1:9995", sconn.LocalAddr().String())
+
+	l.Close()
+
+	_, err = l.Accept()
+	require.ErrorIs(t, err, net.ErrClosed)
+}
+
+func TestListenAddressPort(t *testing.T) {
+	require.Equal(t, "8554", listenAddressPort(":8554"))
+	require.Equal(t, "8554", listenAddressPort("[::]:8554,0.0.0.0:8554"))
+}
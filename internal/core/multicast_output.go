@@ -0,0 +1,266 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+	"github.com/bluenviron/gortsplib/v3/pkg/rtcpsender"
+	"github.com/bluenviron/gortsplib/v3/pkg/sdp"
+	"github.com/pion/rtcp"
+	psdp "github.com/pion/sdp/v3"
+	"golang.org/x/net/ipv4"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	multicastOutputSenderReportPeriod = 10 * time.Second
+)
+
+// multicastOutputSDP returns the SDP that allows decoders to receive
+// a multicast output without RTSP sessions.
+func multicastOutputSDP(
+	medias media.Medias,
+	group net.IP,
+	port int,
+	ttl int,
+	source net.IP,
+) ([]byte, error) {
+	desc := medias.Marshal(true)
+
+	desc.ConnectionInformation.Address.Address = group.String()
+	desc.ConnectionInformation.Address.TTL = &ttl
+
+	if source != nil {
+		desc.Origin.UnicastAddress = source.String()
+	}
+
+	for i, md := range desc.MediaDescriptions {
+		md.MediaName.Port = psdp.RangedPort{Value: port + i*2}
+
+		if source != nil {
+			// RFC4570, allows receivers to use source-specific multicast
+			md.Attributes = append(md.Attributes, psdp.Attribute{
+				Key:   "source-filter",
+				Value: " incl IN IP4 " + group.String() + " " + source.String(),
+			})
+		}
+	}
+
+	return (*sdp.SessionDescription)(desc).Marshal()
+}
+
+func multicastOutputInterface(ip net.IP) (*net.Interface, error) {
+	intfs, err := net.Interfaces()
+	if err != nil {
+		return nil, err
+	}
+
+	for _, intf := range intfs {
+		addrs, err := intf.Addrs()
+		if err != nil {
+			continue
+		}
+
+		for _, addr := range addrs {
+			if ipnet, ok := addr.(*net.IPNet); ok && ipnet.IP.Equal(ip) {
+				intf := intf
+				return &intf, nil
+			}
+		}
+	}
+
+	return nil, fmt.Errorf("no network interface has IP %s", ip)
+}
+
+type multicastOutputParent interface {
+	logger.Writer
+}
+
+// multicastOutput sends a stream to a fixed multicast group.
+type multicastOutput struct {
+	sdpFile string
+	stream  *stream
+	parent  multicastOutputParent
+
+	pc          *net.UDPConn
+	ringBuffer  *ringbuffer.RingBuffer
+	rtcpSenders []*rtcpsender.RTCPSender
+
+	// out
+	done chan struct{}
+}
+
+func newMulticastOutput(
+	address string,
+	ttl int,
+	source string,
+	sdpFile string,
+	readBufferCount int,
+	stream *stream,
+	parent multicastOutputParent,
+) (*multicastOutput, error) {
+	host, portStr, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+
+	group := net.ParseIP(host)
+	port, _ := strconv.Atoi(portStr)
+
+	var sourceIP net.IP
+	listenAddr := &net.UDPAddr{}
+
+	if source != "" {
+		sourceIP = net.ParseIP(source)
+		listenAddr.IP = sourceIP
+	}
+
+	pc, err := net.ListenUDP("udp4", listenAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	p := ipv4.NewPacketConn(pc)
+
+	err = p.SetMulticastTTL(ttl)
+	if err != nil {
+		pc.Close()
+		return nil, err
+	}
+
+	if sourceIP != nil {
+		intf, err := multicastOutputInterface(sourceIP)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+
+		err = p.SetMulticastInterface(intf)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+
+	if sdpFile != "" {
+		byts, err := multicastOutputSDP(stream.medias(), group, port, ttl, sourceIP)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+
+		err = os.WriteFile(sdpFile, byts, 0o644)
+		if err != nil {
+			pc.Close()
+			return nil, err
+		}
+	}
+
+	ringBuffer, _ := ringbuffer.New(uint64(readBufferCount))
+
+	o := &multicastOutput{
+		sdpFile:    sdpFile,
+		stream:     stream,
+		parent:     parent,
+		pc:         pc,
+		ringBuffer: ringBuffer,
+		done:       make(chan struct{}),
+	}
+
+	for i, medi := range stream.medias() {
+		rtpAddr := &net.UDPAddr{IP: group, Port: port + i*2}
+		rtcpAddr := &net.UDPAddr{IP: group, Port: port + i*2 + 1}
+
+		for _, forma := range medi.Formats {
+			o.addFormat(medi, forma, rtpAddr, rtcpAddr)
+		}
+	}
+
+	o.Log(logger.Info, "is sending to %s (TTL %d), %s",
+		address, ttl, sourceMediaInfo(stream.medias()))
+
+	go o.run()
+
+	return o, nil
+}
+
+func (o *multicastOutput) close() {
+	o.stream.readerRemove(o)
+	o.ringBuffer.Close()
+	<-o.done
+
+	for _, rs := range o.rtcpSenders {
+		rs.Close()
+	}
+
+	o.pc.Close()
+
+	if o.sdpFile != "" {
+		os.Remove(o.sdpFile)
+	}
+}
+
+// Log is the main logging function.
+func (o *multicastOutput) Log(level logger.Level, format string, args ...interface{}) {
+	o.parent.Log(level, "[multicast output] "+format, args...)
+}
+
+func (o *multicastOutput) addFormat(
+	medi *media.Media,
+	forma formats.Format,
+	rtpAddr *net.UDPAddr,
+	rtcpAddr *net.UDPAddr,
+) {
+	rs := rtcpsender.New(forma.ClockRate(), func(pkt rtcp.Packet) {
+		byts, err := pkt.Marshal()
+		if err == nil {
+			o.pc.WriteTo(byts, rtcpAddr)
+		}
+	})
+	rs.Start(multicastOutputSenderReportPeriod)
+	o.rtcpSenders = append(o.rtcpSenders, rs)
+
+	o.stream.readerAdd(o, medi, forma, func(unit formatprocessor.Unit) {
+		o.ringBuffer.Push(func() {
+			ntp := unit.GetNTP()
+
+			for _, pkt := range unit.GetRTPPackets() {
+				b, err := rtpBufferMarshal(pkt)
+				if err != nil {
+					continue
+				}
+
+				rs.ProcessPacket(pkt, ntp, forma.PTSEqualsDTS(pkt))
+				o.pc.WriteTo(b.bytes(), rtpAddr)
+				b.release()
+			}
+		})
+	})
+}
+
+func (o *multicastOutput) run() {
+	defer close(o.done)
+
+	for {
+		item, ok := o.ringBuffer.Pull()
+		if !ok {
+			return
+		}
+		item.(func())()
+	}
+}
+
+// apiReaderDescribe implements reader.
+func (o *multicastOutput) apiReaderDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"multicastOutput"}
+}
@@ -0,0 +1,47 @@
+package core
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMulticastOutput(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-multicast-output")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	sdpFile := filepath.Join(dir, "stream.sdp")
+
+	p, ok := newInstance("paths:\n" +
+		"  stream:\n" +
+		"    multicastOutputAddress: 232.1.1.1:5004\n" +
+		"    multicastOutputTTL: 4\n" +
+		"    multicastOutputSource: 127.0.0.1\n" +
+		"    multicastOutputSDPFile: " + sdpFile + "\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err = source.StartRecording("rtsp://localhost:8554/stream", media.Medias{testMediaH264})
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	byts, err := os.ReadFile(sdpFile)
+	require.NoError(t, err)
+	require.Contains(t, string(byts), "c=IN IP4 232.1.1.1/4\r\n")
+	require.Contains(t, string(byts), "m=video 5004 RTP/AVP 96\r\n")
+	require.Contains(t, string(byts), "a=source-filter: incl IN IP4 232.1.1.1 127.0.0.1\r\n")
+
+	source.Close()
+	time.Sleep(500 * time.Millisecond)
+
+	_, err = os.Stat(sdpFile)
+	require.Error(t, err)
+}
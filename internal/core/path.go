@@ -3,7 +3,8 @@ package core
 import (
 	"context"
 	"fmt"
-	"net"
+	"net/http"
+	"os"
 	"strconv"
 	"strings"
 	"sync"
@@ -16,7 +17,9 @@ import (
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
+	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/onvif"
 )
 
 func newEmptyTimer() *time.Timer {
@@ -29,8 +32,18 @@ type authenticateFunc func(
 	pathIPs []fmt.Stringer,
 	pathUser conf.Credential,
 	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
 ) error
 
+// publisherAuthenticateFunc is like authenticateFunc, but it also returns the identity
+// of the publisher, made of the credentials that have been verified.
+type publisherAuthenticateFunc func(
+	pathIPs []fmt.Stringer,
+	pathUser conf.Credential,
+	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
+) (authIdentity, error)
+
 type pathErrNoOnePublishing struct {
 	pathName string
 }
@@ -40,6 +53,15 @@ func (e pathErrNoOnePublishing) Error() string {
 	return fmt.Sprintf("no one is publishing to path '%s'", e.pathName)
 }
 
+type pathErrSourceTemplateMismatch struct {
+	pathName string
+}
+
+// Error implements the error interface.
+func (e pathErrSourceTemplateMismatch) Error() string {
+	return fmt.Sprintf("path '%s' is reading a source that was filled with a different query or protocol", e.pathName)
+}
+
 type pathErrAuthNotCritical struct {
 	message  string
 	response *base.Response
@@ -64,7 +86,15 @@ type pathParent interface {
 	logger.Writer
 	pathSourceReady(*path)
 	pathSourceNotReady(*path)
+	pathSourceReplaced(*path)
 	onPathClose(*path)
+	apiEventPublish(apiEvent)
+	historyStart(name string, source interface{})
+	historyStop(name string, reason string)
+	readersPendingAdd(name string, stream *stream, readers map[reader]struct{}, gracePeriod time.Duration) bool
+	readersPendingTake(pa *path, medias media.Medias, generateRTPPackets bool, source source) (*stream, map[reader]struct{})
+	readersPendingRemove(pa *path, r reader) (bool, *path)
+	readersPendingForget(r reader)
 }
 
 type pathOnDemandState int
@@ -84,13 +114,26 @@ type pathSourceStaticSetReadyRes struct {
 type pathSourceStaticSetReadyReq struct {
 	medias             media.Medias
 	generateRTPPackets bool
+	reannounce         bool
 	res                chan pathSourceStaticSetReadyRes
 }
 
 type pathSourceStaticSetNotReadyReq struct {
+	err error
+
+	// the source is reconnecting; readers are kept until it comes back.
+	keepReaders bool
+
 	res chan struct{}
 }
 
+func (req pathSourceStaticSetNotReadyReq) reason() string {
+	if req.err == nil {
+		return "source stopped"
+	}
+	return "source error: " + req.err.Error()
+}
+
 type pathReaderRemoveReq struct {
 	author reader
 	res    chan struct{}
@@ -106,11 +149,17 @@ type pathDescribeRes struct {
 	stream   *stream
 	redirect string
 	err      error
+
+	// configuration of the alias that has been used to reach the path, if it has one.
+	// It contains the authentication settings of the alias.
+	aliasConf *conf.PathConf
 }
 
 type pathDescribeReq struct {
 	pathName     string
 	url          *url.URL
+	query        string
+	protocol     string
 	authenticate authenticateFunc
 	res          chan pathDescribeRes
 }
@@ -119,11 +168,17 @@ type pathReaderSetupPlayRes struct {
 	path   *path
 	stream *stream
 	err    error
+
+	// configuration of the alias that has been used to reach the path, if it has one.
+	// It contains the authentication settings of the alias.
+	aliasConf *conf.PathConf
 }
 
 type pathReaderAddReq struct {
 	author       reader
 	pathName     string
+	query        string
+	protocol     string
 	authenticate authenticateFunc
 	res          chan pathReaderSetupPlayRes
 }
@@ -134,9 +189,13 @@ type pathPublisherAnnounceRes struct {
 }
 
 type pathPublisherAddReq struct {
-	author       publisher
-	pathName     string
-	authenticate authenticateFunc
+	author   publisher
+	pathName string
+
+	// the last segment of the path name can be a stream key (RTMP only).
+	streamKeyInPath bool
+
+	authenticate publisherAuthenticateFunc
 	res          chan pathPublisherAnnounceRes
 }
 
@@ -162,9 +221,17 @@ type pathAPIPathsListItem struct {
 	Conf          *conf.PathConf `json:"conf"`
 	Source        interface{}    `json:"source"`
 	SourceReady   bool           `json:"sourceReady"`
+	SourceError   *string        `json:"sourceError"`
 	Tracks        []string       `json:"tracks"`
+	SSRCs         []*uint32      `json:"ssrcs"`
 	BytesReceived uint64         `json:"bytesReceived"`
+	ReaderCount   int            `json:"readerCount"`
 	Readers       []interface{}  `json:"readers"`
+	Alerts        []pathAPIAlert `json:"alerts"`
+	Health        *int           `json:"health"`
+
+	sourceStats *sourceStaticStatsAPI
+	latency     map[string]streamLatencyPercentiles
 }
 
 type pathAPIPathsListData struct {
@@ -186,26 +253,83 @@ type pathAPIPathsListSubReq struct {
 	res  chan struct{}
 }
 
+type pathAPIPathsCaptureRes struct {
+	path *path
+	file string
+	err  error
+}
+
+type pathLimitExceededReq struct {
+	alerts *pathAlerts
+	alert  formatprocessor.AnalyzerAlert
+	track  string
+}
+
+type pathAPIPathsCaptureReq struct {
+	name      string
+	duration  time.Duration
+	directory string
+	maxSize   uint64
+	maxFiles  int
+	res       chan pathAPIPathsCaptureRes
+}
+
+type pathSourceConfRes struct {
+	conf *conf.PathConf
+	err  error
+}
+
+type pathSourceConfReq struct {
+	res chan pathSourceConfRes
+}
+
+type pathAPIPathsTeeRes struct {
+	id  string
+	err error
+}
+
+type pathAPIPathsPTZRes struct {
+	path   *path
+	client *onvif.Client
+	err    error
+}
+
+type pathAPIPathsPTZReq struct {
+	name string
+	res  chan pathAPIPathsPTZRes
+}
+
 type path struct {
-	rtspAddress       string
-	readTimeout       conf.StringDuration
-	writeTimeout      conf.StringDuration
-	readBufferCount   int
-	udpMaxPayloadSize int
-	confName          string
-	conf              *conf.PathConf
-	name              string
-	matches           []string
-	wg                *sync.WaitGroup
-	externalCmdPool   *externalcmd.Pool
-	parent            pathParent
+	rtspAddress        string
+	readTimeout        conf.StringDuration
+	writeTimeout       conf.StringDuration
+	readBufferCount    int
+	udpMaxPayloadSize  int
+	confName           string
+	conf               *conf.PathConf
+	name               string
+	matches            []string
+	wg                 *sync.WaitGroup
+	externalCmdPool    *externalcmd.Pool
+	sourceStartLimiter *sourceStartLimiter
+	parent             pathParent
 
 	ctx                            context.Context
 	ctxCancel                      func()
 	confMutex                      sync.RWMutex
 	source                         source
+	previousPublisher              publisher
 	bytesReceived                  *uint64
+	readerCount                    *int64
+	publisherCount                 *int64
 	stream                         *stream
+	sourceDown                     bool
+	multicastOutput                *multicastOutput
+	alerts                         *pathAlerts
+	motion                         *pathMotion
+	scte35                         *pathSCTE35
+	lastFrame                      *pathLastFrame
+	lastFrameTimer                 *time.Timer
 	readers                        map[reader]struct{}
 	describeRequestsOnHold         []pathDescribeReq
 	readerAddRequestsOnHold        []pathReaderAddReq
@@ -230,6 +354,11 @@ type path struct {
 	chReaderAdd               chan pathReaderAddReq
 	chReaderRemove            chan pathReaderRemoveReq
 	chAPIPathsList            chan pathAPIPathsListSubReq
+	chAPIPathsCapture         chan pathAPIPathsCaptureReq
+	chSourceConf              chan pathSourceConfReq
+	chLimitExceeded           chan pathLimitExceededReq
+	chPublisherReplaced       chan publisher
+	chSourceStaticReplaced    chan struct{}
 
 	// out
 	done chan struct{}
@@ -248,6 +377,7 @@ func newPath(
 	matches []string,
 	wg *sync.WaitGroup,
 	externalCmdPool *externalcmd.Pool,
+	sourceStartLimiter *sourceStartLimiter,
 	parent pathParent,
 ) *path {
 	ctx, ctxCancel := context.WithCancel(parentCtx)
@@ -264,15 +394,19 @@ func newPath(
 		matches:                        matches,
 		wg:                             wg,
 		externalCmdPool:                externalCmdPool,
+		sourceStartLimiter:             sourceStartLimiter,
 		parent:                         parent,
 		ctx:                            ctx,
 		ctxCancel:                      ctxCancel,
 		bytesReceived:                  new(uint64),
+		readerCount:                    new(int64),
+		publisherCount:                 new(int64),
 		readers:                        make(map[reader]struct{}),
 		onDemandStaticSourceReadyTimer: newEmptyTimer(),
 		onDemandStaticSourceCloseTimer: newEmptyTimer(),
 		onDemandPublisherReadyTimer:    newEmptyTimer(),
 		onDemandPublisherCloseTimer:    newEmptyTimer(),
+		lastFrameTimer:                 newEmptyTimer(),
 		chReloadConf:                   make(chan *conf.PathConf),
 		chSourceStaticSetReady:         make(chan pathSourceStaticSetReadyReq),
 		chSourceStaticSetNotReady:      make(chan pathSourceStaticSetNotReadyReq),
@@ -284,6 +418,11 @@ func newPath(
 		chReaderAdd:                    make(chan pathReaderAddReq),
 		chReaderRemove:                 make(chan pathReaderRemoveReq),
 		chAPIPathsList:                 make(chan pathAPIPathsListSubReq),
+		chAPIPathsCapture:              make(chan pathAPIPathsCaptureReq),
+		chSourceConf:                   make(chan pathSourceConfReq),
+		chLimitExceeded:                make(chan pathLimitExceededReq),
+		chPublisherReplaced:            make(chan publisher),
+		chSourceStaticReplaced:         make(chan struct{}),
 		done:                           make(chan struct{}),
 	}
 
@@ -308,6 +447,11 @@ func (pa *path) Log(level logger.Level, format string, args ...interface{}) {
 	pa.parent.Log(level, "[path "+pa.name+"] "+format, args...)
 }
 
+// apiEventPublish is called by pathSCTE35.
+func (pa *path) apiEventPublish(ev apiEvent) {
+	pa.parent.apiEventPublish(ev)
+}
+
 func (pa *path) safeConf() *conf.PathConf {
 	pa.confMutex.RLock()
 	defer pa.confMutex.RUnlock()
@@ -323,24 +467,28 @@ func (pa *path) run() {
 	} else if pa.conf.HasStaticSource() {
 		pa.source = newSourceStatic(
 			pa.conf,
+			pa.matches,
 			pa.readTimeout,
 			pa.writeTimeout,
 			pa.readBufferCount,
+			pa.externalCmdPool,
+			pa.sourceStartLimiter,
 			pa)
 
 		if !pa.conf.SourceOnDemand {
-			pa.source.(*sourceStatic).start()
+			pa.source.(*sourceStatic).start("", "")
 		}
 	}
 
 	var onInitCmd *externalcmd.Cmd
 	if pa.conf.RunOnInit != "" {
 		pa.Log(logger.Info, "runOnInit command started")
-		onInitCmd = externalcmd.NewCmd(
+		onInitCmd = externalcmd.NewCmdWithOptions(
 			pa.externalCmdPool,
 			pa.conf.RunOnInit,
 			pa.conf.RunOnInitRestart,
 			pa.externalCmdEnv(),
+			externalCmdOptions(pa.conf, pa),
 			func(co int) {
 				pa.Log(logger.Info, "runOnInit command exited with code %d", co)
 			})
@@ -367,7 +515,7 @@ func (pa *path) run() {
 				}
 
 			case <-pa.onDemandStaticSourceCloseTimer.C:
-				pa.sourceSetNotReady()
+				pa.sourceSetNotReady("not requested anymore")
 				pa.onDemandStaticSourceStop()
 
 				if pa.shouldClose() {
@@ -385,14 +533,22 @@ func (pa *path) run() {
 				}
 				pa.readerAddRequestsOnHold = nil
 
-				pa.onDemandPublisherStop()
+				pa.onDemandPublisherStop("runOnDemand command didn't publish in time")
 
 				if pa.shouldClose() {
 					return fmt.Errorf("not in use")
 				}
 
 			case <-pa.onDemandPublisherCloseTimer.C:
-				pa.onDemandPublisherStop()
+				pa.onDemandPublisherStop("not requested anymore")
+
+				if pa.shouldClose() {
+					return fmt.Errorf("not in use")
+				}
+
+			case <-pa.lastFrameTimer.C:
+				pa.Log(logger.Info, "publisher didn't come back, closing readers")
+				pa.sourceSetNotReady("publisher didn't come back")
 
 				if pa.shouldClose() {
 					return fmt.Errorf("not in use")
@@ -408,6 +564,19 @@ func (pa *path) run() {
 				pa.confMutex.Unlock()
 
 			case req := <-pa.chSourceStaticSetReady:
+				if req.reannounce && pa.stream != nil {
+					stream, ok := pa.stream.reannounce(req.medias, req.generateRTPPackets, pa.source, pa.sourceStaticReplaced)
+					if ok {
+						pa.Log(logger.Info, "source has been reannounced, readers have been kept")
+						pa.sourceSetUp()
+						req.res <- pathSourceStaticSetReadyRes{stream: stream}
+						continue
+					}
+
+					pa.Log(logger.Info, "source has been reannounced with different tracks, closing readers")
+					pa.sourceSetNotReady("tracks changed")
+				}
+
 				err := pa.sourceSetReady(req.medias, req.generateRTPPackets)
 				if err != nil {
 					req.res <- pathSourceStaticSetReadyRes{err: err}
@@ -435,7 +604,15 @@ func (pa *path) run() {
 				}
 
 			case req := <-pa.chSourceStaticSetNotReady:
-				pa.sourceSetNotReady()
+				if req.keepReaders {
+					if pa.stream != nil {
+						pa.sourceSetDown()
+					}
+					close(req.res)
+					continue
+				}
+
+				pa.sourceSetNotReady(req.reason())
 
 				// send response before calling onDemandStaticSourceStop()
 				// in order to avoid a deadlock due to sourceStatic.stop()
@@ -489,6 +666,25 @@ func (pa *path) run() {
 			case req := <-pa.chAPIPathsList:
 				pa.handleAPIPathsList(req)
 
+			case req := <-pa.chAPIPathsCapture:
+				pa.handleAPIPathsCapture(req)
+
+			case req := <-pa.chSourceConf:
+				pa.handleSourceConf(req)
+
+			case req := <-pa.chLimitExceeded:
+				pa.handleLimitExceeded(req)
+
+				if pa.shouldClose() {
+					return fmt.Errorf("not in use")
+				}
+
+			case p := <-pa.chPublisherReplaced:
+				pa.handlePublisherReplaced(p)
+
+			case <-pa.chSourceStaticReplaced:
+				pa.parent.pathSourceReplaced(pa)
+
 			case <-pa.ctx.Done():
 				return fmt.Errorf("terminated")
 			}
@@ -504,6 +700,7 @@ func (pa *path) run() {
 	pa.onDemandStaticSourceCloseTimer.Stop()
 	pa.onDemandPublisherReadyTimer.Stop()
 	pa.onDemandPublisherCloseTimer.Stop()
+	pa.lastFrameTimer.Stop()
 
 	if onInitCmd != nil {
 		onInitCmd.Close()
@@ -519,7 +716,7 @@ func (pa *path) run() {
 	}
 
 	if pa.stream != nil {
-		pa.sourceSetNotReady()
+		pa.sourceSetNotReady("path closed")
 	}
 
 	if pa.source != nil {
@@ -530,6 +727,10 @@ func (pa *path) run() {
 		}
 	}
 
+	if pa.previousPublisher != nil {
+		pa.previousPublisher.close()
+	}
+
 	if pa.onDemandCmd != nil {
 		pa.onDemandCmd.Close()
 		pa.Log(logger.Info, "runOnDemand command stopped")
@@ -546,11 +747,27 @@ func (pa *path) shouldClose() bool {
 		len(pa.readerAddRequestsOnHold) == 0
 }
 
+// externalCmdOptions returns the options of the external commands of a path.
+// Errors are logged with the given logger.
+func externalCmdOptions(pconf *conf.PathConf, l logger.Writer) externalcmd.Options {
+	return externalcmd.Options{
+		User:        pconf.RunOnUser,
+		Dir:         pconf.RunOnDir,
+		CPULimit:    time.Duration(pconf.RunOnCPULimit),
+		MemoryLimit: uint64(pconf.RunOnMemoryLimit),
+		KillTimeout: time.Duration(pconf.RunOnKillTimeout),
+		OnError: func(err error) {
+			l.Log(logger.Error, "unable to start external command: %v", err)
+		},
+	}
+}
+
 func (pa *path) externalCmdEnv() externalcmd.Environment {
-	_, port, _ := net.SplitHostPort(pa.rtspAddress)
+	port := listenAddressPort(pa.rtspAddress)
 	env := externalcmd.Environment{
-		"RTSP_PATH": pa.name,
-		"RTSP_PORT": port,
+		"RTSP_PATH":    pa.name,
+		"RTSP_PORT":    port,
+		"RTSP_READERS": strconv.FormatInt(atomic.LoadInt64(pa.readerCount), 10),
 	}
 
 	if len(pa.matches) > 1 {
@@ -562,8 +779,21 @@ func (pa *path) externalCmdEnv() externalcmd.Environment {
 	return env
 }
 
-func (pa *path) onDemandStaticSourceStart() {
-	pa.source.(*sourceStatic).start()
+// sourceTemplateMismatch checks whether a reader would fill the templates of the source
+// differently than the reader that started it. These readers are rejected,
+// since they would receive a stream that was not requested.
+func (pa *path) sourceTemplateMismatch(query string, protocol string) bool {
+	if !pa.conf.HasOnDemandStaticSource() ||
+		!pa.conf.HasSourceTemplate() ||
+		pa.onDemandStaticSourceState == pathOnDemandStateInitial {
+		return false
+	}
+
+	return !pa.source.(*sourceStatic).templatesMatch(pa.conf, query, protocol)
+}
+
+func (pa *path) onDemandStaticSourceStart(query string, protocol string) {
+	pa.source.(*sourceStatic).start(query, protocol)
 
 	pa.onDemandStaticSourceReadyTimer.Stop()
 	pa.onDemandStaticSourceReadyTimer = time.NewTimer(time.Duration(pa.conf.SourceOnDemandStartTimeout))
@@ -589,13 +819,21 @@ func (pa *path) onDemandStaticSourceStop() {
 	pa.source.(*sourceStatic).stop()
 }
 
-func (pa *path) onDemandPublisherStart() {
+func (pa *path) onDemandPublisherStart(query string, protocol string) {
+	// readers that are waiting for the stream are part of the audience too.
+	env := pa.externalCmdEnv()
+	env["RTSP_READERS"] = strconv.FormatInt(int64(len(pa.readers)+
+		len(pa.describeRequestsOnHold)+len(pa.readerAddRequestsOnHold)), 10)
+	env["RTSP_QUERY"] = query
+	env["RTSP_READER_PROTOCOL"] = protocol
+
 	pa.Log(logger.Info, "runOnDemand command started")
-	pa.onDemandCmd = externalcmd.NewCmd(
+	pa.onDemandCmd = externalcmd.NewCmdWithOptions(
 		pa.externalCmdPool,
 		pa.conf.RunOnDemand,
 		pa.conf.RunOnDemandRestart,
-		pa.externalCmdEnv(),
+		env,
+		externalCmdOptions(pa.conf, pa),
 		func(co int) {
 			pa.Log(logger.Info, "runOnDemand command exited with code %d", co)
 		})
@@ -613,7 +851,7 @@ func (pa *path) onDemandPublisherScheduleClose() {
 	pa.onDemandPublisherState = pathOnDemandStateClosing
 }
 
-func (pa *path) onDemandPublisherStop() {
+func (pa *path) onDemandPublisherStop(reason string) {
 	if pa.onDemandPublisherState == pathOnDemandStateClosing {
 		pa.onDemandPublisherCloseTimer.Stop()
 		pa.onDemandPublisherCloseTimer = newEmptyTimer()
@@ -624,7 +862,7 @@ func (pa *path) onDemandPublisherStop() {
 
 	if pa.source != nil {
 		pa.source.(publisher).close()
-		pa.doPublisherRemove()
+		pa.doPublisherRemove(reason)
 	}
 
 	if pa.onDemandCmd != nil {
@@ -635,42 +873,204 @@ func (pa *path) onDemandPublisherStop() {
 }
 
 func (pa *path) sourceSetReady(medias media.Medias, allocateEncoder bool) error {
-	stream, err := newStream(
-		pa.udpMaxPayloadSize,
-		medias,
-		allocateEncoder,
-		pa.bytesReceived,
-		pa.source,
-	)
-	if err != nil {
-		return err
+	var err error
+
+	// readers that were kept when the previous source went away
+	// continue reading from their stream, that is fed by the new source.
+	stream, readers := pa.parent.readersPendingTake(pa, medias, allocateEncoder, pa.source)
+	if stream == nil {
+		stream, err = pa.newStream(medias, allocateEncoder)
+		if err != nil {
+			return err
+		}
 	}
 
 	pa.stream = stream
 
+	if len(readers) != 0 {
+		for r := range readers {
+			pa.readers[r] = struct{}{}
+		}
+		atomic.StoreInt64(pa.readerCount, int64(len(pa.readers)))
+
+		pa.Log(logger.Info, "source is back, pending readers have been reattached")
+	}
+
+	if pa.conf.MulticastOutputAddress != "" {
+		pa.multicastOutput, err = newMulticastOutput(
+			pa.conf.MulticastOutputAddress,
+			pa.conf.MulticastOutputTTL,
+			pa.conf.MulticastOutputSource,
+			pa.conf.MulticastOutputSDPFile,
+			pa.readBufferCount,
+			pa.stream,
+			pa,
+		)
+		if err != nil {
+			pa.Log(logger.Error, "unable to start the multicast output: %v", err)
+		}
+	}
+
+	pa.scte35 = newPathSCTE35(
+		pa.readBufferCount,
+		time.Duration(pa.readTimeout),
+		pa.conf.RunOnSCTE35Webhook,
+		pa.name,
+		pa.stream,
+		pa,
+	)
+
+	if pa.conf.SourceONVIFEventsAddress != "" {
+		pa.motion, err = newPathMotion(
+			pa.ctx,
+			pa.conf.SourceONVIFEventsAddress,
+			pa.conf.Source,
+			time.Duration(pa.readTimeout),
+			pa.conf.RunOnMotion,
+			pa.conf.RunOnMotionRestart,
+			pa.conf.RunOnMotionWebhook,
+			externalCmdOptions(pa.conf, pa),
+			pa.externalCmdPool,
+			pa.externalCmdEnv,
+			pa.name,
+			pa,
+		)
+		if err != nil {
+			pa.Log(logger.Error, "unable to receive ONVIF events: %v", err)
+		}
+	}
+
 	if pa.conf.RunOnReady != "" {
 		pa.Log(logger.Info, "runOnReady command started")
-		pa.onReadyCmd = externalcmd.NewCmd(
+		pa.onReadyCmd = externalcmd.NewCmdWithOptions(
 			pa.externalCmdPool,
 			pa.conf.RunOnReady,
 			pa.conf.RunOnReadyRestart,
 			pa.externalCmdEnv(),
+			externalCmdOptions(pa.conf, pa),
 			func(co int) {
 				pa.Log(logger.Info, "runOnReady command exited with code %d", co)
 			})
 	}
 
 	pa.parent.pathSourceReady(pa)
+	pa.parent.historyStart(pa.name, pa.source.apiSourceDescribe())
 
 	return nil
 }
 
-func (pa *path) sourceSetNotReady() {
+// newStream allocates the stream of the path, together with the alerts that depend on it.
+func (pa *path) newStream(medias media.Medias, allocateEncoder bool) (*stream, error) {
+	var seiTimestamp *formatprocessor.SEITimestamp
+	if pa.conf.SEITimestamp {
+		seiTimestamp = &formatprocessor.SEITimestamp{
+			Label: pa.conf.SEITimestampLabel,
+		}
+	}
+
+	var analyzer *formatprocessor.Analyzer
+	var onHealthScore func(int)
+
+	if pa.conf.StaticVideoTimeout != 0 || pa.conf.SilentAudioTimeout != 0 ||
+		pa.conf.MaxBitrate != 0 || pa.conf.MaxGOPDuration != 0 || pa.conf.MinHealthScore != 0 {
+		pa.alerts = newPathAlerts(
+			pa.readBufferCount,
+			time.Duration(pa.readTimeout),
+			pa.conf.RunOnAlert,
+			pa.conf.RunOnAlertRestart,
+			pa.conf.RunOnAlertWebhook,
+			externalCmdOptions(pa.conf, pa),
+			pa.externalCmdPool,
+			pa.externalCmdEnv,
+			pa.name,
+			pa.limitExceeded,
+			pa,
+		)
+
+		analyzer = &formatprocessor.Analyzer{
+			StaticVideoTimeout: time.Duration(pa.conf.StaticVideoTimeout),
+			SilentAudioTimeout: time.Duration(pa.conf.SilentAudioTimeout),
+			MaxBitrate:         pa.conf.MaxBitrate,
+			MaxGOPDuration:     time.Duration(pa.conf.MaxGOPDuration),
+			OnChange:           pa.alerts.onChange,
+		}
+
+		if pa.conf.MinHealthScore != 0 {
+			alerts := pa.alerts
+			minScore := pa.conf.MinHealthScore
+			onHealthScore = func(score int) {
+				alerts.onHealthScore(minScore, score)
+			}
+		}
+	}
+
+	stream, err := newStream(
+		pa.udpMaxPayloadSize,
+		medias,
+		allocateEncoder,
+		pa.conf.PreserveSSRC,
+		seiTimestamp,
+		analyzer,
+		pa.bytesReceived,
+		onHealthScore,
+		pa.conf.KeepLastFrame != 0,
+		pa.conf.NormalizeTimestamps,
+		pa.source,
+	)
+	if err != nil {
+		if pa.alerts != nil {
+			pa.alerts.close()
+			pa.alerts = nil
+		}
+		return nil, err
+	}
+
+	return stream, nil
+}
+
+// sourceSetDown is called when the static source disconnects and starts reconnecting.
+// The path is not ready anymore, but the stream is kept in order to resume
+// reading when the source comes back with the same tracks.
+func (pa *path) sourceSetDown() {
+	pa.sourceDown = true
+	pa.Log(logger.Info, "source is down, readers are kept while it reconnects")
 	pa.parent.pathSourceNotReady(pa)
+}
 
-	for r := range pa.readers {
-		pa.doReaderRemove(r)
-		r.close()
+// sourceSetUp is called when the static source comes back after sourceSetDown().
+// Requests that arrived in the meanwhile are served.
+func (pa *path) sourceSetUp() {
+	pa.sourceDown = false
+	pa.parent.pathSourceReady(pa)
+
+	for _, req := range pa.describeRequestsOnHold {
+		req.res <- pathDescribeRes{
+			stream: pa.stream,
+		}
+	}
+	pa.describeRequestsOnHold = nil
+
+	for _, req := range pa.readerAddRequestsOnHold {
+		pa.handleReaderAddPost(req)
+	}
+	pa.readerAddRequestsOnHold = nil
+}
+
+func (pa *path) sourceSetNotReady(reason string) {
+	if !pa.sourceDown {
+		pa.parent.pathSourceNotReady(pa)
+	}
+	pa.sourceDown = false
+
+	pa.parent.historyStop(pa.name, reason)
+
+	pa.lastFrameStop()
+
+	if !pa.readersKeep() {
+		for r := range pa.readers {
+			pa.doReaderRemove(r)
+			r.close()
+		}
 	}
 
 	if pa.onReadyCmd != nil {
@@ -679,26 +1079,134 @@ func (pa *path) sourceSetNotReady() {
 		pa.Log(logger.Info, "runOnReady command stopped")
 	}
 
+	if pa.multicastOutput != nil {
+		pa.multicastOutput.close()
+		pa.multicastOutput = nil
+	}
+
+	if pa.motion != nil {
+		pa.motion.close()
+		pa.motion = nil
+	}
+
+	if pa.scte35 != nil {
+		pa.scte35.close()
+		pa.scte35 = nil
+	}
+
+	if pa.alerts != nil {
+		pa.alerts.close()
+		pa.alerts = nil
+	}
+
 	if pa.stream != nil {
 		pa.stream.close()
 		pa.stream = nil
 	}
 }
 
+// readersKeep hands readers to pathManager, together with the stream they are reading,
+// in order to reattach them to the path with the same name when its source comes back.
+func (pa *path) readersKeep() bool {
+	if pa.conf.ReaderGracePeriod == 0 || pa.stream == nil || len(pa.readers) == 0 {
+		return false
+	}
+
+	readers := make(map[reader]struct{}, len(pa.readers))
+	for r := range pa.readers {
+		readers[r] = struct{}{}
+	}
+
+	if !pa.parent.readersPendingAdd(pa.name, pa.stream, readers, time.Duration(pa.conf.ReaderGracePeriod)) {
+		return false
+	}
+
+	pa.Log(logger.Info, "source is gone, keeping readers for %v", time.Duration(pa.conf.ReaderGracePeriod))
+
+	pa.readers = make(map[reader]struct{})
+	atomic.StoreInt64(pa.readerCount, 0)
+
+	// the stream is closed by pathManager
+	pa.stream = nil
+
+	return true
+}
+
 func (pa *path) doReaderRemove(r reader) {
+	pa.parent.readersPendingForget(r)
 	delete(pa.readers, r)
+	atomic.StoreInt64(pa.readerCount, int64(len(pa.readers)))
+
+	pa.parent.apiEventPublish(apiEvent{
+		Type:   apiEventClientDisconnect,
+		Time:   time.Now(),
+		Path:   pa.name,
+		Client: r.apiReaderDescribe(),
+		Role:   "reader",
+	})
 }
 
-func (pa *path) doPublisherRemove() {
+func (pa *path) doPublisherRemove(reason string) {
 	if pa.stream != nil {
 		if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
-			pa.onDemandPublisherStop()
-		} else {
-			pa.sourceSetNotReady()
+			pa.onDemandPublisherStop(reason)
+		} else if !pa.lastFrameStart() {
+			pa.sourceSetNotReady(reason)
 		}
 	}
 
+	if pa.source != nil {
+		pa.publisherEventPublish(apiEventClientDisconnect, pa.source)
+	}
+
 	pa.source = nil
+	atomic.StoreInt64(pa.publisherCount, 0)
+}
+
+func (pa *path) publisherEventPublish(typ apiEventType, s source) {
+	pa.parent.apiEventPublish(apiEvent{
+		Type:   typ,
+		Time:   time.Now(),
+		Path:   pa.name,
+		Client: s.apiSourceDescribe(),
+		Role:   "publisher",
+	})
+}
+
+// previousPublisherClose closes the publisher that is being replaced by the current one.
+func (pa *path) previousPublisherClose() {
+	pa.previousPublisher.close()
+	pa.publisherEventPublish(apiEventClientDisconnect, pa.previousPublisher)
+	pa.previousPublisher = nil
+}
+
+// lastFrameStart keeps readers alive by repeating the last key frame of the stream,
+// until the publisher comes back or keepLastFrame expires.
+func (pa *path) lastFrameStart() bool {
+	if pa.conf.KeepLastFrame == 0 {
+		return false
+	}
+
+	pa.lastFrame = newPathLastFrame(pa.ctx, pa.stream.writer(), pa.conf.KeepLastFrameLabel)
+	if pa.lastFrame == nil {
+		return false
+	}
+
+	pa.Log(logger.Info, "publisher is gone, repeating the last frame for %v", pa.conf.KeepLastFrame)
+
+	pa.lastFrameTimer.Stop()
+	pa.lastFrameTimer = time.NewTimer(time.Duration(pa.conf.KeepLastFrame))
+	return true
+}
+
+func (pa *path) lastFrameStop() {
+	if pa.lastFrame != nil {
+		pa.lastFrame.close()
+		pa.lastFrame = nil
+
+		pa.lastFrameTimer.Stop()
+		pa.lastFrameTimer = newEmptyTimer()
+	}
 }
 
 func (pa *path) handleDescribe(req pathDescribeReq) {
@@ -709,7 +1217,12 @@ func (pa *path) handleDescribe(req pathDescribeReq) {
 		return
 	}
 
-	if pa.stream != nil {
+	if pa.sourceTemplateMismatch(req.query, req.protocol) {
+		req.res <- pathDescribeRes{err: pathErrSourceTemplateMismatch{pathName: pa.name}}
+		return
+	}
+
+	if pa.stream != nil && !pa.sourceDown {
 		req.res <- pathDescribeRes{
 			stream: pa.stream,
 		}
@@ -718,17 +1231,17 @@ func (pa *path) handleDescribe(req pathDescribeReq) {
 
 	if pa.conf.HasOnDemandStaticSource() {
 		if pa.onDemandStaticSourceState == pathOnDemandStateInitial {
-			pa.onDemandStaticSourceStart()
+			pa.onDemandStaticSourceStart(req.query, req.protocol)
 		}
 		pa.describeRequestsOnHold = append(pa.describeRequestsOnHold, req)
 		return
 	}
 
 	if pa.conf.HasOnDemandPublisher() {
+		pa.describeRequestsOnHold = append(pa.describeRequestsOnHold, req)
 		if pa.onDemandPublisherState == pathOnDemandStateInitial {
-			pa.onDemandPublisherStart()
+			pa.onDemandPublisherStart(req.query, req.protocol)
 		}
-		pa.describeRequestsOnHold = append(pa.describeRequestsOnHold, req)
 		return
 	}
 
@@ -753,9 +1266,25 @@ func (pa *path) handleDescribe(req pathDescribeReq) {
 }
 
 func (pa *path) handlePublisherRemove(req pathPublisherRemoveReq) {
-	if pa.source == req.author {
-		pa.doPublisherRemove()
+	switch {
+	case pa.source == req.author && pa.previousPublisher != nil && pa.stream.writer().source != req.author:
+		// the publisher is gone before replacing the previous one, that is restored.
+		pa.Log(logger.Info, "new publisher is gone, keeping the existing one")
+		pa.publisherEventPublish(apiEventClientDisconnect, pa.source)
+		pa.source = pa.previousPublisher
+		pa.previousPublisher = nil
+
+	case pa.source == req.author:
+		if pa.previousPublisher != nil {
+			pa.previousPublisherClose()
+		}
+		pa.doPublisherRemove("publisher disconnected")
+
+	case pa.previousPublisher == req.author:
+		pa.publisherEventPublish(apiEventClientDisconnect, pa.previousPublisher)
+		pa.previousPublisher = nil
 	}
+
 	close(req.res)
 }
 
@@ -773,12 +1302,30 @@ func (pa *path) handlePublisherAdd(req pathPublisherAddReq) {
 			return
 		}
 
-		pa.Log(logger.Info, "closing existing publisher")
-		pa.source.(publisher).close()
-		pa.doPublisherRemove()
+		switch {
+		case pa.stream == nil || pa.conf.HasOnDemandPublisher():
+			pa.Log(logger.Info, "closing existing publisher")
+			pa.source.(publisher).close()
+			pa.doPublisherRemove("replaced by another publisher")
+
+		case pa.previousPublisher != nil:
+			// the current publisher didn't replace the previous one yet, replace it directly.
+			pa.Log(logger.Info, "closing publisher that is replacing the existing one")
+			pa.source.(publisher).close()
+			pa.publisherEventPublish(apiEventClientDisconnect, pa.source)
+
+		default:
+			// keep the existing publisher and the stream alive until the new publisher
+			// delivers its first key frame, in order to avoid gaps.
+			pa.Log(logger.Info, "existing publisher will be closed when the new one starts")
+			pa.previousPublisher = pa.source.(publisher)
+		}
 	}
 
 	pa.source = req.author
+	atomic.StoreInt64(pa.publisherCount, 1)
+
+	pa.publisherEventPublish(apiEventClientConnect, req.author)
 
 	req.res <- pathPublisherAnnounceRes{path: pa}
 }
@@ -789,6 +1336,27 @@ func (pa *path) handlePublisherStart(req pathPublisherStartReq) {
 		return
 	}
 
+	if pa.stream != nil {
+		pa.lastFrameStop()
+
+		stream, ok := pa.stream.reannounce(req.medias, req.generateRTPPackets, req.author, func() {
+			pa.publisherReplaced(req.author)
+		})
+		if ok {
+			pa.Log(logger.Info, "publisher is being replaced, readers have been kept")
+			req.res <- pathPublisherRecordRes{stream: stream}
+			return
+		}
+
+		pa.Log(logger.Info, "publisher has been replaced with different tracks, closing readers")
+
+		if pa.previousPublisher != nil {
+			pa.previousPublisherClose()
+		}
+
+		pa.sourceSetNotReady("tracks changed")
+	}
+
 	err := pa.sourceSetReady(req.medias, req.generateRTPPackets)
 	if err != nil {
 		req.res <- pathPublisherRecordRes{err: err}
@@ -818,16 +1386,30 @@ func (pa *path) handlePublisherStart(req pathPublisherStartReq) {
 }
 
 func (pa *path) handlePublisherStop(req pathPublisherStopReq) {
-	if req.author == pa.source && pa.stream != nil {
+	// when the publisher is replacing another one, the stream is still in use.
+	if req.author == pa.source && pa.stream != nil && pa.previousPublisher == nil {
 		if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
-			pa.onDemandPublisherStop()
+			pa.onDemandPublisherStop("publisher stopped publishing")
 		} else {
-			pa.sourceSetNotReady()
+			pa.sourceSetNotReady("publisher stopped publishing")
 		}
 	}
 	close(req.res)
 }
 
+func (pa *path) handlePublisherReplaced(p publisher) {
+	if p != pa.source {
+		return
+	}
+
+	if pa.previousPublisher != nil {
+		pa.Log(logger.Info, "publisher has been replaced, closing the previous one")
+		pa.previousPublisherClose()
+	}
+
+	pa.parent.pathSourceReplaced(pa)
+}
+
 func (pa *path) handleReaderRemove(req pathReaderRemoveReq) {
 	if _, ok := pa.readers[req.author]; ok {
 		pa.doReaderRemove(req.author)
@@ -848,24 +1430,29 @@ func (pa *path) handleReaderRemove(req pathReaderRemoveReq) {
 }
 
 func (pa *path) handleReaderAdd(req pathReaderAddReq) {
-	if pa.stream != nil {
+	if pa.sourceTemplateMismatch(req.query, req.protocol) {
+		req.res <- pathReaderSetupPlayRes{err: pathErrSourceTemplateMismatch{pathName: pa.name}}
+		return
+	}
+
+	if pa.stream != nil && !pa.sourceDown {
 		pa.handleReaderAddPost(req)
 		return
 	}
 
 	if pa.conf.HasOnDemandStaticSource() {
 		if pa.onDemandStaticSourceState == pathOnDemandStateInitial {
-			pa.onDemandStaticSourceStart()
+			pa.onDemandStaticSourceStart(req.query, req.protocol)
 		}
 		pa.readerAddRequestsOnHold = append(pa.readerAddRequestsOnHold, req)
 		return
 	}
 
 	if pa.conf.HasOnDemandPublisher() {
+		pa.readerAddRequestsOnHold = append(pa.readerAddRequestsOnHold, req)
 		if pa.onDemandPublisherState == pathOnDemandStateInitial {
-			pa.onDemandPublisherStart()
+			pa.onDemandPublisherStart(req.query, req.protocol)
 		}
-		pa.readerAddRequestsOnHold = append(pa.readerAddRequestsOnHold, req)
 		return
 	}
 
@@ -874,6 +1461,15 @@ func (pa *path) handleReaderAdd(req pathReaderAddReq) {
 
 func (pa *path) handleReaderAddPost(req pathReaderAddReq) {
 	pa.readers[req.author] = struct{}{}
+	atomic.StoreInt64(pa.readerCount, int64(len(pa.readers)))
+
+	pa.parent.apiEventPublish(apiEvent{
+		Type:   apiEventClientConnect,
+		Time:   time.Now(),
+		Path:   pa.name,
+		Client: req.author.apiReaderDescribe(),
+		Role:   "reader",
+	})
 
 	if pa.conf.HasOnDemandStaticSource() {
 		if pa.onDemandStaticSourceState == pathOnDemandStateClosing {
@@ -905,14 +1501,27 @@ func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
 			}
 			return pa.source.apiSourceDescribe()
 		}(),
-		SourceReady: pa.stream != nil,
+		SourceReady: pa.stream != nil && !pa.sourceDown,
+		SourceError: func() *string {
+			if s, ok := pa.source.(*sourceStatic); ok {
+				return s.apiSourceError()
+			}
+			return nil
+		}(),
 		Tracks: func() []string {
 			if pa.stream == nil {
 				return []string{}
 			}
 			return mediasDescription(pa.stream.medias())
 		}(),
+		SSRCs: func() []*uint32 {
+			if pa.stream == nil {
+				return []*uint32{}
+			}
+			return pa.stream.ssrcs()
+		}(),
 		BytesReceived: atomic.LoadUint64(pa.bytesReceived),
+		ReaderCount:   len(pa.readers),
 		Readers: func() []interface{} {
 			ret := []interface{}{}
 			for r := range pa.readers {
@@ -920,10 +1529,157 @@ func (pa *path) handleAPIPathsList(req pathAPIPathsListSubReq) {
 			}
 			return ret
 		}(),
+		Alerts: func() []pathAPIAlert {
+			if pa.alerts == nil {
+				return []pathAPIAlert{}
+			}
+			return pa.alerts.apiList()
+		}(),
+		Health: func() *int {
+			if pa.stream == nil {
+				return nil
+			}
+			return pa.stream.health.current()
+		}(),
+		sourceStats: func() *sourceStaticStatsAPI {
+			if s, ok := pa.source.(*sourceStatic); ok {
+				return s.apiSourceStats()
+			}
+			return nil
+		}(),
+		latency: func() map[string]streamLatencyPercentiles {
+			if pa.stream == nil {
+				return nil
+			}
+			return pa.stream.latency.percentiles()
+		}(),
 	}
 	close(req.res)
 }
 
+func (pa *path) handleLimitExceeded(req pathLimitExceededReq) {
+	// the stream has been closed in the meanwhile.
+	if req.alerts != pa.alerts {
+		return
+	}
+
+	reason := "alert " + pathAlertKey{typ: req.alert, track: req.track}.String() + " started"
+
+	switch pa.conf.LimitAction {
+	case conf.LimitActionNotReady:
+		pa.Log(logger.Warn, "alert %s started, closing the stream", pathAlertKey{typ: req.alert, track: req.track})
+
+		if _, ok := pa.source.(*sourceStatic); ok {
+			pa.staticSourceRestart(reason)
+		} else if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
+			pa.onDemandPublisherStop(reason)
+		} else {
+			// keep the publisher connected. Its stream is not routed until it publishes again.
+			pa.sourceSetNotReady(reason)
+		}
+
+	case conf.LimitActionDisconnect:
+		pa.Log(logger.Warn, "alert %s started, closing the source", pathAlertKey{typ: req.alert, track: req.track})
+
+		if _, ok := pa.source.(*sourceStatic); ok {
+			pa.staticSourceRestart(reason)
+		} else if pa.conf.HasOnDemandPublisher() && pa.onDemandPublisherState != pathOnDemandStateInitial {
+			pa.onDemandPublisherStop(reason)
+		} else if pa.source != nil {
+			pa.source.(publisher).close()
+			pa.doPublisherRemove(reason)
+		} else {
+			// the last frame of a publisher that is gone is being repeated.
+			pa.sourceSetNotReady(reason)
+		}
+	}
+}
+
+// staticSourceRestart closes the stream of a static source and reconnects the source.
+func (pa *path) staticSourceRestart(reason string) {
+	pa.sourceSetNotReady(reason)
+
+	if pa.conf.HasOnDemandStaticSource() {
+		pa.onDemandStaticSourceStop()
+		return
+	}
+
+	s := pa.source.(*sourceStatic)
+	s.stop()
+	s.start("", "")
+}
+
+func (pa *path) handleSourceConf(req pathSourceConfReq) {
+	src, ok := pa.source.(*sourceStatic)
+	if !ok {
+		req.res <- pathSourceConfRes{err: fmt.Errorf("path '%s' has no static source", pa.name)}
+		return
+	}
+
+	req.res <- pathSourceConfRes{conf: src.confWithTemplates(pa.conf)}
+}
+
+func (pa *path) handleAPIPathsCapture(req pathAPIPathsCaptureReq) {
+	if pa.stream == nil {
+		req.res <- pathAPIPathsCaptureRes{err: fmt.Errorf("no one is publishing to path '%s'", pa.name)}
+		return
+	}
+
+	err := os.MkdirAll(req.directory, 0o755)
+	if err != nil {
+		req.res <- pathAPIPathsCaptureRes{err: err}
+		return
+	}
+
+	err = rtpCaptureCleanup(req.directory, req.maxFiles)
+	if err != nil {
+		req.res <- pathAPIPathsCaptureRes{err: err}
+		return
+	}
+
+	f, err := os.CreateTemp(req.directory, strings.Replace(rtpCaptureFilePattern, "*",
+		strings.ReplaceAll(pa.name, "/", "_")+"_*", 1))
+	if err != nil {
+		req.res <- pathAPIPathsCaptureRes{err: err}
+		return
+	}
+
+	c, err := newRTPCapture(f, req.maxSize)
+	if err != nil {
+		f.Close()
+		os.Remove(f.Name())
+		req.res <- pathAPIPathsCaptureRes{err: err}
+		return
+	}
+
+	stream := pa.stream
+	stream.captureAdd(c)
+
+	pa.Log(logger.Info, "capture started, saving to %s", f.Name())
+
+	go func() {
+		select {
+		case <-time.After(req.duration):
+		case <-c.full:
+			pa.Log(logger.Warn, "capture reached the maximum size")
+		case <-pa.ctx.Done():
+		}
+
+		stream.captureRemove(c)
+
+		err := c.close()
+		if err != nil {
+			pa.Log(logger.Warn, "capture error: %v", err)
+			os.Remove(f.Name())
+			return
+		}
+
+		pa.Log(logger.Info, "capture stopped, saved to %s", f.Name())
+	}()
+
+	req.res <- pathAPIPathsCaptureRes{file: f.Name()}
+}
+
 // reloadConf is called by pathManager.
 func (pa *path) reloadConf(newConf *conf.PathConf) {
 	select {
@@ -964,6 +1720,48 @@ func (pa *path) sourceStaticSetNotReady(sourceStaticCtx context.Context, req pat
 	}
 }
 
+// limitExceeded is called by pathAlerts when an alert starts.
+func (pa *path) limitExceeded(alerts *pathAlerts, alert formatprocessor.AnalyzerAlert, track string) {
+	if alert != formatprocessor.AnalyzerAlertMaxBitrate && alert != formatprocessor.AnalyzerAlertMaxGOPDuration &&
+		alert != pathAlertLowHealth {
+		return
+	}
+
+	if pa.safeConf().LimitAction == conf.LimitActionWarn {
+		return
+	}
+
+	// pathAlerts is called by the goroutine of the source, that may be waiting for the path.
+	go func() {
+		select {
+		case pa.chLimitExceeded <- pathLimitExceededReq{alerts: alerts, alert: alert, track: track}:
+		case <-pa.ctx.Done():
+		}
+	}()
+}
+
+// publisherReplaced is called by stream when the data of a publisher replaces the one of the previous publisher.
+func (pa *path) publisherReplaced(p publisher) {
+	// stream is called by the goroutine of the publisher, that may be waiting for the path.
+	go func() {
+		select {
+		case pa.chPublisherReplaced <- p:
+		case <-pa.ctx.Done():
+		}
+	}()
+}
+
+// sourceStaticReplaced is called by stream when a reconnected static source
+// reaches the splicing point.
+func (pa *path) sourceStaticReplaced() {
+	go func() {
+		select {
+		case pa.chSourceStaticReplaced <- struct{}{}:
+		case <-pa.ctx.Done():
+		}
+	}()
+}
+
 // describe is called by a reader or publisher through pathManager.
 func (pa *path) describe(req pathDescribeReq) pathDescribeRes {
 	select {
@@ -1027,15 +1825,82 @@ func (pa *path) readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes {
 
 // readerRemove is called by a reader.
 func (pa *path) readerRemove(req pathReaderRemoveReq) {
+	if pa.readerRemovePending(req) {
+		return
+	}
+
 	req.res = make(chan struct{})
 	select {
 	case pa.chReaderRemove <- req:
 		<-req.res
 	case <-pa.ctx.Done():
 	}
+
+	// the reader may have been made pending in the meanwhile.
+	pa.readerRemovePending(req)
+}
+
+// readerRemovePending removes a reader that is pending or that has been
+// reattached to another path.
+func (pa *path) readerRemovePending(req pathReaderRemoveReq) bool {
+	ok, owner := pa.parent.readersPendingRemove(pa, req.author)
+	if ok && owner != nil {
+		owner.readerRemove(req)
+	}
+	return ok
 }
 
 // apiPathsList is called by api.
+// apiPathsCapture is called by api through pathManager.
+func (pa *path) apiPathsCapture(req pathAPIPathsCaptureReq) pathAPIPathsCaptureRes {
+	req.res = make(chan pathAPIPathsCaptureRes)
+	select {
+	case pa.chAPIPathsCapture <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return pathAPIPathsCaptureRes{err: fmt.Errorf("terminated")}
+	}
+}
+
+// sourceConf is called by a reader.
+func (pa *path) sourceConf() pathSourceConfRes {
+	req := pathSourceConfReq{res: make(chan pathSourceConfRes)}
+	select {
+	case pa.chSourceConf <- req:
+		return <-req.res
+
+	case <-pa.ctx.Done():
+		return pathSourceConfRes{err: fmt.Errorf("terminated")}
+	}
+}
+
+// apiPathsPTZ is called by api.
+func (pa *path) apiPathsPTZ() pathAPIPathsPTZRes {
+	cnf := pa.safeConf()
+
+	if cnf.SourceONVIFAddress == "" {
+		return pathAPIPathsPTZRes{err: fmt.Errorf("path '%s' has no ONVIF address", pa.name)}
+	}
+
+	ur, user, pass, err := onvifServiceAddress(cnf.SourceONVIFAddress, cnf.Source)
+	if err != nil {
+		return pathAPIPathsPTZRes{err: err}
+	}
+
+	c := &onvif.Client{
+		URL:          ur,
+		Username:     user,
+		Password:     pass,
+		ProfileToken: cnf.SourceONVIFProfileToken,
+		HTTPClient: &http.Client{
+			Timeout: time.Duration(pa.readTimeout),
+		},
+	}
+
+	return pathAPIPathsPTZRes{client: c}
+}
+
 func (pa *path) apiPathsList(req pathAPIPathsListSubReq) {
 	req.res = make(chan struct{})
 	select {
@@ -0,0 +1,246 @@
+package core
+
+import (
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+
+	"github.com/aler9/mediamtx/internal/externalcmd"
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// pathAlertLowHealth is emitted when the health score of a stream is lower than minHealthScore.
+// It refers to the whole stream, therefore it has no track.
+const pathAlertLowHealth formatprocessor.AnalyzerAlert = "lowHealth"
+
+type pathAPIAlert struct {
+	Type  formatprocessor.AnalyzerAlert `json:"type"`
+	Track string                        `json:"track"`
+	Since time.Time                     `json:"since"`
+}
+
+type pathAlertKey struct {
+	typ   formatprocessor.AnalyzerAlert
+	track string
+}
+
+func (k pathAlertKey) String() string {
+	if k.track == "" {
+		return string(k.typ)
+	}
+	return string(k.typ) + " on track " + k.track
+}
+
+type pathAlert struct {
+	since time.Time
+	cmd   *externalcmd.Cmd
+}
+
+// pathAlerts contains the alerts emitted by the analyzer of a stream.
+// It is called by the goroutines of sources, therefore it's protected by a mutex.
+// Webhooks are called by a dedicated goroutine, in order not to block sources.
+type pathAlerts struct {
+	runOnAlert        string
+	runOnAlertRestart bool
+	runOnAlertWebhook string
+	externalCmdOpts   externalcmd.Options
+	externalCmdPool   *externalcmd.Pool
+	env               func() externalcmd.Environment
+	pathName          string
+	onStart           func(*pathAlerts, formatprocessor.AnalyzerAlert, string)
+	parent            logger.Writer
+
+	ringBuffer *ringbuffer.RingBuffer
+	httpClient *http.Client
+	done       chan struct{}
+
+	mutex  sync.Mutex
+	closed bool
+	alerts map[pathAlertKey]*pathAlert
+}
+
+func newPathAlerts(
+	readBufferCount int,
+	readTimeout time.Duration,
+	runOnAlert string,
+	runOnAlertRestart bool,
+	runOnAlertWebhook string,
+	externalCmdOpts externalcmd.Options,
+	externalCmdPool *externalcmd.Pool,
+	env func() externalcmd.Environment,
+	pathName string,
+	onStart func(*pathAlerts, formatprocessor.AnalyzerAlert, string),
+	parent logger.Writer,
+) *pathAlerts {
+	a := &pathAlerts{
+		runOnAlert:        runOnAlert,
+		runOnAlertRestart: runOnAlertRestart,
+		runOnAlertWebhook: runOnAlertWebhook,
+		externalCmdOpts:   externalCmdOpts,
+		externalCmdPool:   externalCmdPool,
+		env:               env,
+		pathName:          pathName,
+		onStart:           onStart,
+		parent:            parent,
+		alerts:            make(map[pathAlertKey]*pathAlert),
+	}
+
+	if runOnAlertWebhook != "" {
+		a.ringBuffer, _ = ringbuffer.New(uint64(readBufferCount))
+		a.httpClient = &http.Client{
+			Timeout: readTimeout,
+		}
+		a.done = make(chan struct{})
+		go a.runWebhook()
+	}
+
+	return a
+}
+
+func (a *pathAlerts) close() {
+	a.mutex.Lock()
+
+	a.closed = true
+
+	for key, al := range a.alerts {
+		a.stop(key, al)
+	}
+	a.alerts = nil
+
+	a.mutex.Unlock()
+
+	// pending calls, including the ones about the alerts that have just ended, are performed.
+	if a.ringBuffer != nil {
+		a.ringBuffer.Close()
+		<-a.done
+	}
+}
+
+func (a *pathAlerts) runWebhook() {
+	defer close(a.done)
+
+	for {
+		item, ok := a.ringBuffer.Pull()
+		if !ok {
+			return
+		}
+		item.(func())()
+	}
+}
+
+// callWebhook enqueues a call to runOnAlertWebhook.
+func (a *pathAlerts) callWebhook(key pathAlertKey, active bool, now time.Time) {
+	if a.ringBuffer == nil {
+		return
+	}
+
+	a.ringBuffer.Push(func() {
+		err := webhookPost(a.httpClient, a.runOnAlertWebhook, struct {
+			Path   string                        `json:"path"`
+			Alert  formatprocessor.AnalyzerAlert `json:"alert"`
+			Track  string                        `json:"track"`
+			Active bool                          `json:"active"`
+			Time   time.Time                     `json:"time"`
+		}{
+			Path:   a.pathName,
+			Alert:  key.typ,
+			Track:  key.track,
+			Active: active,
+			Time:   now,
+		})
+		if err != nil {
+			a.parent.Log(logger.Warn, "runOnAlertWebhook failed: %v", err)
+		}
+	})
+}
+
+// onChange is called by formatprocessor.Analyzer.
+func (a *pathAlerts) onChange(forma formats.Format, typ formatprocessor.AnalyzerAlert, active bool) {
+	a.change(pathAlertKey{typ: typ, track: forma.String()}, active)
+}
+
+// onHealthScore is called by streamHealth.
+func (a *pathAlerts) onHealthScore(minScore int, score int) {
+	a.change(pathAlertKey{typ: pathAlertLowHealth}, score < minScore)
+}
+
+func (a *pathAlerts) change(key pathAlertKey, active bool) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	if a.closed {
+		return
+	}
+
+	al, ok := a.alerts[key]
+
+	switch {
+	case active && !ok:
+		al = &pathAlert{since: time.Now()}
+		a.alerts[key] = al
+
+		a.parent.Log(logger.Warn, "alert %s started", key)
+
+		a.onStart(a, key.typ, key.track)
+
+		a.callWebhook(key, true, al.since)
+
+		if a.runOnAlert != "" {
+			env := a.env()
+			env["RTSP_ALERT"] = string(key.typ)
+			env["RTSP_ALERT_TRACK"] = key.track
+
+			a.parent.Log(logger.Info, "runOnAlert command started")
+			al.cmd = externalcmd.NewCmdWithOptions(
+				a.externalCmdPool,
+				a.runOnAlert,
+				a.runOnAlertRestart,
+				env,
+				a.externalCmdOpts,
+				func(co int) {
+					a.parent.Log(logger.Info, "runOnAlert command exited with code %d", co)
+				})
+		}
+
+	case !active && ok:
+		a.stop(key, al)
+		delete(a.alerts, key)
+	}
+}
+
+func (a *pathAlerts) stop(key pathAlertKey, al *pathAlert) {
+	a.parent.Log(logger.Info, "alert %s ended", key)
+
+	if al.cmd != nil {
+		al.cmd.Close()
+		a.parent.Log(logger.Info, "runOnAlert command stopped")
+	}
+
+	a.callWebhook(key, false, time.Now())
+}
+
+func (a *pathAlerts) apiList() []pathAPIAlert {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	ret := []pathAPIAlert{}
+
+	for key, al := range a.alerts {
+		ret = append(ret, pathAPIAlert{
+			Type:  key.typ,
+			Track: key.track,
+			Since: al.since,
+		})
+	}
+
+	sort.Slice(ret, func(i, j int) bool {
+		return ret[i].Since.Before(ret[j].Since)
+	})
+
+	return ret
+}
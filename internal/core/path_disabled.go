@@ -0,0 +1,108 @@
+package core
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+type pathErrDisabled struct {
+	pathName string
+	message  string
+}
+
+// Error implements the error interface.
+func (e pathErrDisabled) Error() string {
+	if e.message != "" {
+		return fmt.Sprintf("path '%s' is disabled: %s", e.pathName, e.message)
+	}
+	return fmt.Sprintf("path '%s' is disabled", e.pathName)
+}
+
+type pathAPIDisabledItem struct {
+	Time    time.Time `json:"time"`
+	Message string    `json:"message"`
+}
+
+type pathAPIDisabledData struct {
+	Items map[string]pathAPIDisabledItem `json:"items"`
+}
+
+type pathAPIPathsDisableRes struct {
+	err error
+}
+
+type pathAPIPathsDisableReq struct {
+	name    string
+	disable bool
+	message string
+	drop    bool
+	res     chan pathAPIPathsDisableRes
+}
+
+// pathDisabled contains the paths that have been disabled with the API,
+// in order to take them offline without editing the configuration.
+// It is written by pathManager and read by the API, therefore it's protected by a mutex.
+type pathDisabled struct {
+	mutex sync.Mutex
+	items map[string]pathAPIDisabledItem
+}
+
+func newPathDisabled() *pathDisabled {
+	return &pathDisabled{
+		items: make(map[string]pathAPIDisabledItem),
+	}
+}
+
+func (d *pathDisabled) add(name string, message string) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.items[name] = pathAPIDisabledItem{
+		Time:    time.Now(),
+		Message: message,
+	}
+}
+
+func (d *pathDisabled) remove(name string) bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if _, ok := d.items[name]; !ok {
+		return false
+	}
+
+	delete(d.items, name)
+	return true
+}
+
+// check returns an error if the path is disabled.
+func (d *pathDisabled) check(name string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	item, ok := d.items[name]
+	if !ok {
+		return nil
+	}
+
+	return pathErrDisabled{
+		pathName: name,
+		message:  item.Message,
+	}
+}
+
+func (d *pathDisabled) list() *pathAPIDisabledData {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	data := &pathAPIDisabledData{
+		Items: make(map[string]pathAPIDisabledItem, len(d.items)),
+	}
+
+	for name, item := range d.items {
+		data.Items[name] = item
+	}
+
+	return data
+}
@@ -0,0 +1,86 @@
+package core
+
+import (
+	"strings"
+)
+
+// pathGroupOf returns the group of a path and the suffix of the path inside the group.
+// A path belongs to a group when its name is the name of the group followed by
+// one of the group suffixes (i.e. mystream_720p belongs to group mystream).
+func pathGroupOf(suffixes []string, name string) (string, string, bool) {
+	for _, suffix := range suffixes {
+		if len(name) > len(suffix) && strings.HasSuffix(name, suffix) &&
+			!strings.HasSuffix(name, "/"+suffix) {
+			return name[:len(name)-len(suffix)], suffix, true
+		}
+	}
+	return "", "", false
+}
+
+// pathGroupRenditions returns the paths of a group, sorted by the order of the suffixes.
+func pathGroupRenditions(suffixes []string, group string, names map[string]struct{}) []string {
+	var ret []string
+
+	for _, suffix := range suffixes {
+		if _, ok := names[group+suffix]; ok {
+			ret = append(ret, group+suffix)
+		}
+	}
+
+	return ret
+}
+
+type pathAPIPathGroupsListRendition struct {
+	Name          string   `json:"name"`
+	Suffix        string   `json:"suffix"`
+	SourceReady   bool     `json:"sourceReady"`
+	Tracks        []string `json:"tracks"`
+	BytesReceived uint64   `json:"bytesReceived"`
+	ReaderCount   int      `json:"readerCount"`
+}
+
+type pathAPIPathGroupsListItem struct {
+	Renditions []pathAPIPathGroupsListRendition `json:"renditions"`
+}
+
+type pathAPIPathGroupsListData struct {
+	Items map[string]pathAPIPathGroupsListItem `json:"items"`
+}
+
+// pathGroupsFromPaths groups the items of a path list.
+func pathGroupsFromPaths(suffixes []string, paths *pathAPIPathsListData) *pathAPIPathGroupsListData {
+	names := make(map[string]struct{})
+	groups := make(map[string]struct{})
+
+	for name := range paths.Items {
+		if group, _, ok := pathGroupOf(suffixes, name); ok {
+			names[name] = struct{}{}
+			groups[group] = struct{}{}
+		}
+	}
+
+	data := &pathAPIPathGroupsListData{
+		Items: make(map[string]pathAPIPathGroupsListItem),
+	}
+
+	for group := range groups {
+		var item pathAPIPathGroupsListItem
+
+		for _, name := range pathGroupRenditions(suffixes, group, names) {
+			pa := paths.Items[name]
+
+			item.Renditions = append(item.Renditions, pathAPIPathGroupsListRendition{
+				Name:          name,
+				Suffix:        name[len(group):],
+				SourceReady:   pa.SourceReady,
+				Tracks:        pa.Tracks,
+				BytesReceived: pa.BytesReceived,
+				ReaderCount:   pa.ReaderCount,
+			})
+		}
+
+		data.Items[group] = item
+	}
+
+	return data
+}
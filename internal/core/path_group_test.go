@@ -0,0 +1,105 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathGroupOf(t *testing.T) {
+	suffixes := []string{"_1080p", "_720p", "_480p"}
+
+	for _, ca := range []struct {
+		name   string
+		group  string
+		suffix string
+		ok     bool
+	}{
+		{"mystream_720p", "mystream", "_720p", true},
+		{"live/mystream_1080p", "live/mystream", "_1080p", true},
+		{"mystream", "", "", false},
+		{"_720p", "", "", false},
+		{"live/_720p", "", "", false},
+		{"mystream_720p_backup", "", "", false},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			group, suffix, ok := pathGroupOf(suffixes, ca.name)
+			require.Equal(t, ca.group, group)
+			require.Equal(t, ca.suffix, suffix)
+			require.Equal(t, ca.ok, ok)
+		})
+	}
+}
+
+func TestPathGroup(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"hlsVariant: mpegts\n" +
+		"hlsSegmentDuration: 500ms\n" +
+		"paths:\n" +
+		"  mystream_480p:\n" +
+		"    source: testsrc\n" +
+		"    testsrcWidth: 854\n" +
+		"    testsrcHeight: 480\n" +
+		"    testsrcBitrate: 500000\n" +
+		"  mystream_1080p:\n" +
+		"    source: testsrc\n" +
+		"    testsrcWidth: 1920\n" +
+		"    testsrcHeight: 1080\n" +
+		"    testsrcBitrate: 3000000\n" +
+		"  mystream_720p:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	t.Run("api", func(t *testing.T) {
+		res, err := http.Get("http://localhost:9997/v1/pathgroups/list")
+		require.NoError(t, err)
+		defer res.Body.Close()
+		require.Equal(t, http.StatusOK, res.StatusCode)
+
+		var out struct {
+			Items map[string]struct {
+				Renditions []struct {
+					Name        string `json:"name"`
+					Suffix      string `json:"suffix"`
+					SourceReady bool   `json:"sourceReady"`
+				} `json:"renditions"`
+			} `json:"items"`
+		}
+		err = json.NewDecoder(res.Body).Decode(&out)
+		require.NoError(t, err)
+
+		require.Equal(t, 1, len(out.Items))
+		renditions := out.Items["mystream"].Renditions
+		require.Equal(t, 3, len(renditions))
+		require.Equal(t, "mystream_1080p", renditions[0].Name)
+		require.Equal(t, "_1080p", renditions[0].Suffix)
+		require.Equal(t, true, renditions[0].SourceReady)
+		require.Equal(t, "mystream_720p", renditions[1].Name)
+		require.Equal(t, false, renditions[1].SourceReady)
+		require.Equal(t, "mystream_480p", renditions[2].Name)
+	})
+
+	t.Run("hls", func(t *testing.T) {
+		cnt, err := httpPullFile("http://localhost:8888/mystream/index.m3u8")
+		require.NoError(t, err)
+		require.Regexp(t, "^#EXTM3U\n"+
+			"#EXT-X-VERSION:3\n"+
+			"#EXT-X-INDEPENDENT-SEGMENTS\n"+
+			"\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=[0-9]+,AVERAGE-BANDWIDTH=[0-9]+,"+
+			"CODECS=\"avc1.42c029\",RESOLUTION=1920x1080,FRAME-RATE=30.000\n"+
+			"../mystream_1080p/stream.m3u8\n"+
+			"#EXT-X-STREAM-INF:BANDWIDTH=[0-9]+,AVERAGE-BANDWIDTH=[0-9]+,"+
+			"CODECS=\"avc1.42c01f\",RESOLUTION=854x480,FRAME-RATE=30.000\n"+
+			"../mystream_480p/stream.m3u8\n$", string(cnt))
+
+		cnt, err = httpPullFile("http://localhost:8888/mystream_480p/stream.m3u8")
+		require.NoError(t, err)
+		require.Contains(t, string(cnt), "#EXTM3U\n")
+	})
+}
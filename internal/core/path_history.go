@@ -0,0 +1,136 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// sessions that ended before this amount of time are discarded.
+	pathHistoryMaxAge = 24 * time.Hour
+
+	// maximum number of sessions that are kept for every path.
+	pathHistoryMaxCount = 256
+)
+
+type pathAPIHistoryItem struct {
+	Start    time.Time   `json:"start"`
+	Stop     *time.Time  `json:"stop"`
+	Duration float64     `json:"duration"`
+	Source   interface{} `json:"source"`
+	Reason   *string     `json:"reason"`
+}
+
+type pathAPIHistoryData struct {
+	Items []pathAPIHistoryItem `json:"items"`
+}
+
+type pathHistorySession struct {
+	start  time.Time
+	stop   time.Time
+	source interface{}
+	reason string
+}
+
+// pathHistory contains the publisher sessions of paths, that are the intervals in which
+// paths are ready. It is owned by pathManager in order to survive paths, and
+// it is called by the goroutines of paths and by the API, therefore it's protected by a mutex.
+type pathHistory struct {
+	mutex    sync.Mutex
+	sessions map[string][]*pathHistorySession
+}
+
+func newPathHistory() *pathHistory {
+	return &pathHistory{
+		sessions: make(map[string][]*pathHistorySession),
+	}
+}
+
+// start opens a session of a path.
+func (h *pathHistory) start(name string, source interface{}) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	sessions := h.prune(name, time.Now())
+
+	if len(sessions) >= pathHistoryMaxCount {
+		sessions = sessions[len(sessions)-pathHistoryMaxCount+1:]
+	}
+
+	h.sessions[name] = append(sessions, &pathHistorySession{
+		start:  time.Now(),
+		source: source,
+	})
+}
+
+// stop closes the current session of a path.
+func (h *pathHistory) stop(name string, reason string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	sessions := h.sessions[name]
+	if len(sessions) == 0 {
+		return
+	}
+
+	last := sessions[len(sessions)-1]
+	if !last.stop.IsZero() {
+		return
+	}
+
+	last.stop = time.Now()
+	last.reason = reason
+}
+
+// prune removes sessions that are too old.
+func (h *pathHistory) prune(name string, now time.Time) []*pathHistorySession {
+	sessions := h.sessions[name]
+
+	i := 0
+	for i < len(sessions) && !sessions[i].stop.IsZero() && now.Sub(sessions[i].stop) >= pathHistoryMaxAge {
+		i++
+	}
+	sessions = sessions[i:]
+
+	if len(sessions) == 0 {
+		delete(h.sessions, name)
+	} else {
+		h.sessions[name] = sessions
+	}
+
+	return sessions
+}
+
+// list returns the sessions of a path, from the oldest to the newest.
+func (h *pathHistory) list(name string) *pathAPIHistoryData {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	now := time.Now()
+	sessions := h.prune(name, now)
+
+	data := &pathAPIHistoryData{
+		Items: make([]pathAPIHistoryItem, len(sessions)),
+	}
+
+	for i, s := range sessions {
+		item := pathAPIHistoryItem{
+			Start:  s.start,
+			Source: s.source,
+		}
+
+		if s.stop.IsZero() {
+			item.Duration = now.Sub(s.start).Seconds()
+		} else {
+			stop := s.stop
+			reason := s.reason
+			item.Stop = &stop
+			item.Duration = stop.Sub(s.start).Seconds()
+			item.Reason = &reason
+		}
+
+		data.Items[i] = item
+	}
+
+	return data
+}
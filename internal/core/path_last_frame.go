@@ -0,0 +1,182 @@
+package core
+
+import (
+	"context"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+// interval between repetitions of the last key frame.
+const pathLastFramePeriod = 500 * time.Millisecond
+
+// pathLastFrameIsParameters checks whether a RTP packet contains parameters only.
+func pathLastFrameIsParameters(forma formats.Format, pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 1 {
+		return false
+	}
+
+	switch forma.(type) {
+	case *formats.H264:
+		switch h264.NALUType(pkt.Payload[0] & 0x1F) {
+		case h264.NALUTypeSPS, h264.NALUTypePPS, h264.NALUTypeSTAPA:
+			return true
+		}
+
+	case *formats.H265:
+		switch h265.NALUType((pkt.Payload[0] >> 1) & 0b111111) {
+		case h265.NALUType_VPS_NUT, h265.NALUType_SPS_NUT, h265.NALUType_PPS_NUT,
+			h265.NALUType_AggregationUnit:
+			return true
+		}
+	}
+
+	return false
+}
+
+type pathLastFrameTrack struct {
+	media    *media.Media
+	format   formats.Format
+	pkts     []*rtp.Packet
+	seq      uint16
+	ts       uint32
+	lastTime time.Time
+}
+
+// packets returns a copy of the key frame, with sequence numbers and timestamps
+// that follow the ones of the last routed packet.
+func (t *pathLastFrameTrack) packets(now time.Time, label string) []*rtp.Packet {
+	t.ts += uint32(now.Sub(t.lastTime).Seconds() * float64(t.format.ClockRate()))
+	t.lastTime = now
+
+	ret := make([]*rtp.Packet, 0, len(t.pkts)+1)
+
+	for i, pkt := range t.pkts {
+		if label != "" && i == len(ret) && !pathLastFrameIsParameters(t.format, pkt) {
+			ret = append(ret, &rtp.Packet{
+				Header: rtp.Header{
+					Version:     2,
+					PayloadType: pkt.PayloadType,
+					SSRC:        pkt.SSRC,
+				},
+				Payload: formatprocessor.SEITimestampNALU(t.format, now, label),
+			})
+		}
+
+		ret = append(ret, pkt.Clone())
+	}
+
+	for _, pkt := range ret {
+		t.seq++
+		pkt.SequenceNumber = t.seq
+		pkt.Timestamp = t.ts
+	}
+
+	return ret
+}
+
+// pathLastFrame repeats the last key frame of a stream after the publisher
+// has disconnected, in order to keep readers alive until it comes back.
+type pathLastFrame struct {
+	stream *stream
+	label  string
+	tracks []*pathLastFrameTrack
+
+	ctx       context.Context
+	ctxCancel func()
+
+	done chan struct{}
+}
+
+// newPathLastFrame starts repeating the last key frame of a stream.
+// It returns nil if the stream doesn't contain any key frame.
+func newPathLastFrame(parentCtx context.Context, stream *stream, label string) *pathLastFrame {
+	var tracks []*pathLastFrameTrack
+
+	for _, medi := range stream.medias() {
+		frames := stream.keyFrames()[medi]
+
+		for _, forma := range medi.Formats {
+			snap, ok := frames[forma]
+			if !ok {
+				continue
+			}
+
+			tracks = append(tracks, &pathLastFrameTrack{
+				media:    medi,
+				format:   forma,
+				pkts:     snap.pkts,
+				seq:      snap.lastSeq,
+				ts:       snap.lastTS,
+				lastTime: snap.lastTime,
+			})
+		}
+	}
+
+	if tracks == nil {
+		return nil
+	}
+
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	f := &pathLastFrame{
+		stream:    stream,
+		label:     label,
+		tracks:    tracks,
+		ctx:       ctx,
+		ctxCancel: ctxCancel,
+		done:      make(chan struct{}),
+	}
+
+	go f.run()
+
+	return f
+}
+
+func (f *pathLastFrame) close() {
+	f.ctxCancel()
+	<-f.done
+}
+
+func (f *pathLastFrame) run() {
+	defer close(f.done)
+
+	t := time.NewTicker(pathLastFramePeriod)
+	defer t.Stop()
+
+	for {
+		select {
+		case <-t.C:
+			now := time.Now()
+
+			for _, track := range f.tracks {
+				for _, pkt := range track.packets(now, f.label) {
+					f.stream.writeUnit(track.media, track.format, newPathLastFrameUnit(track.format, pkt, now))
+				}
+			}
+
+		case <-f.ctx.Done():
+			return
+		}
+	}
+}
+
+func newPathLastFrameUnit(forma formats.Format, pkt *rtp.Packet, now time.Time) formatprocessor.Unit {
+	if _, ok := forma.(*formats.H265); ok {
+		return &formatprocessor.UnitH265{
+			RTPPackets: []*rtp.Packet{pkt},
+			NTP:        now,
+		}
+	}
+
+	return &formatprocessor.UnitH264{
+		RTPPackets: []*rtp.Packet{pkt},
+		NTP:        now,
+	}
+}
@@ -0,0 +1,71 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPathLastFrameTrack(t *testing.T) {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+	}
+
+	lastTime := time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	track := &pathLastFrameTrack{
+		format: forma,
+		pkts: []*rtp.Packet{
+			{
+				Header: rtp.Header{
+					Version:        2,
+					PayloadType:    96,
+					SequenceNumber: 10,
+					Timestamp:      1000,
+					SSRC:           0x38F27A2F,
+				},
+				Payload: []byte{0x67, 0x01, 0x02}, // SPS
+			},
+			{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 11,
+					Timestamp:      1000,
+					SSRC:           0x38F27A2F,
+				},
+				Payload: []byte{0x65, 0x03, 0x04}, // IDR
+			},
+		},
+		seq:      20,
+		ts:       5000,
+		lastTime: lastTime,
+	}
+
+	pkts := track.packets(lastTime.Add(time.Second), "signal lost")
+	require.Equal(t, 3, len(pkts))
+
+	require.Equal(t, []byte{0x67, 0x01, 0x02}, pkts[0].Payload)
+	require.Equal(t, byte(6), pkts[1].Payload[0]&0x1F) // SEI
+	require.Equal(t, []byte{0x65, 0x03, 0x04}, pkts[2].Payload)
+
+	for i, pkt := range pkts {
+		require.Equal(t, uint16(21+i), pkt.SequenceNumber)
+		require.Equal(t, uint32(5000+90000), pkt.Timestamp)
+		require.Equal(t, uint32(0x38F27A2F), pkt.SSRC)
+		require.Equal(t, i == 2, pkt.Marker)
+	}
+
+	// stored packets are not modified.
+	require.Equal(t, uint16(11), track.pkts[1].SequenceNumber)
+
+	pkts = track.packets(lastTime.Add(1500*time.Millisecond), "")
+	require.Equal(t, 2, len(pkts))
+	require.Equal(t, uint16(24), pkts[0].SequenceNumber)
+	require.Equal(t, uint32(5000+90000+45000), pkts[0].Timestamp)
+}
@@ -3,8 +3,13 @@ package core
 import (
 	"context"
 	"fmt"
+	"strings"
 	"sync"
+	"time"
 
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
@@ -25,13 +30,39 @@ func pathConfCanBeUpdated(oldPathConf *conf.PathConf, newPathConf *conf.PathConf
 	copy.RPICameraGain = newPathConf.RPICameraGain
 	copy.RPICameraEV = newPathConf.RPICameraEV
 	copy.RPICameraFPS = newPathConf.RPICameraFPS
+	copy.ReaderGracePeriod = newPathConf.ReaderGracePeriod
+	copy.SourceParameters = newPathConf.SourceParameters
 
 	return newPathConf.Equal(copy)
 }
 
+// mergePathConfs merges the path configurations of the configuration file
+// with the ones provided by the cluster. The first ones take precedence.
+func mergePathConfs(
+	confPathConfs map[string]*conf.PathConf,
+	clusterPathConfs map[string]*conf.PathConf,
+) map[string]*conf.PathConf {
+	if len(clusterPathConfs) == 0 {
+		return confPathConfs
+	}
+
+	ret := make(map[string]*conf.PathConf)
+
+	for name, pathConf := range clusterPathConfs {
+		ret[name] = pathConf
+	}
+
+	for name, pathConf := range confPathConfs {
+		ret[name] = pathConf
+	}
+
+	return ret
+}
+
 type pathManagerHLSServer interface {
 	pathSourceReady(*path)
 	pathSourceNotReady(*path)
+	pathSourceReplaced(*path)
 }
 
 type pathManagerParent interface {
@@ -44,28 +75,46 @@ type pathManager struct {
 	writeTimeout      conf.StringDuration
 	readBufferCount   int
 	udpMaxPayloadSize int
+	confPathConfs     map[string]*conf.PathConf
+	clusterPathConfs  map[string]*conf.PathConf
 	pathConfs         map[string]*conf.PathConf
+	pathAliases       map[string]string
+	tenantQuotas      *tenantQuotas
+	publishPathRules  publishPathRules
 	externalCmdPool   *externalcmd.Pool
 	metrics           *metrics
+	events            *apiEvents
 	parent            pathManagerParent
 
-	ctx         context.Context
-	ctxCancel   func()
-	wg          sync.WaitGroup
-	hlsServer   pathManagerHLSServer
-	paths       map[string]*path
-	pathsByConf map[string]map[*path]struct{}
+	ctx                context.Context
+	ctxCancel          func()
+	wg                 sync.WaitGroup
+	hlsServer          pathManagerHLSServer
+	paths              map[string]*path
+	pathsByConf        map[string]map[*path]struct{}
+	history            *pathHistory
+	readerRejections   *pathReaderRejections
+	disabled           *pathDisabled
+	pending            *pathReadersPending
+	sourceStartLimiter *sourceStartLimiter
+	teesMutex          sync.Mutex
+	tees               map[*pathTee]struct{}
 
 	// in
 	chConfReload         chan map[string]*conf.PathConf
+	chClusterPathsSet    chan map[string]*conf.PathConf
 	chPathClose          chan *path
 	chPathSourceReady    chan *path
 	chPathSourceNotReady chan *path
+	chPathSourceReplaced chan *path
 	chDescribe           chan pathDescribeReq
 	chReaderAdd          chan pathReaderAddReq
 	chPublisherAdd       chan pathPublisherAddReq
 	chHLSServerSet       chan pathManagerHLSServer
 	chAPIPathsList       chan pathAPIPathsListReq
+	chAPIPathsCapture    chan pathAPIPathsCaptureReq
+	chAPIPathsPTZ        chan pathAPIPathsPTZReq
+	chAPIPathsDisable    chan pathAPIPathsDisableReq
 }
 
 func newPathManager(
@@ -76,8 +125,12 @@ func newPathManager(
 	readBufferCount int,
 	udpMaxPayloadSize int,
 	pathConfs map[string]*conf.PathConf,
+	tenants map[string]*conf.TenantConf,
+	publishPathRules conf.PublishPathRules,
+	sourceStartConcurrency int,
 	externalCmdPool *externalcmd.Pool,
 	metrics *metrics,
+	events *apiEvents,
 	parent pathManagerParent,
 ) *pathManager {
 	ctx, ctxCancel := context.WithCancel(parentCtx)
@@ -88,27 +141,44 @@ func newPathManager(
 		writeTimeout:         writeTimeout,
 		readBufferCount:      readBufferCount,
 		udpMaxPayloadSize:    udpMaxPayloadSize,
+		confPathConfs:        pathConfs,
 		pathConfs:            pathConfs,
+		pathAliases:          pathAliases(pathConfs),
+		tenantQuotas:         newTenantQuotas(tenants),
+		publishPathRules:     newPublishPathRules(publishPathRules),
 		externalCmdPool:      externalCmdPool,
 		metrics:              metrics,
+		events:               events,
 		parent:               parent,
 		ctx:                  ctx,
 		ctxCancel:            ctxCancel,
 		paths:                make(map[string]*path),
 		pathsByConf:          make(map[string]map[*path]struct{}),
+		history:              newPathHistory(),
+		readerRejections:     newPathReaderRejections(),
+		disabled:             newPathDisabled(),
+		tees:                 make(map[*pathTee]struct{}),
 		chConfReload:         make(chan map[string]*conf.PathConf),
+		chClusterPathsSet:    make(chan map[string]*conf.PathConf),
 		chPathClose:          make(chan *path),
 		chPathSourceReady:    make(chan *path),
 		chPathSourceNotReady: make(chan *path),
+		chPathSourceReplaced: make(chan *path),
 		chDescribe:           make(chan pathDescribeReq),
 		chReaderAdd:          make(chan pathReaderAddReq),
 		chPublisherAdd:       make(chan pathPublisherAddReq),
 		chHLSServerSet:       make(chan pathManagerHLSServer),
 		chAPIPathsList:       make(chan pathAPIPathsListReq),
+		chAPIPathsCapture:    make(chan pathAPIPathsCaptureReq),
+		chAPIPathsPTZ:        make(chan pathAPIPathsPTZReq),
+		chAPIPathsDisable:    make(chan pathAPIPathsDisableReq),
 	}
 
+	pm.pending = newPathReadersPending(pm)
+	pm.sourceStartLimiter = newSourceStartLimiter(sourceStartConcurrency, pm)
+
 	for pathConfName, pathConf := range pm.pathConfs {
-		if pathConf.Regexp == nil {
+		if _, ok := pm.pathAliases[pathConfName]; !ok && pathConf.Regexp == nil {
 			pm.createPath(pathConfName, pathConf, pathConfName, nil)
 		}
 	}
@@ -129,6 +199,7 @@ func (pm *pathManager) close() {
 	pm.Log(logger.Debug, "path manager is shutting down")
 	pm.ctxCancel()
 	pm.wg.Wait()
+	pm.pending.close()
 }
 
 // Log is the main logging function.
@@ -139,44 +210,25 @@ func (pm *pathManager) Log(level logger.Level, format string, args ...interface{
 func (pm *pathManager) run() {
 	defer pm.wg.Done()
 
+	var tenantBandwidthTickerC <-chan time.Time
+	if ticker := pm.tenantQuotas.ticker(); ticker != nil {
+		defer ticker.Stop()
+		tenantBandwidthTickerC = ticker.C
+	}
+
 outer:
 	for {
 		select {
-		case newPathConfs := <-pm.chConfReload:
-			for confName, pathConf := range pm.pathConfs {
-				if newPathConf, ok := newPathConfs[confName]; ok {
-					// configuration has changed
-					if !newPathConf.Equal(pathConf) {
-						if pathConfCanBeUpdated(pathConf, newPathConf) { // paths associated with the configuration can be updated
-							for pa := range pm.pathsByConf[confName] {
-								go pa.reloadConf(newPathConf)
-							}
-						} else { // paths associated with the configuration must be recreated
-							for pa := range pm.pathsByConf[confName] {
-								pm.removePath(pa)
-								pa.close()
-								pa.wait() // avoid conflicts between sources
-							}
-						}
-					}
-				} else {
-					// configuration has been deleted, remove associated paths
-					for pa := range pm.pathsByConf[confName] {
-						pm.removePath(pa)
-						pa.close()
-						pa.wait() // avoid conflicts between sources
-					}
-				}
-			}
+		case <-tenantBandwidthTickerC:
+			pm.tenantQuotas.measureBandwidth(pm.paths)
 
-			pm.pathConfs = newPathConfs
+		case newPathConfs := <-pm.chConfReload:
+			pm.confPathConfs = newPathConfs
+			pm.setPathConfs(mergePathConfs(pm.confPathConfs, pm.clusterPathConfs))
 
-			// add new paths
-			for pathConfName, pathConf := range pm.pathConfs {
-				if _, ok := pm.paths[pathConfName]; !ok && pathConf.Regexp == nil {
-					pm.createPath(pathConfName, pathConf, pathConfName, nil)
-				}
-			}
+		case newPathConfs := <-pm.chClusterPathsSet:
+			pm.clusterPathConfs = newPathConfs
+			pm.setPathConfs(mergePathConfs(pm.confPathConfs, pm.clusterPathConfs))
 
 		case pa := <-pm.chPathClose:
 			if pmpa, ok := pm.paths[pa.name]; !ok || pmpa != pa {
@@ -189,83 +241,206 @@ outer:
 				pm.hlsServer.pathSourceReady(pa)
 			}
 
+			pm.events.publish(apiEvent{
+				Type:          apiEventPathReady,
+				Time:          time.Now(),
+				Path:          pa.name,
+				bytesReceived: pa.bytesReceived,
+			})
+
 		case pa := <-pm.chPathSourceNotReady:
 			if pm.hlsServer != nil {
 				pm.hlsServer.pathSourceNotReady(pa)
 			}
 
+			pm.events.publish(apiEvent{
+				Type:          apiEventPathNotReady,
+				Time:          time.Now(),
+				Path:          pa.name,
+				bytesReceived: pa.bytesReceived,
+			})
+
+		case pa := <-pm.chPathSourceReplaced:
+			if pm.hlsServer != nil {
+				pm.hlsServer.pathSourceReplaced(pa)
+			}
+
 		case req := <-pm.chDescribe:
-			pathConfName, pathConf, pathMatches, err := pm.findPathConf(req.pathName)
+			pathName, authConf := pm.resolveAlias(req.pathName)
+
+			pathConfName, pathConf, pathMatches, err := pm.findPathConf(pathName)
 			if err != nil {
+				pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionNotConfigured, err.Error())
 				req.res <- pathDescribeRes{err: err}
 				continue
 			}
 
+			if authConf == nil {
+				authConf = pathConf
+			}
+
 			if req.authenticate != nil {
 				err = req.authenticate(
-					pathConf.ReadIPs,
-					pathConf.ReadUser,
-					pathConf.ReadPass)
+					authConf.ReadIPs,
+					authConf.ReadUser,
+					authConf.ReadPass,
+					authConf.Permissions)
 				if err != nil {
+					if terr, ok := err.(pathErrAuthCritical); ok {
+						pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionAuth, terr.message)
+					}
 					req.res <- pathDescribeRes{err: err}
 					continue
 				}
 			}
 
+			err = pm.disabled.check(pathName)
+			if err != nil {
+				pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionDisabled, err.Error())
+				req.res <- pathDescribeRes{err: err}
+				continue
+			}
+
 			// create path if it doesn't exist
-			if _, ok := pm.paths[req.pathName]; !ok {
-				pm.createPath(pathConfName, pathConf, req.pathName, pathMatches)
+			if _, ok := pm.paths[pathName]; !ok {
+				err = pm.tenantQuotas.checkPathCreate(pm.paths, pathName)
+				if err != nil {
+					pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionQuota, err.Error())
+					req.res <- pathDescribeRes{err: err}
+					continue
+				}
+
+				pm.createPath(pathConfName, pathConf, pathName, pathMatches)
 			}
 
-			req.res <- pathDescribeRes{path: pm.paths[req.pathName]}
+			req.res <- pathDescribeRes{path: pm.paths[pathName], aliasConf: pathAliasConf(authConf, pathConf)}
 
 		case req := <-pm.chReaderAdd:
-			pathConfName, pathConf, pathMatches, err := pm.findPathConf(req.pathName)
+			pathName, authConf := pm.resolveAlias(req.pathName)
+
+			pathConfName, pathConf, pathMatches, err := pm.findPathConf(pathName)
 			if err != nil {
+				pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionNotConfigured, err.Error())
 				req.res <- pathReaderSetupPlayRes{err: err}
 				continue
 			}
 
+			if authConf == nil {
+				authConf = pathConf
+			}
+
 			if req.authenticate != nil {
 				err = req.authenticate(
-					pathConf.ReadIPs,
-					pathConf.ReadUser,
-					pathConf.ReadPass)
+					authConf.ReadIPs,
+					authConf.ReadUser,
+					authConf.ReadPass,
+					authConf.Permissions)
 				if err != nil {
+					// non-critical errors are part of the regular authentication flow.
+					if terr, ok := err.(pathErrAuthCritical); ok {
+						pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionAuth, terr.message)
+					}
 					req.res <- pathReaderSetupPlayRes{err: err}
 					continue
 				}
 			}
 
+			err = pm.disabled.check(pathName)
+			if err != nil {
+				pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionDisabled, err.Error())
+				req.res <- pathReaderSetupPlayRes{err: err}
+				continue
+			}
+
+			err = pm.tenantQuotas.checkAdd(pm.paths, pathName, tenantQuotaReader)
+			if err != nil {
+				pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionQuota, err.Error())
+				req.res <- pathReaderSetupPlayRes{err: err}
+				continue
+			}
+
 			// create path if it doesn't exist
-			if _, ok := pm.paths[req.pathName]; !ok {
-				pm.createPath(pathConfName, pathConf, req.pathName, pathMatches)
+			if _, ok := pm.paths[pathName]; !ok {
+				err = pm.tenantQuotas.checkPathCreate(pm.paths, pathName)
+				if err != nil {
+					pm.readerRejections.add(req.pathName, req.protocol, pathReaderRejectionQuota, err.Error())
+					req.res <- pathReaderSetupPlayRes{err: err}
+					continue
+				}
+
+				pm.createPath(pathConfName, pathConf, pathName, pathMatches)
 			}
 
-			req.res <- pathReaderSetupPlayRes{path: pm.paths[req.pathName]}
+			req.res <- pathReaderSetupPlayRes{path: pm.paths[pathName], aliasConf: pathAliasConf(authConf, pathConf)}
 
 		case req := <-pm.chPublisherAdd:
-			pathConfName, pathConf, pathMatches, err := pm.findPathConf(req.pathName)
+			reqPathName, streamKey := req.pathName, ""
+			if req.streamKeyInPath {
+				reqPathName, streamKey = pm.splitStreamKey(req.pathName)
+			}
+
+			pathName, authConf := pm.resolveAlias(reqPathName)
+
+			pathConfName, pathConf, pathMatches, err := pm.findPathConf(pathName)
 			if err != nil {
 				req.res <- pathPublisherAnnounceRes{err: err}
 				continue
 			}
 
-			err = req.authenticate(
-				pathConf.PublishIPs,
-				pathConf.PublishUser,
-				pathConf.PublishPass)
+			if authConf == nil {
+				authConf = pathConf
+			}
+
+			if req.authenticate != nil {
+				var identity authIdentity
+				identity, err = req.authenticate(
+					authConf.PublishIPs,
+					authConf.PublishUser,
+					authConf.PublishPass,
+					authConf.Permissions)
+				if err != nil {
+					req.res <- pathPublisherAnnounceRes{err: err}
+					continue
+				}
+
+				if authConf.StreamKey != "" && !auth.CredentialMatches(authConf.StreamKey, streamKey) {
+					req.res <- pathPublisherAnnounceRes{err: pathErrAuthCritical{
+						message: "invalid stream key",
+					}}
+					continue
+				}
+
+				err = pm.publishPathRules.check(identity, pathName)
+				if err != nil {
+					req.res <- pathPublisherAnnounceRes{err: err}
+					continue
+				}
+			}
+
+			err = pm.disabled.check(pathName)
+			if err != nil {
+				req.res <- pathPublisherAnnounceRes{err: err}
+				continue
+			}
+
+			err = pm.tenantQuotas.checkAdd(pm.paths, pathName, tenantQuotaPublisher)
 			if err != nil {
 				req.res <- pathPublisherAnnounceRes{err: err}
 				continue
 			}
 
 			// create path if it doesn't exist
-			if _, ok := pm.paths[req.pathName]; !ok {
-				pm.createPath(pathConfName, pathConf, req.pathName, pathMatches)
+			if _, ok := pm.paths[pathName]; !ok {
+				err = pm.tenantQuotas.checkPathCreate(pm.paths, pathName)
+				if err != nil {
+					req.res <- pathPublisherAnnounceRes{err: err}
+					continue
+				}
+
+				pm.createPath(pathConfName, pathConf, pathName, pathMatches)
 			}
 
-			req.res <- pathPublisherAnnounceRes{path: pm.paths[req.pathName]}
+			req.res <- pathPublisherAnnounceRes{path: pm.paths[pathName]}
 
 		case s := <-pm.chHLSServerSet:
 			pm.hlsServer = s
@@ -281,6 +456,31 @@ outer:
 				paths: paths,
 			}
 
+		case req := <-pm.chAPIPathsCapture:
+			pathName, _ := pm.resolveAlias(req.name)
+
+			pa, ok := pm.paths[pathName]
+			if !ok {
+				req.res <- pathAPIPathsCaptureRes{err: fmt.Errorf("path '%s' not found", req.name)}
+				continue
+			}
+
+			req.res <- pathAPIPathsCaptureRes{path: pa}
+
+		case req := <-pm.chAPIPathsPTZ:
+			pathName, _ := pm.resolveAlias(req.name)
+
+			pa, ok := pm.paths[pathName]
+			if !ok {
+				req.res <- pathAPIPathsPTZRes{err: fmt.Errorf("path '%s' not found", req.name)}
+				continue
+			}
+
+			req.res <- pathAPIPathsPTZRes{path: pa}
+
+		case req := <-pm.chAPIPathsDisable:
+			req.res <- pathAPIPathsDisableRes{err: pm.setDisabled(req)}
+
 		case <-pm.ctx.Done():
 			break outer
 		}
@@ -293,6 +493,94 @@ outer:
 	}
 }
 
+func (pm *pathManager) setPathConfs(newPathConfs map[string]*conf.PathConf) {
+	newPathAliases := pathAliases(newPathConfs)
+
+	for confName, pathConf := range pm.pathConfs {
+		_, isAlias := newPathAliases[confName]
+
+		if newPathConf, ok := newPathConfs[confName]; ok && !isAlias {
+			// configuration has changed
+			if !newPathConf.Equal(pathConf) {
+				if pathConfCanBeUpdated(pathConf, newPathConf) { // paths associated with the configuration can be updated
+					for pa := range pm.pathsByConf[confName] {
+						go pa.reloadConf(newPathConf)
+					}
+				} else { // paths associated with the configuration must be recreated
+					for pa := range pm.pathsByConf[confName] {
+						pm.removePath(pa)
+						pa.close()
+						pa.wait() // avoid conflicts between sources
+					}
+				}
+			}
+		} else {
+			// configuration has been deleted or has become an alias, remove associated paths
+			for pa := range pm.pathsByConf[confName] {
+				pm.removePath(pa)
+				pa.close()
+				pa.wait() // avoid conflicts between sources
+			}
+		}
+	}
+
+	pm.pathConfs = newPathConfs
+	pm.pathAliases = newPathAliases
+
+	// add new paths
+	for pathConfName, pathConf := range pm.pathConfs {
+		if _, ok := pm.pathAliases[pathConfName]; ok {
+			continue
+		}
+
+		if _, ok := pm.paths[pathConfName]; !ok && pathConf.Regexp == nil &&
+			pm.disabled.check(pathConfName) == nil {
+			pm.createPath(pathConfName, pathConf, pathConfName, nil)
+		}
+	}
+}
+
+func (pm *pathManager) setDisabled(req pathAPIPathsDisableReq) error {
+	pathName, _ := pm.resolveAlias(req.name)
+
+	if !req.disable {
+		if !pm.disabled.remove(pathName) {
+			return fmt.Errorf("path '%s' is not disabled", req.name)
+		}
+
+		pm.Log(logger.Info, "path '%s' has been enabled", pathName)
+
+		// recreate the path if it has been closed and it has a static configuration
+		if pathConf, ok := pm.pathConfs[pathName]; ok && pathConf.Regexp == nil {
+			if _, ok := pm.pathAliases[pathName]; !ok {
+				if _, ok := pm.paths[pathName]; !ok {
+					pm.createPath(pathName, pathConf, pathName, nil)
+				}
+			}
+		}
+
+		return nil
+	}
+
+	if _, _, _, err := pm.findPathConf(pathName); err != nil {
+		return err
+	}
+
+	pm.disabled.add(pathName, req.message)
+
+	pm.Log(logger.Info, "path '%s' has been disabled", pathName)
+
+	if req.drop {
+		if pa, ok := pm.paths[pathName]; ok {
+			pm.removePath(pa)
+			pa.close()
+			pa.wait() // avoid conflicts between sources
+		}
+	}
+
+	return nil
+}
+
 func (pm *pathManager) createPath(
 	pathConfName string,
 	pathConf *conf.PathConf,
@@ -312,6 +600,7 @@ func (pm *pathManager) createPath(
 		matches,
 		&pm.wg,
 		pm.externalCmdPool,
+		pm.sourceStartLimiter,
 		pm)
 
 	pm.paths[name] = pa
@@ -330,6 +619,66 @@ func (pm *pathManager) removePath(pa *path) {
 	delete(pm.paths, pa.name)
 }
 
+// pathAliases returns a map that associates every path alias with the name of its path.
+func pathAliases(pathConfs map[string]*conf.PathConf) map[string]string {
+	ret := make(map[string]string)
+
+	for pathConfName, pathConf := range pathConfs {
+		for _, alias := range pathConf.Aliases {
+			ret[alias] = pathConfName
+		}
+	}
+
+	return ret
+}
+
+// resolveAlias returns the name of the path that is associated with the given name.
+// If the alias has its own configuration, this is returned too, since it contains
+// the authentication settings of the alias.
+func (pm *pathManager) resolveAlias(name string) (string, *conf.PathConf) {
+	target, ok := pm.pathAliases[name]
+	if !ok {
+		return name, nil
+	}
+
+	return target, pm.pathConfs[name]
+}
+
+// pathAliasConf returns the configuration used to authenticate a client
+// when it's different from the configuration of the path, that happens with aliases.
+func pathAliasConf(authConf *conf.PathConf, pathConf *conf.PathConf) *conf.PathConf {
+	if authConf == pathConf {
+		return nil
+	}
+	return authConf
+}
+
+// splitStreamKey splits a path name in the form "path/streamkey".
+// The name is split only when the resulting path has a stream key.
+func (pm *pathManager) splitStreamKey(name string) (string, string) {
+	i := strings.LastIndex(name, "/")
+	if i < 0 {
+		return name, ""
+	}
+
+	pathName, streamKey := name[:i], name[i+1:]
+
+	target, authConf := pm.resolveAlias(pathName)
+	if authConf == nil {
+		var err error
+		_, authConf, _, err = pm.findPathConf(target)
+		if err != nil {
+			return name, ""
+		}
+	}
+
+	if authConf.StreamKey == "" {
+		return name, ""
+	}
+
+	return pathName, streamKey
+}
+
 func (pm *pathManager) findPathConf(name string) (string, *conf.PathConf, []string, error) {
 	err := conf.IsValidPathName(name)
 	if err != nil {
@@ -362,6 +711,14 @@ func (pm *pathManager) confReload(pathConfs map[string]*conf.PathConf) {
 	}
 }
 
+// clusterPathsSet is called by clusterEdge.
+func (pm *pathManager) clusterPathsSet(pathConfs map[string]*conf.PathConf) {
+	select {
+	case pm.chClusterPathsSet <- pathConfs:
+	case <-pm.ctx.Done():
+	}
+}
+
 // pathSourceReady is called by path.
 func (pm *pathManager) pathSourceReady(pa *path) {
 	select {
@@ -380,6 +737,15 @@ func (pm *pathManager) pathSourceNotReady(pa *path) {
 	}
 }
 
+// pathSourceReplaced is called by path.
+func (pm *pathManager) pathSourceReplaced(pa *path) {
+	select {
+	case pm.chPathSourceReplaced <- pa:
+	case <-pm.ctx.Done():
+	case <-pa.ctx.Done(): // in case pathManager is blocked by path.wait()
+	}
+}
+
 // onPathClose is called by path.
 func (pm *pathManager) onPathClose(pa *path) {
 	select {
@@ -401,10 +767,12 @@ func (pm *pathManager) describe(req pathDescribeReq) pathDescribeRes {
 
 		res2 := res1.path.describe(req)
 		if res2.err != nil {
+			pm.readerRejectedByPath(req.pathName, req.protocol, res2.err)
 			return res2
 		}
 
 		res2.path = res1.path
+		res2.aliasConf = res1.aliasConf
 		return res2
 
 	case <-pm.ctx.Done():
@@ -439,13 +807,39 @@ func (pm *pathManager) readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes {
 			return res
 		}
 
-		return res.path.readerAdd(req)
+		aliasConf := res.aliasConf
+
+		res = res.path.readerAdd(req)
+		if res.err != nil {
+			pm.readerRejectedByPath(req.pathName, req.protocol, res.err)
+		}
+		res.aliasConf = aliasConf
+		return res
 
 	case <-pm.ctx.Done():
 		return pathReaderSetupPlayRes{err: fmt.Errorf("terminated")}
 	}
 }
 
+func (pm *pathManager) readerRejectedByPath(pathName string, protocol string, err error) {
+	if _, ok := err.(pathErrNoOnePublishing); ok {
+		pm.readerRejections.add(pathName, protocol, pathReaderRejectionNoPublisher, err.Error())
+	} else {
+		pm.readerRejections.add(pathName, protocol, pathReaderRejectionOther, err.Error())
+	}
+}
+
+// readerRejected is called by servers when a reader is rejected after being added to a path
+// or without contacting the path manager.
+func (pm *pathManager) readerRejected(
+	pathName string,
+	protocol string,
+	reason pathReaderRejectionReason,
+	message string,
+) {
+	pm.readerRejections.add(pathName, protocol, reason, message)
+}
+
 // hlsServerSet is called by hlsServer.
 func (pm *pathManager) hlsServerSet(s pathManagerHLSServer) {
 	select {
@@ -478,3 +872,171 @@ func (pm *pathManager) apiPathsList() pathAPIPathsListRes {
 		return pathAPIPathsListRes{err: fmt.Errorf("terminated")}
 	}
 }
+
+// apiPathsCapture is called by api.
+func (pm *pathManager) apiPathsCapture(req pathAPIPathsCaptureReq) pathAPIPathsCaptureRes {
+	req.res = make(chan pathAPIPathsCaptureRes)
+
+	select {
+	case pm.chAPIPathsCapture <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res
+		}
+
+		return res.path.apiPathsCapture(req)
+
+	case <-pm.ctx.Done():
+		return pathAPIPathsCaptureRes{err: fmt.Errorf("terminated")}
+	}
+}
+
+// apiPathsPTZ is called by api.
+func (pm *pathManager) apiPathsPTZ(name string) pathAPIPathsPTZRes {
+	req := pathAPIPathsPTZReq{
+		name: name,
+		res:  make(chan pathAPIPathsPTZRes),
+	}
+
+	select {
+	case pm.chAPIPathsPTZ <- req:
+		res := <-req.res
+		if res.err != nil {
+			return res
+		}
+
+		return res.path.apiPathsPTZ()
+
+	case <-pm.ctx.Done():
+		return pathAPIPathsPTZRes{err: fmt.Errorf("terminated")}
+	}
+}
+
+// apiEventPublish is called by path.
+func (pm *pathManager) apiEventPublish(ev apiEvent) {
+	pm.events.publish(ev)
+}
+
+// historyStart is called by path.
+func (pm *pathManager) historyStart(name string, source interface{}) {
+	pm.history.start(name, source)
+}
+
+// historyStop is called by path.
+func (pm *pathManager) historyStop(name string, reason string) {
+	pm.history.stop(name, reason)
+}
+
+// readersPendingAdd is called by path.
+func (pm *pathManager) readersPendingAdd(
+	name string,
+	stream *stream,
+	readers map[reader]struct{},
+	gracePeriod time.Duration,
+) bool {
+	if pm.ctx.Err() != nil {
+		return false
+	}
+	return pm.pending.add(name, stream, readers, gracePeriod)
+}
+
+// readersPendingTake is called by path.
+func (pm *pathManager) readersPendingTake(
+	pa *path,
+	medias media.Medias,
+	generateRTPPackets bool,
+	source source,
+) (*stream, map[reader]struct{}) {
+	return pm.pending.take(pa, medias, generateRTPPackets, source)
+}
+
+// readersPendingRemove is called by path.
+func (pm *pathManager) readersPendingRemove(pa *path, r reader) (bool, *path) {
+	return pm.pending.readerRemove(pa, r)
+}
+
+// readersPendingForget is called by path.
+func (pm *pathManager) readersPendingForget(r reader) {
+	pm.pending.readerForget(r)
+}
+
+// apiPathsHistory is called by api.
+func (pm *pathManager) apiPathsHistory(name string) *pathAPIHistoryData {
+	return pm.history.list(name)
+}
+
+// apiPathsDisable is called by api.
+func (pm *pathManager) apiPathsDisable(name string, disable bool, message string, drop bool) error {
+	req := pathAPIPathsDisableReq{
+		name:    name,
+		disable: disable,
+		message: message,
+		drop:    drop,
+		res:     make(chan pathAPIPathsDisableRes),
+	}
+
+	select {
+	case pm.chAPIPathsDisable <- req:
+		res := <-req.res
+		return res.err
+
+	case <-pm.ctx.Done():
+		return fmt.Errorf("terminated")
+	}
+}
+
+// apiPathsDisabled is called by api.
+func (pm *pathManager) apiPathsDisabled() *pathAPIDisabledData {
+	return pm.disabled.list()
+}
+
+// apiPathsReaderRejections is called by api.
+func (pm *pathManager) apiPathsReaderRejections(name string) *pathAPIReaderRejectionsData {
+	return pm.readerRejections.list(name)
+}
+
+// apiSourceStartProgress is called by api.
+func (pm *pathManager) apiSourceStartProgress() sourceStartProgressAPI {
+	return pm.sourceStartLimiter.apiProgress()
+}
+
+// apiPathsTee is called by api.
+func (pm *pathManager) apiPathsTee(name string, target string, duration time.Duration) pathAPIPathsTeeRes {
+	if pm.ctx.Err() != nil {
+		return pathAPIPathsTeeRes{err: fmt.Errorf("terminated")}
+	}
+
+	t, err := newPathTee(pm.ctx, name, target, duration, &pm.wg, pm, pm)
+	if err != nil {
+		return pathAPIPathsTeeRes{err: err}
+	}
+
+	pm.teesMutex.Lock()
+	pm.tees[t] = struct{}{}
+	pm.teesMutex.Unlock()
+
+	return pathAPIPathsTeeRes{id: t.uuid.String()}
+}
+
+// apiPathsTeeRemove is called by api.
+func (pm *pathManager) apiPathsTeeRemove(name string, target string) error {
+	pm.teesMutex.Lock()
+	defer pm.teesMutex.Unlock()
+
+	for t := range pm.tees {
+		if t.sourceName == name && t.targetName == target {
+			delete(pm.tees, t)
+			t.close()
+			return nil
+		}
+	}
+
+	return fmt.Errorf("tee not found")
+}
+
+// teeClose is called by pathTee.
+func (pm *pathManager) teeClose(t *pathTee) {
+	pm.teesMutex.Lock()
+	defer pm.teesMutex.Unlock()
+	delete(pm.tees, t)
+}
@@ -0,0 +1,238 @@
+package core
+
+import (
+	"context"
+	"net/http"
+	gourl "net/url"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/externalcmd"
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/onvif"
+)
+
+const (
+	pathMotionSubscriptionDuration = 60 * time.Second
+	pathMotionPullTimeout          = 10 * time.Second
+	pathMotionPullLimit            = 100
+	pathMotionRetryPause           = 5 * time.Second
+)
+
+// onvifServiceAddress returns the address and the credentials of an ONVIF service.
+// Credentials of the source URL are used when they are not in the address of the service.
+func onvifServiceAddress(address string, source string) (string, string, string, error) {
+	u, err := gourl.Parse(address)
+	if err != nil {
+		return "", "", "", err
+	}
+
+	user := u.User
+	if user == nil {
+		su, err := gourl.Parse(source)
+		if err == nil {
+			user = su.User
+		}
+	}
+	u.User = nil
+
+	if user == nil {
+		return u.String(), "", "", nil
+	}
+
+	pass, _ := user.Password()
+	return u.String(), user.Username(), pass, nil
+}
+
+// pathMotion receives motion events from the ONVIF event service of a device
+// and runs runOnMotion and runOnMotionWebhook accordingly.
+type pathMotion struct {
+	runOnMotion        string
+	runOnMotionRestart bool
+	runOnMotionWebhook string
+	externalCmdOpts    externalcmd.Options
+	externalCmdPool    *externalcmd.Pool
+	env                func() externalcmd.Environment
+	pathName           string
+	parent             logger.Writer
+
+	ctx        context.Context
+	ctxCancel  func()
+	client     *onvif.EventsClient
+	httpClient *http.Client
+	active     bool
+	cmd        *externalcmd.Cmd
+
+	done chan struct{}
+}
+
+func newPathMotion(
+	parentCtx context.Context,
+	address string,
+	source string,
+	readTimeout time.Duration,
+	runOnMotion string,
+	runOnMotionRestart bool,
+	runOnMotionWebhook string,
+	externalCmdOpts externalcmd.Options,
+	externalCmdPool *externalcmd.Pool,
+	env func() externalcmd.Environment,
+	pathName string,
+	parent logger.Writer,
+) (*pathMotion, error) {
+	ur, user, pass, err := onvifServiceAddress(address, source)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	m := &pathMotion{
+		runOnMotion:        runOnMotion,
+		runOnMotionRestart: runOnMotionRestart,
+		runOnMotionWebhook: runOnMotionWebhook,
+		externalCmdOpts:    externalCmdOpts,
+		externalCmdPool:    externalCmdPool,
+		env:                env,
+		pathName:           pathName,
+		parent:             parent,
+		ctx:                ctx,
+		ctxCancel:          ctxCancel,
+		client: &onvif.EventsClient{
+			URL:      ur,
+			Username: user,
+			Password: pass,
+			HTTPClient: &http.Client{
+				Timeout: readTimeout + pathMotionPullTimeout,
+			},
+		},
+		httpClient: &http.Client{
+			Timeout: readTimeout,
+		},
+		done: make(chan struct{}),
+	}
+
+	go m.run()
+
+	return m, nil
+}
+
+func (m *pathMotion) close() {
+	m.ctxCancel()
+	<-m.done
+}
+
+func (m *pathMotion) run() {
+	defer close(m.done)
+
+	for {
+		err := m.runInner()
+
+		// motion can't be tracked anymore.
+		m.setActive(false, "")
+
+		select {
+		case <-m.ctx.Done():
+			return
+		default:
+		}
+
+		m.parent.Log(logger.Warn, "unable to receive ONVIF events: %v", err)
+
+		select {
+		case <-time.After(pathMotionRetryPause):
+		case <-m.ctx.Done():
+			return
+		}
+	}
+}
+
+func (m *pathMotion) runInner() error {
+	err := m.client.Subscribe(m.ctx, pathMotionSubscriptionDuration)
+	if err != nil {
+		return err
+	}
+
+	defer m.client.Unsubscribe(context.Background()) //nolint:errcheck
+
+	m.parent.Log(logger.Debug, "subscribed to ONVIF events")
+
+	renewAt := time.Now().Add(pathMotionSubscriptionDuration / 2)
+
+	for {
+		evs, err := m.client.PullMessages(m.ctx, pathMotionPullTimeout, pathMotionPullLimit)
+		if err != nil {
+			return err
+		}
+
+		for _, ev := range evs {
+			if isMotion, active := ev.Motion(); isMotion {
+				m.setActive(active, ev.Topic)
+			}
+		}
+
+		if time.Now().After(renewAt) {
+			err := m.client.Renew(m.ctx, pathMotionSubscriptionDuration)
+			if err != nil {
+				return err
+			}
+
+			renewAt = time.Now().Add(pathMotionSubscriptionDuration / 2)
+		}
+	}
+}
+
+func (m *pathMotion) setActive(active bool, topic string) {
+	if active == m.active {
+		return
+	}
+	m.active = active
+
+	if active {
+		m.parent.Log(logger.Info, "motion started (%s)", topic)
+
+		if m.runOnMotion != "" {
+			env := m.env()
+			env["RTSP_MOTION_TOPIC"] = topic
+
+			m.parent.Log(logger.Info, "runOnMotion command started")
+			m.cmd = externalcmd.NewCmdWithOptions(
+				m.externalCmdPool,
+				m.runOnMotion,
+				m.runOnMotionRestart,
+				env,
+				m.externalCmdOpts,
+				func(co int) {
+					m.parent.Log(logger.Info, "runOnMotion command exited with code %d", co)
+				})
+		}
+	} else {
+		m.parent.Log(logger.Info, "motion ended")
+
+		if m.cmd != nil {
+			m.cmd.Close()
+			m.cmd = nil
+			m.parent.Log(logger.Info, "runOnMotion command stopped")
+		}
+	}
+
+	if m.runOnMotionWebhook != "" {
+		err := m.callWebhook(active, topic)
+		if err != nil {
+			m.parent.Log(logger.Warn, "runOnMotionWebhook failed: %v", err)
+		}
+	}
+}
+
+func (m *pathMotion) callWebhook(active bool, topic string) error {
+	return webhookPost(m.httpClient, m.runOnMotionWebhook, struct {
+		Path   string    `json:"path"`
+		Motion bool      `json:"motion"`
+		Topic  string    `json:"topic"`
+		Time   time.Time `json:"time"`
+	}{
+		Path:   m.pathName,
+		Motion: active,
+		Topic:  topic,
+		Time:   time.Now(),
+	})
+}
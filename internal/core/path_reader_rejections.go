@@ -0,0 +1,182 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// rejections are counted in buckets of this duration.
+	pathReaderRejectionsBucketDuration = time.Minute
+
+	// rejections that happened before this amount of time are discarded.
+	pathReaderRejectionsWindow = time.Hour
+
+	// maximum number of paths whose rejections are kept.
+	// When it's exceeded, the path with the oldest rejection is discarded.
+	pathReaderRejectionsMaxPaths = 1024
+)
+
+type pathReaderRejectionReason string
+
+const (
+	pathReaderRejectionAuth              pathReaderRejectionReason = "auth"
+	pathReaderRejectionNotConfigured     pathReaderRejectionReason = "notConfigured"
+	pathReaderRejectionQuota             pathReaderRejectionReason = "quota"
+	pathReaderRejectionNoPublisher       pathReaderRejectionReason = "noPublisher"
+	pathReaderRejectionDisabled          pathReaderRejectionReason = "disabled"
+	pathReaderRejectionCodecUnsupported  pathReaderRejectionReason = "codecUnsupported"
+	pathReaderRejectionTransportDisabled pathReaderRejectionReason = "transportDisabled"
+	pathReaderRejectionOther             pathReaderRejectionReason = "other"
+)
+
+type pathAPIReaderRejectionsLast struct {
+	Time     time.Time                 `json:"time"`
+	Protocol string                    `json:"protocol"`
+	Reason   pathReaderRejectionReason `json:"reason"`
+	Message  string                    `json:"message"`
+}
+
+type pathAPIReaderRejectionsData struct {
+	// duration of the period in which rejections are counted, in seconds.
+	Window    float64                                         `json:"window"`
+	Total     uint64                                          `json:"total"`
+	Reasons   map[pathReaderRejectionReason]uint64            `json:"reasons"`
+	Protocols map[string]map[pathReaderRejectionReason]uint64 `json:"protocols"`
+	Last      *pathAPIReaderRejectionsLast                    `json:"last"`
+}
+
+type pathReaderRejectionsKey struct {
+	protocol string
+	reason   pathReaderRejectionReason
+}
+
+type pathReaderRejectionsBucket struct {
+	start  time.Time
+	counts map[pathReaderRejectionsKey]uint64
+}
+
+type pathReaderRejectionsEntry struct {
+	buckets []*pathReaderRejectionsBucket
+	last    pathAPIReaderRejectionsLast
+}
+
+// prune removes buckets that are outside the window.
+func (e *pathReaderRejectionsEntry) prune(now time.Time) {
+	i := 0
+	for i < len(e.buckets) && now.Sub(e.buckets[i].start) >= pathReaderRejectionsWindow {
+		i++
+	}
+	e.buckets = e.buckets[i:]
+}
+
+// pathReaderRejections counts the reader attempts that were rejected, for every path,
+// in order to allow to diagnose readers that are unable to read without enabling debug logs.
+// It is owned by pathManager in order to count rejections of paths that don't exist,
+// and it is called by the goroutines of servers and by the API, therefore it's protected by a mutex.
+type pathReaderRejections struct {
+	mutex   sync.Mutex
+	entries map[string]*pathReaderRejectionsEntry
+}
+
+func newPathReaderRejections() *pathReaderRejections {
+	return &pathReaderRejections{
+		entries: make(map[string]*pathReaderRejectionsEntry),
+	}
+}
+
+// add counts a rejection.
+func (r *pathReaderRejections) add(
+	name string,
+	protocol string,
+	reason pathReaderRejectionReason,
+	message string,
+) {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	now := time.Now()
+
+	e, ok := r.entries[name]
+	if !ok {
+		if len(r.entries) >= pathReaderRejectionsMaxPaths {
+			r.removeOldest()
+		}
+
+		e = &pathReaderRejectionsEntry{}
+		r.entries[name] = e
+	}
+
+	e.prune(now)
+
+	if len(e.buckets) == 0 || now.Sub(e.buckets[len(e.buckets)-1].start) >= pathReaderRejectionsBucketDuration {
+		e.buckets = append(e.buckets, &pathReaderRejectionsBucket{
+			start:  now,
+			counts: make(map[pathReaderRejectionsKey]uint64),
+		})
+	}
+
+	e.buckets[len(e.buckets)-1].counts[pathReaderRejectionsKey{protocol, reason}]++
+
+	e.last = pathAPIReaderRejectionsLast{
+		Time:     now,
+		Protocol: protocol,
+		Reason:   reason,
+		Message:  message,
+	}
+}
+
+func (r *pathReaderRejections) removeOldest() {
+	var oldestName string
+	var oldest time.Time
+
+	for name, e := range r.entries {
+		if oldestName == "" || e.last.Time.Before(oldest) {
+			oldestName = name
+			oldest = e.last.Time
+		}
+	}
+
+	delete(r.entries, oldestName)
+}
+
+// list returns the rejections of a path.
+func (r *pathReaderRejections) list(name string) *pathAPIReaderRejectionsData {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	data := &pathAPIReaderRejectionsData{
+		Window:    pathReaderRejectionsWindow.Seconds(),
+		Reasons:   make(map[pathReaderRejectionReason]uint64),
+		Protocols: make(map[string]map[pathReaderRejectionReason]uint64),
+	}
+
+	e, ok := r.entries[name]
+	if !ok {
+		return data
+	}
+
+	e.prune(time.Now())
+
+	if len(e.buckets) == 0 {
+		delete(r.entries, name)
+		return data
+	}
+
+	for _, b := range e.buckets {
+		for key, count := range b.counts {
+			data.Total += count
+			data.Reasons[key.reason] += count
+
+			if _, ok := data.Protocols[key.protocol]; !ok {
+				data.Protocols[key.protocol] = make(map[pathReaderRejectionReason]uint64)
+			}
+			data.Protocols[key.protocol][key.reason] += count
+		}
+	}
+
+	last := e.last
+	data.Last = &last
+
+	return data
+}
@@ -0,0 +1,187 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type pathReadersPendingEntry struct {
+	stream  *stream
+	readers map[reader]struct{}
+	timer   *time.Timer
+}
+
+// pathReadersPending contains readers of paths whose source is gone, that are kept
+// for a grace period and reattached to the path with the same name when its source
+// comes back. It is owned by pathManager in order to survive paths, and
+// it is called by the goroutines of paths and readers, therefore it's protected by a mutex.
+type pathReadersPending struct {
+	parent logger.Writer
+
+	mutex   sync.Mutex
+	closed  bool
+	entries map[string]*pathReadersPendingEntry
+
+	// path of every pending reader.
+	pending map[reader]string
+
+	// paths that adopted readers of other paths.
+	// Readers keep calling the path they were reading,
+	// therefore their requests are forwarded to the new one.
+	owners map[reader]*path
+}
+
+func newPathReadersPending(parent logger.Writer) *pathReadersPending {
+	return &pathReadersPending{
+		parent:  parent,
+		entries: make(map[string]*pathReadersPendingEntry),
+		pending: make(map[reader]string),
+		owners:  make(map[reader]*path),
+	}
+}
+
+// close closes all pending readers.
+func (p *pathReadersPending) close() {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.closed = true
+
+	for name, entry := range p.entries {
+		p.doExpire(name, entry)
+	}
+}
+
+// add makes the readers of a path pending, together with the stream they are reading.
+// It returns false if readers can't be kept.
+func (p *pathReadersPending) add(
+	name string,
+	stream *stream,
+	readers map[reader]struct{},
+	gracePeriod time.Duration,
+) bool {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.closed {
+		return false
+	}
+
+	if entry, ok := p.entries[name]; ok {
+		p.doExpire(name, entry)
+	}
+
+	entry := &pathReadersPendingEntry{
+		stream:  stream,
+		readers: readers,
+	}
+
+	for r := range readers {
+		p.pending[r] = name
+		delete(p.owners, r)
+	}
+
+	entry.timer = time.AfterFunc(gracePeriod, func() {
+		p.mutex.Lock()
+		defer p.mutex.Unlock()
+
+		if p.entries[name] == entry {
+			p.parent.Log(logger.Info, "[path %s] source didn't come back, closing pending readers", name)
+			p.doExpire(name, entry)
+		}
+	})
+
+	p.entries[name] = entry
+
+	return true
+}
+
+// take reattaches the pending readers of a path to a new stream.
+// Readers are returned only if the new stream is compatible with the previous one,
+// otherwise they are closed.
+func (p *pathReadersPending) take(
+	pa *path,
+	medias media.Medias,
+	generateRTPPackets bool,
+	source source,
+) (*stream, map[reader]struct{}) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	entry, ok := p.entries[pa.name]
+	if !ok {
+		return nil, nil
+	}
+
+	entry.timer.Stop()
+	delete(p.entries, pa.name)
+
+	stream, ok := entry.stream.adopt(medias, generateRTPPackets, source, pa.bytesReceived)
+	if !ok {
+		pa.Log(logger.Info, "source came back with different tracks, closing pending readers")
+		p.doExpire(pa.name, entry)
+		return nil, nil
+	}
+
+	for r := range entry.readers {
+		delete(p.pending, r)
+		p.owners[r] = pa
+	}
+
+	return stream, entry.readers
+}
+
+// readerRemove is called when a reader asks a path to remove it.
+// It returns true if the reader is pending or has been adopted by another path,
+// together with the adopting path, that must handle the request.
+func (p *pathReadersPending) readerRemove(pa *path, r reader) (bool, *path) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if name, ok := p.pending[r]; ok {
+		delete(p.pending, r)
+
+		entry := p.entries[name]
+		delete(entry.readers, r)
+
+		if len(entry.readers) == 0 {
+			entry.timer.Stop()
+			delete(p.entries, name)
+			entry.stream.close()
+		}
+
+		return true, nil
+	}
+
+	owner, ok := p.owners[r]
+	if !ok || owner == pa {
+		return false, nil
+	}
+
+	delete(p.owners, r)
+	return true, owner
+}
+
+// readerForget is called when a path removes a reader.
+func (p *pathReadersPending) readerForget(r reader) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	delete(p.owners, r)
+}
+
+func (p *pathReadersPending) doExpire(name string, entry *pathReadersPendingEntry) {
+	entry.timer.Stop()
+	delete(p.entries, name)
+
+	for r := range entry.readers {
+		delete(p.pending, r)
+		r.close()
+	}
+
+	entry.stream.close()
+}
@@ -0,0 +1,137 @@
+package core
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type pathSCTE35Parent interface {
+	logger.Writer
+	apiEventPublish(apiEvent)
+}
+
+// pathSCTE35 receives SCTE-35 cues from the source of a path,
+// publishes them as events and sends them to runOnSCTE35Webhook.
+type pathSCTE35 struct {
+	runOnSCTE35Webhook string
+	pathName           string
+	stream             *stream
+	parent             pathSCTE35Parent
+
+	ringBuffer *ringbuffer.RingBuffer
+	httpClient *http.Client
+
+	done chan struct{}
+}
+
+// newPathSCTE35 starts receiving SCTE-35 cues from a stream.
+// It returns nil if the stream doesn't contain any SCTE-35 track.
+func newPathSCTE35(
+	readBufferCount int,
+	readTimeout time.Duration,
+	runOnSCTE35Webhook string,
+	pathName string,
+	stream *stream,
+	parent pathSCTE35Parent,
+) *pathSCTE35 {
+	medi, forma := scte35FindFormat(stream.medias())
+	if forma == nil {
+		return nil
+	}
+
+	ringBuffer, _ := ringbuffer.New(uint64(readBufferCount))
+
+	s := &pathSCTE35{
+		runOnSCTE35Webhook: runOnSCTE35Webhook,
+		pathName:           pathName,
+		stream:             stream,
+		parent:             parent,
+		ringBuffer:         ringBuffer,
+		httpClient: &http.Client{
+			Timeout: readTimeout,
+		},
+		done: make(chan struct{}),
+	}
+
+	stream.readerAdd(s, medi, forma, func(unit formatprocessor.Unit) {
+		s.ringBuffer.Push(func() {
+			cue, _, err := scte35UnitCue(unit)
+			if err != nil {
+				s.parent.Log(logger.Warn, "unable to decode SCTE-35 cue: %v", err)
+				return
+			}
+
+			s.onCue(cue)
+		})
+	})
+
+	go s.run()
+
+	return s
+}
+
+func (s *pathSCTE35) close() {
+	s.stream.readerRemove(s)
+	s.ringBuffer.Close()
+	<-s.done
+}
+
+func (s *pathSCTE35) run() {
+	defer close(s.done)
+
+	for {
+		item, ok := s.ringBuffer.Pull()
+		if !ok {
+			return
+		}
+		item.(func())()
+	}
+}
+
+func (s *pathSCTE35) onCue(cue *scte35Cue) {
+	if cue.Type != "" {
+		s.parent.Log(logger.Info, "SCTE-35 cue received (%s, %s)", cue.Command, cue.Type)
+	} else {
+		s.parent.Log(logger.Debug, "SCTE-35 cue received (%s)", cue.Command)
+	}
+
+	now := time.Now()
+
+	s.parent.apiEventPublish(apiEvent{
+		Type:   apiEventSCTE35,
+		Time:   now,
+		Path:   s.pathName,
+		SCTE35: cue,
+	})
+
+	if s.runOnSCTE35Webhook != "" {
+		err := s.callWebhook(cue, now)
+		if err != nil {
+			s.parent.Log(logger.Warn, "runOnSCTE35Webhook failed: %v", err)
+		}
+	}
+}
+
+func (s *pathSCTE35) callWebhook(cue *scte35Cue, now time.Time) error {
+	return webhookPost(s.httpClient, s.runOnSCTE35Webhook, struct {
+		Path   string     `json:"path"`
+		Time   time.Time  `json:"time"`
+		SCTE35 *scte35Cue `json:"scte35"`
+	}{
+		Path:   s.pathName,
+		Time:   now,
+		SCTE35: cue,
+	})
+}
+
+// apiReaderDescribe implements reader.
+func (s *pathSCTE35) apiReaderDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"scte35Receiver"}
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type pathTeeParent interface {
+	logger.Writer
+	teeClose(t *pathTee)
+}
+
+// pathTee reads the stream of a path and republishes it under another path.
+// The target path keeps its own configuration and credentials, therefore
+// the copy can be shared without exposing the credentials of the source path.
+type pathTee struct {
+	sourceName string
+	targetName string
+	wg         *sync.WaitGroup
+	parent     pathTeeParent
+
+	ctx          context.Context
+	ctxCancel    func()
+	uuid         uuid.UUID
+	sourcePath   *path
+	sourceStream *stream
+	targetPath   *path
+}
+
+func newPathTee(
+	parentCtx context.Context,
+	sourceName string,
+	targetName string,
+	duration time.Duration,
+	wg *sync.WaitGroup,
+	pathManager *pathManager,
+	parent pathTeeParent,
+) (*pathTee, error) {
+	if sourceName == targetName {
+		return nil, fmt.Errorf("source and target paths must be different")
+	}
+
+	var ctx context.Context
+	var ctxCancel func()
+	if duration > 0 {
+		ctx, ctxCancel = context.WithTimeout(parentCtx, duration)
+	} else {
+		ctx, ctxCancel = context.WithCancel(parentCtx)
+	}
+
+	t := &pathTee{
+		sourceName: sourceName,
+		targetName: targetName,
+		wg:         wg,
+		parent:     parent,
+		ctx:        ctx,
+		ctxCancel:  ctxCancel,
+		uuid:       uuid.New(),
+	}
+
+	rres := pathManager.readerAdd(pathReaderAddReq{
+		author:   t,
+		pathName: sourceName,
+		protocol: "tee",
+	})
+	if rres.err != nil {
+		ctxCancel()
+		return nil, rres.err
+	}
+
+	t.sourcePath = rres.path
+	t.sourceStream = rres.stream
+
+	pres := pathManager.publisherAdd(pathPublisherAddReq{
+		author:   t,
+		pathName: targetName,
+	})
+	if pres.err != nil {
+		rres.path.readerRemove(pathReaderRemoveReq{author: t})
+		ctxCancel()
+		return nil, pres.err
+	}
+
+	medias := rres.stream.medias()
+
+	sres := pres.path.publisherStart(pathPublisherStartReq{
+		author: t,
+		medias: medias,
+	})
+	if sres.err != nil {
+		pres.path.publisherRemove(pathPublisherRemoveReq{author: t})
+		rres.path.readerRemove(pathReaderRemoveReq{author: t})
+		ctxCancel()
+		return nil, sres.err
+	}
+
+	t.targetPath = pres.path
+
+	for _, medi := range medias {
+		for _, forma := range medi.Formats {
+			writeFunc := getRTSPWriteFunc(medi, forma, sres.stream)
+
+			rres.stream.readerAdd(t, medi, forma, func(unit formatprocessor.Unit) {
+				// packets are routed to the readers of the source path too,
+				// therefore they must be copied before being processed again.
+				for _, pkt := range unit.GetRTPPackets() {
+					pktCopy := *pkt
+					pktCopy.Payload = append([]byte(nil), pkt.Payload...)
+					writeFunc(&pktCopy)
+				}
+			})
+		}
+	}
+
+	t.Log(logger.Info, "is copying path '%s' to path '%s', %s",
+		sourceName, targetName, sourceMediaInfo(medias))
+
+	t.wg.Add(1)
+	go t.run()
+
+	return t, nil
+}
+
+// close implements reader and publisher.
+func (t *pathTee) close() {
+	t.ctxCancel()
+}
+
+// Log is the main logging function.
+func (t *pathTee) Log(level logger.Level, format string, args ...interface{}) {
+	t.parent.Log(level, "[tee %v] "+format, append([]interface{}{t.uuid}, args...)...)
+}
+
+func (t *pathTee) run() {
+	defer t.wg.Done()
+
+	<-t.ctx.Done()
+
+	t.sourceStream.readerRemove(t)
+	t.sourcePath.readerRemove(pathReaderRemoveReq{author: t})
+	t.targetPath.publisherRemove(pathPublisherRemoveReq{author: t})
+
+	t.parent.teeClose(t)
+
+	t.Log(logger.Info, "closed")
+}
+
+// apiReaderDescribe implements reader.
+func (t *pathTee) apiReaderDescribe() interface{} {
+	return t.apiSourceDescribe()
+}
+
+// apiSourceDescribe implements source.
+func (t *pathTee) apiSourceDescribe() interface{} {
+	return struct {
+		Type   string `json:"type"`
+		ID     string `json:"id"`
+		Source string `json:"source"`
+		Target string `json:"target"`
+	}{"tee", t.uuid.String(), t.sourceName, t.targetName}
+}
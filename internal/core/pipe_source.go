@@ -0,0 +1,117 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/asticode/go-astits"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type pipeSourceParent interface {
+	logger.Writer
+	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
+	sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq)
+}
+
+type pipeSource struct {
+	readTimeout conf.StringDuration
+	parent      pipeSourceParent
+}
+
+func newPipeSource(
+	readTimeout conf.StringDuration,
+	parent pipeSourceParent,
+) *pipeSource {
+	return &pipeSource{
+		readTimeout: readTimeout,
+		parent:      parent,
+	}
+}
+
+func (s *pipeSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[pipe source] "+format, args...)
+}
+
+func openPipe(source string) (*os.File, error) {
+	if source == "stdin" {
+		// open a new descriptor instead of using os.Stdin, in order to be able to
+		// interrupt reads by closing the file.
+		return os.OpenFile("/dev/stdin", os.O_RDONLY, 0)
+	}
+
+	// a named pipe is opened in read-write mode, in order to avoid
+	// blocking until a writer is available, and to avoid receiving EOF when the writer exits.
+	return os.OpenFile(source[len("pipe://"):], os.O_RDWR, 0)
+}
+
+// run implements sourceStaticImpl.
+func (s *pipeSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
+	s.Log(logger.Debug, "connecting")
+
+	f, err := openPipe(cnf.Source)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dem := astits.NewDemuxer(
+		context.Background(),
+		f,
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+	readerErr := make(chan error)
+
+	go func() {
+		readerErr <- func() error {
+			// deadlines are not supported by regular files, that do not need them anyway.
+			f.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
+			tracks, err := mpegtsFindTracks(dem)
+			if err != nil {
+				return err
+			}
+
+			medias, writers := mpegtsMedias(tracks, s)
+
+			res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+				medias:             medias,
+				generateRTPPackets: true,
+			})
+			if res.err != nil {
+				return res.err
+			}
+
+			defer func() {
+				s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+			}()
+
+			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
+
+			return mpegtsReadData(dem, res.stream, writers, func() {
+				f.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
+			})
+		}()
+	}()
+
+	select {
+	case err := <-readerErr:
+		return err
+
+	case <-ctx.Done():
+		f.Close()
+		<-readerErr
+		return fmt.Errorf("terminated")
+	}
+}
+
+// apiSourceDescribe implements sourceStaticImpl.
+func (*pipeSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"pipeSource"}
+}
@@ -0,0 +1,87 @@
+package core
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPipeSource(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-pipe")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fifoPath := filepath.Join(dir, "stream.ts")
+
+	err = exec.Command("mkfifo", fifoPath).Run()
+	if err != nil {
+		t.Skip("mkfifo is not available")
+	}
+
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  proxied:\n" +
+		"    source: pipe://" + fifoPath + "\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	f, err := os.OpenFile(fifoPath, os.O_WRONLY, 0)
+	require.NoError(t, err)
+	defer f.Close()
+
+	mux := astits.NewMuxer(context.Background(), f)
+
+	mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 256,
+		StreamType:    astits.StreamTypeH264Video,
+	})
+
+	mux.SetPCRPID(256)
+
+	_, err = mux.WriteTables()
+	require.NoError(t, err)
+
+	_, err = mux.WriteData(&astits.MuxerData{
+		PID: 256,
+		PES: &astits.PESData{
+			Header: &astits.PESHeader{
+				OptionalHeader: &astits.PESOptionalHeader{
+					MarkerBits:      2,
+					PTSDTSIndicator: astits.PTSDTSIndicatorOnlyPTS,
+					PTS:             &astits.ClockReference{Base: int64(1 * 90000)},
+				},
+				StreamID: 224, // video
+			},
+			Data: []byte{
+				0, 0, 0, 1, 5, // IDR
+			},
+		},
+	})
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	u, err := url.Parse("rtsp://127.0.0.1:8554/proxied")
+	require.NoError(t, err)
+
+	c := gortsplib.Client{}
+	err = c.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer c.Close()
+
+	medias, _, _, err := c.Describe(u)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(medias))
+	require.IsType(t, &formats.H264{}, medias[0].Formats[0])
+}
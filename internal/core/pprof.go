@@ -5,15 +5,37 @@ import (
 	"log"
 	"net"
 	"net/http"
+	"runtime"
+	"runtime/debug"
+	"runtime/trace"
+	"strconv"
+	"sync"
 	"time"
 
 	// start pprof
 	_ "net/http/pprof"
 
+	"github.com/gin-gonic/gin"
+
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+// maximum duration of a trace captured through /debug/runtime/trace,
+// and of CPU profiles and traces captured through /debug/pprof.
+const pprofMaxTraceSeconds = 60
+
+type pprofRuntimeState struct {
+	GOMAXPROCS   int `json:"gomaxprocs"`
+	GCPercent    int `json:"gcPercent"`
+	NumGoroutine int `json:"numGoroutine"`
+}
+
+type pprofRuntimeSetReq struct {
+	GOMAXPROCS *int `json:"gomaxprocs"`
+	GCPercent  *int `json:"gcPercent"`
+}
+
 type pprofParent interface {
 	logger.Writer
 }
@@ -23,14 +45,18 @@ type pprof struct {
 
 	ln         net.Listener
 	httpServer *http.Server
+	mutex      sync.Mutex
 }
 
 func newPPROF(
 	address string,
+	socketPermissions conf.FileMode,
 	readTimeout conf.StringDuration,
+	user conf.Credential,
+	pass conf.Credential,
 	parent pprofParent,
 ) (*pprof, error) {
-	ln, err := net.Listen(restrictNetwork("tcp", address))
+	ln, err := httpListen(address, socketPermissions)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +66,27 @@ func newPPROF(
 		ln:     ln,
 	}
 
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+
+	group := router.Group("/debug/runtime", httpServerHeaderMiddleware, httpBasicAuthMiddleware(user, pass))
+	group.GET("/get", pp.onRuntimeGet)
+
+	// routes that change the runtime or that are expensive are never exposed without credentials.
+	if user != "" {
+		group.POST("/set", pp.onRuntimeSet)
+		group.POST("/gc", pp.onRuntimeGC)
+		group.GET("/trace", pp.onRuntimeTrace)
+	} else {
+		pp.Log(logger.Warn, "apiUser is not set, /debug/runtime/set, /gc and /trace are disabled")
+	}
+
+	// profiles are served by net/http/pprof, with the same credentials.
+	router.Any("/debug/pprof/*path", httpServerHeaderMiddleware, httpBasicAuthMiddleware(user, pass),
+		pprofLimitSeconds, gin.WrapH(http.DefaultServeMux))
+
 	pp.httpServer = &http.Server{
-		Handler:           http.DefaultServeMux,
+		Handler:           router,
 		ReadHeaderTimeout: time.Duration(readTimeout),
 		ErrorLog:          log.New(&nilWriter{}, "", 0),
 	}
@@ -53,6 +98,23 @@ func newPPROF(
 	return pp, nil
 }
 
+// pprofLimitSeconds rejects CPU profiles and traces of net/http/pprof
+// that last longer than pprofMaxTraceSeconds.
+func pprofLimitSeconds(ctx *gin.Context) {
+	switch ctx.Request.URL.Path {
+	case "/debug/pprof/profile", "/debug/pprof/trace":
+		if v := ctx.Query("seconds"); v != "" {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds < 1 || seconds > pprofMaxTraceSeconds {
+				ctx.AbortWithStatus(http.StatusBadRequest)
+				return
+			}
+		}
+	}
+
+	ctx.Next()
+}
+
 func (pp *pprof) close() {
 	pp.Log(logger.Info, "listener is closing")
 	pp.httpServer.Shutdown(context.Background())
@@ -62,3 +124,88 @@ func (pp *pprof) close() {
 func (pp *pprof) Log(level logger.Level, format string, args ...interface{}) {
 	pp.parent.Log(level, "[pprof] "+format, args...)
 }
+
+// gcPercent returns the current GC percent.
+// The runtime doesn't provide a getter, therefore the value is set and restored.
+func (pp *pprof) gcPercent() int {
+	v := debug.SetGCPercent(100)
+	debug.SetGCPercent(v)
+	return v
+}
+
+func (pp *pprof) onRuntimeGet(ctx *gin.Context) {
+	pp.mutex.Lock()
+	defer pp.mutex.Unlock()
+
+	ctx.JSON(http.StatusOK, pprofRuntimeState{
+		GOMAXPROCS:   runtime.GOMAXPROCS(0),
+		GCPercent:    pp.gcPercent(),
+		NumGoroutine: runtime.NumGoroutine(),
+	})
+}
+
+func (pp *pprof) onRuntimeSet(ctx *gin.Context) {
+	var in pprofRuntimeSetReq
+	err := ctx.ShouldBindJSON(&in)
+	if err != nil || (in.GOMAXPROCS != nil && *in.GOMAXPROCS < 1) {
+		ctx.AbortWithStatus(http.StatusBadRequest)
+		return
+	}
+
+	pp.mutex.Lock()
+	defer pp.mutex.Unlock()
+
+	if in.GOMAXPROCS != nil {
+		prev := runtime.GOMAXPROCS(*in.GOMAXPROCS)
+		pp.Log(logger.Info, "GOMAXPROCS changed from %d to %d", prev, *in.GOMAXPROCS)
+	}
+
+	if in.GCPercent != nil {
+		prev := debug.SetGCPercent(*in.GCPercent)
+		pp.Log(logger.Info, "GC percent changed from %d to %d", prev, *in.GCPercent)
+	}
+
+	ctx.Status(http.StatusOK)
+}
+
+func (pp *pprof) onRuntimeGC(ctx *gin.Context) {
+	pp.Log(logger.Info, "garbage collection triggered")
+	runtime.GC()
+	debug.FreeOSMemory()
+	ctx.Status(http.StatusOK)
+}
+
+func (pp *pprof) onRuntimeTrace(ctx *gin.Context) {
+	seconds := 1
+
+	if v := ctx.Query("seconds"); v != "" {
+		var err error
+		seconds, err = strconv.Atoi(v)
+		if err != nil || seconds < 1 || seconds > pprofMaxTraceSeconds {
+			ctx.AbortWithStatus(http.StatusBadRequest)
+			return
+		}
+	}
+
+	ctx.Header("Content-Type", "application/octet-stream")
+	ctx.Header("Content-Disposition", `attachment; filename="trace-`+
+		time.Now().UTC().Format("20060102-150405")+`.out"`)
+
+	err := trace.Start(ctx.Writer)
+	if err != nil {
+		// another trace is in progress.
+		ctx.Writer.Header().Del("Content-Type")
+		ctx.Writer.Header().Del("Content-Disposition")
+		ctx.AbortWithStatus(http.StatusConflict)
+		return
+	}
+
+	pp.Log(logger.Info, "capturing a trace of %d seconds", seconds)
+
+	select {
+	case <-time.After(time.Duration(seconds) * time.Second):
+	case <-ctx.Request.Context().Done():
+	}
+
+	trace.Stop()
+}
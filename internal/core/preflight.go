@@ -0,0 +1,402 @@
+package core
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/kballard/go-shellquote"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// preflightListener is a listener that is opened by the server.
+type preflightListener struct {
+	param   string
+	network string
+	address string
+}
+
+// preflightListeners returns the listeners that are going to be opened with the given configuration.
+func preflightListeners(cnf *conf.Conf) []preflightListener {
+	var ret []preflightListener
+
+	add := func(param string, network string, address string) {
+		ret = append(ret, preflightListener{param: param, network: network, address: address})
+	}
+
+	if cnf.Metrics {
+		add("metricsAddress", "tcp", cnf.MetricsAddress)
+	}
+
+	if cnf.PPROF {
+		add("pprofAddress", "tcp", cnf.PPROFAddress)
+	}
+
+	if len(cnf.ACMEDomains) != 0 && cnf.ACMEHTTPAddress != "" {
+		add("acmeHTTPAddress", "tcp", cnf.ACMEHTTPAddress)
+	}
+
+	if !cnf.RTSPDisable &&
+		(cnf.Encryption == conf.EncryptionNo ||
+			cnf.Encryption == conf.EncryptionOptional) {
+		add("rtspAddress", "tcp", cnf.RTSPAddress)
+
+		if _, ok := cnf.Protocols[conf.Protocol(gortsplib.TransportUDP)]; ok {
+			add("rtpAddress", "udp", cnf.RTPAddress)
+			add("rtcpAddress", "udp", cnf.RTCPAddress)
+		}
+	}
+
+	if !cnf.RTSPDisable &&
+		(cnf.Encryption == conf.EncryptionStrict ||
+			cnf.Encryption == conf.EncryptionOptional) {
+		add("rtspsAddress", "tcp", cnf.RTSPSAddress)
+	}
+
+	if !cnf.RTMPDisable &&
+		(cnf.RTMPEncryption == conf.EncryptionNo ||
+			cnf.RTMPEncryption == conf.EncryptionOptional) {
+		add("rtmpAddress", "tcp", cnf.RTMPAddress)
+	}
+
+	if !cnf.RTMPDisable &&
+		(cnf.RTMPEncryption == conf.EncryptionStrict ||
+			cnf.RTMPEncryption == conf.EncryptionOptional) {
+		add("rtmpsAddress", "tcp", cnf.RTMPSAddress)
+	}
+
+	if !cnf.HLSDisable {
+		add("hlsAddress", "tcp", cnf.HLSAddress)
+	}
+
+	if !cnf.WebRTCDisable {
+		add("webrtcAddress", "tcp", cnf.WebRTCAddress)
+
+		if cnf.WebRTCICEUDPMuxAddress != "" {
+			add("webrtcICEUDPMuxAddress", "udp", cnf.WebRTCICEUDPMuxAddress)
+		}
+
+		if cnf.WebRTCICETCPMuxAddress != "" {
+			add("webrtcICETCPMuxAddress", "tcp", cnf.WebRTCICETCPMuxAddress)
+		}
+	}
+
+	if cnf.HTTPIngest {
+		add("httpIngestAddress", "tcp", cnf.HTTPIngestAddress)
+	}
+
+	if cnf.API {
+		add("apiAddress", "tcp", cnf.APIAddress)
+	}
+
+	if cnf.GRPCAPI {
+		add("grpcAPIAddress", "tcp", cnf.GRPCAPIAddress)
+	}
+
+	return ret
+}
+
+// preflightCheckListeners finds invalid addresses and ports that are assigned to multiple
+// parameters. When bind is true, it also tries to open every listener, in order to find
+// ports that are used by other programs.
+func preflightCheckListeners(cnf *conf.Conf, bind bool) []error {
+	var errs []error
+	var closers []io.Closer
+	usedBy := make(map[string]string)
+
+	defer func() {
+		for _, c := range closers {
+			c.Close()
+		}
+	}()
+
+	for _, l := range preflightListeners(cnf) {
+		// UNIX sockets left by previous instances are removed when opening the listener
+		if strings.HasPrefix(l.address, "unix://") {
+			continue
+		}
+
+		for _, addr := range splitListenAddress(l.address) {
+			_, port, err := net.SplitHostPort(addr)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("%s: '%s' is not a valid address, use the format host:port", l.param, addr))
+				continue
+			}
+
+			key := l.network + "/" + port
+			if other, ok := usedBy[key]; ok && other != l.param {
+				errs = append(errs, fmt.Errorf("%s: %s port %s is also used by %s, assign a different port to one of them",
+					l.param, strings.ToUpper(l.network), port, other))
+				continue
+			}
+
+			if !bind {
+				usedBy[key] = l.param
+				continue
+			}
+
+			var c io.Closer
+			if l.network == "udp" {
+				c, err = net.ListenPacket(restrictNetwork("udp", addr))
+			} else {
+				c, err = net.Listen(restrictNetwork("tcp", addr))
+			}
+
+			switch {
+			case err == nil:
+				usedBy[key] = l.param
+				closers = append(closers, c)
+
+			case errors.Is(err, syscall.EADDRINUSE):
+				errs = append(errs, fmt.Errorf("%s: address '%s' is already in use, probably by another instance "+
+					"of the server or by another program; stop it or change %s", l.param, addr, l.param))
+
+			case errors.Is(err, syscall.EACCES):
+				errs = append(errs, fmt.Errorf("%s: permission denied while listening on '%s'; use a port "+
+					"above 1023 or run the server with the privileges needed to bind privileged ports", l.param, addr))
+
+			default:
+				errs = append(errs, fmt.Errorf("%s: unable to listen on '%s': %s", l.param, addr, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// preflightCheckCertificates checks that the certificates of encrypted servers can be loaded.
+func preflightCheckCertificates(cnf *conf.Conf) []error {
+	type pair struct {
+		certParam string
+		cert      string
+		keyParam  string
+		key       string
+	}
+
+	var pairs []pair
+
+	// certificates are obtained automatically when ACME is enabled
+	if len(cnf.ACMEDomains) == 0 {
+		if !cnf.RTSPDisable &&
+			(cnf.Encryption == conf.EncryptionStrict ||
+				cnf.Encryption == conf.EncryptionOptional) {
+			pairs = append(pairs, pair{"serverCert", cnf.ServerCert, "serverKey", cnf.ServerKey})
+		}
+
+		if !cnf.RTMPDisable &&
+			(cnf.RTMPEncryption == conf.EncryptionStrict ||
+				cnf.RTMPEncryption == conf.EncryptionOptional) {
+			pairs = append(pairs, pair{"rtmpServerCert", cnf.RTMPServerCert, "rtmpServerKey", cnf.RTMPServerKey})
+		}
+
+		if !cnf.HLSDisable && cnf.HLSEncryption {
+			pairs = append(pairs, pair{"hlsServerCert", cnf.HLSServerCert, "hlsServerKey", cnf.HLSServerKey})
+		}
+	}
+
+	if !cnf.WebRTCDisable && cnf.WebRTCEncryption {
+		pairs = append(pairs, pair{"webrtcServerCert", cnf.WebRTCServerCert, "webrtcServerKey", cnf.WebRTCServerKey})
+	}
+
+	var errs []error
+
+	for _, p := range pairs {
+		missing := false
+
+		for _, f := range [][2]string{{p.certParam, p.cert}, {p.keyParam, p.key}} {
+			if _, err := os.Stat(f[1]); err != nil {
+				errs = append(errs, fmt.Errorf("%s: file '%s' cannot be read (%s); generate it or point %s to an existing file",
+					f[0], f[1], err, f[0]))
+				missing = true
+			}
+		}
+
+		if missing {
+			continue
+		}
+
+		_, err := tls.LoadX509KeyPair(p.cert, p.key)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s, %s: unable to load the certificate: %s", p.certParam, p.keyParam, err))
+		}
+	}
+
+	return errs
+}
+
+// preflightCommandBinary returns the executable invoked by an external command.
+// It returns an empty string if the executable is not known in advance.
+func preflightCommandBinary(cmdstr string) (string, error) {
+	parts, err := shellquote.Split(cmdstr)
+	if err != nil {
+		return "", err
+	}
+
+	if len(parts) == 0 || strings.Contains(parts[0], "$") {
+		return "", nil
+	}
+
+	return parts[0], nil
+}
+
+// preflightCheckCommand checks that the executable of an external command exists.
+func preflightCheckCommand(param string, cmdstr string, dir string) error {
+	if cmdstr == "" {
+		return nil
+	}
+
+	bin, err := preflightCommandBinary(cmdstr)
+	if err != nil {
+		return fmt.Errorf("%s: unable to parse command '%s': %s", param, cmdstr, err)
+	}
+
+	if bin == "" {
+		return nil
+	}
+
+	if dir != "" && strings.ContainsRune(bin, filepath.Separator) && !filepath.IsAbs(bin) {
+		bin = filepath.Join(dir, bin)
+	}
+
+	_, err = exec.LookPath(bin)
+	if err != nil {
+		return fmt.Errorf("%s: executable '%s' not found; install it, add it to PATH or use an absolute path",
+			param, bin)
+	}
+
+	return nil
+}
+
+// preflightCheckCommands checks external commands of the configuration.
+func preflightCheckCommands(cnf *conf.Conf) []error {
+	var errs []error
+
+	if err := preflightCheckCommand("runOnConnect", cnf.RunOnConnect, ""); err != nil {
+		errs = append(errs, err)
+	}
+
+	for _, name := range preflightPathNames(cnf) {
+		pconf := cnf.Paths[name]
+
+		cmds := [][2]string{
+			{"runOnInit", pconf.RunOnInit},
+			{"runOnDemand", pconf.RunOnDemand},
+			{"runOnReady", pconf.RunOnReady},
+			{"runOnRead", pconf.RunOnRead},
+			{"runOnAlert", pconf.RunOnAlert},
+			{"runOnMotion", pconf.RunOnMotion},
+		}
+
+		if strings.HasPrefix(pconf.Source, "exec://") {
+			cmds = append(cmds, [2]string{"source", pconf.Source[len("exec://"):]})
+		}
+
+		for _, cmd := range cmds {
+			err := preflightCheckCommand(cmd[0], cmd[1], pconf.RunOnDir)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("path '%s': %s", name, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+// preflightCheckSourceURL checks that the URL of a static source contains a valid host and port.
+func preflightCheckSourceURL(source string) error {
+	tmp := source
+	if strings.HasPrefix(tmp, "rtsp://") ||
+		strings.HasPrefix(tmp, "rtsps://") {
+		// RTSP URLs can contain special characters in credentials
+		tmp = strings.Replace(tmp, "%", "%25", -1)
+	}
+
+	u, err := url.Parse(tmp)
+	if err != nil {
+		return fmt.Errorf("'%s' is not a valid URL: %s", source, err)
+	}
+
+	if u.Hostname() == "" {
+		return fmt.Errorf("'%s' doesn't contain a host, use the format %s://host:port/path", source, u.Scheme)
+	}
+
+	if port := u.Port(); port != "" {
+		v, err := strconv.ParseUint(port, 10, 16)
+		if err != nil || v == 0 {
+			return fmt.Errorf("'%s' contains an invalid port", source)
+		}
+	}
+
+	return nil
+}
+
+// preflightCheckSources checks URLs of static sources.
+func preflightCheckSources(cnf *conf.Conf) []error {
+	var errs []error
+
+	for _, name := range preflightPathNames(cnf) {
+		pconf := cnf.Paths[name]
+
+		// sources of regular expression paths can contain placeholders
+		if strings.HasPrefix(name, "~") {
+			continue
+		}
+
+		switch {
+		case strings.HasPrefix(pconf.Source, "rtsp://"),
+			strings.HasPrefix(pconf.Source, "rtsps://"),
+			strings.HasPrefix(pconf.Source, "rtmp://"),
+			strings.HasPrefix(pconf.Source, "rtmps://"),
+			strings.HasPrefix(pconf.Source, "http://"),
+			strings.HasPrefix(pconf.Source, "https://"):
+			err := preflightCheckSourceURL(pconf.Source)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("path '%s': source %s", name, err))
+			}
+
+		case strings.HasPrefix(pconf.Source, "file://") && !pconf.HasSourceTemplate():
+			_, err := os.Stat(pconf.Source[len("file://"):])
+			if err != nil {
+				errs = append(errs, fmt.Errorf("path '%s': source file can't be read: %s", name, err))
+			}
+		}
+	}
+
+	return errs
+}
+
+func preflightPathNames(cnf *conf.Conf) []string {
+	names := make([]string, 0, len(cnf.Paths))
+	for name, pconf := range cnf.Paths {
+		if pconf != nil {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// preflight checks whether the server can start with the given configuration,
+// and returns all problems found, instead of stopping at the first one.
+// Listeners are opened only when bind is true, since they fail when
+// the server is already running.
+func preflight(cnf *conf.Conf, bind bool) []error {
+	var errs []error
+	errs = append(errs, preflightCheckListeners(cnf, bind)...)
+	errs = append(errs, preflightCheckCertificates(cnf)...)
+	errs = append(errs, preflightCheckCommands(cnf)...)
+	errs = append(errs, preflightCheckSources(cnf)...)
+	return errs
+}
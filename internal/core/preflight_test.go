@@ -0,0 +1,56 @@
+package core
+
+import (
+	"net"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestPreflight(t *testing.T) {
+	ln, err := net.Listen("tcp", "localhost:8554")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	tmpf, err := writeTempFile([]byte("rtspAddress: localhost:8554\n" +
+		"rtmpAddress: :8888\n" +
+		"hlsEncryption: yes\n" +
+		"hlsServerCert: /nonexisting/server.crt\n" +
+		"hlsServerKey: /nonexisting/server.key\n" +
+		"paths:\n" +
+		"  cam:\n" +
+		"    source: rtsp:///mystream\n" +
+		"    runOnInit: nonexisting-command --arg\n" +
+		"  other:\n" +
+		"    runOnReady: sh -c 'echo'\n"))
+	require.NoError(t, err)
+	defer os.Remove(tmpf)
+
+	cnf, _, err := conf.Load(tmpf)
+	require.NoError(t, err)
+
+	errs := preflight(cnf, true)
+
+	var msgs []string
+	for _, err := range errs {
+		msgs = append(msgs, err.Error())
+	}
+
+	require.Equal(t, 6, len(msgs), strings.Join(msgs, "\n"))
+	require.Contains(t, msgs[0], "rtspAddress: address 'localhost:8554' is already in use")
+	require.Contains(t, msgs[1], "hlsAddress: TCP port 8888 is also used by rtmpAddress")
+	require.Contains(t, msgs[2], "hlsServerCert: file '/nonexisting/server.crt' cannot be read")
+	require.Contains(t, msgs[3], "hlsServerKey: file '/nonexisting/server.key' cannot be read")
+	require.Contains(t, msgs[4], "path 'cam': runOnInit: executable 'nonexisting-command' not found")
+	require.Contains(t, msgs[5], "path 'cam': source 'rtsp:///mystream' doesn't contain a host")
+
+	// without binding, ports used by other programs are not reported,
+	// in order to check the configuration of a running server.
+	errs = preflight(cnf, false)
+	require.Equal(t, 5, len(errs))
+	require.Contains(t, errs[0].Error(), "hlsAddress: TCP port 8888 is also used by rtmpAddress")
+}
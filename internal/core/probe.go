@@ -0,0 +1,368 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gohlslib"
+	"github.com/bluenviron/gohlslib/pkg/codecs"
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	rtspurl "github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/rtmp"
+	"github.com/aler9/mediamtx/internal/rtmp/message"
+)
+
+const (
+	probeTimeout = 10 * time.Second
+)
+
+type probeResult struct {
+	medias   media.Medias
+	received uint64
+}
+
+func probeRTSP(ur string, duration time.Duration) (*probeResult, error) {
+	u, err := rtspurl.Parse(ur)
+	if err != nil {
+		return nil, err
+	}
+
+	c := gortsplib.Client{
+		ReadTimeout:  probeTimeout,
+		WriteTimeout: probeTimeout,
+		TLSConfig: &tls.Config{
+			InsecureSkipVerify: true,
+		},
+	}
+
+	err = c.Start(u.Scheme, u.Host)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+
+	medias, baseURL, _, err := c.Describe(u)
+	if err != nil {
+		return nil, err
+	}
+
+	err = c.SetupAll(medias, baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &probeResult{
+		medias: medias,
+	}
+
+	c.OnPacketRTPAny(func(medi *media.Media, forma formats.Format, pkt *rtp.Packet) {
+		atomic.AddUint64(&res.received, uint64(len(pkt.Payload)))
+	})
+
+	_, err = c.Play(nil)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-waitErr(c.Wait):
+		return nil, err
+
+	case <-time.After(duration):
+	}
+
+	return res, nil
+}
+
+func probeRTMP(u *url.URL, duration time.Duration) (*probeResult, error) {
+	// add default port
+	_, _, err := net.SplitHostPort(u.Host)
+	if err != nil {
+		u.Host = net.JoinHostPort(u.Host, "1935")
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+
+	nconn, err := func() (net.Conn, error) {
+		if u.Scheme == "rtmp" {
+			return (&net.Dialer{}).DialContext(ctx, "tcp", u.Host)
+		}
+
+		return (&tls.Dialer{Config: &tls.Config{InsecureSkipVerify: true}}).DialContext(ctx, "tcp", u.Host)
+	}()
+	if err != nil {
+		return nil, err
+	}
+	defer nconn.Close()
+
+	conn := rtmp.NewConn(nconn)
+
+	nconn.SetDeadline(time.Now().Add(probeTimeout))
+	err = conn.InitializeClient(u, false)
+	if err != nil {
+		return nil, err
+	}
+
+	videoFormat, audioFormat, err := conn.ReadTracks()
+	if err != nil {
+		return nil, err
+	}
+
+	res := &probeResult{}
+
+	if videoFormat != nil {
+		res.medias = append(res.medias, &media.Media{
+			Type:    media.TypeVideo,
+			Formats: []formats.Format{videoFormat},
+		})
+	}
+
+	if audioFormat != nil {
+		res.medias = append(res.medias, &media.Media{
+			Type:    media.TypeAudio,
+			Formats: []formats.Format{audioFormat},
+		})
+	}
+
+	end := time.Now().Add(duration)
+
+	for time.Now().Before(end) {
+		nconn.SetReadDeadline(time.Now().Add(probeTimeout))
+		msg, err := conn.ReadMessage()
+		if err != nil {
+			return nil, err
+		}
+
+		switch tmsg := msg.(type) {
+		case *message.Video:
+			res.received += uint64(len(tmsg.Payload))
+
+		case *message.ExtendedCodedFrames:
+			res.received += uint64(len(tmsg.Payload))
+
+		case *message.ExtendedFramesX:
+			res.received += uint64(len(tmsg.Payload))
+
+		case *message.Audio:
+			res.received += uint64(len(tmsg.Payload))
+		}
+	}
+
+	return res, nil
+}
+
+func probeHLS(ur string, duration time.Duration) (*probeResult, error) {
+	res := &probeResult{}
+
+	c := &gohlslib.Client{
+		URI: ur,
+		HTTPClient: &http.Client{
+			Transport: &http.Transport{
+				TLSClientConfig: &tls.Config{
+					InsecureSkipVerify: true,
+				},
+			},
+		},
+		Log: func(level gohlslib.LogLevel, format string, args ...interface{}) {
+		},
+	}
+
+	c.OnTracks(func(tracks []*gohlslib.Track) error {
+		var medias media.Medias
+
+		for _, track := range tracks {
+			var medi *media.Media
+
+			switch tcodec := track.Codec.(type) {
+			case *codecs.H264:
+				medi = &media.Media{
+					Type: media.TypeVideo,
+					Formats: []formats.Format{&formats.H264{
+						PayloadTyp:        96,
+						PacketizationMode: 1,
+						SPS:               tcodec.SPS,
+						PPS:               tcodec.PPS,
+					}},
+				}
+
+				c.OnData(track, func(pts time.Duration, unit interface{}) {
+					for _, nalu := range unit.([][]byte) {
+						atomic.AddUint64(&res.received, uint64(len(nalu)))
+					}
+				})
+
+			case *codecs.H265:
+				medi = &media.Media{
+					Type: media.TypeVideo,
+					Formats: []formats.Format{&formats.H265{
+						PayloadTyp: 96,
+						VPS:        tcodec.VPS,
+						SPS:        tcodec.SPS,
+						PPS:        tcodec.PPS,
+					}},
+				}
+
+				c.OnData(track, func(pts time.Duration, unit interface{}) {
+					for _, nalu := range unit.([][]byte) {
+						atomic.AddUint64(&res.received, uint64(len(nalu)))
+					}
+				})
+
+			case *codecs.MPEG4Audio:
+				medi = &media.Media{
+					Type: media.TypeAudio,
+					Formats: []formats.Format{&formats.MPEG4Audio{
+						PayloadTyp:       96,
+						SizeLength:       13,
+						IndexLength:      3,
+						IndexDeltaLength: 3,
+						Config:           &tcodec.Config,
+					}},
+				}
+
+				c.OnData(track, func(pts time.Duration, unit interface{}) {
+					atomic.AddUint64(&res.received, uint64(len(unit.([]byte))))
+				})
+
+			case *codecs.Opus:
+				medi = &media.Media{
+					Type: media.TypeAudio,
+					Formats: []formats.Format{&formats.Opus{
+						PayloadTyp: 96,
+						IsStereo:   (tcodec.Channels == 2),
+					}},
+				}
+
+				c.OnData(track, func(pts time.Duration, unit interface{}) {
+					atomic.AddUint64(&res.received, uint64(len(unit.([]byte))))
+				})
+			}
+
+			medias = append(medias, medi)
+		}
+
+		res.medias = medias
+		return nil
+	})
+
+	err := c.Start()
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case err := <-c.Wait():
+		return nil, err
+
+	case <-time.After(duration):
+		c.Close()
+		<-c.Wait()
+	}
+
+	if res.medias == nil {
+		return nil, fmt.Errorf("no tracks received in %v", duration)
+	}
+
+	return res, nil
+}
+
+// waitErr wraps a blocking function into a channel.
+func waitErr(fn func() error) chan error {
+	ch := make(chan error, 1)
+	go func() {
+		ch <- fn()
+	}()
+	return ch
+}
+
+func probeFormatDescription(forma formats.Format) string {
+	desc := forma.String()
+
+	switch tforma := forma.(type) {
+	case *formats.H264:
+		sps, _ := tforma.SafeParams()
+		var s h264.SPS
+		if sps != nil && s.Unmarshal(sps) == nil {
+			desc += fmt.Sprintf(", %dx%d", s.Width(), s.Height())
+			if fps := s.FPS(); fps != 0 {
+				desc += fmt.Sprintf(", %.2f fps", fps)
+			}
+		}
+
+	case *formats.H265:
+		_, sps, _ := tforma.SafeParams()
+		var s h265.SPS
+		if sps != nil && s.Unmarshal(sps) == nil {
+			desc += fmt.Sprintf(", %dx%d", s.Width(), s.Height())
+			if fps := s.FPS(); fps != 0 {
+				desc += fmt.Sprintf(", %.2f fps", fps)
+			}
+		}
+
+	case *formats.MPEG4Audio:
+		desc += fmt.Sprintf(", %d Hz, %d channels", tforma.Config.SampleRate, tforma.Config.ChannelCount)
+
+	default:
+		desc += fmt.Sprintf(", clock rate %d", forma.ClockRate())
+	}
+
+	return desc
+}
+
+// probe connects to a RTSP, RTMP or HLS source, reads it for the given duration
+// and returns a human-readable report with its tracks and measured bitrate.
+func probe(ur string, duration time.Duration) (string, error) {
+	u, err := url.Parse(ur)
+	if err != nil {
+		return "", err
+	}
+
+	var res *probeResult
+
+	switch u.Scheme {
+	case "rtsp", "rtsps":
+		res, err = probeRTSP(ur, duration)
+
+	case "rtmp", "rtmps":
+		res, err = probeRTMP(u, duration)
+
+	case "http", "https":
+		res, err = probeHLS(ur, duration)
+
+	default:
+		return "", fmt.Errorf("unsupported scheme: '%s'", u.Scheme)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	var buf strings.Builder
+
+	fmt.Fprintf(&buf, "source: %s\n", ur)
+	fmt.Fprintf(&buf, "tracks:\n")
+
+	for _, medi := range res.medias {
+		for _, forma := range medi.Formats {
+			fmt.Fprintf(&buf, "  %s: %s\n", medi.Type, probeFormatDescription(forma))
+		}
+	}
+
+	fmt.Fprintf(&buf, "bitrate: %.2f kbit/s (measured over %v)\n",
+		float64(atomic.LoadUint64(&res.received))*8/1000/duration.Seconds(), duration)
+
+	return buf.String(), nil
+}
@@ -0,0 +1,58 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestProbe(t *testing.T) {
+	p, ok := newInstance("paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	medi := testMediaH264
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/mypath", media.Medias{medi})
+	require.NoError(t, err)
+	defer source.Close()
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		for i := uint16(0); ; i++ {
+			select {
+			case <-done:
+				return
+			case <-time.After(50 * time.Millisecond):
+			}
+
+			source.WritePacketRTP(medi, &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 123 + i,
+					Timestamp:      45343 + uint32(i)*4500,
+					SSRC:           563423,
+				},
+				Payload: []byte{0x05, 0x01, 0x02, 0x03, 0x04},
+			})
+		}
+	}()
+
+	report, err := probe("rtsp://localhost:8554/mypath", 500*time.Millisecond)
+	require.NoError(t, err)
+	require.Contains(t, report, "video: H264, 1920x1080")
+	require.NotContains(t, report, "bitrate: 0.00 kbit/s")
+
+	_, err = probe("ftp://localhost/mypath", 500*time.Millisecond)
+	require.EqualError(t, err, "unsupported scheme: 'ftp'")
+}
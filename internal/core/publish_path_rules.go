@@ -0,0 +1,73 @@
+package core
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+type publishPathRule struct {
+	identity string
+	match    *regexp.Regexp
+	prefix   string
+}
+
+// publishPathRules confines publishers to the paths that are derived from their identity.
+type publishPathRules []publishPathRule
+
+func newPublishPathRules(confRules conf.PublishPathRules) publishPathRules {
+	rules := make(publishPathRules, len(confRules))
+
+	for i, r := range confRules {
+		rules[i] = publishPathRule{
+			identity: r.Identity,
+			match:    regexp.MustCompile(r.Match), // already checked by conf
+			prefix:   r.Prefix,
+		}
+	}
+
+	return rules
+}
+
+// check checks whether a publisher can publish to a path.
+// The first rule that matches the identity of the publisher is applied.
+// When there are rules, publishers whose identity doesn't match any of them,
+// for instance anonymous ones, are rejected.
+func (rules publishPathRules) check(identity authIdentity, pathName string) error {
+	if len(rules) == 0 {
+		return nil
+	}
+
+	for _, r := range rules {
+		v := identity.user
+		if r.identity == "token" {
+			v = identity.token
+		}
+
+		if v == "" {
+			continue
+		}
+
+		m := r.match.FindStringSubmatchIndex(v)
+		if m == nil {
+			continue
+		}
+
+		// slashes would allow to enter the namespace of other identities.
+		if strings.Contains(v, "/") {
+			return fmt.Errorf("%s contains a slash, publishing is not allowed", r.identity)
+		}
+
+		prefix := string(r.match.ExpandString(nil, r.prefix, v, m))
+
+		if !strings.HasPrefix(pathName, prefix) {
+			return fmt.Errorf("publisher is allowed to publish only to paths that start with '%s'", prefix)
+		}
+
+		return nil
+	}
+
+	return fmt.Errorf("publisher doesn't match any publish path rule")
+}
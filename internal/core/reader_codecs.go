@@ -0,0 +1,98 @@
+package core
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+)
+
+// codecs that can be read with each protocol.
+var (
+	hlsSupportedCodecs    = []string{"H264", "H265", "MPEG-4 Audio", "Opus"}
+	rtmpSupportedCodecs   = []string{"H264", "H265", "MPEG-2 Audio", "MPEG-4 Audio", "G711"}
+	webRTCSupportedCodecs = []string{"AV1", "VP9", "VP8", "H264", "Opus", "G722", "G711"}
+)
+
+// formatCodec returns the name of the codec of a format.
+func formatCodec(forma formats.Format) string {
+	switch forma.(type) {
+	case *formats.MPEG4Audio:
+		return "MPEG-4 Audio"
+
+	case *formats.MPEG2Audio:
+		return "MPEG-2 Audio"
+
+	default:
+		return forma.String()
+	}
+}
+
+// readerErrCodecsUnsupported is returned when the protocol of a reader
+// can't carry any codec of the stream.
+type readerErrCodecsUnsupported struct {
+	protocol  string
+	codecs    []string
+	supported []string
+}
+
+func newReaderErrCodecsUnsupported(
+	protocol string,
+	supported []string,
+	medias media.Medias,
+) readerErrCodecsUnsupported {
+	var codecs []string
+	for _, medi := range medias {
+		for _, forma := range medi.Formats {
+			codecs = append(codecs, formatCodec(forma))
+		}
+	}
+
+	return readerErrCodecsUnsupported{
+		protocol:  protocol,
+		codecs:    codecs,
+		supported: supported,
+	}
+}
+
+// Error implements the error interface.
+func (e readerErrCodecsUnsupported) Error() string {
+	return fmt.Sprintf("the stream doesn't contain any supported codec, which are currently %s (stream codecs: %s)",
+		strings.Join(e.supported, ", "), strings.Join(e.codecs, ", "))
+}
+
+// readerCodecsProblem is the body of responses to readers that have been rejected
+// because of codecs, in the RFC 7807 (Problem Details for HTTP APIs) format,
+// in order to allow clients to show a meaningful message or to fall back to another protocol.
+type readerCodecsProblem struct {
+	Type            string   `json:"type"`
+	Title           string   `json:"title"`
+	Status          int      `json:"status"`
+	Detail          string   `json:"detail"`
+	Protocol        string   `json:"protocol"`
+	Codecs          []string `json:"codecs"`
+	SupportedCodecs []string `json:"supportedCodecs"`
+}
+
+func (e readerErrCodecsUnsupported) problem() readerCodecsProblem {
+	return readerCodecsProblem{
+		Type:            "urn:mediamtx:codecs-unsupported",
+		Title:           "Unsupported codecs",
+		Status:          http.StatusNotAcceptable,
+		Detail:          e.Error(),
+		Protocol:        e.protocol,
+		Codecs:          e.codecs,
+		SupportedCodecs: e.supported,
+	}
+}
+
+// writeHTTP writes the error as a HTTP response.
+func (e readerErrCodecsUnsupported) writeHTTP(w http.ResponseWriter) {
+	byts, _ := json.Marshal(e.problem())
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(http.StatusNotAcceptable)
+	w.Write(byts)
+}
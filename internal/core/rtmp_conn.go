@@ -1,6 +1,7 @@
 package core
 
 import (
+	"bytes"
 	"context"
 	"errors"
 	"fmt"
@@ -15,11 +16,13 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
 	"github.com/bluenviron/mediacommon/pkg/codecs/av1"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg2audio"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
 	"github.com/google/uuid"
 	"github.com/notedit/rtmp/format/flv/flvio"
 
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/formatprocessor"
@@ -27,10 +30,7 @@ import (
 	"github.com/aler9/mediamtx/internal/rtmp"
 	"github.com/aler9/mediamtx/internal/rtmp/h264conf"
 	"github.com/aler9/mediamtx/internal/rtmp/message"
-)
-
-const (
-	rtmpConnPauseAfterAuthError = 2 * time.Second
+	"github.com/aler9/mediamtx/internal/rtmp/tracks"
 )
 
 func pathNameAndQuery(inURL *url.URL) (string, url.Values, string) {
@@ -129,7 +129,20 @@ func getRTMPWriteFunc(medi *media.Media, format formats.Format, stream *stream)
 
 	case *formats.AV1:
 		return func(msg interface{}) error {
-			if tmsg, ok := msg.(*message.ExtendedCodedFrames); ok {
+			switch tmsg := msg.(type) {
+			case *message.ExtendedFramesX:
+				obus, err := av1.BitstreamUnmarshal(tmsg.Payload, true)
+				if err != nil {
+					return fmt.Errorf("unable to decode bitstream: %v", err)
+				}
+
+				stream.writeUnit(medi, format, &formatprocessor.UnitAV1{
+					PTS:  tmsg.DTS,
+					OBUs: obus,
+					NTP:  time.Now(),
+				})
+
+			case *message.ExtendedCodedFrames:
 				obus, err := av1.BitstreamUnmarshal(tmsg.Payload, true)
 				if err != nil {
 					return fmt.Errorf("unable to decode bitstream: %v", err)
@@ -158,6 +171,19 @@ func getRTMPWriteFunc(medi *media.Media, format formats.Format, stream *stream)
 			return nil
 		}
 
+	case *formats.G711:
+		return func(msg interface{}) error {
+			tmsg := msg.(*message.Audio)
+
+			stream.writeUnit(medi, format, &formatprocessor.UnitG711{
+				PTS:     tmsg.DTS,
+				Samples: tmsg.Payload,
+				NTP:     time.Now(),
+			})
+
+			return nil
+		}
+
 	case *formats.MPEG4Audio:
 		return func(msg interface{}) error {
 			tmsg := msg.(*message.Audio)
@@ -187,6 +213,7 @@ const (
 
 type rtmpConnPathManager interface {
 	readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes
+	readerRejected(pathName string, protocol string, reason pathReaderRejectionReason, message string)
 	publisherAdd(req pathPublisherAddReq) pathPublisherAnnounceRes
 }
 
@@ -198,6 +225,9 @@ type rtmpConnParent interface {
 type rtmpConn struct {
 	isTLS                     bool
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
+	authPauseAfterError       conf.StringDuration
 	rtspAddress               string
 	readTimeout               conf.StringDuration
 	writeTimeout              conf.StringDuration
@@ -216,6 +246,7 @@ type rtmpConn struct {
 	uuid       uuid.UUID
 	created    time.Time
 	state      rtmpConnState
+	identity   authIdentity
 	stateMutex sync.Mutex
 }
 
@@ -223,6 +254,9 @@ func newRTMPConn(
 	parentCtx context.Context,
 	isTLS bool,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
+	authPauseAfterError conf.StringDuration,
 	rtspAddress string,
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
@@ -240,6 +274,9 @@ func newRTMPConn(
 	c := &rtmpConn{
 		isTLS:                     isTLS,
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
+		authPauseAfterError:       authPauseAfterError,
 		rtspAddress:               rtspAddress,
 		readTimeout:               readTimeout,
 		writeTimeout:              writeTimeout,
@@ -288,12 +325,18 @@ func (c *rtmpConn) safeState() rtmpConnState {
 	return c.state
 }
 
+func (c *rtmpConn) safeIdentity() authIdentity {
+	c.stateMutex.Lock()
+	defer c.stateMutex.Unlock()
+	return c.identity
+}
+
 func (c *rtmpConn) run() {
 	defer c.wg.Done()
 
 	if c.runOnConnect != "" {
 		c.Log(logger.Info, "runOnConnect command started")
-		_, port, _ := net.SplitHostPort(c.rtspAddress)
+		port := listenAddressPort(c.rtspAddress)
 		onConnectCmd := externalcmd.NewCmd(
 			c.externalCmdPool,
 			c.runOnConnect,
@@ -361,19 +404,27 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 	res := c.pathManager.readerAdd(pathReaderAddReq{
 		author:   c,
 		pathName: pathName,
+		query:    rawQuery,
+		protocol: "rtmp",
 		authenticate: func(
 			pathIPs []fmt.Stringer,
 			pathUser conf.Credential,
 			pathPass conf.Credential,
+			pathPermissions conf.PathPermissions,
 		) error {
-			return c.authenticate(pathName, pathIPs, pathUser, pathPass, false, query, rawQuery)
+			_, err := c.authenticate(pathName, pathIPs, pathUser, pathPass, pathPermissions, false, query, rawQuery)
+			return err
 		},
 	})
 
 	if res.err != nil {
 		if terr, ok := res.err.(pathErrAuthCritical); ok {
+			if c.authBanList != nil {
+				c.authBanList.onFailure(c.ip())
+			}
+
 			// wait some seconds to stop brute force attacks
-			<-time.After(rtmpConnPauseAfterAuthError)
+			<-time.After(time.Duration(c.authPauseAfterError))
 			return errors.New(terr.message)
 		}
 		return res.err
@@ -387,6 +438,7 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 
 	c.stateMutex.Lock()
 	c.state = rtmpConnStateRead
+	c.identity = newAuthIdentity(query.Get("user"), rawQuery)
 	c.stateMutex.Unlock()
 
 	ringBuffer, _ := ringbuffer.New(uint64(c.readBufferCount))
@@ -412,8 +464,9 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 	}
 
 	if videoFormat == nil && audioFormat == nil {
-		return fmt.Errorf(
-			"the stream doesn't contain any supported codec, which are currently H264, MPEG-2 Audio, MPEG-4 Audio")
+		err := newReaderErrCodecsUnsupported("rtmp", rtmpSupportedCodecs, res.stream.medias())
+		c.pathManager.readerRejected(pathName, "rtmp", pathReaderRejectionCodecUnsupported, err.Error())
+		return err
 	}
 
 	defer res.stream.readerRemove(c)
@@ -425,11 +478,12 @@ func (c *rtmpConn) runRead(ctx context.Context, u *url.URL) error {
 
 	if pathConf.RunOnRead != "" {
 		c.Log(logger.Info, "runOnRead command started")
-		onReadCmd := externalcmd.NewCmd(
+		onReadCmd := externalcmd.NewCmdWithOptions(
 			c.externalCmdPool,
 			pathConf.RunOnRead,
 			pathConf.RunOnReadRestart,
 			path.externalCmdEnv(),
+			externalCmdOptions(pathConf, c),
 			func(co int) {
 				c.Log(logger.Info, "runOnRead command exited with code %d", co)
 			})
@@ -471,6 +525,9 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		var videoStartPTS time.Duration
 		var videoDTSExtractor *h264.DTSExtractor
 
+		// parameters sent by WriteTracks()
+		videoSPS, videoPPS := videoFormatH264.SafeParams()
+
 		stream.readerAdd(c, videoMedia, videoFormatH264, func(unit formatprocessor.Unit) {
 			ringBuffer.Push(func() error {
 				tunit := unit.(*formatprocessor.UnitH264)
@@ -534,6 +591,35 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 					pts -= *videoStartDTS
 				}
 
+				// send the decoder configuration when it becomes available or changes
+				if idrPresent {
+					sps, pps := videoFormatH264.SafeParams()
+					if sps != nil && pps != nil &&
+						(!bytes.Equal(sps, videoSPS) || !bytes.Equal(pps, videoPPS)) {
+						videoSPS = sps
+						videoPPS = pps
+
+						buf, _ := h264conf.Conf{
+							SPS: sps,
+							PPS: pps,
+						}.Marshal()
+
+						c.nconn.SetWriteDeadline(time.Now().Add(time.Duration(c.writeTimeout)))
+						err := c.conn.WriteMessage(&message.Video{
+							ChunkStreamID:   message.VideoChunkStreamID,
+							MessageStreamID: 0x1000000,
+							Codec:           message.CodecH264,
+							IsKeyFrame:      true,
+							Type:            message.VideoTypeConfig,
+							Payload:         buf,
+							DTS:             dts,
+						})
+						if err != nil {
+							return err
+						}
+					}
+				}
+
 				avcc, err := h264.AVCCMarshal(tunit.AU)
 				if err != nil {
 					return err
@@ -554,6 +640,8 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 					return err
 				}
 
+				stream.latency.observe(streamLatencyRTMP, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -561,6 +649,135 @@ func (c *rtmpConn) findVideoFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		return videoMedia, videoFormatH264
 	}
 
+	var videoFormatH265 *formats.H265
+	videoMedia = stream.medias().FindFormat(&videoFormatH265)
+
+	if videoFormatH265 != nil {
+		videoStartPTSFilled := false
+		var videoStartPTS time.Duration
+		var videoDTSExtractor *h265.DTSExtractor
+
+		// parameters sent by WriteTracks()
+		videoVPS, videoSPS, videoPPS := videoFormatH265.SafeParams()
+
+		stream.readerAdd(c, videoMedia, videoFormatH265, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitH265)
+
+				if tunit.AU == nil {
+					return nil
+				}
+
+				if !videoStartPTSFilled {
+					videoStartPTSFilled = true
+					videoStartPTS = tunit.PTS
+				}
+				pts := tunit.PTS - videoStartPTS
+
+				randomAccessPresent := false
+				vclPresent := false
+
+				for _, nalu := range tunit.AU {
+					typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
+					switch typ {
+					case h265.NALUType_IDR_W_RADL, h265.NALUType_IDR_N_LP, h265.NALUType_CRA_NUT:
+						randomAccessPresent = true
+					}
+					if typ < h265.NALUType_VPS_NUT {
+						vclPresent = true
+					}
+				}
+
+				var dts time.Duration
+
+				// wait until we receive a random access unit
+				if !*videoFirstIDRFound {
+					if !randomAccessPresent {
+						return nil
+					}
+
+					*videoFirstIDRFound = true
+					videoDTSExtractor = h265.NewDTSExtractor()
+
+					var err error
+					dts, err = videoDTSExtractor.Extract(tunit.AU, pts)
+					if err != nil {
+						return err
+					}
+
+					*videoStartDTS = dts
+					dts = 0
+					pts -= *videoStartDTS
+				} else {
+					if !vclPresent {
+						return nil
+					}
+
+					var err error
+					dts, err = videoDTSExtractor.Extract(tunit.AU, pts)
+					if err != nil {
+						return err
+					}
+
+					dts -= *videoStartDTS
+					pts -= *videoStartDTS
+				}
+
+				// send the decoder configuration when it becomes available or changes
+				if randomAccessPresent {
+					vps, sps, pps := videoFormatH265.SafeParams()
+					if vps != nil && sps != nil && pps != nil &&
+						(!bytes.Equal(vps, videoVPS) || !bytes.Equal(sps, videoSPS) || !bytes.Equal(pps, videoPPS)) {
+						videoVPS = vps
+						videoSPS = sps
+						videoPPS = pps
+
+						buf, err := tracks.H265Config(vps, sps, pps)
+						if err != nil {
+							return err
+						}
+
+						c.nconn.SetWriteDeadline(time.Now().Add(time.Duration(c.writeTimeout)))
+						err = c.conn.WriteMessage(&message.ExtendedSequenceStart{
+							ChunkStreamID:   message.VideoChunkStreamID,
+							MessageStreamID: 0x1000000,
+							FourCC:          message.FourCCHEVC,
+							Config:          buf,
+							DTS:             dts,
+						})
+						if err != nil {
+							return err
+						}
+					}
+				}
+
+				avcc, err := h264.AVCCMarshal(tunit.AU)
+				if err != nil {
+					return err
+				}
+
+				c.nconn.SetWriteDeadline(time.Now().Add(time.Duration(c.writeTimeout)))
+				err = c.conn.WriteMessage(&message.ExtendedCodedFrames{
+					ChunkStreamID:   message.VideoChunkStreamID,
+					MessageStreamID: 0x1000000,
+					FourCC:          message.FourCCHEVC,
+					Payload:         avcc,
+					DTS:             dts,
+					PTSDelta:        pts - dts,
+				})
+				if err != nil {
+					return err
+				}
+
+				stream.latency.observe(streamLatencyRTMP, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return videoMedia, videoFormatH265
+	}
+
 	return nil, nil
 }
 
@@ -618,6 +835,8 @@ func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 					}
 				}
 
+				stream.latency.observe(streamLatencyRTMP, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -700,6 +919,8 @@ func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 						time.Second / time.Duration(h.SampleRate)
 				}
 
+				stream.latency.observe(streamLatencyRTMP, tunit.NTP)
+
 				return nil
 			})
 		})
@@ -707,6 +928,67 @@ func (c *rtmpConn) findAudioFormat(stream *stream, ringBuffer *ringbuffer.RingBu
 		return audioMedia, audioFormatMPEG2
 	}
 
+	var audioFormatG711 *formats.G711
+	audioMedia = stream.medias().FindFormat(&audioFormatG711)
+
+	if audioMedia != nil {
+		audioStartPTSFilled := false
+		var audioStartPTS time.Duration
+
+		codec := uint8(message.CodecPCMA)
+		if audioFormatG711.MULaw {
+			codec = message.CodecPCMU
+		}
+
+		stream.readerAdd(c, audioMedia, audioFormatG711, func(unit formatprocessor.Unit) {
+			ringBuffer.Push(func() error {
+				tunit := unit.(*formatprocessor.UnitG711)
+
+				if tunit.Samples == nil {
+					return nil
+				}
+
+				if !audioStartPTSFilled {
+					audioStartPTSFilled = true
+					audioStartPTS = tunit.PTS
+				}
+				pts := tunit.PTS - audioStartPTS
+
+				if videoFormat != nil {
+					if !*videoFirstIDRFound {
+						return nil
+					}
+
+					pts -= *videoStartDTS
+					if pts < 0 {
+						return nil
+					}
+				}
+
+				c.nconn.SetWriteDeadline(time.Now().Add(time.Duration(c.writeTimeout)))
+				err := c.conn.WriteMessage(&message.Audio{
+					ChunkStreamID:   message.AudioChunkStreamID,
+					MessageStreamID: 0x1000000,
+					Codec:           codec,
+					Rate:            flvio.SOUND_5_5Khz,
+					Depth:           flvio.SOUND_16BIT,
+					Channels:        flvio.SOUND_MONO,
+					Payload:         tunit.Samples,
+					DTS:             pts,
+				})
+				if err != nil {
+					return err
+				}
+
+				stream.latency.observe(streamLatencyRTMP, tunit.NTP)
+
+				return nil
+			})
+		})
+
+		return audioMedia, audioFormatG711
+	}
+
 	return nil, nil
 }
 
@@ -714,21 +996,27 @@ func (c *rtmpConn) runPublish(ctx context.Context, u *url.URL) error {
 	pathName, query, rawQuery := pathNameAndQuery(u)
 
 	res := c.pathManager.publisherAdd(pathPublisherAddReq{
-		author:   c,
-		pathName: pathName,
+		author:          c,
+		pathName:        pathName,
+		streamKeyInPath: true,
 		authenticate: func(
 			pathIPs []fmt.Stringer,
 			pathUser conf.Credential,
 			pathPass conf.Credential,
-		) error {
-			return c.authenticate(pathName, pathIPs, pathUser, pathPass, true, query, rawQuery)
+			pathPermissions conf.PathPermissions,
+		) (authIdentity, error) {
+			return c.authenticate(pathName, pathIPs, pathUser, pathPass, pathPermissions, true, query, rawQuery)
 		},
 	})
 
 	if res.err != nil {
 		if terr, ok := res.err.(pathErrAuthCritical); ok {
+			if c.authBanList != nil {
+				c.authBanList.onFailure(c.ip())
+			}
+
 			// wait some seconds to stop brute force attacks
-			<-time.After(rtmpConnPauseAfterAuthError)
+			<-time.After(time.Duration(c.authPauseAfterError))
 			return errors.New(terr.message)
 		}
 		return res.err
@@ -742,6 +1030,7 @@ func (c *rtmpConn) runPublish(ctx context.Context, u *url.URL) error {
 
 	c.stateMutex.Lock()
 	c.state = rtmpConnStatePublish
+	c.identity = newAuthIdentity(query.Get("user"), rawQuery)
 	c.stateMutex.Unlock()
 
 	videoFormat, audioFormat, err := c.conn.ReadTracks()
@@ -824,10 +1113,31 @@ func (c *rtmpConn) authenticate(
 	pathIPs []fmt.Stringer,
 	pathUser conf.Credential,
 	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
 	isPublishing bool,
 	query url.Values,
 	rawQuery string,
-) error {
+) (authIdentity, error) {
+	var identity authIdentity
+
+	if c.authBanList != nil {
+		err := c.authBanList.check(c.ip())
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
+	if c.accessList != nil {
+		err := c.accessList.checkAction(c.ip(), isPublishing)
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
 	if c.externalAuthenticationURL != "" {
 		err := externalAuth(
 			c.externalAuthenticationURL,
@@ -840,31 +1150,62 @@ func (c *rtmpConn) authenticate(
 			isPublishing,
 			rawQuery)
 		if err != nil {
-			return pathErrAuthCritical{
+			return authIdentity{}, pathErrAuthCritical{
 				message: fmt.Sprintf("external authentication failed: %s", err),
 			}
 		}
+
+		// credentials and the query have been verified by the external service.
+		identity = newAuthIdentity(query.Get("user"), rawQuery)
 	}
 
 	if pathIPs != nil {
 		ip := c.ip()
-		if !ipEqualOrInRange(ip, pathIPs) {
-			return pathErrAuthCritical{
+		if !auth.IPEqualOrInRange(ip, pathIPs) {
+			return authIdentity{}, pathErrAuthCritical{
 				message: fmt.Sprintf("IP '%s' not allowed", ip),
 			}
 		}
 	}
 
 	if pathUser != "" {
-		if query.Get("user") != string(pathUser) ||
-			query.Get("pass") != string(pathPass) {
-			return pathErrAuthCritical{
+		if !auth.CredentialMatches(pathUser, query.Get("user")) ||
+			!auth.CredentialMatches(pathPass, query.Get("pass")) {
+			return authIdentity{}, pathErrAuthCritical{
 				message: "invalid credentials",
 			}
 		}
+
+		identity.user = query.Get("user")
 	}
 
-	return nil
+	if len(pathPermissions) != 0 {
+		action := auth.ActionRead
+		if isPublishing {
+			action = auth.ActionPublish
+		}
+
+		err := auth.Check(pathPermissions, auth.Request{
+			IP:       c.ip(),
+			Action:   action,
+			Protocol: auth.ProtocolRTMP,
+			CheckCredentials: func(user conf.Credential, pass conf.Credential) bool {
+				if auth.CredentialMatches(user, query.Get("user")) &&
+					auth.CredentialMatches(pass, query.Get("pass")) {
+					identity.user = query.Get("user")
+					return true
+				}
+				return false
+			},
+		})
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
+	return identity, nil
 }
 
 // apiReaderDescribe implements reader.
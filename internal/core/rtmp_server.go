@@ -43,12 +43,21 @@ type rtmpServerAPIConnsKickReq struct {
 	res chan rtmpServerAPIConnsKickRes
 }
 
+type rtmpServerAPIConnsRevokeReq struct {
+	user  string
+	token string
+	res   chan int
+}
+
 type rtmpServerParent interface {
 	logger.Writer
 }
 
 type rtmpServer struct {
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
+	authPauseAfterError       conf.StringDuration
 	readTimeout               conf.StringDuration
 	writeTimeout              conf.StringDuration
 	readBufferCount           int
@@ -61,28 +70,39 @@ type rtmpServer struct {
 	pathManager               *pathManager
 	parent                    rtmpServerParent
 
-	ctx       context.Context
-	ctxCancel func()
-	wg        sync.WaitGroup
-	ln        net.Listener
-	conns     map[*rtmpConn]struct{}
+	ctx        context.Context
+	ctxCancel  func()
+	wg         sync.WaitGroup
+	ln         net.Listener
+	certLoader *certLoader
+	conns      map[*rtmpConn]struct{}
 
 	// in
-	chConnClose    chan *rtmpConn
-	chAPIConnsList chan rtmpServerAPIConnsListReq
-	chAPIConnsKick chan rtmpServerAPIConnsKickReq
+	chConnClose      chan *rtmpConn
+	chAPIConnsList   chan rtmpServerAPIConnsListReq
+	chAPIConnsKick   chan rtmpServerAPIConnsKickReq
+	chAPIConnsRevoke chan rtmpServerAPIConnsRevokeReq
 }
 
 func newRTMPServer(
 	parentCtx context.Context,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
+	authPauseAfterError conf.StringDuration,
 	address string,
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
 	readBufferCount int,
+	ipFilter conf.IPFilter,
+	socketOptions conf.SocketOptions,
 	isTLS bool,
 	serverCert string,
 	serverKey string,
+	acmeManager *acmeManager,
+	tlsMinVersion conf.TLSVersion,
+	tlsCipherSuites conf.TLSCipherSuites,
+	tlsLogHandshakes bool,
 	rtspAddress string,
 	runOnConnect string,
 	runOnConnectRestart bool,
@@ -91,27 +111,40 @@ func newRTMPServer(
 	pathManager *pathManager,
 	parent rtmpServerParent,
 ) (*rtmpServer, error) {
-	ln, err := func() (net.Listener, error) {
-		if !isTLS {
-			return net.Listen(restrictNetwork("tcp", address))
-		}
+	var tlsConfig *tls.Config
+	var certLoader *certLoader
 
-		cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+	if isTLS {
+		var err error
+		tlsConfig, certLoader, err = loadTLSConfig(serverCert, serverKey, acmeManager,
+			tlsMinVersion, tlsCipherSuites, tlsLogHandshakes, parent)
 		if err != nil {
 			return nil, err
 		}
+	}
 
-		network, address := restrictNetwork("tcp", address)
-		return tls.Listen(network, address, &tls.Config{Certificates: []tls.Certificate{cert}})
-	}()
+	ln, err := listenMultiple("tcp", address)
 	if err != nil {
+		if certLoader != nil {
+			certLoader.close()
+		}
 		return nil, err
 	}
 
+	ln = newIPFilterListener(ln, ipFilter, parent)
+	ln = newSocketOptionsListener(ln, socketOptions)
+
+	if tlsConfig != nil {
+		ln = tls.NewListener(ln, tlsConfig)
+	}
+
 	ctx, ctxCancel := context.WithCancel(parentCtx)
 
 	s := &rtmpServer{
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
+		authPauseAfterError:       authPauseAfterError,
 		readTimeout:               readTimeout,
 		writeTimeout:              writeTimeout,
 		readBufferCount:           readBufferCount,
@@ -126,10 +159,12 @@ func newRTMPServer(
 		ctx:                       ctx,
 		ctxCancel:                 ctxCancel,
 		ln:                        ln,
+		certLoader:                certLoader,
 		conns:                     make(map[*rtmpConn]struct{}),
 		chConnClose:               make(chan *rtmpConn),
 		chAPIConnsList:            make(chan rtmpServerAPIConnsListReq),
 		chAPIConnsKick:            make(chan rtmpServerAPIConnsKickReq),
+		chAPIConnsRevoke:          make(chan rtmpServerAPIConnsRevokeReq),
 	}
 
 	s.Log(logger.Info, "listener opened on %s", address)
@@ -158,6 +193,10 @@ func (s *rtmpServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *rtmpServer) run() {
@@ -197,10 +236,22 @@ outer:
 			break outer
 
 		case nconn := <-connNew:
+			if s.accessList != nil {
+				err := s.accessList.checkConn(nconn.RemoteAddr().(*net.TCPAddr).IP)
+				if err != nil {
+					s.Log(logger.Info, "[conn %v] %v", nconn.RemoteAddr(), err)
+					nconn.Close()
+					continue
+				}
+			}
+
 			c := newRTMPConn(
 				s.ctx,
 				s.isTLS,
 				s.externalAuthenticationURL,
+				s.authBanList,
+				s.accessList,
+				s.authPauseAfterError,
 				s.rtspAddress,
 				s.readTimeout,
 				s.writeTimeout,
@@ -260,6 +311,18 @@ outer:
 				req.res <- rtmpServerAPIConnsKickRes{fmt.Errorf("not found")}
 			}
 
+		case req := <-s.chAPIConnsRevoke:
+			n := 0
+			for c := range s.conns {
+				if c.safeIdentity().matches(req.user, req.token) {
+					c.Log(logger.Info, "authorization has been revoked")
+					delete(s.conns, c)
+					c.close()
+					n++
+				}
+			}
+			req.res <- n
+
 		case <-s.ctx.Done():
 			break outer
 		}
@@ -297,6 +360,23 @@ func (s *rtmpServer) apiConnsList() rtmpServerAPIConnsListRes {
 	}
 }
 
+// apiConnsRevoke is called by api.
+func (s *rtmpServer) apiConnsRevoke(user string, token string) int {
+	req := rtmpServerAPIConnsRevokeReq{
+		user:  user,
+		token: token,
+		res:   make(chan int),
+	}
+
+	select {
+	case s.chAPIConnsRevoke <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return 0
+	}
+}
+
 // apiConnsKick is called by api.
 func (s *rtmpServer) apiConnsKick(id string) rtmpServerAPIConnsKickRes {
 	req := rtmpServerAPIConnsKickReq{
@@ -14,6 +14,7 @@ import (
 
 	"github.com/aler9/mediamtx/internal/rtmp"
 	"github.com/aler9/mediamtx/internal/rtmp/message"
+	"github.com/aler9/mediamtx/internal/rtmp/tracks"
 )
 
 func TestRTMPServerRunOnConnect(t *testing.T) {
@@ -181,6 +182,108 @@ func TestRTMPServerPublishRead(t *testing.T) {
 	}
 }
 
+func TestRTMPServerPublishReadH265(t *testing.T) {
+	p, ok := newInstance("rtspDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	u, err := url.Parse("rtmp://127.0.0.1:1935/mystream")
+	require.NoError(t, err)
+
+	nconn1, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer nconn1.Close()
+	conn1 := rtmp.NewConn(nconn1)
+
+	err = conn1.InitializeClient(u, true)
+	require.NoError(t, err)
+
+	vps := []byte{
+		0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x40,
+		0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+		0x03, 0x00, 0x00, 0x03, 0x00, 0x7b, 0xac, 0x09,
+	}
+	sps := []byte{
+		0x42, 0x01, 0x01, 0x01, 0x40, 0x00, 0x00, 0x03,
+		0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+		0x03, 0x00, 0x7b, 0xa0, 0x03, 0xc0, 0x80, 0x11,
+		0x07, 0xcb, 0x96, 0xb4, 0xa4, 0x25, 0x92, 0xe3,
+		0x01, 0x6a, 0x02, 0x02, 0x02, 0x08, 0x00, 0x00,
+		0x03, 0x00, 0x08, 0x00, 0x00, 0x03, 0x01, 0xe3,
+		0x00, 0x2e, 0xf2, 0x88, 0x00, 0x09, 0x89, 0x60,
+		0x00, 0x04, 0xc4, 0xb4, 0x20,
+	}
+	pps := []byte{0x44, 0x01, 0xc0, 0xf7, 0xc0, 0xcc, 0x90}
+
+	videoTrack := &formats.H265{
+		PayloadTyp: 96,
+		VPS:        vps,
+		SPS:        sps,
+		PPS:        pps,
+	}
+
+	err = conn1.WriteTracks(videoTrack, nil)
+	require.NoError(t, err)
+
+	time.Sleep(500 * time.Millisecond)
+
+	nconn2, err := net.Dial("tcp", u.Host)
+	require.NoError(t, err)
+	defer nconn2.Close()
+	conn2 := rtmp.NewConn(nconn2)
+
+	err = conn2.InitializeClient(u, false)
+	require.NoError(t, err)
+
+	videoTrack1, audioTrack1, err := conn2.ReadTracks()
+	require.NoError(t, err)
+	require.Equal(t, videoTrack, videoTrack1)
+	require.Nil(t, audioTrack1)
+
+	err = conn1.WriteMessage(&message.ExtendedCodedFrames{
+		ChunkStreamID:   message.VideoChunkStreamID,
+		MessageStreamID: 0x1000000,
+		FourCC:          message.FourCCHEVC,
+		Payload: []byte{
+			0x00, 0x00, 0x00, 0x04, 0x26, 0x01, 0xaf, 0x08, // IDR
+		},
+	})
+	require.NoError(t, err)
+
+	msg, err := conn2.ReadMessage()
+	require.NoError(t, err)
+	require.IsType(t, &message.ExtendedCodedFrames{}, msg)
+
+	// the decoder configuration is sent again when parameters change.
+	pps2 := []byte{0x44, 0x01, 0xc0, 0xf7, 0xc0, 0xcc, 0x91}
+
+	err = conn1.WriteMessage(&message.ExtendedCodedFrames{
+		ChunkStreamID:   message.VideoChunkStreamID,
+		MessageStreamID: 0x1000000,
+		FourCC:          message.FourCCHEVC,
+		DTS:             100 * time.Millisecond,
+		Payload: append(append([]byte{0x00, 0x00, 0x00, byte(len(pps2))}, pps2...),
+			0x00, 0x00, 0x00, 0x04, 0x26, 0x01, 0xaf, 0x08), // PPS + IDR
+	})
+	require.NoError(t, err)
+
+	msg, err = conn2.ReadMessage()
+	require.NoError(t, err)
+	config, err := tracks.H265Config(vps, sps, pps2)
+	require.NoError(t, err)
+	require.Equal(t, &message.ExtendedSequenceStart{
+		ChunkStreamID:   message.VideoChunkStreamID,
+		MessageStreamID: 0x1000000,
+		FourCC:          message.FourCCHEVC,
+		Config:          config,
+		DTS:             100 * time.Millisecond,
+	}, msg)
+}
+
 func TestRTMPServerAuth(t *testing.T) {
 	for _, ca := range []string{
 		"internal",
@@ -267,6 +370,84 @@ func TestRTMPServerAuth(t *testing.T) {
 	}
 }
 
+func TestRTMPServerStreamKey(t *testing.T) {
+	for _, ca := range []string{
+		"valid",
+		"invalid",
+		"missing",
+	} {
+		t.Run(ca, func(t *testing.T) {
+			p, ok := newInstance("rtspDisable: yes\n" +
+				"hlsDisable: yes\n" +
+				"webrtcDisable: yes\n" +
+				"paths:\n" +
+				"  live:\n" +
+				"    streamKey: mykey\n")
+			require.Equal(t, true, ok)
+			defer p.Close()
+
+			var publishURL string
+			switch ca {
+			case "valid":
+				publishURL = "rtmp://127.0.0.1:1935/live/mykey"
+
+			case "invalid":
+				publishURL = "rtmp://127.0.0.1:1935/live/otherkey"
+
+			case "missing":
+				publishURL = "rtmp://127.0.0.1:1935/live"
+			}
+
+			u1, err := url.Parse(publishURL)
+			require.NoError(t, err)
+
+			nconn1, err := net.Dial("tcp", u1.Host)
+			require.NoError(t, err)
+			defer nconn1.Close()
+			conn1 := rtmp.NewConn(nconn1)
+
+			err = conn1.InitializeClient(u1, true)
+			require.NoError(t, err)
+
+			videoTrack := &formats.H264{
+				PayloadTyp: 96,
+				SPS: []byte{
+					0x67, 0x64, 0x00, 0x0c, 0xac, 0x3b, 0x50, 0xb0,
+					0x4b, 0x42, 0x00, 0x00, 0x03, 0x00, 0x02, 0x00,
+					0x00, 0x03, 0x00, 0x3d, 0x08,
+				},
+				PPS: []byte{
+					0x68, 0xee, 0x3c, 0x80,
+				},
+				PacketizationMode: 1,
+			}
+
+			err = conn1.WriteTracks(videoTrack, nil)
+			require.NoError(t, err)
+
+			time.Sleep(500 * time.Millisecond)
+
+			u2, err := url.Parse("rtmp://127.0.0.1:1935/live")
+			require.NoError(t, err)
+
+			nconn2, err := net.Dial("tcp", u2.Host)
+			require.NoError(t, err)
+			defer nconn2.Close()
+			conn2 := rtmp.NewConn(nconn2)
+
+			err = conn2.InitializeClient(u2, false)
+			require.NoError(t, err)
+
+			_, _, err = conn2.ReadTracks()
+			if ca == "valid" {
+				require.NoError(t, err)
+			} else {
+				require.EqualError(t, err, "EOF")
+			}
+		})
+	}
+}
+
 func TestRTMPServerAuthFail(t *testing.T) {
 	t.Run("publish", func(t *testing.T) { //nolint:dupl
 		p, ok := newInstance("rtspDisable: yes\n" +
@@ -29,17 +29,20 @@ type rtmpSourceParent interface {
 type rtmpSource struct {
 	readTimeout  conf.StringDuration
 	writeTimeout conf.StringDuration
+	stats        *sourceStaticStats
 	parent       rtmpSourceParent
 }
 
 func newRTMPSource(
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
+	stats *sourceStaticStats,
 	parent rtmpSourceParent,
 ) *rtmpSource {
 	return &rtmpSource{
 		readTimeout:  readTimeout,
 		writeTimeout: writeTimeout,
+		stats:        stats,
 		parent:       parent,
 	}
 }
@@ -67,8 +70,17 @@ func (s *rtmpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 	defer cancel2()
 
 	nconn, err := func() (net.Conn, error) {
+		// RTMP doesn't provide a way to measure the RTT from the client side,
+		// therefore it is measured when establishing the TCP connection.
+		start := time.Now()
+		tcpConn, err := (&net.Dialer{}).DialContext(ctx2, "tcp", u.Host)
+		if err != nil {
+			return nil, err
+		}
+		s.stats.setRTT(time.Since(start))
+
 		if u.Scheme == "rtmp" {
-			return (&net.Dialer{}).DialContext(ctx2, "tcp", u.Host)
+			return tcpConn, nil
 		}
 
 		tlsConfig := &tls.Config{
@@ -88,7 +100,15 @@ func (s *rtmpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 			},
 		}
 
-		return (&tls.Dialer{Config: tlsConfig}).DialContext(ctx2, "tcp", u.Host)
+		tlsConn := tls.Client(tcpConn, tlsConfig)
+
+		err = tlsConn.HandshakeContext(ctx2)
+		if err != nil {
+			tcpConn.Close()
+			return nil, err
+		}
+
+		return tlsConn, nil
 	}()
 	if err != nil {
 		return err
@@ -207,8 +227,9 @@ func (s *rtmpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 }
 
 // apiSourceDescribe implements sourceStaticImpl.
-func (*rtmpSource) apiSourceDescribe() interface{} {
+func (s *rtmpSource) apiSourceDescribe() interface{} {
 	return struct {
 		Type string `json:"type"`
-	}{"rtmpSource"}
+		sourceStaticStatsAPI
+	}{"rtmpSource", s.stats.apiDescribe()}
 }
@@ -0,0 +1,57 @@
+package core
+
+import (
+	"sync"
+
+	"github.com/pion/rtp"
+)
+
+// initial size of buffers, that is enough to contain packets sent over UDP.
+const rtpBufferSize = 1500
+
+var rtpBufferPool = sync.Pool{
+	New: func() interface{} {
+		return &rtpBuffer{buf: make([]byte, rtpBufferSize)}
+	},
+}
+
+// rtpBuffer is a pooled buffer that contains a serialized RTP packet.
+// It avoids allocating a buffer for each packet that is written by readers.
+// It can be used only by readers that write packets synchronously, like multicast outputs
+// and captures, since buffers are not reference counted. Payloads of received packets are
+// allocated by the RTSP library and units are shared between readers, therefore they are not pooled.
+// Packets that are shared by the shards of the RTSP fan-out are pooled and reference counted
+// by streamRTSPFanoutPacket.
+type rtpBuffer struct {
+	buf []byte
+}
+
+// rtpBufferMarshal serializes a RTP packet into a pooled buffer.
+// The buffer must be released when it's not used anymore.
+func rtpBufferMarshal(pkt *rtp.Packet) (*rtpBuffer, error) {
+	b := rtpBufferPool.Get().(*rtpBuffer)
+
+	size := pkt.MarshalSize()
+	if cap(b.buf) < size {
+		b.buf = make([]byte, size)
+	}
+	b.buf = b.buf[:size]
+
+	n, err := pkt.MarshalTo(b.buf)
+	if err != nil {
+		b.release()
+		return nil, err
+	}
+
+	b.buf = b.buf[:n]
+	return b, nil
+}
+
+func (b *rtpBuffer) bytes() []byte {
+	return b.buf
+}
+
+// release returns the buffer to the pool. The buffer can't be used anymore.
+func (b *rtpBuffer) release() {
+	rtpBufferPool.Put(b)
+}
@@ -0,0 +1,64 @@
+package core
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRTPBuffer(t *testing.T) {
+	for _, ca := range []struct {
+		name    string
+		payload []byte
+	}{
+		{
+			"small",
+			[]byte{0x01, 0x02, 0x03, 0x04},
+		},
+		{
+			"bigger than the initial size",
+			bytes.Repeat([]byte{0x01}, rtpBufferSize*2),
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			pkt := &rtp.Packet{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: 946,
+					Timestamp:      1287987768,
+					SSRC:           0x9dbb7812,
+				},
+				Payload: ca.payload,
+			}
+
+			byts, err := pkt.Marshal()
+			require.NoError(t, err)
+
+			b, err := rtpBufferMarshal(pkt)
+			require.NoError(t, err)
+			require.Equal(t, byts, b.bytes())
+			b.release()
+		})
+	}
+}
+
+func BenchmarkRTPBufferMarshal(b *testing.B) {
+	pkt := &rtp.Packet{
+		Header: rtp.Header{
+			Version:     2,
+			PayloadType: 96,
+		},
+		Payload: bytes.Repeat([]byte{0x01}, 1000),
+	}
+
+	b.ReportAllocs()
+
+	for i := 0; i < b.N; i++ {
+		buf, _ := rtpBufferMarshal(pkt)
+		buf.release()
+	}
+}
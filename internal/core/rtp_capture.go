@@ -0,0 +1,199 @@
+package core
+
+import (
+	"bufio"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/pion/rtcp"
+	"github.com/pion/rtp"
+)
+
+// rtpCaptureFilePattern is the pattern of the names of capture files.
+const rtpCaptureFilePattern = "capture_*.rtpdump"
+
+// rtpCaptureCleanup deletes the oldest captures of a directory,
+// in order to leave room for a new one without exceeding maxFiles.
+func rtpCaptureCleanup(directory string, maxFiles int) error {
+	names, err := filepath.Glob(filepath.Join(directory, rtpCaptureFilePattern))
+	if err != nil {
+		return err
+	}
+
+	if len(names) < maxFiles {
+		return nil
+	}
+
+	type entry struct {
+		name    string
+		modTime time.Time
+	}
+
+	entries := make([]entry, 0, len(names))
+	for _, name := range names {
+		fi, err := os.Stat(name)
+		if err != nil {
+			continue
+		}
+		entries = append(entries, entry{name, fi.ModTime()})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].modTime.Before(entries[j].modTime)
+	})
+
+	for len(entries) >= maxFiles {
+		err := os.Remove(entries[0].name)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		entries = entries[1:]
+	}
+
+	return nil
+}
+
+// rtpCapture writes RTP and RTCP packets into a file with the rtpdump format,
+// that can be opened with Wireshark or replayed with rtpplay.
+type rtpCapture struct {
+	maxSize uint64
+
+	mutex  sync.Mutex
+	f      *os.File
+	bw     *bufio.Writer
+	start  time.Time
+	size   uint64
+	isFull bool
+
+	// out
+	full chan struct{}
+}
+
+func newRTPCapture(f *os.File, maxSize uint64) (*rtpCapture, error) {
+	c := &rtpCapture{
+		maxSize: maxSize,
+		f:       f,
+		bw:      bufio.NewWriter(f),
+		start:   time.Now(),
+		full:    make(chan struct{}),
+	}
+
+	_, err := c.bw.WriteString("#!rtpplay1.0 0.0.0.0/0\n")
+	if err != nil {
+		return nil, err
+	}
+
+	// RD_hdr_t: start time, source address, port and padding
+	var hdr [16]byte
+	binary.BigEndian.PutUint32(hdr[0:], uint32(c.start.Unix()))
+	binary.BigEndian.PutUint32(hdr[4:], uint32(c.start.Nanosecond()/1000))
+
+	_, err = c.bw.Write(hdr[:])
+	if err != nil {
+		return nil, err
+	}
+
+	c.size = uint64(c.bw.Buffered())
+
+	return c, nil
+}
+
+func (c *rtpCapture) close() error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	err := c.bw.Flush()
+	if err != nil {
+		c.f.Close()
+		return err
+	}
+
+	return c.f.Close()
+}
+
+func (c *rtpCapture) write(byts []byte, isRTP bool) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.isFull {
+		return
+	}
+
+	// RD_packet_t: record length, packet length (zero for RTCP) and offset in milliseconds
+	var hdr [8]byte
+
+	if c.size+uint64(len(hdr)+len(byts)) > c.maxSize {
+		c.isFull = true
+		close(c.full)
+		return
+	}
+
+	binary.BigEndian.PutUint16(hdr[0:], uint16(len(hdr)+len(byts)))
+	if isRTP {
+		binary.BigEndian.PutUint16(hdr[2:], uint16(len(byts)))
+	}
+	binary.BigEndian.PutUint32(hdr[4:], uint32(time.Since(c.start).Milliseconds()))
+
+	c.bw.Write(hdr[:])
+	c.bw.Write(byts)
+	c.size += uint64(len(hdr) + len(byts))
+}
+
+func (c *rtpCapture) writePacketRTP(pkt *rtp.Packet) {
+	b, err := rtpBufferMarshal(pkt)
+	if err != nil {
+		return
+	}
+	c.write(b.bytes(), true)
+	b.release()
+}
+
+func (c *rtpCapture) writePacketRTCP(pkt rtcp.Packet) {
+	byts, err := pkt.Marshal()
+	if err != nil {
+		return
+	}
+	c.write(byts, false)
+}
+
+// streamCaptures contains the captures of a stream.
+type streamCaptures struct {
+	mutex    sync.RWMutex
+	captures map[*rtpCapture]struct{}
+}
+
+func (sc *streamCaptures) add(c *rtpCapture) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	sc.captures[c] = struct{}{}
+}
+
+func (sc *streamCaptures) remove(c *rtpCapture) {
+	sc.mutex.Lock()
+	defer sc.mutex.Unlock()
+	delete(sc.captures, c)
+}
+
+func (sc *streamCaptures) writePacketsRTP(pkts []*rtp.Packet) {
+	sc.mutex.RLock()
+	defer sc.mutex.RUnlock()
+
+	for c := range sc.captures {
+		for _, pkt := range pkts {
+			c.writePacketRTP(pkt)
+		}
+	}
+}
+
+func (sc *streamCaptures) writePacketRTCP(pkt rtcp.Packet) {
+	sc.mutex.RLock()
+	defer sc.mutex.RUnlock()
+
+	for c := range sc.captures {
+		c.writePacketRTCP(pkt)
+	}
+}
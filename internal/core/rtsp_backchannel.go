@@ -0,0 +1,344 @@
+package core
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/base"
+	"github.com/bluenviron/gortsplib/v3/pkg/headers"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+)
+
+// option of the Require header that enables the ONVIF audio backchannel.
+const rtspBackchannelRequire = "www.onvif.org/ver20/backchannel"
+
+// maximum size of the header of a RTSP request that is inspected by rtspBackchannelConn.
+const rtspBackchannelMaxHeaderSize = 64 * 1024
+
+// rtspBackchannelRequired checks whether a request requires the backchannel.
+func rtspBackchannelRequired(h base.Header) bool {
+	for _, v := range h["Require"] {
+		for _, opt := range strings.Split(v, ",") {
+			if strings.TrimSpace(opt) == rtspBackchannelRequire {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// rtspBackchannelSDP generates the session description returned to readers of the backchannel.
+// It's generated in the same way as gortsplib does, except for the direction of medias,
+// that is kept in order to allow readers to find the backchannel.
+func rtspBackchannelSDP(medias media.Medias, u *url.URL) []byte {
+	ret := make(media.Medias, len(medias))
+	for i, medi := range medias {
+		mc := &media.Media{
+			Type:      medi.Type,
+			Direction: medi.Direction,
+			Formats:   medi.Formats,
+			Control:   "trackID=" + strconv.FormatInt(int64(i), 10),
+		}
+
+		cu, _ := mc.URL(u)
+		mc.Control = cu.String()
+
+		ret[i] = mc
+	}
+
+	byts, _ := ret.Marshal(false).Marshal()
+	return byts
+}
+
+// rtspBackchannelTrackID returns the ID of the track that is setupped by a SETUP request.
+func rtspBackchannelTrackID(u *url.URL) (int, bool) {
+	pathAndQuery, ok := u.RTSPPathAndQuery()
+	if !ok {
+		return 0, false
+	}
+
+	i := strings.LastIndex(pathAndQuery, "/trackID=")
+	if i < 0 {
+		return 0, true
+	}
+
+	id, err := strconv.ParseUint(pathAndQuery[i+len("/trackID="):], 10, 31)
+	if err != nil {
+		return 0, false
+	}
+
+	return int(id), true
+}
+
+// rtspBackchannelInterleavedChannel returns the interleaved channel requested by a SETUP request.
+func rtspBackchannelInterleavedChannel(h base.Header) (int, bool) {
+	var ths headers.Transports
+	err := ths.Unmarshal(h["Transport"])
+	if err != nil {
+		return 0, false
+	}
+
+	for _, th := range ths {
+		if th.Protocol == headers.TransportProtocolTCP && th.InterleavedIDs != nil {
+			return th.InterleavedIDs[0], true
+		}
+	}
+
+	return 0, false
+}
+
+type rtspBackchannelConnState int
+
+const (
+	rtspBackchannelConnStateStart rtspBackchannelConnState = iota
+	rtspBackchannelConnStateFrameHeader
+	rtspBackchannelConnStateFramePayload
+	rtspBackchannelConnStateRequestHeader
+	rtspBackchannelConnStateRequestBody
+)
+
+// rtspBackchannelConn is a net.Conn that inspects data received from RTSP clients,
+// in order to read RTP packets that readers send through the backchannel.
+// gortsplib discards packets sent by readers, therefore they are read before reaching it.
+type rtspBackchannelConn struct {
+	net.Conn
+
+	mutex    sync.Mutex
+	channel  int
+	onPacket func([]byte)
+
+	// parser state, accessed by Read() only.
+	state     rtspBackchannelConnState
+	header    []byte
+	remaining int
+	frameCb   func([]byte)
+	payload   []byte
+	broken    bool
+}
+
+// setBackchannel sets the callback that is called when a RTP packet is received
+// on the given interleaved channel. The payload can't be used after the callback returns.
+func (c *rtspBackchannelConn) setBackchannel(channel int, onPacket func([]byte)) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.channel = channel
+	c.onPacket = onPacket
+}
+
+// clearBackchannel removes the callback set by setBackchannel.
+func (c *rtspBackchannelConn) clearBackchannel() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.onPacket = nil
+}
+
+func (c *rtspBackchannelConn) callback(channel int) func([]byte) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if c.onPacket == nil || c.channel != channel {
+		return nil
+	}
+	return c.onPacket
+}
+
+// Read implements net.Conn.
+func (c *rtspBackchannelConn) Read(p []byte) (int, error) {
+	n, err := c.Conn.Read(p)
+	if n > 0 && !c.broken {
+		c.process(p[:n])
+	}
+	return n, err
+}
+
+func (c *rtspBackchannelConn) process(buf []byte) {
+	for len(buf) > 0 {
+		switch c.state {
+		case rtspBackchannelConnStateStart:
+			c.header = c.header[:0]
+			if buf[0] == '$' {
+				c.state = rtspBackchannelConnStateFrameHeader
+			} else {
+				c.state = rtspBackchannelConnStateRequestHeader
+			}
+
+		case rtspBackchannelConnStateFrameHeader:
+			n := 4 - len(c.header)
+			if n > len(buf) {
+				n = len(buf)
+			}
+			c.header = append(c.header, buf[:n]...)
+			buf = buf[n:]
+
+			if len(c.header) == 4 {
+				c.remaining = int(binary.BigEndian.Uint16(c.header[2:]))
+				c.frameCb = c.callback(int(c.header[1]))
+				c.payload = c.payload[:0]
+				c.state = rtspBackchannelConnStateFramePayload
+				c.processFrameEnd()
+			}
+
+		case rtspBackchannelConnStateFramePayload:
+			n := c.remaining
+			if n > len(buf) {
+				n = len(buf)
+			}
+			if c.frameCb != nil {
+				c.payload = append(c.payload, buf[:n]...)
+			}
+			buf = buf[n:]
+			c.remaining -= n
+			c.processFrameEnd()
+
+		case rtspBackchannelConnStateRequestHeader:
+			c.header = append(c.header, buf[0])
+			buf = buf[1:]
+
+			if len(c.header) >= 4 && string(c.header[len(c.header)-4:]) == "\r\n\r\n" {
+				c.remaining = rtspBackchannelContentLength(c.header)
+				c.state = rtspBackchannelConnStateRequestBody
+				if c.remaining == 0 {
+					c.state = rtspBackchannelConnStateStart
+				}
+			} else if len(c.header) > rtspBackchannelMaxHeaderSize {
+				// the stream can't be followed anymore; gortsplib closes the connection anyway.
+				c.broken = true
+				return
+			}
+
+		case rtspBackchannelConnStateRequestBody:
+			n := c.remaining
+			if n > len(buf) {
+				n = len(buf)
+			}
+			buf = buf[n:]
+			c.remaining -= n
+			if c.remaining == 0 {
+				c.state = rtspBackchannelConnStateStart
+			}
+		}
+	}
+}
+
+func (c *rtspBackchannelConn) processFrameEnd() {
+	if c.remaining != 0 {
+		return
+	}
+
+	if c.frameCb != nil {
+		c.frameCb(c.payload)
+		c.frameCb = nil
+	}
+
+	c.state = rtspBackchannelConnStateStart
+}
+
+func rtspBackchannelContentLength(header []byte) int {
+	for _, line := range strings.Split(string(header), "\r\n") {
+		i := strings.IndexByte(line, ':')
+		if i < 0 || !strings.EqualFold(strings.TrimSpace(line[:i]), "Content-Length") {
+			continue
+		}
+
+		v, err := strconv.ParseUint(strings.TrimSpace(line[i+1:]), 10, 31)
+		if err != nil {
+			return 0
+		}
+		return int(v)
+	}
+	return 0
+}
+
+// rtspBackchannelListener is a net.Listener that wraps connections into rtspBackchannelConn.
+type rtspBackchannelListener struct {
+	net.Listener
+}
+
+// Accept implements net.Listener.
+func (l *rtspBackchannelListener) Accept() (net.Conn, error) {
+	conn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+	return &rtspBackchannelConn{Conn: conn}, nil
+}
+
+// rtspBackchannelConnOf returns the rtspBackchannelConn that is under a connection, if any.
+// Encrypted connections can't be inspected, therefore nil is returned for them.
+func rtspBackchannelConnOf(nconn net.Conn) *rtspBackchannelConn {
+	if pc, ok := nconn.(*rtspPacedConn); ok {
+		nconn = pc.Conn
+	}
+
+	bc, _ := nconn.(*rtspBackchannelConn)
+	return bc
+}
+
+// rtspSourceBackchannelConn is the connection of a RTSP source, that allows
+// to write RTP packets to the backchannel of the camera while the stream is being read.
+// gortsplib doesn't allow to write packets while reading, therefore they are written
+// as interleaved frames directly, and writes are serialized with the ones of gortsplib.
+type rtspSourceBackchannelConn struct {
+	net.Conn
+
+	writeTimeout time.Duration
+	mutex        sync.Mutex
+	buf          []byte
+}
+
+func rtspSourceBackchannelDial(
+	writeTimeout time.Duration,
+	onConn func(*rtspSourceBackchannelConn),
+) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		nconn, err := (&net.Dialer{}).DialContext(ctx, network, address)
+		if err != nil {
+			return nil, err
+		}
+
+		c := &rtspSourceBackchannelConn{
+			Conn:         nconn,
+			writeTimeout: writeTimeout,
+		}
+		onConn(c)
+
+		return c, nil
+	}
+}
+
+// Write implements net.Conn.
+func (c *rtspSourceBackchannelConn) Write(p []byte) (int, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.Conn.Write(p)
+}
+
+// writeFrame writes an interleaved frame.
+func (c *rtspSourceBackchannelConn) writeFrame(channel int, payload []byte) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	fr := base.InterleavedFrame{
+		Channel: channel,
+		Payload: payload,
+	}
+
+	size := fr.MarshalSize()
+	if cap(c.buf) < size {
+		c.buf = make([]byte, size)
+	}
+	n, err := fr.MarshalTo(c.buf[:size])
+	if err != nil {
+		return err
+	}
+
+	c.Conn.SetWriteDeadline(time.Now().Add(c.writeTimeout))
+	_, err = c.Conn.Write(c.buf[:n])
+	return err
+}
@@ -7,29 +7,30 @@ import (
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
-	"github.com/bluenviron/gortsplib/v3/pkg/auth"
 	"github.com/bluenviron/gortsplib/v3/pkg/base"
 	"github.com/bluenviron/gortsplib/v3/pkg/headers"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/google/uuid"
 
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
-const (
-	rtspConnPauseAfterAuthError = 2 * time.Second
-)
-
 type rtspConnParent interface {
 	logger.Writer
 }
 
 type rtspConn struct {
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
 	rtspAddress               string
 	authMethods               []headers.AuthMethod
+	authPauseAfterError       conf.StringDuration
+	authRealm                 string
+	authStatusText            string
 	readTimeout               conf.StringDuration
 	runOnConnect              string
 	runOnConnectRestart       bool
@@ -41,16 +42,22 @@ type rtspConn struct {
 	uuid          uuid.UUID
 	created       time.Time
 	onConnectCmd  *externalcmd.Cmd
-	authUser      string
-	authPass      string
-	authValidator *auth.Validator
+	authValidator *auth.RTSPValidator
+	authBasicOnly bool
 	authFailures  int
+	requestMethod base.Method
+	requestStart  time.Time
 }
 
 func newRTSPConn(
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
 	rtspAddress string,
 	authMethods []headers.AuthMethod,
+	authPauseAfterError conf.StringDuration,
+	authRealm string,
+	authStatusText string,
 	readTimeout conf.StringDuration,
 	runOnConnect string,
 	runOnConnectRestart bool,
@@ -61,8 +68,13 @@ func newRTSPConn(
 ) *rtspConn {
 	c := &rtspConn{
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
 		rtspAddress:               rtspAddress,
 		authMethods:               authMethods,
+		authPauseAfterError:       authPauseAfterError,
+		authRealm:                 authRealm,
+		authStatusText:            authStatusText,
 		readTimeout:               readTimeout,
 		runOnConnect:              runOnConnect,
 		runOnConnectRestart:       runOnConnectRestart,
@@ -78,7 +90,7 @@ func newRTSPConn(
 
 	if c.runOnConnect != "" {
 		c.Log(logger.Info, "runOnConnect command started")
-		_, port, _ := net.SplitHostPort(c.rtspAddress)
+		port := listenAddressPort(c.rtspAddress)
 		c.onConnectCmd = externalcmd.NewCmd(
 			c.externalCmdPool,
 			c.runOnConnect,
@@ -112,16 +124,88 @@ func (c *rtspConn) ip() net.IP {
 	return c.conn.NetConn().RemoteAddr().(*net.TCPAddr).IP
 }
 
+// rtspRequestUser returns the user that is contained in the Authorization header of a request.
+func rtspRequestUser(req *base.Request) string {
+	var h headers.Authorization
+	err := h.Unmarshal(req.Header["Authorization"])
+	if err != nil {
+		return ""
+	}
+
+	if h.Method == headers.AuthBasic {
+		return h.BasicUser
+	}
+
+	if h.DigestValues.Username != nil {
+		return *h.DigestValues.Username
+	}
+
+	return ""
+}
+
 func (c *rtspConn) authenticate(
 	path string,
 	query string,
 	pathIPs []fmt.Stringer,
 	pathUser conf.Credential,
 	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
+	isPublishing bool,
+	req *base.Request,
+	baseURL *url.URL,
+) (authIdentity, error) {
+	identity, err := c.doAuthenticate(path, query, pathIPs, pathUser, pathPass, pathPermissions, isPublishing, req, baseURL)
+
+	if c.authStatusText != "" {
+		switch terr := err.(type) {
+		case pathErrAuthCritical:
+			terr.response.StatusMessage = c.authStatusText
+
+		case pathErrAuthNotCritical:
+			terr.response.StatusMessage = c.authStatusText
+		}
+	}
+
+	return identity, err
+}
+
+func (c *rtspConn) doAuthenticate(
+	path string,
+	query string,
+	pathIPs []fmt.Stringer,
+	pathUser conf.Credential,
+	pathPass conf.Credential,
+	pathPermissions conf.PathPermissions,
 	isPublishing bool,
 	req *base.Request,
 	baseURL *url.URL,
-) error {
+) (authIdentity, error) {
+	var identity authIdentity
+
+	if c.authBanList != nil {
+		err := c.authBanList.check(c.ip())
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+				response: &base.Response{
+					StatusCode: base.StatusUnauthorized,
+				},
+			}
+		}
+	}
+
+	if c.accessList != nil {
+		err := c.accessList.checkAction(c.ip(), isPublishing)
+		if err != nil {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+				response: &base.Response{
+					StatusCode: base.StatusUnauthorized,
+				},
+			}
+		}
+	}
+
 	if c.externalAuthenticationURL != "" {
 		username := ""
 		password := ""
@@ -153,7 +237,7 @@ func (c *rtspConn) authenticate(
 			// 4) with password and username
 			// therefore we must allow up to 3 failures
 			if c.authFailures > 3 {
-				return pathErrAuthCritical{
+				return authIdentity{}, pathErrAuthCritical{
 					message: "unauthorized: " + err.Error(),
 					response: &base.Response{
 						StatusCode: base.StatusUnauthorized,
@@ -161,8 +245,8 @@ func (c *rtspConn) authenticate(
 				}
 			}
 
-			v := "IPCAM"
-			return pathErrAuthNotCritical{
+			v := c.authRealm
+			return authIdentity{}, pathErrAuthNotCritical{
 				message: "unauthorized: " + err.Error(),
 				response: &base.Response{
 					StatusCode: base.StatusUnauthorized,
@@ -175,12 +259,15 @@ func (c *rtspConn) authenticate(
 				},
 			}
 		}
+
+		// credentials and the query have been verified by the external service.
+		identity = newAuthIdentity(username, query)
 	}
 
 	if pathIPs != nil {
 		ip := c.ip()
-		if !ipEqualOrInRange(ip, pathIPs) {
-			return pathErrAuthCritical{
+		if !auth.IPEqualOrInRange(ip, pathIPs) {
+			return authIdentity{}, pathErrAuthCritical{
 				message: fmt.Sprintf("IP '%s' not allowed", ip),
 				response: &base.Response{
 					StatusCode: base.StatusUnauthorized,
@@ -189,48 +276,110 @@ func (c *rtspConn) authenticate(
 		}
 	}
 
-	if pathUser != "" {
-		// reset authValidator every time the credentials change
-		if c.authValidator == nil || c.authUser != string(pathUser) || c.authPass != string(pathPass) {
-			c.authUser = string(pathUser)
-			c.authPass = string(pathPass)
-			c.authValidator = auth.NewValidator(string(pathUser), string(pathPass), c.authMethods)
+	var err error
+	credentialsVerified := false
+
+	switch {
+	case pathUser != "":
+		if !c.validator(pathUser.IsHashed() || pathPass.IsHashed()).
+			Validate(req, baseURL, pathUser, pathPass) {
+			err = auth.ErrInvalidCredentials
+		} else {
+			credentialsVerified = true
 		}
 
-		err := c.authValidator.ValidateRequest(req, baseURL)
-		if err != nil {
-			c.authFailures++
+	case len(pathPermissions) != 0:
+		basicOnly := false
+		for _, perm := range pathPermissions {
+			if perm.User.IsHashed() || perm.Pass.IsHashed() {
+				basicOnly = true
+			}
+		}
 
-			// VLC with login prompt sends 4 requests:
-			// 1) without credentials
-			// 2) with password but without username
-			// 3) without credentials
-			// 4) with password and username
-			// therefore we must allow up to 3 failures
-			if c.authFailures > 3 {
-				return pathErrAuthCritical{
-					message: "unauthorized: " + err.Error(),
-					response: &base.Response{
-						StatusCode: base.StatusUnauthorized,
-					},
+		validator := c.validator(basicOnly)
+
+		action := auth.ActionRead
+		if isPublishing {
+			action = auth.ActionPublish
+		}
+
+		err = auth.Check(pathPermissions, auth.Request{
+			IP:       c.ip(),
+			Action:   action,
+			Protocol: auth.ProtocolRTSP,
+			CheckCredentials: func(user conf.Credential, pass conf.Credential) bool {
+				if validator.Validate(req, baseURL, user, pass) {
+					credentialsVerified = true
+					return true
 				}
+				return false
+			},
+		})
+		if err != nil && err != auth.ErrInvalidCredentials {
+			return authIdentity{}, pathErrAuthCritical{
+				message: err.Error(),
+				response: &base.Response{
+					StatusCode: base.StatusUnauthorized,
+				},
 			}
+		}
+	}
 
-			return pathErrAuthNotCritical{
+	if err != nil {
+		c.authFailures++
+
+		// VLC with login prompt sends 4 requests:
+		// 1) without credentials
+		// 2) with password but without username
+		// 3) without credentials
+		// 4) with password and username
+		// therefore we must allow up to 3 failures
+		if c.authFailures > 3 {
+			return authIdentity{}, pathErrAuthCritical{
+				message: "unauthorized: " + err.Error(),
 				response: &base.Response{
 					StatusCode: base.StatusUnauthorized,
-					Header: base.Header{
-						"WWW-Authenticate": c.authValidator.Header(),
-					},
 				},
 			}
 		}
 
+		return authIdentity{}, pathErrAuthNotCritical{
+			response: &base.Response{
+				StatusCode: base.StatusUnauthorized,
+				Header: base.Header{
+					"WWW-Authenticate": c.authValidator.Header(),
+				},
+			},
+		}
+	}
+
+	if pathUser != "" || len(pathPermissions) != 0 {
 		// login successful, reset authFailures
 		c.authFailures = 0
 	}
 
-	return nil
+	if credentialsVerified {
+		identity.user = rtspRequestUser(req)
+	}
+
+	return identity, nil
+}
+
+// validator returns the credentials validator, that is recreated when
+// the authentication methods change.
+// hashed credentials can be checked with the Basic method only.
+func (c *rtspConn) validator(basicOnly bool) *auth.RTSPValidator {
+	if c.authValidator == nil || c.authBasicOnly != basicOnly {
+		methods := c.authMethods
+		if basicOnly {
+			methods = []headers.AuthMethod{headers.AuthBasic}
+		}
+
+		c.authValidator = auth.NewRTSPValidator(methods, c.authRealm)
+		c.authBasicOnly = basicOnly
+	}
+
+	return c.authValidator
 }
 
 // onClose is called by rtspServer.
@@ -246,6 +395,8 @@ func (c *rtspConn) onClose(err error) {
 // onRequest is called by rtspServer.
 func (c *rtspConn) onRequest(req *base.Request) {
 	c.Log(logger.Debug, "[c->s] %v", req)
+	c.requestMethod = req.Method
+	c.requestStart = time.Now()
 }
 
 // OnResponse is called by rtspServer.
@@ -266,12 +417,16 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 	res := c.pathManager.describe(pathDescribeReq{
 		pathName: ctx.Path,
 		url:      ctx.Request.URL,
+		query:    ctx.Query,
+		protocol: "rtsp",
 		authenticate: func(
 			pathIPs []fmt.Stringer,
 			pathUser conf.Credential,
 			pathPass conf.Credential,
+			pathPermissions conf.PathPermissions,
 		) error {
-			return c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, false, ctx.Request, nil)
+			_, err := c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, pathPermissions, false, ctx.Request, nil)
+			return err
 		},
 	})
 
@@ -282,8 +437,12 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 			return terr.response, nil, nil
 
 		case pathErrAuthCritical:
+			if c.authBanList != nil {
+				c.authBanList.onFailure(c.ip())
+			}
+
 			// wait some seconds to stop brute force attacks
-			<-time.After(rtspConnPauseAfterAuthError)
+			<-time.After(time.Duration(c.authPauseAfterError))
 
 			return terr.response, nil, errors.New(terr.message)
 
@@ -292,6 +451,11 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 				StatusCode: base.StatusNotFound,
 			}, nil, res.err
 
+		case pathErrDisabled:
+			return &base.Response{
+				StatusCode: base.StatusServiceUnavailable,
+			}, nil, res.err
+
 		default:
 			return &base.Response{
 				StatusCode: base.StatusBadRequest,
@@ -308,7 +472,28 @@ func (c *rtspConn) onDescribe(ctx *gortsplib.ServerHandlerOnDescribeCtx,
 		}, nil, nil
 	}
 
+	if rtspBackchannelRequired(ctx.Request.Header) {
+		medias := res.stream.rtspFanout.backchannelMedias()
+		if medias == nil || rtspBackchannelConnOf(ctx.Conn.NetConn()) == nil {
+			// the connection is kept open, in order to allow clients to fall back to a regular session.
+			c.Log(logger.Info, "the backchannel is not available")
+			return &base.Response{
+				StatusCode: base.StatusOptionNotSupported,
+				Header: base.Header{
+					"Unsupported": base.HeaderValue{rtspBackchannelRequire},
+				},
+			}, nil, nil
+		}
+
+		// the session description is generated here since gortsplib
+		// doesn't include the direction of medias.
+		return &base.Response{
+			StatusCode: base.StatusOK,
+			Body:       rtspBackchannelSDP(medias, ctx.Request.URL),
+		}, nil, nil
+	}
+
 	return &base.Response{
 		StatusCode: base.StatusOK,
-	}, res.stream.rtspStream, nil
+	}, res.stream.rtspFanout.serverStream(), nil
 }
@@ -0,0 +1,95 @@
+package core
+
+import (
+	"crypto/tls"
+	"net"
+	"sync"
+	"time"
+)
+
+// amount of data that can be written without waiting, expressed as a duration at the maximum bitrate.
+const rtspPacedConnBurst = 50 * time.Millisecond
+
+// rtspPacedConn is a net.Conn that limits the rate at which data is written,
+// with a token bucket.
+type rtspPacedConn struct {
+	net.Conn
+
+	mutex  sync.Mutex
+	rate   float64 // bytes per second, zero means unlimited
+	tokens float64
+	last   time.Time
+}
+
+// setMaxBitrate sets the maximum bitrate, in bits per second.
+func (c *rtspPacedConn) setMaxBitrate(bitrate int) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.rate = float64(bitrate) / 8
+	c.tokens = c.burst()
+	c.last = time.Now()
+}
+
+func (c *rtspPacedConn) burst() float64 {
+	return c.rate * rtspPacedConnBurst.Seconds()
+}
+
+// Write implements net.Conn.
+func (c *rtspPacedConn) Write(p []byte) (int, error) {
+	c.wait(len(p))
+	return c.Conn.Write(p)
+}
+
+func (c *rtspPacedConn) wait(n int) {
+	c.mutex.Lock()
+
+	if c.rate == 0 {
+		c.mutex.Unlock()
+		return
+	}
+
+	now := time.Now()
+	c.tokens += now.Sub(c.last).Seconds() * c.rate
+	if burst := c.burst(); c.tokens > burst {
+		c.tokens = burst
+	}
+	c.last = now
+	c.tokens -= float64(n)
+
+	var wait time.Duration
+	if c.tokens < 0 {
+		wait = time.Duration(-c.tokens / c.rate * float64(time.Second))
+	}
+
+	c.mutex.Unlock()
+
+	if wait > 0 {
+		time.Sleep(wait)
+	}
+}
+
+// rtspPacedConnOf returns the rtspPacedConn that is under a connection, if any.
+func rtspPacedConnOf(nconn net.Conn) *rtspPacedConn {
+	if tc, ok := nconn.(*tls.Conn); ok {
+		nconn = tc.NetConn()
+	}
+
+	pc, _ := nconn.(*rtspPacedConn)
+	return pc
+}
+
+// rtspPacedListener is a net.Listener that returns rtspPacedConns.
+type rtspPacedListener struct {
+	net.Listener
+}
+
+// Accept implements net.Listener.
+func (l *rtspPacedListener) Accept() (net.Conn, error) {
+	nconn, err := l.Listener.Accept()
+	if err != nil {
+		return nil, err
+	}
+
+	return &rtspPacedConn{Conn: nconn}, nil
+}
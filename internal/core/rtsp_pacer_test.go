@@ -0,0 +1,40 @@
+package core
+
+import (
+	"net"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type discardConn struct {
+	net.Conn
+}
+
+func (discardConn) Write(p []byte) (int, error) {
+	return len(p), nil
+}
+
+func TestRTSPPacedConn(t *testing.T) {
+	c := &rtspPacedConn{Conn: discardConn{}}
+	buf := make([]byte, 500)
+
+	start := time.Now()
+	for i := 0; i < 10; i++ {
+		_, err := c.Write(buf)
+		require.NoError(t, err)
+	}
+	require.Less(t, time.Since(start), 50*time.Millisecond)
+
+	c.setMaxBitrate(80000) // 10000 bytes/s, burst of 500 bytes
+
+	start = time.Now()
+	for i := 0; i < 10; i++ {
+		_, err := c.Write(buf)
+		require.NoError(t, err)
+	}
+	elapsed := time.Since(start)
+	require.Greater(t, elapsed, 400*time.Millisecond)
+	require.Less(t, elapsed, 600*time.Millisecond)
+}
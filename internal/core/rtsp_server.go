@@ -2,8 +2,8 @@ package core
 
 import (
 	"context"
-	"crypto/tls"
 	"fmt"
+	"net"
 	"strings"
 	"sync"
 	"time"
@@ -12,12 +12,18 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/base"
 	"github.com/bluenviron/gortsplib/v3/pkg/headers"
 	"github.com/bluenviron/gortsplib/v3/pkg/liberrors"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
 
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+const (
+	// period of the check of RTSP sessions that didn't send keepalives.
+	rtspServerSessionsCheckPeriod = 1 * time.Second
+)
+
 type rtspServerAPIConnsListItem struct {
 	Created       time.Time `json:"created"`
 	RemoteAddr    string    `json:"remoteAddr"`
@@ -35,11 +41,13 @@ type rtspServerAPIConnsListRes struct {
 }
 
 type rtspServerAPISessionsListItem struct {
-	Created       time.Time `json:"created"`
-	RemoteAddr    string    `json:"remoteAddr"`
-	State         string    `json:"state"`
-	BytesReceived uint64    `json:"bytesReceived"`
-	BytesSent     uint64    `json:"bytesSent"`
+	Created       time.Time           `json:"created"`
+	RemoteAddr    string              `json:"remoteAddr"`
+	State         string              `json:"state"`
+	BytesReceived uint64              `json:"bytesReceived"`
+	BytesSent     uint64              `json:"bytesSent"`
+	Timeout       conf.StringDuration `json:"timeout"`
+	LastKeepalive time.Time           `json:"lastKeepalive"`
 }
 
 type rtspServerAPISessionsListData struct {
@@ -51,10 +59,27 @@ type rtspServerAPISessionsListRes struct {
 	err  error
 }
 
+type rtspServerAPIMulticastGroupsListItem struct {
+	Readers int `json:"readers"`
+}
+
+type rtspServerAPIMulticastGroupsListData struct {
+	Items map[string]rtspServerAPIMulticastGroupsListItem `json:"items"`
+}
+
+type rtspServerAPIMulticastGroupsListRes struct {
+	data *rtspServerAPIMulticastGroupsListData
+	err  error
+}
+
 type rtspServerAPISessionsKickRes struct {
 	err error
 }
 
+type rtspServerAPISessionsRedirectRes struct {
+	err error
+}
+
 type rtspServerParent interface {
 	logger.Writer
 }
@@ -77,8 +102,15 @@ func printAddresses(srv *gortsplib.Server) string {
 
 type rtspServer struct {
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
 	authMethods               []headers.AuthMethod
+	authPauseAfterError       conf.StringDuration
+	authRealm                 string
+	authStatusText            string
 	readTimeout               conf.StringDuration
+	sessionTimeout            conf.StringDuration
+	sessionTimeoutTCP         bool
 	isTLS                     bool
 	rtspAddress               string
 	protocols                 map[conf.Protocol]struct{}
@@ -89,23 +121,38 @@ type rtspServer struct {
 	pathManager               *pathManager
 	parent                    rtspServerParent
 
-	ctx       context.Context
-	ctxCancel func()
-	wg        sync.WaitGroup
-	srv       *gortsplib.Server
-	mutex     sync.RWMutex
-	conns     map[*gortsplib.ServerConn]*rtspConn
-	sessions  map[*gortsplib.ServerSession]*rtspSession
+	ctx        context.Context
+	ctxCancel  func()
+	wg         sync.WaitGroup
+	srv        *gortsplib.Server
+	certLoader *certLoader
+	tunnel     *rtspTunnelServer
+	mutex      sync.RWMutex
+	conns      map[*gortsplib.ServerConn]*rtspConn
+	sessions   map[*gortsplib.ServerSession]*rtspSession
+
+	// number of multicast readers of every path
+	multicastGroups map[string]int
 }
 
 func newRTSPServer(
 	parentCtx context.Context,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
 	address string,
 	authMethods []headers.AuthMethod,
+	authPauseAfterError conf.StringDuration,
+	authRealm string,
+	authStatusText string,
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
 	readBufferCount int,
+	sessionTimeout conf.StringDuration,
+	sessionTimeoutTCP bool,
+	ipFilter conf.IPFilter,
+	socketOptions conf.SocketOptions,
+	rtpSocketOptions conf.SocketOptions,
 	useUDP bool,
 	useMulticast bool,
 	rtpAddress string,
@@ -116,6 +163,11 @@ func newRTSPServer(
 	isTLS bool,
 	serverCert string,
 	serverKey string,
+	acmeManager *acmeManager,
+	tlsMinVersion conf.TLSVersion,
+	tlsCipherSuites conf.TLSCipherSuites,
+	tlsLogHandshakes bool,
+	tunnelAddress string,
 	rtspAddress string,
 	protocols map[conf.Protocol]struct{},
 	runOnConnect string,
@@ -129,8 +181,15 @@ func newRTSPServer(
 
 	s := &rtspServer{
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
 		authMethods:               authMethods,
+		authPauseAfterError:       authPauseAfterError,
+		authRealm:                 authRealm,
+		authStatusText:            authStatusText,
 		readTimeout:               readTimeout,
+		sessionTimeout:            sessionTimeout,
+		sessionTimeoutTCP:         sessionTimeoutTCP,
 		isTLS:                     isTLS,
 		rtspAddress:               rtspAddress,
 		protocols:                 protocols,
@@ -144,6 +203,7 @@ func newRTSPServer(
 		ctxCancel:                 ctxCancel,
 		conns:                     make(map[*gortsplib.ServerConn]*rtspConn),
 		sessions:                  make(map[*gortsplib.ServerSession]*rtspSession),
+		multicastGroups:           make(map[string]int),
 	}
 
 	s.srv = &gortsplib.Server{
@@ -167,21 +227,116 @@ func newRTSPServer(
 	}
 
 	if isTLS {
-		cert, err := tls.LoadX509KeyPair(serverCert, serverKey)
+		var err error
+		s.srv.TLSConfig, s.certLoader, err = loadTLSConfig(serverCert, serverKey, acmeManager,
+			tlsMinVersion, tlsCipherSuites, tlsLogHandshakes, s)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var tunnelListener *rtspTunnelListener
+
+	s.srv.Listen = func(_ string, address string) (net.Listener, error) {
+		ln, err := listenMultiple("tcp", address)
 		if err != nil {
 			return nil, err
 		}
 
-		s.srv.TLSConfig = &tls.Config{Certificates: []tls.Certificate{cert}}
+		ln = newIPFilterListener(ln, ipFilter, s)
+		ln = newSocketOptionsListener(ln, socketOptions)
+
+		if tunnelAddress != "" {
+			tunnelListener = newRTSPTunnelListener(ln)
+			ln = tunnelListener
+		}
+
+		// plain connections are inspected in order to read packets sent through the backchannel.
+		if !isTLS {
+			ln = &rtspBackchannelListener{Listener: ln}
+		}
+
+		// connections are wrapped in order to allow pacing of readers.
+		return &rtspPacedListener{Listener: ln}, nil
+	}
+
+	// UDP listeners are created by the server, therefore socket options are applied
+	// when they are opened. The read buffer size is overridden by the server
+	// during startup, therefore it's applied again after it.
+	var udpConnsMutex sync.Mutex
+	var udpConns []*net.UDPConn
+	udpStarted := false
+
+	if rtpSocketOptions != (conf.SocketOptions{}) {
+		s.srv.ListenPacket = func(network string, address string) (net.PacketConn, error) {
+			pc, err := net.ListenPacket(network, address)
+			if err != nil {
+				return nil, err
+			}
+
+			err = applyUDPSocketOptions(pc, rtpSocketOptions)
+			if err != nil {
+				pc.Close()
+				return nil, err
+			}
+
+			udpConnsMutex.Lock()
+			defer udpConnsMutex.Unlock()
+
+			if uconn, ok := pc.(*net.UDPConn); ok && !udpStarted {
+				udpConns = append(udpConns, uconn)
+			}
+
+			return pc, nil
+		}
 	}
 
 	err := s.srv.Start()
 	if err != nil {
+		if s.certLoader != nil {
+			s.certLoader.close()
+		}
+		return nil, err
+	}
+
+	udpConnsMutex.Lock()
+	udpStarted = true
+	for _, uconn := range udpConns {
+		err = applyUDPSocketOptions(uconn, rtpSocketOptions)
+		if err != nil {
+			break
+		}
+	}
+	udpConns = nil
+	udpConnsMutex.Unlock()
+
+	if err != nil {
+		s.srv.Close()
+		if s.certLoader != nil {
+			s.certLoader.close()
+		}
 		return nil, err
 	}
 
 	s.Log(logger.Info, "listener opened on %s", printAddresses(s.srv))
 
+	if tunnelAddress != "" {
+		s.tunnel, err = newRTSPTunnelServer(
+			tunnelAddress,
+			readTimeout,
+			ipFilter,
+			tunnelListener,
+			s,
+		)
+		if err != nil {
+			s.srv.Close()
+			if s.certLoader != nil {
+				s.certLoader.close()
+			}
+			return nil, err
+		}
+	}
+
 	if metrics != nil {
 		if !isTLS {
 			metrics.rtspServerSet(s)
@@ -210,6 +365,10 @@ func (s *rtspServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	s.wg.Wait()
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *rtspServer) run() {
@@ -220,20 +379,32 @@ func (s *rtspServer) run() {
 		serverErr <- s.srv.Wait()
 	}()
 
-outer:
-	select {
-	case err := <-serverErr:
-		s.Log(logger.Error, "%s", err)
-		break outer
+	checkSessionsTicker := time.NewTicker(rtspServerSessionsCheckPeriod)
+	defer checkSessionsTicker.Stop()
 
-	case <-s.ctx.Done():
-		s.srv.Close()
-		<-serverErr
-		break outer
+outer:
+	for {
+		select {
+		case <-checkSessionsTicker.C:
+			s.checkSessions()
+
+		case err := <-serverErr:
+			s.Log(logger.Error, "%s", err)
+			break outer
+
+		case <-s.ctx.Done():
+			s.srv.Close()
+			<-serverErr
+			break outer
+		}
 	}
 
 	s.ctxCancel()
 
+	if s.tunnel != nil {
+		s.tunnel.close()
+	}
+
 	if s.metrics != nil {
 		if !s.isTLS {
 			s.metrics.rtspServerSet(nil)
@@ -243,12 +414,44 @@ outer:
 	}
 }
 
+// sessionTimeoutApplies checks whether the session timeout is enforced on a session.
+// Readers that use TCP, directly or through a tunnel, are closed together with
+// their connection, therefore they are subject to the timeout only if requested.
+func (s *rtspServer) sessionTimeoutApplies(se *rtspSession) bool {
+	return se.safeState() == gortsplib.ServerSessionStatePlay &&
+		(se.safePlayTransport() != gortsplib.TransportTCP || s.sessionTimeoutTCP)
+}
+
+// checkSessions closes readers that didn't send keepalives within the session timeout.
+func (s *rtspServer) checkSessions() {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	now := time.Now()
+
+	for _, se := range s.sessions {
+		if !s.sessionTimeoutApplies(se) {
+			continue
+		}
+
+		if now.Sub(se.lastKeepalive()) >= time.Duration(s.sessionTimeout) {
+			se.Log(logger.Info, "no keepalives received in %v, closing", s.sessionTimeout)
+			se.close()
+		}
+	}
+}
+
 // OnConnOpen implements gortsplib.ServerHandlerOnConnOpen.
 func (s *rtspServer) OnConnOpen(ctx *gortsplib.ServerHandlerOnConnOpenCtx) {
 	c := newRTSPConn(
 		s.externalAuthenticationURL,
+		s.authBanList,
+		s.accessList,
 		s.rtspAddress,
 		s.authMethods,
+		s.authPauseAfterError,
+		s.authRealm,
+		s.authStatusText,
 		s.readTimeout,
 		s.runOnConnect,
 		s.runOnConnectRestart,
@@ -261,6 +464,14 @@ func (s *rtspServer) OnConnOpen(ctx *gortsplib.ServerHandlerOnConnOpenCtx) {
 	s.mutex.Unlock()
 
 	ctx.Conn.SetUserData(c)
+
+	if s.accessList != nil {
+		err := s.accessList.checkConn(c.ip())
+		if err != nil {
+			c.Log(logger.Info, "%v", err)
+			ctx.Conn.Close()
+		}
+	}
 }
 
 // OnConnClose implements gortsplib.ServerHandlerOnConnClose.
@@ -276,12 +487,41 @@ func (s *rtspServer) OnConnClose(ctx *gortsplib.ServerHandlerOnConnCloseCtx) {
 func (s *rtspServer) OnRequest(sc *gortsplib.ServerConn, req *base.Request) {
 	c := sc.UserData().(*rtspConn)
 	c.onRequest(req)
+
+	// any request that belongs to a session is a keepalive.
+	var sx headers.Session
+	if sx.Unmarshal(req.Header["Session"]) == nil {
+		s.mutex.RLock()
+		for _, se := range s.sessions {
+			if se.sessionID() == sx.Session {
+				se.keepalive()
+				break
+			}
+		}
+		s.mutex.RUnlock()
+	}
 }
 
 // OnResponse implements gortsplib.ServerHandlerOnResponse.
 func (s *rtspServer) OnResponse(sc *gortsplib.ServerConn, res *base.Response) {
 	c := sc.UserData().(*rtspConn)
 	c.OnResponse(res)
+
+	// advertise the session timeout, in order to let clients adjust the period of keepalives.
+	var sx headers.Session
+	if sx.Unmarshal(res.Header["Session"]) == nil {
+		v := uint(time.Duration(s.sessionTimeout) / time.Second)
+		sx.Timeout = &v
+		res.Header["Session"] = sx.Marshal()
+	}
+
+	if s.metrics != nil {
+		server := "rtsp"
+		if s.isTLS {
+			server = "rtsps"
+		}
+		s.metrics.requests.observe(server, string(c.requestMethod), int(res.StatusCode), time.Since(c.requestStart))
+	}
 }
 
 // OnSessionOpen implements gortsplib.ServerHandlerOnSessionOpen.
@@ -351,6 +591,32 @@ func (s *rtspServer) OnPause(ctx *gortsplib.ServerHandlerOnPauseCtx) (*base.Resp
 	return se.onPause(ctx)
 }
 
+// OnGetParameter implements gortsplib.ServerHandlerOnGetParameter.
+func (s *rtspServer) OnGetParameter(ctx *gortsplib.ServerHandlerOnGetParameterCtx) (*base.Response, error) {
+	if ctx.Session == nil {
+		return &base.Response{
+			StatusCode: base.StatusNotImplemented,
+		}, nil
+	}
+
+	c := ctx.Conn.UserData().(*rtspConn)
+	se := ctx.Session.UserData().(*rtspSession)
+	return se.onParameter(c, ctx.Request)
+}
+
+// OnSetParameter implements gortsplib.ServerHandlerOnSetParameter.
+func (s *rtspServer) OnSetParameter(ctx *gortsplib.ServerHandlerOnSetParameterCtx) (*base.Response, error) {
+	if ctx.Session == nil {
+		return &base.Response{
+			StatusCode: base.StatusNotImplemented,
+		}, nil
+	}
+
+	c := ctx.Conn.UserData().(*rtspConn)
+	se := ctx.Session.UserData().(*rtspSession)
+	return se.onParameter(c, ctx.Request)
+}
+
 // OnPacketLost implements gortsplib.ServerHandlerOnDecodeError.
 func (s *rtspServer) OnPacketLost(ctx *gortsplib.ServerHandlerOnPacketLostCtx) {
 	se := ctx.Session.UserData().(*rtspSession)
@@ -363,6 +629,33 @@ func (s *rtspServer) OnDecodeError(ctx *gortsplib.ServerHandlerOnDecodeErrorCtx)
 	se.onDecodeError(ctx)
 }
 
+// multicastGroupJoin is called by rtspSession.
+func (s *rtspServer) multicastGroupJoin(pathName string) {
+	s.mutex.Lock()
+	s.multicastGroups[pathName]++
+	count := s.multicastGroups[pathName]
+	s.mutex.Unlock()
+
+	if count == 1 {
+		s.Log(logger.Info, "multicast group of path '%s' opened", pathName)
+	}
+}
+
+// multicastGroupLeave is called by rtspSession.
+func (s *rtspServer) multicastGroupLeave(pathName string) {
+	s.mutex.Lock()
+	s.multicastGroups[pathName]--
+	count := s.multicastGroups[pathName]
+	if count <= 0 {
+		delete(s.multicastGroups, pathName)
+	}
+	s.mutex.Unlock()
+
+	if count <= 0 {
+		s.Log(logger.Info, "multicast group of path '%s' closed", pathName)
+	}
+}
+
 // apiConnsList is called by api and metrics.
 func (s *rtspServer) apiConnsList() rtspServerAPIConnsListRes {
 	select {
@@ -405,12 +698,17 @@ func (s *rtspServer) apiSessionsList() rtspServerAPISessionsListRes {
 		Items: make(map[string]rtspServerAPISessionsListItem),
 	}
 
-	for _, s := range s.sessions {
-		data.Items[s.uuid.String()] = rtspServerAPISessionsListItem{
-			Created:    s.created,
-			RemoteAddr: s.remoteAddr().String(),
+	for _, se := range s.sessions {
+		timeout := conf.StringDuration(0)
+		if s.sessionTimeoutApplies(se) {
+			timeout = s.sessionTimeout
+		}
+
+		data.Items[se.uuid.String()] = rtspServerAPISessionsListItem{
+			Created:    se.created,
+			RemoteAddr: se.remoteAddr().String(),
 			State: func() string {
-				switch s.safeState() {
+				switch se.safeState() {
 				case gortsplib.ServerSessionStatePrePlay,
 					gortsplib.ServerSessionStatePlay:
 					return "read"
@@ -421,14 +719,40 @@ func (s *rtspServer) apiSessionsList() rtspServerAPISessionsListRes {
 				}
 				return "idle"
 			}(),
-			BytesReceived: s.session.BytesReceived(),
-			BytesSent:     s.session.BytesSent(),
+			BytesReceived: se.session.BytesReceived(),
+			BytesSent:     se.session.BytesSent(),
+			Timeout:       timeout,
+			LastKeepalive: se.lastKeepalive(),
 		}
 	}
 
 	return rtspServerAPISessionsListRes{data: data}
 }
 
+// apiMulticastGroupsList is called by api.
+func (s *rtspServer) apiMulticastGroupsList() rtspServerAPIMulticastGroupsListRes {
+	select {
+	case <-s.ctx.Done():
+		return rtspServerAPIMulticastGroupsListRes{err: fmt.Errorf("terminated")}
+	default:
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	data := &rtspServerAPIMulticastGroupsListData{
+		Items: make(map[string]rtspServerAPIMulticastGroupsListItem),
+	}
+
+	for pathName, count := range s.multicastGroups {
+		data.Items[pathName] = rtspServerAPIMulticastGroupsListItem{
+			Readers: count,
+		}
+	}
+
+	return rtspServerAPIMulticastGroupsListRes{data: data}
+}
+
 // apiSessionsKick is called by api.
 func (s *rtspServer) apiSessionsKick(id string) rtspServerAPISessionsKickRes {
 	select {
@@ -451,3 +775,65 @@ func (s *rtspServer) apiSessionsKick(id string) rtspServerAPISessionsKickRes {
 
 	return rtspServerAPISessionsKickRes{err: fmt.Errorf("not found")}
 }
+
+// apiSessionsRevoke is called by api.
+func (s *rtspServer) apiSessionsRevoke(user string, token string) int {
+	select {
+	case <-s.ctx.Done():
+		return 0
+	default:
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := 0
+
+	for key, se := range s.sessions {
+		if se.safeIdentity().matches(user, token) {
+			se.Log(logger.Info, "authorization has been revoked")
+			se.close()
+			delete(s.sessions, key)
+			se.onClose(liberrors.ErrServerTerminated{})
+			n++
+		}
+	}
+
+	return n
+}
+
+// apiSessionsRedirect is called by api.
+func (s *rtspServer) apiSessionsRedirect(id string, target *url.URL) rtspServerAPISessionsRedirectRes {
+	select {
+	case <-s.ctx.Done():
+		return rtspServerAPISessionsRedirectRes{err: fmt.Errorf("terminated")}
+	default:
+	}
+
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	for _, se := range s.sessions {
+		if se.uuid.String() == id {
+			return rtspServerAPISessionsRedirectRes{err: se.redirect(target)}
+		}
+	}
+
+	return rtspServerAPISessionsRedirectRes{err: fmt.Errorf("not found")}
+}
+
+// redirectReaders redirects all readers to another server.
+// It returns the number of redirected readers.
+func (s *rtspServer) redirectReaders(target *url.URL) int {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+
+	n := 0
+	for _, se := range s.sessions {
+		if se.safeState() == gortsplib.ServerSessionStatePlay && se.redirect(target) == nil {
+			n++
+		}
+	}
+
+	return n
+}
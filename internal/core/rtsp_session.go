@@ -1,11 +1,14 @@
 package core
 
 import (
+	"context"
 	"encoding/hex"
 	"errors"
 	"fmt"
 	"net"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
@@ -14,6 +17,7 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 	"github.com/google/uuid"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 
 	"github.com/aler9/mediamtx/internal/conf"
@@ -23,7 +27,9 @@ import (
 )
 
 const (
-	pauseAfterAuthError = 2 * time.Second
+	// time given to redirected clients to read the REDIRECT request,
+	// before their session is closed.
+	rtspSessionRedirectGracePeriod = 2 * time.Second
 )
 
 type rtspWriteFunc func(*rtp.Packet)
@@ -62,6 +68,22 @@ func getRTSPWriteFunc(medi *media.Media, forma formats.Format, stream *stream) r
 			})
 		}
 
+	case *formats.AV1:
+		return func(pkt *rtp.Packet) {
+			stream.writeUnit(medi, forma, &formatprocessor.UnitAV1{
+				RTPPackets: []*rtp.Packet{pkt},
+				NTP:        time.Now(),
+			})
+		}
+
+	case *formats.MPEG2Video:
+		return func(pkt *rtp.Packet) {
+			stream.writeUnit(medi, forma, &formatprocessor.UnitMPEG2Video{
+				RTPPackets: []*rtp.Packet{pkt},
+				NTP:        time.Now(),
+			})
+		}
+
 	case *formats.MPEG2Audio:
 		return func(pkt *rtp.Packet) {
 			stream.writeUnit(medi, forma, &formatprocessor.UnitMPEG2Audio{
@@ -86,6 +108,22 @@ func getRTSPWriteFunc(medi *media.Media, forma formats.Format, stream *stream) r
 			})
 		}
 
+	case *formats.G711:
+		return func(pkt *rtp.Packet) {
+			stream.writeUnit(medi, forma, &formatprocessor.UnitG711{
+				RTPPackets: []*rtp.Packet{pkt},
+				NTP:        time.Now(),
+			})
+		}
+
+	case *formats.G722:
+		return func(pkt *rtp.Packet) {
+			stream.writeUnit(medi, forma, &formatprocessor.UnitG722{
+				RTPPackets: []*rtp.Packet{pkt},
+				NTP:        time.Now(),
+			})
+		}
+
 	default:
 		return func(pkt *rtp.Packet) {
 			stream.writeUnit(medi, forma, &formatprocessor.UnitGeneric{
@@ -99,10 +137,13 @@ func getRTSPWriteFunc(medi *media.Media, forma formats.Format, stream *stream) r
 type rtspSessionPathManager interface {
 	publisherAdd(req pathPublisherAddReq) pathPublisherAnnounceRes
 	readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes
+	readerRejected(pathName string, protocol string, reason pathReaderRejectionReason, message string)
 }
 
 type rtspSessionParent interface {
 	logger.Writer
+	multicastGroupJoin(pathName string)
+	multicastGroupLeave(pathName string)
 }
 
 type rtspSession struct {
@@ -118,9 +159,30 @@ type rtspSession struct {
 	created    time.Time
 	path       *path
 	stream     *stream
+	rtspReader *streamRTSPReader // read
 	state      gortsplib.ServerSessionState
 	stateMutex sync.Mutex
 	onReadCmd  *externalcmd.Cmd // read
+
+	// connection, URL and session header of the PLAY request, used to send REDIRECT requests.
+	playConn      *gortsplib.ServerConn
+	playURL       *url.URL
+	playSessionID string
+	playTransport gortsplib.Transport
+	redirected    bool
+
+	// interleaved channel and connection of the backchannel, if setupped.
+	backchannelChannel *int
+	backchannelConn    *rtspBackchannelConn
+
+	// time of the last RTSP request or RTCP packet received from a reader, in unix nanoseconds.
+	lastKeepaliveTime int64
+
+	// name of the path whose multicast group the session is a member of
+	multicastGroup string
+
+	// identity used to authenticate, used to revoke the session.
+	identity authIdentity
 }
 
 func newRTSPSession(
@@ -144,6 +206,8 @@ func newRTSPSession(
 		created:         time.Now(),
 	}
 
+	s.keepalive()
+
 	s.Log(logger.Info, "created by %v", s.author.NetConn().RemoteAddr())
 
 	return s
@@ -160,6 +224,35 @@ func (s *rtspSession) safeState() gortsplib.ServerSessionState {
 	return s.state
 }
 
+// sessionID returns the ID that the reader uses in the Session header.
+func (s *rtspSession) sessionID() string {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.playSessionID
+}
+
+// safePlayTransport returns the transport protocol used by a reader.
+func (s *rtspSession) safePlayTransport() gortsplib.Transport {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.playTransport
+}
+
+func (s *rtspSession) safeIdentity() authIdentity {
+	s.stateMutex.Lock()
+	defer s.stateMutex.Unlock()
+	return s.identity
+}
+
+// keepalive is called by rtspServer when the reader sends a request.
+func (s *rtspSession) keepalive() {
+	atomic.StoreInt64(&s.lastKeepaliveTime, time.Now().UnixNano())
+}
+
+func (s *rtspSession) lastKeepalive() time.Time {
+	return time.Unix(0, atomic.LoadInt64(&s.lastKeepaliveTime))
+}
+
 func (s *rtspSession) remoteAddr() net.Addr {
 	return s.author.NetConn().RemoteAddr()
 }
@@ -177,6 +270,8 @@ func (s *rtspSession) onClose(err error) {
 			s.onReadCmd = nil
 			s.Log(logger.Info, "runOnRead command stopped")
 		}
+
+		s.leaveMulticastGroup()
 	}
 
 	switch s.session.State() {
@@ -187,6 +282,16 @@ func (s *rtspSession) onClose(err error) {
 		s.path.publisherRemove(pathPublisherRemoveReq{author: s})
 	}
 
+	if s.backchannelConn != nil {
+		s.backchannelConn.clearBackchannel()
+		s.backchannelConn = nil
+	}
+
+	if s.rtspReader != nil {
+		s.rtspReader.fanout.readerRemove(s.rtspReader)
+		s.rtspReader = nil
+	}
+
 	s.path = nil
 	s.stream = nil
 
@@ -209,8 +314,9 @@ func (s *rtspSession) onAnnounce(c *rtspConn, ctx *gortsplib.ServerHandlerOnAnno
 			pathIPs []fmt.Stringer,
 			pathUser conf.Credential,
 			pathPass conf.Credential,
-		) error {
-			return c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, true, ctx.Request, nil)
+			pathPermissions conf.PathPermissions,
+		) (authIdentity, error) {
+			return c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, pathPermissions, true, ctx.Request, nil)
 		},
 	})
 
@@ -221,11 +327,20 @@ func (s *rtspSession) onAnnounce(c *rtspConn, ctx *gortsplib.ServerHandlerOnAnno
 			return terr.response, nil
 
 		case pathErrAuthCritical:
+			if c.authBanList != nil {
+				c.authBanList.onFailure(c.ip())
+			}
+
 			// wait some seconds to stop brute force attacks
-			<-time.After(pauseAfterAuthError)
+			<-time.After(time.Duration(c.authPauseAfterError))
 
 			return terr.response, errors.New(terr.message)
 
+		case pathErrDisabled:
+			return &base.Response{
+				StatusCode: base.StatusServiceUnavailable,
+			}, res.err
+
 		default:
 			return &base.Response{
 				StatusCode: base.StatusBadRequest,
@@ -237,6 +352,7 @@ func (s *rtspSession) onAnnounce(c *rtspConn, ctx *gortsplib.ServerHandlerOnAnno
 
 	s.stateMutex.Lock()
 	s.state = gortsplib.ServerSessionStatePreRecord
+	s.identity = newAuthIdentity(rtspRequestUser(ctx.Request), ctx.Query)
 	s.stateMutex.Unlock()
 
 	return &base.Response{
@@ -260,6 +376,10 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 	// and it is disabled.
 	if ctx.Transport == gortsplib.TransportTCP {
 		if _, ok := s.protocols[conf.Protocol(gortsplib.TransportTCP)]; !ok {
+			if s.session.State() != gortsplib.ServerSessionStatePreRecord {
+				s.pathManager.readerRejected(ctx.Path, "rtsp", pathReaderRejectionTransportDisabled,
+					"the TCP transport protocol is disabled")
+			}
 			return &base.Response{
 				StatusCode: base.StatusUnsupportedTransport,
 			}, nil, nil
@@ -268,13 +388,37 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 
 	switch s.session.State() {
 	case gortsplib.ServerSessionStateInitial, gortsplib.ServerSessionStatePrePlay: // play
+		// the backchannel can be read from plain TCP connections only,
+		// since packets sent by readers are read from the connection.
+		backchannel := rtspBackchannelRequired(ctx.Request.Header)
+		if backchannel {
+			if rtspBackchannelConnOf(c.Conn().NetConn()) == nil {
+				return &base.Response{
+					StatusCode: base.StatusOptionNotSupported,
+					Header: base.Header{
+						"Unsupported": base.HeaderValue{rtspBackchannelRequire},
+					},
+				}, nil, nil
+			}
+
+			if ctx.Transport != gortsplib.TransportTCP {
+				s.Log(logger.Info, "the backchannel can be read with the TCP transport protocol only")
+				return &base.Response{
+					StatusCode: base.StatusUnsupportedTransport,
+				}, nil, nil
+			}
+		}
+
 		res := s.pathManager.readerAdd(pathReaderAddReq{
 			author:   s,
 			pathName: ctx.Path,
+			query:    ctx.Query,
+			protocol: "rtsp",
 			authenticate: func(
 				pathIPs []fmt.Stringer,
 				pathUser conf.Credential,
 				pathPass conf.Credential,
+				pathPermissions conf.PathPermissions,
 			) error {
 				baseURL := &url.URL{
 					Scheme:   ctx.Request.URL.Scheme,
@@ -289,7 +433,8 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 					baseURL.Path += "/"
 				}
 
-				return c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, false, ctx.Request, baseURL)
+				_, err := c.authenticate(ctx.Path, ctx.Query, pathIPs, pathUser, pathPass, pathPermissions, false, ctx.Request, baseURL)
+				return err
 			},
 		})
 
@@ -300,8 +445,12 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 				return terr.response, nil, nil
 
 			case pathErrAuthCritical:
+				if c.authBanList != nil {
+					c.authBanList.onFailure(c.ip())
+				}
+
 				// wait some seconds to stop brute force attacks
-				<-time.After(pauseAfterAuthError)
+				<-time.After(time.Duration(c.authPauseAfterError))
 
 				return terr.response, nil, errors.New(terr.message)
 
@@ -310,6 +459,11 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 					StatusCode: base.StatusNotFound,
 				}, nil, res.err
 
+			case pathErrDisabled:
+				return &base.Response{
+					StatusCode: base.StatusServiceUnavailable,
+				}, nil, res.err
+
 			default:
 				return &base.Response{
 					StatusCode: base.StatusBadRequest,
@@ -320,13 +474,59 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 		s.path = res.path
 		s.stream = res.stream
 
+		// all the medias of a reader must be read from the same shard of the stream.
+		if s.rtspReader == nil || s.rtspReader.fanout != res.stream.rtspFanout {
+			if s.rtspReader != nil {
+				s.rtspReader.fanout.readerRemove(s.rtspReader)
+				s.rtspReader = nil
+			}
+
+			r, err := res.stream.rtspFanout.readerAdd(ctx.Transport, c.ip(), backchannel)
+			if err != nil {
+				s.Log(logger.Info, err.Error())
+				s.setupFailed()
+				return &base.Response{
+					StatusCode: base.StatusOptionNotSupported,
+					Header: base.Header{
+						"Unsupported": base.HeaderValue{rtspBackchannelRequire},
+					},
+				}, nil, nil
+			}
+			s.rtspReader = r
+		}
+
+		if backchannel {
+			if id, ok := rtspBackchannelTrackID(ctx.Request.URL); ok && id == len(res.stream.medias()) {
+				channel, ok := rtspBackchannelInterleavedChannel(ctx.Request.Header)
+				if !ok {
+					s.Log(logger.Info, "the backchannel requires interleaved IDs")
+					s.setupFailed()
+					return &base.Response{
+						StatusCode: base.StatusUnsupportedTransport,
+					}, nil, nil
+				}
+
+				// the backchannel can be used by one reader at a time.
+				if !s.rtspReader.fanout.backchannelAcquire(s.rtspReader) {
+					s.Log(logger.Info, "the backchannel is being used by another reader")
+					s.setupFailed()
+					return &base.Response{
+						StatusCode: base.StatusNotEnoughBandwidth,
+					}, nil, nil
+				}
+
+				s.backchannelChannel = &channel
+			}
+		}
+
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePrePlay
+		s.identity = newAuthIdentity(rtspRequestUser(ctx.Request), ctx.Query)
 		s.stateMutex.Unlock()
 
 		return &base.Response{
 			StatusCode: base.StatusOK,
-		}, res.stream.rtspStream, nil
+		}, s.rtspReader.serverStream(), nil
 
 	default: // record
 		return &base.Response{
@@ -335,6 +535,23 @@ func (s *rtspSession) onSetup(c *rtspConn, ctx *gortsplib.ServerHandlerOnSetupCt
 	}
 }
 
+// setupFailed cancels the registration of a reader whose first SETUP request fails.
+func (s *rtspSession) setupFailed() {
+	if s.session.State() != gortsplib.ServerSessionStateInitial {
+		return
+	}
+
+	s.path.readerRemove(pathReaderRemoveReq{author: s})
+
+	if s.rtspReader != nil {
+		s.rtspReader.fanout.readerRemove(s.rtspReader)
+		s.rtspReader = nil
+	}
+
+	s.path = nil
+	s.stream = nil
+}
+
 // onPlay is called by rtspServer.
 func (s *rtspSession) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Response, error) {
 	h := make(base.Header)
@@ -347,20 +564,65 @@ func (s *rtspSession) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Respo
 
 		pathConf := s.path.safeConf()
 
+		if pathConf.ReaderMaxBitrate != 0 && *s.session.SetuppedTransport() == gortsplib.TransportTCP {
+			if pc := rtspPacedConnOf(ctx.Conn.NetConn()); pc != nil {
+				pc.setMaxBitrate(pathConf.ReaderMaxBitrate)
+			}
+		}
+
+		if *s.session.SetuppedTransport() == gortsplib.TransportUDPMulticast {
+			s.multicastGroup = s.path.name
+			s.parent.multicastGroupJoin(s.multicastGroup)
+		}
+
 		if pathConf.RunOnRead != "" {
 			s.Log(logger.Info, "runOnRead command started")
-			s.onReadCmd = externalcmd.NewCmd(
+			s.onReadCmd = externalcmd.NewCmdWithOptions(
 				s.externalCmdPool,
 				pathConf.RunOnRead,
 				pathConf.RunOnReadRestart,
 				s.path.externalCmdEnv(),
+				externalCmdOptions(pathConf, s),
 				func(co int) {
 					s.Log(logger.Info, "runOnRead command exited with code %d", co)
 				})
 		}
 
+		s.keepalive()
+
+		// RTCP receiver reports are keepalives too.
+		ctx.Session.OnPacketRTCPAny(func(medi *media.Media, pkt rtcp.Packet) {
+			s.keepalive()
+		})
+
+		if s.backchannelChannel != nil {
+			if bc := rtspBackchannelConnOf(ctx.Conn.NetConn()); bc != nil {
+				r := s.rtspReader
+				bc.setBackchannel(*s.backchannelChannel, func(payload []byte) {
+					s.keepalive()
+
+					var pkt rtp.Packet
+					err := pkt.Unmarshal(payload)
+					if err != nil {
+						return
+					}
+
+					r.fanout.writeBackchannel(r, &pkt)
+				})
+				s.backchannelConn = bc
+
+				s.Log(logger.Info, "is sending audio through the backchannel")
+			}
+		}
+
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePlay
+		s.playConn = ctx.Conn
+		s.playURL = ctx.Request.URL
+		s.playTransport = *s.session.SetuppedTransport()
+		if v, ok := ctx.Request.Header["Session"]; ok && len(v) == 1 {
+			s.playSessionID = strings.Split(v[0], ";")[0]
+		}
 		s.stateMutex.Unlock()
 	}
 
@@ -370,6 +632,76 @@ func (s *rtspSession) onPlay(ctx *gortsplib.ServerHandlerOnPlayCtx) (*base.Respo
 	}, nil
 }
 
+// rtspSessionRedirectLocation returns the URL a reader of a path is redirected to.
+// If target doesn't contain a path, the one of the reader is used.
+func rtspSessionRedirectLocation(target *url.URL, pathName string) string {
+	loc := target.Clone()
+	if loc.Path == "" || loc.Path == "/" {
+		loc.Path = "/" + pathName
+	}
+	return loc.String()
+}
+
+// redirect sends a REDIRECT request to a reader, that asks it to move to another server,
+// then closes the session after a grace period.
+func (s *rtspSession) redirect(target *url.URL) error {
+	s.stateMutex.Lock()
+	state := s.state
+	conn := s.playConn
+	u := s.playURL
+	sessionID := s.playSessionID
+	alreadyRedirected := s.redirected
+	s.redirected = true
+	pathName := ""
+	if s.path != nil {
+		pathName = s.path.name
+	}
+	s.stateMutex.Unlock()
+
+	if state != gortsplib.ServerSessionStatePlay || conn == nil {
+		return fmt.Errorf("session is not reading")
+	}
+
+	if alreadyRedirected {
+		return fmt.Errorf("session has already been redirected")
+	}
+
+	location := rtspSessionRedirectLocation(target, pathName)
+
+	req := base.Request{
+		Method: "REDIRECT",
+		URL:    u,
+		Header: base.Header{
+			"CSeq":     base.HeaderValue{"1"},
+			"Location": base.HeaderValue{location},
+		},
+	}
+	if sessionID != "" {
+		req.Header["Session"] = base.HeaderValue{sessionID}
+	}
+
+	byts, err := req.Marshal()
+	if err != nil {
+		return err
+	}
+
+	s.Log(logger.Info, "redirecting to %s", location)
+
+	// a single write is never interleaved with the ones of the server.
+	_, err = conn.NetConn().Write(byts)
+
+	time.AfterFunc(rtspSessionRedirectGracePeriod, s.close)
+
+	return err
+}
+
+func (s *rtspSession) leaveMulticastGroup() {
+	if s.multicastGroup != "" {
+		s.parent.multicastGroupLeave(s.multicastGroup)
+		s.multicastGroup = ""
+	}
+}
+
 // onRecord is called by rtspServer.
 func (s *rtspSession) onRecord(ctx *gortsplib.ServerHandlerOnRecordCtx) (*base.Response, error) {
 	res := s.path.publisherStart(pathPublisherStartReq{
@@ -400,6 +732,10 @@ func (s *rtspSession) onRecord(ctx *gortsplib.ServerHandlerOnRecordCtx) (*base.R
 		}
 	}
 
+	ctx.Session.OnPacketRTCPAny(func(medi *media.Media, pkt rtcp.Packet) {
+		s.stream.writePacketRTCP(pkt)
+	})
+
 	s.stateMutex.Lock()
 	s.state = gortsplib.ServerSessionStateRecord
 	s.stateMutex.Unlock()
@@ -418,6 +754,8 @@ func (s *rtspSession) onPause(ctx *gortsplib.ServerHandlerOnPauseCtx) (*base.Res
 			s.onReadCmd.Close()
 		}
 
+		s.leaveMulticastGroup()
+
 		s.stateMutex.Lock()
 		s.state = gortsplib.ServerSessionStatePrePlay
 		s.stateMutex.Unlock()
@@ -435,6 +773,71 @@ func (s *rtspSession) onPause(ctx *gortsplib.ServerHandlerOnPauseCtx) (*base.Res
 	}, nil
 }
 
+// onParameter is called by rtspServer when a GET_PARAMETER or a SET_PARAMETER request is received.
+func (s *rtspSession) onParameter(c *rtspConn, req *base.Request) (*base.Response, error) {
+	state := s.session.State()
+
+	// GET_PARAMETER without body is used as keepalive.
+	if len(req.Body) == 0 ||
+		(state != gortsplib.ServerSessionStatePrePlay && state != gortsplib.ServerSessionStatePlay) ||
+		len(s.path.safeConf().SourceParameters) == 0 {
+		if req.Method == base.GetParameter {
+			return &base.Response{
+				StatusCode: base.StatusOK,
+				Header: base.Header{
+					"Content-Type": base.HeaderValue{"text/parameters"},
+				},
+				Body: []byte{},
+			}, nil
+		}
+
+		return &base.Response{
+			StatusCode: base.StatusNotImplemented,
+		}, nil
+	}
+
+	res := s.path.sourceConf()
+	if res.err != nil {
+		return &base.Response{
+			StatusCode: base.StatusNotImplemented,
+		}, nil
+	}
+
+	for _, name := range rtspParameterNames(req.Body) {
+		if !rtspParameterAllowed(name, res.conf.SourceParameters) {
+			s.Log(logger.Warn, "%s request refused: parameter '%s' is not allowed", req.Method, name)
+			return &base.Response{
+				StatusCode: base.StatusParameterNotUnderstood,
+			}, nil
+		}
+	}
+
+	timeout := time.Duration(c.readTimeout)
+	ctx, ctxCancel := context.WithTimeout(context.Background(), timeout)
+	defer ctxCancel()
+
+	sourceRes, err := rtspSourceParameterRequest(ctx, res.conf.Source, res.conf.SourceFingerprint, timeout, req)
+	if err != nil {
+		s.Log(logger.Warn, "unable to forward %s request to source: %v", req.Method, err)
+		return &base.Response{
+			StatusCode: base.StatusBadGateway,
+		}, nil
+	}
+
+	fwdRes := &base.Response{
+		StatusCode:    sourceRes.StatusCode,
+		StatusMessage: sourceRes.StatusMessage,
+		Header:        base.Header{},
+		Body:          sourceRes.Body,
+	}
+
+	if v, ok := sourceRes.Header["Content-Type"]; ok {
+		fwdRes.Header["Content-Type"] = v
+	}
+
+	return fwdRes, nil
+}
+
 // apiReaderDescribe implements reader.
 func (s *rtspSession) apiReaderDescribe() interface{} {
 	var typ string
@@ -7,10 +7,17 @@ import (
 	"encoding/hex"
 	"fmt"
 	"strings"
+	"sync/atomic"
 	"time"
 
 	"github.com/bluenviron/gortsplib/v3"
 	"github.com/bluenviron/gortsplib/v3/pkg/base"
+	"github.com/bluenviron/gortsplib/v3/pkg/headers"
+	"github.com/bluenviron/gortsplib/v3/pkg/liberrors"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/rtcpreceiver"
+	"github.com/bluenviron/gortsplib/v3/pkg/rtplossdetector"
+	"github.com/pion/rtcp"
 	"github.com/pion/rtp"
 
 	"github.com/aler9/mediamtx/internal/conf"
@@ -18,6 +25,10 @@ import (
 	"github.com/bluenviron/gortsplib/v3/pkg/url"
 )
 
+const (
+	rtspSourceReceiverReportPeriod = 5 * time.Second
+)
+
 type rtspSourceParent interface {
 	logger.Writer
 	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
@@ -28,20 +39,70 @@ type rtspSource struct {
 	readTimeout     conf.StringDuration
 	writeTimeout    conf.StringDuration
 	readBufferCount int
+	stats           *sourceStaticStats
 	parent          rtspSourceParent
+
+	// when sourceProtocol is automatic and UDP packets are not received,
+	// the source switches to TCP, and TCP is used directly in next connections.
+	tcpFallback *int32
+
+	// transport protocol of the current connection.
+	transport atomic.Value
 }
 
 func newRTSPSource(
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
 	readBufferCount int,
+	stats *sourceStaticStats,
 	parent rtspSourceParent,
 ) *rtspSource {
-	return &rtspSource{
+	s := &rtspSource{
 		readTimeout:     readTimeout,
 		writeTimeout:    writeTimeout,
 		readBufferCount: readBufferCount,
+		stats:           stats,
 		parent:          parent,
+		tcpFallback:     new(int32),
+	}
+
+	s.transport.Store("")
+
+	return s
+}
+
+func rtspSourceTransportLabel(t gortsplib.Transport) string {
+	switch t {
+	case gortsplib.TransportUDP:
+		return "udp"
+
+	case gortsplib.TransportUDPMulticast:
+		return "multicast"
+	}
+	return "tcp"
+}
+
+// rtspSourceTLSConfig returns the TLS configuration used to connect to RTSPS sources.
+func rtspSourceTLSConfig(fingerprint string) *tls.Config {
+	if fingerprint == "" {
+		return nil
+	}
+
+	return &tls.Config{
+		InsecureSkipVerify: true,
+		VerifyConnection: func(cs tls.ConnectionState) error {
+			h := sha256.New()
+			h.Write(cs.PeerCertificates[0].Raw)
+			hstr := hex.EncodeToString(h.Sum(nil))
+			fingerprintLower := strings.ToLower(fingerprint)
+
+			if hstr != fingerprintLower {
+				return fmt.Errorf("server fingerprint do not match: expected %s, got %s",
+					fingerprintLower, hstr)
+			}
+
+			return nil
+		},
 	}
 }
 
@@ -53,41 +114,60 @@ func (s *rtspSource) Log(level logger.Level, format string, args ...interface{})
 func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
 	s.Log(logger.Debug, "connecting")
 
-	var tlsConfig *tls.Config
-	if cnf.SourceFingerprint != "" {
-		tlsConfig = &tls.Config{
-			InsecureSkipVerify: true,
-			VerifyConnection: func(cs tls.ConnectionState) error {
-				h := sha256.New()
-				h.Write(cs.PeerCertificates[0].Raw)
-				hstr := hex.EncodeToString(h.Sum(nil))
-				fingerprintLower := strings.ToLower(cnf.SourceFingerprint)
-
-				if hstr != fingerprintLower {
-					return fmt.Errorf("server fingerprint do not match: expected %s, got %s",
-						fingerprintLower, hstr)
-				}
+	tlsConfig := rtspSourceTLSConfig(cnf.SourceFingerprint)
 
-				return nil
-			},
-		}
+	// RTCP allows to compute the RTT on the sender side only,
+	// therefore the RTT is measured with RTSP requests (including keepalives).
+	var requestTime time.Time
+
+	// gortsplib sends receiver reports when transport is UDP only.
+	isTCP := new(int32)
+
+	transport := cnf.SourceProtocol.Transport
+	usingFallback := false
+
+	if transport == nil && atomic.LoadInt32(s.tcpFallback) == 1 {
+		v := gortsplib.TransportTCP
+		transport = &v
+		usingFallback = true
+		s.Log(logger.Debug, "using TCP since UDP packets were not received during a previous connection")
+	}
+
+	// the backchannel is written directly to the TCP connection.
+	var backchannelConn *rtspSourceBackchannelConn
+	if cnf.SourceBackchannel {
+		v := gortsplib.TransportTCP
+		transport = &v
 	}
 
+	s.transport.Store("")
+	defer s.transport.Store("")
+
 	c := &gortsplib.Client{
-		Transport:       cnf.SourceProtocol.Transport,
+		Transport:       transport,
 		TLSConfig:       tlsConfig,
 		ReadTimeout:     time.Duration(s.readTimeout),
 		WriteTimeout:    time.Duration(s.writeTimeout),
 		ReadBufferCount: s.readBufferCount,
 		AnyPortEnable:   cnf.SourceAnyPortEnable,
 		OnRequest: func(req *base.Request) {
+			if cnf.SourceBackchannel &&
+				(req.Method == base.Describe || req.Method == base.Setup || req.Method == base.Play) {
+				req.Header["Require"] = base.HeaderValue{rtspBackchannelRequire}
+			}
+
 			s.Log(logger.Debug, "c->s %v", req)
+			requestTime = time.Now()
 		},
 		OnResponse: func(res *base.Response) {
 			s.Log(logger.Debug, "s->c %v", res)
+			s.stats.setRTT(time.Since(requestTime))
 		},
 		OnTransportSwitch: func(err error) {
 			s.Log(logger.Warn, err.Error())
+			atomic.StoreInt32(isTCP, 1)
+			atomic.StoreInt32(s.tcpFallback, 1)
+			s.transport.Store(rtspSourceTransportLabel(gortsplib.TransportTCP))
 		},
 		OnPacketLost: func(err error) {
 			s.Log(logger.Warn, err.Error())
@@ -97,6 +177,12 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 		},
 	}
 
+	if cnf.SourceBackchannel {
+		c.DialContext = rtspSourceBackchannelDial(time.Duration(s.writeTimeout), func(nconn *rtspSourceBackchannelConn) {
+			backchannelConn = nconn
+		})
+	}
+
 	u, err := url.Parse(cnf.Source)
 	if err != nil {
 		return err
@@ -116,9 +202,65 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 				return err
 			}
 
-			err = c.SetupAll(medias, baseURL)
-			if err != nil {
-				return err
+			// the backchannel is setupped like other medias, but it's not published.
+			var backchannelMedia *media.Media
+			backchannelChannel := 0
+
+			if cnf.SourceBackchannel {
+				for _, medi := range medias {
+					if medi.Type == media.TypeAudio && medi.Direction == media.DirectionSendonly {
+						backchannelMedia = medi
+						break
+					}
+				}
+
+				if backchannelMedia == nil {
+					s.Log(logger.Warn, "the source doesn't provide a backchannel")
+				}
+			}
+
+			for _, medi := range medias {
+				res, err := c.Setup(medi, baseURL, 0, 0)
+				if err != nil {
+					// the server doesn't support TCP anymore, detect the transport again
+					// during the next connection.
+					if terr, ok := err.(liberrors.ErrClientBadStatusCode); ok && usingFallback &&
+						terr.Code == base.StatusUnsupportedTransport {
+						atomic.StoreInt32(s.tcpFallback, 0)
+					}
+					return err
+				}
+
+				var th headers.Transport
+				err = th.Unmarshal(res.Header["Transport"])
+				if err == nil {
+					// packets are sent to the backchannel on the interleaved channel chosen by the source.
+					if medi == backchannelMedia && th.InterleavedIDs != nil {
+						backchannelChannel = th.InterleavedIDs[0]
+					}
+
+					switch {
+					case th.Protocol == headers.TransportProtocolTCP:
+						atomic.StoreInt32(isTCP, 1)
+						s.transport.Store(rtspSourceTransportLabel(gortsplib.TransportTCP))
+
+					case th.Delivery != nil && *th.Delivery == headers.TransportDeliveryMulticast:
+						s.transport.Store(rtspSourceTransportLabel(gortsplib.TransportUDPMulticast))
+
+					default:
+						s.transport.Store(rtspSourceTransportLabel(gortsplib.TransportUDP))
+					}
+				}
+			}
+
+			if backchannelMedia != nil {
+				var tmp media.Medias
+				for _, medi := range medias {
+					if medi != backchannelMedia {
+						tmp = append(tmp, medi)
+					}
+				}
+				medias = tmp
 			}
 
 			res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
@@ -129,22 +271,82 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 				return res.err
 			}
 
+			if backchannelMedia != nil {
+				res.stream.rtspFanout.setBackchannel(backchannelMedia, func(pkt *rtp.Packet) {
+					byts, err := pkt.Marshal()
+					if err != nil {
+						return
+					}
+
+					err = backchannelConn.writeFrame(backchannelChannel, byts)
+					if err != nil {
+						s.Log(logger.Debug, "unable to write to the backchannel: %v", err)
+					}
+				})
+			}
+
 			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
 
 			defer func() {
 				s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
 			}()
 
+			rtcpReceivers := make(map[*media.Media][]*rtcpreceiver.RTCPReceiver)
+
+			defer func() {
+				for _, rrs := range rtcpReceivers {
+					for _, rr := range rrs {
+						rr.Close()
+					}
+				}
+			}()
+
 			for _, medi := range medias {
+				cmedi := medi
+				lossDetector := rtplossdetector.New()
+
 				for _, forma := range medi.Formats {
 					writeFunc := getRTSPWriteFunc(medi, forma, res.stream)
+					clockRate := forma.ClockRate()
+
+					rr := rtcpreceiver.New(
+						rtspSourceReceiverReportPeriod,
+						nil,
+						clockRate,
+						func(pkt rtcp.Packet) {
+							s.onReceiverReport(pkt.(*rtcp.ReceiverReport), clockRate)
+
+							if atomic.LoadInt32(isTCP) == 1 {
+								c.WritePacketRTCP(cmedi, pkt)
+							}
+						})
+					rtcpReceivers[medi] = append(rtcpReceivers[medi], rr)
+
+					ptsEqualsDTS := forma.PTSEqualsDTS
 
 					c.OnPacketRTP(medi, forma, func(pkt *rtp.Packet) {
+						if lost := lossDetector.Process(pkt); lost != 0 {
+							s.stats.addPacketsLost(uint64(lost))
+						}
+						rr.ProcessPacket(pkt, time.Now(), ptsEqualsDTS(pkt))
 						writeFunc(pkt)
 					})
 				}
 			}
 
+			c.OnPacketRTCPAny(func(medi *media.Media, pkt rtcp.Packet) {
+				if sr, ok := pkt.(*rtcp.SenderReport); ok {
+					now := time.Now()
+					for _, rr := range rtcpReceivers[medi] {
+						if ssrc, ok := rr.LastSSRC(); ok && ssrc == sr.SSRC {
+							rr.ProcessSenderReport(sr, now)
+						}
+					}
+				}
+
+				res.stream.writePacketRTCP(pkt)
+			})
+
 			_, err = c.Play(nil)
 			if err != nil {
 				return err
@@ -169,9 +371,24 @@ func (s *rtspSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf cha
 	}
 }
 
+func (s *rtspSource) onReceiverReport(rr *rtcp.ReceiverReport, clockRate int) {
+	for _, report := range rr.Reports {
+		s.stats.setReceptionQuality(
+			time.Duration(float64(report.Jitter)/float64(clockRate)*float64(time.Second)),
+			float64(report.FractionLost)/256)
+	}
+}
+
 // apiSourceDescribe implements sourceStaticImpl.
-func (*rtspSource) apiSourceDescribe() interface{} {
+func (s *rtspSource) apiSourceDescribe() interface{} {
+	var transport *string
+	if v := s.transport.Load().(string); v != "" {
+		transport = &v
+	}
+
 	return struct {
-		Type string `json:"type"`
-	}{"rtspSource"}
+		Type      string  `json:"type"`
+		Transport *string `json:"transport"`
+		sourceStaticStatsAPI
+	}{"rtspSource", transport, s.stats.apiDescribe()}
 }
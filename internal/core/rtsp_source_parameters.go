@@ -0,0 +1,133 @@
+package core
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	gourl "net/url"
+	"strings"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/auth"
+	"github.com/bluenviron/gortsplib/v3/pkg/base"
+	"github.com/bluenviron/gortsplib/v3/pkg/conn"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+)
+
+// rtspParameterNames returns the names of the parameters contained in the body
+// of a GET_PARAMETER or SET_PARAMETER request (text/parameters format).
+func rtspParameterNames(body []byte) []string {
+	var ret []string
+
+	for _, line := range strings.Split(string(body), "\n") {
+		name, _, _ := strings.Cut(line, ":")
+		name = strings.TrimSpace(name)
+		if name != "" {
+			ret = append(ret, name)
+		}
+	}
+
+	return ret
+}
+
+func rtspParameterAllowed(name string, allowed []string) bool {
+	for _, a := range allowed {
+		if a == name {
+			return true
+		}
+	}
+	return false
+}
+
+// rtspSourceParameterRequest sends a GET_PARAMETER or SET_PARAMETER request to a RTSP source.
+// The request is sent on a dedicated connection, in order not to interfere with
+// the connection used to pull the stream.
+func rtspSourceParameterRequest(
+	ctx context.Context,
+	source string,
+	fingerprint string,
+	timeout time.Duration,
+	req *base.Request,
+) (*base.Response, error) {
+	u, err := url.Parse(source)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname := (*gourl.URL)(u).Hostname()
+
+	host := u.Host
+	if (*gourl.URL)(u).Port() == "" {
+		if u.Scheme == "rtsps" {
+			host = net.JoinHostPort(hostname, "322")
+		} else {
+			host = net.JoinHostPort(hostname, "554")
+		}
+	}
+
+	dialer := &net.Dialer{Timeout: timeout}
+	nconn, err := dialer.DialContext(ctx, "tcp", host)
+	if err != nil {
+		return nil, err
+	}
+	defer nconn.Close()
+
+	if u.Scheme == "rtsps" {
+		tlsConfig := rtspSourceTLSConfig(fingerprint)
+		if tlsConfig == nil {
+			tlsConfig = &tls.Config{}
+		}
+		tlsConfig.ServerName = hostname
+		nconn = tls.Client(nconn, tlsConfig)
+	}
+
+	nconn.SetDeadline(time.Now().Add(timeout))
+	c := conn.NewConn(nconn)
+
+	outReq := &base.Request{
+		Method: req.Method,
+		URL:    u.CloneWithoutCredentials(),
+		Header: base.Header{
+			"CSeq": base.HeaderValue{"1"},
+		},
+		Body: req.Body,
+	}
+
+	if v, ok := req.Header["Content-Type"]; ok {
+		outReq.Header["Content-Type"] = v
+	}
+
+	do := func() (*base.Response, error) {
+		err := c.WriteRequest(outReq)
+		if err != nil {
+			return nil, err
+		}
+		return c.ReadResponse()
+	}
+
+	res, err := do()
+	if err != nil {
+		return nil, err
+	}
+
+	// send the request again with credentials
+	if res.StatusCode == base.StatusUnauthorized && u.User != nil {
+		pass, _ := u.User.Password()
+
+		sender, err := auth.NewSender(res.Header["WWW-Authenticate"], u.User.Username(), pass)
+		if err != nil {
+			return nil, fmt.Errorf("unable to setup authentication: %s", err)
+		}
+
+		outReq.Header["CSeq"] = base.HeaderValue{"2"}
+		sender.AddAuthorization(outReq)
+
+		res, err = do()
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return res, nil
+}
@@ -0,0 +1,419 @@
+package core
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/websocket"
+)
+
+const (
+	rtspTunnelContentType = "application/x-rtsp-tunnelled"
+	rtspTunnelPairTimeout = 10 * time.Second
+)
+
+// rtspTunnelConn is the server side of a tunnel, that is passed to the RTSP server.
+type rtspTunnelConn struct {
+	net.Conn
+	remoteAddr *net.TCPAddr
+}
+
+// RemoteAddr implements net.Conn.
+func (c *rtspTunnelConn) RemoteAddr() net.Addr {
+	return c.remoteAddr
+}
+
+type rtspTunnelAcceptRes struct {
+	conn net.Conn
+	err  error
+}
+
+// rtspTunnelListener is a net.Listener that returns both connections
+// accepted by a TCP listener and connections coming from tunnels.
+type rtspTunnelListener struct {
+	net.Listener
+
+	closeOnce sync.Once
+	chAccept  chan rtspTunnelAcceptRes
+	chTunnel  chan net.Conn
+	done      chan struct{}
+}
+
+func newRTSPTunnelListener(ln net.Listener) *rtspTunnelListener {
+	l := &rtspTunnelListener{
+		Listener: ln,
+		chAccept: make(chan rtspTunnelAcceptRes),
+		chTunnel: make(chan net.Conn),
+		done:     make(chan struct{}),
+	}
+
+	go l.runAccept()
+
+	return l
+}
+
+func (l *rtspTunnelListener) runAccept() {
+	for {
+		conn, err := l.Listener.Accept()
+
+		select {
+		case l.chAccept <- rtspTunnelAcceptRes{conn, err}:
+		case <-l.done:
+			if conn != nil {
+				conn.Close()
+			}
+			return
+		}
+
+		if err != nil {
+			return
+		}
+	}
+}
+
+// Accept implements net.Listener.
+func (l *rtspTunnelListener) Accept() (net.Conn, error) {
+	select {
+	case res := <-l.chAccept:
+		return res.conn, res.err
+
+	case conn := <-l.chTunnel:
+		return conn, nil
+
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+// Close implements net.Listener.
+func (l *rtspTunnelListener) Close() error {
+	l.closeOnce.Do(func() {
+		close(l.done)
+	})
+	return l.Listener.Close()
+}
+
+// push passes a tunnel to the RTSP server.
+func (l *rtspTunnelListener) push(conn net.Conn) bool {
+	select {
+	case l.chTunnel <- conn:
+		return true
+	case <-l.done:
+		return false
+	}
+}
+
+// rtspTunnelDecode decodes a stream of base64-encoded chunks,
+// each of them possibly padded.
+func rtspTunnelDecode(r io.Reader, w io.Writer) error {
+	buf := make([]byte, 4096)
+	var pending []byte
+	dec := make([]byte, 3)
+
+	for {
+		n, err := r.Read(buf)
+		if err != nil {
+			return err
+		}
+
+		for _, b := range buf[:n] {
+			if b == '\r' || b == '\n' || b == ' ' {
+				continue
+			}
+
+			pending = append(pending, b)
+			if len(pending) < 4 {
+				continue
+			}
+
+			dn, err := base64.StdEncoding.Decode(dec, pending)
+			if err != nil {
+				return err
+			}
+			pending = pending[:0]
+
+			_, err = w.Write(dec[:dn])
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+type rtspTunnelPending struct {
+	tunnelSide net.Conn
+	paired     bool
+	postConn   net.Conn
+}
+
+type rtspTunnelServerParent interface {
+	logger.Writer
+}
+
+// rtspTunnelServer allows to reach the RTSP server through HTTP,
+// with the QuickTime tunneling method, or through WebSocket.
+type rtspTunnelServer struct {
+	listener *rtspTunnelListener
+	parent   rtspTunnelServerParent
+
+	ln         net.Listener
+	httpServer *http.Server
+	mutex      sync.Mutex
+	pending    map[string]*rtspTunnelPending
+}
+
+func newRTSPTunnelServer(
+	address string,
+	readTimeout conf.StringDuration,
+	ipFilter conf.IPFilter,
+	listener *rtspTunnelListener,
+	parent rtspTunnelServerParent,
+) (*rtspTunnelServer, error) {
+	ln, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	ln = newIPFilterListener(ln, ipFilter, parent)
+
+	s := &rtspTunnelServer{
+		listener: listener,
+		parent:   parent,
+		ln:       ln,
+		pending:  make(map[string]*rtspTunnelPending),
+	}
+
+	router := gin.New()
+	router.SetTrustedProxies(nil)
+	router.NoRoute(s.onRequest)
+
+	s.httpServer = &http.Server{
+		Handler:           router,
+		ReadHeaderTimeout: time.Duration(readTimeout),
+		ErrorLog:          log.New(&nilWriter{}, "", 0),
+	}
+
+	s.Log(logger.Info, "listener opened on %s (HTTP tunnel, WebSocket)", address)
+
+	go s.httpServer.Serve(s.ln)
+
+	return s, nil
+}
+
+func (s *rtspTunnelServer) close() {
+	s.Log(logger.Info, "listener is closing")
+	s.httpServer.Shutdown(context.Background())
+	s.ln.Close() // in case Shutdown() is called before Serve()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, p := range s.pending {
+		p.tunnelSide.Close()
+		if p.postConn != nil {
+			p.postConn.Close()
+		}
+	}
+}
+
+// Log is the main logging function.
+func (s *rtspTunnelServer) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[tunnel] "+format, args...)
+}
+
+func (s *rtspTunnelServer) onRequest(ctx *gin.Context) {
+	switch {
+	case strings.ToLower(ctx.Request.Header.Get("Upgrade")) == "websocket":
+		s.onWebSocket(ctx)
+
+	case ctx.Request.Method == http.MethodGet &&
+		ctx.Request.Header.Get("x-sessioncookie") != "":
+		s.onTunnelGet(ctx)
+
+	case ctx.Request.Method == http.MethodPost &&
+		ctx.Request.Header.Get("x-sessioncookie") != "":
+		s.onTunnelPost(ctx)
+
+	default:
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+	}
+}
+
+// newPipe returns the server side and the tunnel side of a new tunnel.
+func (s *rtspTunnelServer) newPipe(remoteAddr net.Addr) (net.Conn, net.Conn) {
+	serverSide, tunnelSide := net.Pipe()
+
+	tcpAddr, ok := remoteAddr.(*net.TCPAddr)
+	if !ok {
+		tcpAddr = &net.TCPAddr{}
+	}
+
+	return &rtspTunnelConn{Conn: serverSide, remoteAddr: tcpAddr}, tunnelSide
+}
+
+func (s *rtspTunnelServer) onTunnelGet(ctx *gin.Context) {
+	cookie := ctx.Request.Header.Get("x-sessioncookie")
+
+	s.mutex.Lock()
+	_, exists := s.pending[cookie]
+	s.mutex.Unlock()
+	if exists {
+		ctx.Writer.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	nconn, rw, err := ctx.Writer.Hijack()
+	if err != nil {
+		return
+	}
+
+	_, err = rw.WriteString("HTTP/1.0 200 OK\r\n" +
+		"Content-Type: " + rtspTunnelContentType + "\r\n" +
+		"Cache-Control: no-cache\r\n" +
+		"Pragma: no-cache\r\n" +
+		"\r\n")
+	if err == nil {
+		err = rw.Flush()
+	}
+	if err != nil {
+		nconn.Close()
+		return
+	}
+
+	serverSide, tunnelSide := s.newPipe(nconn.RemoteAddr())
+
+	p := &rtspTunnelPending{tunnelSide: tunnelSide}
+
+	s.mutex.Lock()
+	s.pending[cookie] = p
+	s.mutex.Unlock()
+
+	// the POST request must be received within a timeout
+	time.AfterFunc(rtspTunnelPairTimeout, func() {
+		s.mutex.Lock()
+		defer s.mutex.Unlock()
+		if !p.paired && s.pending[cookie] == p {
+			delete(s.pending, cookie)
+			tunnelSide.Close()
+		}
+	})
+
+	go func() {
+		// server -> client data is sent in plain form
+		io.Copy(nconn, tunnelSide)
+		nconn.Close()
+		tunnelSide.Close()
+
+		s.mutex.Lock()
+		if s.pending[cookie] == p {
+			delete(s.pending, cookie)
+		}
+		if p.postConn != nil {
+			p.postConn.Close()
+		}
+		s.mutex.Unlock()
+	}()
+
+	if !s.listener.push(serverSide) {
+		serverSide.Close()
+	}
+}
+
+func (s *rtspTunnelServer) onTunnelPost(ctx *gin.Context) {
+	cookie := ctx.Request.Header.Get("x-sessioncookie")
+
+	s.mutex.Lock()
+	p, ok := s.pending[cookie]
+	if ok && p.paired {
+		ok = false
+	}
+	if ok {
+		p.paired = true
+	}
+	s.mutex.Unlock()
+
+	if !ok {
+		ctx.Writer.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	nconn, rw, err := ctx.Writer.Hijack()
+	if err != nil {
+		p.tunnelSide.Close()
+		return
+	}
+
+	s.mutex.Lock()
+	p.postConn = nconn
+	s.mutex.Unlock()
+
+	go func() {
+		// client -> server data is encoded with base64.
+		// the response to the POST request is never sent.
+		rtspTunnelDecode(rw.Reader, p.tunnelSide)
+		nconn.Close()
+		p.tunnelSide.Close()
+	}()
+}
+
+func (s *rtspTunnelServer) onWebSocket(ctx *gin.Context) {
+	wsconn, err := websocket.NewServerConn(ctx.Writer, ctx.Request)
+	if err != nil {
+		return
+	}
+
+	serverSide, tunnelSide := s.newPipe(wsconn.RemoteAddr())
+
+	if !s.listener.push(serverSide) {
+		serverSide.Close()
+		tunnelSide.Close()
+		wsconn.Close()
+		return
+	}
+
+	readDone := make(chan struct{})
+	go func() {
+		defer close(readDone)
+
+		for {
+			byts, err := wsconn.ReadMessage()
+			if err != nil {
+				tunnelSide.Close()
+				return
+			}
+
+			_, err = tunnelSide.Write(byts)
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	buf := make([]byte, 4096)
+
+	for {
+		n, err := tunnelSide.Read(buf)
+		if err != nil {
+			break
+		}
+
+		err = wsconn.WriteBinary(buf[:n])
+		if err != nil {
+			break
+		}
+	}
+
+	tunnelSide.Close()
+	wsconn.Close()
+	<-readDone
+}
@@ -0,0 +1,482 @@
+package core
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/asticode/go-astits"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+const (
+	scte35TableID                = 0xFC
+	scte35RTPMap                 = "x-scte35/90000"
+	scte35CUEIIdentifier         = uint32('C')<<24 | uint32('U')<<16 | uint32('E')<<8 | uint32('I')
+	scte35SegmentationDescriptor = 0x02
+)
+
+// types of splice commands.
+const (
+	scte35CommandSpliceNull           = 0x00
+	scte35CommandSpliceSchedule       = 0x04
+	scte35CommandSpliceInsert         = 0x05
+	scte35CommandTimeSignal           = 0x06
+	scte35CommandBandwidthReservation = 0x07
+	scte35CommandPrivate              = 0xFF
+)
+
+var scte35CommandNames = map[uint8]string{
+	scte35CommandSpliceNull:           "spliceNull",
+	scte35CommandSpliceSchedule:       "spliceSchedule",
+	scte35CommandSpliceInsert:         "spliceInsert",
+	scte35CommandTimeSignal:           "timeSignal",
+	scte35CommandBandwidthReservation: "bandwidthReservation",
+	scte35CommandPrivate:              "private",
+}
+
+// segmentation types that start and end an ad break.
+var (
+	scte35SegmentationBreakStarts = map[uint8]struct{}{
+		0x22: {}, // break start
+		0x30: {}, // provider advertisement start
+		0x32: {}, // distributor advertisement start
+		0x34: {}, // provider placement opportunity start
+		0x36: {}, // distributor placement opportunity start
+		0x44: {}, // provider ad block start
+		0x46: {}, // distributor ad block start
+	}
+	scte35SegmentationBreakEnds = map[uint8]struct{}{
+		0x23: {}, // break end
+		0x31: {}, // provider advertisement end
+		0x33: {}, // distributor advertisement end
+		0x35: {}, // provider placement opportunity end
+		0x37: {}, // distributor placement opportunity end
+		0x45: {}, // provider ad block end
+		0x47: {}, // distributor ad block end
+	}
+)
+
+func scte35CRC32(byts []byte) uint32 {
+	crc := uint32(0xFFFFFFFF)
+	for _, b := range byts {
+		crc ^= uint32(b) << 24
+		for i := 0; i < 8; i++ {
+			if (crc & 0x80000000) != 0 {
+				crc = (crc << 1) ^ 0x04C11DB7
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return crc
+}
+
+func scte35Read33(b []byte) uint64 {
+	return uint64(b[0]&0x01)<<32 | uint64(b[1])<<24 | uint64(b[2])<<16 | uint64(b[3])<<8 | uint64(b[4])
+}
+
+// scte35Cue is the content of a SCTE-35 splice_info_section.
+type scte35Cue struct {
+	// name of the splice command.
+	Command string `json:"command"`
+
+	// "out" when the cue starts an ad break, "in" when it ends it,
+	// empty when the cue doesn't affect ad breaks.
+	Type string `json:"type"`
+
+	EventID            *uint32  `json:"eventId"`
+	Cancel             bool     `json:"cancel"`
+	Immediate          bool     `json:"immediate"`
+	Duration           *float64 `json:"duration"`
+	AutoReturn         bool     `json:"autoReturn"`
+	SegmentationTypeID *uint8   `json:"segmentationTypeId"`
+
+	// raw section, in order to allow receivers to decode fields that are not listed.
+	Data []byte `json:"data"`
+
+	// splice time, with pts_adjustment applied, in 90kHz units.
+	spliceTime *uint64
+}
+
+func scte35ReadSpliceTime(b []byte) (*uint64, int, error) {
+	if len(b) < 1 {
+		return nil, 0, fmt.Errorf("splice_time is truncated")
+	}
+
+	if (b[0] & 0x80) == 0 {
+		return nil, 1, nil
+	}
+
+	if len(b) < 5 {
+		return nil, 0, fmt.Errorf("splice_time is truncated")
+	}
+
+	v := scte35Read33(b)
+	return &v, 5, nil
+}
+
+func (c *scte35Cue) unmarshalSpliceInsert(b []byte) error {
+	if len(b) < 5 {
+		return fmt.Errorf("splice_insert is truncated")
+	}
+
+	eventID := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	c.EventID = &eventID
+	c.Cancel = (b[4] & 0x80) != 0
+	b = b[5:]
+
+	if c.Cancel {
+		return nil
+	}
+
+	if len(b) < 1 {
+		return fmt.Errorf("splice_insert is truncated")
+	}
+
+	outOfNetwork := (b[0] & 0x80) != 0
+	programSplice := (b[0] & 0x40) != 0
+	hasDuration := (b[0] & 0x20) != 0
+	c.Immediate = (b[0] & 0x10) != 0
+	b = b[1:]
+
+	if outOfNetwork {
+		c.Type = "out"
+	} else {
+		c.Type = "in"
+	}
+
+	if programSplice {
+		if !c.Immediate {
+			t, n, err := scte35ReadSpliceTime(b)
+			if err != nil {
+				return err
+			}
+			c.spliceTime = t
+			b = b[n:]
+		}
+	} else {
+		if len(b) < 1 {
+			return fmt.Errorf("splice_insert is truncated")
+		}
+		count := int(b[0])
+		b = b[1:]
+
+		for i := 0; i < count; i++ {
+			if len(b) < 1 {
+				return fmt.Errorf("splice_insert is truncated")
+			}
+			b = b[1:]
+
+			if !c.Immediate {
+				t, n, err := scte35ReadSpliceTime(b)
+				if err != nil {
+					return err
+				}
+
+				// use the splice time of the first component.
+				if c.spliceTime == nil {
+					c.spliceTime = t
+				}
+				b = b[n:]
+			}
+		}
+	}
+
+	if hasDuration {
+		if len(b) < 5 {
+			return fmt.Errorf("break_duration is truncated")
+		}
+		c.AutoReturn = (b[0] & 0x80) != 0
+		v := float64(scte35Read33(b)) / 90000
+		c.Duration = &v
+	}
+
+	return nil
+}
+
+func (c *scte35Cue) unmarshalSegmentationDescriptor(b []byte) error {
+	if len(b) < 5 {
+		return fmt.Errorf("segmentation_descriptor is truncated")
+	}
+
+	eventID := uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+	cancel := (b[4] & 0x80) != 0
+	b = b[5:]
+
+	if cancel {
+		if c.EventID == nil {
+			c.EventID = &eventID
+			c.Cancel = true
+		}
+		return nil
+	}
+
+	if len(b) < 1 {
+		return fmt.Errorf("segmentation_descriptor is truncated")
+	}
+
+	programSegmentation := (b[0] & 0x80) != 0
+	hasDuration := (b[0] & 0x40) != 0
+	b = b[1:]
+
+	if !programSegmentation {
+		if len(b) < 1 {
+			return fmt.Errorf("segmentation_descriptor is truncated")
+		}
+		n := 1 + int(b[0])*6
+		if len(b) < n {
+			return fmt.Errorf("segmentation_descriptor is truncated")
+		}
+		b = b[n:]
+	}
+
+	var duration *float64
+	if hasDuration {
+		if len(b) < 5 {
+			return fmt.Errorf("segmentation_descriptor is truncated")
+		}
+		v := float64(uint64(b[0])<<32|uint64(b[1])<<24|uint64(b[2])<<16|uint64(b[3])<<8|uint64(b[4])) / 90000
+		duration = &v
+		b = b[5:]
+	}
+
+	if len(b) < 2 {
+		return fmt.Errorf("segmentation_descriptor is truncated")
+	}
+	n := 2 + int(b[1])
+	if len(b) < n+1 {
+		return fmt.Errorf("segmentation_descriptor is truncated")
+	}
+	typeID := b[n]
+
+	// the first descriptor that affects ad breaks is used.
+	if c.Type != "" {
+		return nil
+	}
+
+	if _, ok := scte35SegmentationBreakStarts[typeID]; ok {
+		c.Type = "out"
+	} else if _, ok := scte35SegmentationBreakEnds[typeID]; ok {
+		c.Type = "in"
+	} else if c.SegmentationTypeID != nil {
+		return nil
+	}
+
+	c.EventID = &eventID
+	c.SegmentationTypeID = &typeID
+	c.Duration = duration
+
+	return nil
+}
+
+func (c *scte35Cue) unmarshalDescriptors(b []byte) error {
+	for len(b) != 0 {
+		if len(b) < 2 {
+			return fmt.Errorf("descriptor is truncated")
+		}
+
+		tag := b[0]
+		n := 2 + int(b[1])
+		if len(b) < n {
+			return fmt.Errorf("descriptor is truncated")
+		}
+		desc := b[2:n]
+		b = b[n:]
+
+		if tag != scte35SegmentationDescriptor || len(desc) < 4 ||
+			(uint32(desc[0])<<24|uint32(desc[1])<<16|uint32(desc[2])<<8|uint32(desc[3])) != scte35CUEIIdentifier {
+			continue
+		}
+
+		err := c.unmarshalSegmentationDescriptor(desc[4:])
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// unmarshal decodes a splice_info_section.
+// Bytes after the end of the section (i.e. stuffing) are ignored.
+func (c *scte35Cue) unmarshal(byts []byte) error {
+	if len(byts) < 3 || byts[0] != scte35TableID {
+		return fmt.Errorf("invalid table ID")
+	}
+
+	size := 3 + int(uint16(byts[1]&0x0F)<<8|uint16(byts[2]))
+	if len(byts) < size || size < 20 {
+		return fmt.Errorf("section is truncated")
+	}
+	byts = byts[:size]
+
+	if scte35CRC32(byts) != 0 {
+		return fmt.Errorf("CRC mismatch")
+	}
+
+	c.Data = byts
+
+	if (byts[4] & 0x80) != 0 {
+		return fmt.Errorf("encrypted sections are not supported")
+	}
+
+	ptsAdjustment := scte35Read33(byts[4:])
+	commandLength := int(uint16(byts[11]&0x0F)<<8 | uint16(byts[12]))
+	commandType := byts[13]
+	body := byts[14 : size-4]
+
+	if name, ok := scte35CommandNames[commandType]; ok {
+		c.Command = name
+	} else {
+		c.Command = "unknown"
+	}
+
+	// legacy sections don't specify the command length.
+	if commandLength == 0xFFF {
+		switch commandType {
+		case scte35CommandSpliceNull, scte35CommandBandwidthReservation:
+			commandLength = 0
+
+		case scte35CommandTimeSignal:
+			_, n, err := scte35ReadSpliceTime(body)
+			if err != nil {
+				return err
+			}
+			commandLength = n
+
+		default:
+			return fmt.Errorf("command length is missing")
+		}
+	}
+
+	if len(body) < commandLength+2 {
+		return fmt.Errorf("section is truncated")
+	}
+	command := body[:commandLength]
+	body = body[commandLength:]
+
+	switch commandType {
+	case scte35CommandSpliceInsert:
+		err := c.unmarshalSpliceInsert(command)
+		if err != nil {
+			return err
+		}
+
+	case scte35CommandTimeSignal:
+		t, _, err := scte35ReadSpliceTime(command)
+		if err != nil {
+			return err
+		}
+		c.spliceTime = t
+	}
+
+	descriptorsLength := int(uint16(body[0])<<8 | uint16(body[1]))
+	if len(body) < 2+descriptorsLength {
+		return fmt.Errorf("section is truncated")
+	}
+
+	err := c.unmarshalDescriptors(body[2 : 2+descriptorsLength])
+	if err != nil {
+		return err
+	}
+
+	if c.spliceTime != nil {
+		*c.spliceTime = (*c.spliceTime + ptsAdjustment) & 0x1FFFFFFFF
+	} else if c.Type != "" {
+		c.Immediate = true
+	}
+
+	return nil
+}
+
+// scte35PacketsParser is a astits.PacketsParser that extracts SCTE-35 sections.
+// Sections are returned as PES packets, whose PTS is the splice time.
+// Sections without a splice time are returned as PES packets without PTS.
+func scte35PacketsParser(ps []*astits.Packet) ([]*astits.DemuxerData, bool, error) {
+	if len(ps) == 0 || !ps[0].Header.PayloadUnitStartIndicator || len(ps[0].Payload) < 2 {
+		return nil, false, nil
+	}
+
+	pointer := int(ps[0].Payload[0])
+	if len(ps[0].Payload) <= 1+pointer || ps[0].Payload[1+pointer] != scte35TableID {
+		return nil, false, nil
+	}
+
+	var payload []byte
+	for _, p := range ps {
+		payload = append(payload, p.Payload...)
+	}
+
+	var cue scte35Cue
+	err := cue.unmarshal(payload[1+pointer:])
+	if err != nil {
+		// skip invalid sections, without stopping the stream.
+		return nil, true, nil
+	}
+
+	data := &astits.DemuxerData{
+		FirstPacket: ps[0],
+		PID:         ps[0].Header.PID,
+		PES: &astits.PESData{
+			Header: &astits.PESHeader{},
+			Data:   cue.Data,
+		},
+	}
+
+	if cue.spliceTime != nil {
+		data.PES.Header.OptionalHeader = &astits.PESOptionalHeader{
+			PTSDTSIndicator: astits.PTSDTSIndicatorOnlyPTS,
+			PTS:             &astits.ClockReference{Base: int64(*cue.spliceTime)},
+		}
+	}
+
+	return []*astits.DemuxerData{data}, true, nil
+}
+
+// scte35FindFormat finds the SCTE-35 track of a stream.
+func scte35FindFormat(medias media.Medias) (*media.Media, *formats.Generic) {
+	for _, medi := range medias {
+		for _, forma := range medi.Formats {
+			if tforma, ok := forma.(*formats.Generic); ok && tforma.RTPMap() == scte35RTPMap {
+				return medi, tforma
+			}
+		}
+	}
+	return nil, nil
+}
+
+// scte35UnitCue decodes the cue contained into a unit.
+// It returns whether the PTS of the unit is the splice time too.
+func scte35UnitCue(unit formatprocessor.Unit) (*scte35Cue, bool, error) {
+	tunit := unit.(*formatprocessor.UnitGeneric)
+
+	payload := tunit.Payload
+	hasPTS := true
+
+	// units received with RTP don't have a payload nor a PTS.
+	if payload == nil {
+		hasPTS = false
+		for _, pkt := range tunit.RTPPackets {
+			payload = append(payload, pkt.Payload...)
+		}
+	}
+
+	var cue scte35Cue
+	err := cue.unmarshal(payload)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &cue, hasPTS && !cue.Immediate, nil
+}
+
+// scte35DurationValue converts a duration in seconds into a time.Duration.
+func scte35DurationValue(v *float64) time.Duration {
+	if v == nil {
+		return 0
+	}
+	return time.Duration(*v * float64(time.Second))
+}
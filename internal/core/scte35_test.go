@@ -0,0 +1,209 @@
+package core
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/asticode/go-astits"
+	"github.com/stretchr/testify/require"
+)
+
+func scte35TestSection(ptsAdjustment uint64, commandType byte, command []byte, descriptors []byte) []byte {
+	b := []byte{
+		scte35TableID, 0, 0,
+		0,
+		byte(ptsAdjustment>>32) & 0x01, byte(ptsAdjustment >> 24), byte(ptsAdjustment >> 16),
+		byte(ptsAdjustment >> 8), byte(ptsAdjustment),
+		0,
+		0xFF, 0xF0 | byte(len(command)>>8), byte(len(command)),
+		commandType,
+	}
+	b = append(b, command...)
+	b = append(b, byte(len(descriptors)>>8), byte(len(descriptors)))
+	b = append(b, descriptors...)
+
+	size := len(b) + 4 - 3
+	b[1] = 0x30 | byte(size>>8)
+	b[2] = byte(size)
+
+	crc := scte35CRC32(b)
+	return append(b, byte(crc>>24), byte(crc>>16), byte(crc>>8), byte(crc))
+}
+
+func scte35TestSpliceTime(pts uint64) []byte {
+	return []byte{0xFE | byte(pts>>32)&0x01, byte(pts >> 24), byte(pts >> 16), byte(pts >> 8), byte(pts)}
+}
+
+func TestSCTE35CueUnmarshal(t *testing.T) {
+	uint32Ptr := func(v uint32) *uint32 { return &v }
+	uint8Ptr := func(v uint8) *uint8 { return &v }
+	float64Ptr := func(v float64) *float64 { return &v }
+	uint64Ptr := func(v uint64) *uint64 { return &v }
+
+	for _, ca := range []struct {
+		name    string
+		section []byte
+		cue     scte35Cue
+	}{
+		{
+			"splice insert out",
+			scte35TestSection(
+				90000,
+				scte35CommandSpliceInsert,
+				append(append(
+					[]byte{0x10, 0x00, 0x00, 0x01, 0x7F, 0xEF},
+					scte35TestSpliceTime(900000)...),
+					0xFE, 0x00, 0x29, 0x32, 0xE0, // auto return, 30s
+					0x00, 0x01, 0x00, 0x00),
+				nil),
+			scte35Cue{
+				Command:    "spliceInsert",
+				Type:       "out",
+				EventID:    uint32Ptr(0x10000001),
+				Duration:   float64Ptr(30),
+				AutoReturn: true,
+				spliceTime: uint64Ptr(990000),
+			},
+		},
+		{
+			"splice insert in immediate",
+			scte35TestSection(
+				0,
+				scte35CommandSpliceInsert,
+				[]byte{0x10, 0x00, 0x00, 0x02, 0x7F, 0x5F, 0x00, 0x01, 0x00, 0x00},
+				nil),
+			scte35Cue{
+				Command:   "spliceInsert",
+				Type:      "in",
+				EventID:   uint32Ptr(0x10000002),
+				Immediate: true,
+			},
+		},
+		{
+			"splice insert cancel",
+			scte35TestSection(
+				0,
+				scte35CommandSpliceInsert,
+				[]byte{0x10, 0x00, 0x00, 0x03, 0xFF},
+				nil),
+			scte35Cue{
+				Command: "spliceInsert",
+				EventID: uint32Ptr(0x10000003),
+				Cancel:  true,
+			},
+		},
+		{
+			"time signal with placement opportunity",
+			scte35TestSection(
+				0,
+				scte35CommandTimeSignal,
+				scte35TestSpliceTime(180000),
+				[]byte{
+					scte35SegmentationDescriptor, 20,
+					'C', 'U', 'E', 'I',
+					0x00, 0x00, 0x00, 0x05, 0x7F,
+					0xFF,
+					0x00, 0x00, 0x14, 0x99, 0x70, // 15s
+					0x00, 0x00,
+					0x34, 0x00, 0x00,
+				}),
+			scte35Cue{
+				Command:            "timeSignal",
+				Type:               "out",
+				EventID:            uint32Ptr(5),
+				Duration:           float64Ptr(15),
+				SegmentationTypeID: uint8Ptr(0x34),
+				spliceTime:         uint64Ptr(180000),
+			},
+		},
+		{
+			"splice null",
+			scte35TestSection(0, scte35CommandSpliceNull, nil, nil),
+			scte35Cue{
+				Command: "spliceNull",
+			},
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			var cue scte35Cue
+			err := cue.unmarshal(append(ca.section, 0xFF, 0xFF))
+			require.NoError(t, err)
+			ca.cue.Data = ca.section
+			require.Equal(t, ca.cue, cue)
+		})
+	}
+
+	t.Run("crc mismatch", func(t *testing.T) {
+		section := scte35TestSection(0, scte35CommandSpliceNull, nil, nil)
+		section[len(section)-1]++
+
+		var cue scte35Cue
+		err := cue.unmarshal(section)
+		require.EqualError(t, err, "CRC mismatch")
+	})
+}
+
+func TestSCTE35PacketsParser(t *testing.T) {
+	var buf bytes.Buffer
+	mux := astits.NewMuxer(context.Background(), &buf)
+
+	err := mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 256,
+		StreamType:    astits.StreamTypeH264Video,
+	})
+	require.NoError(t, err)
+
+	err = mux.AddElementaryStream(astits.PMTElementaryStream{
+		ElementaryPID: 257,
+		StreamType:    mpegtsStreamTypeSCTE35,
+	})
+	require.NoError(t, err)
+
+	mux.SetPCRPID(256)
+
+	_, err = mux.WriteTables()
+	require.NoError(t, err)
+
+	section := scte35TestSection(0, scte35CommandTimeSignal, scte35TestSpliceTime(180000), nil)
+
+	pkt := make([]byte, 188)
+	for i := range pkt {
+		pkt[i] = 0xFF
+	}
+	pkt[0] = 0x47
+	pkt[1] = 0x40 | 0x01 // payload unit start indicator
+	pkt[2] = 0x01
+	pkt[3] = 0x10
+	pkt[4] = 0x00 // pointer field
+	copy(pkt[5:], section)
+	buf.Write(pkt)
+
+	// the section is returned when the next one begins.
+	pkt2 := append([]byte(nil), pkt...)
+	pkt2[3] = 0x11
+	buf.Write(pkt2)
+
+	dem := astits.NewDemuxer(context.Background(), &buf,
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
+
+	tracks, err := mpegtsFindTracks(dem)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(tracks))
+	require.IsType(t, &mpegtsCodecSCTE35{}, tracks[1].Codec)
+
+	for {
+		data, err := dem.NextData()
+		require.NoError(t, err)
+
+		if data.PID != 257 {
+			continue
+		}
+
+		require.Equal(t, section, data.PES.Data)
+		require.Equal(t, uint8(astits.PTSDTSIndicatorOnlyPTS), data.PES.Header.OptionalHeader.PTSDTSIndicator)
+		require.Equal(t, int64(180000), data.PES.Header.OptionalHeader.PTS.Base)
+		break
+	}
+}
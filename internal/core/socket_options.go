@@ -0,0 +1,93 @@
+package core
+
+import (
+	"net"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+// applyTCPSocketOptions applies socket options to a TCP connection.
+func applyTCPSocketOptions(nconn net.Conn, opts conf.SocketOptions) error {
+	tconn, ok := nconn.(*net.TCPConn)
+	if !ok {
+		return nil
+	}
+
+	if opts.ReadBufferSize != 0 {
+		err := tconn.SetReadBuffer(int(opts.ReadBufferSize))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.WriteBufferSize != 0 {
+		err := tconn.SetWriteBuffer(int(opts.WriteBufferSize))
+		if err != nil {
+			return err
+		}
+	}
+
+	return tconn.SetNoDelay(!opts.Nagle)
+}
+
+// applyUDPSocketOptions applies socket options to a UDP connection.
+func applyUDPSocketOptions(pc net.PacketConn, opts conf.SocketOptions) error {
+	uconn, ok := pc.(*net.UDPConn)
+	if !ok {
+		return nil
+	}
+
+	if opts.ReadBufferSize != 0 {
+		err := uconn.SetReadBuffer(int(opts.ReadBufferSize))
+		if err != nil {
+			return err
+		}
+	}
+
+	if opts.WriteBufferSize != 0 {
+		err := uconn.SetWriteBuffer(int(opts.WriteBufferSize))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// socketOptionsListener is a net.Listener that applies socket options
+// to accepted connections.
+type socketOptionsListener struct {
+	net.Listener
+	opts conf.SocketOptions
+}
+
+func newSocketOptionsListener(ln net.Listener, opts conf.SocketOptions) net.Listener {
+	if opts == (conf.SocketOptions{}) {
+		return ln
+	}
+
+	return &socketOptionsListener{
+		Listener: ln,
+		opts:     opts,
+	}
+}
+
+// Accept implements net.Listener.
+// Connections whose options can't be applied are discarded, since returning
+// an error would stop the server that is using the listener.
+func (l *socketOptionsListener) Accept() (net.Conn, error) {
+	for {
+		nconn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+
+		err = applyTCPSocketOptions(nconn, l.opts)
+		if err != nil {
+			nconn.Close()
+			continue
+		}
+
+		return nconn, nil
+	}
+}
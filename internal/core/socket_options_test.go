@@ -0,0 +1,52 @@
+package core
+
+import (
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestSocketOptionsListener(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:9993")
+	require.NoError(t, err)
+	defer ln.Close()
+
+	require.Equal(t, ln, newSocketOptionsListener(ln, conf.SocketOptions{}))
+
+	ln2 := newSocketOptionsListener(ln, conf.SocketOptions{
+		ReadBufferSize:  1024 * 1024,
+		WriteBufferSize: 1024 * 1024,
+		Nagle:           true,
+	})
+
+	conn, err := net.Dial("tcp", "127.0.0.1:9993")
+	require.NoError(t, err)
+	defer conn.Close()
+
+	sconn, err := ln2.Accept()
+	require.NoError(t, err)
+	defer sconn.Close()
+
+	_, err = conn.Write([]byte("test"))
+	require.NoError(t, err)
+
+	buf := make([]byte, 4)
+	_, err = sconn.Read(buf)
+	require.NoError(t, err)
+	require.Equal(t, []byte("test"), buf)
+}
+
+func TestApplyUDPSocketOptions(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:9994")
+	require.NoError(t, err)
+	defer pc.Close()
+
+	err = applyUDPSocketOptions(pc, conf.SocketOptions{
+		ReadBufferSize:  1024 * 1024,
+		WriteBufferSize: 1024 * 1024,
+	})
+	require.NoError(t, err)
+}
@@ -0,0 +1,168 @@
+package core
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type sourceStartLimiterParent interface {
+	logger.Writer
+}
+
+type sourceStartProgressAPI struct {
+	Total      int     `json:"total"`
+	Queued     int     `json:"queued"`
+	Connecting int     `json:"connecting"`
+	Ready      int     `json:"ready"`
+	Failed     int     `json:"failed"`
+	Complete   bool    `json:"complete"`
+	Elapsed    *string `json:"elapsed"`
+}
+
+// sourceStartLimiter limits the number of static sources that are performing
+// their first connection attempt at the same time, and tracks their progress.
+// Progress is tracked in batches: a batch starts when a source is added while
+// no other source is starting, and ends when all sources of the batch
+// have completed their first attempt.
+type sourceStartLimiter struct {
+	parent sourceStartLimiterParent
+
+	sem chan struct{}
+
+	mutex      sync.Mutex
+	batchStart time.Time
+	batchEnd   time.Time
+	total      int
+	queued     int
+	connecting int
+	ready      int
+	failed     int
+}
+
+func newSourceStartLimiter(
+	concurrency int,
+	parent sourceStartLimiterParent,
+) *sourceStartLimiter {
+	return &sourceStartLimiter{
+		parent: parent,
+		sem:    make(chan struct{}, concurrency),
+	}
+}
+
+func (l *sourceStartLimiter) inProgress() bool {
+	return l.queued != 0 || l.connecting != 0
+}
+
+// add is called when a source is started.
+func (l *sourceStartLimiter) add() {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	if !l.inProgress() {
+		l.batchStart = time.Now()
+		l.total = 0
+		l.ready = 0
+		l.failed = 0
+	}
+
+	l.total++
+	l.queued++
+}
+
+// acquire waits until the source can connect.
+// It returns false if the source has been stopped in the meanwhile.
+func (l *sourceStartLimiter) acquire(ctx context.Context) bool {
+	select {
+	case l.sem <- struct{}{}:
+	case <-ctx.Done():
+		l.mutex.Lock()
+		defer l.mutex.Unlock()
+		l.queued--
+		l.total--
+		l.checkComplete()
+		return false
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+	l.queued--
+	l.connecting++
+	return true
+}
+
+// release is called when the first connection attempt of a source has completed.
+func (l *sourceStartLimiter) release(ready bool) {
+	<-l.sem
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.connecting--
+	if ready {
+		l.ready++
+	} else {
+		l.failed++
+	}
+
+	done := l.ready + l.failed
+	if l.total >= 10 && done != l.total && done%(l.total/10) == 0 {
+		l.parent.Log(logger.Info, "static sources started: %d/%d", done, l.total)
+	}
+
+	l.checkComplete()
+}
+
+// abort is called when a source is stopped during its first connection attempt.
+func (l *sourceStartLimiter) abort() {
+	<-l.sem
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	l.connecting--
+	l.total--
+	l.checkComplete()
+}
+
+func (l *sourceStartLimiter) checkComplete() {
+	if l.inProgress() {
+		return
+	}
+
+	l.batchEnd = time.Now()
+
+	if l.total != 0 {
+		l.parent.Log(logger.Info, "%d static sources started in %v (%d ready, %d failed)",
+			l.total, l.batchEnd.Sub(l.batchStart).Round(time.Millisecond), l.ready, l.failed)
+	}
+}
+
+func (l *sourceStartLimiter) apiProgress() sourceStartProgressAPI {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := sourceStartProgressAPI{
+		Total:      l.total,
+		Queued:     l.queued,
+		Connecting: l.connecting,
+		Ready:      l.ready,
+		Failed:     l.failed,
+		Complete:   !l.inProgress(),
+	}
+
+	if !l.batchStart.IsZero() {
+		var elapsed time.Duration
+		if ret.Complete {
+			elapsed = l.batchEnd.Sub(l.batchStart)
+		} else {
+			elapsed = time.Since(l.batchStart)
+		}
+		v := elapsed.Round(time.Millisecond).String()
+		ret.Elapsed = &v
+	}
+
+	return ret
+}
@@ -2,17 +2,43 @@ package core
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math/rand"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/externalcmd"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
-const (
-	sourceStaticRetryPause = 5 * time.Second
-)
+// errSourceStaticStartAborted is returned when a source is stopped while waiting
+// for its turn to connect.
+var errSourceStaticStartAborted = errors.New("source start aborted")
+
+// sourceStaticRetryPause returns the pause that precedes a reconnection attempt.
+// The pause starts from sourceRetryMin and is doubled after every consecutive failure,
+// until it reaches sourceRetryMax. Then, jitter is applied.
+func sourceStaticRetryPause(cnf *conf.PathConf, attempt int) time.Duration {
+	pause := time.Duration(cnf.SourceRetryMin)
+	max := time.Duration(cnf.SourceRetryMax)
+
+	for i := 0; i < attempt && pause < max; i++ {
+		pause *= 2
+	}
+
+	if pause > max {
+		pause = max
+	}
+
+	if cnf.SourceRetryJitter != 0 {
+		pause += time.Duration((rand.Float64()*2 - 1) * cnf.SourceRetryJitter * float64(pause))
+	}
+
+	return pause
+}
 
 type sourceStaticImpl interface {
 	logger.Writer
@@ -28,13 +54,23 @@ type sourceStaticParent interface {
 
 // sourceStatic is a static source.
 type sourceStatic struct {
-	conf   *conf.PathConf
-	parent sourceStaticParent
+	conf         *conf.PathConf
+	startLimiter *sourceStartLimiter
+	parent       sourceStaticParent
 
 	ctx       context.Context
 	ctxCancel func()
 	impl      sourceStaticImpl
+	stats     *sourceStaticStats
 	running   bool
+	matches   []string
+	query     string
+	protocol  string
+	limited   bool
+
+	// error that caused the source to stop reconnecting.
+	failedErrMutex sync.Mutex
+	failedErr      error
 
 	// in
 	chReloadConf                  chan *conf.PathConf
@@ -47,14 +83,20 @@ type sourceStatic struct {
 
 func newSourceStatic(
 	cnf *conf.PathConf,
+	matches []string,
 	readTimeout conf.StringDuration,
 	writeTimeout conf.StringDuration,
 	readBufferCount int,
+	externalCmdPool *externalcmd.Pool,
+	startLimiter *sourceStartLimiter,
 	parent sourceStaticParent,
 ) *sourceStatic {
 	s := &sourceStatic{
 		conf:                          cnf,
+		startLimiter:                  startLimiter,
+		matches:                       matches,
 		parent:                        parent,
+		stats:                         newSourceStaticStats(),
 		chReloadConf:                  make(chan *conf.PathConf),
 		chSourceStaticImplSetReady:    make(chan pathSourceStaticSetReadyReq),
 		chSourceStaticImplSetNotReady: make(chan pathSourceStaticSetNotReadyReq),
@@ -67,6 +109,7 @@ func newSourceStatic(
 			readTimeout,
 			writeTimeout,
 			readBufferCount,
+			s.stats,
 			s)
 
 	case strings.HasPrefix(cnf.Source, "rtmp://") ||
@@ -74,6 +117,7 @@ func newSourceStatic(
 		s.impl = newRTMPSource(
 			readTimeout,
 			writeTimeout,
+			s.stats,
 			s)
 
 	case strings.HasPrefix(cnf.Source, "http://") ||
@@ -83,12 +127,34 @@ func newSourceStatic(
 
 	case strings.HasPrefix(cnf.Source, "udp://"):
 		s.impl = newUDPSource(
+			readTimeout,
+			s.stats,
+			s)
+
+	case strings.HasPrefix(cnf.Source, "pipe://") ||
+		cnf.Source == "stdin":
+		s.impl = newPipeSource(
 			readTimeout,
 			s)
 
+	case strings.HasPrefix(cnf.Source, "exec://"):
+		s.impl = newExecSource(
+			readTimeout,
+			externalCmdPool,
+			s)
+
+	case strings.HasPrefix(cnf.Source, "file://") ||
+		strings.HasPrefix(cnf.Source, "s3://"):
+		s.impl = newFileSource(
+			s)
+
 	case cnf.Source == "rpiCamera":
 		s.impl = newRPICameraSource(
 			s)
+
+	case cnf.Source == "testsrc":
+		s.impl = newTestsrcSource(
+			s)
 	}
 
 	return s
@@ -100,14 +166,32 @@ func (s *sourceStatic) close() {
 	}
 }
 
-func (s *sourceStatic) start() {
+// start starts the source.
+// query and protocol are the query and the protocol of the reader that requested the source,
+// and are used to fill templates of the source URL.
+func (s *sourceStatic) start(query string, protocol string) {
 	if s.running {
 		panic("should not happen")
 	}
 
 	s.running = true
+	s.query = query
+	s.protocol = protocol
+	s.setFailedErr(nil)
 	s.impl.Log(logger.Info, "started")
 
+	// only sources that connect to remote servers are limited.
+	// On-demand sources are not limited, since a reader is waiting for them.
+	switch s.impl.(type) {
+	case *rtspSource, *rtmpSource, *hlsSource:
+		s.limited = s.startLimiter != nil && !s.conf.SourceOnDemand
+	default:
+		s.limited = false
+	}
+	if s.limited {
+		s.startLimiter.add()
+	}
+
 	s.ctx, s.ctxCancel = context.WithCancel(context.Background())
 	s.done = make(chan struct{})
 
@@ -140,59 +224,145 @@ func (s *sourceStatic) run() {
 	implErr := make(chan error)
 	innerReloadConf := make(chan *conf.PathConf)
 
-	recreate := func() {
+	recreate := func(waitTurn bool) {
 		innerCtx, innerCtxCancel = context.WithCancel(context.Background())
+		ctx := innerCtx
 		go func() {
-			implErr <- s.impl.run(innerCtx, s.conf, innerReloadConf)
+			if waitTurn && !s.startLimiter.acquire(ctx) {
+				implErr <- errSourceStaticStartAborted
+				return
+			}
+			implErr <- s.impl.run(ctx, s.confWithTemplates(s.conf), innerReloadConf)
 		}()
 	}
 
-	recreate()
+	// the first connection attempt of a limited source waits for its turn.
+	firstAttempt := s.limited
+	recreate(firstAttempt)
 
 	recreating := false
 	recreateTimer := newEmptyTimer()
 
+	// consecutive reconnection attempts.
+	// When sourceRetryMaxCount is reached, the source stops reconnecting.
+	retries := 0
+	failed := false
+
+	// when the source stops, the path becomes not ready but keeps its readers,
+	// that are closed only if the source can't be reconnected.
+	// Otherwise the stream is reannounced and readers resume reading.
+	notReadyPending := false
+	reconnecting := false
+
 	for {
 		select {
 		case err := <-implErr:
 			innerCtxCancel()
 			s.impl.Log(logger.Info, "ERR: %v", err)
 			recreating = true
-			recreateTimer = time.NewTimer(sourceStaticRetryPause)
+
+			if firstAttempt {
+				firstAttempt = false
+				s.startLimiter.release(false)
+			}
+
+			if s.conf.SourceRetryMaxCount != 0 && retries >= s.conf.SourceRetryMaxCount {
+				s.impl.Log(logger.Error, "source has failed after %d reconnection attempts, giving up", retries)
+				failed = true
+				s.setFailedErr(err)
+			} else {
+				recreateTimer = time.NewTimer(sourceStaticRetryPause(s.conf, retries))
+				retries++
+			}
+
+			if reconnecting || (failed && notReadyPending) {
+				notReadyPending = false
+				reconnecting = false
+
+				req := pathSourceStaticSetNotReadyReq{err: err, res: make(chan struct{})}
+				s.parent.sourceStaticSetNotReady(s.ctx, req)
+				<-req.res
+			}
 
 		case newConf := <-s.chReloadConf:
 			s.conf = newConf
+
+			// a new configuration restarts a source that has stopped reconnecting.
+			if failed {
+				failed = false
+				retries = 0
+				s.setFailedErr(nil)
+				recreateTimer = time.NewTimer(0)
+			}
+
 			if !recreating {
 				cReloadConf := innerReloadConf
 				cInnerCtx := innerCtx
+				cConf := s.confWithTemplates(newConf)
 				go func() {
 					select {
-					case cReloadConf <- newConf:
+					case cReloadConf <- cConf:
 					case <-cInnerCtx.Done():
 					}
 				}()
 			}
 
 		case req := <-s.chSourceStaticImplSetReady:
+			retries = 0
+			if firstAttempt {
+				firstAttempt = false
+				s.startLimiter.release(true)
+			}
+			if notReadyPending {
+				notReadyPending = false
+				reconnecting = false
+				req.reannounce = true
+			}
 			s.parent.sourceStaticSetReady(s.ctx, req)
 
 		case req := <-s.chSourceStaticImplSetNotReady:
+			notReadyPending = true
+			req.keepReaders = true
 			s.parent.sourceStaticSetNotReady(s.ctx, req)
 
 		case <-recreateTimer.C:
-			recreate()
+			s.stats.addReconnect()
+			recreate(false)
 			recreating = false
+			reconnecting = notReadyPending
 
 		case <-s.ctx.Done():
 			if !recreating {
 				innerCtxCancel()
-				<-implErr
+				err := <-implErr
+
+				if firstAttempt && err != errSourceStaticStartAborted {
+					s.startLimiter.abort()
+				}
 			}
 			return
 		}
 	}
 }
 
+// confWithTemplates returns a configuration in which templates of the source are filled.
+func (s *sourceStatic) confWithTemplates(cnf *conf.PathConf) *conf.PathConf {
+	if !cnf.HasSourceTemplate() {
+		return cnf
+	}
+
+	newConf := cnf.Clone()
+	newConf.Source = cnf.SourceWithTemplates(s.query, s.matches, s.protocol)
+	return newConf
+}
+
+// templatesMatch checks whether templates of the source, filled with the query and
+// the protocol of a reader, are equal to the ones filled when the source was started.
+func (s *sourceStatic) templatesMatch(cnf *conf.PathConf, query string, protocol string) bool {
+	return cnf.SourceWithTemplates(query, s.matches, protocol) ==
+		cnf.SourceWithTemplates(s.query, s.matches, s.protocol)
+}
+
 func (s *sourceStatic) reloadConf(newConf *conf.PathConf) {
 	select {
 	case s.chReloadConf <- newConf:
@@ -205,6 +375,34 @@ func (s *sourceStatic) apiSourceDescribe() interface{} {
 	return s.impl.apiSourceDescribe()
 }
 
+func (s *sourceStatic) setFailedErr(err error) {
+	s.failedErrMutex.Lock()
+	defer s.failedErrMutex.Unlock()
+	s.failedErr = err
+}
+
+// apiSourceError returns the error that caused the source to stop reconnecting, if any.
+func (s *sourceStatic) apiSourceError() *string {
+	s.failedErrMutex.Lock()
+	defer s.failedErrMutex.Unlock()
+
+	if s.failedErr == nil {
+		return nil
+	}
+
+	v := s.failedErr.Error()
+	return &v
+}
+
+func (s *sourceStatic) apiSourceStats() *sourceStaticStatsAPI {
+	switch s.impl.(type) {
+	case *rtspSource, *rtmpSource, *udpSource:
+		stats := s.stats.apiDescribe()
+		return &stats
+	}
+	return nil
+}
+
 // sourceStaticImplSetReady is called by a sourceStaticImpl.
 func (s *sourceStatic) sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes {
 	req.res = make(chan pathSourceStaticSetReadyRes)
@@ -0,0 +1,83 @@
+package core
+
+import (
+	"math"
+	"sync/atomic"
+	"time"
+)
+
+// sourceStaticStats contains statistics of a static source,
+// that allow to detect unreliable upstream servers and cameras.
+type sourceStaticStats struct {
+	rtt         *int64
+	packetsLost *uint64
+	reconnects  *uint64
+
+	// filled by sources that generate RTCP receiver reports.
+	hasReceptionQuality *int32
+	jitter              *int64
+	fractionLost        *uint64
+}
+
+func newSourceStaticStats() *sourceStaticStats {
+	return &sourceStaticStats{
+		rtt:                 new(int64),
+		packetsLost:         new(uint64),
+		reconnects:          new(uint64),
+		hasReceptionQuality: new(int32),
+		jitter:              new(int64),
+		fractionLost:        new(uint64),
+	}
+}
+
+func (st *sourceStaticStats) setRTT(rtt time.Duration) {
+	atomic.StoreInt64(st.rtt, int64(rtt))
+}
+
+func (st *sourceStaticStats) addPacketsLost(n uint64) {
+	atomic.AddUint64(st.packetsLost, n)
+}
+
+func (st *sourceStaticStats) addReconnect() {
+	atomic.AddUint64(st.reconnects, 1)
+}
+
+// setReceptionQuality is called when a RTCP receiver report is generated.
+func (st *sourceStaticStats) setReceptionQuality(jitter time.Duration, fractionLost float64) {
+	atomic.StoreInt64(st.jitter, int64(jitter))
+	atomic.StoreUint64(st.fractionLost, math.Float64bits(fractionLost))
+	atomic.StoreInt32(st.hasReceptionQuality, 1)
+}
+
+type sourceStaticStatsAPI struct {
+	// RTT in seconds, nil when it can't be measured.
+	RTT         *float64 `json:"rtt"`
+	PacketsLost uint64   `json:"packetsLost"`
+	Reconnects  uint64   `json:"reconnects"`
+	// jitter in seconds and fraction of lost packets, taken from the last RTCP receiver report.
+	// They are nil when receiver reports are not generated.
+	Jitter       *float64 `json:"jitter"`
+	FractionLost *float64 `json:"fractionLost"`
+}
+
+func (st *sourceStaticStats) apiDescribe() sourceStaticStatsAPI {
+	ret := sourceStaticStatsAPI{
+		PacketsLost: atomic.LoadUint64(st.packetsLost),
+		Reconnects:  atomic.LoadUint64(st.reconnects),
+	}
+
+	if rtt := atomic.LoadInt64(st.rtt); rtt != 0 {
+		v := time.Duration(rtt).Seconds()
+		ret.RTT = &v
+	}
+
+	if atomic.LoadInt32(st.hasReceptionQuality) == 1 {
+		jitter := time.Duration(atomic.LoadInt64(st.jitter)).Seconds()
+		ret.Jitter = &jitter
+
+		fractionLost := math.Float64frombits(atomic.LoadUint64(st.fractionLost))
+		ret.FractionLost = &fractionLost
+	}
+
+	return ret
+}
@@ -0,0 +1,67 @@
+package core
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+func TestSourceStaticRetryPause(t *testing.T) {
+	cnf := &conf.PathConf{
+		SourceRetryMin: conf.StringDuration(1 * time.Second),
+		SourceRetryMax: conf.StringDuration(5 * time.Second),
+	}
+
+	for i, ca := range []time.Duration{
+		1 * time.Second,
+		2 * time.Second,
+		4 * time.Second,
+		5 * time.Second,
+		5 * time.Second,
+	} {
+		require.Equal(t, ca, sourceStaticRetryPause(cnf, i))
+	}
+
+	cnf.SourceRetryJitter = 0.5
+
+	for i := 0; i < 100; i++ {
+		pause := sourceStaticRetryPause(cnf, 10)
+		require.GreaterOrEqual(t, pause, 2500*time.Millisecond)
+		require.LessOrEqual(t, pause, 7500*time.Millisecond)
+	}
+}
+
+func TestSourceStaticRetryMaxCount(t *testing.T) {
+	p, ok := newInstance("api: yes\n" +
+		"paths:\n" +
+		"  mypath:\n" +
+		"    source: rtsp://127.0.0.1:8556/nonexisting\n" +
+		"    sourceRetryMin: 100ms\n" +
+		"    sourceRetryMax: 200ms\n" +
+		"    sourceRetryMaxCount: 2\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	sourceError := func() *string {
+		var out struct {
+			Items map[string]struct {
+				SourceError *string `json:"sourceError"`
+			} `json:"items"`
+		}
+		err := httpRequest(http.MethodGet, "http://localhost:9997/v1/paths/list", nil, &out)
+		require.NoError(t, err)
+		return out.Items["mypath"].SourceError
+	}
+
+	require.Nil(t, sourceError())
+
+	time.Sleep(1 * time.Second)
+
+	err := sourceError()
+	require.NotNil(t, err)
+	require.Contains(t, *err, "connection refused")
+}
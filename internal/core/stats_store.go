@@ -0,0 +1,341 @@
+package core
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const statsStoreSavePeriod = 10 * time.Second
+
+type statsStorePath struct {
+	BytesReceived  uint64  `json:"bytesReceived"`
+	PublishSeconds float64 `json:"publishSeconds"`
+}
+
+type statsStoreData struct {
+	TotalSessions      uint64                     `json:"totalSessions"`
+	TotalBytesReceived uint64                     `json:"totalBytesReceived"`
+	Paths              map[string]*statsStorePath `json:"paths"`
+}
+
+func (d *statsStoreData) clone() *statsStoreData {
+	ret := &statsStoreData{
+		TotalSessions:      d.TotalSessions,
+		TotalBytesReceived: d.TotalBytesReceived,
+		Paths:              make(map[string]*statsStorePath, len(d.Paths)),
+	}
+	for name, pa := range d.Paths {
+		cpa := *pa
+		ret.Paths[name] = &cpa
+	}
+	return ret
+}
+
+type statsStoreReadyPath struct {
+	since time.Time
+
+	// counter of the path, nil when the path was ready before the store started.
+	bytesReceived *uint64
+}
+
+type statsStoreParent interface {
+	logger.Writer
+}
+
+// statsStore keeps cumulative counters and persists them into a file,
+// in order to preserve them across restarts.
+// Sessions, publish time and bytes received are counted from events,
+// that carry the byte counters of paths.
+type statsStore struct {
+	filePath    string
+	pathManager apiPathManager
+	events      *apiEvents
+	metrics     *metrics
+	parent      statsStoreParent
+
+	ctx       context.Context
+	ctxCancel func()
+	mutex     sync.Mutex
+	data      *statsStoreData
+	ch        chan apiEvent
+
+	// per-path state
+	lastBytes  map[string]uint64
+	readyPaths map[string]*statsStoreReadyPath
+
+	// out
+	done chan struct{}
+}
+
+func newStatsStore(
+	parentCtx context.Context,
+	filePath string,
+	pathManager apiPathManager,
+	events *apiEvents,
+	metrics *metrics,
+	parent statsStoreParent,
+) (*statsStore, error) {
+	ctx, ctxCancel := context.WithCancel(parentCtx)
+
+	s := &statsStore{
+		filePath:    filePath,
+		pathManager: pathManager,
+		events:      events,
+		metrics:     metrics,
+		parent:      parent,
+		ctx:         ctx,
+		ctxCancel:   ctxCancel,
+		lastBytes:   make(map[string]uint64),
+		readyPaths:  make(map[string]*statsStoreReadyPath),
+		done:        make(chan struct{}),
+	}
+
+	err := s.load()
+	if err != nil {
+		ctxCancel()
+		return nil, err
+	}
+
+	s.Log(logger.Info, "loaded from %s", filePath)
+
+	s.subscribe()
+
+	if s.metrics != nil {
+		s.metrics.statsStoreSet(s)
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+func (s *statsStore) close() {
+	s.Log(logger.Info, "closing")
+	s.ctxCancel()
+	<-s.done
+}
+
+// Log is the main logging function.
+func (s *statsStore) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[stats] "+format, args...)
+}
+
+func (s *statsStore) load() error {
+	s.data = &statsStoreData{
+		Paths: make(map[string]*statsStorePath),
+	}
+
+	byts, err := os.ReadFile(s.filePath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return err
+	}
+
+	err = json.Unmarshal(byts, s.data)
+	if err != nil {
+		return err
+	}
+
+	if s.data.Paths == nil {
+		s.data.Paths = make(map[string]*statsStorePath)
+	}
+
+	return nil
+}
+
+func (s *statsStore) save() error {
+	s.mutex.Lock()
+	byts, err := json.Marshal(s.data)
+	s.mutex.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// write into a temporary file and rename it, in order to avoid corrupting
+	// the existing file in case of crash.
+	tempPath := filepath.Join(filepath.Dir(s.filePath), "."+filepath.Base(s.filePath)+".tmp")
+
+	err = os.WriteFile(tempPath, byts, 0o644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tempPath, s.filePath)
+}
+
+func (s *statsStore) run() {
+	defer close(s.done)
+
+	saveTicker := time.NewTicker(statsStoreSavePeriod)
+	defer saveTicker.Stop()
+
+outer:
+	for {
+		select {
+		case ev, ok := <-s.ch:
+			if !ok {
+				s.Log(logger.Warn, "too many events, some sessions may not have been counted")
+				s.subscribe()
+				continue
+			}
+			s.handleEvent(ev)
+
+		case <-saveTicker.C:
+			s.update()
+
+			err := s.save()
+			if err != nil {
+				s.Log(logger.Warn, "unable to save: %v", err)
+			}
+
+		case <-s.ctx.Done():
+			break outer
+		}
+	}
+
+	s.events.unsubscribe(s.ch)
+
+	// process events that are still queued
+	for ev := range s.ch {
+		s.handleEvent(ev)
+	}
+
+	s.update()
+
+	if s.metrics != nil {
+		s.metrics.statsStoreSet(nil)
+	}
+
+	err := s.save()
+	if err != nil {
+		s.Log(logger.Warn, "unable to save: %v", err)
+	}
+}
+
+// subscribe subscribes to events and sets the initial state of paths,
+// that is used as a baseline.
+func (s *statsStore) subscribe() {
+	s.ch = s.events.subscribe()
+
+	res := s.pathManager.apiPathsList()
+	if res.err != nil {
+		return
+	}
+
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	readyPaths := make(map[string]*statsStoreReadyPath)
+
+	for name, i := range res.data.Items {
+		if i.SourceReady {
+			rp, ok := s.readyPaths[name]
+			if !ok {
+				s.lastBytes[name] = i.BytesReceived
+				rp = &statsStoreReadyPath{since: now}
+			}
+			readyPaths[name] = rp
+		}
+	}
+
+	s.readyPaths = readyPaths
+}
+
+func (s *statsStore) path(name string) *statsStorePath {
+	pa, ok := s.data.Paths[name]
+	if !ok {
+		pa = &statsStorePath{}
+		s.data.Paths[name] = pa
+	}
+	return pa
+}
+
+func (s *statsStore) addBytes(name string, bytesReceived *uint64) {
+	if bytesReceived == nil {
+		return
+	}
+	cur := atomic.LoadUint64(bytesReceived)
+
+	// paths are recreated when they become idle, resetting their counters
+	delta := cur
+	if last, ok := s.lastBytes[name]; ok && cur >= last {
+		delta = cur - last
+	}
+	s.lastBytes[name] = cur
+
+	if delta != 0 {
+		s.path(name).BytesReceived += delta
+		s.data.TotalBytesReceived += delta
+	}
+}
+
+func (s *statsStore) handleEvent(ev apiEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch ev.Type {
+	case apiEventClientConnect:
+		s.data.TotalSessions++
+
+	case apiEventPathReady:
+		s.addBytes(ev.Path, ev.bytesReceived)
+		s.readyPaths[ev.Path] = &statsStoreReadyPath{
+			since:         ev.Time,
+			bytesReceived: ev.bytesReceived,
+		}
+
+	case apiEventPathNotReady:
+		s.addBytes(ev.Path, ev.bytesReceived)
+
+		if rp, ok := s.readyPaths[ev.Path]; ok {
+			s.path(ev.Path).PublishSeconds += ev.Time.Sub(rp.since).Seconds()
+			delete(s.readyPaths, ev.Path)
+		}
+	}
+}
+
+// update adds bytes received and publish time of paths that are ready.
+func (s *statsStore) update() {
+	now := time.Now()
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, rp := range s.readyPaths {
+		s.addBytes(name, rp.bytesReceived)
+		s.path(name).PublishSeconds += now.Sub(rp.since).Seconds()
+		rp.since = now
+	}
+}
+
+// apiStats is called by metrics.
+func (s *statsStore) apiStats() *statsStoreData {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	data := s.data.clone()
+	now := time.Now()
+
+	// add the publish time of paths that are ready
+	for name, rp := range s.readyPaths {
+		pa, ok := data.Paths[name]
+		if !ok {
+			pa = &statsStorePath{}
+			data.Paths[name] = pa
+		}
+		pa.PublishSeconds += now.Sub(rp.since).Seconds()
+	}
+
+	return data
+}
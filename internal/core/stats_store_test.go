@@ -0,0 +1,86 @@
+package core
+
+import (
+	"encoding/json"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStatsStore(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mediamtx-stats")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	statsFile := filepath.Join(dir, "stats.json")
+
+	publish := func() {
+		p, ok := newInstance("statsFile: " + statsFile + "\n" +
+			"paths:\n" +
+			"  all:\n")
+		require.Equal(t, true, ok)
+		defer p.Close()
+
+		source := gortsplib.Client{}
+		err := source.StartRecording("rtsp://localhost:8554/mypath",
+			media.Medias{testMediaH264})
+		require.NoError(t, err)
+		defer source.Close()
+
+		err = source.WritePacketRTP(testMediaH264, &rtp.Packet{
+			Header: rtp.Header{
+				Version:        0x02,
+				PayloadType:    96,
+				SequenceNumber: 57899,
+				Timestamp:      345234345,
+				SSRC:           978651231,
+				Marker:         true,
+			},
+			Payload: []byte{0x05, 0x02, 0x03, 0x04},
+		})
+		require.NoError(t, err)
+
+		// HLS readers are counted as sessions.
+		// the playlist is never ready since segments are not generated,
+		// but the muxer is created and starts reading.
+		hc := &http.Client{Timeout: 500 * time.Millisecond}
+		res, err := hc.Get("http://localhost:8888/mypath/index.m3u8")
+		if err == nil {
+			res.Body.Close()
+		}
+
+		time.Sleep(2500 * time.Millisecond)
+	}
+
+	read := func() statsStoreData {
+		byts, err := os.ReadFile(statsFile)
+		require.NoError(t, err)
+
+		var data statsStoreData
+		err = json.Unmarshal(byts, &data)
+		require.NoError(t, err)
+		return data
+	}
+
+	publish()
+
+	data1 := read()
+	require.Equal(t, uint64(2), data1.TotalSessions)
+	require.NotEqual(t, uint64(0), data1.TotalBytesReceived)
+	require.Equal(t, data1.TotalBytesReceived, data1.Paths["mypath"].BytesReceived)
+	require.Greater(t, data1.Paths["mypath"].PublishSeconds, float64(1))
+
+	publish()
+
+	data2 := read()
+	require.Equal(t, uint64(4), data2.TotalSessions)
+	require.Equal(t, 2*data1.TotalBytesReceived, data2.TotalBytesReceived)
+	require.Greater(t, data2.Paths["mypath"].PublishSeconds, data1.Paths["mypath"].PublishSeconds)
+}
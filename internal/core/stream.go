@@ -1,51 +1,244 @@
 package core
 
 import (
-	"github.com/bluenviron/gortsplib/v3"
+	"bytes"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/pion/rtcp"
 
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 )
 
+// streamTiming keeps timestamps monotonic when the source of a stream
+// is replaced by calling reannounce().
+// Routing and adjusting units doesn't lock any mutex, unless a replacement is in progress.
+type streamTiming struct {
+	// stream whose units are routed to readers.
+	writer atomic.Pointer[stream]
+
+	offset        int64 // time.Duration
+	offsetPending int32
+	lastPTS       int64 // time.Duration
+	lastPTSTime   int64 // unix nanoseconds
+
+	mutex sync.Mutex
+
+	// stream that replaces writer at the splicing point.
+	nextWriter *stream
+
+	// format whose next key frame is the splicing point.
+	keyFrameFormat *streamFormat
+
+	// called when nextWriter replaces writer.
+	onReplaced func()
+}
+
+// splice is called when the source is replaced.
+// Units of the previous writer are routed until the splicing point, that is
+// the first key frame of keyFrameFormat or, when keyFrameFormat is nil, the first unit.
+func (t *streamTiming) splice(nextWriter *stream, keyFrameFormat *streamFormat, onReplaced func()) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	t.nextWriter = nextWriter
+	t.keyFrameFormat = keyFrameFormat
+	t.onReplaced = onReplaced
+}
+
+// activeWriter returns the stream whose units are routed to readers.
+func (t *streamTiming) activeWriter() *stream {
+	return t.writer.Load()
+}
+
+// canWrite returns whether a unit written by s can be routed to readers.
+// Units of a new writer that precede the first key frame are discarded,
+// otherwise readers would decode frames that reference missing ones;
+// then, units of the previous writer are discarded.
+func (t *streamTiming) canWrite(s *stream, sf *streamFormat, u formatprocessor.Unit) bool {
+	if s == t.writer.Load() {
+		return true
+	}
+
+	t.mutex.Lock()
+
+	if s == t.writer.Load() {
+		t.mutex.Unlock()
+		return true
+	}
+
+	if s != t.nextWriter ||
+		(t.keyFrameFormat != nil && (sf != t.keyFrameFormat || !formatprocessor.UnitRandomAccess(u))) {
+		t.mutex.Unlock()
+		return false
+	}
+
+	t.nextWriter = nil
+	t.keyFrameFormat = nil
+	atomic.StoreInt32(&t.offsetPending, 1)
+	t.writer.Store(s)
+	onReplaced := t.onReplaced
+	t.onReplaced = nil
+
+	t.mutex.Unlock()
+
+	if onReplaced != nil {
+		onReplaced()
+	}
+
+	return true
+}
+
+func (t *streamTiming) adjust(pts *time.Duration, now time.Time) {
+	// continue from the last timestamp of the previous source.
+	if atomic.LoadInt32(&t.offsetPending) != 0 {
+		t.mutex.Lock()
+		if atomic.LoadInt32(&t.offsetPending) != 0 {
+			if lastPTSTime := atomic.LoadInt64(&t.lastPTSTime); lastPTSTime != 0 {
+				atomic.StoreInt64(&t.offset, atomic.LoadInt64(&t.lastPTS)+
+					int64(now.Sub(time.Unix(0, lastPTSTime)))-int64(*pts))
+			}
+			atomic.StoreInt32(&t.offsetPending, 0)
+		}
+		t.mutex.Unlock()
+	}
+
+	*pts += time.Duration(atomic.LoadInt64(&t.offset))
+
+	for {
+		lastPTS := atomic.LoadInt64(&t.lastPTS)
+		if int64(*pts) <= lastPTS {
+			break
+		}
+		if atomic.CompareAndSwapInt64(&t.lastPTS, lastPTS, int64(*pts)) {
+			atomic.StoreInt64(&t.lastPTSTime, now.UnixNano())
+			break
+		}
+	}
+}
+
 type stream struct {
-	bytesReceived *uint64
+	bytesReceived      *uint64
+	udpMaxPayloadSize  int
+	generateRTPPackets bool
+	preserveSSRC       bool
+	seiTimestamp       *formatprocessor.SEITimestamp
+	analyzer           *formatprocessor.Analyzer
+	source             source
 
-	rtspStream *gortsplib.ServerStream
+	rtspFanout *streamRTSPFanout
 	smedias    map[*media.Media]*streamMedia
+	timing     *streamTiming
+	normalizer *streamNormalizer
+	captures   *streamCaptures
+	latency    *streamLatency
+	health     *streamHealth
 }
 
 func newStream(
 	udpMaxPayloadSize int,
 	medias media.Medias,
 	generateRTPPackets bool,
+	preserveSSRC bool,
+	seiTimestamp *formatprocessor.SEITimestamp,
+	analyzer *formatprocessor.Analyzer,
 	bytesReceived *uint64,
+	onHealthScore func(int),
+	keepKeyFrames bool,
+	normalizeTimestamps bool,
 	source source,
 ) (*stream, error) {
 	s := &stream{
-		bytesReceived: bytesReceived,
-		rtspStream:    gortsplib.NewServerStream(medias),
+		bytesReceived:      bytesReceived,
+		udpMaxPayloadSize:  udpMaxPayloadSize,
+		generateRTPPackets: generateRTPPackets,
+		preserveSSRC:       preserveSSRC,
+		seiTimestamp:       seiTimestamp,
+		analyzer:           analyzer,
+		source:             source,
+		rtspFanout:         newStreamRTSPFanout(medias),
+		timing:             &streamTiming{},
+		captures: &streamCaptures{
+			captures: make(map[*rtpCapture]struct{}),
+		},
+		latency: newStreamLatency(),
+		health:  newStreamHealth(onHealthScore),
 	}
 
 	s.smedias = make(map[*media.Media]*streamMedia)
 
-	for _, media := range s.rtspStream.Medias() {
+	for _, media := range s.rtspFanout.medias {
 		var err error
-		s.smedias[media], err = newStreamMedia(udpMaxPayloadSize, media, generateRTPPackets, source)
+		s.smedias[media], err = newStreamMedia(udpMaxPayloadSize, media, generateRTPPackets,
+			seiTimestamp, analyzer, keepKeyFrames, source)
 		if err != nil {
 			return nil, err
 		}
+
+		for _, sf := range s.smedias[media].formats {
+			sf.writer = s
+		}
+	}
+
+	s.timing.writer.Store(s)
+
+	if normalizeTimestamps {
+		s.normalizer = newStreamNormalizer()
 	}
 
 	return s, nil
 }
 
 func (s *stream) close() {
-	s.rtspStream.Close()
+	s.rtspFanout.close()
 }
 
 func (s *stream) medias() media.Medias {
-	return s.rtspStream.Medias()
+	return s.rtspFanout.medias
+}
+
+// ssrcs returns the SSRC of the last packet routed to readers, for each media.
+func (s *stream) ssrcs() []*uint32 {
+	medias := s.medias()
+	ret := make([]*uint32, len(medias))
+
+	for i, medi := range medias {
+		sm := s.smedias[medi]
+		for _, forma := range medi.Formats {
+			if ssrc, ok := sm.formats[forma].lastSSRC(); ok {
+				ret[i] = &ssrc
+				break
+			}
+		}
+	}
+
+	return ret
+}
+
+// keyFrames returns the last key frame of each format, if key frames are kept.
+func (s *stream) keyFrames() map[*media.Media]map[formats.Format]streamKeyFrameSnapshot {
+	ret := make(map[*media.Media]map[formats.Format]streamKeyFrameSnapshot)
+
+	for medi, sm := range s.smedias {
+		for forma, sf := range sm.formats {
+			if sf.keyFrame == nil {
+				continue
+			}
+
+			if snap, ok := sf.keyFrame.snapshot(); ok {
+				if ret[medi] == nil {
+					ret[medi] = make(map[formats.Format]streamKeyFrameSnapshot)
+				}
+				ret[medi][forma] = snap
+			}
+		}
+	}
+
+	return ret
 }
 
 func (s *stream) readerAdd(r reader, medi *media.Media, forma formats.Format, cb func(formatprocessor.Unit)) {
@@ -65,5 +258,177 @@ func (s *stream) readerRemove(r reader) {
 func (s *stream) writeUnit(medi *media.Media, forma formats.Format, data formatprocessor.Unit) {
 	sm := s.smedias[medi]
 	sf := sm.formats[forma]
-	sf.writeUnit(s, medi, data)
+	sf.writeUnit(s, sm.media, data)
+	s.captures.writePacketsRTP(data.GetRTPPackets())
+}
+
+// writePacketRTCP routes a RTCP packet received by the source.
+// It's used by captures only.
+func (s *stream) writePacketRTCP(pkt rtcp.Packet) {
+	s.captures.writePacketRTCP(pkt)
+}
+
+func (s *stream) captureAdd(c *rtpCapture) {
+	s.captures.add(c)
+}
+
+func (s *stream) captureRemove(c *rtpCapture) {
+	s.captures.remove(c)
+}
+
+func streamFormatsCompatible(oldForma formats.Format, newForma formats.Format) bool {
+	if reflect.TypeOf(oldForma) != reflect.TypeOf(newForma) ||
+		oldForma.PayloadType() != newForma.PayloadType() ||
+		oldForma.ClockRate() != newForma.ClockRate() {
+		return false
+	}
+
+	switch oldForma := oldForma.(type) {
+	case *formats.H264:
+		// parameters can change, packetization mode can't.
+		return oldForma.PacketizationMode == newForma.(*formats.H264).PacketizationMode
+
+	case *formats.H265:
+		return true
+	}
+
+	return oldForma.RTPMap() == newForma.RTPMap() &&
+		reflect.DeepEqual(oldForma.FMTP(), newForma.FMTP())
+}
+
+func streamMediasCompatible(oldMedias media.Medias, newMedias media.Medias) bool {
+	if len(oldMedias) != len(newMedias) {
+		return false
+	}
+
+	for i, oldMedia := range oldMedias {
+		newMedia := newMedias[i]
+
+		if oldMedia.Type != newMedia.Type ||
+			len(oldMedia.Formats) != len(newMedia.Formats) {
+			return false
+		}
+
+		for j, oldForma := range oldMedia.Formats {
+			if !streamFormatsCompatible(oldForma, newMedia.Formats[j]) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+func streamFormatUpdateParams(oldForma formats.Format, newForma formats.Format) {
+	switch oldForma := oldForma.(type) {
+	case *formats.H264:
+		sps, pps := newForma.(*formats.H264).SafeParams()
+		oldSPS, oldPPS := oldForma.SafeParams()
+
+		if sps != nil && pps != nil &&
+			(!bytes.Equal(sps, oldSPS) || !bytes.Equal(pps, oldPPS)) {
+			oldForma.SafeSetParams(sps, pps)
+		}
+
+	case *formats.H265:
+		vps, sps, pps := newForma.(*formats.H265).SafeParams()
+		oldVPS, oldSPS, oldPPS := oldForma.SafeParams()
+
+		if vps != nil && sps != nil && pps != nil &&
+			(!bytes.Equal(vps, oldVPS) || !bytes.Equal(sps, oldSPS) || !bytes.Equal(pps, oldPPS)) {
+			oldForma.SafeSetParams(vps, sps, pps)
+		}
+	}
+}
+
+// writer returns the stream that is currently used by the source to write data.
+func (s *stream) writer() *stream {
+	return s.timing.activeWriter()
+}
+
+// reannounce allows a new source, with the given medias, to write into the stream,
+// without disconnecting readers. Medias must have the same layout of the existing ones,
+// while codec parameters can change. Data of the new source is spliced at the next key frame,
+// and data of the previous source is routed until then; onReplaced, if not nil, is called
+// by the new source when the splicing point is reached.
+// It returns a stream that must be used by the new source to write data.
+func (s *stream) reannounce(
+	medias media.Medias,
+	generateRTPPackets bool,
+	source source,
+	onReplaced func(),
+) (*stream, bool) {
+	if generateRTPPackets != s.generateRTPPackets ||
+		!streamMediasCompatible(s.medias(), medias) {
+		return nil, false
+	}
+
+	alias := &stream{
+		bytesReceived:      s.bytesReceived,
+		udpMaxPayloadSize:  s.udpMaxPayloadSize,
+		generateRTPPackets: s.generateRTPPackets,
+		preserveSSRC:       s.preserveSSRC,
+		seiTimestamp:       s.seiTimestamp,
+		analyzer:           s.analyzer,
+		source:             source,
+		rtspFanout:         s.rtspFanout,
+		smedias:            make(map[*media.Media]*streamMedia),
+		timing:             s.timing,
+		normalizer:         s.normalizer,
+		captures:           s.captures,
+		latency:            s.latency,
+		health:             s.health,
+	}
+
+	var keyFrameFormat *streamFormat
+
+	for i, oldMedia := range s.medias() {
+		newMedia := medias[i]
+		sm := s.smedias[oldMedia]
+
+		alias.smedias[newMedia] = &streamMedia{
+			media:   sm.media,
+			formats: make(map[formats.Format]*streamFormat),
+		}
+
+		for j, oldForma := range oldMedia.Formats {
+			newForma := newMedia.Formats[j]
+			sf := sm.formats[oldForma]
+
+			err := sf.setNext(oldForma, newForma, source, alias)
+			if err != nil {
+				return nil, false
+			}
+
+			alias.smedias[newMedia].formats[newForma] = sf
+
+			if keyFrameFormat == nil {
+				switch oldForma.(type) {
+				case *formats.H264, *formats.H265, *formats.MPEG2Video:
+					keyFrameFormat = sf
+				}
+			}
+		}
+	}
+
+	s.timing.splice(alias, keyFrameFormat, onReplaced)
+
+	return alias, true
+}
+
+// adopt is like reannounce, but the returned stream is used by another path,
+// whose received bytes are counted separately.
+func (s *stream) adopt(
+	medias media.Medias,
+	generateRTPPackets bool,
+	source source,
+	bytesReceived *uint64,
+) (*stream, bool) {
+	alias, ok := s.reannounce(medias, generateRTPPackets, source, nil)
+	if !ok {
+		return nil, false
+	}
+
+	alias.bytesReceived = bytesReceived
+	return alias, true
 }
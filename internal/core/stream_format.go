@@ -3,18 +3,105 @@ package core
 import (
 	"sync"
 	"sync/atomic"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/pion/rtp"
 
 	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
+// streamRTPTiming keeps sequence numbers, timestamps and SSRC of RTP packets
+// continuous when the source of a stream is replaced.
+type streamRTPTiming struct {
+	clockRate   int
+	initialized bool
+	pending     bool
+	ssrc        uint32
+	seqOffset   uint16
+	tsOffset    uint32
+	lastSeq     uint16
+	lastTS      uint32
+	lastTime    time.Time
+}
+
+func (t *streamRTPTiming) rewrite(pkt *rtp.Packet) {
+	now := time.Now()
+
+	switch {
+	case !t.initialized:
+		t.initialized = true
+		t.ssrc = pkt.SSRC
+
+	case t.pending:
+		t.pending = false
+		t.seqOffset = t.lastSeq + 1 - pkt.SequenceNumber
+		t.tsOffset = t.lastTS + uint32(now.Sub(t.lastTime).Seconds()*float64(t.clockRate)) - pkt.Timestamp
+	}
+
+	pkt.SSRC = t.ssrc
+	pkt.SequenceNumber += t.seqOffset
+	pkt.Timestamp += t.tsOffset
+
+	t.lastSeq = pkt.SequenceNumber
+	t.lastTS = pkt.Timestamp
+	t.lastTime = now
+}
+
+// streamLossDetector counts RTP packets that are missing from a sequence.
+type streamLossDetector struct {
+	initialized bool
+	expectedSeq uint16
+}
+
+func (d *streamLossDetector) process(pkt *rtp.Packet) uint64 {
+	if !d.initialized {
+		d.initialized = true
+		d.expectedSeq = pkt.SequenceNumber + 1
+		return 0
+	}
+
+	diff := pkt.SequenceNumber - d.expectedSeq
+
+	// reordered or duplicated packets are not counted.
+	if diff >= 0x8000 {
+		return 0
+	}
+
+	d.expectedSeq = pkt.SequenceNumber + 1
+	return uint64(diff)
+}
+
+// streamFormatNext contains the state of a source that is replacing the current one.
+type streamFormatNext struct {
+	writer   *stream
+	source   source
+	proc     formatprocessor.Processor
+	forma    formats.Format
+	newForma formats.Format
+}
+
 type streamFormat struct {
-	source         source
+	udpMaxPayloadSize  int
+	generateRTPPackets bool
+	seiTimestamp       *formatprocessor.SEITimestamp
+	analyzer           *formatprocessor.Analyzer
+	source             source
+
+	writer         *stream
 	proc           formatprocessor.Processor
+	next           *streamFormatNext
+	rtpTiming      streamRTPTiming
+	lossDetector   streamLossDetector
+	hasKeyFrames   bool
+	lastKeyFrame   time.Time
+	keyFrame       *streamKeyFrame
+	ssrc           uint32
+	ssrcKnown      int32
 	mutex          sync.RWMutex
+	writeMutex     sync.Mutex
 	nonRTSPReaders map[reader]func(formatprocessor.Unit)
 }
 
@@ -22,19 +109,38 @@ func newStreamFormat(
 	udpMaxPayloadSize int,
 	forma formats.Format,
 	generateRTPPackets bool,
+	seiTimestamp *formatprocessor.SEITimestamp,
+	analyzer *formatprocessor.Analyzer,
+	keepKeyFrames bool,
 	source source,
 ) (*streamFormat, error) {
-	proc, err := formatprocessor.New(udpMaxPayloadSize, forma, generateRTPPackets, source)
+	proc, err := formatprocessor.New(udpMaxPayloadSize, forma, generateRTPPackets, seiTimestamp, analyzer, source)
 	if err != nil {
 		return nil, err
 	}
 
 	sf := &streamFormat{
-		source:         source,
-		proc:           proc,
-		nonRTSPReaders: make(map[reader]func(formatprocessor.Unit)),
+		udpMaxPayloadSize:  udpMaxPayloadSize,
+		generateRTPPackets: generateRTPPackets,
+		seiTimestamp:       seiTimestamp,
+		analyzer:           analyzer,
+		source:             source,
+		proc:               proc,
+		rtpTiming:          streamRTPTiming{clockRate: forma.ClockRate()},
+		lastKeyFrame:       time.Now(),
+		nonRTSPReaders:     make(map[reader]func(formatprocessor.Unit)),
 	}
 
+	switch forma.(type) {
+	case *formats.H264, *formats.H265:
+		sf.hasKeyFrames = true
+		if keepKeyFrames {
+			sf.keyFrame = &streamKeyFrame{}
+		}
+
+	case *formats.MPEG2Video:
+		sf.hasKeyFrames = true
+	}
 	return sf, nil
 }
 
@@ -50,22 +156,137 @@ func (sf *streamFormat) readerRemove(r reader) {
 	delete(sf.nonRTSPReaders, r)
 }
 
+// setNext allocates a processor for data coming from a new source, that writes through writer.
+// The current processor is kept until the new source reaches the splicing point.
+func (sf *streamFormat) setNext(forma formats.Format, newForma formats.Format, source source, writer *stream) error {
+	proc, err := formatprocessor.New(sf.udpMaxPayloadSize, forma, sf.generateRTPPackets,
+		sf.seiTimestamp, sf.analyzer, source)
+	if err != nil {
+		return err
+	}
+
+	sf.mutex.Lock()
+	defer sf.mutex.Unlock()
+	sf.next = &streamFormatNext{
+		writer:   writer,
+		source:   source,
+		proc:     proc,
+		forma:    forma,
+		newForma: newForma,
+	}
+	return nil
+}
+
+// promote replaces the current source with the next one.
+// It must be called with writeMutex locked.
+func (sf *streamFormat) promote() {
+	streamFormatUpdateParams(sf.next.forma, sf.next.newForma)
+
+	sf.writer = sf.next.writer
+	sf.source = sf.next.source
+	sf.proc = sf.next.proc
+	sf.next = nil
+	sf.rtpTiming.pending = sf.rtpTiming.initialized
+	sf.lossDetector = streamLossDetector{}
+	sf.lastKeyFrame = time.Now()
+}
+
+func (sf *streamFormat) lastSSRC() (uint32, bool) {
+	if atomic.LoadInt32(&sf.ssrcKnown) == 0 {
+		return 0, false
+	}
+	return atomic.LoadUint32(&sf.ssrc), true
+}
+
 func (sf *streamFormat) writeUnit(s *stream, medi *media.Media, data formatprocessor.Unit) {
+	// while a source is being replaced, two sources can write at once.
+	sf.writeMutex.Lock()
+	defer sf.writeMutex.Unlock()
+
 	sf.mutex.RLock()
 	defer sf.mutex.RUnlock()
 
+	var proc formatprocessor.Processor
+	var source source
+
+	switch {
+	case sf.next != nil && s == sf.next.writer:
+		proc = sf.next.proc
+		source = sf.next.source
+
+	case s == sf.writer:
+		proc = sf.proc
+		source = sf.source
+
+	default:
+		// the source has been replaced
+		return
+	}
+
 	hasNonRTSPReaders := len(sf.nonRTSPReaders) > 0
 
-	err := sf.proc.Process(data, hasNonRTSPReaders)
+	now := time.Now()
+	defer s.health.update(now)
+
+	err := proc.Process(data, hasNonRTSPReaders)
+	s.health.addUnit(err != nil)
 	if err != nil {
-		sf.source.Log(logger.Warn, err.Error())
+		source.Log(logger.Warn, err.Error())
 		return
 	}
 
+	if !s.timing.canWrite(s, sf, data) {
+		return
+	}
+
+	if s != sf.writer {
+		sf.promote()
+	}
+
+	// packets received from the source
+	if pkts := data.GetRTPPackets(); pkts != nil {
+		lost := uint64(0)
+		for _, pkt := range pkts {
+			lost += sf.lossDetector.process(pkt)
+		}
+		s.health.addReceived(uint64(len(pkts)), lost)
+	}
+
+	if sf.keyFrame != nil {
+		sf.keyFrame.process(data, now)
+	}
+
+	if sf.hasKeyFrames {
+		if formatprocessor.UnitRandomAccess(data) {
+			sf.lastKeyFrame = now
+		} else if now.Sub(sf.lastKeyFrame) >= streamHealthMaxKeyFrameInterval {
+			sf.lastKeyFrame = now
+			s.health.addMissingKeyFrames()
+		}
+	}
+
 	// forward RTP packets to RTSP readers
 	for _, pkt := range data.GetRTPPackets() {
 		atomic.AddUint64(s.bytesReceived, uint64(pkt.MarshalSize()))
-		s.rtspStream.WritePacketRTPWithNTP(medi, pkt, data.GetNTP())
+		if !s.preserveSSRC {
+			sf.rtpTiming.rewrite(pkt)
+		}
+		atomic.StoreUint32(&sf.ssrc, pkt.SSRC)
+		atomic.StoreInt32(&sf.ssrcKnown, 1)
+		s.rtspFanout.writePacketRTP(medi, pkt, data.GetNTP())
+	}
+
+	if len(data.GetRTPPackets()) != 0 {
+		s.latency.observe(streamLatencyRTSP, data.GetNTP())
+	}
+
+	if hasNonRTSPReaders {
+		if pts := formatprocessor.UnitPTS(data); pts != nil {
+			if s.normalizer != nil {
+				s.normalizer.normalize(sf, pts, now)
+			}
+			s.timing.adjust(pts, now)
+		}
 	}
 
 	// forward decoded frames to non-RTSP readers
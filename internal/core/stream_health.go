@@ -0,0 +1,124 @@
+package core
+
+import (
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	// interval in which the health score is computed.
+	streamHealthPeriod = 5 * time.Second
+
+	// video tracks without key frames for longer than this can't be decoded by new readers.
+	streamHealthMaxKeyFrameInterval = 10 * time.Second
+
+	// points that are removed for each 1% of lost packets or of units that can't be decoded.
+	streamHealthLossPenalty  = 10
+	streamHealthErrorPenalty = 10
+
+	// points that are removed when a video track has no key frames.
+	streamHealthMissingKeyFramesPenalty = 50
+)
+
+// streamHealthScore computes a health score between 0 and 100.
+func streamHealthScore(received uint64, lost uint64, units uint64, errors uint64, missingKeyFrames uint64) int {
+	score := 100.0
+
+	if lost != 0 {
+		score -= float64(lost) / float64(received+lost) * 100 * streamHealthLossPenalty
+	}
+
+	if errors != 0 {
+		score -= float64(errors) / float64(units+errors) * 100 * streamHealthErrorPenalty
+	}
+
+	if missingKeyFrames != 0 {
+		score -= streamHealthMissingKeyFramesPenalty
+	}
+
+	return int(math.Max(0, math.Round(score)))
+}
+
+// streamHealth measures the health of a stream from lost RTP packets,
+// units that can't be decoded and video tracks without key frames.
+// It is called by the goroutines of sources, therefore counters are atomic.
+type streamHealth struct {
+	onScore func(int)
+
+	received         uint64
+	lost             uint64
+	units            uint64
+	errors           uint64
+	missingKeyFrames uint64
+	score            int64
+	periodStart      int64
+
+	mutex sync.Mutex
+}
+
+func newStreamHealth(onScore func(int)) *streamHealth {
+	return &streamHealth{
+		onScore:     onScore,
+		score:       -1,
+		periodStart: time.Now().UnixNano(),
+	}
+}
+
+func (h *streamHealth) addReceived(received uint64, lost uint64) {
+	atomic.AddUint64(&h.received, received)
+	atomic.AddUint64(&h.lost, lost)
+}
+
+func (h *streamHealth) addUnit(err bool) {
+	if err {
+		atomic.AddUint64(&h.errors, 1)
+	} else {
+		atomic.AddUint64(&h.units, 1)
+	}
+}
+
+func (h *streamHealth) addMissingKeyFrames() {
+	atomic.AddUint64(&h.missingKeyFrames, 1)
+}
+
+// update computes the score when a period has elapsed.
+func (h *streamHealth) update(now time.Time) {
+	if now.UnixNano()-atomic.LoadInt64(&h.periodStart) < int64(streamHealthPeriod) {
+		return
+	}
+
+	h.mutex.Lock()
+
+	// another goroutine may have computed the score in the meanwhile.
+	if now.UnixNano()-atomic.LoadInt64(&h.periodStart) < int64(streamHealthPeriod) {
+		h.mutex.Unlock()
+		return
+	}
+
+	atomic.StoreInt64(&h.periodStart, now.UnixNano())
+
+	score := streamHealthScore(
+		atomic.SwapUint64(&h.received, 0),
+		atomic.SwapUint64(&h.lost, 0),
+		atomic.SwapUint64(&h.units, 0),
+		atomic.SwapUint64(&h.errors, 0),
+		atomic.SwapUint64(&h.missingKeyFrames, 0))
+	atomic.StoreInt64(&h.score, int64(score))
+
+	h.mutex.Unlock()
+
+	if h.onScore != nil {
+		h.onScore(score)
+	}
+}
+
+// current returns the score of the last period, or nil if no period has elapsed yet.
+func (h *streamHealth) current() *int {
+	v := int(atomic.LoadInt64(&h.score))
+	if v < 0 {
+		return nil
+	}
+	return &v
+}
@@ -0,0 +1,91 @@
+package core
+
+import (
+	"sync"
+	"time"
+
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+)
+
+// streamKeyFrameSnapshot contains the last key frame of a format,
+// together with the position of the last RTP packet that was routed.
+type streamKeyFrameSnapshot struct {
+	pkts     []*rtp.Packet
+	lastSeq  uint16
+	lastTS   uint32
+	lastTime time.Time
+}
+
+// streamKeyFrame stores the RTP packets of the last key frame of a format,
+// in order to repeat it after the source is gone.
+type streamKeyFrame struct {
+	mutex     sync.Mutex
+	pending   []*rtp.Packet
+	receiving bool
+	pkts      []*rtp.Packet
+	lastSeq   uint16
+	lastTS    uint32
+	lastTime  time.Time
+}
+
+func (k *streamKeyFrame) process(u formatprocessor.Unit, now time.Time) {
+	pkts := u.GetRTPPackets()
+	if len(pkts) == 0 {
+		return
+	}
+
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	last := pkts[len(pkts)-1]
+	k.lastSeq = last.SequenceNumber
+	k.lastTS = last.Timestamp
+	k.lastTime = now
+
+	// parameters and slices of a key frame can be in distinct units.
+	if formatprocessor.UnitRandomAccess(u) &&
+		(!k.receiving || len(k.pending) == 0 || pkts[0].Timestamp != k.pending[0].Timestamp) {
+		k.receiving = true
+		k.pending = nil
+	}
+
+	if !k.receiving {
+		return
+	}
+
+	for _, pkt := range pkts {
+		// all packets of a frame share the same timestamp.
+		if len(k.pending) != 0 && pkt.Timestamp != k.pending[0].Timestamp {
+			k.receiving = false
+			k.pending = nil
+			return
+		}
+
+		k.pending = append(k.pending, pkt.Clone())
+
+		if pkt.Marker {
+			k.pkts = k.pending
+			k.receiving = false
+			k.pending = nil
+			return
+		}
+	}
+}
+
+func (k *streamKeyFrame) snapshot() (streamKeyFrameSnapshot, bool) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	if k.pkts == nil {
+		return streamKeyFrameSnapshot{}, false
+	}
+
+	return streamKeyFrameSnapshot{
+		pkts:     k.pkts,
+		lastSeq:  k.lastSeq,
+		lastTS:   k.lastTS,
+		lastTime: k.lastTime,
+	}, true
+}
@@ -0,0 +1,101 @@
+package core
+
+import (
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// number of recent samples that are used to compute percentiles.
+const streamLatencyWindowSize = 512
+
+// protocols whose latency is measured.
+const (
+	streamLatencyRTSP   = "rtsp"
+	streamLatencyRTMP   = "rtmp"
+	streamLatencyHLS    = "hls"
+	streamLatencyWebRTC = "webrtc"
+)
+
+type streamLatencyPercentiles struct {
+	P50 time.Duration
+	P99 time.Duration
+}
+
+type streamLatencyWindow struct {
+	samples [streamLatencyWindowSize]time.Duration
+	count   int
+	pos     int
+}
+
+func (w *streamLatencyWindow) add(v time.Duration) {
+	w.samples[w.pos] = v
+	w.pos = (w.pos + 1) % streamLatencyWindowSize
+	if w.count < streamLatencyWindowSize {
+		w.count++
+	}
+}
+
+func (w *streamLatencyWindow) percentiles() streamLatencyPercentiles {
+	sorted := make([]time.Duration, w.count)
+	copy(sorted, w.samples[:w.count])
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i] < sorted[j]
+	})
+
+	// nearest-rank method
+	at := func(q float64) time.Duration {
+		return sorted[int(math.Ceil(q*float64(len(sorted))))-1]
+	}
+
+	return streamLatencyPercentiles{
+		P50: at(0.50),
+		P99: at(0.99),
+	}
+}
+
+// streamLatency measures the delay between the moment in which units enter the server
+// and the moment in which they are written to readers, for each protocol.
+// It is called by the goroutines of readers, therefore it's protected by a mutex.
+type streamLatency struct {
+	mutex   sync.Mutex
+	windows map[string]*streamLatencyWindow
+}
+
+func newStreamLatency() *streamLatency {
+	return &streamLatency{
+		windows: make(map[string]*streamLatencyWindow),
+	}
+}
+
+// observe is called when a unit, received at time ntp, has been written to a reader.
+func (l *streamLatency) observe(protocol string, ntp time.Time) {
+	if ntp.IsZero() {
+		return
+	}
+
+	v := time.Since(ntp)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	w, ok := l.windows[protocol]
+	if !ok {
+		w = &streamLatencyWindow{}
+		l.windows[protocol] = w
+	}
+
+	w.add(v)
+}
+
+func (l *streamLatency) percentiles() map[string]streamLatencyPercentiles {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	ret := make(map[string]streamLatencyPercentiles, len(l.windows))
+	for protocol, w := range l.windows {
+		ret[protocol] = w.percentiles()
+	}
+	return ret
+}
@@ -3,24 +3,32 @@ package core
 import (
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
 )
 
 type streamMedia struct {
+	media   *media.Media
 	formats map[formats.Format]*streamFormat
 }
 
 func newStreamMedia(udpMaxPayloadSize int,
 	medi *media.Media,
 	generateRTPPackets bool,
+	seiTimestamp *formatprocessor.SEITimestamp,
+	analyzer *formatprocessor.Analyzer,
+	keepKeyFrames bool,
 	source source,
 ) (*streamMedia, error) {
 	sm := &streamMedia{
+		media:   medi,
 		formats: make(map[formats.Format]*streamFormat),
 	}
 
 	for _, forma := range medi.Formats {
 		var err error
-		sm.formats[forma], err = newStreamFormat(udpMaxPayloadSize, forma, generateRTPPackets, source)
+		sm.formats[forma], err = newStreamFormat(udpMaxPayloadSize, forma, generateRTPPackets,
+			seiTimestamp, analyzer, keepKeyFrames, source)
 		if err != nil {
 			return nil, err
 		}
@@ -0,0 +1,63 @@
+package core
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	// difference between the timestamp of a track and the common clock
+	// above which the clock of the source is considered reset.
+	streamNormalizerMaxDrift = 2 * time.Second
+
+	// at every unit, the difference between the timestamp of a track
+	// and the common clock is reduced by this fraction.
+	streamNormalizerSlewDivisor = 1024
+)
+
+type streamNormalizerTrack struct {
+	offset time.Duration
+}
+
+// streamNormalizer re-timestamps all tracks of a stream onto a common clock,
+// that is anchored at the creation of the stream and advances with the arrival time of units.
+// Slow drifts between the clocks of the tracks are compensated gradually,
+// while sudden jumps (source clock resets, bad wraparounds) are compensated at once.
+type streamNormalizer struct {
+	start time.Time
+
+	mutex  sync.Mutex
+	tracks map[*streamFormat]*streamNormalizerTrack
+}
+
+func newStreamNormalizer() *streamNormalizer {
+	return &streamNormalizer{
+		start:  time.Now(),
+		tracks: make(map[*streamFormat]*streamNormalizerTrack),
+	}
+}
+
+func (n *streamNormalizer) normalize(sf *streamFormat, pts *time.Duration, now time.Time) {
+	n.mutex.Lock()
+	defer n.mutex.Unlock()
+
+	elapsed := now.Sub(n.start)
+
+	track, ok := n.tracks[sf]
+	if !ok {
+		track = &streamNormalizerTrack{
+			offset: elapsed - *pts,
+		}
+		n.tracks[sf] = track
+	}
+
+	diff := elapsed - (*pts + track.offset)
+
+	if diff > streamNormalizerMaxDrift || diff < -streamNormalizerMaxDrift {
+		track.offset += diff
+	} else {
+		track.offset += diff / streamNormalizerSlewDivisor
+	}
+
+	*pts += track.offset
+}
@@ -0,0 +1,342 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"runtime"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/ringbuffer"
+	"github.com/pion/rtp"
+)
+
+// maximum number of shards of a stream.
+var streamRTSPFanoutMaxShards = runtime.NumCPU()
+
+const (
+	// number of readers of a shard after which a new shard is allocated.
+	streamRTSPFanoutShardReaders = 32
+
+	// size of the queue of shards that are written by their own goroutine.
+	streamRTSPFanoutQueueSize = 1024
+)
+
+var streamRTSPFanoutPacketPool = sync.Pool{
+	New: func() interface{} {
+		return &streamRTSPFanoutPacket{}
+	},
+}
+
+// streamRTSPFanoutPacket is a packet that is shared by the queues of shards.
+// It is reference counted, and it is returned to the pool when all shards have written it.
+// Packets that are overwritten in a full queue, or left in the queue of a closed shard,
+// are never released and are collected by the GC.
+type streamRTSPFanoutPacket struct {
+	medi *media.Media
+	pkt  *rtp.Packet
+	ntp  time.Time
+	refs int32
+}
+
+func newStreamRTSPFanoutPacket(medi *media.Media, pkt *rtp.Packet, ntp time.Time, refs int32) *streamRTSPFanoutPacket {
+	p := streamRTSPFanoutPacketPool.Get().(*streamRTSPFanoutPacket)
+	p.medi = medi
+	p.pkt = pkt
+	p.ntp = ntp
+	p.refs = refs
+	return p
+}
+
+// release drops a reference to the packet. The packet can't be used anymore.
+func (p *streamRTSPFanoutPacket) release() {
+	if atomic.AddInt32(&p.refs, -1) == 0 {
+		p.medi = nil
+		p.pkt = nil
+		streamRTSPFanoutPacketPool.Put(p)
+	}
+}
+
+// streamRTSPFanoutShard is a group of RTSP readers that share a gortsplib.ServerStream.
+type streamRTSPFanoutShard struct {
+	stream *gortsplib.ServerStream
+
+	// queue is nil in the first shard, that is written by the goroutine of the source.
+	queue *ringbuffer.RingBuffer
+	done  chan struct{}
+
+	// whether the shard contains readers of the backchannel.
+	backchannel bool
+
+	// readers, and UDP readers of every IP.
+	// They are protected by the mutex of the fan-out.
+	readers int
+	udpIPs  map[string]int
+}
+
+func (sh *streamRTSPFanoutShard) run() {
+	defer close(sh.done)
+
+	for {
+		item, ok := sh.queue.Pull()
+		if !ok {
+			return
+		}
+
+		p := item.(*streamRTSPFanoutPacket)
+		sh.stream.WritePacketRTPWithNTP(p.medi, p.pkt, p.ntp)
+		p.release()
+	}
+}
+
+// streamRTSPBackchannel is the ONVIF audio backchannel of a stream, that allows
+// readers to send audio to the source.
+type streamRTSPBackchannel struct {
+	media *media.Media
+	write func(*rtp.Packet)
+	shard *streamRTSPFanoutShard
+
+	// the only reader that is allowed to send audio.
+	owner *streamRTSPReader
+}
+
+// streamRTSPReader is the slot of a RTSP reader inside a streamRTSPFanout.
+type streamRTSPReader struct {
+	fanout *streamRTSPFanout
+	shard  *streamRTSPFanoutShard
+	udpIP  string
+}
+
+// serverStream returns the stream that must be used by the reader.
+func (r *streamRTSPReader) serverStream() *gortsplib.ServerStream {
+	return r.shard.stream
+}
+
+// streamRTSPFanout distributes RTP packets to RTSP readers.
+// Readers are split into shards, each with its own gortsplib.ServerStream
+// and therefore its own list of readers. The first shard is written by the goroutine
+// of the source, while the other ones, that are allocated when the number of readers grows,
+// are written by dedicated goroutines through queues, in order not to serialize
+// the distribution of packets to hundreds of readers on a single goroutine.
+// Every reader then has its own write queue, provided by gortsplib.
+type streamRTSPFanout struct {
+	medias    media.Medias
+	maxShards int
+
+	mutex       sync.RWMutex
+	closed      bool
+	shards      []*streamRTSPFanoutShard
+	backchannel *streamRTSPBackchannel
+}
+
+func newStreamRTSPFanout(medias media.Medias) *streamRTSPFanout {
+	return &streamRTSPFanout{
+		medias:    medias,
+		maxShards: streamRTSPFanoutMaxShards,
+		shards: []*streamRTSPFanoutShard{{
+			stream: gortsplib.NewServerStream(medias),
+			udpIPs: make(map[string]int),
+		}},
+	}
+}
+
+func (f *streamRTSPFanout) close() {
+	f.mutex.Lock()
+	f.closed = true
+	f.mutex.Unlock()
+
+	for _, sh := range f.shards {
+		sh.stream.Close()
+
+		if sh.queue != nil {
+			sh.queue.Close()
+			<-sh.done
+		}
+	}
+}
+
+// serverStream returns the stream that is used to describe the medias to readers.
+func (f *streamRTSPFanout) serverStream() *gortsplib.ServerStream {
+	return f.shards[0].stream
+}
+
+func (f *streamRTSPFanout) newShard(medias media.Medias) *streamRTSPFanoutShard {
+	sh := &streamRTSPFanoutShard{
+		stream: gortsplib.NewServerStream(medias),
+		udpIPs: make(map[string]int),
+		done:   make(chan struct{}),
+	}
+	sh.queue, _ = ringbuffer.New(streamRTSPFanoutQueueSize)
+
+	go sh.run()
+
+	return sh
+}
+
+// setBackchannel sets the backchannel of the stream.
+// write is called with the packets sent by the reader that owns the backchannel.
+func (f *streamRTSPFanout) setBackchannel(medi *media.Media, write func(*rtp.Packet)) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.backchannel = &streamRTSPBackchannel{
+		media: medi,
+		write: write,
+	}
+}
+
+// backchannelMedias returns the medias of the stream followed by the backchannel,
+// or nil if the stream has no backchannel.
+func (f *streamRTSPFanout) backchannelMedias() media.Medias {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if f.backchannel == nil {
+		return nil
+	}
+
+	return append(f.medias[:len(f.medias):len(f.medias)], f.backchannel.media)
+}
+
+// readerAdd assigns a shard to a RTSP reader.
+// Readers of the backchannel are put into a dedicated shard, whose stream contains the backchannel.
+func (f *streamRTSPFanout) readerAdd(transport gortsplib.Transport, ip net.IP, backchannel bool) (*streamRTSPReader, error) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	r := &streamRTSPReader{fanout: f}
+
+	switch {
+	case backchannel:
+		if f.backchannel == nil {
+			return nil, fmt.Errorf("the stream has no backchannel")
+		}
+
+		if f.backchannel.shard == nil {
+			if f.closed {
+				return nil, fmt.Errorf("terminated")
+			}
+
+			f.backchannel.shard = f.newShard(append(f.medias[:len(f.medias):len(f.medias)], f.backchannel.media))
+			f.backchannel.shard.backchannel = true
+			f.shards = append(f.shards, f.backchannel.shard)
+		}
+
+		r.shard = f.backchannel.shard
+
+	case transport == gortsplib.TransportUDPMulticast:
+		// multicast listeners are allocated by every gortsplib.ServerStream,
+		// therefore multicast readers are all put into the first shard.
+		r.shard = f.shards[0]
+
+	case transport == gortsplib.TransportUDP:
+		r.udpIP = ip.String()
+
+		// gortsplib detects UDP readers of the same IP that use the same ports
+		// only if they are in the same shard.
+		for _, sh := range f.shards {
+			if !sh.backchannel && sh.udpIPs[r.udpIP] != 0 {
+				r.shard = sh
+				break
+			}
+		}
+	}
+
+	if r.shard == nil {
+		r.shard = f.shards[0]
+		for _, sh := range f.shards[1:] {
+			if !sh.backchannel && sh.readers < r.shard.readers {
+				r.shard = sh
+			}
+		}
+
+		if r.shard.readers >= streamRTSPFanoutShardReaders && len(f.shards) < f.maxShards && !f.closed {
+			r.shard = f.newShard(f.medias)
+			f.shards = append(f.shards, r.shard)
+		}
+	}
+
+	r.shard.readers++
+	if r.udpIP != "" {
+		r.shard.udpIPs[r.udpIP]++
+	}
+
+	return r, nil
+}
+
+// readerRemove releases the shard of a RTSP reader.
+// Shards are kept, in order to keep their stream statistics updated.
+func (f *streamRTSPFanout) readerRemove(r *streamRTSPReader) {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	r.shard.readers--
+
+	if f.backchannel != nil && f.backchannel.owner == r {
+		f.backchannel.owner = nil
+	}
+
+	if r.udpIP != "" {
+		r.shard.udpIPs[r.udpIP]--
+		if r.shard.udpIPs[r.udpIP] == 0 {
+			delete(r.shard.udpIPs, r.udpIP)
+		}
+	}
+}
+
+// backchannelAcquire allows a reader of the backchannel to send audio.
+// Only one reader at a time can send audio.
+func (f *streamRTSPFanout) backchannelAcquire(r *streamRTSPReader) bool {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	if f.backchannel == nil || (f.backchannel.owner != nil && f.backchannel.owner != r) {
+		return false
+	}
+
+	f.backchannel.owner = r
+	return true
+}
+
+// writeBackchannel writes a RTP packet, sent by a reader, to the backchannel.
+func (f *streamRTSPFanout) writeBackchannel(r *streamRTSPReader, pkt *rtp.Packet) {
+	f.mutex.RLock()
+	bc := f.backchannel
+	owned := bc != nil && bc.owner == r
+	f.mutex.RUnlock()
+
+	if !owned {
+		return
+	}
+
+	for _, forma := range bc.media.Formats {
+		if forma.PayloadType() == pkt.PayloadType {
+			bc.write(pkt)
+			return
+		}
+	}
+}
+
+// writePacketRTP writes a RTP packet to all the RTSP readers.
+// The packet must not be modified after this call.
+func (f *streamRTSPFanout) writePacketRTP(medi *media.Media, pkt *rtp.Packet, ntp time.Time) {
+	f.mutex.RLock()
+	defer f.mutex.RUnlock()
+
+	if f.closed {
+		return
+	}
+
+	if len(f.shards) > 1 {
+		p := newStreamRTSPFanoutPacket(medi, pkt, ntp, int32(len(f.shards)-1))
+
+		for _, sh := range f.shards[1:] {
+			sh.queue.Push(p)
+		}
+	}
+
+	f.shards[0].stream.WritePacketRTPWithNTP(medi, pkt, ntp)
+}
@@ -0,0 +1,799 @@
+package core
+
+import (
+	"fmt"
+	"net"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+type testStreamEntity struct{}
+
+func (testStreamEntity) Log(logger.Level, string, ...interface{}) {}
+
+func (testStreamEntity) apiSourceDescribe() interface{} { return nil }
+
+func (testStreamEntity) close() {}
+
+func (testStreamEntity) apiReaderDescribe() interface{} { return nil }
+
+type testStreamReader struct {
+	testStreamEntity
+	id int
+}
+
+func TestStreamReannounce(t *testing.T) {
+	newMedias := func(sps []byte) media.Medias {
+		return media.Medias{{
+			Type: media.TypeVideo,
+			Formats: []formats.Format{&formats.H264{
+				PayloadTyp:        96,
+				SPS:               sps,
+				PPS:               []byte{0x08, 0x06, 0x07, 0x08},
+				PacketizationMode: 1,
+			}},
+		}}
+	}
+
+	medias := newMedias(testFormatH264.SPS)
+
+	s, err := newStream(1472, medias, true, false, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	forma := medias[0].Formats[0].(*formats.H264)
+
+	var pts []time.Duration
+	s.readerAdd(testStreamEntity{}, medias[0], forma, func(unit formatprocessor.Unit) {
+		pts = append(pts, unit.(*formatprocessor.UnitH264).PTS)
+	})
+
+	s.writeUnit(medias[0], forma, &formatprocessor.UnitH264{
+		PTS: 2 * time.Second,
+		AU:  [][]byte{{0x05, 0x02, 0x03, 0x04}},
+	})
+
+	_, ok := s.reannounce(media.Medias{{
+		Type:    media.TypeAudio,
+		Formats: []formats.Format{&formats.G711{}},
+	}}, true, testStreamEntity{}, nil)
+	require.Equal(t, false, ok)
+
+	newSPS := []byte{0x67, 0x42, 0xc0, 0x1f, 0xd9, 0x00, 0x50, 0x05}
+	newMedias2 := newMedias(newSPS)
+
+	alias, ok := s.reannounce(newMedias2, true, testStreamEntity{}, nil)
+	require.Equal(t, true, ok)
+
+	// parameters are updated when the new source starts writing
+	sps, _ := forma.SafeParams()
+	require.Equal(t, testFormatH264.SPS, sps)
+
+	alias.writeUnit(newMedias2[0], newMedias2[0].Formats[0], &formatprocessor.UnitH264{
+		PTS: 0,
+		AU:  [][]byte{{0x05, 0x02, 0x03, 0x04}},
+	})
+
+	sps, _ = forma.SafeParams()
+	require.Equal(t, newSPS, sps)
+
+	require.Equal(t, 2, len(pts))
+	require.Equal(t, 2*time.Second, pts[0])
+	require.GreaterOrEqual(t, pts[1], 2*time.Second)
+}
+
+func TestStreamReannounceSplice(t *testing.T) {
+	medias := media.Medias{{
+		Type: media.TypeVideo,
+		Formats: []formats.Format{&formats.H264{
+			PayloadTyp:        96,
+			SPS:               testFormatH264.SPS,
+			PPS:               testFormatH264.PPS,
+			PacketizationMode: 1,
+		}},
+	}}
+
+	s, err := newStream(1472, medias, false, false, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	forma := medias[0].Formats[0]
+
+	type written struct {
+		seq  uint16
+		ts   uint32
+		ssrc uint32
+	}
+	var pkts []written
+
+	s.readerAdd(testStreamEntity{}, medias[0], forma, func(unit formatprocessor.Unit) {
+		for _, pkt := range unit.GetRTPPackets() {
+			pkts = append(pkts, written{pkt.SequenceNumber, pkt.Timestamp, pkt.SSRC})
+		}
+	})
+
+	writePacket := func(s *stream, medi *media.Media, forma formats.Format,
+		seq uint16, ts uint32, ssrc uint32, payload []byte,
+	) {
+		s.writeUnit(medi, forma, &formatprocessor.UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: seq,
+					Timestamp:      ts,
+					SSRC:           ssrc,
+				},
+				Payload: payload,
+			}},
+			NTP: time.Now(),
+		})
+	}
+
+	writePacket(s, medias[0], forma, 100, 1000, 1234, []byte{0x05, 0x01}) // IDR
+	writePacket(s, medias[0], forma, 101, 4000, 1234, []byte{0x01, 0x01}) // non-IDR
+
+	newMedias := media.Medias{{
+		Type: media.TypeVideo,
+		Formats: []formats.Format{&formats.H264{
+			PayloadTyp:        96,
+			SPS:               testFormatH264.SPS,
+			PPS:               testFormatH264.PPS,
+			PacketizationMode: 1,
+		}},
+	}}
+
+	alias, ok := s.reannounce(newMedias, false, testStreamEntity{}, nil)
+	require.Equal(t, true, ok)
+
+	newForma := newMedias[0].Formats[0]
+
+	// units that precede the first key frame are discarded
+	writePacket(alias, newMedias[0], newForma, 5000, 90000, 5678, []byte{0x01, 0x01})       // non-IDR
+	writePacket(alias, newMedias[0], newForma, 5001, 93000, 5678, []byte{0x1c, 0x45, 0x01}) // FU-A, middle of IDR
+	writePacket(alias, newMedias[0], newForma, 5002, 96000, 5678, []byte{0x1c, 0x85, 0x01}) // FU-A, start of IDR
+	writePacket(alias, newMedias[0], newForma, 5003, 99000, 5678, []byte{0x01, 0x01})       // non-IDR
+
+	require.Equal(t, 4, len(pkts))
+	require.Equal(t, written{100, 1000, 1234}, pkts[0])
+	require.Equal(t, written{101, 4000, 1234}, pkts[1])
+
+	// sequence numbers, timestamps and SSRC continue from the previous source
+	require.Equal(t, uint16(102), pkts[2].seq)
+	require.Equal(t, uint32(1234), pkts[2].ssrc)
+	require.GreaterOrEqual(t, pkts[2].ts, uint32(4000))
+	require.Equal(t, written{103, pkts[2].ts + 3000, 1234}, pkts[3])
+}
+
+func TestStreamReannounceHandoff(t *testing.T) {
+	newMedias := func() media.Medias {
+		return media.Medias{{
+			Type: media.TypeVideo,
+			Formats: []formats.Format{&formats.H264{
+				PayloadTyp:        96,
+				SPS:               testFormatH264.SPS,
+				PPS:               testFormatH264.PPS,
+				PacketizationMode: 1,
+			}},
+		}}
+	}
+
+	medias := newMedias()
+
+	s, err := newStream(1472, medias, false, false, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	var payloads [][]byte
+	s.readerAdd(testStreamEntity{}, medias[0], medias[0].Formats[0], func(unit formatprocessor.Unit) {
+		for _, pkt := range unit.GetRTPPackets() {
+			payloads = append(payloads, pkt.Payload)
+		}
+	})
+
+	writePacket := func(s *stream, medi *media.Media, seq uint16, payload []byte) {
+		s.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: seq,
+					Timestamp:      uint32(seq) * 3000,
+				},
+				Payload: payload,
+			}},
+			NTP: time.Now(),
+		})
+	}
+
+	writePacket(s, medias[0], 100, []byte{0x05, 0x01})
+
+	replaced := 0
+	newMedias2 := newMedias()
+	alias, ok := s.reannounce(newMedias2, false, testStreamEntity{}, func() {
+		replaced++
+	})
+	require.Equal(t, true, ok)
+	require.Equal(t, s, s.writer())
+
+	// the previous source is routed until the first key frame of the new one
+	writePacket(s, medias[0], 101, []byte{0x01, 0x02})
+	writePacket(alias, newMedias2[0], 5000, []byte{0x01, 0x03})
+	writePacket(s, medias[0], 102, []byte{0x01, 0x04})
+	require.Equal(t, 0, replaced)
+
+	writePacket(alias, newMedias2[0], 5001, []byte{0x05, 0x05})
+	require.Equal(t, 1, replaced)
+	require.Equal(t, alias, s.writer())
+
+	// then, the previous source is discarded
+	writePacket(s, medias[0], 103, []byte{0x01, 0x06})
+	writePacket(alias, newMedias2[0], 5002, []byte{0x01, 0x07})
+
+	require.Equal(t, [][]byte{
+		{0x05, 0x01},
+		{0x01, 0x02},
+		{0x01, 0x04},
+		{0x05, 0x05},
+		{0x01, 0x07},
+	}, payloads)
+}
+
+func TestStreamPreserveSSRC(t *testing.T) {
+	newMedias := func() media.Medias {
+		return media.Medias{{
+			Type: media.TypeVideo,
+			Formats: []formats.Format{&formats.H264{
+				PayloadTyp:        96,
+				SPS:               testFormatH264.SPS,
+				PPS:               testFormatH264.PPS,
+				PacketizationMode: 1,
+			}},
+		}}
+	}
+
+	medias := newMedias()
+
+	s, err := newStream(1472, medias, false, true, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	require.Equal(t, []*uint32{nil}, s.ssrcs())
+
+	writeIDR := func(s *stream, medi *media.Media, seq uint16, ssrc uint32) {
+		s.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: seq,
+					SSRC:           ssrc,
+				},
+				Payload: []byte{0x05, 0x01},
+			}},
+			NTP: time.Now(),
+		})
+	}
+
+	writeIDR(s, medias[0], 100, 1234)
+
+	ssrc := uint32(1234)
+	require.Equal(t, []*uint32{&ssrc}, s.ssrcs())
+
+	newMedias2 := newMedias()
+	alias, ok := s.reannounce(newMedias2, false, testStreamEntity{}, nil)
+	require.Equal(t, true, ok)
+
+	var pkts []*rtp.Packet
+	s.readerAdd(testStreamEntity{}, medias[0], medias[0].Formats[0], func(unit formatprocessor.Unit) {
+		pkts = append(pkts, unit.GetRTPPackets()...)
+	})
+
+	writeIDR(alias, newMedias2[0], 5000, 5678)
+
+	require.Equal(t, 1, len(pkts))
+	require.Equal(t, uint16(5000), pkts[0].SequenceNumber)
+	require.Equal(t, uint32(5678), pkts[0].SSRC)
+
+	ssrc = 5678
+	require.Equal(t, []*uint32{&ssrc}, s.ssrcs())
+}
+
+func TestStreamReaders(t *testing.T) {
+	medias := media.Medias{testMediaH264}
+
+	s, err := newStream(1472, medias, false, false, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	forma := medias[0].Formats[0]
+
+	counts := make([]int, 3)
+	for i := range counts {
+		i := i
+		s.readerAdd(testStreamReader{id: i}, medias[0], forma, func(unit formatprocessor.Unit) {
+			counts[i]++
+		})
+	}
+
+	writeIDR := func() {
+		s.writeUnit(medias[0], forma, &formatprocessor.UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:     2,
+					Marker:      true,
+					PayloadType: 96,
+				},
+				Payload: []byte{0x05, 0x01},
+			}},
+			NTP: time.Now(),
+		})
+	}
+
+	writeIDR()
+	require.Equal(t, []int{1, 1, 1}, counts)
+
+	// adding a reader twice replaces its callback
+	s.readerAdd(testStreamReader{id: 0}, medias[0], forma, func(unit formatprocessor.Unit) {
+		counts[0] += 10
+	})
+
+	s.readerRemove(testStreamReader{id: 1})
+
+	writeIDR()
+	require.Equal(t, []int{11, 1, 2}, counts)
+}
+
+func BenchmarkStreamWriteUnit(b *testing.B) {
+	for _, ca := range []int{1, 10, 100, 1000} {
+		b.Run(fmt.Sprintf("%d readers", ca), func(b *testing.B) {
+			medias := media.Medias{testMediaH264}
+
+			s, err := newStream(1472, medias, false, false, nil, nil, new(uint64), nil, false, false, testStreamEntity{})
+			require.NoError(b, err)
+			defer s.close()
+
+			forma := medias[0].Formats[0]
+
+			for i := 0; i < ca; i++ {
+				s.readerAdd(testStreamReader{id: i}, medias[0], forma, func(unit formatprocessor.Unit) {})
+			}
+
+			// readers that are added and removed while units are written
+			done := make(chan struct{})
+			var wg sync.WaitGroup
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for {
+					select {
+					case <-done:
+						return
+					default:
+					}
+					s.readerAdd(testStreamReader{id: -1}, medias[0], forma, func(unit formatprocessor.Unit) {})
+					s.readerRemove(testStreamReader{id: -1})
+				}
+			}()
+
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				s.writeUnit(medias[0], forma, &formatprocessor.UnitH264{
+					RTPPackets: []*rtp.Packet{{
+						Header: rtp.Header{
+							Version:        2,
+							Marker:         true,
+							PayloadType:    96,
+							SequenceNumber: uint16(i),
+						},
+						Payload: []byte{0x05, 0x01},
+					}},
+					NTP: time.Now(),
+				})
+			}
+
+			b.StopTimer()
+			close(done)
+			wg.Wait()
+		})
+	}
+}
+
+func TestStreamRTSPFanoutShards(t *testing.T) {
+	f := newStreamRTSPFanout(media.Medias{testMediaH264})
+	f.maxShards = 3
+	defer f.close()
+
+	ip := net.ParseIP("127.0.0.1")
+
+	readerAdd := func(transport gortsplib.Transport, ip net.IP) *streamRTSPReader {
+		r, err := f.readerAdd(transport, ip, false)
+		require.NoError(t, err)
+		return r
+	}
+
+	// the first shard is filled before allocating a new one.
+	var readers []*streamRTSPReader
+	for i := 0; i < streamRTSPFanoutShardReaders; i++ {
+		r := readerAdd(gortsplib.TransportTCP, ip)
+		require.Equal(t, f.shards[0], r.shard)
+		readers = append(readers, r)
+	}
+
+	r := readerAdd(gortsplib.TransportTCP, ip)
+	require.Equal(t, 2, len(f.shards))
+	require.Equal(t, f.shards[1], r.shard)
+
+	// readers are put into the least loaded shard.
+	f.readerRemove(readers[0])
+	r = readerAdd(gortsplib.TransportTCP, ip)
+	require.Equal(t, f.shards[1], r.shard)
+
+	// UDP readers of the same IP share the same shard.
+	udp1 := readerAdd(gortsplib.TransportUDP, net.ParseIP("192.168.2.1"))
+	require.Equal(t, f.shards[1], udp1.shard)
+	for i := 0; i < 3; i++ {
+		readerAdd(gortsplib.TransportTCP, ip)
+	}
+	udp2 := readerAdd(gortsplib.TransportUDP, net.ParseIP("192.168.2.1"))
+	require.Equal(t, f.shards[1], udp2.shard)
+
+	// multicast readers are all put into the first shard.
+	r = readerAdd(gortsplib.TransportUDPMulticast, ip)
+	require.Equal(t, f.shards[0], r.shard)
+
+	f.readerRemove(udp1)
+	f.readerRemove(udp2)
+	require.Equal(t, map[string]int{}, f.shards[1].udpIPs)
+}
+
+func TestStreamRTSPFanoutBackchannel(t *testing.T) {
+	f := newStreamRTSPFanout(media.Medias{testMediaH264})
+	defer f.close()
+
+	ip := net.ParseIP("127.0.0.1")
+
+	_, err := f.readerAdd(gortsplib.TransportTCP, ip, true)
+	require.EqualError(t, err, "the stream has no backchannel")
+	require.Equal(t, media.Medias(nil), f.backchannelMedias())
+
+	backMedia := &media.Media{
+		Type:      media.TypeAudio,
+		Direction: media.DirectionSendonly,
+		Formats:   []formats.Format{&formats.G711{MULaw: true}},
+	}
+
+	var received []*rtp.Packet
+	f.setBackchannel(backMedia, func(pkt *rtp.Packet) {
+		received = append(received, pkt)
+	})
+	require.Equal(t, media.Medias{testMediaH264, backMedia}, f.backchannelMedias())
+
+	// readers of the backchannel are put into a dedicated shard.
+	r1, err := f.readerAdd(gortsplib.TransportTCP, ip, true)
+	require.NoError(t, err)
+	require.Equal(t, 2, len(f.shards))
+	require.Equal(t, 2, len(r1.serverStream().Medias()))
+
+	r2, err := f.readerAdd(gortsplib.TransportTCP, ip, true)
+	require.NoError(t, err)
+	require.Equal(t, r1.shard, r2.shard)
+
+	r3, err := f.readerAdd(gortsplib.TransportTCP, ip, false)
+	require.NoError(t, err)
+	require.Equal(t, f.shards[0], r3.shard)
+
+	// only one reader at a time can send audio.
+	require.Equal(t, true, f.backchannelAcquire(r1))
+	require.Equal(t, false, f.backchannelAcquire(r2))
+
+	f.writeBackchannel(r1, &rtp.Packet{Header: rtp.Header{PayloadType: 0}})
+	f.writeBackchannel(r1, &rtp.Packet{Header: rtp.Header{PayloadType: 96}})
+	f.writeBackchannel(r2, &rtp.Packet{Header: rtp.Header{PayloadType: 0}})
+	require.Equal(t, 1, len(received))
+
+	f.readerRemove(r1)
+	require.Equal(t, true, f.backchannelAcquire(r2))
+}
+
+func TestStreamRTSPFanoutPacket(t *testing.T) {
+	pkt := &rtp.Packet{Header: rtp.Header{Version: 2, PayloadType: 96}}
+
+	p := newStreamRTSPFanoutPacket(testMediaH264, pkt, time.Now(), 2)
+
+	// the packet is kept until all shards have released it.
+	p.release()
+	require.Equal(t, pkt, p.pkt)
+
+	p.release()
+	require.Equal(t, (*rtp.Packet)(nil), p.pkt)
+	require.Equal(t, int32(0), p.refs)
+}
+
+func TestStreamRTSPFanoutReaders(t *testing.T) {
+	defer func(v int) { streamRTSPFanoutMaxShards = v }(streamRTSPFanoutMaxShards)
+	streamRTSPFanoutMaxShards = 4
+
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  all:\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	source := gortsplib.Client{}
+	err := source.StartRecording("rtsp://localhost:8554/teststream", media.Medias{testMediaH264})
+	require.NoError(t, err)
+	defer source.Close()
+
+	// enough readers to fill more than one shard.
+	n := streamRTSPFanoutShardReaders*2 + 1
+	var wg sync.WaitGroup
+	wg.Add(n)
+
+	for i := 0; i < n; i++ {
+		c := testStreamRTSPReader(t, func(pkt *rtp.Packet) {
+			require.Equal(t, []byte{0x05, 0x01}, pkt.Payload)
+			wg.Done()
+		})
+		defer c.Close()
+	}
+
+	err = source.WritePacketRTP(testMediaH264, &rtp.Packet{
+		Header: rtp.Header{
+			Version:     2,
+			Marker:      true,
+			PayloadType: 96,
+		},
+		Payload: []byte{0x05, 0x01},
+	})
+	require.NoError(t, err)
+
+	wg.Wait()
+}
+
+func testStreamRTSPReader(t testing.TB, onPacket func(*rtp.Packet)) *gortsplib.Client {
+	c := &gortsplib.Client{
+		Transport: func() *gortsplib.Transport {
+			v := gortsplib.TransportTCP
+			return &v
+		}(),
+	}
+
+	u, err := url.Parse("rtsp://localhost:8554/teststream")
+	require.NoError(t, err)
+
+	err = c.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+
+	medias, baseURL, _, err := c.Describe(u)
+	require.NoError(t, err)
+
+	err = c.SetupAll(medias, baseURL)
+	require.NoError(t, err)
+
+	c.OnPacketRTP(medias[0], medias[0].Formats[0], onPacket)
+
+	_, err = c.Play(nil)
+	require.NoError(t, err)
+
+	return c
+}
+
+func BenchmarkStreamRTSPReaders(b *testing.B) {
+	for _, ca := range []int{10, 100, 500} {
+		b.Run(fmt.Sprintf("%d readers", ca), func(b *testing.B) {
+			p, ok := newInstance("rtmpDisable: yes\n" +
+				"hlsDisable: yes\n" +
+				"webrtcDisable: yes\n" +
+				"readBufferCount: 4096\n" +
+				"paths:\n" +
+				"  all:\n")
+			require.Equal(b, true, ok)
+			defer p.Close()
+
+			source := gortsplib.Client{
+				Transport: func() *gortsplib.Transport {
+					v := gortsplib.TransportTCP
+					return &v
+				}(),
+			}
+			err := source.StartRecording("rtsp://localhost:8554/teststream", media.Medias{testMediaH264})
+			require.NoError(b, err)
+			defer source.Close()
+
+			var received uint64
+
+			for i := 0; i < ca; i++ {
+				c := testStreamRTSPReader(b, func(pkt *rtp.Packet) {
+					atomic.AddUint64(&received, 1)
+				})
+				defer c.Close()
+			}
+
+			b.ResetTimer()
+
+			// packets are written in bursts that fit into queues,
+			// and every burst is waited until it's received by all readers.
+			const burst = 128
+
+			for i := 0; i < b.N; i++ {
+				err := source.WritePacketRTP(testMediaH264, &rtp.Packet{
+					Header: rtp.Header{
+						Version:        2,
+						Marker:         true,
+						PayloadType:    96,
+						SequenceNumber: uint16(i),
+					},
+					Payload: []byte{0x05, 0x01},
+				})
+				require.NoError(b, err)
+
+				if (i+1)%burst == 0 || i == b.N-1 {
+					expected := uint64((i + 1) * ca)
+					deadline := time.Now().Add(5 * time.Second)
+					for atomic.LoadUint64(&received) < expected && time.Now().Before(deadline) {
+						time.Sleep(100 * time.Microsecond)
+					}
+				}
+			}
+
+			b.StopTimer()
+			b.ReportMetric(float64(atomic.LoadUint64(&received))/float64(b.N*ca)*100, "%received")
+		})
+	}
+}
+
+func TestStreamLatency(t *testing.T) {
+	l := newStreamLatency()
+
+	require.Equal(t, map[string]streamLatencyPercentiles{}, l.percentiles())
+
+	// units without an ingest time are ignored
+	l.observe(streamLatencyRTMP, time.Time{})
+	require.Equal(t, map[string]streamLatencyPercentiles{}, l.percentiles())
+
+	w := &streamLatencyWindow{}
+	for i := 1; i <= 100; i++ {
+		w.add(time.Duration(i) * time.Millisecond)
+	}
+	require.Equal(t, streamLatencyPercentiles{
+		P50: 50 * time.Millisecond,
+		P99: 99 * time.Millisecond,
+	}, w.percentiles())
+
+	// old samples are discarded
+	for i := 0; i < streamLatencyWindowSize; i++ {
+		w.add(time.Second)
+	}
+	require.Equal(t, streamLatencyPercentiles{
+		P50: time.Second,
+		P99: time.Second,
+	}, w.percentiles())
+
+	l.observe(streamLatencyHLS, time.Now().Add(-2*time.Second))
+	p := l.percentiles()
+	require.Equal(t, 1, len(p))
+	require.GreaterOrEqual(t, p[streamLatencyHLS].P50, 2*time.Second)
+}
+
+func TestStreamHealthScore(t *testing.T) {
+	for _, ca := range []struct {
+		name             string
+		received         uint64
+		lost             uint64
+		units            uint64
+		errors           uint64
+		missingKeyFrames uint64
+		score            int
+	}{
+		{"healthy", 1000, 0, 500, 0, 0, 100},
+		{"lost packets", 990, 10, 500, 0, 0, 90},
+		{"decode errors", 1000, 0, 490, 10, 0, 80},
+		{"missing key frames", 1000, 0, 500, 0, 1, 50},
+		{"corrupted", 900, 100, 450, 50, 1, 0},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.score, streamHealthScore(ca.received, ca.lost, ca.units, ca.errors, ca.missingKeyFrames))
+		})
+	}
+}
+
+func TestStreamHealth(t *testing.T) {
+	medias := media.Medias{{
+		Type: media.TypeVideo,
+		Formats: []formats.Format{&formats.H264{
+			PayloadTyp:        96,
+			SPS:               testFormatH264.SPS,
+			PPS:               testFormatH264.PPS,
+			PacketizationMode: 1,
+		}},
+	}}
+
+	scores := make(chan int, 1)
+
+	s, err := newStream(1472, medias, false, false, nil, nil, new(uint64), func(score int) {
+		scores <- score
+	}, false, false, testStreamEntity{})
+	require.NoError(t, err)
+	defer s.close()
+
+	require.Nil(t, s.health.current())
+
+	for _, seq := range []uint16{100, 101, 102, 110} {
+		s.writeUnit(medias[0], medias[0].Formats[0], &formatprocessor.UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: seq,
+					SSRC:           1234,
+				},
+				Payload: []byte{0x05, 0x01},
+			}},
+			NTP: time.Now(),
+		})
+
+		// end the period before the last packet.
+		if seq == 102 {
+			s.health.periodStart -= int64(streamHealthPeriod)
+		}
+	}
+
+	// 7 packets out of 11 have been lost.
+	require.Equal(t, 0, <-scores)
+	require.Equal(t, 0, *s.health.current())
+}
+
+func TestStreamNormalizer(t *testing.T) {
+	n := newStreamNormalizer()
+	start := n.start
+
+	video := &streamFormat{}
+	audio := &streamFormat{}
+
+	// the clock of the video track runs 0.1% faster than the one of the audio track,
+	// and the audio track starts later, with an arbitrary timestamp.
+	for i := 0; i < 20000; i++ {
+		now := start.Add(time.Duration(i) * 20 * time.Millisecond)
+
+		pts := time.Duration(i) * 20 * time.Millisecond * 1001 / 1000
+		n.normalize(video, &pts, now)
+		require.InDelta(t, float64(now.Sub(start)), float64(pts), float64(streamNormalizerMaxDrift))
+
+		if i >= 100 {
+			pts = 5*time.Hour + time.Duration(i-100)*20*time.Millisecond
+			n.normalize(audio, &pts, now)
+			require.InDelta(t, float64(now.Sub(start)), float64(pts), float64(100*time.Millisecond))
+		}
+	}
+
+	// drift of the video track is compensated.
+	now := start.Add(20000 * 20 * time.Millisecond)
+	pts := 20000 * 20 * time.Millisecond * 1001 / 1000
+	n.normalize(video, &pts, now)
+	require.InDelta(t, float64(now.Sub(start)), float64(pts), float64(100*time.Millisecond))
+
+	// reset of the clock of the audio track.
+	pts = 0
+	n.normalize(audio, &pts, now)
+	require.Equal(t, now.Sub(start), pts)
+}
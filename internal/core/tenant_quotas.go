@@ -0,0 +1,143 @@
+package core
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/conf"
+)
+
+const (
+	tenantBandwidthPeriod = 1 * time.Second
+)
+
+type tenantQuotaKind int
+
+const (
+	tenantQuotaReader tenantQuotaKind = iota
+	tenantQuotaPublisher
+)
+
+// tenantQuotas enforces the quotas of tenants.
+// It is not thread safe and must be used by pathManager only.
+type tenantQuotas struct {
+	tenants map[string]*conf.TenantConf
+
+	lastBytesReceived map[*path]uint64
+	bandwidth         map[string]int
+}
+
+func newTenantQuotas(tenants map[string]*conf.TenantConf) *tenantQuotas {
+	return &tenantQuotas{
+		tenants:           tenants,
+		lastBytesReceived: make(map[*path]uint64),
+		bandwidth:         make(map[string]int),
+	}
+}
+
+// ticker returns a ticker that drives bandwidth measurements, or nil
+// if no tenant has a bandwidth quota.
+func (q *tenantQuotas) ticker() *time.Ticker {
+	for _, tconf := range q.tenants {
+		if tconf.MaxBandwidth != 0 {
+			return time.NewTicker(tenantBandwidthPeriod)
+		}
+	}
+	return nil
+}
+
+// measureBandwidth updates the incoming bandwidth of every tenant.
+func (q *tenantQuotas) measureBandwidth(paths map[string]*path) {
+	bandwidth := make(map[string]int)
+	lastBytesReceived := make(map[*path]uint64, len(paths))
+
+	for name, pa := range paths {
+		cur := atomic.LoadUint64(pa.bytesReceived)
+		lastBytesReceived[pa] = cur
+
+		tenantName, tconf := conf.FindTenant(q.tenants, name)
+		if tconf == nil {
+			continue
+		}
+
+		// paths that were not present in the previous measurement are skipped.
+		if prev, ok := q.lastBytesReceived[pa]; ok {
+			bandwidth[tenantName] += int(float64((cur-prev)*8) / tenantBandwidthPeriod.Seconds())
+		}
+	}
+
+	q.lastBytesReceived = lastBytesReceived
+	q.bandwidth = bandwidth
+}
+
+// checkPathCreate checks whether a path can be created.
+func (q *tenantQuotas) checkPathCreate(paths map[string]*path, name string) error {
+	tenantName, tconf := conf.FindTenant(q.tenants, name)
+	if tconf == nil || tconf.MaxPaths == 0 {
+		return nil
+	}
+
+	count := 0
+	for otherName := range paths {
+		if tconf.Owns(otherName) {
+			count++
+		}
+	}
+
+	if count >= tconf.MaxPaths {
+		return fmt.Errorf("tenant '%s' has reached the maximum number of paths (%d)", tenantName, tconf.MaxPaths)
+	}
+
+	return nil
+}
+
+// checkAdd checks whether a reader or a publisher can be added to a path.
+func (q *tenantQuotas) checkAdd(paths map[string]*path, name string, kind tenantQuotaKind) error {
+	tenantName, tconf := conf.FindTenant(q.tenants, name)
+	if tconf == nil {
+		return nil
+	}
+
+	switch kind {
+	case tenantQuotaReader:
+		if tconf.MaxReaders == 0 {
+			return nil
+		}
+
+		count := int64(0)
+		for otherName, pa := range paths {
+			if tconf.Owns(otherName) {
+				count += atomic.LoadInt64(pa.readerCount)
+			}
+		}
+
+		if count >= int64(tconf.MaxReaders) {
+			return fmt.Errorf("tenant '%s' has reached the maximum number of readers (%d)",
+				tenantName, tconf.MaxReaders)
+		}
+
+	case tenantQuotaPublisher:
+		if tconf.MaxPublishers != 0 {
+			count := int64(0)
+			for otherName, pa := range paths {
+				// a publisher that replaces another one doesn't increase the count.
+				if otherName != name && tconf.Owns(otherName) {
+					count += atomic.LoadInt64(pa.publisherCount)
+				}
+			}
+
+			if count >= int64(tconf.MaxPublishers) {
+				return fmt.Errorf("tenant '%s' has reached the maximum number of publishers (%d)",
+					tenantName, tconf.MaxPublishers)
+			}
+		}
+
+		if tconf.MaxBandwidth != 0 && q.bandwidth[tenantName] >= tconf.MaxBandwidth {
+			return fmt.Errorf("tenant '%s' has reached the maximum bandwidth (%d bit/s)",
+				tenantName, tconf.MaxBandwidth)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package core
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/media"
+
+	"github.com/aler9/mediamtx/internal/conf"
+	"github.com/aler9/mediamtx/internal/formatprocessor"
+	"github.com/aler9/mediamtx/internal/logger"
+	"github.com/aler9/mediamtx/internal/testsrc"
+)
+
+type testsrcSourceParent interface {
+	logger.Writer
+	sourceStaticImplSetReady(req pathSourceStaticSetReadyReq) pathSourceStaticSetReadyRes
+	sourceStaticImplSetNotReady(req pathSourceStaticSetNotReadyReq)
+}
+
+// testsrcSource generates a H264 test pattern.
+type testsrcSource struct {
+	parent testsrcSourceParent
+}
+
+func newTestsrcSource(
+	parent testsrcSourceParent,
+) *testsrcSource {
+	return &testsrcSource{
+		parent: parent,
+	}
+}
+
+func (s *testsrcSource) Log(level logger.Level, format string, args ...interface{}) {
+	s.parent.Log(level, "[testsrc source] "+format, args...)
+}
+
+// run implements sourceStaticImpl.
+func (s *testsrcSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan *conf.PathConf) error {
+	enc, err := testsrc.NewEncoder(testsrc.Params{
+		Width:   cnf.TestsrcWidth,
+		Height:  cnf.TestsrcHeight,
+		FPS:     cnf.TestsrcFPS,
+		Bitrate: cnf.TestsrcBitrate,
+	})
+	if err != nil {
+		return err
+	}
+
+	medi := &media.Media{
+		Type: media.TypeVideo,
+		Formats: []formats.Format{&formats.H264{
+			PayloadTyp:        96,
+			PacketizationMode: 1,
+			SPS:               enc.SPS(),
+			PPS:               enc.PPS(),
+		}},
+	}
+	medias := media.Medias{medi}
+
+	res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
+		medias:             medias,
+		generateRTPPackets: true,
+	})
+	if res.err != nil {
+		return res.err
+	}
+
+	defer func() {
+		s.parent.sourceStaticImplSetNotReady(pathSourceStaticSetNotReadyReq{})
+	}()
+
+	s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
+
+	start := time.Now()
+	count := 0
+
+	timer := time.NewTimer(0)
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-timer.C:
+			pts := time.Duration(count) * time.Second / time.Duration(cnf.TestsrcFPS)
+			now := time.Now()
+
+			res.stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
+				PTS: pts,
+				AU:  enc.Encode(now),
+				NTP: now,
+			})
+
+			count++
+			timer.Reset(time.Until(start.Add(time.Duration(count) * time.Second / time.Duration(cnf.TestsrcFPS))))
+
+		case <-reloadConf:
+
+		case <-ctx.Done():
+			return fmt.Errorf("terminated")
+		}
+	}
+}
+
+// apiSourceDescribe implements sourceStaticImpl.
+func (*testsrcSource) apiSourceDescribe() interface{} {
+	return struct {
+		Type string `json:"type"`
+	}{"testsrcSource"}
+}
@@ -0,0 +1,78 @@
+package core
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3"
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/gortsplib/v3/pkg/url"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTestsrcSource(t *testing.T) {
+	p, ok := newInstance("rtmpDisable: yes\n" +
+		"hlsDisable: yes\n" +
+		"webrtcDisable: yes\n" +
+		"paths:\n" +
+		"  test:\n" +
+		"    source: testsrc\n" +
+		"    testsrcWidth: 320\n" +
+		"    testsrcHeight: 240\n" +
+		"    testsrcFPS: 10\n")
+	require.Equal(t, true, ok)
+	defer p.Close()
+
+	time.Sleep(500 * time.Millisecond)
+
+	u, err := url.Parse("rtsp://127.0.0.1:8554/test")
+	require.NoError(t, err)
+
+	c := gortsplib.Client{}
+	err = c.Start(u.Scheme, u.Host)
+	require.NoError(t, err)
+	defer c.Close()
+
+	medias, baseURL, _, err := c.Describe(u)
+	require.NoError(t, err)
+
+	var forma *formats.H264
+	medi := medias.FindFormat(&forma)
+	require.NotNil(t, medi)
+
+	var sps h264.SPS
+	err = sps.Unmarshal(forma.SPS)
+	require.NoError(t, err)
+	require.Equal(t, 320, sps.Width())
+	require.Equal(t, 240, sps.Height())
+
+	err = c.SetupAll(medias, baseURL)
+	require.NoError(t, err)
+
+	dec := forma.CreateDecoder()
+	received := make(chan struct{})
+	var once bool
+
+	c.OnPacketRTP(medi, forma, func(pkt *rtp.Packet) {
+		au, _, err := dec.Decode(pkt)
+		if err != nil || once {
+			return
+		}
+
+		if h264.IDRPresent(au) {
+			once = true
+			close(received)
+		}
+	})
+
+	_, err = c.Play(nil)
+	require.NoError(t, err)
+
+	select {
+	case <-received:
+	case <-time.After(5 * time.Second):
+		t.Errorf("timed out")
+	}
+}
@@ -7,15 +7,9 @@ import (
 	"time"
 
 	"github.com/asticode/go-astits"
-	"github.com/bluenviron/gortsplib/v3/pkg/formats"
-	"github.com/bluenviron/gortsplib/v3/pkg/media"
-	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
-	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
-	"github.com/bluenviron/mediacommon/pkg/formats/mpegts"
 	"golang.org/x/net/ipv4"
 
 	"github.com/aler9/mediamtx/internal/conf"
-	"github.com/aler9/mediamtx/internal/formatprocessor"
 	"github.com/aler9/mediamtx/internal/logger"
 )
 
@@ -24,53 +18,65 @@ const (
 	udpMTU       = 1472
 )
 
-var opusDurations = [32]int{
-	480, 960, 1920, 2880, /* Silk NB */
-	480, 960, 1920, 2880, /* Silk MB */
-	480, 960, 1920, 2880, /* Silk WB */
-	480, 960, /* Hybrid SWB */
-	480, 960, /* Hybrid FB */
-	120, 240, 480, 960, /* CELT NB */
-	120, 240, 480, 960, /* CELT NB */
-	120, 240, 480, 960, /* CELT NB */
-	120, 240, 480, 960, /* CELT NB */
+// mpegtsLossDetector detects lost MPEG-TS packets by using continuity counters.
+type mpegtsLossDetector struct {
+	counters map[uint16]uint8
 }
 
-func opusGetPacketDuration(pkt []byte) time.Duration {
-	if len(pkt) == 0 {
+func newMPEGTSLossDetector() *mpegtsLossDetector {
+	return &mpegtsLossDetector{
+		counters: make(map[uint16]uint8),
+	}
+}
+
+// process processes a MPEG-TS packet and returns the number of lost packets.
+func (d *mpegtsLossDetector) process(pkt []byte) uint64 {
+	if len(pkt) < 4 || pkt[0] != 0x47 {
 		return 0
 	}
 
-	frameDuration := opusDurations[pkt[0]>>3]
-
-	frameCount := 0
-	switch pkt[0] & 3 {
-	case 0:
-		frameCount = 1
-	case 1:
-		frameCount = 2
-	case 2:
-		frameCount = 2
-	case 3:
-		if len(pkt) < 2 {
-			return 0
-		}
-		frameCount = int(pkt[1] & 63)
+	pid := uint16(pkt[1]&0x1f)<<8 | uint16(pkt[2])
+	if pid == 0x1fff { // null packet
+		return 0
+	}
+
+	adaptationFieldControl := (pkt[3] >> 4) & 0x03
+	counter := pkt[3] & 0x0f
+
+	// discontinuity indicator
+	if (adaptationFieldControl&0x02) != 0 && len(pkt) >= 6 && pkt[4] > 0 && (pkt[5]&0x80) != 0 {
+		delete(d.counters, pid)
+	}
+
+	// counters are incremented only when packets contain a payload
+	if (adaptationFieldControl & 0x01) == 0 {
+		return 0
 	}
 
-	return (time.Duration(frameDuration) * time.Duration(frameCount) * time.Millisecond) / 48
+	prev, ok := d.counters[pid]
+	d.counters[pid] = counter
+
+	if !ok || counter == prev { // first packet or duplicate
+		return 0
+	}
+
+	return uint64((counter - prev - 1) & 0x0f)
 }
 
 type packetConnReader struct {
-	pc        net.PacketConn
-	midbuf    []byte
-	midbufpos int
+	pc            net.PacketConn
+	lossDetector  *mpegtsLossDetector
+	onPacketsLost func(uint64)
+	midbuf        []byte
+	midbufpos     int
 }
 
-func newPacketConnReader(pc net.PacketConn) *packetConnReader {
+func newPacketConnReader(pc net.PacketConn, onPacketsLost func(uint64)) *packetConnReader {
 	return &packetConnReader{
-		pc:     pc,
-		midbuf: make([]byte, 0, 1500),
+		pc:            pc,
+		lossDetector:  newMPEGTSLossDetector(),
+		onPacketsLost: onPacketsLost,
+		midbuf:        make([]byte, 0, 1500),
 	}
 }
 
@@ -91,6 +97,13 @@ func (r *packetConnReader) Read(p []byte) (int, error) {
 	}
 
 	r.midbuf = r.midbuf[:mn]
+
+	for i := 0; i < mn; i += 188 {
+		if lost := r.lossDetector.process(r.midbuf[i : i+188]); lost != 0 {
+			r.onPacketsLost(lost)
+		}
+	}
+
 	n := copy(p, r.midbuf)
 	r.midbufpos = n
 	return n, nil
@@ -104,15 +117,18 @@ type udpSourceParent interface {
 
 type udpSource struct {
 	readTimeout conf.StringDuration
+	stats       *sourceStaticStats
 	parent      udpSourceParent
 }
 
 func newUDPSource(
 	readTimeout conf.StringDuration,
+	stats *sourceStaticStats,
 	parent udpSourceParent,
 ) *udpSource {
 	return &udpSource{
 		readTimeout: readTimeout,
+		stats:       stats,
 		parent:      parent,
 	}
 }
@@ -159,142 +175,21 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 
 	dem := astits.NewDemuxer(
 		context.Background(),
-		newPacketConnReader(pc),
-		astits.DemuxerOptPacketSize(188))
+		newPacketConnReader(pc, s.stats.addPacketsLost),
+		astits.DemuxerOptPacketSize(188),
+		astits.DemuxerOptPacketsParser(scte35PacketsParser))
 
 	readerErr := make(chan error)
 
 	go func() {
 		readerErr <- func() error {
 			pc.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
-			tracks, err := mpegts.FindTracks(dem)
+			tracks, err := mpegtsFindTracks(dem)
 			if err != nil {
 				return err
 			}
 
-			var medias media.Medias
-			mediaCallbacks := make(map[uint16]func(time.Duration, []byte), len(tracks))
-			var stream *stream
-
-			for _, track := range tracks {
-				var medi *media.Media
-
-				switch tcodec := track.Codec.(type) {
-				case *mpegts.CodecH264:
-					medi = &media.Media{
-						Type: media.TypeVideo,
-						Formats: []formats.Format{&formats.H264{
-							PayloadTyp:        96,
-							PacketizationMode: 1,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						au, err := h264.AnnexBUnmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH264{
-							PTS: pts,
-							AU:  au,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecH265:
-					medi = &media.Media{
-						Type: media.TypeVideo,
-						Formats: []formats.Format{&formats.H265{
-							PayloadTyp: 96,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						au, err := h264.AnnexBUnmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitH265{
-							PTS: pts,
-							AU:  au,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecMPEG4Audio:
-					medi = &media.Media{
-						Type: media.TypeAudio,
-						Formats: []formats.Format{&formats.MPEG4Audio{
-							PayloadTyp:       96,
-							SizeLength:       13,
-							IndexLength:      3,
-							IndexDeltaLength: 3,
-							Config:           &tcodec.Config,
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						var pkts mpeg4audio.ADTSPackets
-						err := pkts.Unmarshal(data)
-						if err != nil {
-							s.Log(logger.Warn, "%v", err)
-							return
-						}
-
-						aus := make([][]byte, len(pkts))
-						for i, pkt := range pkts {
-							aus[i] = pkt.AU
-						}
-
-						stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitMPEG4Audio{
-							PTS: pts,
-							AUs: aus,
-							NTP: time.Now(),
-						})
-					}
-
-				case *mpegts.CodecOpus:
-					medi = &media.Media{
-						Type: media.TypeAudio,
-						Formats: []formats.Format{&formats.Opus{
-							PayloadTyp: 96,
-							IsStereo:   (tcodec.Channels == 2),
-						}},
-					}
-
-					mediaCallbacks[track.ES.ElementaryPID] = func(pts time.Duration, data []byte) {
-						pos := 0
-
-						for {
-							var au mpegts.OpusAccessUnit
-							n, err := au.Unmarshal(data[pos:])
-							if err != nil {
-								s.Log(logger.Warn, "%v", err)
-								return
-							}
-							pos += n
-
-							stream.writeUnit(medi, medi.Formats[0], &formatprocessor.UnitOpus{
-								PTS:   pts,
-								Frame: au.Frame,
-								NTP:   time.Now(),
-							})
-
-							if len(data[pos:]) == 0 {
-								break
-							}
-
-							pts += opusGetPacketDuration(au.Frame)
-						}
-					}
-				}
-
-				medias = append(medias, medi)
-			}
+			medias, writers := mpegtsMedias(tracks, s)
 
 			res := s.parent.sourceStaticImplSetReady(pathSourceStaticSetReadyReq{
 				medias:             medias,
@@ -310,41 +205,9 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 
 			s.Log(logger.Info, "ready: %s", sourceMediaInfo(medias))
 
-			stream = res.stream
-			var timedec *mpegts.TimeDecoder
-
-			for {
+			return mpegtsReadData(dem, res.stream, writers, func() {
 				pc.SetReadDeadline(time.Now().Add(time.Duration(s.readTimeout)))
-				data, err := dem.NextData()
-				if err != nil {
-					return err
-				}
-
-				if data.PES == nil {
-					continue
-				}
-
-				if data.PES.Header.OptionalHeader == nil ||
-					data.PES.Header.OptionalHeader.PTSDTSIndicator == astits.PTSDTSIndicatorNoPTSOrDTS ||
-					data.PES.Header.OptionalHeader.PTSDTSIndicator == astits.PTSDTSIndicatorIsForbidden {
-					return fmt.Errorf("PTS is missing")
-				}
-
-				var pts time.Duration
-				if timedec == nil {
-					timedec = mpegts.NewTimeDecoder(data.PES.Header.OptionalHeader.PTS.Base)
-					pts = 0
-				} else {
-					pts = timedec.Decode(data.PES.Header.OptionalHeader.PTS.Base)
-				}
-
-				cb, ok := mediaCallbacks[data.PID]
-				if !ok {
-					continue
-				}
-
-				cb(pts, data.PES.Data)
-			}
+			})
 		}()
 	}()
 
@@ -360,8 +223,9 @@ func (s *udpSource) run(ctx context.Context, cnf *conf.PathConf, reloadConf chan
 }
 
 // apiSourceDescribe implements sourceStaticImpl.
-func (*udpSource) apiSourceDescribe() interface{} {
+func (s *udpSource) apiSourceDescribe() interface{} {
 	return struct {
 		Type string `json:"type"`
-	}{"udpSource"}
+		sourceStaticStatsAPI
+	}{"udpSource", s.stats.apiDescribe()}
 }
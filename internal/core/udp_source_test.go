@@ -0,0 +1,29 @@
+package core
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPEGTSLossDetector(t *testing.T) {
+	d := newMPEGTSLossDetector()
+
+	pkt := func(pid uint16, counter uint8) []byte {
+		byts := make([]byte, 188)
+		byts[0] = 0x47
+		byts[1] = byte(pid >> 8)
+		byts[2] = byte(pid)
+		byts[3] = 0x10 | counter
+		return byts
+	}
+
+	require.Equal(t, uint64(0), d.process(pkt(256, 14)))
+	require.Equal(t, uint64(0), d.process(pkt(256, 15)))
+	require.Equal(t, uint64(0), d.process(pkt(256, 0)))
+	require.Equal(t, uint64(0), d.process(pkt(256, 0))) // duplicate
+	require.Equal(t, uint64(0), d.process(pkt(257, 5)))
+	require.Equal(t, uint64(2), d.process(pkt(256, 3)))
+	require.Equal(t, uint64(14), d.process(pkt(256, 2)))
+	require.Equal(t, uint64(0), d.process(pkt(0x1fff, 9)))
+}
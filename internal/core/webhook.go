@@ -0,0 +1,28 @@
+package core
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+)
+
+// webhookPost sends a JSON payload to a webhook with a POST request.
+func webhookPost(httpClient *http.Client, ur string, payload interface{}) error {
+	enc, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	res, err := httpClient.Post(ur, "application/json", bytes.NewReader(enc))
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	return nil
+}
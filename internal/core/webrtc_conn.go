@@ -95,6 +95,7 @@ func gatherMedias(tracks []*webRTCTrack) media.Medias {
 
 type webRTCConnPathManager interface {
 	readerAdd(req pathReaderAddReq) pathReaderSetupPlayRes
+	readerRejected(pathName string, protocol string, reason pathReaderRejectionReason, message string)
 }
 
 type webRTCConnParent interface {
@@ -105,6 +106,8 @@ type webRTCConnParent interface {
 type webRTCConn struct {
 	readBufferCount   int
 	pathName          string
+	query             string
+	identity          authIdentity
 	wsconn            *websocket.ServerConn
 	iceServers        []string
 	wg                *sync.WaitGroup
@@ -128,6 +131,8 @@ func newWebRTCConn(
 	parentCtx context.Context,
 	readBufferCount int,
 	pathName string,
+	query string,
+	identity authIdentity,
 	wsconn *websocket.ServerConn,
 	iceServers []string,
 	wg *sync.WaitGroup,
@@ -142,6 +147,8 @@ func newWebRTCConn(
 	c := &webRTCConn{
 		readBufferCount:   readBufferCount,
 		pathName:          pathName,
+		query:             query,
+		identity:          identity,
 		wsconn:            wsconn,
 		iceServers:        iceServers,
 		wg:                wg,
@@ -302,10 +309,13 @@ func (c *webRTCConn) runInner(ctx context.Context) error {
 	res := c.pathManager.readerAdd(pathReaderAddReq{
 		author:   c,
 		pathName: c.pathName,
+		query:    c.query,
+		protocol: "webrtc",
 		authenticate: func(
 			pathIPs []fmt.Stringer,
 			pathUser conf.Credential,
 			pathPass conf.Credential,
+			pathPermissions conf.PathPermissions,
 		) error {
 			return nil
 		},
@@ -341,8 +351,12 @@ func (c *webRTCConn) runInner(ctx context.Context) error {
 	}
 
 	if tracks == nil {
-		return fmt.Errorf(
-			"the stream doesn't contain any supported codec, which are currently H264, VP8, VP9, G711, G722, Opus")
+		err := newReaderErrCodecsUnsupported("webrtc", webRTCSupportedCodecs, res.stream.medias())
+		c.pathManager.readerRejected(c.pathName, "webrtc", pathReaderRejectionCodecUnsupported, err.Error())
+
+		// send the reason to the client in place of ICE servers.
+		c.wsconn.WriteJSON(err.problem())
+		return err
 	}
 
 	err = c.wsconn.WriteJSON(c.genICEServers())
@@ -539,6 +553,7 @@ outer:
 		res.stream.readerAdd(c, track.media, track.format, func(unit formatprocessor.Unit) {
 			ringBuffer.Push(func() {
 				ctrack.cb(unit, ctx, writeError)
+				res.stream.latency.observe(streamLatencyWebRTC, unit.GetNTP())
 			})
 		})
 	}
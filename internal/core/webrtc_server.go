@@ -17,6 +17,7 @@ import (
 	"github.com/pion/ice/v2"
 	"github.com/pion/webrtc/v3"
 
+	"github.com/aler9/mediamtx/internal/auth"
 	"github.com/aler9/mediamtx/internal/conf"
 	"github.com/aler9/mediamtx/internal/logger"
 	"github.com/aler9/mediamtx/internal/websocket"
@@ -57,8 +58,16 @@ type webRTCServerAPIConnsKickReq struct {
 	res chan webRTCServerAPIConnsKickRes
 }
 
+type webRTCServerAPIConnsRevokeReq struct {
+	user  string
+	token string
+	res   chan int
+}
+
 type webRTCConnNewReq struct {
 	pathName string
+	query    string
+	identity authIdentity
 	wsconn   *websocket.ServerConn
 	res      chan *webRTCConn
 }
@@ -69,6 +78,8 @@ type webRTCServerParent interface {
 
 type webRTCServer struct {
 	externalAuthenticationURL string
+	authBanList               *authBanList
+	accessList                *accessList
 	allowOrigin               string
 	trustedProxies            conf.IPsOrCIDRs
 	iceServers                []string
@@ -80,6 +91,7 @@ type webRTCServer struct {
 	ctx               context.Context
 	ctxCancel         func()
 	ln                net.Listener
+	certLoader        *certLoader
 	requestPool       *httpRequestPool
 	httpServer        *http.Server
 	udpMuxLn          net.PacketConn
@@ -90,10 +102,11 @@ type webRTCServer struct {
 	iceTCPMux         ice.TCPMux
 
 	// in
-	connNew        chan webRTCConnNewReq
-	chConnClose    chan *webRTCConn
-	chAPIConnsList chan webRTCServerAPIConnsListReq
-	chAPIConnsKick chan webRTCServerAPIConnsKickReq
+	connNew          chan webRTCConnNewReq
+	chConnClose      chan *webRTCConn
+	chAPIConnsList   chan webRTCServerAPIConnsListReq
+	chAPIConnsKick   chan webRTCServerAPIConnsKickReq
+	chAPIConnsRevoke chan webRTCServerAPIConnsRevokeReq
 
 	// out
 	done chan struct{}
@@ -102,10 +115,15 @@ type webRTCServer struct {
 func newWebRTCServer(
 	parentCtx context.Context,
 	externalAuthenticationURL string,
+	authBanList *authBanList,
+	accessList *accessList,
 	address string,
 	encryption bool,
 	serverKey string,
 	serverCert string,
+	tlsMinVersion conf.TLSVersion,
+	tlsCipherSuites conf.TLSCipherSuites,
+	tlsLogHandshakes bool,
 	allowOrigin string,
 	trustedProxies conf.IPsOrCIDRs,
 	iceServers []string,
@@ -118,22 +136,20 @@ func newWebRTCServer(
 	iceUDPMuxAddress string,
 	iceTCPMuxAddress string,
 ) (*webRTCServer, error) {
-	ln, err := net.Listen(restrictNetwork("tcp", address))
+	ln, err := listenMultiple("tcp", address)
 	if err != nil {
 		return nil, err
 	}
 
 	var tlsConfig *tls.Config
+	var certLoader *certLoader
 	if encryption {
-		crt, err := tls.LoadX509KeyPair(serverCert, serverKey)
+		tlsConfig, certLoader, err = loadTLSConfig(serverCert, serverKey, nil,
+			tlsMinVersion, tlsCipherSuites, tlsLogHandshakes, parent)
 		if err != nil {
 			ln.Close()
 			return nil, err
 		}
-
-		tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{crt},
-		}
 	}
 
 	var iceUDPMux ice.UDPMux
@@ -160,6 +176,8 @@ func newWebRTCServer(
 
 	s := &webRTCServer{
 		externalAuthenticationURL: externalAuthenticationURL,
+		authBanList:               authBanList,
+		accessList:                accessList,
 		allowOrigin:               allowOrigin,
 		trustedProxies:            trustedProxies,
 		iceServers:                iceServers,
@@ -170,6 +188,7 @@ func newWebRTCServer(
 		ctx:                       ctx,
 		ctxCancel:                 ctxCancel,
 		ln:                        ln,
+		certLoader:                certLoader,
 		udpMuxLn:                  udpMuxLn,
 		tcpMuxLn:                  tcpMuxLn,
 		iceUDPMux:                 iceUDPMux,
@@ -180,6 +199,7 @@ func newWebRTCServer(
 		chConnClose:               make(chan *webRTCConn),
 		chAPIConnsList:            make(chan webRTCServerAPIConnsListReq),
 		chAPIConnsKick:            make(chan webRTCServerAPIConnsKickReq),
+		chAPIConnsRevoke:          make(chan webRTCServerAPIConnsRevokeReq),
 		done:                      make(chan struct{}),
 	}
 
@@ -224,6 +244,10 @@ func (s *webRTCServer) close() {
 	s.Log(logger.Info, "listener is closing")
 	s.ctxCancel()
 	<-s.done
+
+	if s.certLoader != nil {
+		s.certLoader.close()
+	}
 }
 
 func (s *webRTCServer) run() {
@@ -245,6 +269,8 @@ outer:
 				s.ctx,
 				s.readBufferCount,
 				req.pathName,
+				req.query,
+				req.identity,
 				req.wsconn,
 				s.iceServers,
 				&wg,
@@ -296,6 +322,18 @@ outer:
 				req.res <- webRTCServerAPIConnsKickRes{fmt.Errorf("not found")}
 			}
 
+		case req := <-s.chAPIConnsRevoke:
+			n := 0
+			for c := range s.conns {
+				if c.identity.matches(req.user, req.token) {
+					c.Log(logger.Info, "authorization has been revoked")
+					delete(s.conns, c)
+					c.close()
+					n++
+				}
+			}
+			req.res <- n
+
 		case <-s.ctx.Done():
 			break outer
 		}
@@ -335,6 +373,15 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		return
 	}
 
+	if s.accessList != nil {
+		err := s.accessList.checkAction(net.ParseIP(ctx.ClientIP()), false)
+		if err != nil {
+			s.Log(logger.Info, "[conn %v] %v", ctx.Request.RemoteAddr, err)
+			ctx.Writer.WriteHeader(http.StatusForbidden)
+			return
+		}
+	}
+
 	// remove leading prefix
 	pa := ctx.Request.URL.Path[1:]
 
@@ -360,16 +407,23 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 
 	res := s.pathManager.describe(pathDescribeReq{
 		pathName: dir,
+		query:    ctx.Request.URL.RawQuery,
+		protocol: "webrtc",
 	})
 	if res.err != nil {
 		ctx.Writer.WriteHeader(http.StatusNotFound)
 		return
 	}
 
-	err := s.authenticate(res.path, ctx)
+	err := s.authenticate(res.path, res.aliasConf, ctx)
 	if err != nil {
 		if terr, ok := err.(pathErrAuthCritical); ok {
 			s.Log(logger.Info, "authentication error: %s", terr.message)
+
+			if s.authBanList != nil {
+				s.authBanList.onFailure(net.ParseIP(ctx.ClientIP()))
+			}
+
 			ctx.Writer.Header().Set("WWW-Authenticate", `Basic realm="mediamtx"`)
 			ctx.Writer.WriteHeader(http.StatusUnauthorized)
 			return
@@ -394,7 +448,9 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 		}
 		defer wsconn.Close()
 
-		c := s.newConn(dir, wsconn)
+		user, _, _ := ctx.Request.BasicAuth()
+
+		c := s.newConn(dir, ctx.Request.URL.RawQuery, newAuthIdentity(user, ctx.Request.URL.RawQuery), wsconn)
 		if c == nil {
 			return
 		}
@@ -403,9 +459,16 @@ func (s *webRTCServer) onRequest(ctx *gin.Context) {
 	}
 }
 
-func (s *webRTCServer) newConn(dir string, wsconn *websocket.ServerConn) *webRTCConn {
+func (s *webRTCServer) newConn(
+	dir string,
+	query string,
+	identity authIdentity,
+	wsconn *websocket.ServerConn,
+) *webRTCConn {
 	req := webRTCConnNewReq{
 		pathName: dir,
+		query:    query,
+		identity: identity,
 		wsconn:   wsconn,
 		res:      make(chan *webRTCConn),
 	}
@@ -418,11 +481,26 @@ func (s *webRTCServer) newConn(dir string, wsconn *websocket.ServerConn) *webRTC
 	}
 }
 
-func (s *webRTCServer) authenticate(pa *path, ctx *gin.Context) error {
-	pathConf := pa.safeConf()
+func (s *webRTCServer) authenticate(pa *path, aliasConf *conf.PathConf, ctx *gin.Context) error {
+	// readers that use an alias are authenticated with the settings of the alias.
+	pathConf := aliasConf
+	if pathConf == nil {
+		pathConf = pa.safeConf()
+	}
+
 	pathIPs := pathConf.ReadIPs
 	pathUser := pathConf.ReadUser
 	pathPass := pathConf.ReadPass
+	pathPermissions := pathConf.Permissions
+
+	if s.authBanList != nil {
+		err := s.authBanList.check(net.ParseIP(ctx.ClientIP()))
+		if err != nil {
+			return pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
 
 	if s.externalAuthenticationURL != "" {
 		ip := net.ParseIP(ctx.ClientIP())
@@ -452,7 +530,7 @@ func (s *webRTCServer) authenticate(pa *path, ctx *gin.Context) error {
 	if pathIPs != nil {
 		ip := net.ParseIP(ctx.ClientIP())
 
-		if !ipEqualOrInRange(ip, pathIPs) {
+		if !auth.IPEqualOrInRange(ip, pathIPs) {
 			return pathErrAuthCritical{
 				message: fmt.Sprintf("IP '%s' not allowed", ip),
 			}
@@ -465,13 +543,36 @@ func (s *webRTCServer) authenticate(pa *path, ctx *gin.Context) error {
 			return pathErrAuthNotCritical{}
 		}
 
-		if user != string(pathUser) || pass != string(pathPass) {
+		if !auth.CredentialMatches(pathUser, user) || !auth.CredentialMatches(pathPass, pass) {
 			return pathErrAuthCritical{
 				message: "invalid credentials",
 			}
 		}
 	}
 
+	if len(pathPermissions) != 0 {
+		user, pass, ok := ctx.Request.BasicAuth()
+
+		err := auth.Check(pathPermissions, auth.Request{
+			IP:       net.ParseIP(ctx.ClientIP()),
+			Action:   auth.ActionRead,
+			Protocol: auth.ProtocolWebRTC,
+			CheckCredentials: func(pathUser conf.Credential, pathPass conf.Credential) bool {
+				return auth.CredentialMatches(pathUser, user) &&
+					auth.CredentialMatches(pathPass, pass)
+			},
+		})
+		if err != nil {
+			if err == auth.ErrInvalidCredentials && !ok {
+				return pathErrAuthNotCritical{}
+			}
+
+			return pathErrAuthCritical{
+				message: err.Error(),
+			}
+		}
+	}
+
 	return nil
 }
 
@@ -513,3 +614,20 @@ func (s *webRTCServer) apiConnsKick(id string) webRTCServerAPIConnsKickRes {
 		return webRTCServerAPIConnsKickRes{err: fmt.Errorf("terminated")}
 	}
 }
+
+// apiConnsRevoke is called by api.
+func (s *webRTCServer) apiConnsRevoke(user string, token string) int {
+	req := webRTCServerAPIConnsRevokeReq{
+		user:  user,
+		token: token,
+		res:   make(chan int),
+	}
+
+	select {
+	case s.chAPIConnsRevoke <- req:
+		return <-req.res
+
+	case <-s.ctx.Done():
+		return 0
+	}
+}
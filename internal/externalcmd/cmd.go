@@ -2,6 +2,7 @@
 package externalcmd
 
 import (
+	"io"
 	"strings"
 	"time"
 )
@@ -13,12 +14,42 @@ const (
 // Environment is a Cmd environment.
 type Environment map[string]string
 
+// Options contains optional parameters of a Cmd.
+type Options struct {
+	// writer that receives the standard output of the command,
+	// instead of the standard output of the server.
+	Stdout io.Writer
+
+	// name of the user that runs the command.
+	// It requires the server to run as root. It is not supported on Windows.
+	User string
+
+	// working directory of the command.
+	Dir string
+
+	// maximum CPU time of the command.
+	// It is applied by a shell before the command is started. It is supported on Linux only.
+	CPULimit time.Duration
+
+	// maximum size of the virtual memory of the command, in bytes.
+	// It is applied by a shell before the command is started. It is supported on Linux only.
+	MemoryLimit uint64
+
+	// time to wait after the command has been asked to exit, before killing it
+	// together with its child processes. Zero means forever.
+	KillTimeout time.Duration
+
+	// function that is called when the command can't be started.
+	OnError func(error)
+}
+
 // Cmd is an external command.
 type Cmd struct {
 	pool    *Pool
 	cmdstr  string
 	restart bool
 	env     Environment
+	opts    Options
 	onExit  func(int)
 
 	// in
@@ -32,6 +63,31 @@ func NewCmd(
 	restart bool,
 	env Environment,
 	onExit func(int),
+) *Cmd {
+	return NewCmdWithOptions(pool, cmdstr, restart, env, Options{}, onExit)
+}
+
+// NewCmdWithStdout allocates a Cmd whose standard output is written into stdout,
+// instead of the standard output of the server.
+func NewCmdWithStdout(
+	pool *Pool,
+	cmdstr string,
+	restart bool,
+	env Environment,
+	stdout io.Writer,
+	onExit func(int),
+) *Cmd {
+	return NewCmdWithOptions(pool, cmdstr, restart, env, Options{Stdout: stdout}, onExit)
+}
+
+// NewCmdWithOptions allocates a Cmd with the given options.
+func NewCmdWithOptions(
+	pool *Pool,
+	cmdstr string,
+	restart bool,
+	env Environment,
+	opts Options,
+	onExit func(int),
 ) *Cmd {
 	for key, val := range env {
 		cmdstr = strings.ReplaceAll(cmdstr, "$"+key, val)
@@ -42,6 +98,7 @@ func NewCmd(
 		cmdstr:    cmdstr,
 		restart:   restart,
 		env:       env,
+		opts:      opts,
 		onExit:    onExit,
 		terminate: make(chan struct{}),
 	}
@@ -53,6 +110,12 @@ func NewCmd(
 	return e
 }
 
+func (e *Cmd) error(err error) {
+	if e.opts.OnError != nil {
+		e.opts.OnError(err)
+	}
+}
+
 // Close closes the command. It doesn't wait for the command to exit.
 func (e *Cmd) Close() {
 	close(e.terminate)
@@ -4,19 +4,45 @@
 package externalcmd
 
 import (
+	"fmt"
 	"os"
 	"os/exec"
+	"os/user"
+	"strconv"
 	"syscall"
+	"time"
 
 	"github.com/kballard/go-shellquote"
 )
 
+func credential(name string) (*syscall.Credential, error) {
+	u, err := user.Lookup(name)
+	if err != nil {
+		return nil, err
+	}
+
+	uid, err := strconv.ParseUint(u.Uid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	gid, err := strconv.ParseUint(u.Gid, 10, 32)
+	if err != nil {
+		return nil, err
+	}
+
+	return &syscall.Credential{Uid: uint32(uid), Gid: uint32(gid)}, nil
+}
+
 func (e *Cmd) runInner() (int, bool) {
 	cmdparts, err := shellquote.Split(e.cmdstr)
 	if err != nil {
+		e.error(err)
 		return 0, true
 	}
 
+	cmdparts = limitsWrap(cmdparts, e.opts)
+
 	cmd := exec.Command(cmdparts[0], cmdparts[1:]...)
 
 	cmd.Env = append([]string(nil), os.Environ()...)
@@ -24,11 +50,31 @@ func (e *Cmd) runInner() (int, bool) {
 		cmd.Env = append(cmd.Env, key+"="+val)
 	}
 
-	cmd.Stdout = os.Stdout
+	if e.opts.Stdout != nil {
+		cmd.Stdout = e.opts.Stdout
+	} else {
+		cmd.Stdout = os.Stdout
+	}
 	cmd.Stderr = os.Stderr
 
+	cmd.Dir = e.opts.Dir
+
+	// run the command in a dedicated process group,
+	// in order to be able to stop its child processes too.
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+
+	if e.opts.User != "" {
+		cred, err := credential(e.opts.User)
+		if err != nil {
+			e.error(fmt.Errorf("unable to find user '%s': %w", e.opts.User, err))
+			return 0, true
+		}
+		cmd.SysProcAttr.Credential = cred
+	}
+
 	err = cmd.Start()
 	if err != nil {
+		e.error(err)
 		return 0, true
 	}
 
@@ -49,8 +95,20 @@ func (e *Cmd) runInner() (int, bool) {
 
 	select {
 	case <-e.terminate:
-		syscall.Kill(cmd.Process.Pid, syscall.SIGINT)
-		<-cmdDone
+		// a negative PID sends the signal to the whole process group.
+		syscall.Kill(-cmd.Process.Pid, syscall.SIGINT)
+
+		if e.opts.KillTimeout != 0 {
+			select {
+			case <-cmdDone:
+			case <-time.After(e.opts.KillTimeout):
+				syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+				<-cmdDone
+			}
+		} else {
+			<-cmdDone
+		}
+
 		return 0, false
 
 	case c := <-cmdDone:
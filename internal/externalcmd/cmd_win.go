@@ -13,6 +13,7 @@ import (
 func (e *Cmd) runInner() (int, bool) {
 	cmdparts, err := shellquote.Split(e.cmdstr)
 	if err != nil {
+		e.error(err)
 		return 0, true
 	}
 
@@ -23,11 +24,18 @@ func (e *Cmd) runInner() (int, bool) {
 		cmd.Env = append(cmd.Env, key+"="+val)
 	}
 
-	cmd.Stdout = os.Stdout
+	if e.opts.Stdout != nil {
+		cmd.Stdout = e.opts.Stdout
+	} else {
+		cmd.Stdout = os.Stdout
+	}
 	cmd.Stderr = os.Stderr
 
+	cmd.Dir = e.opts.Dir
+
 	err = cmd.Start()
 	if err != nil {
+		e.error(err)
 		return 0, true
 	}
 
@@ -0,0 +1,36 @@
+package externalcmd
+
+import (
+	"strconv"
+	"strings"
+)
+
+// limitsWrap wraps a command into a shell that sets resource limits and then
+// replaces itself with the command, in order to apply limits before the command starts.
+func limitsWrap(cmdparts []string, opts Options) []string {
+	var script []string
+
+	if opts.CPULimit != 0 {
+		secs := uint64(opts.CPULimit.Seconds())
+		if secs == 0 {
+			secs = 1
+		}
+		script = append(script, "ulimit -t "+strconv.FormatUint(secs, 10))
+	}
+
+	if opts.MemoryLimit != 0 {
+		kib := opts.MemoryLimit / 1024
+		if kib == 0 {
+			kib = 1
+		}
+		script = append(script, "ulimit -v "+strconv.FormatUint(kib, 10))
+	}
+
+	if script == nil {
+		return cmdparts
+	}
+
+	script = append(script, `exec "$@"`)
+
+	return append([]string{"/bin/sh", "-c", strings.Join(script, " && "), "sh"}, cmdparts...)
+}
@@ -0,0 +1,8 @@
+//go:build !linux && !windows
+// +build !linux,!windows
+
+package externalcmd
+
+func limitsWrap(cmdparts []string, _ Options) []string {
+	return cmdparts
+}
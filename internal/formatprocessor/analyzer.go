@@ -0,0 +1,376 @@
+package formatprocessor
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
+	"github.com/pion/rtp"
+)
+
+const (
+	// non-key frames smaller than this are considered part of a static or black video.
+	staticVideoMaxFrameSize = 256
+
+	// audio samples with an amplitude lower than this (-50 dBFS) are considered silent.
+	silentAudioMaxAmplitude = 104
+
+	// Opus packets smaller than this are considered silent (DTX or comfort noise).
+	silentOpusMaxPacketSize = 4
+)
+
+// AnalyzerAlert is an alert emitted by an Analyzer.
+type AnalyzerAlert string
+
+// alerts.
+const (
+	AnalyzerAlertStaticVideo    AnalyzerAlert = "staticVideo"
+	AnalyzerAlertSilentAudio    AnalyzerAlert = "silentAudio"
+	AnalyzerAlertMaxBitrate     AnalyzerAlert = "maxBitrate"
+	AnalyzerAlertMaxGOPDuration AnalyzerAlert = "maxGOPDuration"
+)
+
+// Analyzer contains the parameters of the detection of video tracks that are static or black,
+// of audio tracks that are silent and of tracks that exceed limits.
+// Detection is performed without decoding: H264 and H265 tracks are considered static
+// when all non-key frames are very small, G711 tracks are considered silent when
+// the amplitude of all samples is very low, Opus tracks are considered silent when
+// all packets are very small. Other formats are not analyzed.
+type Analyzer struct {
+	// minimum duration of a static video before an alert is emitted. Zero disables detection.
+	StaticVideoTimeout time.Duration
+
+	// minimum duration of a silent audio before an alert is emitted. Zero disables detection.
+	SilentAudioTimeout time.Duration
+
+	// maximum bitrate of a track, in bits per second. Zero disables the check.
+	MaxBitrate int
+
+	// maximum interval between key frames of H264 and H265 tracks. Zero disables the check.
+	MaxGOPDuration time.Duration
+
+	// called when an alert of a track starts or ends.
+	// It may be called with active=false even if the alert wasn't started.
+	OnChange func(forma formats.Format, alert AnalyzerAlert, active bool)
+}
+
+type trackAnalyzerState int
+
+const (
+	trackAnalyzerStateUnknown trackAnalyzerState = iota
+	trackAnalyzerStateOK
+	trackAnalyzerStateAlert
+)
+
+// set changes the state of an alert, calling OnChange when the state changes.
+func (s *trackAnalyzerState) set(conf *Analyzer, forma formats.Format, alert AnalyzerAlert, active bool) {
+	if active {
+		if *s != trackAnalyzerStateAlert {
+			*s = trackAnalyzerStateAlert
+			conf.OnChange(forma, alert, true)
+		}
+		return
+	}
+
+	if *s != trackAnalyzerStateOK {
+		*s = trackAnalyzerStateOK
+		conf.OnChange(forma, alert, false)
+	}
+}
+
+// unitAnalyzer inspects the units of a track.
+type unitAnalyzer interface {
+	process(Unit)
+}
+
+func newUnitAnalyzers(conf *Analyzer, forma formats.Format) []unitAnalyzer {
+	var ret []unitAnalyzer
+
+	if a := newTrackAnalyzer(conf, forma); a != nil {
+		ret = append(ret, a)
+	}
+
+	if a := newBitrateAnalyzer(conf, forma); a != nil {
+		ret = append(ret, a)
+	}
+
+	if a := newGOPAnalyzer(conf, forma); a != nil {
+		ret = append(ret, a)
+	}
+
+	return ret
+}
+
+type trackAnalyzer struct {
+	conf     *Analyzer
+	forma    formats.Format
+	alert    AnalyzerAlert
+	timeout  time.Duration
+	isActive func(*rtp.Packet) bool // audio only
+
+	state        trackAnalyzerState
+	lastActivity time.Time
+
+	// frame that is being received (video only)
+	frameStarted bool
+	frameTS      uint32
+	frameSize    int
+	frameIsKey   bool
+}
+
+func newTrackAnalyzer(conf *Analyzer, forma formats.Format) *trackAnalyzer {
+	a := &trackAnalyzer{
+		conf:  conf,
+		forma: forma,
+	}
+
+	switch forma := forma.(type) {
+	case *formats.H264, *formats.H265:
+		a.alert = AnalyzerAlertStaticVideo
+		a.timeout = conf.StaticVideoTimeout
+
+	case *formats.G711:
+		a.alert = AnalyzerAlertSilentAudio
+		a.timeout = conf.SilentAudioTimeout
+		mulaw := forma.MULaw
+		a.isActive = func(pkt *rtp.Packet) bool {
+			return g711MaxAmplitude(pkt.Payload, mulaw) >= silentAudioMaxAmplitude
+		}
+
+	case *formats.Opus:
+		a.alert = AnalyzerAlertSilentAudio
+		a.timeout = conf.SilentAudioTimeout
+		a.isActive = func(pkt *rtp.Packet) bool {
+			return len(pkt.Payload) >= silentOpusMaxPacketSize
+		}
+	}
+
+	if a.timeout == 0 {
+		return nil
+	}
+
+	return a
+}
+
+func (a *trackAnalyzer) process(u Unit) {
+	now := u.GetNTP()
+
+	if a.lastActivity.IsZero() {
+		a.lastActivity = now
+	}
+
+	for _, pkt := range u.GetRTPPackets() {
+		if a.isActive != nil {
+			a.update(now, a.isActive(pkt))
+			continue
+		}
+
+		if a.frameStarted && pkt.Timestamp != a.frameTS {
+			a.frameEnd(now)
+		}
+
+		if !a.frameStarted {
+			a.frameStarted = true
+			a.frameTS = pkt.Timestamp
+			a.frameSize = 0
+			a.frameIsKey = false
+		}
+
+		var size int
+		var isKey bool
+		if _, ok := a.forma.(*formats.H264); ok {
+			size, isKey = rtpH264SliceSize(pkt)
+		} else {
+			size, isKey = rtpH265SliceSize(pkt)
+		}
+		a.frameSize += size
+		a.frameIsKey = a.frameIsKey || isKey
+
+		if pkt.Marker {
+			a.frameEnd(now)
+		}
+	}
+}
+
+func (a *trackAnalyzer) frameEnd(now time.Time) {
+	a.frameStarted = false
+
+	// key frames of a static video are not small, therefore they are ignored.
+	if a.frameIsKey {
+		return
+	}
+
+	a.update(now, a.frameSize >= staticVideoMaxFrameSize)
+}
+
+func (a *trackAnalyzer) update(now time.Time, active bool) {
+	if active {
+		a.lastActivity = now
+		a.state.set(a.conf, a.forma, a.alert, false)
+		return
+	}
+
+	if now.Sub(a.lastActivity) >= a.timeout {
+		a.state.set(a.conf, a.forma, a.alert, true)
+	}
+}
+
+// rtpH264SliceSize returns the size of the slices contained in a RTP packet,
+// and whether they belong to a IDR frame.
+func rtpH264SliceSize(pkt *rtp.Packet) (int, bool) {
+	if len(pkt.Payload) < 1 {
+		return 0, false
+	}
+
+	isSlice := func(typ h264.NALUType) bool {
+		return typ >= h264.NALUTypeNonIDR && typ <= h264.NALUTypeIDR
+	}
+
+	typ := h264.NALUType(pkt.Payload[0] & 0x1F)
+
+	switch typ {
+	case h264.NALUTypeSTAPA:
+		payload := pkt.Payload[1:]
+		size := 0
+		isKey := false
+
+		for len(payload) >= 2 {
+			l := int(uint16(payload[0])<<8 | uint16(payload[1]))
+			payload = payload[2:]
+
+			if l == 0 || l > len(payload) {
+				break
+			}
+
+			typ := h264.NALUType(payload[0] & 0x1F)
+			if isSlice(typ) {
+				size += l
+				isKey = isKey || typ == h264.NALUTypeIDR
+			}
+
+			payload = payload[l:]
+		}
+
+		return size, isKey
+
+	case h264.NALUTypeFUA:
+		if len(pkt.Payload) < 2 {
+			return 0, false
+		}
+
+		typ := h264.NALUType(pkt.Payload[1] & 0x1F)
+		if !isSlice(typ) {
+			return 0, false
+		}
+
+		return len(pkt.Payload) - 2, typ == h264.NALUTypeIDR
+
+	default:
+		if !isSlice(typ) {
+			return 0, false
+		}
+
+		return len(pkt.Payload), typ == h264.NALUTypeIDR
+	}
+}
+
+// rtpH265SliceSize returns the size of the slices contained in a RTP packet,
+// and whether they belong to a IRAP frame.
+func rtpH265SliceSize(pkt *rtp.Packet) (int, bool) {
+	if len(pkt.Payload) < 2 {
+		return 0, false
+	}
+
+	isSlice := func(typ h265.NALUType) bool {
+		return typ < 32
+	}
+
+	isKey := func(typ h265.NALUType) bool {
+		return typ >= h265.NALUType_BLA_W_LP && typ <= h265.NALUType_RSV_IRAP_VCL23
+	}
+
+	typ := h265.NALUType((pkt.Payload[0] >> 1) & 0b111111)
+
+	switch typ {
+	case h265.NALUType_AggregationUnit:
+		payload := pkt.Payload[2:]
+		size := 0
+		key := false
+
+		for len(payload) >= 2 {
+			l := int(uint16(payload[0])<<8 | uint16(payload[1]))
+			payload = payload[2:]
+
+			if l == 0 || l > len(payload) {
+				break
+			}
+
+			typ := h265.NALUType((payload[0] >> 1) & 0b111111)
+			if isSlice(typ) {
+				size += l
+				key = key || isKey(typ)
+			}
+
+			payload = payload[l:]
+		}
+
+		return size, key
+
+	case h265.NALUType_FragmentationUnit:
+		if len(pkt.Payload) < 3 {
+			return 0, false
+		}
+
+		typ := h265.NALUType(pkt.Payload[2] & 0b111111)
+		if !isSlice(typ) {
+			return 0, false
+		}
+
+		return len(pkt.Payload) - 3, isKey(typ)
+
+	default:
+		if !isSlice(typ) {
+			return 0, false
+		}
+
+		return len(pkt.Payload), isKey(typ)
+	}
+}
+
+// g711MaxAmplitude returns the maximum amplitude of G711 samples, in the 16-bit range.
+func g711MaxAmplitude(samples []byte, mulaw bool) int {
+	ret := 0
+
+	for _, b := range samples {
+		v := G711Decode(b, mulaw)
+		if v < 0 {
+			v = -v
+		}
+
+		if v > ret {
+			ret = v
+		}
+	}
+
+	return ret
+}
+
+// analyzedProcessor is a Processor whose output is inspected by analyzers.
+type analyzedProcessor struct {
+	Processor
+	analyzers []unitAnalyzer
+}
+
+// Process implements Processor.
+func (p *analyzedProcessor) Process(u Unit, hasNonRTSPReaders bool) error {
+	err := p.Processor.Process(u, hasNonRTSPReaders)
+	if err != nil {
+		return err
+	}
+
+	for _, a := range p.analyzers {
+		a.process(u)
+	}
+	return nil
+}
@@ -0,0 +1,123 @@
+package formatprocessor
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+)
+
+// interval in which the bitrate is measured.
+const bitrateAnalyzerPeriod = 1 * time.Second
+
+// bitrateAnalyzer emits an alert when the bitrate of a track exceeds a maximum.
+type bitrateAnalyzer struct {
+	conf  *Analyzer
+	forma formats.Format
+
+	state       trackAnalyzerState
+	periodStart time.Time
+	periodSize  int
+}
+
+func newBitrateAnalyzer(conf *Analyzer, forma formats.Format) *bitrateAnalyzer {
+	if conf.MaxBitrate == 0 {
+		return nil
+	}
+
+	return &bitrateAnalyzer{
+		conf:  conf,
+		forma: forma,
+	}
+}
+
+func (a *bitrateAnalyzer) process(u Unit) {
+	now := u.GetNTP()
+
+	if a.periodStart.IsZero() {
+		a.periodStart = now
+	}
+
+	for _, pkt := range u.GetRTPPackets() {
+		a.periodSize += len(pkt.Payload)
+	}
+
+	elapsed := now.Sub(a.periodStart)
+	if elapsed < bitrateAnalyzerPeriod {
+		return
+	}
+
+	bitrate := float64(a.periodSize*8) / elapsed.Seconds()
+	a.periodStart = now
+	a.periodSize = 0
+
+	a.state.set(a.conf, a.forma, AnalyzerAlertMaxBitrate, bitrate > float64(a.conf.MaxBitrate))
+}
+
+// gopAnalyzer emits an alert when the interval between key frames of a track exceeds a maximum.
+type gopAnalyzer struct {
+	conf  *Analyzer
+	forma formats.Format
+	isKey func(*rtp.Packet) bool
+
+	state        trackAnalyzerState
+	lastKeyFrame time.Time
+	keyReceived  bool
+	lastKeyTS    uint32
+}
+
+func newGOPAnalyzer(conf *Analyzer, forma formats.Format) *gopAnalyzer {
+	if conf.MaxGOPDuration == 0 {
+		return nil
+	}
+
+	var isKey func(*rtp.Packet) bool
+
+	switch forma.(type) {
+	case *formats.H264:
+		isKey = func(pkt *rtp.Packet) bool {
+			_, key := rtpH264SliceSize(pkt)
+			return key
+		}
+
+	case *formats.H265:
+		isKey = func(pkt *rtp.Packet) bool {
+			_, key := rtpH265SliceSize(pkt)
+			return key
+		}
+
+	default:
+		return nil
+	}
+
+	return &gopAnalyzer{
+		conf:  conf,
+		forma: forma,
+		isKey: isKey,
+	}
+}
+
+func (a *gopAnalyzer) process(u Unit) {
+	now := u.GetNTP()
+
+	// a stream that doesn't contain key frames at all exceeds the limit too.
+	if a.lastKeyFrame.IsZero() {
+		a.lastKeyFrame = now
+	}
+
+	for _, pkt := range u.GetRTPPackets() {
+		// all packets of a key frame share the same timestamp.
+		if !a.isKey(pkt) || (a.keyReceived && pkt.Timestamp == a.lastKeyTS) {
+			continue
+		}
+
+		a.state.set(a.conf, a.forma, AnalyzerAlertMaxGOPDuration, now.Sub(a.lastKeyFrame) > a.conf.MaxGOPDuration)
+		a.lastKeyFrame = now
+		a.keyReceived = true
+		a.lastKeyTS = pkt.Timestamp
+	}
+
+	if now.Sub(a.lastKeyFrame) > a.conf.MaxGOPDuration {
+		a.state.set(a.conf, a.forma, AnalyzerAlertMaxGOPDuration, true)
+	}
+}
@@ -0,0 +1,248 @@
+package formatprocessor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+type analyzerEvent struct {
+	alert  AnalyzerAlert
+	active bool
+}
+
+func TestAnalyzerStaticVideo(t *testing.T) {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+	}
+
+	var events []analyzerEvent
+
+	p, err := New(1472, forma, false, nil, &Analyzer{
+		StaticVideoTimeout: 2 * time.Second,
+		OnChange: func(f formats.Format, alert AnalyzerAlert, active bool) {
+			require.Equal(t, forma, f)
+			events = append(events, analyzerEvent{alert, active})
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+	seq := uint16(0)
+
+	writeFrame := func(i int, nalu []byte) {
+		err := p.Process(&UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: seq,
+					Timestamp:      uint32(i * 9000),
+				},
+				Payload: nalu,
+			}},
+			NTP: start.Add(time.Duration(i) * 100 * time.Millisecond),
+		}, false)
+		require.NoError(t, err)
+		seq++
+	}
+
+	bigIDR := append([]byte{0x65}, bytes.Repeat([]byte{1}, 1000)...)
+	bigNonIDR := append([]byte{0x41}, bytes.Repeat([]byte{1}, 1000)...)
+	smallNonIDR := []byte{0x41, 1, 2, 3}
+
+	writeFrame(0, bigIDR)
+	writeFrame(1, bigNonIDR)
+	require.Equal(t, []analyzerEvent{{AnalyzerAlertStaticVideo, false}}, events)
+
+	// small non-key frames and big key frames
+	for i := 2; i < 30; i++ {
+		if i%10 == 0 {
+			writeFrame(i, bigIDR)
+		} else {
+			writeFrame(i, smallNonIDR)
+		}
+	}
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertStaticVideo, false},
+		{AnalyzerAlertStaticVideo, true},
+	}, events)
+
+	writeFrame(30, bigNonIDR)
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertStaticVideo, false},
+		{AnalyzerAlertStaticVideo, true},
+		{AnalyzerAlertStaticVideo, false},
+	}, events)
+}
+
+func TestAnalyzerSilentAudio(t *testing.T) {
+	forma := &formats.G711{
+		MULaw: true,
+	}
+
+	var events []analyzerEvent
+
+	p, err := New(1472, forma, false, nil, &Analyzer{
+		SilentAudioTimeout: 1 * time.Second,
+		OnChange: func(f formats.Format, alert AnalyzerAlert, active bool) {
+			events = append(events, analyzerEvent{alert, active})
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writePacket := func(i int, sample byte) {
+		err := p.Process(&UnitG711{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 160),
+				},
+				Payload: bytes.Repeat([]byte{sample}, 160),
+			}},
+			NTP: start.Add(time.Duration(i) * 20 * time.Millisecond),
+		}, false)
+		require.NoError(t, err)
+	}
+
+	for i := 0; i < 100; i++ {
+		writePacket(i, 0xFF) // silence
+	}
+	require.Equal(t, []analyzerEvent{{AnalyzerAlertSilentAudio, true}}, events)
+
+	writePacket(100, 0x80) // maximum amplitude
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertSilentAudio, true},
+		{AnalyzerAlertSilentAudio, false},
+	}, events)
+}
+
+func TestG711MaxAmplitude(t *testing.T) {
+	require.Equal(t, 0, g711MaxAmplitude([]byte{0xFF, 0x7F}, true))
+	require.Equal(t, 32124, g711MaxAmplitude([]byte{0xFF, 0x80}, true))
+	require.Equal(t, 8, g711MaxAmplitude([]byte{0xD5, 0x55}, false))
+	require.Equal(t, 32256, g711MaxAmplitude([]byte{0xD5, 0xAA}, false))
+}
+
+func TestAnalyzerMaxBitrate(t *testing.T) {
+	forma := &formats.G711{
+		MULaw: true,
+	}
+
+	var events []analyzerEvent
+
+	p, err := New(1472, forma, false, nil, &Analyzer{
+		MaxBitrate: 100000,
+		OnChange: func(f formats.Format, alert AnalyzerAlert, active bool) {
+			events = append(events, analyzerEvent{alert, active})
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writePacket := func(i int, size int) {
+		err := p.Process(&UnitG711{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 160),
+				},
+				Payload: bytes.Repeat([]byte{0x80}, size),
+			}},
+			NTP: start.Add(time.Duration(i) * 20 * time.Millisecond),
+		}, false)
+		require.NoError(t, err)
+	}
+
+	// 64 kbit/s
+	for i := 0; i <= 50; i++ {
+		writePacket(i, 160)
+	}
+	require.Equal(t, []analyzerEvent{{AnalyzerAlertMaxBitrate, false}}, events)
+
+	// 160 kbit/s
+	for i := 51; i <= 100; i++ {
+		writePacket(i, 400)
+	}
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertMaxBitrate, false},
+		{AnalyzerAlertMaxBitrate, true},
+	}, events)
+}
+
+func TestAnalyzerMaxGOPDuration(t *testing.T) {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		PacketizationMode: 1,
+	}
+
+	var events []analyzerEvent
+
+	p, err := New(1472, forma, false, nil, &Analyzer{
+		MaxGOPDuration: 2 * time.Second,
+		OnChange: func(f formats.Format, alert AnalyzerAlert, active bool) {
+			events = append(events, analyzerEvent{alert, active})
+		},
+	}, nil)
+	require.NoError(t, err)
+
+	start := time.Date(2010, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	writeFrame := func(i int, isKey bool) {
+		nalu := []byte{0x41, 1, 2, 3}
+		if isKey {
+			nalu = []byte{0x65, 1, 2, 3}
+		}
+
+		err := p.Process(&UnitH264{
+			RTPPackets: []*rtp.Packet{{
+				Header: rtp.Header{
+					Version:        2,
+					Marker:         true,
+					PayloadType:    96,
+					SequenceNumber: uint16(i),
+					Timestamp:      uint32(i * 9000),
+				},
+				Payload: nalu,
+			}},
+			NTP: start.Add(time.Duration(i) * 100 * time.Millisecond),
+		}, false)
+		require.NoError(t, err)
+	}
+
+	// GOPs of 1 second
+	for i := 0; i <= 20; i++ {
+		writeFrame(i, i%10 == 0)
+	}
+	require.Equal(t, []analyzerEvent{{AnalyzerAlertMaxGOPDuration, false}}, events)
+
+	// no key frames for more than 2 seconds
+	for i := 21; i <= 50; i++ {
+		writeFrame(i, false)
+	}
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertMaxGOPDuration, false},
+		{AnalyzerAlertMaxGOPDuration, true},
+	}, events)
+
+	writeFrame(51, true)
+	writeFrame(61, true)
+	require.Equal(t, []analyzerEvent{
+		{AnalyzerAlertMaxGOPDuration, false},
+		{AnalyzerAlertMaxGOPDuration, true},
+		{AnalyzerAlertMaxGOPDuration, false},
+	}, events)
+}
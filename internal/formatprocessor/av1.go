@@ -55,6 +55,7 @@ func newAV1(
 	if generateRTPPackets {
 		t.encoder = &rtpav1.Encoder{
 			PayloadMaxSize: t.udpMaxPayloadSize - 12,
+			PayloadType:    forma.PayloadTyp,
 		}
 		t.encoder.Init()
 		t.lastKeyFrameReceived = time.Now()
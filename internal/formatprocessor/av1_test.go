@@ -0,0 +1,33 @@
+package formatprocessor
+
+import (
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAV1Encode(t *testing.T) {
+	forma := &formats.AV1{
+		PayloadTyp: 98,
+	}
+
+	p, err := New(1472, forma, true, nil, nil, nil)
+	require.NoError(t, err)
+
+	unit := &UnitAV1{
+		OBUs: [][]byte{
+			{0x0a, 0x0b, 0x00, 0x00, 0x00, 0x24, 0xcf, 0x7f, 0x0d, 0xbf, 0xff, 0x30, 0x08},
+		},
+	}
+
+	err = p.Process(unit, false)
+	require.NoError(t, err)
+	require.Equal(t, 1, len(unit.RTPPackets))
+	require.Equal(t, uint8(98), unit.RTPPackets[0].PayloadType)
+
+	dec := forma.CreateDecoder()
+	obus, _, err := dec.DecodeUntilMarker(unit.RTPPackets[0])
+	require.NoError(t, err)
+	require.Equal(t, unit.OBUs, obus)
+}
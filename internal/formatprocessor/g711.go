@@ -0,0 +1,81 @@
+package formatprocessor
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// UnitG711 is a G711 data unit.
+type UnitG711 struct {
+	RTPPackets []*rtp.Packet
+	NTP        time.Time
+	PTS        time.Duration
+	Samples    []byte
+}
+
+// GetRTPPackets implements Unit.
+func (d *UnitG711) GetRTPPackets() []*rtp.Packet {
+	return d.RTPPackets
+}
+
+// GetNTP implements Unit.
+func (d *UnitG711) GetNTP() time.Time {
+	return d.NTP
+}
+
+// G711Decode converts a G711 sample into a linear sample, in the 16-bit range.
+func G711Decode(b byte, mulaw bool) int {
+	if mulaw {
+		b = ^b
+		exponent := (b >> 4) & 0x07
+		mantissa := int(b & 0x0F)
+		v := (((mantissa << 3) + 0x84) << exponent) - 0x84
+		if (b & 0x80) != 0 {
+			return -v
+		}
+		return v
+	}
+
+	b ^= 0x55
+	exponent := (b >> 4) & 0x07
+	mantissa := int(b & 0x0F)
+	var v int
+	if exponent == 0 {
+		v = (mantissa << 4) + 8
+	} else {
+		v = ((mantissa << 4) + 0x108) << (exponent - 1)
+	}
+	if (b & 0x80) == 0 {
+		return -v
+	}
+	return v
+}
+
+type formatProcessorG711 struct {
+	*formatProcessorSimpleAudio
+}
+
+func newG711(
+	udpMaxPayloadSize int,
+	forma *formats.G711,
+	generateRTPPackets bool,
+	log logger.Writer,
+) (*formatProcessorG711, error) {
+	return &formatProcessorG711{
+		formatProcessorSimpleAudio: newSimpleAudio(
+			udpMaxPayloadSize,
+			forma.PayloadType(),
+			8000,
+			forma.CreateDecoder,
+			generateRTPPackets),
+	}, nil
+}
+
+func (t *formatProcessorG711) Process(unit Unit, hasNonRTSPReaders bool) error {
+	tunit := unit.(*UnitG711)
+	return t.process(&tunit.RTPPackets, &tunit.PTS, &tunit.Samples, hasNonRTSPReaders)
+}
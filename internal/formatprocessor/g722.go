@@ -0,0 +1,53 @@
+package formatprocessor
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+// UnitG722 is a G722 data unit.
+type UnitG722 struct {
+	RTPPackets []*rtp.Packet
+	NTP        time.Time
+	PTS        time.Duration
+	Samples    []byte
+}
+
+// GetRTPPackets implements Unit.
+func (d *UnitG722) GetRTPPackets() []*rtp.Packet {
+	return d.RTPPackets
+}
+
+// GetNTP implements Unit.
+func (d *UnitG722) GetNTP() time.Time {
+	return d.NTP
+}
+
+type formatProcessorG722 struct {
+	*formatProcessorSimpleAudio
+}
+
+func newG722(
+	udpMaxPayloadSize int,
+	forma *formats.G722,
+	generateRTPPackets bool,
+	log logger.Writer,
+) (*formatProcessorG722, error) {
+	return &formatProcessorG722{
+		formatProcessorSimpleAudio: newSimpleAudio(
+			udpMaxPayloadSize,
+			forma.PayloadType(),
+			8000,
+			forma.CreateDecoder,
+			generateRTPPackets),
+	}, nil
+}
+
+func (t *formatProcessorG722) Process(unit Unit, hasNonRTSPReaders bool) error {
+	tunit := unit.(*UnitG722)
+	return t.process(&tunit.RTPPackets, &tunit.PTS, &tunit.Samples, hasNonRTSPReaders)
+}
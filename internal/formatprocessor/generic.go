@@ -1,6 +1,7 @@
 package formatprocessor
 
 import (
+	"crypto/rand"
 	"fmt"
 	"time"
 
@@ -14,6 +15,10 @@ import (
 type UnitGeneric struct {
 	RTPPackets []*rtp.Packet
 	NTP        time.Time
+
+	// filled when RTP packets are generated by the server.
+	PTS     time.Duration
+	Payload []byte
 }
 
 // GetRTPPackets implements Unit.
@@ -26,8 +31,71 @@ func (d *UnitGeneric) GetNTP() time.Time {
 	return d.NTP
 }
 
+func randUint32() uint32 {
+	var b [4]byte
+	rand.Read(b[:])
+	return uint32(b[0])<<24 | uint32(b[1])<<16 | uint32(b[2])<<8 | uint32(b[3])
+}
+
+// genericEncoder splits payloads into RTP packets.
+// The marker flag is set on the last packet of each payload.
+type genericEncoder struct {
+	payloadType    uint8
+	clockRate      int
+	payloadMaxSize int
+
+	ssrc           uint32
+	sequenceNumber uint16
+	initialTS      uint32
+}
+
+func newGenericEncoder(payloadType uint8, clockRate int, payloadMaxSize int) *genericEncoder {
+	return &genericEncoder{
+		payloadType:    payloadType,
+		clockRate:      clockRate,
+		payloadMaxSize: payloadMaxSize,
+		ssrc:           randUint32(),
+		sequenceNumber: uint16(randUint32()),
+		initialTS:      randUint32(),
+	}
+}
+
+func (e *genericEncoder) encode(payload []byte, pts time.Duration) []*rtp.Packet {
+	ts := e.initialTS + uint32(pts.Seconds()*float64(e.clockRate))
+
+	n := (len(payload) + e.payloadMaxSize - 1) / e.payloadMaxSize
+	if n == 0 {
+		n = 1
+	}
+
+	pkts := make([]*rtp.Packet, n)
+
+	for i := range pkts {
+		end := (i + 1) * e.payloadMaxSize
+		if end > len(payload) {
+			end = len(payload)
+		}
+
+		pkts[i] = &rtp.Packet{
+			Header: rtp.Header{
+				Version:        2,
+				PayloadType:    e.payloadType,
+				SequenceNumber: e.sequenceNumber,
+				Timestamp:      ts,
+				SSRC:           e.ssrc,
+				Marker:         i == (n - 1),
+			},
+			Payload: payload[i*e.payloadMaxSize : end],
+		}
+		e.sequenceNumber++
+	}
+
+	return pkts
+}
+
 type formatProcessorGeneric struct {
 	udpMaxPayloadSize int
+	encoder           *genericEncoder
 }
 
 func newGeneric(
@@ -36,18 +104,30 @@ func newGeneric(
 	generateRTPPackets bool,
 	log logger.Writer,
 ) (*formatProcessorGeneric, error) {
+	t := &formatProcessorGeneric{
+		udpMaxPayloadSize: udpMaxPayloadSize,
+	}
+
 	if generateRTPPackets {
-		return nil, fmt.Errorf("we don't know how to generate RTP packets of format %+v", forma)
+		if forma.ClockRate() == 0 {
+			return nil, fmt.Errorf("we don't know how to generate RTP packets of format %+v", forma)
+		}
+
+		t.encoder = newGenericEncoder(forma.PayloadType(), forma.ClockRate(), udpMaxPayloadSize-12)
 	}
 
-	return &formatProcessorGeneric{
-		udpMaxPayloadSize: udpMaxPayloadSize,
-	}, nil
+	return t, nil
 }
 
 func (t *formatProcessorGeneric) Process(unit Unit, hasNonRTSPReaders bool) error {
 	tunit := unit.(*UnitGeneric)
 
+	if tunit.RTPPackets == nil {
+		// encode into RTP
+		tunit.RTPPackets = t.encoder.encode(tunit.Payload, tunit.PTS)
+		return nil
+	}
+
 	pkt := tunit.RTPPackets[0]
 
 	// remove padding
@@ -1,7 +1,9 @@
 package formatprocessor
 
 import (
+	"bytes"
 	"testing"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/pion/rtp"
@@ -15,7 +17,7 @@ func TestGenericRemovePadding(t *testing.T) {
 	}
 	forma.Init()
 
-	p, err := New(1472, forma, false, nil)
+	p, err := New(1472, forma, false, nil, nil, nil)
 	require.NoError(t, err)
 
 	pkt := &rtp.Packet{
@@ -49,3 +51,30 @@ func TestGenericRemovePadding(t *testing.T) {
 		Payload: []byte{0x01, 0x02, 0x03, 0x04},
 	}, pkt)
 }
+
+func TestGenericGenerateRTPPackets(t *testing.T) {
+	forma := &formats.Generic{
+		PayloadTyp: 96,
+		RTPMa:      "smpte336m/90000",
+	}
+	forma.Init()
+
+	p, err := New(1472, forma, true, nil, nil, nil)
+	require.NoError(t, err)
+
+	unit := &UnitGeneric{
+		PTS:     2 * time.Second,
+		Payload: bytes.Repeat([]byte{0x01}, 2000),
+	}
+
+	err = p.Process(unit, false)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(unit.RTPPackets))
+	require.Equal(t, 1460, len(unit.RTPPackets[0].Payload))
+	require.Equal(t, false, unit.RTPPackets[0].Marker)
+	require.Equal(t, 540, len(unit.RTPPackets[1].Payload))
+	require.Equal(t, true, unit.RTPPackets[1].Marker)
+	require.Equal(t, unit.RTPPackets[0].SequenceNumber+1, unit.RTPPackets[1].SequenceNumber)
+	require.Equal(t, unit.RTPPackets[0].Timestamp, unit.RTPPackets[1].Timestamp)
+}
@@ -90,6 +90,7 @@ func (d *UnitH264) GetNTP() time.Time {
 type formatProcessorH264 struct {
 	udpMaxPayloadSize int
 	format            *formats.H264
+	seiTimestamp      *SEITimestamp
 	log               logger.Writer
 
 	encoder              *rtph264.Encoder
@@ -101,11 +102,13 @@ func newH264(
 	udpMaxPayloadSize int,
 	forma *formats.H264,
 	generateRTPPackets bool,
+	seiTimestamp *SEITimestamp,
 	log logger.Writer,
 ) (*formatProcessorH264, error) {
 	t := &formatProcessorH264{
 		udpMaxPayloadSize: udpMaxPayloadSize,
 		format:            forma,
+		seiTimestamp:      seiTimestamp,
 		log:               log,
 	}
 
@@ -257,8 +260,8 @@ func (t *formatProcessorH264) Process(unit Unit, hasNonRTSPReaders bool) error {
 			pkt.Header.Padding = false
 			pkt.PaddingSize = 0
 
-			// RTP packets exceed maximum size: start re-encoding them
-			if pkt.MarshalSize() > t.udpMaxPayloadSize {
+			// RTP packets exceed maximum size or SEI NAL units have to be injected: start re-encoding them
+			if pkt.MarshalSize() > t.udpMaxPayloadSize || t.seiTimestamp != nil {
 				v1 := pkt.SSRC
 				v2 := pkt.SequenceNumber
 				v3 := pkt.Timestamp
@@ -307,6 +310,14 @@ func (t *formatProcessorH264) Process(unit Unit, hasNonRTSPReaders bool) error {
 		tunit.AU = t.remuxAccessUnit(tunit.AU)
 	}
 
+	if t.seiTimestamp != nil && len(tunit.AU) != 0 {
+		tunit.AU = seiInsert(tunit.AU, t.seiTimestamp.h264NALU(tunit.NTP),
+			func(nalu []byte) bool {
+				typ := h264.NALUType(nalu[0] & 0x1F)
+				return typ == h264.NALUTypeSPS || typ == h264.NALUTypePPS
+			})
+	}
+
 	// encode into RTP
 	if len(tunit.AU) != 0 {
 		pkts, err := t.encoder.Encode(tunit.AU, tunit.PTS)
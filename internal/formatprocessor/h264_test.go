@@ -3,6 +3,7 @@ package formatprocessor
 import (
 	"bytes"
 	"testing"
+	"time"
 
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
@@ -16,7 +17,7 @@ func TestH264DynamicParams(t *testing.T) {
 		PacketizationMode: 1,
 	}
 
-	p, err := New(1472, forma, false, nil)
+	p, err := New(1472, forma, false, nil, nil, nil)
 	require.NoError(t, err)
 
 	enc := forma.CreateEncoder()
@@ -61,7 +62,7 @@ func TestH264OversizedPackets(t *testing.T) {
 		PacketizationMode: 1,
 	}
 
-	p, err := New(1472, forma, false, nil)
+	p, err := New(1472, forma, false, nil, nil, nil)
 	require.NoError(t, err)
 
 	var out []*rtp.Packet
@@ -158,7 +159,7 @@ func TestH264EmptyPacket(t *testing.T) {
 		PacketizationMode: 1,
 	}
 
-	p, err := New(1472, forma, true, nil)
+	p, err := New(1472, forma, true, nil, nil, nil)
 	require.NoError(t, err)
 
 	unit := &UnitH264{
@@ -173,3 +174,30 @@ func TestH264EmptyPacket(t *testing.T) {
 	// if all NALUs have been removed, no RTP packets must be generated.
 	require.Equal(t, []*rtp.Packet(nil), unit.RTPPackets)
 }
+
+func TestH264SEITimestamp(t *testing.T) {
+	forma := &formats.H264{
+		PayloadTyp:        96,
+		SPS:               []byte{0x67, 0x01},
+		PPS:               []byte{0x68, 0x02},
+		PacketizationMode: 1,
+	}
+
+	p, err := New(1472, forma, true, &SEITimestamp{Label: "cam1"}, nil, nil)
+	require.NoError(t, err)
+
+	data := &UnitH264{
+		NTP: time.Date(2023, 5, 20, 10, 15, 30, 250000000, time.UTC),
+		AU:  [][]byte{{byte(h264.NALUTypeIDR)}},
+	}
+	err = p.Process(data, true)
+	require.NoError(t, err)
+
+	require.Equal(t, [][]byte{
+		{0x67, 0x01},
+		{0x68, 0x02},
+		append(append([]byte{0x06, 0x05, 16 + 28}, seiTimestampUUID[:]...),
+			[]byte("2023-05-20T10:15:30.25Z cam1\x80")...),
+		{byte(h264.NALUTypeIDR)},
+	}, data.AU)
+}
@@ -97,6 +97,7 @@ func (d *UnitH265) GetNTP() time.Time {
 type formatProcessorH265 struct {
 	udpMaxPayloadSize int
 	format            *formats.H265
+	seiTimestamp      *SEITimestamp
 	log               logger.Writer
 
 	encoder              *rtph265.Encoder
@@ -108,11 +109,13 @@ func newH265(
 	udpMaxPayloadSize int,
 	forma *formats.H265,
 	generateRTPPackets bool,
+	seiTimestamp *SEITimestamp,
 	log logger.Writer,
 ) (*formatProcessorH265, error) {
 	t := &formatProcessorH265{
 		udpMaxPayloadSize: udpMaxPayloadSize,
 		format:            forma,
+		seiTimestamp:      seiTimestamp,
 		log:               log,
 	}
 
@@ -278,8 +281,8 @@ func (t *formatProcessorH265) Process(unit Unit, hasNonRTSPReaders bool) error {
 			pkt.Header.Padding = false
 			pkt.PaddingSize = 0
 
-			// RTP packets exceed maximum size: start re-encoding them
-			if pkt.MarshalSize() > t.udpMaxPayloadSize {
+			// RTP packets exceed maximum size or SEI NAL units have to be injected: start re-encoding them
+			if pkt.MarshalSize() > t.udpMaxPayloadSize || t.seiTimestamp != nil {
 				v1 := pkt.SSRC
 				v2 := pkt.SequenceNumber
 				v3 := pkt.Timestamp
@@ -328,6 +331,14 @@ func (t *formatProcessorH265) Process(unit Unit, hasNonRTSPReaders bool) error {
 		tunit.AU = t.remuxAccessUnit(tunit.AU)
 	}
 
+	if t.seiTimestamp != nil && len(tunit.AU) != 0 {
+		tunit.AU = seiInsert(tunit.AU, t.seiTimestamp.h265NALU(tunit.NTP),
+			func(nalu []byte) bool {
+				typ := h265.NALUType((nalu[0] >> 1) & 0b111111)
+				return typ == h265.NALUType_VPS_NUT || typ == h265.NALUType_SPS_NUT || typ == h265.NALUType_PPS_NUT
+			})
+	}
+
 	// encode into RTP
 	if len(tunit.AU) != 0 {
 		pkts, err := t.encoder.Encode(tunit.AU, tunit.PTS)
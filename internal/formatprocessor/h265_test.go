@@ -15,7 +15,7 @@ func TestH265DynamicParams(t *testing.T) {
 		PayloadTyp: 96,
 	}
 
-	p, err := New(1472, forma, false, nil)
+	p, err := New(1472, forma, false, nil, nil, nil)
 	require.NoError(t, err)
 
 	enc := forma.CreateEncoder()
@@ -66,7 +66,7 @@ func TestH265OversizedPackets(t *testing.T) {
 		PPS:        []byte{byte(h265.NALUType_PPS_NUT) << 1, 16, 17, 18},
 	}
 
-	p, err := New(1472, forma, false, nil)
+	p, err := New(1472, forma, false, nil, nil, nil)
 	require.NoError(t, err)
 
 	var out []*rtp.Packet
@@ -150,7 +150,7 @@ func TestH265EmptyPacket(t *testing.T) {
 		PayloadTyp: 96,
 	}
 
-	p, err := New(1472, forma, true, nil)
+	p, err := New(1472, forma, true, nil, nil, nil)
 	require.NoError(t, err)
 
 	unit := &UnitH265{
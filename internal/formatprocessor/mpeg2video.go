@@ -0,0 +1,143 @@
+package formatprocessor
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/pion/rtp"
+
+	"github.com/aler9/mediamtx/internal/logger"
+)
+
+const (
+	mpeg2VideoStartCodePicture  = 0x00
+	mpeg2VideoStartCodeSequence = 0xB3
+	mpeg2VideoStartCodeGOP      = 0xB8
+)
+
+// UnitMPEG2Video is a MPEG-1 or MPEG-2 Video data unit.
+type UnitMPEG2Video struct {
+	RTPPackets []*rtp.Packet
+	NTP        time.Time
+	PTS        time.Duration
+	Frame      []byte
+}
+
+// GetRTPPackets implements Unit.
+func (d *UnitMPEG2Video) GetRTPPackets() []*rtp.Packet {
+	return d.RTPPackets
+}
+
+// GetNTP implements Unit.
+func (d *UnitMPEG2Video) GetNTP() time.Time {
+	return d.NTP
+}
+
+// mpeg2VideoFindStartCode returns the position of the given start code, or -1.
+func mpeg2VideoFindStartCode(frame []byte, code byte) int {
+	return bytes.Index(frame, []byte{0x00, 0x00, 0x01, code})
+}
+
+func mpeg2VideoRandomAccess(frame []byte) bool {
+	return mpeg2VideoFindStartCode(frame, mpeg2VideoStartCodeSequence) >= 0 ||
+		mpeg2VideoFindStartCode(frame, mpeg2VideoStartCodeGOP) >= 0
+}
+
+func rtpMPEG2VideoRandomAccess(pkt *rtp.Packet) bool {
+	// the S bit of the MPEG video-specific header marks the presence of a sequence header.
+	return len(pkt.Payload) >= 4 && (pkt.Payload[2]&0x20) != 0
+}
+
+// mpeg2VideoEncoder is a RTP encoder for MPEG-1 and MPEG-2 Video.
+// Specification: https://datatracker.ietf.org/doc/html/rfc2250
+type mpeg2VideoEncoder struct {
+	*genericEncoder
+}
+
+func (e *mpeg2VideoEncoder) encode(frame []byte, pts time.Duration) []*rtp.Packet {
+	// temporal reference and picture type are taken from the picture header.
+	var temporalReference uint16
+	var pictureType byte
+	if i := mpeg2VideoFindStartCode(frame, mpeg2VideoStartCodePicture); i >= 0 && len(frame[i:]) >= 6 {
+		temporalReference = uint16(frame[i+4])<<2 | uint16(frame[i+5])>>6
+		pictureType = (frame[i+5] >> 3) & 0x07
+	}
+
+	hasSequenceHeader := mpeg2VideoFindStartCode(frame, mpeg2VideoStartCodeSequence) >= 0
+
+	e.payloadMaxSize -= 4
+	pkts := e.genericEncoder.encode(frame, pts)
+	e.payloadMaxSize += 4
+
+	for i, pkt := range pkts {
+		header := make([]byte, 4, 4+len(pkt.Payload))
+		header[0] = byte(temporalReference >> 8)
+		header[1] = byte(temporalReference)
+
+		if i == 0 && hasSequenceHeader {
+			header[2] |= 0x20 // S
+		}
+		if i == 0 || bytes.HasPrefix(pkt.Payload, []byte{0x00, 0x00, 0x01}) {
+			header[2] |= 0x10 // B
+		}
+		if i == len(pkts)-1 {
+			header[2] |= 0x08 // E
+		}
+		header[2] |= pictureType
+
+		pkt.Payload = append(header, pkt.Payload...)
+	}
+
+	return pkts
+}
+
+type formatProcessorMPEG2Video struct {
+	udpMaxPayloadSize int
+	encoder           *mpeg2VideoEncoder
+}
+
+func newMPEG2Video(
+	udpMaxPayloadSize int,
+	forma *formats.MPEG2Video,
+	generateRTPPackets bool,
+	log logger.Writer,
+) (*formatProcessorMPEG2Video, error) {
+	t := &formatProcessorMPEG2Video{
+		udpMaxPayloadSize: udpMaxPayloadSize,
+	}
+
+	if generateRTPPackets {
+		t.encoder = &mpeg2VideoEncoder{
+			genericEncoder: newGenericEncoder(forma.PayloadType(), forma.ClockRate(), udpMaxPayloadSize-12),
+		}
+	}
+
+	return t, nil
+}
+
+func (t *formatProcessorMPEG2Video) Process(unit Unit, hasNonRTSPReaders bool) error { //nolint:dupl
+	tunit := unit.(*UnitMPEG2Video)
+
+	if tunit.RTPPackets != nil {
+		pkt := tunit.RTPPackets[0]
+
+		// remove padding
+		pkt.Header.Padding = false
+		pkt.PaddingSize = 0
+
+		if pkt.MarshalSize() > t.udpMaxPayloadSize {
+			return fmt.Errorf("payload size (%d) is greater than maximum allowed (%d)",
+				pkt.MarshalSize(), t.udpMaxPayloadSize)
+		}
+
+		// route packet as is
+		return nil
+	}
+
+	// encode into RTP
+	tunit.RTPPackets = t.encoder.encode(tunit.Frame, tunit.PTS)
+
+	return nil
+}
@@ -0,0 +1,39 @@
+package formatprocessor
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMPEG2VideoGenerateRTPPackets(t *testing.T) {
+	forma := &formats.MPEG2Video{}
+
+	p, err := New(1472, forma, true, nil, nil, nil)
+	require.NoError(t, err)
+
+	frame := append([]byte{
+		0x00, 0x00, 0x01, 0xB3, 0x14, 0x00, 0xF0, 0x13, // sequence header
+		0x00, 0x00, 0x01, 0x00, 0x01, 0x4F, 0xFF, 0xF8, // picture header, TR = 5, I-frame
+	}, bytes.Repeat([]byte{0x01}, 2000)...)
+
+	unit := &UnitMPEG2Video{
+		PTS:   2 * time.Second,
+		Frame: frame,
+	}
+
+	err = p.Process(unit, false)
+	require.NoError(t, err)
+
+	require.Equal(t, 2, len(unit.RTPPackets))
+	require.Equal(t, 1460, len(unit.RTPPackets[0].Payload))
+	require.Equal(t, []byte{0x00, 0x05, 0x20 | 0x10 | 0x01}, unit.RTPPackets[0].Payload[:3])
+	require.Equal(t, false, unit.RTPPackets[0].Marker)
+	require.Equal(t, []byte{0x00, 0x05, 0x08 | 0x01}, unit.RTPPackets[1].Payload[:3])
+	require.Equal(t, true, unit.RTPPackets[1].Marker)
+	require.Equal(t, true, rtpMPEG2VideoRandomAccess(unit.RTPPackets[0]))
+	require.Equal(t, true, UnitRandomAccess(unit))
+}
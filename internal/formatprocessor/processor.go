@@ -24,14 +24,40 @@ func New(
 	udpMaxPayloadSize int,
 	forma formats.Format,
 	generateRTPPackets bool,
+	seiTimestamp *SEITimestamp,
+	analyzer *Analyzer,
+	log logger.Writer,
+) (Processor, error) {
+	proc, err := newProcessor(udpMaxPayloadSize, forma, generateRTPPackets, seiTimestamp, log)
+	if err != nil {
+		return nil, err
+	}
+
+	if analyzer != nil {
+		if analyzers := newUnitAnalyzers(analyzer, forma); len(analyzers) != 0 {
+			return &analyzedProcessor{
+				Processor: proc,
+				analyzers: analyzers,
+			}, nil
+		}
+	}
+
+	return proc, nil
+}
+
+func newProcessor(
+	udpMaxPayloadSize int,
+	forma formats.Format,
+	generateRTPPackets bool,
+	seiTimestamp *SEITimestamp,
 	log logger.Writer,
 ) (Processor, error) {
 	switch forma := forma.(type) {
 	case *formats.H264:
-		return newH264(udpMaxPayloadSize, forma, generateRTPPackets, log)
+		return newH264(udpMaxPayloadSize, forma, generateRTPPackets, seiTimestamp, log)
 
 	case *formats.H265:
-		return newH265(udpMaxPayloadSize, forma, generateRTPPackets, log)
+		return newH265(udpMaxPayloadSize, forma, generateRTPPackets, seiTimestamp, log)
 
 	case *formats.VP8:
 		return newVP8(udpMaxPayloadSize, forma, generateRTPPackets, log)
@@ -42,6 +68,9 @@ func New(
 	case *formats.AV1:
 		return newAV1(udpMaxPayloadSize, forma, generateRTPPackets, log)
 
+	case *formats.MPEG2Video:
+		return newMPEG2Video(udpMaxPayloadSize, forma, generateRTPPackets, log)
+
 	case *formats.MPEG2Audio:
 		return newMPEG2Audio(udpMaxPayloadSize, forma, generateRTPPackets, log)
 
@@ -51,6 +80,12 @@ func New(
 	case *formats.Opus:
 		return newOpus(udpMaxPayloadSize, forma, generateRTPPackets, log)
 
+	case *formats.G711:
+		return newG711(udpMaxPayloadSize, forma, generateRTPPackets, log)
+
+	case *formats.G722:
+		return newG722(udpMaxPayloadSize, forma, generateRTPPackets, log)
+
 	default:
 		return newGeneric(udpMaxPayloadSize, forma, generateRTPPackets, log)
 	}
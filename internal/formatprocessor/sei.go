@@ -0,0 +1,128 @@
+package formatprocessor
+
+import (
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+)
+
+// seiTimestampUUID identifies SEI messages that contain timestamps.
+var seiTimestampUUID = [16]byte{
+	0x6d, 0x65, 0x64, 0x69, 0x61, 0x6d, 0x74, 0x78,
+	0x2d, 0x74, 0x69, 0x6d, 0x65, 0x73, 0x74, 0x70,
+}
+
+const (
+	seiPayloadTypeUserDataUnregistered = 5
+)
+
+// SEITimestamp contains the parameters of the injection of
+// SEI user_data_unregistered NAL units into H264 and H265 streams.
+// Every NAL unit contains the wall-clock time at which the access unit
+// was received, in RFC3339 format, followed by an optional label.
+type SEITimestamp struct {
+	Label string
+}
+
+func (s *SEITimestamp) payload(ntp time.Time) []byte {
+	text := ntp.UTC().Format(time.RFC3339Nano)
+	if s.Label != "" {
+		text += " " + s.Label
+	}
+
+	ret := make([]byte, 16+len(text))
+	copy(ret, seiTimestampUUID[:])
+	copy(ret[16:], text)
+	return ret
+}
+
+// seiMessage returns a sei_message() followed by rbsp_trailing_bits(),
+// with emulation prevention bytes.
+func seiMessage(payloadType int, payload []byte) []byte {
+	var rbsp []byte
+
+	for v := payloadType; ; v -= 255 {
+		if v < 255 {
+			rbsp = append(rbsp, byte(v))
+			break
+		}
+		rbsp = append(rbsp, 0xFF)
+	}
+
+	for v := len(payload); ; v -= 255 {
+		if v < 255 {
+			rbsp = append(rbsp, byte(v))
+			break
+		}
+		rbsp = append(rbsp, 0xFF)
+	}
+
+	rbsp = append(rbsp, payload...)
+	rbsp = append(rbsp, 0x80)
+
+	return emulationPreventionAdd(rbsp)
+}
+
+// emulationPreventionAdd inserts emulation prevention bytes,
+// in order to avoid start code emulations.
+func emulationPreventionAdd(rbsp []byte) []byte {
+	ret := make([]byte, 0, len(rbsp)+len(rbsp)/64)
+	zeros := 0
+
+	for _, b := range rbsp {
+		if zeros == 2 && b <= 0x03 {
+			ret = append(ret, 0x03)
+			zeros = 0
+		}
+
+		ret = append(ret, b)
+
+		if b == 0x00 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+
+	return ret
+}
+
+func (s *SEITimestamp) h264NALU(ntp time.Time) []byte {
+	return append([]byte{0x06}, seiMessage(seiPayloadTypeUserDataUnregistered, s.payload(ntp))...)
+}
+
+func (s *SEITimestamp) h265NALU(ntp time.Time) []byte {
+	// PREFIX_SEI_NUT, nuh_layer_id = 0, nuh_temporal_id_plus1 = 1
+	return append([]byte{39 << 1, 0x01}, seiMessage(seiPayloadTypeUserDataUnregistered, s.payload(ntp))...)
+}
+
+// SEITimestampNALU returns a SEI user_data_unregistered NAL unit that contains
+// the given time and label, in the same format used by SEITimestamp.
+// It returns nil if the format is not H264 or H265.
+func SEITimestampNALU(forma formats.Format, ntp time.Time, label string) []byte {
+	s := &SEITimestamp{Label: label}
+
+	switch forma.(type) {
+	case *formats.H264:
+		return s.h264NALU(ntp)
+
+	case *formats.H265:
+		return s.h265NALU(ntp)
+	}
+
+	return nil
+}
+
+// seiInsert inserts a NAL unit after parameters, before the first slice.
+func seiInsert(nalus [][]byte, sei []byte, isParameter func([]byte) bool) [][]byte {
+	i := 0
+	for i < len(nalus) && isParameter(nalus[i]) {
+		i++
+	}
+
+	ret := make([][]byte, 0, len(nalus)+1)
+	ret = append(ret, nalus[:i]...)
+	ret = append(ret, sei)
+	ret = append(ret, nalus[i:]...)
+	return ret
+}
@@ -0,0 +1,13 @@
+package formatprocessor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSEIEmulationPrevention(t *testing.T) {
+	require.Equal(t,
+		[]byte{0x00, 0x00, 0x03, 0x01, 0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x03, 0x00, 0x00, 0x04},
+		emulationPreventionAdd([]byte{0x00, 0x00, 0x01, 0x00, 0x00, 0x00, 0x00, 0x03, 0x00, 0x00, 0x04}))
+}
@@ -0,0 +1,87 @@
+package formatprocessor
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats/rtpsimpleaudio"
+	"github.com/pion/rtp"
+)
+
+// formatProcessorSimpleAudio contains the code shared by processors of formats
+// that are transported with rtpsimpleaudio, like G711 and G722.
+type formatProcessorSimpleAudio struct {
+	udpMaxPayloadSize int
+	createDecoder     func() *rtpsimpleaudio.Decoder
+	encoder           *rtpsimpleaudio.Encoder
+	decoder           *rtpsimpleaudio.Decoder
+}
+
+func newSimpleAudio(
+	udpMaxPayloadSize int,
+	payloadType uint8,
+	sampleRate int,
+	createDecoder func() *rtpsimpleaudio.Decoder,
+	generateRTPPackets bool,
+) *formatProcessorSimpleAudio {
+	t := &formatProcessorSimpleAudio{
+		udpMaxPayloadSize: udpMaxPayloadSize,
+		createDecoder:     createDecoder,
+	}
+
+	if generateRTPPackets {
+		t.encoder = &rtpsimpleaudio.Encoder{
+			PayloadMaxSize: t.udpMaxPayloadSize - 12,
+			PayloadType:    payloadType,
+			SampleRate:     sampleRate,
+		}
+		t.encoder.Init()
+	}
+
+	return t
+}
+
+func (t *formatProcessorSimpleAudio) process(
+	rtpPackets *[]*rtp.Packet,
+	pts *time.Duration,
+	samples *[]byte,
+	hasNonRTSPReaders bool,
+) error {
+	if *rtpPackets != nil {
+		pkt := (*rtpPackets)[0]
+
+		// remove padding
+		pkt.Header.Padding = false
+		pkt.PaddingSize = 0
+
+		if pkt.MarshalSize() > t.udpMaxPayloadSize {
+			return fmt.Errorf("payload size (%d) is greater than maximum allowed (%d)",
+				pkt.MarshalSize(), t.udpMaxPayloadSize)
+		}
+
+		// decode from RTP
+		if hasNonRTSPReaders {
+			if t.decoder == nil {
+				t.decoder = t.createDecoder()
+			}
+
+			var err error
+			*samples, *pts, err = t.decoder.Decode(pkt)
+			if err != nil {
+				return err
+			}
+		}
+
+		// route packet as is
+		return nil
+	}
+
+	// encode into RTP
+	pkt, err := t.encoder.Encode(*samples, *pts)
+	if err != nil {
+		return err
+	}
+	*rtpPackets = []*rtp.Packet{pkt}
+
+	return nil
+}
@@ -0,0 +1,62 @@
+package formatprocessor
+
+import (
+	"testing"
+
+	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSimpleAudioEncodeDecode(t *testing.T) {
+	for _, ca := range []struct {
+		name  string
+		forma formats.Format
+		unit  func(samples []byte) Unit
+	}{
+		{
+			"g711",
+			&formats.G711{MULaw: true},
+			func(samples []byte) Unit { return &UnitG711{Samples: samples} },
+		},
+		{
+			"g722",
+			&formats.G722{},
+			func(samples []byte) Unit { return &UnitG722{Samples: samples} },
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			p, err := New(1472, ca.forma, true, nil, nil, nil)
+			require.NoError(t, err)
+
+			samples := []byte{1, 2, 3, 4}
+			unit := ca.unit(samples)
+
+			err = p.Process(unit, false)
+			require.NoError(t, err)
+			require.Equal(t, 1, len(unit.GetRTPPackets()))
+			require.Equal(t, ca.forma.PayloadType(), unit.GetRTPPackets()[0].PayloadType)
+
+			// decode the generated packet, as it was received from RTSP
+			p2, err := New(1472, ca.forma, false, nil, nil, nil)
+			require.NoError(t, err)
+
+			unit2 := ca.unit(nil)
+			switch tunit := unit2.(type) {
+			case *UnitG711:
+				tunit.RTPPackets = unit.GetRTPPackets()
+			case *UnitG722:
+				tunit.RTPPackets = unit.GetRTPPackets()
+			}
+
+			err = p2.Process(unit2, true)
+			require.NoError(t, err)
+
+			switch tunit := unit2.(type) {
+			case *UnitG711:
+				require.Equal(t, samples, tunit.Samples)
+			case *UnitG722:
+				require.Equal(t, samples, tunit.Samples)
+			}
+		})
+	}
+}
@@ -3,6 +3,8 @@ package formatprocessor
 import (
 	"time"
 
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
 	"github.com/pion/rtp"
 )
 
@@ -11,3 +13,179 @@ type Unit interface {
 	GetRTPPackets() []*rtp.Packet
 	GetNTP() time.Time
 }
+
+// UnitPTS returns a pointer to the PTS of a unit,
+// or nil if the unit doesn't have a PTS.
+func UnitPTS(u Unit) *time.Duration {
+	switch tunit := u.(type) {
+	case *UnitH264:
+		return &tunit.PTS
+
+	case *UnitH265:
+		return &tunit.PTS
+
+	case *UnitVP8:
+		return &tunit.PTS
+
+	case *UnitVP9:
+		return &tunit.PTS
+
+	case *UnitAV1:
+		return &tunit.PTS
+
+	case *UnitMPEG2Video:
+		return &tunit.PTS
+
+	case *UnitMPEG2Audio:
+		return &tunit.PTS
+
+	case *UnitMPEG4Audio:
+		return &tunit.PTS
+
+	case *UnitOpus:
+		return &tunit.PTS
+
+	case *UnitG711:
+		return &tunit.PTS
+
+	case *UnitG722:
+		return &tunit.PTS
+
+	default:
+		return nil
+	}
+}
+
+// UnitRandomAccess returns whether a unit can be used as the starting point of a stream,
+// that is, it contains or begins a key frame, or parameters that precede a key frame.
+// Units of formats that don't have key frames are always random access.
+func UnitRandomAccess(u Unit) bool {
+	switch tunit := u.(type) {
+	case *UnitH264:
+		if len(tunit.AU) != 0 {
+			return h264RandomAccess(tunit.AU)
+		}
+		return len(tunit.RTPPackets) != 0 && rtpH264RandomAccess(tunit.RTPPackets[0])
+
+	case *UnitH265:
+		if len(tunit.AU) != 0 {
+			return h265RandomAccess(tunit.AU)
+		}
+		return len(tunit.RTPPackets) != 0 && rtpH265RandomAccess(tunit.RTPPackets[0])
+
+	case *UnitMPEG2Video:
+		if len(tunit.Frame) != 0 {
+			return mpeg2VideoRandomAccess(tunit.Frame)
+		}
+		return len(tunit.RTPPackets) != 0 && rtpMPEG2VideoRandomAccess(tunit.RTPPackets[0])
+
+	default:
+		return true
+	}
+}
+
+func h264RandomAccessType(typ h264.NALUType) bool {
+	return typ == h264.NALUTypeIDR || typ == h264.NALUTypeSPS
+}
+
+func h264RandomAccess(au [][]byte) bool {
+	for _, nalu := range au {
+		if len(nalu) != 0 && h264RandomAccessType(h264.NALUType(nalu[0]&0x1F)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rtpH264RandomAccess(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 1 {
+		return false
+	}
+
+	typ := h264.NALUType(pkt.Payload[0] & 0x1F)
+
+	switch typ {
+	case h264.NALUTypeSTAPA:
+		payload := pkt.Payload[1:]
+
+		for len(payload) >= 2 {
+			l := int(uint16(payload[0])<<8 | uint16(payload[1]))
+			payload = payload[2:]
+
+			if l == 0 || l > len(payload) {
+				break
+			}
+
+			if h264RandomAccessType(h264.NALUType(payload[0] & 0x1F)) {
+				return true
+			}
+
+			payload = payload[l:]
+		}
+
+		return false
+
+	case h264.NALUTypeFUA:
+		// only the first fragment begins the NALU
+		return len(pkt.Payload) >= 2 &&
+			(pkt.Payload[1]&0x80) != 0 &&
+			h264RandomAccessType(h264.NALUType(pkt.Payload[1]&0x1F))
+
+	default:
+		return h264RandomAccessType(typ)
+	}
+}
+
+func h265RandomAccessType(typ h265.NALUType) bool {
+	return (typ >= h265.NALUType_BLA_W_LP && typ <= h265.NALUType_RSV_IRAP_VCL23) ||
+		typ == h265.NALUType_VPS_NUT ||
+		typ == h265.NALUType_SPS_NUT
+}
+
+func h265RandomAccess(au [][]byte) bool {
+	for _, nalu := range au {
+		if len(nalu) != 0 && h265RandomAccessType(h265.NALUType((nalu[0]>>1)&0b111111)) {
+			return true
+		}
+	}
+	return false
+}
+
+func rtpH265RandomAccess(pkt *rtp.Packet) bool {
+	if len(pkt.Payload) < 2 {
+		return false
+	}
+
+	typ := h265.NALUType((pkt.Payload[0] >> 1) & 0b111111)
+
+	switch typ {
+	case h265.NALUType_AggregationUnit:
+		payload := pkt.Payload[2:]
+
+		for len(payload) >= 2 {
+			l := int(uint16(payload[0])<<8 | uint16(payload[1]))
+			payload = payload[2:]
+
+			if l == 0 || l > len(payload) {
+				break
+			}
+
+			if h265RandomAccessType(h265.NALUType((payload[0] >> 1) & 0b111111)) {
+				return true
+			}
+
+			payload = payload[l:]
+		}
+
+		return false
+
+	case h265.NALUType_FragmentationUnit:
+		// only the first fragment begins the NALU
+		return len(pkt.Payload) >= 3 &&
+			(pkt.Payload[2]&0x80) != 0 &&
+			h265RandomAccessType(h265.NALUType(pkt.Payload[2]&0b111111))
+
+	default:
+		return h265RandomAccessType(typ)
+	}
+}
@@ -0,0 +1,66 @@
+package formatprocessor
+
+import (
+	"testing"
+
+	"github.com/pion/rtp"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUnitRandomAccess(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		unit Unit
+		ok   bool
+	}{
+		{
+			"h264 au idr",
+			&UnitH264{AU: [][]byte{{0x09, 0xf0}, {0x05, 0x01}}},
+			true,
+		},
+		{
+			"h264 au non-idr",
+			&UnitH264{AU: [][]byte{{0x01, 0x01}}},
+			false,
+		},
+		{
+			"h264 rtp stap-a with sps",
+			&UnitH264{RTPPackets: []*rtp.Packet{{Payload: []byte{0x18, 0x00, 0x02, 0x67, 0x42}}}},
+			true,
+		},
+		{
+			"h264 rtp fu-a start of idr",
+			&UnitH264{RTPPackets: []*rtp.Packet{{Payload: []byte{0x1c, 0x85, 0x01}}}},
+			true,
+		},
+		{
+			"h264 rtp fu-a middle of idr",
+			&UnitH264{RTPPackets: []*rtp.Packet{{Payload: []byte{0x1c, 0x05, 0x01}}}},
+			false,
+		},
+		{
+			"h265 au idr",
+			&UnitH265{AU: [][]byte{{0x26, 0x01, 0x01}}},
+			true,
+		},
+		{
+			"h265 rtp fu start of idr",
+			&UnitH265{RTPPackets: []*rtp.Packet{{Payload: []byte{0x62, 0x01, 0x93, 0x01}}}},
+			true,
+		},
+		{
+			"h265 rtp non-idr",
+			&UnitH265{RTPPackets: []*rtp.Packet{{Payload: []byte{0x02, 0x01, 0x01}}}},
+			false,
+		},
+		{
+			"opus",
+			&UnitOpus{},
+			true,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			require.Equal(t, ca.ok, UnitRandomAccess(ca.unit))
+		})
+	}
+}
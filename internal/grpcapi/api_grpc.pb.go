@@ -0,0 +1,412 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             v3.21.12
+// source: api.proto
+
+package grpcapi
+
+import (
+	context "context"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	API_GetConfig_FullMethodName        = "/mediamtx.v1.API/GetConfig"
+	API_SetConfig_FullMethodName        = "/mediamtx.v1.API/SetConfig"
+	API_AddPathConfig_FullMethodName    = "/mediamtx.v1.API/AddPathConfig"
+	API_EditPathConfig_FullMethodName   = "/mediamtx.v1.API/EditPathConfig"
+	API_RemovePathConfig_FullMethodName = "/mediamtx.v1.API/RemovePathConfig"
+	API_ListPaths_FullMethodName        = "/mediamtx.v1.API/ListPaths"
+	API_Kick_FullMethodName             = "/mediamtx.v1.API/Kick"
+	API_WatchEvents_FullMethodName      = "/mediamtx.v1.API/WatchEvents"
+)
+
+// APIClient is the client API for API service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type APIClient interface {
+	// GetConfig returns the configuration.
+	GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error)
+	// SetConfig changes the configuration. Only the fields that are present are changed.
+	SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error)
+	// AddPathConfig adds the configuration of a path.
+	AddPathConfig(ctx context.Context, in *AddPathConfigRequest, opts ...grpc.CallOption) (*AddPathConfigResponse, error)
+	// EditPathConfig changes the configuration of a path. Only the fields that are present are changed.
+	EditPathConfig(ctx context.Context, in *EditPathConfigRequest, opts ...grpc.CallOption) (*EditPathConfigResponse, error)
+	// RemovePathConfig removes the configuration of a path.
+	RemovePathConfig(ctx context.Context, in *RemovePathConfigRequest, opts ...grpc.CallOption) (*RemovePathConfigResponse, error)
+	// ListPaths returns active paths.
+	ListPaths(ctx context.Context, in *ListPathsRequest, opts ...grpc.CallOption) (*ListPathsResponse, error)
+	// Kick kicks out RTSP sessions, RTMP connections and WebRTC connections with the given IDs.
+	Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*KickResponse, error)
+	// WatchEvents streams path events as they happen.
+	WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (API_WatchEventsClient, error)
+}
+
+type aPIClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewAPIClient(cc grpc.ClientConnInterface) APIClient {
+	return &aPIClient{cc}
+}
+
+func (c *aPIClient) GetConfig(ctx context.Context, in *GetConfigRequest, opts ...grpc.CallOption) (*GetConfigResponse, error) {
+	out := new(GetConfigResponse)
+	err := c.cc.Invoke(ctx, API_GetConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) SetConfig(ctx context.Context, in *SetConfigRequest, opts ...grpc.CallOption) (*SetConfigResponse, error) {
+	out := new(SetConfigResponse)
+	err := c.cc.Invoke(ctx, API_SetConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) AddPathConfig(ctx context.Context, in *AddPathConfigRequest, opts ...grpc.CallOption) (*AddPathConfigResponse, error) {
+	out := new(AddPathConfigResponse)
+	err := c.cc.Invoke(ctx, API_AddPathConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) EditPathConfig(ctx context.Context, in *EditPathConfigRequest, opts ...grpc.CallOption) (*EditPathConfigResponse, error) {
+	out := new(EditPathConfigResponse)
+	err := c.cc.Invoke(ctx, API_EditPathConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) RemovePathConfig(ctx context.Context, in *RemovePathConfigRequest, opts ...grpc.CallOption) (*RemovePathConfigResponse, error) {
+	out := new(RemovePathConfigResponse)
+	err := c.cc.Invoke(ctx, API_RemovePathConfig_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) ListPaths(ctx context.Context, in *ListPathsRequest, opts ...grpc.CallOption) (*ListPathsResponse, error) {
+	out := new(ListPathsResponse)
+	err := c.cc.Invoke(ctx, API_ListPaths_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) Kick(ctx context.Context, in *KickRequest, opts ...grpc.CallOption) (*KickResponse, error) {
+	out := new(KickResponse)
+	err := c.cc.Invoke(ctx, API_Kick_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *aPIClient) WatchEvents(ctx context.Context, in *WatchEventsRequest, opts ...grpc.CallOption) (API_WatchEventsClient, error) {
+	stream, err := c.cc.NewStream(ctx, &API_ServiceDesc.Streams[0], API_WatchEvents_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &aPIWatchEventsClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type API_WatchEventsClient interface {
+	Recv() (*Event, error)
+	grpc.ClientStream
+}
+
+type aPIWatchEventsClient struct {
+	grpc.ClientStream
+}
+
+func (x *aPIWatchEventsClient) Recv() (*Event, error) {
+	m := new(Event)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// APIServer is the server API for API service.
+// All implementations must embed UnimplementedAPIServer
+// for forward compatibility
+type APIServer interface {
+	// GetConfig returns the configuration.
+	GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error)
+	// SetConfig changes the configuration. Only the fields that are present are changed.
+	SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error)
+	// AddPathConfig adds the configuration of a path.
+	AddPathConfig(context.Context, *AddPathConfigRequest) (*AddPathConfigResponse, error)
+	// EditPathConfig changes the configuration of a path. Only the fields that are present are changed.
+	EditPathConfig(context.Context, *EditPathConfigRequest) (*EditPathConfigResponse, error)
+	// RemovePathConfig removes the configuration of a path.
+	RemovePathConfig(context.Context, *RemovePathConfigRequest) (*RemovePathConfigResponse, error)
+	// ListPaths returns active paths.
+	ListPaths(context.Context, *ListPathsRequest) (*ListPathsResponse, error)
+	// Kick kicks out RTSP sessions, RTMP connections and WebRTC connections with the given IDs.
+	Kick(context.Context, *KickRequest) (*KickResponse, error)
+	// WatchEvents streams path events as they happen.
+	WatchEvents(*WatchEventsRequest, API_WatchEventsServer) error
+	mustEmbedUnimplementedAPIServer()
+}
+
+// UnimplementedAPIServer must be embedded to have forward compatible implementations.
+type UnimplementedAPIServer struct {
+}
+
+func (UnimplementedAPIServer) GetConfig(context.Context, *GetConfigRequest) (*GetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method GetConfig not implemented")
+}
+func (UnimplementedAPIServer) SetConfig(context.Context, *SetConfigRequest) (*SetConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method SetConfig not implemented")
+}
+func (UnimplementedAPIServer) AddPathConfig(context.Context, *AddPathConfigRequest) (*AddPathConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method AddPathConfig not implemented")
+}
+func (UnimplementedAPIServer) EditPathConfig(context.Context, *EditPathConfigRequest) (*EditPathConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method EditPathConfig not implemented")
+}
+func (UnimplementedAPIServer) RemovePathConfig(context.Context, *RemovePathConfigRequest) (*RemovePathConfigResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method RemovePathConfig not implemented")
+}
+func (UnimplementedAPIServer) ListPaths(context.Context, *ListPathsRequest) (*ListPathsResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method ListPaths not implemented")
+}
+func (UnimplementedAPIServer) Kick(context.Context, *KickRequest) (*KickResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Kick not implemented")
+}
+func (UnimplementedAPIServer) WatchEvents(*WatchEventsRequest, API_WatchEventsServer) error {
+	return status.Errorf(codes.Unimplemented, "method WatchEvents not implemented")
+}
+func (UnimplementedAPIServer) mustEmbedUnimplementedAPIServer() {}
+
+// UnsafeAPIServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to APIServer will
+// result in compilation errors.
+type UnsafeAPIServer interface {
+	mustEmbedUnimplementedAPIServer()
+}
+
+func RegisterAPIServer(s grpc.ServiceRegistrar, srv APIServer) {
+	s.RegisterService(&API_ServiceDesc, srv)
+}
+
+func _API_GetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(GetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).GetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_GetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).GetConfig(ctx, req.(*GetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_SetConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(SetConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).SetConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_SetConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).SetConfig(ctx, req.(*SetConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_AddPathConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(AddPathConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).AddPathConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_AddPathConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).AddPathConfig(ctx, req.(*AddPathConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_EditPathConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(EditPathConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).EditPathConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_EditPathConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).EditPathConfig(ctx, req.(*EditPathConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_RemovePathConfig_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(RemovePathConfigRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).RemovePathConfig(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_RemovePathConfig_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).RemovePathConfig(ctx, req.(*RemovePathConfigRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_ListPaths_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(ListPathsRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).ListPaths(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_ListPaths_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).ListPaths(ctx, req.(*ListPathsRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_Kick_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(KickRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(APIServer).Kick(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: API_Kick_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(APIServer).Kick(ctx, req.(*KickRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _API_WatchEvents_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(WatchEventsRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(APIServer).WatchEvents(m, &aPIWatchEventsServer{stream})
+}
+
+type API_WatchEventsServer interface {
+	Send(*Event) error
+	grpc.ServerStream
+}
+
+type aPIWatchEventsServer struct {
+	grpc.ServerStream
+}
+
+func (x *aPIWatchEventsServer) Send(m *Event) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+// API_ServiceDesc is the grpc.ServiceDesc for API service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var API_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "mediamtx.v1.API",
+	HandlerType: (*APIServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "GetConfig",
+			Handler:    _API_GetConfig_Handler,
+		},
+		{
+			MethodName: "SetConfig",
+			Handler:    _API_SetConfig_Handler,
+		},
+		{
+			MethodName: "AddPathConfig",
+			Handler:    _API_AddPathConfig_Handler,
+		},
+		{
+			MethodName: "EditPathConfig",
+			Handler:    _API_EditPathConfig_Handler,
+		},
+		{
+			MethodName: "RemovePathConfig",
+			Handler:    _API_RemovePathConfig_Handler,
+		},
+		{
+			MethodName: "ListPaths",
+			Handler:    _API_ListPaths_Handler,
+		},
+		{
+			MethodName: "Kick",
+			Handler:    _API_Kick_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "WatchEvents",
+			Handler:       _API_WatchEvents_Handler,
+			ServerStreams: true,
+		},
+	},
+	Metadata: "api.proto",
+}
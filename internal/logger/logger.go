@@ -12,7 +12,9 @@ import (
 
 // Logger is a log handler.
 type Logger struct {
-	level Level
+	level        Level
+	moduleLevels map[string]Level
+	levelMutex   sync.RWMutex
 
 	destinations []destination
 	mutex        sync.Mutex
@@ -50,6 +52,16 @@ func New(level Level, destinations []Destination, filePath string) (*Logger, err
 	return lh, nil
 }
 
+// SetLevel changes the log level and the log levels of modules.
+// Modules that are not present in moduleLevels use the log level.
+func (lh *Logger) SetLevel(level Level, moduleLevels map[string]Level) {
+	lh.levelMutex.Lock()
+	defer lh.levelMutex.Unlock()
+
+	lh.level = level
+	lh.moduleLevels = moduleLevels
+}
+
 // Close closes a log handler.
 func (lh *Logger) Close() {
 	for _, dest := range lh.destinations {
@@ -143,7 +155,19 @@ func writeContent(buf *bytes.Buffer, format string, args []interface{}) {
 
 // Log writes a log entry.
 func (lh *Logger) Log(level Level, format string, args ...interface{}) {
-	if level < lh.level {
+	lh.LogModule("", level, format, args...)
+}
+
+// LogModule writes a log entry of a module.
+func (lh *Logger) LogModule(module string, level Level, format string, args ...interface{}) {
+	lh.levelMutex.RLock()
+	minLevel, ok := lh.moduleLevels[module]
+	if !ok {
+		minLevel = lh.level
+	}
+	lh.levelMutex.RUnlock()
+
+	if level < minLevel {
 		return
 	}
 
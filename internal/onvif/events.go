@@ -0,0 +1,209 @@
+package onvif
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	actionCreatePullPointSubscription = "http://www.onvif.org/ver10/events/wsdl/EventPortType/" +
+		"CreatePullPointSubscriptionRequest"
+	actionPullMessages = "http://www.onvif.org/ver10/events/wsdl/PullPointSubscription/PullMessagesRequest"
+	actionRenew        = "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/RenewRequest"
+	actionUnsubscribe  = "http://docs.oasis-open.org/wsn/bw-2/SubscriptionManager/UnsubscribeRequest"
+)
+
+type simpleItem struct {
+	Name  string `xml:"Name,attr"`
+	Value string `xml:"Value,attr"`
+}
+
+type createPullPointSubscriptionResponse struct {
+	Address string `xml:"Body>CreatePullPointSubscriptionResponse>SubscriptionReference>Address"`
+}
+
+type pullMessagesResponse struct {
+	Messages []struct {
+		Topic   string `xml:"Topic"`
+		Message struct {
+			UtcTime           string       `xml:"UtcTime,attr"`
+			PropertyOperation string       `xml:"PropertyOperation,attr"`
+			Source            []simpleItem `xml:"Source>SimpleItem"`
+			Data              []simpleItem `xml:"Data>SimpleItem"`
+		} `xml:"Message>Message"`
+	} `xml:"Body>PullMessagesResponse>NotificationMessage"`
+}
+
+// Event is an event emitted by a device.
+type Event struct {
+	// topic, i.e. tns1:VideoSource/MotionAlarm
+	Topic string
+
+	// time of the event, as reported by the device.
+	Time time.Time
+
+	// Initialized, Changed or Deleted.
+	PropertyOperation string
+
+	Source map[string]string
+	Data   map[string]string
+}
+
+// Motion returns whether the event is a motion event and, in that case, whether motion is in progress.
+// Both the VideoSource/MotionAlarm and the RuleEngine/CellMotionDetector/Motion topics are supported.
+func (e Event) Motion() (bool, bool) {
+	var key string
+
+	switch {
+	case strings.HasSuffix(e.Topic, "VideoSource/MotionAlarm"):
+		key = "State"
+
+	case strings.HasSuffix(e.Topic, "CellMotionDetector/Motion"):
+		key = "IsMotion"
+
+	default:
+		return false, false
+	}
+
+	v, ok := e.Data[key]
+	if !ok {
+		return false, false
+	}
+
+	active, err := strconv.ParseBool(v)
+	if err != nil {
+		return false, false
+	}
+
+	return true, active
+}
+
+// EventsClient is a client of the ONVIF event service of a device,
+// that receives events through a PullPoint subscription.
+type EventsClient struct {
+	// address of the event service, i.e. http://myhost/onvif/event_service
+	URL string
+
+	// credentials. When Username is empty, requests are not authenticated.
+	Username string
+	Password string
+
+	// HTTP client used to perform requests. When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+
+	subscriptionURL string
+}
+
+func (c *EventsClient) do(ctx context.Context, url string, action string, body string) ([]byte, error) {
+	return soapRequest{
+		ctx:        ctx,
+		httpClient: c.HTTPClient,
+		url:        url,
+		username:   c.Username,
+		password:   c.Password,
+		action:     action,
+		body:       body,
+	}.do()
+}
+
+// Subscribe creates a PullPoint subscription, that expires after the given duration
+// unless it is renewed.
+func (c *EventsClient) Subscribe(ctx context.Context, termination time.Duration) error {
+	byts, err := c.do(ctx, c.URL, actionCreatePullPointSubscription,
+		`<tev:CreatePullPointSubscription>`+
+			`<tev:InitialTerminationTime>`+formatDuration(termination)+`</tev:InitialTerminationTime>`+
+			`</tev:CreatePullPointSubscription>`)
+	if err != nil {
+		return err
+	}
+
+	var res createPullPointSubscriptionResponse
+	err = xml.Unmarshal(byts, &res)
+	if err != nil {
+		return err
+	}
+
+	// some devices don't provide the subscription address and use the address of the service.
+	c.subscriptionURL = strings.TrimSpace(res.Address)
+	if c.subscriptionURL == "" {
+		c.subscriptionURL = c.URL
+	}
+
+	return nil
+}
+
+// PullMessages waits for events, up to the given timeout.
+func (c *EventsClient) PullMessages(ctx context.Context, timeout time.Duration, limit int) ([]Event, error) {
+	if c.subscriptionURL == "" {
+		return nil, fmt.Errorf("subscription has not been created")
+	}
+
+	byts, err := c.do(ctx, c.subscriptionURL, actionPullMessages,
+		`<tev:PullMessages>`+
+			`<tev:Timeout>`+formatDuration(timeout)+`</tev:Timeout>`+
+			`<tev:MessageLimit>`+strconv.FormatInt(int64(limit), 10)+`</tev:MessageLimit>`+
+			`</tev:PullMessages>`)
+	if err != nil {
+		return nil, err
+	}
+
+	var res pullMessagesResponse
+	err = xml.Unmarshal(byts, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	ret := make([]Event, len(res.Messages))
+
+	for i, msg := range res.Messages {
+		ev := Event{
+			Topic:             strings.TrimSpace(msg.Topic),
+			PropertyOperation: msg.Message.PropertyOperation,
+			Source:            make(map[string]string),
+			Data:              make(map[string]string),
+		}
+
+		ev.Time, _ = time.Parse(time.RFC3339, msg.Message.UtcTime)
+
+		for _, item := range msg.Message.Source {
+			ev.Source[item.Name] = item.Value
+		}
+
+		for _, item := range msg.Message.Data {
+			ev.Data[item.Name] = item.Value
+		}
+
+		ret[i] = ev
+	}
+
+	return ret, nil
+}
+
+// Renew extends the duration of the subscription.
+func (c *EventsClient) Renew(ctx context.Context, termination time.Duration) error {
+	if c.subscriptionURL == "" {
+		return fmt.Errorf("subscription has not been created")
+	}
+
+	_, err := c.do(ctx, c.subscriptionURL, actionRenew,
+		`<wsnt:Renew>`+
+			`<wsnt:TerminationTime>`+formatDuration(termination)+`</wsnt:TerminationTime>`+
+			`</wsnt:Renew>`)
+	return err
+}
+
+// Unsubscribe deletes the subscription.
+func (c *EventsClient) Unsubscribe(ctx context.Context) error {
+	if c.subscriptionURL == "" {
+		return nil
+	}
+
+	_, err := c.do(ctx, c.subscriptionURL, actionUnsubscribe, `<wsnt:Unsubscribe/>`)
+	c.subscriptionURL = ""
+	return err
+}
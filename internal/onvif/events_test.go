@@ -0,0 +1,130 @@
+package onvif
+
+import (
+	"context"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEventsEnvelope struct {
+	Action string `xml:"Header>Action"`
+	To     string `xml:"Header>To"`
+	Body   struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+func TestEventsClient(t *testing.T) {
+	var s *httptest.Server
+	step := 0
+
+	s = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		byts, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+
+		var env testEventsEnvelope
+		err = xml.Unmarshal(byts, &env)
+		require.NoError(t, err)
+
+		switch step {
+		case 0:
+			require.Equal(t, "/onvif/event_service", r.URL.Path)
+			require.Equal(t, actionCreatePullPointSubscription, env.Action)
+			require.Equal(t, `<tev:CreatePullPointSubscription>`+
+				`<tev:InitialTerminationTime>PT60S</tev:InitialTerminationTime>`+
+				`</tev:CreatePullPointSubscription>`, string(env.Body.Inner))
+
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+				`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+				` xmlns:tev="http://www.onvif.org/ver10/events/wsdl"` +
+				` xmlns:wsa="http://www.w3.org/2005/08/addressing"><s:Body>` +
+				`<tev:CreatePullPointSubscriptionResponse><tev:SubscriptionReference>` +
+				`<wsa:Address>` + s.URL + `/onvif/subscription?Idx=3</wsa:Address>` +
+				`</tev:SubscriptionReference></tev:CreatePullPointSubscriptionResponse>` +
+				`</s:Body></s:Envelope>`))
+
+		case 1:
+			require.Equal(t, "/onvif/subscription", r.URL.Path)
+			require.Equal(t, actionPullMessages, env.Action)
+			require.Equal(t, s.URL+"/onvif/subscription?Idx=3", env.To)
+
+			w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+				`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+				` xmlns:tev="http://www.onvif.org/ver10/events/wsdl"` +
+				` xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2"` +
+				` xmlns:tt="http://www.onvif.org/ver10/schema"><s:Body><tev:PullMessagesResponse>` +
+				`<wsnt:NotificationMessage>` +
+				`<wsnt:Topic Dialect="http://www.onvif.org/ver10/tev/topicExpression/ConcreteSet">` +
+				`tns1:RuleEngine/CellMotionDetector/Motion</wsnt:Topic>` +
+				`<wsnt:Message><tt:Message UtcTime="2023-05-10T12:00:00Z" PropertyOperation="Changed">` +
+				`<tt:Source><tt:SimpleItem Name="VideoSourceConfigurationToken" Value="VideoSource_1"/></tt:Source>` +
+				`<tt:Data><tt:SimpleItem Name="IsMotion" Value="true"/></tt:Data>` +
+				`</tt:Message></wsnt:Message></wsnt:NotificationMessage>` +
+				`<wsnt:NotificationMessage>` +
+				`<wsnt:Topic>tns1:Device/Trigger/DigitalInput</wsnt:Topic>` +
+				`<wsnt:Message><tt:Message UtcTime="2023-05-10T12:00:01Z" PropertyOperation="Changed">` +
+				`<tt:Data><tt:SimpleItem Name="LogicalState" Value="true"/></tt:Data>` +
+				`</tt:Message></wsnt:Message></wsnt:NotificationMessage>` +
+				`</tev:PullMessagesResponse></s:Body></s:Envelope>`))
+
+		case 2:
+			require.Equal(t, actionUnsubscribe, env.Action)
+			require.Equal(t, `<wsnt:Unsubscribe/>`, string(env.Body.Inner))
+		}
+
+		step++
+	}))
+	defer s.Close()
+
+	c := &EventsClient{
+		URL: s.URL + "/onvif/event_service",
+	}
+
+	err := c.Subscribe(context.Background(), 60*time.Second)
+	require.NoError(t, err)
+
+	evs, err := c.PullMessages(context.Background(), 10*time.Second, 10)
+	require.NoError(t, err)
+	require.Equal(t, []Event{
+		{
+			Topic:             "tns1:RuleEngine/CellMotionDetector/Motion",
+			Time:              time.Date(2023, 5, 10, 12, 0, 0, 0, time.UTC),
+			PropertyOperation: "Changed",
+			Source:            map[string]string{"VideoSourceConfigurationToken": "VideoSource_1"},
+			Data:              map[string]string{"IsMotion": "true"},
+		},
+		{
+			Topic:             "tns1:Device/Trigger/DigitalInput",
+			Time:              time.Date(2023, 5, 10, 12, 0, 1, 0, time.UTC),
+			PropertyOperation: "Changed",
+			Source:            map[string]string{},
+			Data:              map[string]string{"LogicalState": "true"},
+		},
+	}, evs)
+
+	isMotion, active := evs[0].Motion()
+	require.Equal(t, true, isMotion)
+	require.Equal(t, true, active)
+
+	isMotion, _ = evs[1].Motion()
+	require.Equal(t, false, isMotion)
+
+	err = c.Unsubscribe(context.Background())
+	require.NoError(t, err)
+	require.Equal(t, 3, step)
+}
+
+func TestEventMotion(t *testing.T) {
+	isMotion, active := Event{
+		Topic: "tns1:VideoSource/MotionAlarm",
+		Data:  map[string]string{"State": "false"},
+	}.Motion()
+	require.Equal(t, true, isMotion)
+	require.Equal(t, false, active)
+}
@@ -0,0 +1,80 @@
+// Package onvif contains clients of the ONVIF PTZ and event services.
+package onvif
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// Client is a client of the ONVIF PTZ service of a device.
+type Client struct {
+	// address of the PTZ service, i.e. http://myhost/onvif/ptz_service
+	URL string
+
+	// credentials. When Username is empty, requests are not authenticated.
+	Username string
+	Password string
+
+	// token of the media profile that is controlled.
+	ProfileToken string
+
+	// HTTP client used to perform requests. When nil, http.DefaultClient is used.
+	HTTPClient *http.Client
+}
+
+// Move starts moving the device with the given pan and tilt velocities, in the range [-1, 1].
+// When timeout is not zero, the device stops automatically after it.
+func (c *Client) Move(pan float64, tilt float64, timeout time.Duration) error {
+	return c.continuousMove(
+		`<tt:PanTilt x="`+formatFloat(pan)+`" y="`+formatFloat(tilt)+`"/>`,
+		timeout)
+}
+
+// Zoom starts zooming the device with the given velocity, in the range [-1, 1].
+// When timeout is not zero, the device stops automatically after it.
+func (c *Client) Zoom(speed float64, timeout time.Duration) error {
+	return c.continuousMove(
+		`<tt:Zoom x="`+formatFloat(speed)+`"/>`,
+		timeout)
+}
+
+func (c *Client) continuousMove(velocity string, timeout time.Duration) error {
+	body := `<tptz:ContinuousMove>` +
+		`<tptz:ProfileToken>` + escape(c.ProfileToken) + `</tptz:ProfileToken>` +
+		`<tptz:Velocity>` + velocity + `</tptz:Velocity>`
+	if timeout != 0 {
+		body += `<tptz:Timeout>` + formatDuration(timeout) + `</tptz:Timeout>`
+	}
+	body += `</tptz:ContinuousMove>`
+
+	return c.do(body)
+}
+
+// Stop stops the pan / tilt movement, the zoom movement, or both.
+func (c *Client) Stop(panTilt bool, zoom bool) error {
+	return c.do(`<tptz:Stop>` +
+		`<tptz:ProfileToken>` + escape(c.ProfileToken) + `</tptz:ProfileToken>` +
+		`<tptz:PanTilt>` + strconv.FormatBool(panTilt) + `</tptz:PanTilt>` +
+		`<tptz:Zoom>` + strconv.FormatBool(zoom) + `</tptz:Zoom>` +
+		`</tptz:Stop>`)
+}
+
+// GotoPreset moves the device to a preset.
+func (c *Client) GotoPreset(token string) error {
+	return c.do(`<tptz:GotoPreset>` +
+		`<tptz:ProfileToken>` + escape(c.ProfileToken) + `</tptz:ProfileToken>` +
+		`<tptz:PresetToken>` + escape(token) + `</tptz:PresetToken>` +
+		`</tptz:GotoPreset>`)
+}
+
+func (c *Client) do(body string) error {
+	_, err := soapRequest{
+		httpClient: c.HTTPClient,
+		url:        c.URL,
+		username:   c.Username,
+		password:   c.Password,
+		body:       body,
+	}.do()
+	return err
+}
@@ -0,0 +1,121 @@
+package onvif
+
+import (
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type testEnvelope struct {
+	Username string `xml:"Header>Security>UsernameToken>Username"`
+	Password string `xml:"Header>Security>UsernameToken>Password"`
+	Nonce    string `xml:"Header>Security>UsernameToken>Nonce"`
+	Created  string `xml:"Header>Security>UsernameToken>Created"`
+	Body     struct {
+		Inner []byte `xml:",innerxml"`
+	} `xml:"Body"`
+}
+
+func TestClient(t *testing.T) {
+	for _, ca := range []struct {
+		name string
+		do   func(c *Client) error
+		body string
+	}{
+		{
+			"move",
+			func(c *Client) error {
+				return c.Move(0.5, -0.25, 1500*time.Millisecond)
+			},
+			`<tptz:ContinuousMove><tptz:ProfileToken>prof&amp;1</tptz:ProfileToken>` +
+				`<tptz:Velocity><tt:PanTilt x="0.5" y="-0.25"/></tptz:Velocity>` +
+				`<tptz:Timeout>PT1.5S</tptz:Timeout></tptz:ContinuousMove>`,
+		},
+		{
+			"zoom",
+			func(c *Client) error {
+				return c.Zoom(1, 0)
+			},
+			`<tptz:ContinuousMove><tptz:ProfileToken>prof&amp;1</tptz:ProfileToken>` +
+				`<tptz:Velocity><tt:Zoom x="1"/></tptz:Velocity></tptz:ContinuousMove>`,
+		},
+		{
+			"stop",
+			func(c *Client) error {
+				return c.Stop(true, false)
+			},
+			`<tptz:Stop><tptz:ProfileToken>prof&amp;1</tptz:ProfileToken>` +
+				`<tptz:PanTilt>true</tptz:PanTilt><tptz:Zoom>false</tptz:Zoom></tptz:Stop>`,
+		},
+		{
+			"preset",
+			func(c *Client) error {
+				return c.GotoPreset("home")
+			},
+			`<tptz:GotoPreset><tptz:ProfileToken>prof&amp;1</tptz:ProfileToken>` +
+				`<tptz:PresetToken>home</tptz:PresetToken></tptz:GotoPreset>`,
+		},
+	} {
+		t.Run(ca.name, func(t *testing.T) {
+			s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				require.Equal(t, "application/soap+xml; charset=utf-8", r.Header.Get("Content-Type"))
+
+				byts, err := io.ReadAll(r.Body)
+				require.NoError(t, err)
+
+				var env testEnvelope
+				err = xml.Unmarshal(byts, &env)
+				require.NoError(t, err)
+
+				require.Equal(t, "myuser", env.Username)
+
+				nonce, err := base64.StdEncoding.DecodeString(env.Nonce)
+				require.NoError(t, err)
+
+				h := sha1.New()
+				h.Write(nonce)
+				h.Write([]byte(env.Created))
+				h.Write([]byte("mypass"))
+				require.Equal(t, base64.StdEncoding.EncodeToString(h.Sum(nil)), env.Password)
+
+				require.Equal(t, ca.body, string(env.Body.Inner))
+			}))
+			defer s.Close()
+
+			c := &Client{
+				URL:          s.URL,
+				Username:     "myuser",
+				Password:     "mypass",
+				ProfileToken: "prof&1",
+			}
+			err := ca.do(c)
+			require.NoError(t, err)
+		})
+	}
+}
+
+func TestClientFault(t *testing.T) {
+	s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`<?xml version="1.0" encoding="UTF-8"?>` +
+			`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"><s:Body><s:Fault>` +
+			`<s:Code><s:Value>s:Sender</s:Value></s:Code>` +
+			`<s:Reason><s:Text xml:lang="en">No such preset</s:Text></s:Reason>` +
+			`</s:Fault></s:Body></s:Envelope>`))
+	}))
+	defer s.Close()
+
+	c := &Client{
+		URL:          s.URL,
+		ProfileToken: "prof",
+	}
+	err := c.GotoPreset("missing")
+	require.EqualError(t, err, "device replied with error: No such preset")
+}
@@ -0,0 +1,152 @@
+package onvif
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const envelopeTemplate = `<?xml version="1.0" encoding="UTF-8"?>` +
+	`<s:Envelope xmlns:s="http://www.w3.org/2003/05/soap-envelope"` +
+	` xmlns:tptz="http://www.onvif.org/ver20/ptz/wsdl"` +
+	` xmlns:tev="http://www.onvif.org/ver10/events/wsdl"` +
+	` xmlns:wsnt="http://docs.oasis-open.org/wsn/b-2"` +
+	` xmlns:wsa="http://www.w3.org/2005/08/addressing"` +
+	` xmlns:tt="http://www.onvif.org/ver10/schema">` +
+	`%s<s:Body>%s</s:Body></s:Envelope>`
+
+const securityTemplate = `<wsse:Security s:mustUnderstand="1"` +
+	` xmlns:wsse="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-secext-1.0.xsd"` +
+	` xmlns:wsu="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-wssecurity-utility-1.0.xsd">` +
+	`<wsse:UsernameToken>` +
+	`<wsse:Username>%s</wsse:Username>` +
+	`<wsse:Password Type="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-username-token-profile-1.0` +
+	`#PasswordDigest">%s</wsse:Password>` +
+	`<wsse:Nonce EncodingType="http://docs.oasis-open.org/wss/2004/01/oasis-200401-wss-soap-message-security-1.0` +
+	`#Base64Binary">%s</wsse:Nonce>` +
+	`<wsu:Created>%s</wsu:Created>` +
+	`</wsse:UsernameToken>` +
+	`</wsse:Security>`
+
+func escape(s string) string {
+	var buf bytes.Buffer
+	xml.EscapeText(&buf, []byte(s))
+	return buf.String()
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// formatDuration encodes a duration with the xs:duration format.
+func formatDuration(d time.Duration) string {
+	return "PT" + formatFloat(d.Seconds()) + "S"
+}
+
+type fault struct {
+	Reason string `xml:"Body>Fault>Reason>Text"`
+}
+
+func security(username string, password string) (string, error) {
+	if username == "" {
+		return "", nil
+	}
+
+	nonce := make([]byte, 16)
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	created := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
+	h := sha1.New()
+	h.Write(nonce)
+	h.Write([]byte(created))
+	h.Write([]byte(password))
+	digest := h.Sum(nil)
+
+	return fmt.Sprintf(securityTemplate,
+		escape(username),
+		base64.StdEncoding.EncodeToString(digest),
+		base64.StdEncoding.EncodeToString(nonce),
+		created), nil
+}
+
+// soapRequest is a request to a SOAP service.
+type soapRequest struct {
+	ctx        context.Context
+	httpClient *http.Client
+	url        string
+	username   string
+	password   string
+
+	// WS-Addressing action. When empty, addressing headers are not sent.
+	action string
+
+	body string
+}
+
+// do performs the request and returns the body of the response.
+func (r soapRequest) do() ([]byte, error) {
+	header, err := security(r.username, r.password)
+	if err != nil {
+		return nil, err
+	}
+
+	if r.action != "" {
+		header += `<wsa:Action>` + escape(r.action) + `</wsa:Action>` +
+			`<wsa:To>` + escape(r.url) + `</wsa:To>`
+	}
+
+	if header != "" {
+		header = `<s:Header>` + header + `</s:Header>`
+	}
+
+	ctx := r.ctx
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.url,
+		strings.NewReader(fmt.Sprintf(envelopeTemplate, header, r.body)))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/soap+xml; charset=utf-8")
+
+	hc := r.httpClient
+	if hc == nil {
+		hc = http.DefaultClient
+	}
+
+	res, err := hc.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	byts, err := io.ReadAll(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if res.StatusCode != http.StatusOK {
+		var f fault
+		if xml.Unmarshal(byts, &f) == nil && f.Reason != "" {
+			return nil, fmt.Errorf("device replied with error: %s", f.Reason)
+		}
+		return nil, fmt.Errorf("bad status code: %d", res.StatusCode)
+	}
+
+	return byts, nil
+}
@@ -15,6 +15,8 @@ const (
 // supported audio codecs
 const (
 	CodecMPEG2Audio = 2
+	CodecPCMA       = 7
+	CodecPCMU       = 8
 	CodecMPEG4Audio = 10
 )
 
@@ -52,7 +54,7 @@ func (m *Audio) Unmarshal(raw *rawmessage.Message) error {
 
 	m.Codec = raw.Body[0] >> 4
 	switch m.Codec {
-	case CodecMPEG2Audio, CodecMPEG4Audio:
+	case CodecMPEG2Audio, CodecPCMA, CodecPCMU, CodecMPEG4Audio:
 	default:
 		return fmt.Errorf("unsupported audio codec: %d", m.Codec)
 	}
@@ -61,9 +63,7 @@ func (m *Audio) Unmarshal(raw *rawmessage.Message) error {
 	m.Depth = (raw.Body[0] >> 1) & 0x01
 	m.Channels = raw.Body[0] & 0x01
 
-	if m.Codec == CodecMPEG2Audio {
-		m.Payload = raw.Body[1:]
-	} else {
+	if m.Codec == CodecMPEG4Audio {
 		m.AACType = AudioAACType(raw.Body[1])
 		switch m.AACType {
 		case AudioAACTypeConfig, AudioAACTypeAU:
@@ -72,6 +72,8 @@ func (m *Audio) Unmarshal(raw *rawmessage.Message) error {
 		}
 
 		m.Payload = raw.Body[2:]
+	} else {
+		m.Payload = raw.Body[1:]
 	}
 
 	return nil
@@ -80,20 +82,20 @@ func (m *Audio) Unmarshal(raw *rawmessage.Message) error {
 // Marshal implements Message.
 func (m Audio) Marshal() (*rawmessage.Message, error) {
 	var l int
-	if m.Codec == CodecMPEG2Audio {
-		l = 1 + len(m.Payload)
-	} else {
+	if m.Codec == CodecMPEG4Audio {
 		l = 2 + len(m.Payload)
+	} else {
+		l = 1 + len(m.Payload)
 	}
 	body := make([]byte, l)
 
 	body[0] = m.Codec<<4 | m.Rate<<2 | m.Depth<<1 | m.Channels
 
-	if m.Codec == CodecMPEG2Audio {
-		copy(body[1:], m.Payload)
-	} else {
+	if m.Codec == CodecMPEG4Audio {
 		body[1] = uint8(m.AACType)
 		copy(body[2:], m.Payload)
+	} else {
+		copy(body[1:], m.Payload)
 	}
 
 	return &rawmessage.Message{
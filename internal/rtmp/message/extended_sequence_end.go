@@ -2,13 +2,17 @@ package message
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/aler9/mediamtx/internal/rtmp/rawmessage"
 )
 
 // ExtendedSequenceEnd is a sequence end extended message.
 type ExtendedSequenceEnd struct {
-	FourCC [4]byte
+	ChunkStreamID   byte
+	DTS             time.Duration
+	MessageStreamID uint32
+	FourCC          [4]byte
 }
 
 // Unmarshal implements Message.
@@ -17,6 +21,9 @@ func (m *ExtendedSequenceEnd) Unmarshal(raw *rawmessage.Message) error {
 		return fmt.Errorf("invalid body size")
 	}
 
+	m.ChunkStreamID = raw.ChunkStreamID
+	m.DTS = raw.Timestamp
+	m.MessageStreamID = raw.MessageStreamID
 	copy(m.FourCC[:], raw.Body[1:5])
 
 	return nil
@@ -24,5 +31,16 @@ func (m *ExtendedSequenceEnd) Unmarshal(raw *rawmessage.Message) error {
 
 // Marshal implements Message.
 func (m ExtendedSequenceEnd) Marshal() (*rawmessage.Message, error) {
-	return nil, fmt.Errorf("TODO")
+	body := make([]byte, 5)
+
+	body[0] = 0b10000000 | byte(ExtendedTypeSequenceEnd)
+	copy(body[1:5], m.FourCC[:])
+
+	return &rawmessage.Message{
+		ChunkStreamID:   m.ChunkStreamID,
+		Timestamp:       m.DTS,
+		Type:            uint8(TypeVideo),
+		MessageStreamID: m.MessageStreamID,
+		Body:            body,
+	}, nil
 }
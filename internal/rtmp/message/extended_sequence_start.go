@@ -2,18 +2,29 @@ package message
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/aler9/mediamtx/internal/rtmp/rawmessage"
 )
 
 // ExtendedSequenceStart is a sequence start extended message.
 type ExtendedSequenceStart struct {
-	FourCC [4]byte
-	Config []byte
+	ChunkStreamID   byte
+	DTS             time.Duration
+	MessageStreamID uint32
+	FourCC          [4]byte
+	Config          []byte
 }
 
 // Unmarshal implements Message.
 func (m *ExtendedSequenceStart) Unmarshal(raw *rawmessage.Message) error {
+	if len(raw.Body) < 5 {
+		return fmt.Errorf("not enough bytes")
+	}
+
+	m.ChunkStreamID = raw.ChunkStreamID
+	m.DTS = raw.Timestamp
+	m.MessageStreamID = raw.MessageStreamID
 	copy(m.FourCC[:], raw.Body[1:5])
 	m.Config = raw.Body[5:]
 
@@ -22,5 +33,17 @@ func (m *ExtendedSequenceStart) Unmarshal(raw *rawmessage.Message) error {
 
 // Marshal implements Message.
 func (m ExtendedSequenceStart) Marshal() (*rawmessage.Message, error) {
-	return nil, fmt.Errorf("TODO")
+	body := make([]byte, 5+len(m.Config))
+
+	body[0] = 0b10000000 | byte(ExtendedTypeSequenceStart)
+	copy(body[1:5], m.FourCC[:])
+	copy(body[5:], m.Config)
+
+	return &rawmessage.Message{
+		ChunkStreamID:   m.ChunkStreamID,
+		Timestamp:       m.DTS,
+		Type:            uint8(TypeVideo),
+		MessageStreamID: m.MessageStreamID,
+		Body:            body,
+	}, nil
 }
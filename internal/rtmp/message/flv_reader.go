@@ -0,0 +1,105 @@
+package message
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/aler9/mediamtx/internal/rtmp/rawmessage"
+)
+
+// FLVReader reads messages from a FLV stream, that is made of the same
+// audio, video and data messages that are exchanged with RTMP.
+type FLVReader struct {
+	r          io.Reader
+	headerRead bool
+}
+
+// NewFLVReader allocates a FLVReader.
+func NewFLVReader(r io.Reader) *FLVReader {
+	return &FLVReader{
+		r: r,
+	}
+}
+
+func (r *FLVReader) readHeader() error {
+	var header [9]byte
+	_, err := io.ReadFull(r.r, header[:])
+	if err != nil {
+		return err
+	}
+
+	if header[0] != 'F' || header[1] != 'L' || header[2] != 'V' {
+		return fmt.Errorf("invalid FLV signature")
+	}
+
+	dataOffset := uint32(header[5])<<24 | uint32(header[6])<<16 | uint32(header[7])<<8 | uint32(header[8])
+	if dataOffset < 9 {
+		return fmt.Errorf("invalid FLV data offset: %d", dataOffset)
+	}
+
+	// skip the rest of the header and the first PreviousTagSize
+	_, err = io.CopyN(io.Discard, r.r, int64(dataOffset-9)+4)
+	return err
+}
+
+// Read reads a Message.
+func (r *FLVReader) Read() (Message, error) {
+	if !r.headerRead {
+		err := r.readHeader()
+		if err != nil {
+			return nil, err
+		}
+		r.headerRead = true
+	}
+
+	for {
+		var header [11]byte
+		_, err := io.ReadFull(r.r, header[:])
+		if err != nil {
+			return nil, err
+		}
+
+		if (header[0] & 0x20) != 0 {
+			return nil, fmt.Errorf("encrypted FLV tags are not supported")
+		}
+
+		typ := header[0] & 0x1F
+		dataSize := uint32(header[1])<<16 | uint32(header[2])<<8 | uint32(header[3])
+		timestamp := uint32(header[7])<<24 | uint32(header[4])<<16 | uint32(header[5])<<8 | uint32(header[6])
+		streamID := uint32(header[8])<<16 | uint32(header[9])<<8 | uint32(header[10])
+
+		// read body and PreviousTagSize
+		body := make([]byte, dataSize+4)
+		_, err = io.ReadFull(r.r, body)
+		if err != nil {
+			return nil, err
+		}
+		body = body[:dataSize]
+
+		switch Type(typ) {
+		case TypeAudio, TypeVideo, TypeDataAMF0:
+		default:
+			continue
+		}
+
+		raw := &rawmessage.Message{
+			Timestamp:       time.Duration(timestamp) * time.Millisecond,
+			Type:            typ,
+			MessageStreamID: streamID,
+			Body:            body,
+		}
+
+		msg, err := allocateMessage(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		err = msg.Unmarshal(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return msg, nil
+	}
+}
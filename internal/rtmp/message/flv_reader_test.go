@@ -0,0 +1,59 @@
+package message
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/notedit/rtmp/format/flv/flvio"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFLVReader(t *testing.T) {
+	var buf bytes.Buffer
+
+	// header and first PreviousTagSize
+	buf.Write([]byte{
+		'F', 'L', 'V', 0x01, 0x05, 0x00, 0x00, 0x00, 0x09,
+		0x00, 0x00, 0x00, 0x00,
+	})
+
+	// unsupported tag, skipped
+	buf.Write([]byte{
+		0x0a, 0x00, 0x00, 0x02, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00,
+		0x01, 0x02,
+		0x00, 0x00, 0x00, 0x0d,
+	})
+
+	// video tag with extended timestamp
+	buf.Write([]byte{
+		0x09, 0x00, 0x00, 0x09, 0x5b, 0xc3, 0x6e, 0x01, 0x00, 0x00, 0x00,
+		0x17, 0x01, 0x00, 0x00, 0x02, 0x01, 0x02, 0x03, 0x04,
+		0x00, 0x00, 0x00, 0x14,
+	})
+
+	r := NewFLVReader(&buf)
+
+	msg, err := r.Read()
+	require.NoError(t, err)
+	require.Equal(t, &Video{
+		DTS:        (6013806 + 0x01000000) * time.Millisecond,
+		Codec:      CodecH264,
+		IsKeyFrame: true,
+		Type:       VideoTypeAU,
+		PTSDelta:   2 * time.Millisecond,
+		Payload:    []byte{0x01, 0x02, 0x03, 0x04},
+	}, msg)
+
+	_, err = r.Read()
+	require.Error(t, err)
+}
+
+func TestFLVReaderInvalidSignature(t *testing.T) {
+	r := NewFLVReader(bytes.NewReader([]byte{
+		'F', 'L', 'X', 0x01, byte(flvio.FILE_HAS_VIDEO), 0x00, 0x00, 0x00, 0x09,
+	}))
+
+	_, err := r.Read()
+	require.EqualError(t, err, "invalid FLV signature")
+}
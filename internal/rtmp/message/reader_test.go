@@ -43,6 +43,23 @@ var readWriterCases = []struct {
 			0x01, 0x02, 0x03, 0x04,
 		},
 	},
+	{
+		"audio g711",
+		&Audio{
+			ChunkStreamID:   7,
+			DTS:             6013806 * time.Millisecond,
+			MessageStreamID: 4534543,
+			Codec:           CodecPCMU,
+			Rate:            flvio.SOUND_5_5Khz,
+			Depth:           flvio.SOUND_16BIT,
+			Channels:        flvio.SOUND_MONO,
+			Payload:         []byte{0x01, 0x02, 0x03, 0x04},
+		},
+		[]byte{
+			0x7, 0x5b, 0xc3, 0x6e, 0x0, 0x0, 0x5, 0x8, 0x0, 0x45, 0x31, 0xf, 0x82,
+			0x01, 0x02, 0x03, 0x04,
+		},
+	},
 	{
 		"audio mpeg4",
 		&Audio{
@@ -232,6 +249,35 @@ var readWriterCases = []struct {
 			0x0a, 0x01, 0x02, 0x03,
 		},
 	},
+	{
+		"extended sequence start",
+		&ExtendedSequenceStart{
+			ChunkStreamID:   4,
+			DTS:             15100 * time.Millisecond,
+			MessageStreamID: 0x1000000,
+			FourCC:          FourCCAV1,
+			Config:          []byte{0x01, 0x02, 0x03},
+		},
+		[]byte{
+			0x04, 0x00, 0x3a, 0xfc, 0x00, 0x00, 0x08, 0x09,
+			0x01, 0x00, 0x00, 0x00, 0x80, 0x61, 0x76, 0x30,
+			0x31, 0x01, 0x02, 0x03,
+		},
+	},
+	{
+		"extended sequence end",
+		&ExtendedSequenceEnd{
+			ChunkStreamID:   4,
+			DTS:             15100 * time.Millisecond,
+			MessageStreamID: 0x1000000,
+			FourCC:          FourCCHEVC,
+		},
+		[]byte{
+			0x04, 0x00, 0x3a, 0xfc, 0x00, 0x00, 0x05, 0x09,
+			0x01, 0x00, 0x00, 0x00, 0x82, 0x68, 0x76, 0x63,
+			0x31,
+		},
+	},
 	{
 		"extended coded frames",
 		&ExtendedCodedFrames{
@@ -19,6 +19,10 @@ import (
 	"github.com/aler9/mediamtx/internal/rtmp/message"
 )
 
+type messageReader interface {
+	Read() (message.Message, error)
+}
+
 func h265FindNALU(array []gomp4.HEVCNaluArray, typ h265.NALUType) []byte {
 	for _, entry := range array {
 		if entry.NaluType == byte(typ) && entry.NumNalus == 1 &&
@@ -60,9 +64,67 @@ func trackFromAACDecoderConfig(data []byte) (*formats.MPEG4Audio, error) {
 	}, nil
 }
 
+func trackFromExtendedSequenceStart(msg *message.ExtendedSequenceStart) (formats.Format, error) {
+	switch msg.FourCC {
+	case message.FourCCHEVC:
+		var hvcc gomp4.HvcC
+		_, err := gomp4.Unmarshal(bytes.NewReader(msg.Config), uint64(len(msg.Config)), &hvcc, gomp4.Context{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid H265 configuration: %v", err)
+		}
+
+		vps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_VPS_NUT)
+		sps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_SPS_NUT)
+		pps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_PPS_NUT)
+		if vps == nil || sps == nil || pps == nil {
+			return nil, fmt.Errorf("H265 parameters are missing")
+		}
+
+		return &formats.H265{
+			PayloadTyp: 96,
+			VPS:        vps,
+			SPS:        sps,
+			PPS:        pps,
+		}, nil
+
+	case message.FourCCAV1:
+		var av1c Av1C
+		_, err := gomp4.Unmarshal(bytes.NewReader(msg.Config), uint64(len(msg.Config)), &av1c, gomp4.Context{})
+		if err != nil {
+			return nil, fmt.Errorf("invalid AV1 configuration: %v", err)
+		}
+
+		// parse sequence header and metadata contained in ConfigOBUs, but do not use them
+		_, err = av1.BitstreamUnmarshal(av1c.ConfigOBUs, false)
+		if err != nil {
+			return nil, fmt.Errorf("invalid AV1 configuration: %v", err)
+		}
+
+		profile := int(av1c.SeqProfile)
+		levelIdx := int(av1c.SeqLevelIdx0)
+		tier := int(av1c.SeqTier0)
+
+		return &formats.AV1{
+			PayloadTyp: 96,
+			Profile:    &profile,
+			LevelIdx:   &levelIdx,
+			Tier:       &tier,
+		}, nil
+
+	default: // VP9
+		return nil, fmt.Errorf("VP9 is not supported yet")
+	}
+}
+
+// fourCCToFloat converts a FourCC into the numeric form
+// used by enhanced RTMP clients in the videocodecid metadata field.
+func fourCCToFloat(v message.FourCC) float64 {
+	return float64(uint32(v[0])<<24 | uint32(v[1])<<16 | uint32(v[2])<<8 | uint32(v[3]))
+}
+
 var errEmptyMetadata = errors.New("metadata is empty")
 
-func readTracksFromMetadata(r *message.ReadWriter, payload []interface{}) (formats.Format, formats.Format, error) {
+func readTracksFromMetadata(r messageReader, payload []interface{}) (formats.Format, formats.Format, error) {
 	if len(payload) != 1 {
 		return nil, nil, fmt.Errorf("invalid metadata")
 	}
@@ -87,12 +149,12 @@ func readTracksFromMetadata(r *message.ReadWriter, payload []interface{}) (forma
 			case 0:
 				return false, nil
 
-			case message.CodecH264:
+			case message.CodecH264, fourCCToFloat(message.FourCCHEVC), fourCCToFloat(message.FourCCAV1):
 				return true, nil
 			}
 
 		case string:
-			if vt == "avc1" {
+			if vt == "avc1" || vt == "hvc1" || vt == "av01" {
 				return true, nil
 			}
 		}
@@ -119,6 +181,14 @@ func readTracksFromMetadata(r *message.ReadWriter, payload []interface{}) (forma
 				audioTrack = &formats.MPEG2Audio{}
 				return true, nil
 
+			case message.CodecPCMA:
+				audioTrack = &formats.G711{}
+				return true, nil
+
+			case message.CodecPCMU:
+				audioTrack = &formats.G711{MULaw: true}
+				return true, nil
+
 			case message.CodecMPEG4Audio:
 				return true, nil
 			}
@@ -202,45 +272,9 @@ func readTracksFromMetadata(r *message.ReadWriter, payload []interface{}) (forma
 
 		case *message.ExtendedSequenceStart:
 			if videoTrack == nil {
-				switch tmsg.FourCC {
-				case message.FourCCHEVC:
-					var hvcc gomp4.HvcC
-					_, err := gomp4.Unmarshal(bytes.NewReader(tmsg.Config), uint64(len(tmsg.Config)), &hvcc, gomp4.Context{})
-					if err != nil {
-						return nil, nil, fmt.Errorf("invalid H265 configuration: %v", err)
-					}
-
-					vps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_VPS_NUT)
-					sps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_SPS_NUT)
-					pps := h265FindNALU(hvcc.NaluArrays, h265.NALUType_PPS_NUT)
-					if vps == nil || sps == nil || pps == nil {
-						return nil, nil, fmt.Errorf("H265 parameters are missing")
-					}
-
-					videoTrack = &formats.H265{
-						PayloadTyp: 96,
-						VPS:        vps,
-						SPS:        sps,
-						PPS:        pps,
-					}
-
-				case message.FourCCAV1:
-					var av1c Av1C
-					_, err := gomp4.Unmarshal(bytes.NewReader(tmsg.Config), uint64(len(tmsg.Config)), &av1c, gomp4.Context{})
-					if err != nil {
-						return nil, nil, fmt.Errorf("invalid AV1 configuration: %v", err)
-					}
-
-					// parse sequence header and metadata contained in ConfigOBUs, but do not use them
-					_, err = av1.BitstreamUnmarshal(av1c.ConfigOBUs, false)
-					if err != nil {
-						return nil, nil, fmt.Errorf("invalid AV1 configuration: %v", err)
-					}
-
-					videoTrack = &formats.AV1{}
-
-				default: // VP9
-					return nil, nil, fmt.Errorf("VP9 is not supported yet")
+				videoTrack, err = trackFromExtendedSequenceStart(tmsg)
+				if err != nil {
+					return nil, nil, err
 				}
 			}
 
@@ -261,10 +295,10 @@ func readTracksFromMetadata(r *message.ReadWriter, payload []interface{}) (forma
 	}
 }
 
-func readTracksFromMessages(r *message.ReadWriter, msg message.Message) (formats.Format, *formats.MPEG4Audio, error) {
+func readTracksFromMessages(r messageReader, msg message.Message) (formats.Format, formats.Format, error) {
 	var startTime *time.Duration
 	var videoTrack formats.Format
-	var audioTrack *formats.MPEG4Audio
+	var audioTrack formats.Format
 
 	// analyze 1 second of packets
 outer:
@@ -295,6 +329,39 @@ outer:
 				break outer
 			}
 
+		case *message.ExtendedSequenceStart:
+			if startTime == nil {
+				v := tmsg.DTS
+				startTime = &v
+			}
+
+			if videoTrack == nil {
+				var err error
+				videoTrack, err = trackFromExtendedSequenceStart(tmsg)
+				if err != nil {
+					return nil, nil, err
+				}
+
+				// stop the analysis if both tracks are found
+				if videoTrack != nil && audioTrack != nil {
+					return videoTrack, audioTrack, nil
+				}
+			}
+
+			if (tmsg.DTS - *startTime) >= 1*time.Second {
+				break outer
+			}
+
+		case *message.ExtendedCodedFrames:
+			if startTime == nil {
+				v := tmsg.DTS
+				startTime = &v
+			}
+
+			if (tmsg.DTS - *startTime) >= 1*time.Second {
+				break outer
+			}
+
 		case *message.Audio:
 			if startTime == nil {
 				v := tmsg.DTS
@@ -337,7 +404,7 @@ outer:
 
 // Read reads track informations.
 // It returns the video track and the audio track.
-func Read(r *message.ReadWriter) (formats.Format, formats.Format, error) {
+func Read(r messageReader) (formats.Format, formats.Format, error) {
 	msg, err := func() (message.Message, error) {
 		for {
 			msg, err := r.Read()
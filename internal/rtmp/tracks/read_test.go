@@ -5,8 +5,10 @@ import (
 	"testing"
 	"time"
 
+	gomp4 "github.com/abema/go-mp4"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
 	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
 	"github.com/bluenviron/mediacommon/pkg/codecs/mpeg4audio"
 	"github.com/notedit/rtmp/format/flv/flvio"
 	"github.com/stretchr/testify/require"
@@ -152,6 +154,73 @@ func TestRead(t *testing.T) {
 				IndexDeltaLength: 3,
 			},
 		},
+		{
+			"obs studio 29.1 h265",
+			&formats.H265{
+				PayloadTyp: 96,
+				VPS: []byte{
+					0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x40,
+					0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+					0x03, 0x00, 0x00, 0x03, 0x00, 0x7b, 0xac, 0x09,
+				},
+				SPS: []byte{
+					0x42, 0x01, 0x01, 0x01, 0x40, 0x00, 0x00, 0x03,
+					0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+					0x03, 0x00, 0x7b, 0xa0, 0x03, 0xc0, 0x80, 0x11,
+					0x07, 0xcb, 0x96, 0xb4, 0xa4, 0x25, 0x92, 0xe3,
+					0x01, 0x6a, 0x02, 0x02, 0x02, 0x08, 0x00, 0x00,
+					0x03, 0x00, 0x08, 0x00, 0x00, 0x03, 0x01, 0xe3,
+					0x00, 0x2e, 0xf2, 0x88, 0x00, 0x09, 0x89, 0x60,
+					0x00, 0x04, 0xc4, 0xb4, 0x20,
+				},
+				PPS: []byte{
+					0x44, 0x01, 0xc0, 0xf7, 0xc0, 0xcc, 0x90,
+				},
+			},
+			&formats.MPEG4Audio{
+				PayloadTyp: 96,
+				Config: &mpeg4audio.Config{
+					Type:         2,
+					SampleRate:   44100,
+					ChannelCount: 2,
+				},
+				SizeLength:       13,
+				IndexLength:      3,
+				IndexDeltaLength: 3,
+			},
+		},
+		{
+			"missing metadata, h265",
+			&formats.H265{
+				PayloadTyp: 96,
+				VPS: []byte{
+					0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x40,
+					0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+					0x03, 0x00, 0x00, 0x03, 0x00, 0x7b, 0xac, 0x09,
+				},
+				SPS: []byte{
+					0x42, 0x01, 0x01, 0x01, 0x40, 0x00, 0x00, 0x03,
+					0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+					0x03, 0x00, 0x7b, 0xa0, 0x03, 0xc0, 0x80, 0x11,
+					0x07, 0xcb, 0x96, 0xb4, 0xa4, 0x25, 0x92, 0xe3,
+					0x01, 0x6a, 0x02, 0x02, 0x02, 0x08, 0x00, 0x00,
+					0x03, 0x00, 0x08, 0x00, 0x00, 0x03, 0x01, 0xe3,
+					0x00, 0x2e, 0xf2, 0x88, 0x00, 0x09, 0x89, 0x60,
+					0x00, 0x04, 0xc4, 0xb4, 0x20,
+				},
+				PPS: []byte{
+					0x44, 0x01, 0xc0, 0xf7, 0xc0, 0xcc, 0x90,
+				},
+			},
+			nil,
+		},
+		{
+			"g711",
+			nil,
+			&formats.G711{
+				MULaw: true,
+			},
+		},
 	} {
 		t.Run(ca.name, func(t *testing.T) {
 			var buf bytes.Buffer
@@ -473,6 +542,168 @@ func TestRead(t *testing.T) {
 					Payload:         enc,
 				})
 				require.NoError(t, err)
+
+			case "obs studio 29.1 h265":
+				err := mrw.Write(&message.DataAMF0{
+					ChunkStreamID:   4,
+					MessageStreamID: 1,
+					Payload: []interface{}{
+						"@setDataFrame",
+						"onMetaData",
+						flvio.AMFMap{
+							{
+								K: "videodatarate",
+								V: float64(0),
+							},
+							{
+								K: "videocodecid",
+								V: fourCCToFloat(message.FourCCHEVC),
+							},
+							{
+								K: "audiodatarate",
+								V: float64(0),
+							},
+							{
+								K: "audiocodecid",
+								V: float64(message.CodecMPEG4Audio),
+							},
+						},
+					},
+				})
+				require.NoError(t, err)
+
+				var hvcc bytes.Buffer
+				_, err = gomp4.Marshal(&hvcc, &gomp4.HvcC{
+					ConfigurationVersion: 1,
+					NumOfNaluArrays:      3,
+					NaluArrays: []gomp4.HEVCNaluArray{
+						{
+							NaluType: byte(h265.NALUType_VPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).VPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).VPS,
+							}},
+						},
+						{
+							NaluType: byte(h265.NALUType_SPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).SPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).SPS,
+							}},
+						},
+						{
+							NaluType: byte(h265.NALUType_PPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).PPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).PPS,
+							}},
+						},
+					},
+				}, gomp4.Context{})
+				require.NoError(t, err)
+
+				err = mrw.Write(&message.ExtendedSequenceStart{
+					ChunkStreamID:   message.VideoChunkStreamID,
+					MessageStreamID: 0x1000000,
+					FourCC:          message.FourCCHEVC,
+					Config:          hvcc.Bytes(),
+				})
+				require.NoError(t, err)
+
+				enc, err := mpeg4audio.Config{
+					Type:         2,
+					SampleRate:   44100,
+					ChannelCount: 2,
+				}.Marshal()
+				require.NoError(t, err)
+
+				err = mrw.Write(&message.Audio{
+					ChunkStreamID:   message.AudioChunkStreamID,
+					MessageStreamID: 0x1000000,
+					Codec:           message.CodecMPEG4Audio,
+					Rate:            flvio.SOUND_44Khz,
+					Depth:           flvio.SOUND_16BIT,
+					Channels:        flvio.SOUND_STEREO,
+					AACType:         message.AudioAACTypeConfig,
+					Payload:         enc,
+				})
+				require.NoError(t, err)
+
+			case "g711":
+				err := mrw.Write(&message.DataAMF0{
+					ChunkStreamID:   4,
+					MessageStreamID: 1,
+					Payload: []interface{}{
+						"@setDataFrame",
+						"onMetaData",
+						flvio.AMFMap{
+							{
+								K: "videocodecid",
+								V: float64(0),
+							},
+							{
+								K: "audiocodecid",
+								V: float64(message.CodecPCMU),
+							},
+						},
+					},
+				})
+				require.NoError(t, err)
+
+			case "missing metadata, h265":
+				var err error
+				var hvcc bytes.Buffer
+				_, err = gomp4.Marshal(&hvcc, &gomp4.HvcC{
+					ConfigurationVersion: 1,
+					NumOfNaluArrays:      3,
+					NaluArrays: []gomp4.HEVCNaluArray{
+						{
+							NaluType: byte(h265.NALUType_VPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).VPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).VPS,
+							}},
+						},
+						{
+							NaluType: byte(h265.NALUType_SPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).SPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).SPS,
+							}},
+						},
+						{
+							NaluType: byte(h265.NALUType_PPS_NUT),
+							NumNalus: 1,
+							Nalus: []gomp4.HEVCNalu{{
+								Length:  uint16(len(ca.videoTrack.(*formats.H265).PPS)),
+								NALUnit: ca.videoTrack.(*formats.H265).PPS,
+							}},
+						},
+					},
+				}, gomp4.Context{})
+				require.NoError(t, err)
+
+				err = mrw.Write(&message.ExtendedSequenceStart{
+					ChunkStreamID:   message.VideoChunkStreamID,
+					MessageStreamID: 0x1000000,
+					FourCC:          message.FourCCHEVC,
+					Config:          hvcc.Bytes(),
+				})
+				require.NoError(t, err)
+
+				err = mrw.Write(&message.ExtendedCodedFrames{
+					ChunkStreamID:   message.VideoChunkStreamID,
+					MessageStreamID: 0x1000000,
+					FourCC:          message.FourCCHEVC,
+					DTS:             1 * time.Second,
+					Payload:         []byte{0x00, 0x00, 0x00, 0x01, 0x26},
+				})
+				require.NoError(t, err)
 			}
 
 			videoTrack, audioTrack, err := Read(mrw)
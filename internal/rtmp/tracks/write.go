@@ -1,7 +1,12 @@
 package tracks
 
 import (
+	"bytes"
+	"fmt"
+
+	gomp4 "github.com/abema/go-mp4"
 	"github.com/bluenviron/gortsplib/v3/pkg/formats"
+	"github.com/bluenviron/mediacommon/pkg/codecs/h265"
 	"github.com/notedit/rtmp/format/flv/flvio"
 
 	"github.com/aler9/mediamtx/internal/rtmp/h264conf"
@@ -28,6 +33,9 @@ func Write(w *message.ReadWriter, videoTrack formats.Format, audioTrack formats.
 						case *formats.H264:
 							return message.CodecH264
 
+						case *formats.H265:
+							return fourCCToFloat(message.FourCCHEVC)
+
 						default:
 							return 0
 						}
@@ -40,13 +48,19 @@ func Write(w *message.ReadWriter, videoTrack formats.Format, audioTrack formats.
 				{
 					K: "audiocodecid",
 					V: func() float64 {
-						switch audioTrack.(type) {
+						switch audioTrack := audioTrack.(type) {
 						case *formats.MPEG2Audio:
 							return message.CodecMPEG2Audio
 
 						case *formats.MPEG4Audio:
 							return message.CodecMPEG4Audio
 
+						case *formats.G711:
+							if audioTrack.MULaw {
+								return message.CodecPCMU
+							}
+							return message.CodecPCMA
+
 						default:
 							return 0
 						}
@@ -82,6 +96,27 @@ func Write(w *message.ReadWriter, videoTrack formats.Format, audioTrack formats.
 		}
 	}
 
+	if videoTrack, ok := videoTrack.(*formats.H265); ok {
+		// write decoder config only if VPS, SPS and PPS are available.
+		// if they're not available yet, they're sent later.
+		if vps, sps, pps := videoTrack.SafeParams(); vps != nil && sps != nil && pps != nil {
+			buf, err := H265Config(vps, sps, pps)
+			if err != nil {
+				return err
+			}
+
+			err = w.Write(&message.ExtendedSequenceStart{
+				ChunkStreamID:   message.VideoChunkStreamID,
+				MessageStreamID: 0x1000000,
+				FourCC:          message.FourCCHEVC,
+				Config:          buf,
+			})
+			if err != nil {
+				return err
+			}
+		}
+	}
+
 	if mpeg4audioTrack, ok := audioTrack.(*formats.MPEG4Audio); ok {
 		enc, err := mpeg4audioTrack.Config.Marshal()
 		if err != nil {
@@ -105,3 +140,66 @@ func Write(w *message.ReadWriter, videoTrack formats.Format, audioTrack formats.
 
 	return nil
 }
+
+// H265Config encodes H265 parameters into a decoder configuration record,
+// that is the payload of ExtendedSequenceStart messages.
+func H265Config(vps []byte, sps []byte, pps []byte) ([]byte, error) {
+	var spsp h265.SPS
+	err := spsp.Unmarshal(sps)
+	if err != nil {
+		return nil, fmt.Errorf("invalid H265 SPS: %v", err)
+	}
+
+	if len(sps) < 13 {
+		return nil, fmt.Errorf("invalid H265 SPS: not enough bytes")
+	}
+
+	var buf bytes.Buffer
+	_, err = gomp4.Marshal(&buf, &gomp4.HvcC{
+		ConfigurationVersion:        1,
+		GeneralProfileIdc:           spsp.ProfileTierLevel.GeneralProfileIdc,
+		GeneralProfileCompatibility: spsp.ProfileTierLevel.GeneralProfileCompatibilityFlag,
+		GeneralConstraintIndicator: [6]uint8{
+			sps[7], sps[8], sps[9],
+			sps[10], sps[11], sps[12],
+		},
+		GeneralLevelIdc:      spsp.ProfileTierLevel.GeneralLevelIdc,
+		ChromaFormatIdc:      uint8(spsp.ChromaFormatIdc),
+		BitDepthLumaMinus8:   uint8(spsp.BitDepthLumaMinus8),
+		BitDepthChromaMinus8: uint8(spsp.BitDepthChromaMinus8),
+		NumTemporalLayers:    1,
+		LengthSizeMinusOne:   3,
+		NumOfNaluArrays:      3,
+		NaluArrays: []gomp4.HEVCNaluArray{
+			{
+				NaluType: byte(h265.NALUType_VPS_NUT),
+				NumNalus: 1,
+				Nalus: []gomp4.HEVCNalu{{
+					Length:  uint16(len(vps)),
+					NALUnit: vps,
+				}},
+			},
+			{
+				NaluType: byte(h265.NALUType_SPS_NUT),
+				NumNalus: 1,
+				Nalus: []gomp4.HEVCNalu{{
+					Length:  uint16(len(sps)),
+					NALUnit: sps,
+				}},
+			},
+			{
+				NaluType: byte(h265.NALUType_PPS_NUT),
+				NumNalus: 1,
+				Nalus: []gomp4.HEVCNalu{{
+					Length:  uint16(len(pps)),
+					NALUnit: pps,
+				}},
+			},
+		},
+	}, gomp4.Context{})
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -94,3 +94,39 @@ func TestWrite(t *testing.T) {
 		Payload:         []byte{0x12, 0x10},
 	}, msg)
 }
+
+func TestWriteH265(t *testing.T) {
+	var buf bytes.Buffer
+	bc := bytecounter.NewReadWriter(&buf)
+	mrw := message.NewReadWriter(bc, true)
+
+	videoTrack := &formats.H265{
+		PayloadTyp: 96,
+		VPS: []byte{
+			0x40, 0x01, 0x0c, 0x01, 0xff, 0xff, 0x01, 0x40,
+			0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+			0x03, 0x00, 0x00, 0x03, 0x00, 0x7b, 0xac, 0x09,
+		},
+		SPS: []byte{
+			0x42, 0x01, 0x01, 0x01, 0x40, 0x00, 0x00, 0x03,
+			0x00, 0x00, 0x03, 0x00, 0x00, 0x03, 0x00, 0x00,
+			0x03, 0x00, 0x7b, 0xa0, 0x03, 0xc0, 0x80, 0x11,
+			0x07, 0xcb, 0x96, 0xb4, 0xa4, 0x25, 0x92, 0xe3,
+			0x01, 0x6a, 0x02, 0x02, 0x02, 0x08, 0x00, 0x00,
+			0x03, 0x00, 0x08, 0x00, 0x00, 0x03, 0x01, 0xe3,
+			0x00, 0x2e, 0xf2, 0x88, 0x00, 0x09, 0x89, 0x60,
+			0x00, 0x04, 0xc4, 0xb4, 0x20,
+		},
+		PPS: []byte{
+			0x44, 0x01, 0xc0, 0xf7, 0xc0, 0xcc, 0x90,
+		},
+	}
+
+	err := Write(mrw, videoTrack, nil)
+	require.NoError(t, err)
+
+	videoTrack2, audioTrack2, err := Read(mrw)
+	require.NoError(t, err)
+	require.Equal(t, videoTrack, videoTrack2)
+	require.Nil(t, audioTrack2)
+}
@@ -0,0 +1,70 @@
+package testsrc
+
+// bitWriter writes values bit by bit, MSB first.
+type bitWriter struct {
+	buf []byte
+	n   int // number of bits written into the last byte
+}
+
+func (w *bitWriter) writeBit(v uint64) {
+	if w.n == 0 {
+		w.buf = append(w.buf, 0)
+	}
+	w.buf[len(w.buf)-1] |= byte(v&1) << (7 - w.n)
+	w.n = (w.n + 1) % 8
+}
+
+func (w *bitWriter) writeBits(v uint64, n int) {
+	for i := n - 1; i >= 0; i-- {
+		w.writeBit(v >> i)
+	}
+}
+
+func (w *bitWriter) writeFlag(v bool) {
+	if v {
+		w.writeBit(1)
+	} else {
+		w.writeBit(0)
+	}
+}
+
+// writeUE writes an unsigned Exp-Golomb code.
+func (w *bitWriter) writeUE(v uint32) {
+	v1 := uint64(v) + 1
+	size := 0
+	for tmp := v1; tmp != 0; tmp >>= 1 {
+		size++
+	}
+	w.writeBits(0, size-1)
+	w.writeBits(v1, size)
+}
+
+// writeSE writes a signed Exp-Golomb code.
+func (w *bitWriter) writeSE(v int32) {
+	if v > 0 {
+		w.writeUE(uint32(2*v - 1))
+	} else {
+		w.writeUE(uint32(-2 * v))
+	}
+}
+
+// align writes zero bits until the byte boundary.
+func (w *bitWriter) align() {
+	w.n = 0
+}
+
+// writeBytes writes bytes. The writer must be aligned.
+func (w *bitWriter) writeBytes(buf []byte) {
+	w.buf = append(w.buf, buf...)
+}
+
+// writeTrailingBits writes the RBSP trailing bits.
+func (w *bitWriter) writeTrailingBits() {
+	w.writeBit(1)
+	w.align()
+}
+
+// bytes returns written bytes.
+func (w *bitWriter) bytes() []byte {
+	return w.buf
+}
@@ -0,0 +1,515 @@
+// Package testsrc contains a generator of a H264 test pattern.
+package testsrc
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	// layout of the timestamp that is burned into frames.
+	// Every character occupies a macroblock.
+	textLayout = "15:04:05.000"
+
+	// size of a I_PCM macroblock (4:2:0, 8 bit).
+	pcmSize = 16*16 + 2*8*8
+
+	log2MaxFrameNum = 8
+
+	// mb_type of I_PCM in I slices and in P slices.
+	mbTypeIPCM  = 25
+	mbTypePIPCM = 5 + 25
+
+	// mb_type of a Intra_16x16 macroblock with vertical prediction and without residual.
+	mbTypeI16x16Vertical = 1
+	chromaPredVertical   = 2
+)
+
+// colors of the bars (75% color bars, BT.601), in Y, Cb, Cr format.
+var barColors = [][3]byte{
+	{180, 128, 128}, // white
+	{162, 44, 142},  // yellow
+	{131, 156, 44},  // cyan
+	{112, 72, 58},   // green
+	{84, 184, 198},  // magenta
+	{65, 100, 212},  // red
+	{35, 212, 114},  // blue
+	{16, 128, 128},  // black
+}
+
+var (
+	textColor       = [3]byte{235, 128, 128}
+	backgroundColor = [3]byte{16, 128, 128}
+)
+
+// H264 levels, with the maximum macroblock processing rate and frame size.
+var levels = []struct {
+	idc   byte
+	maxMB int
+	maxFS int
+}{
+	{30, 40500, 1620},
+	{31, 108000, 3600},
+	{32, 216000, 5120},
+	{41, 245760, 8192},
+	{42, 522240, 8704},
+	{50, 589824, 22080},
+	{51, 983040, 36864},
+	{52, 2073600, 36864},
+}
+
+// Params are the parameters of a Encoder.
+type Params struct {
+	Width   int
+	Height  int
+	FPS     int
+	Bitrate int
+}
+
+// Encoder generates H264 frames that contain color bars and a timestamp.
+//
+// Frames are not encoded from pictures; they are built from pre-encoded macroblocks:
+//   - the first row contains the timestamp, encoded with I_PCM macroblocks;
+//   - the second row contains the top of the bars, encoded with I_PCM macroblocks;
+//   - other rows are Intra_16x16 macroblocks that copy the rows above them.
+//
+// The pattern is sent once per second as a IDR frame; following frames are P frames
+// that contain only the characters of the timestamp that changed, while other macroblocks are skipped.
+// Filler data is appended in order to reach the requested bitrate.
+type Encoder struct {
+	params   Params
+	mbWidth  int
+	mbHeight int
+	sps      []byte
+	pps      []byte
+	glyphs   map[byte][]byte
+	idrTail  []byte
+
+	frameCount int
+	frameNum   uint32
+	idrPicID   uint32
+	prevText   string
+	balance    float64
+}
+
+// NewEncoder allocates a Encoder.
+func NewEncoder(params Params) (*Encoder, error) {
+	if params.Width < len(textLayout)*16 || (params.Width%2) != 0 {
+		return nil, fmt.Errorf("width must be an even number greater or equal than %d", len(textLayout)*16)
+	}
+
+	if params.Height < 32 || (params.Height%2) != 0 {
+		return nil, fmt.Errorf("height must be an even number greater or equal than 32")
+	}
+
+	if params.FPS <= 0 {
+		return nil, fmt.Errorf("invalid FPS")
+	}
+
+	e := &Encoder{
+		params:   params,
+		mbWidth:  (params.Width + 15) / 16,
+		mbHeight: (params.Height + 15) / 16,
+	}
+
+	level, err := e.level()
+	if err != nil {
+		return nil, err
+	}
+
+	e.sps = e.marshalSPS(level)
+	e.pps = e.marshalPPS()
+	e.glyphs = make(map[byte][]byte)
+	for c := range font {
+		e.glyphs[c] = e.renderGlyph(c)
+	}
+	e.idrTail = e.marshalIDRTail()
+
+	return e, nil
+}
+
+func (e *Encoder) level() (byte, error) {
+	fs := e.mbWidth * e.mbHeight
+
+	for _, l := range levels {
+		if fs <= l.maxFS && fs*e.params.FPS <= l.maxMB {
+			return l.idc, nil
+		}
+	}
+
+	return 0, fmt.Errorf("resolution and FPS are too high")
+}
+
+// SPS returns the sequence parameter set.
+func (e *Encoder) SPS() []byte {
+	return e.sps
+}
+
+// PPS returns the picture parameter set.
+func (e *Encoder) PPS() []byte {
+	return e.pps
+}
+
+func (e *Encoder) marshalSPS(level byte) []byte {
+	w := &bitWriter{}
+
+	w.writeBits(66, 8)   // profile_idc (baseline)
+	w.writeBits(0xC0, 8) // constraint_set0_flag, constraint_set1_flag (constrained baseline)
+	w.writeBits(uint64(level), 8)
+	w.writeUE(0)                   // seq_parameter_set_id
+	w.writeUE(log2MaxFrameNum - 4) // log2_max_frame_num_minus4
+	w.writeUE(2)                   // pic_order_cnt_type (output order = decoding order)
+	w.writeUE(1)                   // max_num_ref_frames
+	w.writeFlag(false)             // gaps_in_frame_num_value_allowed_flag
+	w.writeUE(uint32(e.mbWidth - 1))
+	w.writeUE(uint32(e.mbHeight - 1))
+	w.writeFlag(true) // frame_mbs_only_flag
+	w.writeFlag(true) // direct_8x8_inference_flag
+
+	cropRight := (e.mbWidth*16 - e.params.Width) / 2
+	cropBottom := (e.mbHeight*16 - e.params.Height) / 2
+	if cropRight != 0 || cropBottom != 0 {
+		w.writeFlag(true)
+		w.writeUE(0)
+		w.writeUE(uint32(cropRight))
+		w.writeUE(0)
+		w.writeUE(uint32(cropBottom))
+	} else {
+		w.writeFlag(false)
+	}
+
+	w.writeFlag(true)  // vui_parameters_present_flag
+	w.writeFlag(false) // aspect_ratio_info_present_flag
+	w.writeFlag(false) // overscan_info_present_flag
+	w.writeFlag(false) // video_signal_type_present_flag
+	w.writeFlag(false) // chroma_loc_info_present_flag
+	w.writeFlag(true)  // timing_info_present_flag
+	w.writeBits(1, 32) // num_units_in_tick
+	w.writeBits(uint64(e.params.FPS*2), 32)
+	w.writeFlag(true)  // fixed_frame_rate_flag
+	w.writeFlag(false) // nal_hrd_parameters_present_flag
+	w.writeFlag(false) // vcl_hrd_parameters_present_flag
+	w.writeFlag(false) // pic_struct_present_flag
+	w.writeFlag(true)  // bitstream_restriction_flag
+	w.writeFlag(true)  // motion_vectors_over_pic_boundaries_flag
+	w.writeUE(0)       // max_bytes_per_pic_denom
+	w.writeUE(0)       // max_bits_per_mb_denom
+	w.writeUE(16)      // log2_max_mv_length_horizontal
+	w.writeUE(16)      // log2_max_mv_length_vertical
+	w.writeUE(0)       // max_num_reorder_frames
+	w.writeUE(1)       // max_dec_frame_buffering
+
+	w.writeTrailingBits()
+
+	return marshalNALU(0x67, w.bytes())
+}
+
+func (e *Encoder) marshalPPS() []byte {
+	w := &bitWriter{}
+
+	w.writeUE(0)       // pic_parameter_set_id
+	w.writeUE(0)       // seq_parameter_set_id
+	w.writeFlag(false) // entropy_coding_mode_flag (CAVLC)
+	w.writeFlag(false) // bottom_field_pic_order_in_frame_present_flag
+	w.writeUE(0)       // num_slice_groups_minus1
+	w.writeUE(0)       // num_ref_idx_l0_default_active_minus1
+	w.writeUE(0)       // num_ref_idx_l1_default_active_minus1
+	w.writeFlag(false) // weighted_pred_flag
+	w.writeBits(0, 2)  // weighted_bipred_idc
+	w.writeSE(0)       // pic_init_qp_minus26
+	w.writeSE(0)       // pic_init_qs_minus26
+	w.writeSE(0)       // chroma_qp_index_offset
+	w.writeFlag(true)  // deblocking_filter_control_present_flag
+	w.writeFlag(false) // constrained_intra_pred_flag
+	w.writeFlag(false) // redundant_pic_cnt_present_flag
+
+	w.writeTrailingBits()
+
+	return marshalNALU(0x68, w.bytes())
+}
+
+// renderGlyph returns the samples of a I_PCM macroblock that contains a character.
+func (e *Encoder) renderGlyph(c byte) []byte {
+	mb := make([]byte, pcmSize)
+	bitmap := font[c]
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			// characters are scaled by 2 and centered.
+			gx := (x - 3) / 2
+			gy := (y - 1) / 2
+
+			if x >= 3 && gx < 5 && y >= 1 && gy < 7 && (bitmap[gy]>>(4-gx))&1 != 0 {
+				mb[y*16+x] = textColor[0]
+			} else {
+				mb[y*16+x] = backgroundColor[0]
+			}
+		}
+	}
+
+	for i := 256; i < pcmSize; i++ {
+		mb[i] = 128
+	}
+
+	return mb
+}
+
+// renderBars returns the samples of a I_PCM macroblock that contains the bars.
+func (e *Encoder) renderBars(mbX int) []byte {
+	mb := make([]byte, pcmSize)
+
+	barAt := func(x int) [3]byte {
+		i := x * len(barColors) / e.params.Width
+		if i >= len(barColors) {
+			i = len(barColors) - 1
+		}
+		return barColors[i]
+	}
+
+	for y := 0; y < 16; y++ {
+		for x := 0; x < 16; x++ {
+			mb[y*16+x] = barAt(mbX*16 + x)[0]
+		}
+	}
+
+	for y := 0; y < 8; y++ {
+		for x := 0; x < 8; x++ {
+			color := barAt(mbX*16 + x*2)
+			mb[256+y*8+x] = color[1]
+			mb[256+64+y*8+x] = color[2]
+		}
+	}
+
+	return mb
+}
+
+// marshalIDRTail encodes the macroblocks of the IDR frame that follow the timestamp.
+// Since the timestamp is made of I_PCM macroblocks, the result starts at a byte boundary.
+func (e *Encoder) marshalIDRTail() []byte {
+	w := &bitWriter{}
+
+	background := make([]byte, pcmSize)
+	for i := range background {
+		if i < 256 {
+			background[i] = backgroundColor[0]
+		} else {
+			background[i] = 128
+		}
+	}
+
+	for i := len(textLayout); i < e.mbWidth*e.mbHeight; i++ {
+		x := i % e.mbWidth
+		y := i / e.mbWidth
+
+		switch y {
+		case 0:
+			w.writeUE(mbTypeIPCM)
+			w.align()
+			w.writeBytes(background)
+
+		case 1:
+			w.writeUE(mbTypeIPCM)
+			w.align()
+			w.writeBytes(e.renderBars(x))
+
+		default:
+			w.writeUE(mbTypeI16x16Vertical)
+			w.writeUE(chromaPredVertical) // intra_chroma_pred_mode
+			w.writeSE(0)                  // mb_qp_delta
+			writeZeroCoeffToken(w, e.nC(x, y))
+		}
+	}
+
+	w.writeTrailingBits()
+
+	return w.bytes()
+}
+
+// nC returns the context of the coeff_token of the Intra16x16DCLevel block of a macroblock
+// of the IDR frame. The first two rows contain I_PCM macroblocks, which count as 16 coefficients,
+// while other macroblocks have no coefficients.
+func (e *Encoder) nC(x int, y int) int {
+	coeffs := func(y int) int {
+		if y < 2 {
+			return 16
+		}
+		return 0
+	}
+
+	availableA := (x > 0)
+	availableB := (y > 0)
+
+	switch {
+	case availableA && availableB:
+		return (coeffs(y) + coeffs(y-1) + 1) >> 1
+	case availableA:
+		return coeffs(y)
+	case availableB:
+		return coeffs(y - 1)
+	}
+	return 0
+}
+
+// writeZeroCoeffToken writes a coeff_token with TotalCoeff = 0 and TrailingOnes = 0.
+func writeZeroCoeffToken(w *bitWriter, nC int) {
+	switch {
+	case nC < 2:
+		w.writeBits(0b1, 1)
+	case nC < 4:
+		w.writeBits(0b11, 2)
+	case nC < 8:
+		w.writeBits(0b1111, 4)
+	default:
+		w.writeBits(0b000011, 6)
+	}
+}
+
+func (e *Encoder) writeSliceHeader(w *bitWriter, idr bool) {
+	w.writeUE(0) // first_mb_in_slice
+	if idr {
+		w.writeUE(7) // slice_type (I)
+	} else {
+		w.writeUE(5) // slice_type (P)
+	}
+	w.writeUE(0) // pic_parameter_set_id
+	w.writeBits(uint64(e.frameNum), log2MaxFrameNum)
+
+	if idr {
+		w.writeUE(e.idrPicID)
+	} else {
+		w.writeFlag(false) // num_ref_idx_active_override_flag
+		w.writeFlag(false) // ref_pic_list_modification_flag_l0
+	}
+
+	if idr {
+		w.writeFlag(false) // no_output_of_prior_pics_flag
+		w.writeFlag(false) // long_term_reference_flag
+	} else {
+		w.writeFlag(false) // adaptive_ref_pic_marking_mode_flag
+	}
+
+	w.writeSE(0) // slice_qp_delta
+	w.writeUE(1) // disable_deblocking_filter_idc
+}
+
+func (e *Encoder) marshalIDR(text string) []byte {
+	w := &bitWriter{}
+	e.writeSliceHeader(w, true)
+
+	for i := 0; i < len(text); i++ {
+		w.writeUE(mbTypeIPCM)
+		w.align()
+		w.writeBytes(e.glyphs[text[i]])
+	}
+
+	w.writeBytes(e.idrTail)
+
+	return marshalNALU(0x65, w.bytes())
+}
+
+func (e *Encoder) marshalP(text string) []byte {
+	w := &bitWriter{}
+	e.writeSliceHeader(w, false)
+
+	skipRun := 0
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == e.prevText[i] {
+			skipRun++
+			continue
+		}
+
+		w.writeUE(uint32(skipRun))
+		skipRun = 0
+		w.writeUE(mbTypePIPCM)
+		w.align()
+		w.writeBytes(e.glyphs[text[i]])
+	}
+
+	skipRun += e.mbWidth*e.mbHeight - len(text)
+	w.writeUE(uint32(skipRun))
+
+	w.writeTrailingBits()
+
+	return marshalNALU(0x41, w.bytes())
+}
+
+// Encode generates a frame that contains the given time.
+// It returns the NALUs of the frame.
+func (e *Encoder) Encode(t time.Time) [][]byte {
+	text := t.Format(textLayout)
+	var au [][]byte
+
+	if (e.frameCount % e.params.FPS) == 0 {
+		e.frameNum = 0
+		au = [][]byte{e.sps, e.pps, e.marshalIDR(text)}
+		e.idrPicID = (e.idrPicID + 1) % 2
+	} else {
+		au = [][]byte{e.marshalP(text)}
+	}
+
+	e.frameCount++
+	e.frameNum = (e.frameNum + 1) % (1 << log2MaxFrameNum)
+	e.prevText = text
+
+	if e.params.Bitrate > 0 {
+		au = e.fill(au)
+	}
+
+	return au
+}
+
+// fill appends filler data in order to reach the bitrate.
+func (e *Encoder) fill(au [][]byte) [][]byte {
+	perFrame := float64(e.params.Bitrate) / 8 / float64(e.params.FPS)
+	e.balance += perFrame
+
+	for _, nalu := range au {
+		e.balance -= float64(len(nalu))
+	}
+
+	// do not accumulate more than a second of excess.
+	if min := -perFrame * float64(e.params.FPS); e.balance < min {
+		e.balance = min
+	}
+
+	if e.balance < 2 {
+		return au
+	}
+
+	n := int(e.balance)
+	e.balance -= float64(n)
+
+	filler := make([]byte, n)
+	filler[0] = 0x0C // filler data
+	for i := 1; i < n-1; i++ {
+		filler[i] = 0xFF
+	}
+	filler[n-1] = 0x80 // rbsp_trailing_bits
+
+	return append(au, filler)
+}
+
+// marshalNALU encodes a NALU, by adding emulation prevention bytes to the payload.
+func marshalNALU(header byte, payload []byte) []byte {
+	ret := make([]byte, 0, 1+len(payload)+len(payload)/100)
+	ret = append(ret, header)
+	zeros := 0
+
+	for _, b := range payload {
+		if zeros >= 2 && b <= 3 {
+			ret = append(ret, 3)
+			zeros = 0
+		}
+
+		ret = append(ret, b)
+
+		if b == 0 {
+			zeros++
+		} else {
+			zeros = 0
+		}
+	}
+
+	return ret
+}
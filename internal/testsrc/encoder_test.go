@@ -0,0 +1,61 @@
+package testsrc
+
+import (
+	"testing"
+	"time"
+
+	"github.com/bluenviron/mediacommon/pkg/codecs/h264"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEncoderParams(t *testing.T) {
+	e, err := NewEncoder(Params{Width: 1918, Height: 1080, FPS: 25})
+	require.NoError(t, err)
+
+	var sps h264.SPS
+	err = sps.Unmarshal(e.SPS())
+	require.NoError(t, err)
+	require.Equal(t, 1918, sps.Width())
+	require.Equal(t, 1080, sps.Height())
+	require.Equal(t, float64(25), sps.FPS())
+	require.Equal(t, uint8(41), sps.LevelIdc)
+
+	_, err = NewEncoder(Params{Width: 100, Height: 1080, FPS: 25})
+	require.Error(t, err)
+}
+
+func TestEncoderFrames(t *testing.T) {
+	e, err := NewEncoder(Params{Width: 640, Height: 480, FPS: 10, Bitrate: 1000000})
+	require.NoError(t, err)
+
+	start := time.Date(2023, 5, 1, 10, 0, 0, 0, time.UTC)
+	size := 0
+
+	for i := 0; i < 20; i++ {
+		au := e.Encode(start.Add(time.Duration(i) * 100 * time.Millisecond))
+
+		var types []h264.NALUType
+		for _, nalu := range au {
+			types = append(types, h264.NALUType(nalu[0]&0x1F))
+			size += len(nalu)
+		}
+
+		if (i % 10) == 0 {
+			require.Equal(t, []h264.NALUType{
+				h264.NALUTypeSPS,
+				h264.NALUTypePPS,
+				h264.NALUTypeIDR,
+			}, types[:3])
+			types = types[3:]
+		} else {
+			require.Equal(t, h264.NALUTypeNonIDR, types[0])
+			types = types[1:]
+		}
+
+		for _, typ := range types {
+			require.Equal(t, h264.NALUTypeFillerData, typ)
+		}
+	}
+
+	require.InDelta(t, 1000000*2/8, size, 2)
+}
@@ -23,13 +23,18 @@ var upgrader = websocket.Upgrader{
 	},
 }
 
+type message struct {
+	typ  int
+	byts []byte
+}
+
 // ServerConn is a server-side WebSocket connection with automatic, periodic ping / pong.
 type ServerConn struct {
 	wc *websocket.Conn
 
 	// in
 	terminate chan struct{}
-	write     chan []byte
+	write     chan message
 
 	// out
 	writeErr chan error
@@ -45,7 +50,7 @@ func NewServerConn(w http.ResponseWriter, req *http.Request) (*ServerConn, error
 	c := &ServerConn{
 		wc:        wc,
 		terminate: make(chan struct{}),
-		write:     make(chan []byte),
+		write:     make(chan message),
 		writeErr:  make(chan error),
 	}
 
@@ -78,9 +83,9 @@ func (c *ServerConn) run() {
 
 	for {
 		select {
-		case byts := <-c.write:
+		case msg := <-c.write:
 			c.wc.SetWriteDeadline(time.Now().Add(writeTimeout))
-			err := c.wc.WriteMessage(websocket.TextMessage, byts)
+			err := c.wc.WriteMessage(msg.typ, msg.byts)
 			c.writeErr <- err
 
 		case <-pingTicker.C:
@@ -105,8 +110,23 @@ func (c *ServerConn) WriteJSON(in interface{}) error {
 		return err
 	}
 
+	return c.writeMessage(message{websocket.TextMessage, byts})
+}
+
+// ReadMessage reads the content of a text or binary message.
+func (c *ServerConn) ReadMessage() ([]byte, error) {
+	_, byts, err := c.wc.ReadMessage()
+	return byts, err
+}
+
+// WriteBinary writes a binary message.
+func (c *ServerConn) WriteBinary(byts []byte) error {
+	return c.writeMessage(message{websocket.BinaryMessage, byts})
+}
+
+func (c *ServerConn) writeMessage(msg message) error {
 	select {
-	case c.write <- byts:
+	case c.write <- msg:
 		return <-c.writeErr
 	case <-c.terminate:
 		return fmt.Errorf("terminated")
@@ -0,0 +1,412 @@
+// Package zeroconf contains a minimal DNS-SD/mDNS responder.
+package zeroconf
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+
+	"golang.org/x/net/dns/dnsmessage"
+	"golang.org/x/net/ipv4"
+)
+
+const (
+	// time-to-live of host and service records, as suggested by RFC 6762.
+	hostTTL = 120
+
+	// time-to-live of other records, as suggested by RFC 6762.
+	otherTTL = 4500
+
+	// top bit of the class of unique records, that tells receivers to flush their cache.
+	cacheFlush = 1 << 15
+
+	// top bit of the class of questions, that asks for an unicast response.
+	unicastResponse = 1 << 15
+
+	servicesName = "_services._dns-sd._udp.local."
+)
+
+var groupAddr = &net.UDPAddr{IP: net.IPv4(224, 0, 0, 251), Port: 5353}
+
+// Service is a service instance that is advertised.
+type Service struct {
+	// instance name, i.e. "mystream".
+	Instance string
+
+	// service type, i.e. "_rtsp._tcp".
+	Type string
+
+	// port of the service.
+	Port uint16
+
+	// TXT record, in the key=value format.
+	Text []string
+}
+
+func (s Service) typeName() string {
+	return s.Type + ".local."
+}
+
+func (s Service) instanceName() string {
+	return InstanceName(s.Instance) + "." + s.typeName()
+}
+
+// InstanceName converts an arbitrary string into a valid instance name.
+func InstanceName(v string) string {
+	v = strings.NewReplacer(".", "_", "\\", "_").Replace(v)
+
+	// labels are limited to 63 bytes
+	if len(v) > 63 {
+		v = v[:63]
+	}
+
+	return v
+}
+
+// HostName converts an arbitrary string into a valid host name.
+func HostName(v string) string {
+	v = strings.TrimSuffix(v, ".local")
+
+	// keep the first label only
+	if i := strings.IndexByte(v, '.'); i >= 0 {
+		v = v[:i]
+	}
+
+	return InstanceName(v)
+}
+
+// Server is a mDNS responder that advertises services with DNS-SD.
+type Server struct {
+	hostName string
+
+	conn *ipv4.PacketConn
+
+	mutex    sync.Mutex
+	services map[string]Service
+
+	done chan struct{}
+}
+
+// New allocates a Server.
+// hostName is the name of the host, without the ".local" suffix.
+func New(hostName string) (*Server, error) {
+	if hostName == "" {
+		return nil, fmt.Errorf("host name is empty")
+	}
+
+	pc, err := net.ListenMulticastUDP("udp4", nil, groupAddr)
+	if err != nil {
+		return nil, err
+	}
+
+	conn := ipv4.NewPacketConn(pc)
+
+	// join the group on every interface that supports multicast
+	if intfs, err := net.Interfaces(); err == nil {
+		for _, intf := range intfs {
+			if intf.Flags&net.FlagUp != 0 && intf.Flags&net.FlagMulticast != 0 {
+				intf := intf
+				conn.JoinGroup(&intf, groupAddr) //nolint:errcheck
+			}
+		}
+	}
+
+	conn.SetMulticastLoopback(true) //nolint:errcheck
+
+	s := &Server{
+		hostName: HostName(hostName) + ".local.",
+		conn:     conn,
+		services: make(map[string]Service),
+		done:     make(chan struct{}),
+	}
+
+	go s.run()
+
+	return s, nil
+}
+
+// Close closes the Server, sending a goodbye message for every advertised service.
+func (s *Server) Close() {
+	s.mutex.Lock()
+	for key, svc := range s.services {
+		s.send(s.announcement(svc, 0))
+		delete(s.services, key)
+	}
+	s.mutex.Unlock()
+
+	s.conn.Close()
+	<-s.done
+}
+
+// Add advertises a service, replacing any service with the same instance name and type.
+func (s *Server) Add(svc Service) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.services[svc.instanceName()] = svc
+	s.send(s.announcement(svc, 1))
+}
+
+// Remove stops advertising a service.
+func (s *Server) Remove(instance string, typ string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	key := Service{Instance: instance, Type: typ}.instanceName()
+
+	svc, ok := s.services[key]
+	if !ok {
+		return
+	}
+
+	delete(s.services, key)
+	s.send(s.announcement(svc, 0))
+}
+
+func (s *Server) run() {
+	defer close(s.done)
+
+	buf := make([]byte, 9000)
+
+	for {
+		n, _, src, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+
+		var query dnsmessage.Message
+		err = query.Unpack(buf[:n])
+		if err != nil || query.Header.Response {
+			continue
+		}
+
+		s.mutex.Lock()
+		res := s.response(&query)
+		s.mutex.Unlock()
+
+		if res == nil {
+			continue
+		}
+
+		srcAddr, ok := src.(*net.UDPAddr)
+
+		// legacy unicast queries, that must be answered directly, with the same ID and questions
+		if ok && srcAddr.Port != groupAddr.Port {
+			res.Header.ID = query.Header.ID
+			res.Questions = query.Questions
+			byts, err := res.Pack()
+			if err == nil {
+				s.conn.WriteTo(byts, nil, srcAddr) //nolint:errcheck
+			}
+			continue
+		}
+
+		s.send(res)
+	}
+}
+
+func (s *Server) send(msg *dnsmessage.Message) {
+	byts, err := msg.Pack()
+	if err != nil {
+		return
+	}
+
+	s.conn.WriteTo(byts, nil, groupAddr) //nolint:errcheck
+}
+
+// announcement returns a message that contains all records of a service.
+// A TTL multiplier of zero produces a goodbye message.
+func (s *Server) announcement(svc Service, ttlMul uint32) *dnsmessage.Message {
+	msg := &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+	}
+
+	msg.Answers = append(msg.Answers, s.ptrRecord(svc, otherTTL*ttlMul))
+	msg.Answers = append(msg.Answers, s.srvRecord(svc, hostTTL*ttlMul))
+	msg.Answers = append(msg.Answers, s.txtRecord(svc, otherTTL*ttlMul))
+
+	if ttlMul != 0 {
+		msg.Answers = append(msg.Answers, s.addressRecords()...)
+	}
+
+	return msg
+}
+
+// response returns the response to a query, or nil if the query is not directed to the Server.
+func (s *Server) response(query *dnsmessage.Message) *dnsmessage.Message {
+	msg := &dnsmessage.Message{
+		Header: dnsmessage.Header{Response: true, Authoritative: true},
+	}
+
+	var additionals []dnsmessage.Resource
+	addAdditionals := func(svc Service, withPTR bool) {
+		if withPTR {
+			additionals = append(additionals, s.ptrRecord(svc, otherTTL))
+		}
+		additionals = append(additionals, s.srvRecord(svc, hostTTL))
+		additionals = append(additionals, s.txtRecord(svc, otherTTL))
+	}
+
+	for _, q := range query.Questions {
+		if q.Class&^unicastResponse != dnsmessage.ClassINET && q.Class&^unicastResponse != dnsmessage.ClassANY {
+			continue
+		}
+
+		name := strings.ToLower(q.Name.String())
+		isType := func(t dnsmessage.Type) bool {
+			return q.Type == t || q.Type == dnsmessage.TypeALL
+		}
+
+		switch {
+		case name == servicesName && isType(dnsmessage.TypePTR):
+			types := make(map[string]struct{})
+			for _, svc := range s.services {
+				if _, ok := types[svc.typeName()]; ok {
+					continue
+				}
+				types[svc.typeName()] = struct{}{}
+
+				msg.Answers = append(msg.Answers, dnsmessage.Resource{
+					Header: dnsmessage.ResourceHeader{
+						Name:  dnsmessage.MustNewName(servicesName),
+						Type:  dnsmessage.TypePTR,
+						Class: dnsmessage.ClassINET,
+						TTL:   otherTTL,
+					},
+					Body: &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(svc.typeName())},
+				})
+			}
+
+		case name == strings.ToLower(s.hostName) && isType(dnsmessage.TypeA):
+			msg.Answers = append(msg.Answers, s.addressRecords()...)
+
+		default:
+			for _, svc := range s.services {
+				switch {
+				case name == strings.ToLower(svc.typeName()) && isType(dnsmessage.TypePTR):
+					msg.Answers = append(msg.Answers, s.ptrRecord(svc, otherTTL))
+					addAdditionals(svc, false)
+
+				case name == strings.ToLower(svc.instanceName()):
+					if isType(dnsmessage.TypeSRV) {
+						msg.Answers = append(msg.Answers, s.srvRecord(svc, hostTTL))
+					}
+					if isType(dnsmessage.TypeTXT) {
+						msg.Answers = append(msg.Answers, s.txtRecord(svc, otherTTL))
+					}
+				}
+			}
+		}
+	}
+
+	if len(msg.Answers) == 0 {
+		return nil
+	}
+
+	if additionals != nil {
+		msg.Additionals = append(additionals, s.addressRecords()...)
+	}
+
+	return msg
+}
+
+func (s *Server) ptrRecord(svc Service, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(svc.typeName()),
+			Type:  dnsmessage.TypePTR,
+			Class: dnsmessage.ClassINET,
+			TTL:   ttl,
+		},
+		Body: &dnsmessage.PTRResource{PTR: dnsmessage.MustNewName(svc.instanceName())},
+	}
+}
+
+func (s *Server) srvRecord(svc Service, ttl uint32) dnsmessage.Resource {
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(svc.instanceName()),
+			Type:  dnsmessage.TypeSRV,
+			Class: dnsmessage.ClassINET | cacheFlush,
+			TTL:   ttl,
+		},
+		Body: &dnsmessage.SRVResource{
+			Target: dnsmessage.MustNewName(s.hostName),
+			Port:   svc.Port,
+		},
+	}
+}
+
+func (s *Server) txtRecord(svc Service, ttl uint32) dnsmessage.Resource {
+	txt := svc.Text
+	if len(txt) == 0 {
+		// a TXT record must contain at least one string
+		txt = []string{""}
+	}
+
+	return dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{
+			Name:  dnsmessage.MustNewName(svc.instanceName()),
+			Type:  dnsmessage.TypeTXT,
+			Class: dnsmessage.ClassINET | cacheFlush,
+			TTL:   ttl,
+		},
+		Body: &dnsmessage.TXTResource{TXT: txt},
+	}
+}
+
+func (s *Server) addressRecords() []dnsmessage.Resource {
+	var ret []dnsmessage.Resource
+
+	for _, ip := range localIPs() {
+		var a [4]byte
+		copy(a[:], ip)
+
+		ret = append(ret, dnsmessage.Resource{
+			Header: dnsmessage.ResourceHeader{
+				Name:  dnsmessage.MustNewName(s.hostName),
+				Type:  dnsmessage.TypeA,
+				Class: dnsmessage.ClassINET | cacheFlush,
+				TTL:   hostTTL,
+			},
+			Body: &dnsmessage.AResource{A: a},
+		})
+	}
+
+	return ret
+}
+
+// localIPs returns the IPv4 addresses of the host, excluding loopback ones when possible.
+func localIPs() []net.IP {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil
+	}
+
+	var ret []net.IP
+	var loopback []net.IP
+
+	for _, addr := range addrs {
+		ipnet, ok := addr.(*net.IPNet)
+		if !ok {
+			continue
+		}
+
+		ip := ipnet.IP.To4()
+		if ip == nil {
+			continue
+		}
+
+		if ip.IsLoopback() {
+			loopback = append(loopback, ip)
+		} else {
+			ret = append(ret, ip)
+		}
+	}
+
+	if ret == nil {
+		return loopback
+	}
+	return ret
+}
@@ -0,0 +1,74 @@
+package zeroconf
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+func TestInstanceName(t *testing.T) {
+	require.Equal(t, "my/stream_1", InstanceName("my/stream.1"))
+	require.Equal(t, 63, len(InstanceName(string(make([]byte, 100)))))
+	require.Equal(t, "myhost", HostName("myhost.example.com"))
+	require.Equal(t, "myhost", HostName("myhost.local"))
+}
+
+func TestServerResponse(t *testing.T) {
+	s := &Server{
+		hostName: "myhost.local.",
+		services: make(map[string]Service),
+	}
+
+	svc := Service{
+		Instance: "my/stream",
+		Type:     "_rtsp._tcp",
+		Port:     8554,
+		Text:     []string{"path=/my/stream", "tracks=H264"},
+	}
+	s.services[svc.instanceName()] = svc
+
+	query := func(name string, typ dnsmessage.Type) *dnsmessage.Message {
+		return s.response(&dnsmessage.Message{
+			Questions: []dnsmessage.Question{{
+				Name:  dnsmessage.MustNewName(name),
+				Type:  typ,
+				Class: dnsmessage.ClassINET | unicastResponse,
+			}},
+		})
+	}
+
+	res := query("_services._dns-sd._udp.local.", dnsmessage.TypePTR)
+	require.NotNil(t, res)
+	require.Equal(t, 1, len(res.Answers))
+	require.Equal(t, "_rtsp._tcp.local.", res.Answers[0].Body.(*dnsmessage.PTRResource).PTR.String())
+
+	res = query("_rtsp._tcp.local.", dnsmessage.TypePTR)
+	require.NotNil(t, res)
+	require.Equal(t, 1, len(res.Answers))
+	require.Equal(t, "my/stream._rtsp._tcp.local.", res.Answers[0].Body.(*dnsmessage.PTRResource).PTR.String())
+	require.GreaterOrEqual(t, len(res.Additionals), 2)
+
+	srv := res.Additionals[0].Body.(*dnsmessage.SRVResource)
+	require.Equal(t, "myhost.local.", srv.Target.String())
+	require.Equal(t, uint16(8554), srv.Port)
+
+	txt := res.Additionals[1].Body.(*dnsmessage.TXTResource)
+	require.Equal(t, []string{"path=/my/stream", "tracks=H264"}, txt.TXT)
+
+	res = query("My/Stream._rtsp._tcp.local.", dnsmessage.TypeTXT)
+	require.NotNil(t, res)
+	require.Equal(t, 1, len(res.Answers))
+	require.Equal(t, dnsmessage.TypeTXT, res.Answers[0].Header.Type)
+
+	_, err := res.Pack()
+	require.NoError(t, err)
+
+	res = query("_http._tcp.local.", dnsmessage.TypePTR)
+	require.Nil(t, res)
+
+	goodbye := s.announcement(svc, 0)
+	for _, ans := range goodbye.Answers {
+		require.Equal(t, uint32(0), ans.Header.TTL)
+	}
+}
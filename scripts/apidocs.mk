@@ -19,3 +19,7 @@ apidocs-gen:
 	echo "$$DOCKERFILE_APIDOCS_GEN" | docker build . -f - -t temp
 	docker run --rm -v $(PWD)/apidocs:/s -w /s temp \
 	sh -c "redoc-cli bundle openapi.yaml"
+
+apidocs-sdk:
+	docker run --rm -v $(PWD)/apidocs:/s -w /s $(OPENAPI_GENERATOR_IMAGE) \
+	generate -i openapi.yaml -g $(LANG) -o sdk/$(LANG)
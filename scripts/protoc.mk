@@ -0,0 +1,13 @@
+define DOCKERFILE_PROTOC
+FROM $(BASE_IMAGE)
+RUN apk add --no-cache protobuf-dev
+RUN go install google.golang.org/protobuf/cmd/protoc-gen-go@v1.30.0
+RUN go install google.golang.org/grpc/cmd/protoc-gen-go-grpc@v1.3.0
+endef
+export DOCKERFILE_PROTOC
+
+protoc:
+	echo "$$DOCKERFILE_PROTOC" | docker build . -f - -t temp
+	docker run --rm -v $(PWD):/s -w /s/internal/grpcapi temp \
+	sh -c "protoc --go_out=. --go_opt=paths=source_relative \
+	--go-grpc_out=. --go-grpc_opt=paths=source_relative api.proto"